@@ -0,0 +1,87 @@
+// Command seed fills the development database with fake users, sessions
+// and audit events, plus the two test accounts documented in the README
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/seed"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+func main() {
+	users := flag.Int("users", seed.DefaultUsers, "number of fake users to create besides the test accounts")
+	randSeed := flag.Uint64("seed", 0, "seed for the fake data; reruns with the same seed find the same users")
+	flag.Parse()
+
+	// Same configuration sources as the server
+	cfg, err := config.LoadFromFile(".env")
+	if err != nil {
+		cfg, err = config.LoadFromEnv()
+		if err != nil {
+			logger.Error("failed to load configuration", map[string]any{"error": err.Error()})
+			os.Exit(1)
+		}
+	}
+	cfg = cfg.Effective()
+
+	if cfg.IsProduction() {
+		logger.Error(seed.ErrProduction.Error(), nil)
+		os.Exit(1)
+	}
+	if cfg.DatabaseURL == "" {
+		logger.Error("DATABASE_URL is required; the in-memory stores don't outlive this command", nil)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	db, err := database.NewDB(ctx, cfg.DatabaseURL)
+	if err != nil {
+		logger.Error("failed to connect to database", map[string]any{"error": err.Error()})
+		os.Exit(1)
+	}
+	lifecycle := app.NewLifecycle()
+	lifecycle.RegisterShutdownHook("database", app.PriorityDatabase, func(context.Context) error {
+		db.Close()
+		return nil
+	})
+
+	code := 0
+	if err := run(ctx, db, seed.Options{Env: cfg.Env, Users: *users, Seed: *randSeed}); err != nil {
+		logger.Error(err.Error(), nil)
+		code = 1
+	}
+	if err := lifecycle.Shutdown(ctx); err != nil {
+		logger.Warn("shutdown finished with errors", map[string]any{"error": err.Error()})
+	}
+	os.Exit(code)
+}
+
+// run seeds the default tenant and logs what was created
+func run(ctx context.Context, db *database.DBPool, opts seed.Options) error {
+	// Seeded users belong to the default tenant
+	t, err := tenant.NewRepository(db).FindDefault(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find the default tenant: %w", err)
+	}
+
+	sum, err := seed.SeedDev(tenant.WithID(ctx, t.ID), seed.PgDB(db), opts)
+	if err != nil {
+		return fmt.Errorf("seeding failed: %w", err)
+	}
+	logger.Info("database seeded", map[string]any{
+		"tenant":       t.Slug,
+		"created":      sum.Created,
+		"existing":     sum.Existing,
+		"sessions":     sum.Sessions,
+		"audit_events": sum.AuditEvents,
+	})
+	return nil
+}
@@ -2,49 +2,142 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"flag"
+	"io"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	appdeps "dvith.com/go-service-api/internal/app"
 	"dvith.com/go-service-api/internal/config"
 	"dvith.com/go-service-api/internal/domain"
+	"dvith.com/go-service-api/internal/outbox"
+	"dvith.com/go-service-api/internal/preflight"
 	"dvith.com/go-service-api/pkg/database"
 	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/proxyproto"
 	"github.com/gofiber/fiber/v3"
 )
 
 func main() {
-	app := fiber.New()
+	check := flag.Bool("check", false, "run the startup preflight checks, print the report and exit")
+	flag.Parse()
 
 	// Prefer loading configuration from a local .env-like file into a
 	// Config object. If the file isn't present or fails to parse, fall
-	// back to reading from the process environment.
+	// back to reading from the process environment. Validation happens in
+	// the preflight, which reports every problem at once.
 	cfg, err := config.LoadFromFile(".env")
 	if err != nil {
 		logger.Warn("could not load configuration from .env file", map[string]any{"error": err.Error()})
-		cfg = config.MustLoadFromEnv()
+		cfg, err = config.LoadFromEnv()
+		if err != nil {
+			logger.Error("failed to load configuration", map[string]any{"error": err.Error()})
+			os.Exit(1)
+		}
 	}
 
+	// Fill in the ENV profile's defaults for everything not set explicitly.
+	// Logged before the level applies, so production still shows it.
+	cfg = cfg.Effective()
+	logger.Info("configuration profile", map[string]any{"profile": cfg.Profile(), "settings": cfg.PresetFields()})
+
 	logger.InitFromEnv(cfg.Env)
+	if level, ok := logger.ParseLevel(cfg.LogLevel); ok {
+		logger.SetLevel(level)
+	}
+	if cfg.LogFormat == "json" {
+		logger.SetJSON(true)
+	}
+	logger.SetMaxFieldLength(cfg.LogMaxFieldLength)
 
-	// If a database URL is provided, initialize the connection pool.
-	db, err := database.NewDB(context.Background(), cfg.DatabaseURL)
-	if err != nil {
-		logger.Error("failed to initialize database", map[string]any{"error": err.Error()})
+	// Request bodies are streamed so the user import can read uploads
+	// larger than BodyLimit; middleware.BodyLimit enforces it elsewhere
+	app := fiber.New(fiber.Config{
+		BodyLimit:         cfg.BodyLimit,
+		StreamRequestBody: true,
+	})
+
+	// If a database URL is provided, initialize the connection pool. In
+	// development without one, NewDeps falls back to in-memory stores.
+	var db *database.DBPool
+	var dbErr error
+	if !cfg.UsesMemoryStore() {
+		db, dbErr = database.NewDB(context.Background(), cfg.DatabaseURL)
+		if dbErr != nil {
+			logger.Error("failed to initialize database", map[string]any{"error": dbErr.Error()})
+		}
 	}
-	defer db.Close()
+
+	// Refuse to start with any problem, listing them all; --check stops
+	// here either way
+	if report := runPreflight(cfg, db, dbErr); !report.OK() {
+		os.Exit(1)
+	}
+	if *check {
+		if db != nil {
+			db.Close()
+		}
+		return
+	}
+
+	var logFile *os.File
+	if cfg.LogFile != "" {
+		f, err := os.OpenFile(cfg.LogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			logger.Error("failed to open log file", map[string]any{"error": err.Error(), "path": cfg.LogFile})
+			os.Exit(1)
+		}
+		logger.SetOutput(io.MultiWriter(os.Stdout, f))
+		logFile = f
+	}
+
+	// Shared dependencies are built once and threaded through the routes.
+	// Their lifecycle shuts the components down in priority order.
+	deps := appdeps.NewDeps(db, cfg)
+	registerShutdownHooks(deps.Lifecycle, app, db, logFile)
 
 	// set up routes and start the server
-	domain.Init(app, db, cfg)
+	domain.Init(app, deps)
+
+	// Background workers (outbox poller, scheduled jobs) stop on shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	deps.Lifecycle.RegisterShutdownHook("background", appdeps.PriorityBackground, func(ctx context.Context) error {
+		stopBackground()
+		return waitFor(ctx, deps.Runner.Wait)
+	})
+	if db != nil {
+		publishers := []outbox.Publisher{outbox.NewLogPublisher()}
+		if cfg.OutboxWebhookURL != "" {
+			publishers = append(publishers, outbox.NewWebhookPublisher(cfg.OutboxWebhookURL, 5*time.Second))
+		}
+		poller := outbox.NewPoller(outbox.NewPgStore(db), outbox.PollerConfig{
+			Interval:    cfg.OutboxPollInterval,
+			MaxAttempts: cfg.OutboxMaxAttempts,
+		}, publishers...)
+		go poller.Run(bgCtx)
+		go deps.Revocations.Listen(bgCtx, db)
+		deps.Runner.Start(bgCtx)
+	}
 
-	addr := fmt.Sprintf(":%d", cfg.Port)
+	// Readiness fails until the database answers, so traffic isn't routed
+	// here while the pool is still connecting
+	go warmUp(bgCtx, db, deps.Lifecycle, cfg.HealthCheckTimeout)
+
+	addr := cfg.Addr()
+	ln, err := listen(addr, cfg.EnableProxyProtocol)
+	if err != nil {
+		logger.Error("server listen error", map[string]any{"err": err.Error(), "addr": addr})
+		os.Exit(1)
+	}
 
 	// Start server in background so we can handle graceful shutdown.
 	srvErr := make(chan error, 1)
 	go func() {
-		srvErr <- app.Listen(addr)
+		srvErr <- app.Listener(ln)
 	}()
 
 	// trap signals for graceful shutdown
@@ -55,29 +148,105 @@ func main() {
 	case sig := <-sigCh:
 		logger.Info("shutdown signal received", map[string]any{"signal": sig.String()})
 
-		// give the server up to 10s to shut down gracefully
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		done := make(chan struct{})
-		go func() {
-			if err := app.Shutdown(); err != nil {
-				logger.Error("error during shutdown", map[string]any{"err": err.Error()})
-			}
-			close(done)
-		}()
-
-		select {
-		case <-done:
-			logger.Info("server stopped", nil)
-		case <-ctx.Done():
-			logger.Warn("graceful shutdown timed out", nil)
+		// Lame duck: readiness fails and background work is refused, but
+		// requests are still served until load balancers notice
+		_ = deps.Lifecycle.Drain()
+		if cfg.ShutdownDrainDelay > 0 {
+			time.Sleep(cfg.ShutdownDrainDelay)
+		}
+		if err := deps.Lifecycle.Shutdown(context.Background()); err != nil {
+			logger.Warn("shutdown finished with errors", map[string]any{"err": err.Error()})
 		}
 
 	case err := <-srvErr:
 		if err != nil {
 			logger.Error("server listen error", map[string]any{"err": err.Error(), "addr": addr})
+			_ = deps.Lifecycle.Shutdown(context.Background())
 			os.Exit(1)
 		}
 	}
 }
+
+// registerShutdownHooks stops the HTTP server, then closes the database and
+// the log file. NewDeps registers the hooks of the components it builds.
+func registerShutdownHooks(lifecycle *appdeps.Lifecycle, app *fiber.App, db *database.DBPool, logFile *os.File) {
+	lifecycle.RegisterShutdownHook("http_server", appdeps.PriorityHTTPServer, app.ShutdownWithContext)
+	if db != nil {
+		lifecycle.RegisterShutdownHook("database", appdeps.PriorityDatabase, func(ctx context.Context) error {
+			return waitFor(ctx, db.Close)
+		})
+	}
+	if logFile != nil {
+		lifecycle.RegisterShutdownHook("log_file", appdeps.PriorityLogger, func(ctx context.Context) error {
+			logger.SetOutput(os.Stdout)
+			return logFile.Close()
+		})
+	}
+}
+
+// waitFor runs fn, which can't be cancelled, returning early when ctx is done
+func waitFor(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runPreflight checks the config, database, secret and log file and logs
+// the report
+func runPreflight(cfg config.Config, db *database.DBPool, dbErr error) preflight.Report {
+	// A nil pool must reach preflight as a nil interface
+	var pdb preflight.DB
+	if db != nil {
+		pdb = db
+	}
+	report := preflight.Run(context.Background(), cfg, pdb, dbErr, preflight.Options{Timeout: cfg.HealthCheckTimeout})
+	report.Log()
+	return report
+}
+
+// listen binds addr over both IPv4 and IPv6 (Fiber's own Listen is IPv4
+// only) and, when proxyProtocol is set, strips the PROXY header from each
+// connection so c.IP() is the client behind the balancer
+func listen(addr string, proxyProtocol bool) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if proxyProtocol {
+		logger.Info("PROXY protocol enabled", map[string]any{"addr": addr})
+		return proxyproto.NewListener(ln, proxyproto.DefaultHeaderTimeout), nil
+	}
+	return ln, nil
+}
+
+// warmUp marks the lifecycle ready once the database answers a ping. It
+// retries until ctx ends; without a database it marks ready immediately.
+func warmUp(ctx context.Context, db *database.DBPool, lifecycle *appdeps.Lifecycle, pingTimeout time.Duration) {
+	for attempt := 1; db != nil; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err := db.Health(pingCtx)
+		cancel()
+		if err == nil {
+			break
+		}
+		logger.Warn("database not ready yet", map[string]any{"attempt": attempt, "error": err.Error()})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+
+	if err := lifecycle.MarkReady(); err != nil {
+		logger.Warn("could not mark service ready", map[string]any{"error": err.Error()})
+	}
+}
@@ -0,0 +1,178 @@
+// Package apiversion names the API versions served side by side under
+// /api/<version>, records which one a request is served as, and picks the
+// handler of routes that differ between versions.
+package apiversion
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+)
+
+// Version is an API version, served under /api/<version>
+type Version string
+
+// Supported versions
+const (
+	V1 Version = "v1"
+	V2 Version = "v2"
+)
+
+// Supported lists the versions served, oldest first
+var Supported = []Version{V1, V2}
+
+// Latest is the newest version, the one "latest" asks for
+const Latest = V2
+
+// Header names
+const (
+	// HeaderAcceptVersion asks for a version other than the one in the
+	// path, such as "v2" or "latest"
+	HeaderAcceptVersion = "Accept-Version"
+	// HeaderAPIVersion reports the version a response was served as
+	HeaderAPIVersion = "API-Version"
+)
+
+// LatestAlias is the HeaderAcceptVersion value asking for Latest
+const LatestAlias = "latest"
+
+// localsVersion holds the version the request is served as
+const localsVersion = "api_version"
+
+// Parse reads "v2", "2" or "latest", in any case, as a supported version
+func Parse(s string) (Version, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == LatestAlias {
+		return Latest, true
+	}
+	if !strings.HasPrefix(s, "v") {
+		s = "v" + s
+	}
+	v := Version(s)
+	return v, slices.Contains(Supported, v)
+}
+
+// Prefix is the path v is served under, such as /api/v2
+func (v Version) Prefix() string {
+	return "/api/" + string(v)
+}
+
+// RouteName is the fiber route name of the route called name in v. V1
+// routes keep their names, so route metadata resolves to them; those of
+// later versions are prefixed, as in "v2.auth.signin".
+func (v Version) RouteName(name string) string {
+	if v == V1 {
+		return name
+	}
+	return string(v) + "." + name
+}
+
+// index orders versions, -1 for unsupported ones
+func (v Version) index() int {
+	return slices.Index(Supported, v)
+}
+
+// FromCtx returns the version the request is served as, V1 outside the
+// versioned groups
+func FromCtx(c fiber.Ctx) Version {
+	if v, ok := c.Locals(localsVersion).(Version); ok {
+		return v
+	}
+	return V1
+}
+
+// Deprecation announces that a version is going away
+type Deprecation struct {
+	// Sunset is when the version stops being served
+	Sunset time.Time
+	// Successor is the path of the version replacing it
+	Successor string
+}
+
+// Middleware marks requests of its group as served as v and reports it in
+// HeaderAPIVersion. With a deprecation, responses also carry Deprecation,
+// Sunset (RFC 8594) and a successor-version Link.
+func Middleware(v Version, deprecation *Deprecation) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		c.Locals(localsVersion, v)
+		c.Set(HeaderAPIVersion, string(v))
+		if deprecation != nil {
+			c.Set("Deprecation", "true")
+			c.Set("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+			if deprecation.Successor != "" {
+				c.Append(fiber.HeaderLink, "<"+deprecation.Successor+`>; rel="successor-version"`)
+			}
+		}
+		return c.Next()
+	}
+}
+
+// versionedPath matches the version segment of an API path
+var versionedPath = regexp.MustCompile(`^/api/(v[0-9]+)(/|$)`)
+
+// Negotiate serves requests carrying HeaderAcceptVersion as the version
+// they ask for, rewriting /api/v1/... to /api/v2/... for "v2" or "latest".
+// It must run before routing, on the app rather than a group. Unknown
+// versions are rejected; requests without the header, or outside /api/vN,
+// are left alone.
+func Negotiate() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		requested := c.Get(HeaderAcceptVersion)
+		if requested == "" {
+			return c.Next()
+		}
+		path := c.Path()
+		m := versionedPath.FindStringSubmatchIndex(path)
+		if m == nil {
+			return c.Next()
+		}
+
+		c.Vary(HeaderAcceptVersion)
+		v, ok := Parse(requested)
+		if !ok {
+			return middleware.ValidationErrorResponse(c, "unsupported API version "+requested)
+		}
+		if current := Version(path[m[2]:m[3]]); current != v {
+			c.Path(v.Prefix() + path[m[3]:])
+		}
+		return c.Next()
+	}
+}
+
+// Handlers is a route's handler per version. A version without an entry
+// is served by the entry of the closest older version, so a route that is
+// the same as in v1 needs only a V1 entry, and one whose v2 response
+// changed has a V2 entry too. A nil entry, or no older one, answers 404:
+// the route was removed in that version or added in a later one.
+type Handlers map[Version]fiber.Handler
+
+// Handler returns the handler dispatching on FromCtx
+func (h Handlers) Handler() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if handler := h.For(FromCtx(c)); handler != nil {
+			return handler(c)
+		}
+		return middleware.NotFoundResponse(c, "route not found in API version "+string(FromCtx(c)))
+	}
+}
+
+// For returns the handler serving v, or nil
+func (h Handlers) For(v Version) fiber.Handler {
+	for i := v.index(); i >= 0; i-- {
+		if handler, ok := h[Supported[i]]; ok {
+			return handler
+		}
+	}
+	return nil
+}
+
+// Envelope is the v2 body of a successful response: the resource under
+// data, leaving the top level free for metadata added later
+type Envelope struct {
+	Data any `json:"data" xml:"data"`
+}
@@ -0,0 +1,106 @@
+package apiversion
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]Version{"v1": V1, "V2": V2, "2": V2, " latest ": Latest, "LATEST": Latest}
+	for in, want := range tests {
+		got, ok := Parse(in)
+		assert.True(t, ok, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"", "v3", "v", "beta"} {
+		_, ok := Parse(in)
+		assert.False(t, ok, in)
+	}
+}
+
+func TestHandlers_For(t *testing.T) {
+	v1 := func(c fiber.Ctx) error { return c.SendString("v1") }
+	v2 := func(c fiber.Ctx) error { return c.SendString("v2") }
+
+	same := Handlers{V1: v1}
+	assert.NotNil(t, same.For(V2), "a route unchanged since v1 is served in v2")
+
+	added := Handlers{V2: v2}
+	assert.Nil(t, added.For(V1), "a route added in v2 isn't served in v1")
+
+	removed := Handlers{V1: v1, V2: nil}
+	assert.Nil(t, removed.For(V2))
+}
+
+func serve(t *testing.T, app *fiber.App, path string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestMiddleware_Deprecation(t *testing.T) {
+	sunset := time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)
+	app := fiber.New()
+	app.Get("/api/v1/ping", Middleware(V1, &Deprecation{Sunset: sunset, Successor: "/api/v2"}), func(c fiber.Ctx) error {
+		return c.SendString(string(FromCtx(c)))
+	})
+	app.Get("/api/v2/ping", Middleware(V2, nil), func(c fiber.Ctx) error {
+		return c.SendString(string(FromCtx(c)))
+	})
+
+	resp, body := serve(t, app, "/api/v1/ping", nil)
+	assert.Equal(t, "v1", body)
+	assert.Equal(t, "true", resp.Header.Get("Deprecation"))
+	assert.Equal(t, "Wed, 30 Jun 2027 00:00:00 GMT", resp.Header.Get("Sunset"))
+	assert.Equal(t, `</api/v2>; rel="successor-version"`, resp.Header.Get(fiber.HeaderLink))
+
+	resp, body = serve(t, app, "/api/v2/ping", nil)
+	assert.Equal(t, "v2", body)
+	assert.Equal(t, "v2", resp.Header.Get(HeaderAPIVersion))
+	assert.Empty(t, resp.Header.Get("Deprecation"))
+	assert.Empty(t, resp.Header.Get("Sunset"))
+}
+
+func TestNegotiate(t *testing.T) {
+	app := fiber.New()
+	app.Use(Negotiate())
+	for _, v := range Supported {
+		app.Get(v.Prefix()+"/ping", Middleware(v, nil), func(c fiber.Ctx) error {
+			return c.SendString(string(FromCtx(c)))
+		})
+	}
+	app.Get("/health", func(c fiber.Ctx) error { return c.SendString("ok") })
+
+	tests := []struct {
+		path, accept, want string
+	}{
+		{"/api/v1/ping", "", "v1"},
+		{"/api/v1/ping", "latest", string(Latest)},
+		{"/api/v1/ping", "v2", "v2"},
+		{"/api/v2/ping", "v1", "v1"},
+		{"/api/v2/ping", "2", "v2"},
+		{"/health", "latest", "ok"},
+	}
+	for _, tt := range tests {
+		resp, body := serve(t, app, tt.path, http.Header{HeaderAcceptVersion: {tt.accept}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode, tt.path+" "+tt.accept)
+		assert.Equal(t, tt.want, body, tt.path+" "+tt.accept)
+	}
+
+	resp, _ := serve(t, app, "/api/v1/ping", http.Header{HeaderAcceptVersion: {"v9"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,271 @@
+package app
+
+import (
+	"time"
+
+	"dvith.com/go-service-api/internal/capture"
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/events"
+	"dvith.com/go-service-api/internal/features"
+	"dvith.com/go-service-api/internal/jobs"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/routemeta"
+	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/crypto"
+	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/pkg/geoip"
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/mailer"
+	"dvith.com/go-service-api/pkg/metrics"
+	"dvith.com/go-service-api/pkg/signedurl"
+	"dvith.com/go-service-api/pkg/storage"
+)
+
+// Deps holds the shared dependencies built once at startup and threaded
+// through route registration
+type Deps struct {
+	DB           *database.DBPool
+	Cfg          config.Config
+	TokenManager *token.TokenManager
+	Logger       *logger.Logger
+	Cache        cache.Cache
+	Storage      storage.Backend
+	Mailer       mailer.Mailer
+	GeoIP        geoip.Resolver
+	Tenants      tenant.Store
+	Runner       *jobs.Runner
+	Routes       *routemeta.Registry
+	Features     *features.Flags
+	Stores       Stores
+	Lifecycle    *Lifecycle
+	Links        *signedurl.Signer
+	Capture      *capture.Capturer
+	Faults       *middleware.FaultInjector
+	Revocations  *session.Revocations
+	// ErrorRate tracks the 5xx rate the readiness probe degrades on; nil
+	// when HEALTH_ERROR_RATE_THRESHOLD is 0
+	ErrorRate *middleware.ErrorRateTracker
+	// KeyRing encrypts sensitive columns; nil when no keys are configured
+	KeyRing *crypto.KeyRing
+	// Events carries domain events from the services; modules subscribe
+	// with events.On when they register their routes
+	Events *events.Bus
+	// Clock is the time source of the services modules build
+	Clock func() time.Time
+	// Hasher hashes and verifies passwords, with calibrated parameters
+	// under CALIBRATE_HASH_PARAMS
+	Hasher hashpassword.Hasher
+}
+
+// NewDeps builds the dependency container from the loaded configuration.
+// In development without a DATABASE_URL the auth and account routes run on
+// in-memory stores instead of Postgres.
+func NewDeps(db *database.DBPool, cfg config.Config) *Deps {
+	deps := &Deps{
+		DB:  db,
+		Cfg: cfg,
+		TokenManager: token.NewTokenManager(token.TokenConfig{
+			SecretKey:         cfg.JWTSecretKey,
+			TTLs:              tokenTTLs(cfg.TokenTTLs),
+			Issuer:            cfg.JWTIssuer,
+			ClientProfiles:    clientProfiles(cfg.JWTClientProfiles),
+			RequireSession:    cfg.SessionClaimRequired,
+			AdditionalIssuers: additionalIssuers(cfg.JWTAdditionalIssuers),
+		}),
+		Logger:    logger.Std(),
+		Cache:     cache.NewMemory(),
+		Mailer:    mailer.NewLogMailer(),
+		GeoIP:     geoip.NewNoopResolver(),
+		Tenants:   tenant.NewRepository(db),
+		Runner:    jobs.NewRunner(),
+		Routes:    routemeta.NewRegistry(),
+		Features:  features.NewFlags(cfg.Features),
+		Stores:    PgStores(db),
+		Lifecycle: NewLifecycle(),
+		Links:     signedurl.MustNew(cfg.AbsoluteURL("", nil), cfg.JWTSecretKey),
+		Clock:     clock.Now,
+	}
+	deps.Capture = capture.NewCapturer(deps.Cache)
+
+	// Preflight has already rejected a malformed FAULT_INJECTION
+	faults, _ := middleware.ParseFaultRules(cfg.FaultInjection)
+	deps.Faults = middleware.NewFaultInjector(faults, cfg.IsProduction())
+
+	if cfg.HealthErrorRateThreshold > 0 {
+		deps.ErrorRate = middleware.NewErrorRateTracker(middleware.ErrorRateConfig{
+			Threshold:   cfg.HealthErrorRateThreshold,
+			Recovery:    cfg.HealthErrorRateRecovery,
+			MinRequests: cfg.HealthErrorRateMinRequests,
+		})
+	}
+
+	// Revoked sessions are remembered for as long as their access tokens
+	// could still be valid
+	deps.Revocations = session.NewRevocations(deps.Cache, longestAccessTTL(cfg))
+	deps.TokenManager.WithSessionRevocations(deps.Revocations)
+	if cfg.TokenValidationCacheSize > 0 {
+		deps.TokenManager.WithValidationCache(token.NewValidationCache(cfg.TokenValidationCacheSize))
+	}
+
+	// Preflight has already rejected malformed keys. Encrypted struct
+	// fields read the ring from the crypto package, since pgx scans them
+	// without any way to pass it along.
+	deps.KeyRing, _ = cfg.KeyRing()
+	crypto.SetDefault(deps.KeyRing)
+	if deps.KeyRing == nil && cfg.IsProduction() {
+		logger.Warn("no ENCRYPTION_KEYS or ENCRYPTION_MASTER_KEY: sensitive columns are stored in plaintext", nil)
+	}
+
+	deps.Storage = newStorage(cfg)
+	deps.Hasher = newHasher(cfg)
+
+	if cfg.ShutdownHookTimeout > 0 {
+		deps.Lifecycle.WithHookTimeout(cfg.ShutdownHookTimeout)
+	}
+
+	deps.Events = events.NewBus()
+	events.SubscribeMetrics(deps.Events, metrics.Default)
+	// Queued events, such as signin webhooks, are delivered before exit
+	deps.Lifecycle.RegisterShutdownHook("events", PriorityEvents, deps.Events.Close)
+
+	if cfg.UsesMemoryStore() {
+		logger.Warn("no DATABASE_URL in development: using in-memory stores, all data is lost on restart", map[string]any{
+			"backed_routes": "signup, signin, refresh-token, profile, sessions, preferences, account deletion",
+		})
+		deps.Stores = MemoryStores()
+		deps.Tenants = tenant.NewMemoryStore()
+	} else {
+		events.SubscribeAudit(deps.Events, db)
+		events.SubscribeWebhooks(deps.Events, db)
+	}
+	deps.cacheUsers()
+
+	return deps
+}
+
+// WithClock replaces the time source of the dependencies, and of the
+// services modules build from them, for tests. It must be called before
+// the routes are registered; in-memory stores are replaced by empty ones.
+func (d *Deps) WithClock(now func() time.Time) *Deps {
+	d.Clock = now
+	d.TokenManager.WithClock(now)
+	d.Links.WithClock(now)
+	d.Capture.WithClock(now)
+	if m, ok := d.Cache.(*cache.Memory); ok {
+		m.WithClock(now)
+	}
+	if d.Cfg.UsesMemoryStore() {
+		d.Stores = memoryStores(now)
+		d.cacheUsers()
+	}
+	return d
+}
+
+// cacheUsers shares the users loaded by middleware.GetCurrentUser across
+// requests for USER_CACHE_TTL; the writes made through Stores.Users purge
+// them
+func (d *Deps) cacheUsers() {
+	if d.Cfg.UserCacheTTL > 0 {
+		d.Stores.Users = model.NewCachedStore(d.Stores.Users, d.Cache, d.Cfg.UserCacheTTL)
+	}
+}
+
+// newStorage keeps files under STORAGE_DIR, or in memory without one
+func newStorage(cfg config.Config) storage.Backend {
+	if cfg.StorageDir == "" {
+		if cfg.IsProduction() {
+			logger.Warn("no STORAGE_DIR: data exports are kept in memory and lost on restart", nil)
+		}
+		return storage.NewMemory()
+	}
+
+	dir, err := storage.NewDir(cfg.StorageDir)
+	if err != nil {
+		logger.Error("failed to open STORAGE_DIR, keeping files in memory", map[string]any{
+			"dir":   cfg.StorageDir,
+			"error": err.Error(),
+		})
+		return storage.NewMemory()
+	}
+	return dir
+}
+
+// newHasher hashes with the default Argon2 parameters, or with those
+// calibrated for this host under CALIBRATE_HASH_PARAMS. Hashes record
+// their parameters, so the existing ones keep verifying when the choice
+// changes and are upgraded at the next signin.
+func newHasher(cfg config.Config) hashpassword.Hasher {
+	if !cfg.CalibrateHashParams {
+		return hashpassword.Default
+	}
+
+	params, took, err := hashpassword.CalibrateArgon2(hashpassword.CalibrationTarget, uint32(cfg.PasswordHashMaxMemoryMB)*1024)
+	if err != nil {
+		logger.Error("failed to calibrate password hashing, using the defaults", map[string]any{
+			"error": err.Error(),
+		})
+		return hashpassword.Default
+	}
+	logger.Info("calibrated password hashing", map[string]any{
+		"time":        params.Time,
+		"memory_kib":  params.Memory,
+		"parallelism": params.Parallelism,
+		"took_ms":     took.Milliseconds(),
+	})
+	return hashpassword.NewArgon2(params)
+}
+
+// longestAccessTTL is the longest lifetime of any access token issued
+func longestAccessTTL(cfg config.Config) time.Duration {
+	ttl := cfg.TokenTTLs.Access
+	for _, p := range cfg.JWTClientProfiles {
+		ttl = max(ttl, p.AccessTTL)
+	}
+	return ttl
+}
+
+// tokenTTLs converts the configured token lifetimes for the token manager
+func tokenTTLs(t config.TokenTTLs) map[token.Kind]time.Duration {
+	return map[token.Kind]time.Duration{
+		token.KindAccess:        t.Access,
+		token.KindRefresh:       t.Refresh,
+		token.KindMFA:           t.MFA,
+		token.KindReset:         t.Reset,
+		token.KindVerification:  t.Verification,
+		token.KindImpersonation: t.Impersonation,
+	}
+}
+
+// clientProfiles converts the configured client profiles for the token manager
+func clientProfiles(in config.ClientProfiles) map[string]token.ClientProfile {
+	out := make(map[string]token.ClientProfile, len(in))
+	for id, p := range in {
+		out[id] = token.ClientProfile{
+			Audience:   p.Audience,
+			AccessTTL:  p.AccessTTL,
+			RefreshTTL: p.RefreshTTL,
+		}
+	}
+	return out
+}
+
+// additionalIssuers converts the configured issuers for the token manager
+func additionalIssuers(in config.Issuers) []token.IssuerConfig {
+	out := make([]token.IssuerConfig, 0, len(in))
+	for _, iss := range in {
+		out = append(out, token.IssuerConfig{
+			Issuer:      iss.Issuer,
+			Audience:    iss.Audience,
+			Key:         iss.Key,
+			JWKSURL:     iss.JWKSURL,
+			JWKSRefresh: iss.JWKSRefresh,
+		})
+	}
+	return out
+}
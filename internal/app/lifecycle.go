@@ -0,0 +1,152 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/errs"
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/metrics"
+)
+
+// State is a phase of the process lifecycle
+type State string
+
+// Lifecycle states, in the only order they can be entered
+const (
+	StateStarting State = "starting"
+	StateReady    State = "ready"
+	StateDraining State = "draining"
+	StateStopped  State = "stopped"
+)
+
+// ErrDraining is returned by components that refuse new background work
+// because the process is shutting down
+var ErrDraining = errors.New("service is draining")
+
+var (
+	lifecycleTransitions = metrics.NewCounter("app_lifecycle_transitions_total", "Lifecycle state transitions.", "from", "to")
+	lifecycleState       = metrics.NewGauge("app_lifecycle_state", "1 for the current lifecycle state, 0 otherwise.", "state")
+)
+
+func init() {
+	errs.Register(errs.Translation{
+		Name:       "draining",
+		Match:      func(err error) bool { return errors.Is(err, ErrDraining) },
+		Status:     http.StatusServiceUnavailable,
+		Code:       "draining",
+		Message:    "the service is shutting down, retry shortly",
+		RetryAfter: time.Second,
+	})
+}
+
+// transitions lists the states reachable from each state. Startup may be
+// aborted straight into draining, but nothing ever goes back.
+var transitions = map[State][]State{
+	StateStarting: {StateReady, StateDraining, StateStopped},
+	StateReady:    {StateDraining, StateStopped},
+	StateDraining: {StateStopped},
+}
+
+// Lifecycle tracks whether the process is warming up, serving, draining
+// (lame duck) or stopped. The bootstrap marks it ready once dependencies
+// are warm and the shutdown sequence drains it; the readiness probe and
+// background work producers read it. Components register hooks to release
+// themselves when it is shut down.
+type Lifecycle struct {
+	mu          sync.RWMutex
+	state       State
+	hooks       []shutdownHook
+	hookTimeout time.Duration
+}
+
+// NewLifecycle creates a lifecycle in the starting state
+func NewLifecycle() *Lifecycle {
+	l := &Lifecycle{state: StateStarting, hookTimeout: DefaultHookTimeout}
+	l.publish()
+	return l
+}
+
+// State returns the current state
+func (l *Lifecycle) State() State {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.state
+}
+
+// Ready reports whether the process should receive traffic
+func (l *Lifecycle) Ready() bool {
+	return l.State() == StateReady
+}
+
+// AcceptingWork reports whether new background work (emails, webhooks) may
+// be started. Work is refused once draining begins so it isn't cut off
+// mid-flight by the shutdown.
+func (l *Lifecycle) AcceptingWork() bool {
+	s := l.State()
+	return s == StateStarting || s == StateReady
+}
+
+// Transition moves to the given state. Moving to the current state is a
+// no-op; moving backwards or skipping past stopped is an error.
+func (l *Lifecycle) Transition(to State) error {
+	l.mu.Lock()
+	from := l.state
+	if from == to {
+		l.mu.Unlock()
+		return nil
+	}
+	if !allowed(from, to) {
+		l.mu.Unlock()
+		return fmt.Errorf("invalid lifecycle transition from %s to %s", from, to)
+	}
+	l.state = to
+	l.publish()
+	l.mu.Unlock()
+
+	lifecycleTransitions.Inc(string(from), string(to))
+	logger.Info("lifecycle state changed", map[string]any{
+		"from": string(from),
+		"to":   string(to),
+	})
+	return nil
+}
+
+// MarkReady moves from starting to ready
+func (l *Lifecycle) MarkReady() error {
+	return l.Transition(StateReady)
+}
+
+// Drain enters lame-duck mode: readiness fails and new background work is
+// refused while in-flight requests finish
+func (l *Lifecycle) Drain() error {
+	return l.Transition(StateDraining)
+}
+
+// Stop marks the process stopped
+func (l *Lifecycle) Stop() error {
+	return l.Transition(StateStopped)
+}
+
+// publish sets the state gauge. Callers hold the lock.
+func (l *Lifecycle) publish() {
+	for _, s := range []State{StateStarting, StateReady, StateDraining, StateStopped} {
+		v := 0.0
+		if s == l.state {
+			v = 1
+		}
+		lifecycleState.Set(v, string(s))
+	}
+}
+
+func allowed(from, to State) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
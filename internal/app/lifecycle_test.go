@@ -0,0 +1,70 @@
+package app
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle_HappyPath(t *testing.T) {
+	l := NewLifecycle()
+	assert.Equal(t, StateStarting, l.State())
+	assert.False(t, l.Ready())
+	assert.True(t, l.AcceptingWork())
+
+	require.NoError(t, l.MarkReady())
+	assert.True(t, l.Ready())
+	assert.True(t, l.AcceptingWork())
+	assert.Equal(t, float64(1), lifecycleState.Value(string(StateReady)))
+	assert.Equal(t, float64(0), lifecycleState.Value(string(StateStarting)))
+
+	require.NoError(t, l.Drain())
+	assert.False(t, l.Ready())
+	assert.False(t, l.AcceptingWork())
+
+	require.NoError(t, l.Stop())
+	assert.Equal(t, StateStopped, l.State())
+}
+
+func TestLifecycle_RejectsBackwardTransitions(t *testing.T) {
+	l := NewLifecycle()
+	require.NoError(t, l.Drain(), "startup can be aborted straight into draining")
+
+	assert.Error(t, l.MarkReady())
+	assert.Equal(t, StateDraining, l.State())
+
+	require.NoError(t, l.Stop())
+	assert.Error(t, l.Drain())
+	assert.Error(t, l.Transition(StateStarting))
+}
+
+func TestLifecycle_SameStateIsNoop(t *testing.T) {
+	l := NewLifecycle()
+	require.NoError(t, l.MarkReady())
+
+	before := lifecycleTransitions.Value(string(StateStarting), string(StateReady))
+	require.NoError(t, l.MarkReady())
+	assert.Equal(t, before, lifecycleTransitions.Value(string(StateStarting), string(StateReady)))
+}
+
+func TestLifecycle_ConcurrentDrain(t *testing.T) {
+	l := NewLifecycle()
+	require.NoError(t, l.MarkReady())
+
+	before := lifecycleTransitions.Value(string(StateReady), string(StateDraining))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = l.Drain()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, StateDraining, l.State())
+	assert.Equal(t, before+1, lifecycleTransitions.Value(string(StateReady), string(StateDraining)), "the transition happens once")
+}
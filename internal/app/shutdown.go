@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+// Shutdown hook priorities. Hooks run from the lowest priority up, so
+// components stop before the ones they depend on.
+const (
+	// PriorityHTTPServer stops accepting requests and finishes in-flight ones
+	PriorityHTTPServer = 100
+	// PriorityBackground stops the job scheduler, outbox poller and other
+	// background workers
+	PriorityBackground = 200
+	// PriorityEvents delivers queued events, such as webhooks
+	PriorityEvents = 300
+	// PriorityDatabase closes the connection pool, once nothing uses it
+	PriorityDatabase = 400
+	// PriorityLogger flushes and closes log outputs, last so every other
+	// hook is logged
+	PriorityLogger = 500
+)
+
+// DefaultHookTimeout bounds each shutdown hook unless WithHookTimeout says
+// otherwise
+const DefaultHookTimeout = 10 * time.Second
+
+// ShutdownHook releases a component on shutdown. It should return once ctx
+// is done; it is abandoned then if it doesn't.
+type ShutdownHook func(ctx context.Context) error
+
+type shutdownHook struct {
+	name     string
+	priority int
+	fn       ShutdownHook
+}
+
+// WithHookTimeout sets how long each shutdown hook may run
+func (l *Lifecycle) WithHookTimeout(d time.Duration) *Lifecycle {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hookTimeout = d
+	return l
+}
+
+// RegisterShutdownHook adds fn to run on Shutdown. Hooks run one at a time
+// by ascending priority, see PriorityHTTPServer and the others; those of
+// equal priority run in the order they were registered.
+func (l *Lifecycle) RegisterShutdownHook(name string, priority int, fn ShutdownHook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, shutdownHook{name: name, priority: priority, fn: fn})
+}
+
+// Shutdown drains the lifecycle, runs the shutdown hooks and marks it
+// stopped. Each hook gets its own timeout and a failing or hung hook
+// doesn't keep the others from running; their errors are joined.
+func (l *Lifecycle) Shutdown(ctx context.Context) error {
+	_ = l.Drain()
+
+	l.mu.Lock()
+	hooks := append([]shutdownHook(nil), l.hooks...)
+	l.hooks = nil
+	timeout := l.hookTimeout
+	l.mu.Unlock()
+	sort.SliceStable(hooks, func(i, j int) bool { return hooks[i].priority < hooks[j].priority })
+
+	var errs []error
+	for _, h := range hooks {
+		start := time.Now()
+		err := runHook(ctx, h, timeout)
+		fields := map[string]any{
+			"hook":     h.name,
+			"priority": h.priority,
+			"duration": time.Since(start).String(),
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+			logger.Error("shutdown hook failed", fields)
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		logger.Info("shutdown hook done", fields)
+	}
+
+	_ = l.Stop()
+	return errors.Join(errs...)
+}
+
+// runHook runs h with timeout, returning early if it is exceeded
+func runHook(ctx context.Context, h shutdownHook, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- h.fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s: %w", timeout, ctx.Err())
+	}
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hookRecorder records the order hooks ran in
+type hookRecorder struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *hookRecorder) hook(name string, err error) ShutdownHook {
+	return func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.ran = append(r.ran, name)
+		return err
+	}
+}
+
+func TestShutdown_RunsHooksByPriority(t *testing.T) {
+	l := NewLifecycle()
+	r := &hookRecorder{}
+	l.RegisterShutdownHook("logger", PriorityLogger, r.hook("logger", nil))
+	l.RegisterShutdownHook("database", PriorityDatabase, r.hook("database", nil))
+	l.RegisterShutdownHook("http_server", PriorityHTTPServer, r.hook("http_server", nil))
+	l.RegisterShutdownHook("jobs", PriorityBackground, r.hook("jobs", nil))
+	l.RegisterShutdownHook("outbox", PriorityBackground, r.hook("outbox", nil))
+
+	require.NoError(t, l.Shutdown(context.Background()))
+	assert.Equal(t, []string{"http_server", "jobs", "outbox", "database", "logger"}, r.ran)
+	assert.Equal(t, StateStopped, l.State())
+}
+
+func TestShutdown_FailingHookDoesNotStopOthers(t *testing.T) {
+	l := NewLifecycle()
+	r := &hookRecorder{}
+	errFlush := errors.New("flush failed")
+	l.RegisterShutdownHook("first", 1, r.hook("first", nil))
+	l.RegisterShutdownHook("failing", 2, r.hook("failing", errFlush))
+	l.RegisterShutdownHook("panicking", 3, func(ctx context.Context) error { panic("boom") })
+	l.RegisterShutdownHook("last", 4, r.hook("last", nil))
+
+	err := l.Shutdown(context.Background())
+	assert.ErrorIs(t, err, errFlush)
+	assert.ErrorContains(t, err, "failing: flush failed")
+	assert.ErrorContains(t, err, "panicking: panic: boom")
+	assert.Equal(t, []string{"first", "failing", "last"}, r.ran)
+	assert.Equal(t, StateStopped, l.State())
+}
+
+func TestShutdown_SlowHookTimesOut(t *testing.T) {
+	l := NewLifecycle().WithHookTimeout(20 * time.Millisecond)
+	r := &hookRecorder{}
+	release := make(chan struct{})
+	defer close(release)
+	l.RegisterShutdownHook("respects_ctx", 1, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	l.RegisterShutdownHook("ignores_ctx", 2, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	l.RegisterShutdownHook("after", 3, r.hook("after", nil))
+
+	start := time.Now()
+	err := l.Shutdown(context.Background())
+	assert.Less(t, time.Since(start), time.Second, "a hung hook must not hold up shutdown")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "respects_ctx")
+	assert.ErrorContains(t, err, "ignores_ctx: timed out after 20ms")
+	assert.Equal(t, []string{"after"}, r.ran)
+}
+
+func TestShutdown_RunsHooksOnce(t *testing.T) {
+	l := NewLifecycle()
+	r := &hookRecorder{}
+	l.RegisterShutdownHook("database", PriorityDatabase, r.hook("database", nil))
+	require.NoError(t, l.MarkReady())
+
+	require.NoError(t, l.Shutdown(context.Background()))
+	require.NoError(t, l.Shutdown(context.Background()))
+	assert.Equal(t, []string{"database"}, r.ran)
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"dvith.com/go-service-api/internal/autorole"
+	magiclink "dvith.com/go-service-api/internal/domain/authentication/magic_link"
+	refreshtoken "dvith.com/go-service-api/internal/domain/authentication/refresh_token"
+	"dvith.com/go-service-api/internal/domain/authentication/signin"
+	"dvith.com/go-service-api/internal/domain/authentication/signup"
+	"dvith.com/go-service-api/internal/domain/user/exportjob"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/invitation"
+	"dvith.com/go-service-api/internal/preferences"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+)
+
+// Stores are the repositories behind the signup, invitation, signin, magic
+// link, refresh, account, preferences and export job routes, so they can
+// run on Postgres or in memory
+type Stores struct {
+	Users         model.Store
+	Sessions      session.Store
+	RefreshTokens refreshtoken.Store
+	Signups       signup.Repository
+	// InvitedSignups saves users while signup is invite only
+	InvitedSignups signup.InviteRepository
+	Invitations    invitation.Store
+	// DomainRoles are the signup domain role rules admins manage, and
+	// RoleGrants the roles they granted
+	DomainRoles autorole.Store
+	RoleGrants  autorole.GrantStore
+	Signins     signin.Repository
+	Preferences preferences.Store
+	MagicLinks  magiclink.Store
+	ExportJobs  exportjob.Store
+	// Accounts is nil on Postgres, where the user routes use their own
+	// repository so deletion also writes audit and outbox records
+	Accounts AccountStore
+}
+
+// AccountStore deletes the authenticated user's account
+type AccountStore interface {
+	SoftDeleteUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// PgStores builds the Postgres-backed stores
+func PgStores(db *database.DBPool) Stores {
+	signups := signup.NewSignupRepository(db)
+	roles := autorole.NewRepository(db)
+	return Stores{
+		Users:          model.NewUserRepository(db),
+		Sessions:       session.NewRepository(db),
+		RefreshTokens:  refreshtoken.NewRepository(db),
+		Signups:        signups,
+		InvitedSignups: signups,
+		Invitations:    invitation.NewRepository(db),
+		DomainRoles:    roles,
+		RoleGrants:     roles,
+		Signins:        signin.NewSigninRepository(db),
+		Preferences:    preferences.NewRepository(db),
+		MagicLinks:     magiclink.NewPgRepository(db),
+		ExportJobs:     exportjob.NewPgRepository(db),
+	}
+}
+
+// MemoryStores builds stores that keep everything in process memory.
+// Audit events and outbox messages are not recorded.
+func MemoryStores() Stores {
+	return memoryStores(clock.Now)
+}
+
+// memoryStores is MemoryStores timestamping with now
+func memoryStores(now func() time.Time) Stores {
+	users := model.NewMemoryStore().WithClock(now)
+	sessions := session.NewMemoryStore().WithClock(now)
+	invitations := invitation.NewMemoryStore().WithClock(now)
+	roles := autorole.NewMemoryStore().WithClock(now)
+	return Stores{
+		Users:          users,
+		Sessions:       sessions,
+		RefreshTokens:  refreshtoken.NewMemoryStore().WithSessions(sessions),
+		Signups:        users,
+		InvitedSignups: memoryInvitedSignups{users: users, invitations: invitations},
+		Invitations:    invitations,
+		DomainRoles:    roles,
+		RoleGrants:     roles,
+		Signins:        memorySignins{users: users, sessions: sessions},
+		Preferences:    preferences.NewMemoryStore().WithClock(now),
+		MagicLinks:     magiclink.NewMemoryStore(),
+		ExportJobs:     exportjob.NewMemoryStore(),
+		Accounts:       memoryAccounts{users: users, sessions: sessions},
+	}
+}
+
+// memoryInvitedSignups adapts the in-memory stores to
+// signup.InviteRepository
+type memoryInvitedSignups struct {
+	users       *model.MemoryStore
+	invitations *invitation.MemoryStore
+}
+
+func (m memoryInvitedSignups) SaveInvitedUser(ctx context.Context, user *model.User, codeHash string, now time.Time) (*model.User, error) {
+	var saved *model.User
+	_, err := m.invitations.Redeem(ctx, codeHash, now, func() error {
+		var err error
+		saved, err = m.users.SaveUser(ctx, user)
+		return err
+	})
+	return saved, err
+}
+
+func (m memoryInvitedSignups) CheckInvitation(ctx context.Context, codeHash string, now time.Time) error {
+	return m.invitations.Check(ctx, codeHash, now)
+}
+
+// memorySignins adapts the in-memory stores to signin.Repository
+type memorySignins struct {
+	users    *model.MemoryStore
+	sessions *session.MemoryStore
+}
+
+func (m memorySignins) FindUser(ctx context.Context, email string) (*model.User, error) {
+	user, err := m.users.FindByEmail(ctx, email, model.ActiveOnly)
+	if errors.Is(err, model.ErrUserNotFound) {
+		return nil, nil
+	}
+	return user, err
+}
+
+func (m memorySignins) CreateSession(ctx context.Context, s *session.Session, limit session.Limit) ([]uuid.UUID, error) {
+	return m.sessions.CreateLimited(ctx, s, limit)
+}
+
+func (m memorySignins) RehashPassword(ctx context.Context, userID uuid.UUID, oldHash, newHash string) error {
+	return m.users.RehashPassword(ctx, userID, oldHash, newHash)
+}
+
+// memoryAccounts adapts the in-memory stores to AccountStore
+type memoryAccounts struct {
+	users    *model.MemoryStore
+	sessions *session.MemoryStore
+}
+
+func (m memoryAccounts) SoftDeleteUser(ctx context.Context, userID uuid.UUID) error {
+	return m.users.SoftDelete(ctx, userID)
+}
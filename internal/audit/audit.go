@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/queries"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+)
+
+// Audit actions
+const (
+	ActionUserRegistered = "user.registered"
+	ActionUserSignedIn   = "user.signed_in"
+	ActionTokenRefreshed = "user.token_refreshed"
+	ActionUserDeleted    = "user.deleted"
+	ActionDataExported   = "user.data_exported"
+	ActionUserPurged     = "user.purged"
+	ActionUsersImported  = "users.imported"
+	// ActionPasswordResetRequired is recorded on the user an admin forced
+	// to reset their password
+	ActionPasswordResetRequired = "user.password_reset_required"
+	// Email change actions, recorded on the user whose email changes
+	ActionEmailChangeRequested = "user.email_change_requested"
+	ActionEmailChanged         = "user.email_changed"
+	ActionEmailChangeCancelled = "user.email_change_cancelled"
+)
+
+// Event represents a row in the audit_events table
+type Event struct {
+	ID        uuid.UUID       `db:"id" json:"id"`
+	TenantID  *uuid.UUID      `db:"tenant_id" json:"-"`
+	UserID    *uuid.UUID      `db:"user_id" json:"user_id,omitempty"`
+	Action    string          `db:"action" json:"action"`
+	IP        *string         `db:"ip" json:"ip,omitempty"`
+	UserAgent *string         `db:"user_agent" json:"user_agent,omitempty"`
+	Metadata  json.RawMessage `db:"metadata" json:"metadata,omitempty"`
+	CreatedAt time.Time       `db:"created_at" json:"created_at"`
+}
+
+// Entry describes an audit event to record
+type Entry struct {
+	UserID    uuid.UUID
+	Action    string
+	IP        string
+	UserAgent string
+	Metadata  map[string]any
+}
+
+// Record writes an audit event using q. Pass the transaction performing
+// the audited mutation so both commit or roll back together.
+func Record(ctx context.Context, q database.Querier, e Entry) error {
+	metadata := []byte("{}")
+	if len(e.Metadata) > 0 {
+		data, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit metadata: %w", err)
+		}
+		metadata = data
+	}
+
+	var tenantID *uuid.UUID
+	if id, ok := tenant.IDFromContext(ctx); ok {
+		tenantID = &id
+	}
+
+	var userID *uuid.UUID
+	if e.UserID != uuid.Nil {
+		userID = &e.UserID
+	}
+
+	if _, err := q.Exec(ctx, queries.AuditInsert.SQL, uuid.New(), tenantID, userID, e.Action, nullable(e.IP), nullable(e.UserAgent), metadata, clock.Now()); err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+
+	return nil
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// Repository reads audit events
+type Repository struct {
+	db *database.DBPool
+}
+
+// NewRepository creates a new audit repository
+func NewRepository(db *database.DBPool) *Repository {
+	return &Repository{db: db}
+}
+
+// StreamByUser calls fn for each of the user's audit events in chronological
+// order. Rows are iterated rather than collected so large histories are
+// never held in memory at once.
+func (repo *Repository) StreamByUser(ctx context.Context, userID uuid.UUID, fn func(Event) error) error {
+	rows, err := repo.db.Query(ctx, queries.AuditStreamByUser.SQL, userID)
+	if err != nil {
+		return fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := database.ScanRow[Event](rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
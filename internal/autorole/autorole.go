@@ -0,0 +1,290 @@
+// Package autorole assigns roles to users at signup by the domain of their
+// email. The rules come from Config.SignupDomainRoles and from the
+// signup_domain_roles table admins manage at runtime, which wins for the
+// same domain. Assigned roles are granted in user_roles, on top of
+// users.role, and embedded in issued tokens.
+package autorole
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotFound is returned when deleting a rule that doesn't exist in the
+// tenant
+var ErrNotFound = errors.New("domain role rule not found")
+
+// Rules maps lower-case email domains to the role signups from them get
+type Rules map[string]string
+
+// Match returns the role for the domain of email. Domains match exactly,
+// ignoring case: a rule for acme.com doesn't cover eu.acme.com.
+func (r Rules) Match(email string) (string, bool) {
+	role, ok := r[Domain(email)]
+	return role, ok && role != ""
+}
+
+// Domain returns the lower-case domain of email, empty without an '@'
+func Domain(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[at+1:]))
+}
+
+// Rule is a row in the signup_domain_roles table
+type Rule struct {
+	TenantID  uuid.UUID `db:"tenant_id" json:"-"`
+	Domain    string    `db:"domain" json:"domain"`
+	Role      string    `db:"role" json:"role"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Grant is a row in the user_roles table
+type Grant struct {
+	TenantID              uuid.UUID `db:"tenant_id" json:"-"`
+	UserID                uuid.UUID `db:"user_id" json:"user_id"`
+	Role                  string    `db:"role" json:"role"`
+	RequiresVerifiedEmail bool      `db:"requires_verified_email" json:"requires_verified_email"`
+	GrantedAt             time.Time `db:"granted_at" json:"granted_at"`
+}
+
+// Store persists the rules of the current tenant
+type Store interface {
+	// List returns the tenant's rules, by domain
+	List(ctx context.Context) ([]Rule, error)
+	// Find returns the rule for domain, nil without one
+	Find(ctx context.Context, domain string) (*Rule, error)
+	// Put creates or replaces the rule for rule.Domain
+	Put(ctx context.Context, rule *Rule) error
+	// Delete removes the rule for domain
+	Delete(ctx context.Context, domain string) error
+}
+
+// GrantStore persists the roles granted to users of the current tenant
+type GrantStore interface {
+	// Grant gives g.Role to g.UserID, keeping an existing grant
+	Grant(ctx context.Context, g *Grant) error
+	// Roles returns the roles granted to userID, leaving out those that
+	// require a verified email unless emailVerified
+	Roles(ctx context.Context, userID uuid.UUID, emailVerified bool) ([]string, error)
+}
+
+var (
+	_ Store      = (*Repository)(nil)
+	_ Store      = (*MemoryStore)(nil)
+	_ GrantStore = (*Repository)(nil)
+	_ GrantStore = (*MemoryStore)(nil)
+)
+
+// Repository reads and writes rules and grants in the current tenant
+type Repository struct {
+	q   database.Querier
+	now func() time.Time
+}
+
+// NewRepository creates a rule and grant repository on q
+func NewRepository(q database.Querier) *Repository {
+	return &Repository{q: q, now: clock.Now}
+}
+
+// WithClock replaces the time source for timestamps, for tests
+func (repo *Repository) WithClock(now func() time.Time) *Repository {
+	repo.now = now
+	return repo
+}
+
+// List implements Store
+func (repo *Repository) List(ctx context.Context) ([]Rule, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT tenant_id, domain, role, updated_at FROM signup_domain_roles WHERE tenant_id = $1 ORDER BY domain`
+	rules, err := database.QueryAll[Rule](ctx, repo.q, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domain role rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Find implements Store
+func (repo *Repository) Find(ctx context.Context, domain string) (*Rule, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT tenant_id, domain, role, updated_at FROM signup_domain_roles WHERE tenant_id = $1 AND domain = $2`
+	rule, err := database.QueryOne[Rule](ctx, repo.q, query, tenantID, domain)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find domain role rule: %w", err)
+	}
+	return rule, nil
+}
+
+// Put implements Store
+func (repo *Repository) Put(ctx context.Context, rule *Rule) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+	rule.TenantID = tenantID
+	rule.UpdatedAt = repo.now()
+
+	query := `
+		INSERT INTO signup_domain_roles (tenant_id, domain, role, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant_id, domain) DO UPDATE SET role = EXCLUDED.role, updated_at = EXCLUDED.updated_at
+	`
+	if _, err := repo.q.Exec(ctx, query, rule.TenantID, rule.Domain, rule.Role, rule.UpdatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to save domain role rule: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store
+func (repo *Repository) Delete(ctx context.Context, domain string) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := repo.q.Exec(ctx, `DELETE FROM signup_domain_roles WHERE tenant_id = $1 AND domain = $2`, tenantID, domain)
+	if err != nil {
+		return fmt.Errorf("failed to delete domain role rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Grant implements GrantStore
+func (repo *Repository) Grant(ctx context.Context, g *Grant) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+	g.TenantID = tenantID
+	g.GrantedAt = repo.now()
+
+	query := `
+		INSERT INTO user_roles (tenant_id, user_id, role, requires_verified_email, granted_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, role) DO NOTHING
+	`
+	if _, err := repo.q.Exec(ctx, query, g.TenantID, g.UserID, g.Role, g.RequiresVerifiedEmail, g.GrantedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to grant role: %w", err)
+	}
+	return nil
+}
+
+// Roles implements GrantStore
+func (repo *Repository) Roles(ctx context.Context, userID uuid.UUID, emailVerified bool) ([]string, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT tenant_id, user_id, role, requires_verified_email, granted_at FROM user_roles
+		WHERE tenant_id = $1 AND user_id = $2 AND (NOT requires_verified_email OR $3::boolean)
+		ORDER BY role
+	`
+	grants, err := database.QueryAll[Grant](ctx, repo.q, query, tenantID, userID, emailVerified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load granted roles: %w", err)
+	}
+	roles := make([]string, 0, len(grants))
+	for _, g := range grants {
+		roles = append(roles, g.Role)
+	}
+	return roles, nil
+}
+
+// Assigner picks the role of a new user by email domain and grants it
+type Assigner struct {
+	rules           Rules
+	store           Store
+	grants          GrantStore
+	requireVerified bool
+}
+
+// NewAssigner creates an assigner with the static rules, overridden per
+// domain by the rules in store when it's not nil. While requireVerified,
+// a granted role only applies once the user's email is verified.
+func NewAssigner(rules Rules, store Store, grants GrantStore, requireVerified bool) *Assigner {
+	return &Assigner{rules: rules, store: store, grants: grants, requireVerified: requireVerified}
+}
+
+// Resolve returns the role for email, empty when no rule matches
+func (a *Assigner) Resolve(ctx context.Context, email string) (string, error) {
+	if a.store != nil {
+		rule, err := a.store.Find(ctx, Domain(email))
+		if err != nil {
+			return "", err
+		}
+		if rule != nil {
+			return rule.Role, nil
+		}
+	}
+	role, _ := a.rules.Match(email)
+	return role, nil
+}
+
+// Assign grants userID the role for email, if a rule matches. It returns
+// the role when it applies right away, that is when verification isn't
+// required or emailVerified, and empty otherwise.
+func (a *Assigner) Assign(ctx context.Context, userID uuid.UUID, email string, emailVerified bool) (string, error) {
+	role, err := a.Resolve(ctx, email)
+	if err != nil || role == "" {
+		return "", err
+	}
+	g := &Grant{UserID: userID, Role: role, RequiresVerifiedEmail: a.requireVerified}
+	if err := a.grants.Grant(ctx, g); err != nil {
+		return "", err
+	}
+	if a.requireVerified && !emailVerified {
+		return "", nil
+	}
+	return role, nil
+}
+
+// Static returns the rules from the configuration
+func (a *Assigner) Static() Rules {
+	return a.rules
+}
+
+// TokenRoles returns the roles to embed in a token for a user with role,
+// adding those granted in grants when it's not nil
+func TokenRoles(ctx context.Context, grants GrantStore, role string, userID uuid.UUID, emailVerified bool) ([]string, error) {
+	roles := []string{role}
+	if grants == nil {
+		return roles, nil
+	}
+	granted, err := grants.Roles(ctx, userID, emailVerified)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range granted {
+		if r != role {
+			roles = append(roles, r)
+		}
+	}
+	return roles, nil
+}
@@ -0,0 +1,153 @@
+package autorole
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/google/uuid"
+)
+
+// ruleKey identifies a rule across tenants
+type ruleKey struct {
+	tenantID uuid.UUID
+	domain   string
+}
+
+// grantKey identifies a grant; user IDs are unique across tenants
+type grantKey struct {
+	userID uuid.UUID
+	role   string
+}
+
+// MemoryStore keeps rules and grants in memory for development mode and
+// tests
+type MemoryStore struct {
+	mu     sync.Mutex
+	rules  map[ruleKey]Rule
+	grants map[grantKey]Grant
+	now    func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory rule and grant store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{rules: map[ruleKey]Rule{}, grants: map[grantKey]Grant{}, now: clock.Now}
+}
+
+// WithClock replaces the time source for timestamps, for tests
+func (m *MemoryStore) WithClock(now func() time.Time) *MemoryStore {
+	m.now = now
+	return m
+}
+
+// List implements Store
+func (m *MemoryStore) List(ctx context.Context) ([]Rule, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []Rule
+	for key, rule := range m.rules {
+		if key.tenantID == tenantID {
+			out = append(out, rule)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Domain < out[j].Domain })
+	return out, nil
+}
+
+// Find implements Store
+func (m *MemoryStore) Find(ctx context.Context, domain string) (*Rule, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, ok := m.rules[ruleKey{tenantID, domain}]
+	if !ok {
+		return nil, nil
+	}
+	return &rule, nil
+}
+
+// Put implements Store
+func (m *MemoryStore) Put(ctx context.Context, rule *Rule) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+	rule.TenantID = tenantID
+	rule.UpdatedAt = m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules[ruleKey{tenantID, rule.Domain}] = *rule
+	return nil
+}
+
+// Delete implements Store
+func (m *MemoryStore) Delete(ctx context.Context, domain string) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := ruleKey{tenantID, domain}
+	if _, ok := m.rules[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.rules, key)
+	return nil
+}
+
+// Grant implements GrantStore
+func (m *MemoryStore) Grant(ctx context.Context, g *Grant) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+	g.TenantID = tenantID
+	g.GrantedAt = m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := grantKey{g.UserID, g.Role}
+	if _, ok := m.grants[key]; !ok {
+		m.grants[key] = *g
+	}
+	return nil
+}
+
+// Roles implements GrantStore
+func (m *MemoryStore) Roles(ctx context.Context, userID uuid.UUID, emailVerified bool) ([]string, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	roles := []string{}
+	for _, g := range m.grants {
+		if g.TenantID == tenantID && g.UserID == userID && (!g.RequiresVerifiedEmail || emailVerified) {
+			roles = append(roles, g.Role)
+		}
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
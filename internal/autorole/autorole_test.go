@@ -0,0 +1,103 @@
+package autorole
+
+import (
+	"context"
+	"testing"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRules_Match(t *testing.T) {
+	rules := Rules{"acme.com": "acme-member", "partner.io": "partner"}
+
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"jane@acme.com", "acme-member"},
+		{"Jane@ACME.com", "acme-member"},
+		{"bob@partner.io", "partner"},
+		{"jane@eu.acme.com", ""},
+		{"jane@acme.com.evil.test", ""},
+		{"jane@example.org", ""},
+		{"no-at-sign", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		role, ok := rules.Match(tt.email)
+		assert.Equal(t, tt.want, role, tt.email)
+		assert.Equal(t, tt.want != "", ok, tt.email)
+	}
+}
+
+func TestAssigner_StoredRulesOverrideStatic(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	store := NewMemoryStore()
+	a := NewAssigner(Rules{"acme.com": "acme-member", "beta.dev": "tester"}, store, store, false)
+
+	require.NoError(t, store.Put(ctx, &Rule{Domain: "acme.com", Role: "acme-staff"}))
+	require.NoError(t, store.Put(ctx, &Rule{Domain: "new.org", Role: "newcomer"}))
+
+	for email, want := range map[string]string{
+		"jane@acme.com": "acme-staff",
+		"qa@beta.dev":   "tester",
+		"ann@new.org":   "newcomer",
+		"joe@other.net": "",
+	} {
+		role, err := a.Resolve(ctx, email)
+		require.NoError(t, err)
+		assert.Equal(t, want, role, email)
+	}
+
+	// Rules are scoped to their tenant
+	other := tenant.WithID(context.Background(), uuid.New())
+	role, err := a.Resolve(other, "jane@acme.com")
+	require.NoError(t, err)
+	assert.Equal(t, "acme-member", role)
+
+	require.NoError(t, store.Delete(ctx, "acme.com"))
+	role, err = a.Resolve(ctx, "jane@acme.com")
+	require.NoError(t, err)
+	assert.Equal(t, "acme-member", role, "the static rule is back once the override is deleted")
+	assert.ErrorIs(t, store.Delete(ctx, "acme.com"), ErrNotFound)
+}
+
+func TestAssigner_VerificationGated(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	store := NewMemoryStore()
+	userID := uuid.New()
+
+	gated := NewAssigner(Rules{"acme.com": "acme-member"}, nil, store, true)
+	role, err := gated.Assign(ctx, userID, "jane@acme.com", false)
+	require.NoError(t, err)
+	assert.Empty(t, role, "the role waits for the email to be verified")
+
+	roles, err := TokenRoles(ctx, store, "user", userID, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user"}, roles)
+	roles, err = TokenRoles(ctx, store, "user", userID, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user", "acme-member"}, roles, "and applies once it is")
+
+	open := NewAssigner(Rules{"acme.com": "acme-member"}, nil, store, false)
+	other := uuid.New()
+	role, err = open.Assign(ctx, other, "bob@acme.com", false)
+	require.NoError(t, err)
+	assert.Equal(t, "acme-member", role)
+	roles, err = TokenRoles(ctx, store, "user", other, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user", "acme-member"}, roles)
+
+	role, err = open.Assign(ctx, uuid.New(), "bob@example.org", true)
+	require.NoError(t, err)
+	assert.Empty(t, role, "no rule, no grant")
+}
+
+func TestTokenRoles_WithoutGrants(t *testing.T) {
+	roles, err := TokenRoles(context.Background(), nil, "admin", uuid.New(), true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin"}, roles)
+}
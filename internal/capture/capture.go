@@ -0,0 +1,147 @@
+// Package capture records the traffic of selected users so operators can
+// debug a problem one account is hitting. Capture is switched on per user
+// by an admin for a limited window; the records are redacted with the
+// logger's rules and expire on their own.
+package capture
+
+import (
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxBody is how much of each request and response body is kept
+	MaxBody = 64 << 10
+	// MaxRecords is how many records are kept per user; older ones are dropped
+	MaxRecords = 500
+	// MaxWindow caps how long capture can be enabled for in one go
+	MaxWindow = time.Hour
+	// Retention is how long records stay retrievable after the window ends
+	Retention = time.Hour
+)
+
+const (
+	windowPrefix  = "capture:user:"
+	recordsPrefix = "capture:records:"
+)
+
+// Record is one captured request/response exchange
+type Record struct {
+	Time            time.Time         `json:"time"`
+	Method          string            `json:"method"`
+	Path            string            `json:"path"`
+	Status          int               `json:"status"`
+	DurationMS      int64             `json:"duration_ms"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+	ResponseBody    string            `json:"response_body,omitempty"`
+	// Truncated is set when either body was cut at MaxBody
+	Truncated bool `json:"truncated,omitempty"`
+	// Error is the error the handler returned, when it left the response
+	// to the error handler
+	Error string `json:"error,omitempty"`
+}
+
+// Capture is the state reported for a user
+type Capture struct {
+	UserID    uuid.UUID  `json:"user_id"`
+	Active    bool       `json:"active"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Records   []Record   `json:"records"`
+}
+
+// records is the per-user buffer stored in the cache
+type records struct {
+	mu    sync.Mutex
+	items []Record
+}
+
+// Capturer keeps the capture registry and the captured records in a cache,
+// so a shared backend makes capture work across replicas
+type Capturer struct {
+	cache cache.Cache
+	now   func() time.Time
+}
+
+// NewCapturer creates a capturer backed by c
+func NewCapturer(c cache.Cache) *Capturer {
+	return &Capturer{cache: c, now: clock.Now}
+}
+
+// WithClock replaces the time source used for record timestamps and
+// expiry reporting, for tests
+func (c *Capturer) WithClock(now func() time.Time) *Capturer {
+	c.now = now
+	return c
+}
+
+// Enable turns capture on for userID for ttl, capped at MaxWindow.
+// Records captured in an earlier window are kept.
+func (c *Capturer) Enable(userID uuid.UUID, ttl time.Duration) time.Time {
+	if ttl > MaxWindow {
+		ttl = MaxWindow
+	}
+	expiresAt := c.now().Add(ttl)
+	c.cache.Set(windowPrefix+userID.String(), expiresAt, ttl)
+
+	buf, ok := c.buffer(userID)
+	if !ok {
+		buf = &records{}
+	}
+	c.cache.Set(recordsPrefix+userID.String(), buf, ttl+Retention)
+	return expiresAt
+}
+
+// Active reports whether capture is on for userID
+func (c *Capturer) Active(userID uuid.UUID) bool {
+	_, ok := c.cache.Get(windowPrefix + userID.String())
+	return ok
+}
+
+// Get returns the capture state and records for userID, oldest first
+func (c *Capturer) Get(userID uuid.UUID) Capture {
+	out := Capture{UserID: userID, Records: []Record{}}
+	if v, ok := c.cache.Get(windowPrefix + userID.String()); ok {
+		if expiresAt, ok := v.(time.Time); ok {
+			out.Active = true
+			out.ExpiresAt = &expiresAt
+		}
+	}
+	if buf, ok := c.buffer(userID); ok {
+		buf.mu.Lock()
+		out.Records = append(out.Records, buf.items...)
+		buf.mu.Unlock()
+	}
+	return out
+}
+
+// add appends rec to the user's records while capture is active
+func (c *Capturer) add(userID uuid.UUID, rec Record) {
+	if !c.Active(userID) {
+		return
+	}
+	buf, ok := c.buffer(userID)
+	if !ok {
+		return
+	}
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	if len(buf.items) >= MaxRecords {
+		buf.items = append(buf.items[:0], buf.items[len(buf.items)-MaxRecords+1:]...)
+	}
+	buf.items = append(buf.items, rec)
+}
+
+func (c *Capturer) buffer(userID uuid.UUID) (*records, bool) {
+	v, ok := c.cache.Get(recordsPrefix + userID.String())
+	if !ok {
+		return nil, false
+	}
+	buf, ok := v.(*records)
+	return buf, ok
+}
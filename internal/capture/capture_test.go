@@ -0,0 +1,204 @@
+package capture
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestCapturer() (*Capturer, *testClock) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	return NewCapturer(cache.NewMemory().WithClock(clock.Now)).WithClock(clock.Now), clock
+}
+
+// newTestApp authenticates every request as the user in the X-Test-User
+// header and echoes the request body back
+func newTestApp(c *Capturer, handler fiber.Handler) *fiber.App {
+	app := fiber.New()
+	app.Use(func(ctx fiber.Ctx) error {
+		if id, err := uuid.Parse(ctx.Get("X-Test-User")); err == nil {
+			ctx.Locals(middleware.ContextKeyUserID, id)
+		}
+		return ctx.Next()
+	})
+	app.Use(c.Middleware())
+	if handler == nil {
+		handler = func(ctx fiber.Ctx) error {
+			ctx.Set(fiber.HeaderContentType, ctx.Get(fiber.HeaderContentType))
+			ctx.Set(fiber.HeaderSetCookie, "session=abc")
+			return ctx.Status(fiber.StatusCreated).Send(ctx.Body())
+		}
+	}
+	app.Post("/echo", handler)
+	return app
+}
+
+func send(t *testing.T, app *fiber.App, userID uuid.UUID, contentType, body string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(body))
+	req.Header.Set("X-Test-User", userID.String())
+	req.Header.Set(fiber.HeaderContentType, contentType)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer secret-access-token")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestCapture_OnlyEnabledUsers(t *testing.T) {
+	c, _ := newTestCapturer()
+	app := newTestApp(c, nil)
+	enabled, other := uuid.New(), uuid.New()
+
+	expiresAt := c.Enable(enabled, 10*time.Minute)
+	send(t, app, enabled, fiber.MIMEApplicationJSON, `{"name":"alice"}`)
+	send(t, app, other, fiber.MIMEApplicationJSON, `{"name":"bob"}`)
+
+	got := c.Get(enabled)
+	assert.True(t, got.Active)
+	require.NotNil(t, got.ExpiresAt)
+	assert.Equal(t, expiresAt, *got.ExpiresAt)
+	require.Len(t, got.Records, 1)
+	rec := got.Records[0]
+	assert.Equal(t, http.MethodPost, rec.Method)
+	assert.Equal(t, "/echo", rec.Path)
+	assert.Equal(t, http.StatusCreated, rec.Status)
+	assert.JSONEq(t, `{"name":"alice"}`, rec.RequestBody)
+	assert.JSONEq(t, `{"name":"alice"}`, rec.ResponseBody)
+
+	assert.False(t, c.Get(other).Active)
+	assert.Empty(t, c.Get(other).Records)
+}
+
+func TestCapture_WindowIsCapped(t *testing.T) {
+	c, clock := newTestCapturer()
+	assert.Equal(t, clock.Now().Add(MaxWindow), c.Enable(uuid.New(), 24*time.Hour))
+}
+
+func TestCapture_Redaction(t *testing.T) {
+	c, _ := newTestCapturer()
+	app := newTestApp(c, nil)
+	userID := uuid.New()
+	c.Enable(userID, time.Minute)
+
+	send(t, app, userID, fiber.MIMEApplicationJSON, `{"email":"a@example.com","password":"hunter2","nested":{"refresh_token":"rt"},"items":[{"client_secret":"s"}]}`)
+	send(t, app, userID, fiber.MIMEApplicationForm, "username=alice&new_password=hunter2")
+	send(t, app, userID, fiber.MIMEApplicationJSON, `{"password":"hunter2"`)
+
+	recs := c.Get(userID).Records
+	require.Len(t, recs, 3)
+
+	for _, rec := range recs {
+		assert.Equal(t, logger.RedactedValue, rec.RequestHeaders[fiber.HeaderAuthorization])
+		assert.Equal(t, logger.RedactedValue, rec.ResponseHeaders[fiber.HeaderSetCookie])
+		assert.NotContains(t, rec.RequestBody, "hunter2")
+		assert.NotContains(t, rec.ResponseBody, "hunter2")
+	}
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal([]byte(recs[0].RequestBody), &body))
+	assert.Equal(t, "a@example.com", body["email"])
+	assert.Equal(t, logger.RedactedValue, body["password"])
+	assert.Equal(t, logger.RedactedValue, body["nested"].(map[string]any)["refresh_token"])
+	assert.Equal(t, logger.RedactedValue, body["items"].([]any)[0].(map[string]any)["client_secret"])
+
+	assert.Contains(t, recs[1].RequestBody, "username=alice")
+	assert.Equal(t, omittedBody, recs[2].RequestBody, "malformed JSON is not recorded")
+}
+
+func TestCapture_TruncatesLargeBodies(t *testing.T) {
+	c, _ := newTestCapturer()
+	app := newTestApp(c, nil)
+	userID := uuid.New()
+	c.Enable(userID, time.Minute)
+
+	send(t, app, userID, fiber.MIMETextPlain, strings.Repeat("a", MaxBody+100))
+	send(t, app, userID, fiber.MIMETextPlain, "small")
+
+	recs := c.Get(userID).Records
+	require.Len(t, recs, 2)
+	assert.True(t, recs[0].Truncated)
+	assert.Len(t, recs[0].RequestBody, MaxBody)
+	assert.Len(t, recs[0].ResponseBody, MaxBody)
+	assert.False(t, recs[1].Truncated)
+	assert.Equal(t, "small", recs[1].RequestBody)
+}
+
+func TestCapture_RecordsHandlerErrors(t *testing.T) {
+	c, _ := newTestCapturer()
+	app := newTestApp(c, func(ctx fiber.Ctx) error {
+		return errors.New("boom")
+	})
+	userID := uuid.New()
+	c.Enable(userID, time.Minute)
+
+	send(t, app, userID, fiber.MIMEApplicationJSON, `{}`)
+
+	recs := c.Get(userID).Records
+	require.Len(t, recs, 1)
+	assert.Equal(t, "boom", recs[0].Error)
+}
+
+func TestCapture_Expiry(t *testing.T) {
+	c, clock := newTestCapturer()
+	app := newTestApp(c, nil)
+	userID := uuid.New()
+	c.Enable(userID, 10*time.Minute)
+
+	send(t, app, userID, fiber.MIMETextPlain, "during")
+	clock.Advance(11 * time.Minute)
+	send(t, app, userID, fiber.MIMETextPlain, "after")
+
+	got := c.Get(userID)
+	assert.False(t, got.Active)
+	assert.Nil(t, got.ExpiresAt)
+	require.Len(t, got.Records, 1, "nothing is captured once the window ends")
+	assert.Equal(t, "during", got.Records[0].RequestBody)
+
+	clock.Advance(Retention)
+	assert.Empty(t, c.Get(userID).Records, "records expire after the retention period")
+}
+
+func TestCapture_KeepsMostRecentRecords(t *testing.T) {
+	c, _ := newTestCapturer()
+	userID := uuid.New()
+	c.Enable(userID, time.Minute)
+
+	for i := 0; i < MaxRecords+5; i++ {
+		c.add(userID, Record{Status: i})
+	}
+
+	recs := c.Get(userID).Records
+	require.Len(t, recs, MaxRecords)
+	assert.Equal(t, 5, recs[0].Status)
+	assert.Equal(t, MaxRecords+4, recs[len(recs)-1].Status)
+}
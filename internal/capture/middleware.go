@@ -0,0 +1,57 @@
+package capture
+
+import (
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+)
+
+// streamedBody stands in for bodies that are streamed and never buffered
+const streamedBody = "[streamed]"
+
+// Middleware records the exchange when capture is on for the authenticated
+// user. It must run after AuthMiddleware. Errors returned further down are
+// rendered by middleware.ErrorHandler outside this middleware, so for those
+// the record carries the error instead of the final response.
+func (c *Capturer) Middleware() fiber.Handler {
+	return func(ctx fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(ctx)
+		if err != nil || !c.Active(userID) {
+			return ctx.Next()
+		}
+
+		start := c.now()
+		rec := Record{
+			Time:           start,
+			Method:         ctx.Method(),
+			Path:           ctx.Path(),
+			RequestHeaders: redactHeaders(ctx.Request().Header.VisitAll),
+		}
+		if ctx.Request().IsBodyStream() {
+			rec.RequestBody = streamedBody
+		} else {
+			var truncated bool
+			rec.RequestBody, truncated = redactBody(ctx.Body(), ctx.Get(fiber.HeaderContentType))
+			rec.Truncated = rec.Truncated || truncated
+		}
+
+		err = ctx.Next()
+		if err != nil {
+			rec.Error = err.Error()
+		}
+
+		resp := ctx.Response()
+		rec.Status = resp.StatusCode()
+		rec.DurationMS = c.now().Sub(start).Milliseconds()
+		rec.ResponseHeaders = redactHeaders(resp.Header.VisitAll)
+		if resp.IsBodyStream() {
+			rec.ResponseBody = streamedBody
+		} else {
+			var truncated bool
+			rec.ResponseBody, truncated = redactBody(resp.Body(), string(resp.Header.ContentType()))
+			rec.Truncated = rec.Truncated || truncated
+		}
+
+		c.add(userID, rec)
+		return err
+	}
+}
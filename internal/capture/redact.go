@@ -0,0 +1,83 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+// omittedBody stands in for bodies that are not recorded
+const omittedBody = "[omitted]"
+
+// redactHeaders returns the headers with sensitive values masked
+func redactHeaders(visit func(func(key, value []byte))) map[string]string {
+	out := make(map[string]string)
+	visit(func(key, value []byte) {
+		k := string(key)
+		if logger.IsSensitiveKey(k) {
+			out[k] = logger.RedactedValue
+			return
+		}
+		out[k] = string(value)
+	})
+	return out
+}
+
+// redactBody masks sensitive fields of a JSON or form body and cuts it at
+// MaxBody. Bodies of other types are kept as they are.
+func redactBody(body []byte, contentType string) (string, bool) {
+	if len(body) == 0 {
+		return "", false
+	}
+
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(ct, "json"):
+		// Malformed JSON can't be redacted field by field, so keep nothing
+		// rather than risk leaking a secret
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			return omittedBody, false
+		}
+		redacted, err := json.Marshal(redactJSON(v))
+		if err != nil {
+			return omittedBody, false
+		}
+		body = redacted
+	case strings.Contains(ct, "application/x-www-form-urlencoded"):
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			for k := range form {
+				if logger.IsSensitiveKey(k) {
+					form[k] = []string{logger.RedactedValue}
+				}
+			}
+			body = []byte(form.Encode())
+		}
+	}
+
+	if len(body) > MaxBody {
+		return string(bytes.ToValidUTF8(body[:MaxBody], nil)), true
+	}
+	return string(body), false
+}
+
+func redactJSON(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if logger.IsSensitiveKey(k) {
+				t[k] = logger.RedactedValue
+				continue
+			}
+			t[k] = redactJSON(val)
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = redactJSON(val)
+		}
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ClientProfile configures tokens for one client type, e.g.
+//
+//	{"web": {"audience": "web", "access_ttl": "15m", "refresh_ttl": "168h"}}
+type ClientProfile struct {
+	Audience   string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// UnmarshalJSON parses TTLs as Go duration strings
+func (p *ClientProfile) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Audience   string `json:"audience"`
+		AccessTTL  string `json:"access_ttl"`
+		RefreshTTL string `json:"refresh_ttl"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	p.Audience = raw.Audience
+	for _, f := range []struct {
+		name string
+		val  string
+		dst  *time.Duration
+	}{
+		{"access_ttl", raw.AccessTTL, &p.AccessTTL},
+		{"refresh_ttl", raw.RefreshTTL, &p.RefreshTTL},
+	} {
+		if f.val == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.val)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", f.name, err)
+		}
+		*f.dst = d
+	}
+	return nil
+}
+
+// ClientProfiles maps client IDs to their token profile. It is read from
+// JWT_CLIENT_PROFILES as a JSON object.
+type ClientProfiles map[string]ClientProfile
+
+// EnvDecode implements envconfig.Decoder
+func (p *ClientProfiles) EnvDecode(val string) error {
+	if val == "" {
+		return nil
+	}
+	profiles := ClientProfiles{}
+	if err := json.Unmarshal([]byte(val), &profiles); err != nil {
+		return fmt.Errorf("invalid JWT_CLIENT_PROFILES: %w", err)
+	}
+	*p = profiles
+	return nil
+}
+
+// validate checks every profile has an audience and non-negative TTLs
+func (p ClientProfiles) validate() error {
+	for id, profile := range p {
+		if id == "" {
+			return fmt.Errorf("JWT_CLIENT_PROFILES contains an empty client id")
+		}
+		if profile.Audience == "" {
+			return fmt.Errorf("JWT_CLIENT_PROFILES[%s].audience is required", id)
+		}
+		if profile.AccessTTL < 0 || profile.RefreshTTL < 0 {
+			return fmt.Errorf("JWT_CLIENT_PROFILES[%s] TTLs must be >= 0", id)
+		}
+	}
+	return nil
+}
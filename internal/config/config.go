@@ -3,18 +3,23 @@ package config
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"dvith.com/go-service-api/pkg/database"
 	envconfig "github.com/sethvargo/go-envconfig"
 )
 
 // Config holds application configuration loaded from environment variables.
 type Config struct {
-	// URL is the base URL for the service (optional, used for generating links).
+	// URL is the public base URL of the service, which links in emails are
+	// built under (see AbsoluteURL). Required in production.
 	URL string `env:"URL"`
 
 	// Port the HTTP server will listen on.
@@ -26,8 +31,18 @@ type Config struct {
 	// LogLevel textual log level (debug, info, warn, error)
 	LogLevel string `env:"LOG_LEVEL,default=info"`
 
+	// LogFormat is text or json
+	LogFormat string `env:"LOG_FORMAT,default=text"`
+
+	// LogFile, when set, receives a copy of the logs in addition to stdout
+	LogFile string `env:"LOG_FILE"`
+
+	// LogMaxFieldLength is the longest string written in a log field, in
+	// bytes; longer values are truncated
+	LogMaxFieldLength int `env:"LOG_MAX_FIELD_LENGTH,default=4096"`
+
 	// Database connection string (optional)
-	DatabaseURL string `env:"DATABASE_URL"`
+	DatabaseURL string `env:"DATABASE_URL" secret:"url"`
 
 	// ReadTimeout for HTTP server
 	ReadTimeout time.Duration `env:"READ_TIMEOUT,default=5s"`
@@ -36,16 +51,354 @@ type Config struct {
 	WriteTimeout time.Duration `env:"WRITE_TIMEOUT,default=10s"`
 
 	// JWT Secret Key for signing tokens
-	JWTSecretKey string `env:"JWT_SECRET_KEY,default=your-secret-key-change-in-production"`
-
-	// JWT Token Expiration Time
-	JWTExpirationTime time.Duration `env:"JWT_EXPIRATION_TIME,default=1h"`
+	JWTSecretKey string `env:"JWT_SECRET_KEY,default=your-secret-key-change-in-production" secret:"true"`
 
-	// JWT Refresh Token Duration
-	JWTRefreshDuration time.Duration `env:"JWT_REFRESH_DURATION,default=168h"`
+	// TokenTTLs are the lifetimes of each kind of token issued
+	TokenTTLs TokenTTLs
 
 	// JWT Issuer
 	JWTIssuer string `env:"JWT_ISSUER,default=go-service-api"`
+
+	// JWTClientProfiles holds per-client token audiences and lifetimes as JSON
+	JWTClientProfiles ClientProfiles `env:"JWT_CLIENT_PROFILES"`
+
+	// JWTAdditionalIssuers are other services whose access tokens are
+	// accepted on safe methods, as JSON
+	JWTAdditionalIssuers Issuers `env:"JWT_ADDITIONAL_ISSUERS"`
+
+	// AdminTokenMaxAge is how old, by issue time, an access token may be to
+	// call the admin API, however long it lives; older ones are rejected as
+	// stale until refreshed. 0 accepts any age.
+	AdminTokenMaxAge time.Duration `env:"ADMIN_TOKEN_MAX_AGE,default=10m"`
+
+	// AdminRequireFreshToken limits the admin API to access tokens issued
+	// on password signin, not by a refresh
+	AdminRequireFreshToken bool `env:"ADMIN_REQUIRE_FRESH_TOKEN"`
+
+	// OutboxWebhookURL receives outbox events as JSON POSTs (optional)
+	OutboxWebhookURL string `env:"OUTBOX_WEBHOOK_URL" secret:"true"`
+
+	// OutboxPollInterval is the delay between outbox polls when idle
+	OutboxPollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL,default=1s"`
+
+	// OutboxMaxAttempts is the number of delivery attempts before an event is marked dead
+	OutboxMaxAttempts int `env:"OUTBOX_MAX_ATTEMPTS,default=10"`
+
+	// UserPurgeAfter is how long soft-deleted users are kept before being anonymized
+	UserPurgeAfter time.Duration `env:"USER_PURGE_AFTER,default=720h"`
+
+	// UserPurgeInterval is how often the purge job runs
+	UserPurgeInterval time.Duration `env:"USER_PURGE_INTERVAL,default=1h"`
+
+	// UserPurgeMaxPerRun caps the number of users purged by a single run
+	UserPurgeMaxPerRun int `env:"USER_PURGE_MAX_PER_RUN,default=100"`
+
+	// RequestTimeout bounds how long a request context stays alive before it is cancelled
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT,default=30s"`
+
+	// RefreshRotationGrace is how long the previous refresh token in a family
+	// is still accepted after rotation (concurrent refreshes from one client)
+	RefreshRotationGrace time.Duration `env:"REFRESH_ROTATION_GRACE,default=10s"`
+
+	// TokenHistoryRetention is how long refresh tokens are kept after they
+	// expire, rotated and revoked ones included, before the retention job
+	// deletes them
+	TokenHistoryRetention time.Duration `env:"TOKEN_HISTORY_RETENTION,default=720h"`
+
+	// Features lists the feature flags enabled at startup
+	Features Features `env:"FEATURES,default=admin_api,examples"`
+
+	// SignupRateLimit is how many signups one IP may attempt per SIGNUP_RATE_WINDOW
+	SignupRateLimit int `env:"SIGNUP_RATE_LIMIT,default=5"`
+
+	// SignupRateWindow is the window SIGNUP_RATE_LIMIT is counted over
+	SignupRateWindow time.Duration `env:"SIGNUP_RATE_WINDOW,default=1h"`
+
+	// SignupAllowedDomains, when set, is the only email domains signups may use
+	SignupAllowedDomains Domains `env:"SIGNUP_ALLOWED_DOMAINS"`
+
+	// SignupBlockedDomains lists email domains signups may not use
+	SignupBlockedDomains Domains `env:"SIGNUP_BLOCKED_DOMAINS"`
+
+	// SignupBlockDisposable rejects signups from known disposable email providers
+	SignupBlockDisposable bool `env:"SIGNUP_BLOCK_DISPOSABLE,default=true"`
+
+	// SignupDisposableDomainsFile lists more disposable providers, one domain
+	// per line, such as the full upstream blocklist the embedded one is
+	// trimmed from. Ignored when SIGNUP_BLOCK_DISPOSABLE is off.
+	SignupDisposableDomainsFile string `env:"SIGNUP_DISPOSABLE_DOMAINS_FILE"`
+
+	// SignupBlockProfanity rejects usernames and full names containing a word
+	// from the embedded profanity list
+	SignupBlockProfanity bool `env:"SIGNUP_BLOCK_PROFANITY,default=true"`
+
+	// SignupReservedNamesFile lists more reserved usernames, one per line, on
+	// top of the built-in ones
+	SignupReservedNamesFile string `env:"SIGNUP_RESERVED_NAMES_FILE"`
+
+	// SignupProfanityFile lists more profane words, one per line, checked
+	// even when SIGNUP_BLOCK_PROFANITY is off
+	SignupProfanityFile string `env:"SIGNUP_PROFANITY_FILE"`
+
+	// SignupNameMatch is how reserved and profane words are found in names:
+	// word matches whole words only, substring anywhere in the name
+	SignupNameMatch string `env:"SIGNUP_NAME_MATCH,default=word"`
+
+	// SignupRequiresInvite makes signup invite only: every request needs an
+	// invite_code from an invitation created by an admin
+	SignupRequiresInvite bool `env:"SIGNUP_REQUIRES_INVITE"`
+
+	// SignupDomainRoles assigns a role to signups by email domain as JSON,
+	// e.g. {"acme.com": "acme-member"}. Rules admins save at runtime
+	// override it for the same domain.
+	SignupDomainRoles DomainRoles `env:"SIGNUP_DOMAIN_ROLES"`
+
+	// SignupDomainRolesRequireVerified holds back a role assigned by domain
+	// until the user's email is verified
+	SignupDomainRolesRequireVerified bool `env:"SIGNUP_DOMAIN_ROLES_REQUIRE_VERIFIED,default=true"`
+
+	// SignupCaptchaProvider enables CAPTCHA checks on signup: turnstile or recaptcha
+	SignupCaptchaProvider string `env:"SIGNUP_CAPTCHA_PROVIDER"`
+
+	// SignupCaptchaSecret is the server-side secret for the CAPTCHA provider
+	SignupCaptchaSecret string `env:"SIGNUP_CAPTCHA_SECRET" secret:"true"`
+
+	// GoogleClientID enables Sign in with Google when set
+	GoogleClientID string `env:"GOOGLE_CLIENT_ID"`
+
+	// GoogleClientSecret is the OAuth client secret issued by Google
+	GoogleClientSecret string `env:"GOOGLE_CLIENT_SECRET" secret:"true"`
+
+	// GoogleRedirectURL is the OAuth callback URL registered with Google
+	GoogleRedirectURL string `env:"GOOGLE_REDIRECT_URL"`
+
+	// HealthCheckTimeout bounds each dependency check made by the readiness probe
+	HealthCheckTimeout time.Duration `env:"HEALTH_CHECK_TIMEOUT,default=500ms"`
+
+	// HealthReadyBudget is the most time the readiness probe takes to respond
+	HealthReadyBudget time.Duration `env:"HEALTH_READY_BUDGET,default=1s"`
+
+	// HealthErrorRateThreshold is the share of 5xx responses over the last
+	// five minutes at which the readiness probe reports degraded; 0 turns
+	// the check off
+	HealthErrorRateThreshold float64 `env:"HEALTH_ERROR_RATE_THRESHOLD,default=0.05"`
+
+	// HealthErrorRateRecovery is the share the 5xx rate must fall below for
+	// a degraded service to report ok again
+	HealthErrorRateRecovery float64 `env:"HEALTH_ERROR_RATE_RECOVERY,default=0.025"`
+
+	// HealthErrorRateMinRequests is how many requests the five minutes need
+	// before their error rate can degrade the service
+	HealthErrorRateMinRequests int `env:"HEALTH_ERROR_RATE_MIN_REQUESTS,default=100"`
+
+	// HealthDegradedUnavailable makes a degraded readiness probe answer 503
+	// instead of 200
+	HealthDegradedUnavailable bool `env:"HEALTH_DEGRADED_UNAVAILABLE"`
+
+	// UserImportBatchSize is how many imported users are inserted per transaction
+	UserImportBatchSize int `env:"USER_IMPORT_BATCH_SIZE,default=500"`
+
+	// BodyLimit caps request bodies in bytes; the user import endpoint is exempt
+	BodyLimit int `env:"BODY_LIMIT,default=4194304"`
+
+	// ShutdownDrainDelay is how long shutdown stays in lame-duck mode,
+	// failing readiness while still serving, so load balancers stop routing
+	ShutdownDrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY,default=0s"`
+	// ShutdownHookTimeout is how long each shutdown hook, such as stopping
+	// the HTTP server or draining event queues, may run
+	ShutdownHookTimeout time.Duration `env:"SHUTDOWN_HOOK_TIMEOUT,default=10s"`
+
+	// DebugErrors adds the error chain and panic stack traces to error
+	// responses. The development profile turns it on by default. It cannot
+	// be enabled in production.
+	DebugErrors bool `env:"DEBUG_ERRORS"`
+
+	// ListenAddr is the host:port to bind, e.g. "[::1]:8080" or
+	// "10.0.0.5:8080". When empty the server binds ":PORT", which is
+	// ":8080" by default and listens on every IPv4 and IPv6 interface.
+	ListenAddr string `env:"LISTEN_ADDR"`
+
+	// EnableProxyProtocol reads the PROXY v1/v2 header an L4 balancer
+	// prepends to each connection, so client IPs are the real ones. Only
+	// enable it when every connection comes through such a balancer.
+	EnableProxyProtocol bool `env:"ENABLE_PROXY_PROTOCOL"`
+
+	// RateLimitEnabled turns the per-client request quota on
+	RateLimitEnabled bool `env:"RATE_LIMIT_ENABLED,default=true"`
+
+	// RateLimitWindow is the fixed window API rate limits are counted over
+	RateLimitWindow time.Duration `env:"RATE_LIMIT_WINDOW,default=1m"`
+
+	// RateLimitAnonymous is how many requests one IP may make per window
+	// without an access token
+	RateLimitAnonymous int `env:"RATE_LIMIT_ANONYMOUS,default=60"`
+
+	// RateLimitAuthenticated is how many requests one user may make per window
+	RateLimitAuthenticated int `env:"RATE_LIMIT_AUTHENTICATED,default=600"`
+
+	// RateLimitIETFHeaders sends the IETF draft RateLimit-* headers instead
+	// of X-RateLimit-*
+	RateLimitIETFHeaders bool `env:"RATE_LIMIT_IETF_HEADERS"`
+
+	// TokenValidationCacheSize is how many validated access tokens are
+	// remembered, for up to a minute each, so repeated requests skip the
+	// signature check; 0 disables the cache
+	TokenValidationCacheSize int `env:"TOKEN_VALIDATION_CACHE_SIZE,default=0"`
+
+	// SessionClaimRequired rejects access tokens without a session (sid)
+	// claim. Leave it off until tokens issued before the claim existed
+	// have expired, or those users are signed out at once.
+	SessionClaimRequired bool `env:"SESSION_CLAIM_REQUIRED"`
+
+	// StrictAccept answers requests whose Accept header names no supported
+	// response encoding with 406 instead of falling back to JSON
+	StrictAccept bool `env:"STRICT_ACCEPT"`
+
+	// ValidateQueriesOnStart prepares every named query against the database
+	// in the startup preflight, so a query naming a missing column stops the
+	// service from starting
+	ValidateQueriesOnStart bool `env:"VALIDATE_QUERIES_ON_START"`
+
+	// FaultInjection lists faults to inject into a share of requests for
+	// resilience testing, e.g. "latency:200ms:0.1,error:503:0.05". It is
+	// refused in production.
+	FaultInjection string `env:"FAULT_INJECTION"`
+
+	// PasswordRehashOnSignin upgrades outdated password hashes when users sign in
+	PasswordRehashOnSignin bool `env:"PASSWORD_REHASH_ON_SIGNIN,default=true"`
+
+	// PasswordBreachCheck checks signin passwords against Pwned Passwords
+	// and tells clients when theirs appears in a breach
+	PasswordBreachCheck bool `env:"PASSWORD_BREACH_CHECK"`
+
+	// PasswordBreachTimeout bounds the breach check of one signin
+	PasswordBreachTimeout time.Duration `env:"PASSWORD_BREACH_TIMEOUT,default=500ms"`
+
+	// PasswordHashConcurrency caps the signup, signin and password reset
+	// requests in flight at once, as each Argon2 hash takes 64MB; 0 is
+	// middleware.DefaultConcurrency
+	PasswordHashConcurrency int `env:"PASSWORD_HASH_CONCURRENCY,default=0"`
+
+	// PasswordHashQueueTimeout is how long a request past
+	// PasswordHashConcurrency waits for a slot before it is shed with a 503
+	PasswordHashQueueTimeout time.Duration `env:"PASSWORD_HASH_QUEUE_TIMEOUT,default=2s"`
+
+	// CalibrateHashParams measures the host at startup and picks the
+	// Argon2 parameters a hash takes about 250ms with, instead of the
+	// defaults
+	CalibrateHashParams bool `env:"CALIBRATE_HASH_PARAMS"`
+
+	// PasswordHashMaxMemoryMB is the most memory, in MB, one calibrated
+	// hash may use
+	PasswordHashMaxMemoryMB int `env:"PASSWORD_HASH_MAX_MEMORY_MB,default=64"`
+
+	// MaxSessionsPerUser caps each user's active sessions; 0 means unlimited
+	MaxSessionsPerUser int `env:"MAX_SESSIONS_PER_USER,default=10"`
+
+	// SessionEvictionPolicy is what a signin over MaxSessionsPerUser does:
+	// "oldest" revokes the least recently used sessions, "deny" rejects the
+	// signin with 409 Conflict
+	SessionEvictionPolicy string `env:"SESSION_EVICTION_POLICY,default=oldest"`
+
+	// RefreshRateLimit is how many refresh attempts one IP may make per
+	// REFRESH_RATE_WINDOW; 0 disables the limit
+	RefreshRateLimit int `env:"REFRESH_RATE_LIMIT,default=30"`
+
+	// RefreshMaxInvalid is how many rejected refresh tokens one IP may submit
+	// per REFRESH_RATE_WINDOW before it is refused outright; 0 disables the
+	// lockout
+	RefreshMaxInvalid int `env:"REFRESH_MAX_INVALID,default=10"`
+
+	// RefreshRateWindow is the window REFRESH_RATE_LIMIT and
+	// REFRESH_MAX_INVALID are counted over
+	RefreshRateWindow time.Duration `env:"REFRESH_RATE_WINDOW,default=1m"`
+
+	// SlowRequestThreshold logs a warning for requests that take longer,
+	// with their route, user and query count; 0 turns the log off
+	SlowRequestThreshold time.Duration `env:"SLOW_REQUEST_THRESHOLD,default=1s"`
+
+	// BaggageKeys are the W3C baggage members accepted from the gateway and
+	// forwarded on outbound calls; without any, baggage is ignored
+	BaggageKeys Keys `env:"BAGGAGE_KEYS"`
+
+	// BaggageLogKeys are the accepted baggage members added to the slow
+	// request and error logs
+	BaggageLogKeys Keys `env:"BAGGAGE_LOG_KEYS"`
+
+	// BaggageMaxBytes is the longest baggage header accepted; longer ones
+	// are ignored
+	BaggageMaxBytes int `env:"BAGGAGE_MAX_BYTES,default=8192"`
+
+	// EncryptionKeys are the column encryption keys as comma-separated
+	// id:base64key pairs; the first encrypts new values. Empty with no
+	// master key leaves encrypted columns in plaintext.
+	EncryptionKeys string `env:"ENCRYPTION_KEYS" secret:"true"`
+
+	// EncryptionMasterKey, base64, derives the keys named by
+	// EncryptionKeyIDs instead of listing them
+	EncryptionMasterKey string `env:"ENCRYPTION_MASTER_KEY" secret:"true"`
+
+	// EncryptionKeyIDs names the keys derived from the master key, newest
+	// first
+	EncryptionKeyIDs string `env:"ENCRYPTION_KEY_IDS,default=v1"`
+
+	// ReencryptInterval is how often rows still in plaintext or under an
+	// old key are re-encrypted
+	ReencryptInterval time.Duration `env:"REENCRYPT_INTERVAL,default=1h"`
+
+	// ReencryptBatchSize caps the rows re-encrypted per run
+	ReencryptBatchSize int `env:"REENCRYPT_BATCH_SIZE,default=500"`
+
+	// ValidateEmailMX rejects signups whose email domain DNS says takes no mail
+	ValidateEmailMX bool `env:"VALIDATE_EMAIL_MX,default=false"`
+
+	// JSONNaming is the key convention of JSON responses: snake or camel
+	JSONNaming string `env:"JSON_NAMING,default=snake"`
+
+	// SigninDedupeWindow is how long a signin response is replayed to
+	// requests repeating its X-Client-Request-ID; 0 disables it
+	SigninDedupeWindow time.Duration `env:"SIGNIN_DEDUPE_WINDOW,default=2s"`
+
+	// V1SunsetDate, a date such as 2027-06-30 or an RFC 3339 time, is when
+	// /api/v1 stops being served. Once it is set v1 responses carry
+	// Deprecation and Sunset headers.
+	V1SunsetDate string `env:"V1_SUNSET_DATE"`
+
+	// AuthCookieEnabled is set for the cookie auth mode, where browsers send
+	// the refresh token as a cookie. State-changing requests without a
+	// bearer token then need a CSRF token.
+	AuthCookieEnabled bool `env:"AUTH_COOKIE_ENABLED"`
+
+	// MagicLinkRateLimit is how many magic links may be requested per email
+	// address, and per IP, every MagicLinkRateWindow
+	MagicLinkRateLimit int `env:"MAGIC_LINK_RATE_LIMIT,default=5"`
+
+	// MagicLinkRateWindow is the window of MagicLinkRateLimit
+	MagicLinkRateWindow time.Duration `env:"MAGIC_LINK_RATE_WINDOW,default=15m"`
+
+	// UserCacheTTL is how long GetCurrentUser caches a user record across
+	// requests; zero disables the cache
+	UserCacheTTL time.Duration `env:"USER_CACHE_TTL,default=30s"`
+
+	// UsernameReservation is how long a username a user renamed away from
+	// stays reserved for them to reclaim, and unavailable to others
+	UsernameReservation time.Duration `env:"USERNAME_RESERVATION,default=2160h"`
+
+	// UsernameMaxRenames caps a user's username changes per 30 days; 0 means
+	// no cap
+	UsernameMaxRenames int `env:"USERNAME_MAX_RENAMES,default=3"`
+
+	// StorageDir is the directory generated files, such as data exports, are
+	// kept in. It must be shared by every instance; empty keeps them in
+	// memory.
+	StorageDir string `env:"STORAGE_DIR"`
+
+	// ServeAccountPages serves the built-in pages under /account that emailed
+	// reset and confirmation links open, for deployments without a frontend
+	ServeAccountPages bool `env:"SERVE_ACCOUNT_PAGES"`
+
+	// sources records where each value came from, keyed by env var name
+	sources map[string]Source
 }
 
 // LoadFromEnv loads configuration from environment variables using go-envconfig.
@@ -54,6 +407,7 @@ func LoadFromEnv() (Config, error) {
 	if err := envconfig.Process(context.Background(), &c); err != nil {
 		return c, fmt.Errorf("failed to load environment: %w", err)
 	}
+	c.sources = trackSources(SourceEnv, envIsSet)
 	return c, nil
 }
 
@@ -102,16 +456,66 @@ func LoadFromFile(path string) (Config, error) {
 
 	// Start with defaults then override from vals map.
 	c := Config{
-		Port:                8080,
-		Env:                 "development",
-		LogLevel:            "info",
-		DatabaseURL:         "",
-		ReadTimeout:         5 * time.Second,
-		WriteTimeout:        10 * time.Second,
-		JWTSecretKey:        "your-secret-key-change-in-production",
-		JWTExpirationTime:   1 * time.Hour,
-		JWTRefreshDuration:  7 * 24 * time.Hour,
-		JWTIssuer:           "go-service-api",
+		Port:                             8080,
+		Env:                              "development",
+		LogLevel:                         "info",
+		DatabaseURL:                      "",
+		ReadTimeout:                      5 * time.Second,
+		WriteTimeout:                     10 * time.Second,
+		JWTSecretKey:                     "your-secret-key-change-in-production",
+		TokenTTLs:                        DefaultTokenTTLs(),
+		JWTIssuer:                        "go-service-api",
+		OutboxPollInterval:               1 * time.Second,
+		OutboxMaxAttempts:                10,
+		UserPurgeAfter:                   30 * 24 * time.Hour,
+		UserPurgeInterval:                1 * time.Hour,
+		UserPurgeMaxPerRun:               100,
+		RequestTimeout:                   30 * time.Second,
+		RefreshRotationGrace:             10 * time.Second,
+		Features:                         Features{"admin_api", "examples"},
+		SignupRateLimit:                  5,
+		SignupRateWindow:                 time.Hour,
+		SignupBlockDisposable:            true,
+		SignupDomainRolesRequireVerified: true,
+		HealthCheckTimeout:               500 * time.Millisecond,
+		HealthReadyBudget:                time.Second,
+		UserImportBatchSize:              500,
+		BodyLimit:                        4 * 1024 * 1024,
+		RateLimitWindow:                  time.Minute,
+		RateLimitAnonymous:               60,
+		RateLimitAuthenticated:           600,
+		SignupBlockProfanity:             true,
+		SignupNameMatch:                  "word",
+		LogFormat:                        "text",
+		RateLimitEnabled:                 true,
+		PasswordRehashOnSignin:           true,
+		PasswordBreachTimeout:            500 * time.Millisecond,
+		MaxSessionsPerUser:               10,
+		SessionEvictionPolicy:            "oldest",
+		RefreshRateLimit:                 30,
+		RefreshMaxInvalid:                10,
+		RefreshRateWindow:                time.Minute,
+		SlowRequestThreshold:             time.Second,
+		BaggageMaxBytes:                  8192,
+		EncryptionKeyIDs:                 "v1",
+		ReencryptInterval:                1 * time.Hour,
+		ReencryptBatchSize:               500,
+		JSONNaming:                       "snake",
+		SigninDedupeWindow:               2 * time.Second,
+		MagicLinkRateLimit:               5,
+		MagicLinkRateWindow:              15 * time.Minute,
+		ShutdownHookTimeout:              10 * time.Second,
+		UserCacheTTL:                     30 * time.Second,
+		PasswordHashQueueTimeout:         2 * time.Second,
+		AdminTokenMaxAge:                 10 * time.Minute,
+		TokenHistoryRetention:            30 * 24 * time.Hour,
+		HealthErrorRateThreshold:         0.05,
+		HealthErrorRateRecovery:          0.025,
+		HealthErrorRateMinRequests:       100,
+		LogMaxFieldLength:                4096,
+		UsernameReservation:              90 * 24 * time.Hour,
+		UsernameMaxRenames:               3,
+		PasswordHashMaxMemoryMB:          64,
 	}
 
 	if v, ok := vals["PORT"]; ok && v != "" {
@@ -155,69 +559,968 @@ func LoadFromFile(path string) (Config, error) {
 		if err != nil {
 			return c, fmt.Errorf("invalid JWT_EXPIRATION_TIME in file: %w", err)
 		}
-		c.JWTExpirationTime = d
+		c.TokenTTLs.Access = d
 	}
 	if v, ok := vals["JWT_REFRESH_DURATION"]; ok && v != "" {
 		d, err := time.ParseDuration(v)
 		if err != nil {
 			return c, fmt.Errorf("invalid JWT_REFRESH_DURATION in file: %w", err)
 		}
-		c.JWTRefreshDuration = d
+		c.TokenTTLs.Refresh = d
 	}
 	if v, ok := vals["JWT_ISSUER"]; ok && v != "" {
 		c.JWTIssuer = v
 	}
+	if v, ok := vals["JWT_CLIENT_PROFILES"]; ok && v != "" {
+		if err := c.JWTClientProfiles.EnvDecode(v); err != nil {
+			return c, err
+		}
+	}
+	if v, ok := vals["JWT_ADDITIONAL_ISSUERS"]; ok && v != "" {
+		if err := c.JWTAdditionalIssuers.EnvDecode(v); err != nil {
+			return c, err
+		}
+	}
+	if v, ok := vals["OUTBOX_WEBHOOK_URL"]; ok && v != "" {
+		c.OutboxWebhookURL = v
+	}
+	if v, ok := vals["OUTBOX_POLL_INTERVAL"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid OUTBOX_POLL_INTERVAL in file: %w", err)
+		}
+		c.OutboxPollInterval = d
+	}
+	if v, ok := vals["OUTBOX_MAX_ATTEMPTS"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid OUTBOX_MAX_ATTEMPTS in file: %w", err)
+		}
+		c.OutboxMaxAttempts = n
+	}
+	if v, ok := vals["USER_PURGE_AFTER"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid USER_PURGE_AFTER in file: %w", err)
+		}
+		c.UserPurgeAfter = d
+	}
+	if v, ok := vals["USER_PURGE_INTERVAL"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid USER_PURGE_INTERVAL in file: %w", err)
+		}
+		c.UserPurgeInterval = d
+	}
+	if v, ok := vals["USER_PURGE_MAX_PER_RUN"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid USER_PURGE_MAX_PER_RUN in file: %w", err)
+		}
+		c.UserPurgeMaxPerRun = n
+	}
+	if v, ok := vals["REQUEST_TIMEOUT"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid REQUEST_TIMEOUT in file: %w", err)
+		}
+		c.RequestTimeout = d
+	}
+	if v, ok := vals["REFRESH_ROTATION_GRACE"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid REFRESH_ROTATION_GRACE in file: %w", err)
+		}
+		c.RefreshRotationGrace = d
+	}
+	if v, ok := vals["FEATURES"]; ok && v != "" {
+		c.Features = parseFeatures(v)
+	}
+	if v, ok := vals["RESET_TOKEN_TTL"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid RESET_TOKEN_TTL in file: %w", err)
+		}
+		c.TokenTTLs.Reset = d
+	}
+	if v, ok := vals["MAGIC_LINK_TOKEN_TTL"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid MAGIC_LINK_TOKEN_TTL in file: %w", err)
+		}
+		c.TokenTTLs.MagicLink = d
+	}
+	if v, ok := vals["MFA_TOKEN_TTL"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid MFA_TOKEN_TTL in file: %w", err)
+		}
+		c.TokenTTLs.MFA = d
+	}
+	if v, ok := vals["VERIFICATION_TOKEN_TTL"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid VERIFICATION_TOKEN_TTL in file: %w", err)
+		}
+		c.TokenTTLs.Verification = d
+	}
+	if v, ok := vals["EMAIL_CHANGE_TOKEN_TTL"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid EMAIL_CHANGE_TOKEN_TTL in file: %w", err)
+		}
+		c.TokenTTLs.EmailChange = d
+	}
+	if v, ok := vals["IMPERSONATION_TOKEN_TTL"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid IMPERSONATION_TOKEN_TTL in file: %w", err)
+		}
+		c.TokenTTLs.Impersonation = d
+	}
+	if v, ok := vals["SIGNUP_RATE_LIMIT"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SIGNUP_RATE_LIMIT in file: %w", err)
+		}
+		c.SignupRateLimit = n
+	}
+	if v, ok := vals["SIGNUP_RATE_WINDOW"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SIGNUP_RATE_WINDOW in file: %w", err)
+		}
+		c.SignupRateWindow = d
+	}
+	if v, ok := vals["SIGNUP_ALLOWED_DOMAINS"]; ok && v != "" {
+		c.SignupAllowedDomains = Domains(parseList(v))
+	}
+	if v, ok := vals["SIGNUP_BLOCKED_DOMAINS"]; ok && v != "" {
+		c.SignupBlockedDomains = Domains(parseList(v))
+	}
+	if v, ok := vals["SIGNUP_BLOCK_DISPOSABLE"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SIGNUP_BLOCK_DISPOSABLE in file: %w", err)
+		}
+		c.SignupBlockDisposable = b
+	}
+	if v, ok := vals["SIGNUP_CAPTCHA_PROVIDER"]; ok && v != "" {
+		c.SignupCaptchaProvider = v
+	}
+	if v, ok := vals["SIGNUP_CAPTCHA_SECRET"]; ok && v != "" {
+		c.SignupCaptchaSecret = v
+	}
+	if v, ok := vals["GOOGLE_CLIENT_ID"]; ok && v != "" {
+		c.GoogleClientID = v
+	}
+	if v, ok := vals["GOOGLE_CLIENT_SECRET"]; ok && v != "" {
+		c.GoogleClientSecret = v
+	}
+	if v, ok := vals["GOOGLE_REDIRECT_URL"]; ok && v != "" {
+		c.GoogleRedirectURL = v
+	}
+	if v, ok := vals["HEALTH_CHECK_TIMEOUT"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid HEALTH_CHECK_TIMEOUT in file: %w", err)
+		}
+		c.HealthCheckTimeout = d
+	}
+	if v, ok := vals["HEALTH_READY_BUDGET"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid HEALTH_READY_BUDGET in file: %w", err)
+		}
+		c.HealthReadyBudget = d
+	}
+	if v, ok := vals["USER_IMPORT_BATCH_SIZE"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid USER_IMPORT_BATCH_SIZE in file: %w", err)
+		}
+		c.UserImportBatchSize = n
+	}
+	if v, ok := vals["BODY_LIMIT"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid BODY_LIMIT in file: %w", err)
+		}
+		c.BodyLimit = n
+	}
+	if v, ok := vals["SHUTDOWN_DRAIN_DELAY"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SHUTDOWN_DRAIN_DELAY in file: %w", err)
+		}
+		c.ShutdownDrainDelay = d
+	}
+	if v, ok := vals["DEBUG_ERRORS"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid DEBUG_ERRORS in file: %w", err)
+		}
+		c.DebugErrors = b
+	}
+	if v, ok := vals["LISTEN_ADDR"]; ok && v != "" {
+		c.ListenAddr = v
+	}
+	if v, ok := vals["ENABLE_PROXY_PROTOCOL"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid ENABLE_PROXY_PROTOCOL in file: %w", err)
+		}
+		c.EnableProxyProtocol = b
+	}
+	if v, ok := vals["RATE_LIMIT_WINDOW"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid RATE_LIMIT_WINDOW in file: %w", err)
+		}
+		c.RateLimitWindow = d
+	}
+	if v, ok := vals["RATE_LIMIT_ANONYMOUS"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid RATE_LIMIT_ANONYMOUS in file: %w", err)
+		}
+		c.RateLimitAnonymous = n
+	}
+	if v, ok := vals["RATE_LIMIT_AUTHENTICATED"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid RATE_LIMIT_AUTHENTICATED in file: %w", err)
+		}
+		c.RateLimitAuthenticated = n
+	}
+	if v, ok := vals["RATE_LIMIT_IETF_HEADERS"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid RATE_LIMIT_IETF_HEADERS in file: %w", err)
+		}
+		c.RateLimitIETFHeaders = b
+	}
+	if v, ok := vals["SESSION_CLAIM_REQUIRED"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SESSION_CLAIM_REQUIRED in file: %w", err)
+		}
+		c.SessionClaimRequired = b
+	}
+	if v, ok := vals["STRICT_ACCEPT"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid STRICT_ACCEPT in file: %w", err)
+		}
+		c.StrictAccept = b
+	}
+	if v, ok := vals["LOG_FILE"]; ok && v != "" {
+		c.LogFile = v
+	}
+	if v, ok := vals["VALIDATE_QUERIES_ON_START"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid VALIDATE_QUERIES_ON_START in file: %w", err)
+		}
+		c.ValidateQueriesOnStart = b
+	}
+	if v, ok := vals["SIGNUP_BLOCK_PROFANITY"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SIGNUP_BLOCK_PROFANITY in file: %w", err)
+		}
+		c.SignupBlockProfanity = b
+	}
+	if v, ok := vals["SIGNUP_RESERVED_NAMES_FILE"]; ok && v != "" {
+		c.SignupReservedNamesFile = v
+	}
+	if v, ok := vals["SIGNUP_PROFANITY_FILE"]; ok && v != "" {
+		c.SignupProfanityFile = v
+	}
+	if v, ok := vals["SIGNUP_DISPOSABLE_DOMAINS_FILE"]; ok && v != "" {
+		c.SignupDisposableDomainsFile = v
+	}
+	if v, ok := vals["SIGNUP_NAME_MATCH"]; ok && v != "" {
+		c.SignupNameMatch = v
+	}
+	if v, ok := vals["LOG_FORMAT"]; ok && v != "" {
+		c.LogFormat = v
+	}
+	if v, ok := vals["RATE_LIMIT_ENABLED"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid RATE_LIMIT_ENABLED in file: %w", err)
+		}
+		c.RateLimitEnabled = b
+	}
+	if v, ok := vals["FAULT_INJECTION"]; ok && v != "" {
+		c.FaultInjection = v
+	}
+	if v, ok := vals["PASSWORD_REHASH_ON_SIGNIN"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid PASSWORD_REHASH_ON_SIGNIN in file: %w", err)
+		}
+		c.PasswordRehashOnSignin = b
+	}
+	if v, ok := vals["PASSWORD_BREACH_CHECK"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid PASSWORD_BREACH_CHECK in file: %w", err)
+		}
+		c.PasswordBreachCheck = b
+	}
+	if v, ok := vals["PASSWORD_BREACH_TIMEOUT"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid PASSWORD_BREACH_TIMEOUT in file: %w", err)
+		}
+		c.PasswordBreachTimeout = d
+	}
+	if v, ok := vals["MAX_SESSIONS_PER_USER"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid MAX_SESSIONS_PER_USER in file: %w", err)
+		}
+		c.MaxSessionsPerUser = n
+	}
+	if v, ok := vals["SESSION_EVICTION_POLICY"]; ok && v != "" {
+		c.SessionEvictionPolicy = v
+	}
+	if v, ok := vals["REFRESH_RATE_LIMIT"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid REFRESH_RATE_LIMIT in file: %w", err)
+		}
+		c.RefreshRateLimit = n
+	}
+	if v, ok := vals["REFRESH_MAX_INVALID"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid REFRESH_MAX_INVALID in file: %w", err)
+		}
+		c.RefreshMaxInvalid = n
+	}
+	if v, ok := vals["REFRESH_RATE_WINDOW"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid REFRESH_RATE_WINDOW in file: %w", err)
+		}
+		c.RefreshRateWindow = d
+	}
+	if v, ok := vals["BAGGAGE_KEYS"]; ok && v != "" {
+		c.BaggageKeys = Keys(splitList(v))
+	}
+	if v, ok := vals["BAGGAGE_LOG_KEYS"]; ok && v != "" {
+		c.BaggageLogKeys = Keys(splitList(v))
+	}
+	if v, ok := vals["BAGGAGE_MAX_BYTES"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid BAGGAGE_MAX_BYTES in file: %w", err)
+		}
+		c.BaggageMaxBytes = n
+	}
+	if v, ok := vals["SLOW_REQUEST_THRESHOLD"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SLOW_REQUEST_THRESHOLD in file: %w", err)
+		}
+		c.SlowRequestThreshold = d
+	}
+	if v, ok := vals["ENCRYPTION_KEYS"]; ok && v != "" {
+		c.EncryptionKeys = v
+	}
+	if v, ok := vals["ENCRYPTION_MASTER_KEY"]; ok && v != "" {
+		c.EncryptionMasterKey = v
+	}
+	if v, ok := vals["ENCRYPTION_KEY_IDS"]; ok && v != "" {
+		c.EncryptionKeyIDs = v
+	}
+	if v, ok := vals["REENCRYPT_INTERVAL"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid REENCRYPT_INTERVAL in file: %w", err)
+		}
+		c.ReencryptInterval = d
+	}
+	if v, ok := vals["REENCRYPT_BATCH_SIZE"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid REENCRYPT_BATCH_SIZE in file: %w", err)
+		}
+		c.ReencryptBatchSize = n
+	}
+	if v, ok := vals["VALIDATE_EMAIL_MX"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid VALIDATE_EMAIL_MX in file: %w", err)
+		}
+		c.ValidateEmailMX = b
+	}
+	if v, ok := vals["JSON_NAMING"]; ok && v != "" {
+		c.JSONNaming = v
+	}
+	if v, ok := vals["SIGNIN_DEDUPE_WINDOW"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SIGNIN_DEDUPE_WINDOW in file: %w", err)
+		}
+		c.SigninDedupeWindow = d
+	}
+	if v, ok := vals["V1_SUNSET_DATE"]; ok && v != "" {
+		c.V1SunsetDate = v
+	}
+	if v, ok := vals["AUTH_COOKIE_ENABLED"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid AUTH_COOKIE_ENABLED in file: %w", err)
+		}
+		c.AuthCookieEnabled = b
+	}
+	if v, ok := vals["MAGIC_LINK_RATE_LIMIT"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid MAGIC_LINK_RATE_LIMIT in file: %w", err)
+		}
+		c.MagicLinkRateLimit = n
+	}
+	if v, ok := vals["MAGIC_LINK_RATE_WINDOW"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid MAGIC_LINK_RATE_WINDOW in file: %w", err)
+		}
+		c.MagicLinkRateWindow = d
+	}
+	if v, ok := vals["SHUTDOWN_HOOK_TIMEOUT"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SHUTDOWN_HOOK_TIMEOUT in file: %w", err)
+		}
+		c.ShutdownHookTimeout = d
+	}
+	if v, ok := vals["USER_CACHE_TTL"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid USER_CACHE_TTL in file: %w", err)
+		}
+		c.UserCacheTTL = d
+	}
+	if v, ok := vals["STORAGE_DIR"]; ok && v != "" {
+		c.StorageDir = v
+	}
+	if v, ok := vals["SERVE_ACCOUNT_PAGES"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SERVE_ACCOUNT_PAGES in file: %w", err)
+		}
+		c.ServeAccountPages = b
+	}
+	if v, ok := vals["SIGNUP_REQUIRES_INVITE"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SIGNUP_REQUIRES_INVITE in file: %w", err)
+		}
+		c.SignupRequiresInvite = b
+	}
+	if v, ok := vals["SIGNUP_DOMAIN_ROLES"]; ok && v != "" {
+		if err := c.SignupDomainRoles.EnvDecode(v); err != nil {
+			return c, err
+		}
+	}
+	if v, ok := vals["SIGNUP_DOMAIN_ROLES_REQUIRE_VERIFIED"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid SIGNUP_DOMAIN_ROLES_REQUIRE_VERIFIED in file: %w", err)
+		}
+		c.SignupDomainRolesRequireVerified = b
+	}
+	if v, ok := vals["TOKEN_VALIDATION_CACHE_SIZE"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid TOKEN_VALIDATION_CACHE_SIZE in file: %w", err)
+		}
+		c.TokenValidationCacheSize = n
+	}
+	if v, ok := vals["PASSWORD_HASH_CONCURRENCY"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid PASSWORD_HASH_CONCURRENCY in file: %w", err)
+		}
+		c.PasswordHashConcurrency = n
+	}
+	if v, ok := vals["PASSWORD_HASH_QUEUE_TIMEOUT"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid PASSWORD_HASH_QUEUE_TIMEOUT in file: %w", err)
+		}
+		c.PasswordHashQueueTimeout = d
+	}
+	if v, ok := vals["ADMIN_TOKEN_MAX_AGE"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid ADMIN_TOKEN_MAX_AGE in file: %w", err)
+		}
+		c.AdminTokenMaxAge = d
+	}
+	if v, ok := vals["ADMIN_REQUIRE_FRESH_TOKEN"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid ADMIN_REQUIRE_FRESH_TOKEN in file: %w", err)
+		}
+		c.AdminRequireFreshToken = b
+	}
+	if v, ok := vals["TOKEN_HISTORY_RETENTION"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid TOKEN_HISTORY_RETENTION in file: %w", err)
+		}
+		c.TokenHistoryRetention = d
+	}
+	if v, ok := vals["HEALTH_ERROR_RATE_THRESHOLD"]; ok && v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid HEALTH_ERROR_RATE_THRESHOLD in file: %w", err)
+		}
+		c.HealthErrorRateThreshold = f
+	}
+	if v, ok := vals["HEALTH_ERROR_RATE_RECOVERY"]; ok && v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid HEALTH_ERROR_RATE_RECOVERY in file: %w", err)
+		}
+		c.HealthErrorRateRecovery = f
+	}
+	if v, ok := vals["HEALTH_ERROR_RATE_MIN_REQUESTS"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid HEALTH_ERROR_RATE_MIN_REQUESTS in file: %w", err)
+		}
+		c.HealthErrorRateMinRequests = n
+	}
+	if v, ok := vals["HEALTH_DEGRADED_UNAVAILABLE"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid HEALTH_DEGRADED_UNAVAILABLE in file: %w", err)
+		}
+		c.HealthDegradedUnavailable = b
+	}
+	if v, ok := vals["LOG_MAX_FIELD_LENGTH"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid LOG_MAX_FIELD_LENGTH in file: %w", err)
+		}
+		c.LogMaxFieldLength = n
+	}
+	if v, ok := vals["USERNAME_RESERVATION"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid USERNAME_RESERVATION in file: %w", err)
+		}
+		c.UsernameReservation = d
+	}
+	if v, ok := vals["USERNAME_MAX_RENAMES"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid USERNAME_MAX_RENAMES in file: %w", err)
+		}
+		c.UsernameMaxRenames = n
+	}
+	if v, ok := vals["CALIBRATE_HASH_PARAMS"]; ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid CALIBRATE_HASH_PARAMS in file: %w", err)
+		}
+		c.CalibrateHashParams = b
+	}
+	if v, ok := vals["PASSWORD_HASH_MAX_MEMORY_MB"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid PASSWORD_HASH_MAX_MEMORY_MB in file: %w", err)
+		}
+		c.PasswordHashMaxMemoryMB = n
+	}
+
+	c.sources = trackSources(SourceFile, func(key string) bool {
+		return vals[key] != ""
+	})
 
 	return c, nil
 }
 
+// IsProduction reports whether the service runs in the production environment
+func (c Config) IsProduction() bool {
+	return strings.ToLower(c.Env) == "production"
+}
+
+// UsesMemoryStore reports whether the service should run on in-memory
+// stores because it is in development and no database is configured
+func (c Config) UsesMemoryStore() bool {
+	return strings.ToLower(c.Env) == "development" && strings.TrimSpace(c.DatabaseURL) == ""
+}
+
+// Addr returns the address the HTTP server binds
+func (c Config) Addr() string {
+	if c.ListenAddr != "" {
+		return c.ListenAddr
+	}
+	return fmt.Sprintf(":%d", c.Port)
+}
+
+// ErrorDebugEnabled reports whether error responses carry debug details:
+// by default in development, on request elsewhere, never in production
+func (c Config) ErrorDebugEnabled() bool {
+	if c.IsProduction() {
+		return false
+	}
+	return c.Effective().DebugErrors
+}
+
 // Validate checks that required configuration values are present and well-formed.
-// It returns an error describing the first validation failure encountered.
+// Every failure is reported, joined with errors.Join, so operators can fix
+// them all in one go.
 func (c Config) Validate() error {
+	var problems []error
+
 	if c.Port <= 0 || c.Port > 65535 {
-		return fmt.Errorf("PORT must be between 1 and 65535, got %d", c.Port)
+		problems = append(problems, fmt.Errorf("PORT must be between 1 and 65535, got %d", c.Port))
+	}
+
+	if c.ListenAddr != "" {
+		if err := validateListenAddr(c.ListenAddr); err != nil {
+			problems = append(problems, fmt.Errorf("LISTEN_ADDR %w", err))
+		}
 	}
 
 	env := strings.ToLower(c.Env)
 	switch env {
 	case "development", "staging", "production", "test", "local":
 	default:
-		return fmt.Errorf("ENV must be one of development|staging|production|test|local, got %q", c.Env)
+		problems = append(problems, fmt.Errorf("ENV must be one of development|staging|production|test|local, got %q", c.Env))
 	}
 
 	lvl := strings.ToLower(c.LogLevel)
 	switch lvl {
 	case "debug", "info", "warn", "error":
 	default:
-		return fmt.Errorf("LOG_LEVEL must be one of debug|info|warn|error, got %q", c.LogLevel)
+		problems = append(problems, fmt.Errorf("LOG_LEVEL must be one of debug|info|warn|error, got %q", c.LogLevel))
+	}
+
+	switch c.JSONNaming {
+	case "", "snake", "camel":
+	default:
+		problems = append(problems, fmt.Errorf("JSON_NAMING must be one of snake|camel, got %q", c.JSONNaming))
+	}
+
+	if _, err := parseSunset(c.V1SunsetDate); err != nil {
+		problems = append(problems, err)
+	}
+
+	switch c.LogFormat {
+	case "", "text", "json":
+	default:
+		problems = append(problems, fmt.Errorf("LOG_FORMAT must be text or json, got %q", c.LogFormat))
+	}
+
+	if c.LogMaxFieldLength <= 0 {
+		problems = append(problems, fmt.Errorf("LOG_MAX_FIELD_LENGTH must be > 0"))
 	}
 
 	if c.ReadTimeout <= 0 {
-		return fmt.Errorf("READ_TIMEOUT must be > 0")
+		problems = append(problems, fmt.Errorf("READ_TIMEOUT must be > 0"))
 	}
 	if c.WriteTimeout <= 0 {
-		return fmt.Errorf("WRITE_TIMEOUT must be > 0")
+		problems = append(problems, fmt.Errorf("WRITE_TIMEOUT must be > 0"))
 	}
 
 	if strings.TrimSpace(c.JWTSecretKey) == "" {
-		return fmt.Errorf("JWT_SECRET_KEY is required")
+		problems = append(problems, fmt.Errorf("JWT_SECRET_KEY is required"))
 	}
 
-	if c.JWTExpirationTime <= 0 {
-		return fmt.Errorf("JWT_EXPIRATION_TIME must be > 0")
+	problems = append(problems, c.TokenTTLs.validate()...)
+
+	if strings.TrimSpace(c.JWTIssuer) == "" {
+		problems = append(problems, fmt.Errorf("JWT_ISSUER is required"))
 	}
 
-	if c.JWTRefreshDuration <= 0 {
-		return fmt.Errorf("JWT_REFRESH_DURATION must be > 0")
+	if err := c.JWTClientProfiles.validate(); err != nil {
+		problems = append(problems, err)
 	}
 
-	if strings.TrimSpace(c.JWTIssuer) == "" {
-		return fmt.Errorf("JWT_ISSUER is required")
+	if err := c.JWTAdditionalIssuers.validate(c.JWTIssuer); err != nil {
+		problems = append(problems, err)
+	}
+
+	if c.OutboxPollInterval <= 0 {
+		problems = append(problems, fmt.Errorf("OUTBOX_POLL_INTERVAL must be > 0"))
+	}
+
+	if c.OutboxMaxAttempts <= 0 {
+		problems = append(problems, fmt.Errorf("OUTBOX_MAX_ATTEMPTS must be > 0"))
+	}
+
+	if c.UserPurgeAfter <= 0 {
+		problems = append(problems, fmt.Errorf("USER_PURGE_AFTER must be > 0"))
+	}
+
+	if c.UserPurgeInterval <= 0 {
+		problems = append(problems, fmt.Errorf("USER_PURGE_INTERVAL must be > 0"))
+	}
+
+	if c.UserPurgeMaxPerRun <= 0 {
+		problems = append(problems, fmt.Errorf("USER_PURGE_MAX_PER_RUN must be > 0"))
+	}
+
+	if c.RequestTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("REQUEST_TIMEOUT must be > 0"))
+	}
+
+	if c.RefreshRotationGrace < 0 {
+		problems = append(problems, fmt.Errorf("REFRESH_ROTATION_GRACE must be >= 0"))
+	}
+
+	if c.SignupRateLimit <= 0 {
+		problems = append(problems, fmt.Errorf("SIGNUP_RATE_LIMIT must be > 0"))
+	}
+
+	if c.SignupRateWindow <= 0 {
+		problems = append(problems, fmt.Errorf("SIGNUP_RATE_WINDOW must be > 0"))
+	}
+
+	switch c.SignupNameMatch {
+	case "", "word", "substring":
+	default:
+		problems = append(problems, fmt.Errorf("SIGNUP_NAME_MATCH must be word or substring"))
+	}
+
+	if c.SignupReservedNamesFile != "" {
+		if _, err := os.Stat(c.SignupReservedNamesFile); err != nil {
+			problems = append(problems, fmt.Errorf("SIGNUP_RESERVED_NAMES_FILE is not readable: %w", err))
+		}
+	}
+
+	if c.SignupProfanityFile != "" {
+		if _, err := os.Stat(c.SignupProfanityFile); err != nil {
+			problems = append(problems, fmt.Errorf("SIGNUP_PROFANITY_FILE is not readable: %w", err))
+		}
+	}
+
+	if c.SignupDisposableDomainsFile != "" {
+		if _, err := os.Stat(c.SignupDisposableDomainsFile); err != nil {
+			problems = append(problems, fmt.Errorf("SIGNUP_DISPOSABLE_DOMAINS_FILE is not readable: %w", err))
+		}
+	}
+
+	switch c.SignupCaptchaProvider {
+	case "":
+	case "turnstile", "recaptcha":
+		if strings.TrimSpace(c.SignupCaptchaSecret) == "" {
+			problems = append(problems, fmt.Errorf("SIGNUP_CAPTCHA_SECRET is required when SIGNUP_CAPTCHA_PROVIDER is set"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("SIGNUP_CAPTCHA_PROVIDER must be turnstile or recaptcha"))
+	}
+
+	if err := c.SignupDomainRoles.validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if c.GoogleClientID != "" && (c.GoogleClientSecret == "" || c.GoogleRedirectURL == "") {
+		problems = append(problems, fmt.Errorf("GOOGLE_CLIENT_SECRET and GOOGLE_REDIRECT_URL are required when GOOGLE_CLIENT_ID is set"))
+	}
+
+	if c.HealthCheckTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("HEALTH_CHECK_TIMEOUT must be > 0"))
+	}
+
+	if c.HealthReadyBudget <= 0 {
+		problems = append(problems, fmt.Errorf("HEALTH_READY_BUDGET must be > 0"))
+	}
+
+	if c.HealthErrorRateThreshold < 0 || c.HealthErrorRateThreshold > 1 {
+		problems = append(problems, fmt.Errorf("HEALTH_ERROR_RATE_THRESHOLD must be between 0 and 1"))
+	}
+
+	if c.HealthErrorRateRecovery < 0 || c.HealthErrorRateRecovery > c.HealthErrorRateThreshold {
+		problems = append(problems, fmt.Errorf("HEALTH_ERROR_RATE_RECOVERY must be between 0 and HEALTH_ERROR_RATE_THRESHOLD"))
+	}
+
+	if c.HealthErrorRateMinRequests < 0 {
+		problems = append(problems, fmt.Errorf("HEALTH_ERROR_RATE_MIN_REQUESTS must be >= 0"))
+	}
+
+	if c.UserImportBatchSize <= 0 {
+		problems = append(problems, fmt.Errorf("USER_IMPORT_BATCH_SIZE must be > 0"))
+	}
+
+	if c.BodyLimit <= 0 {
+		problems = append(problems, fmt.Errorf("BODY_LIMIT must be > 0"))
+	}
+
+	if c.ShutdownDrainDelay < 0 {
+		problems = append(problems, fmt.Errorf("SHUTDOWN_DRAIN_DELAY must be >= 0"))
+	}
+
+	if c.IsProduction() && c.DebugErrors {
+		problems = append(problems, fmt.Errorf("DEBUG_ERRORS cannot be enabled in production environment"))
+	}
+
+	if c.RateLimitWindow <= 0 {
+		problems = append(problems, fmt.Errorf("RATE_LIMIT_WINDOW must be > 0"))
+	}
+
+	if c.RateLimitAnonymous <= 0 {
+		problems = append(problems, fmt.Errorf("RATE_LIMIT_ANONYMOUS must be > 0"))
+	}
+
+	if c.RateLimitAuthenticated <= 0 {
+		problems = append(problems, fmt.Errorf("RATE_LIMIT_AUTHENTICATED must be > 0"))
 	}
 
-	if strings.ToLower(c.Env) == "production" && strings.TrimSpace(c.DatabaseURL) == "" {
-		return fmt.Errorf("DATABASE_URL is required in production environment")
+	if c.IsProduction() && strings.TrimSpace(c.FaultInjection) != "" {
+		problems = append(problems, fmt.Errorf("FAULT_INJECTION cannot be set in production environment"))
 	}
 
+	if c.PasswordBreachCheck && c.PasswordBreachTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("PASSWORD_BREACH_TIMEOUT must be > 0"))
+	}
+
+	if c.MaxSessionsPerUser < 0 {
+		problems = append(problems, fmt.Errorf("MAX_SESSIONS_PER_USER must be >= 0"))
+	}
+
+	switch c.SessionEvictionPolicy {
+	case "", "oldest", "deny":
+	default:
+		problems = append(problems, fmt.Errorf("SESSION_EVICTION_POLICY must be oldest or deny"))
+	}
+
+	if c.RefreshRateLimit < 0 {
+		problems = append(problems, fmt.Errorf("REFRESH_RATE_LIMIT must be >= 0"))
+	}
+
+	if c.RefreshMaxInvalid < 0 {
+		problems = append(problems, fmt.Errorf("REFRESH_MAX_INVALID must be >= 0"))
+	}
+
+	if (c.RefreshRateLimit > 0 || c.RefreshMaxInvalid > 0) && c.RefreshRateWindow <= 0 {
+		problems = append(problems, fmt.Errorf("REFRESH_RATE_WINDOW must be > 0"))
+	}
+
+	if c.SlowRequestThreshold < 0 {
+		problems = append(problems, fmt.Errorf("SLOW_REQUEST_THRESHOLD must be >= 0"))
+	}
+
+	if c.BaggageMaxBytes <= 0 || c.BaggageMaxBytes > 8192 {
+		problems = append(problems, fmt.Errorf("BAGGAGE_MAX_BYTES must be between 1 and 8192"))
+	}
+	for _, key := range c.BaggageLogKeys {
+		if !c.BaggageKeys.Contains(key) {
+			problems = append(problems, fmt.Errorf("BAGGAGE_LOG_KEYS lists %s, which BAGGAGE_KEYS doesn't accept", key))
+		}
+	}
+
+	if kr, err := c.KeyRing(); err != nil {
+		problems = append(problems, err)
+	} else if kr != nil {
+		if c.ReencryptInterval <= 0 {
+			problems = append(problems, fmt.Errorf("REENCRYPT_INTERVAL must be > 0"))
+		}
+		if c.ReencryptBatchSize <= 0 {
+			problems = append(problems, fmt.Errorf("REENCRYPT_BATCH_SIZE must be > 0"))
+		}
+	}
+
+	// Password reset, login notice and email change emails link back here
+	if c.IsProduction() && strings.TrimSpace(c.URL) == "" {
+		problems = append(problems, fmt.Errorf("URL is required in production environment"))
+	}
+	if strings.TrimSpace(c.URL) != "" {
+		if err := validateURL(strings.TrimSpace(c.URL)); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if c.SigninDedupeWindow < 0 {
+		problems = append(problems, fmt.Errorf("SIGNIN_DEDUPE_WINDOW must be >= 0"))
+	}
+
+	if c.MagicLinkRateLimit <= 0 {
+		problems = append(problems, fmt.Errorf("MAGIC_LINK_RATE_LIMIT must be > 0"))
+	}
+
+	if c.MagicLinkRateWindow <= 0 {
+		problems = append(problems, fmt.Errorf("MAGIC_LINK_RATE_WINDOW must be > 0"))
+	}
+
+	if c.ShutdownHookTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("SHUTDOWN_HOOK_TIMEOUT must be > 0"))
+	}
+
+	if c.UserCacheTTL < 0 {
+		problems = append(problems, fmt.Errorf("USER_CACHE_TTL must be >= 0"))
+	}
+
+	if c.UsernameReservation <= 0 {
+		problems = append(problems, fmt.Errorf("USERNAME_RESERVATION must be > 0"))
+	}
+
+	if c.UsernameMaxRenames < 0 {
+		problems = append(problems, fmt.Errorf("USERNAME_MAX_RENAMES must be >= 0"))
+	}
+
+	if c.TokenValidationCacheSize < 0 {
+		problems = append(problems, fmt.Errorf("TOKEN_VALIDATION_CACHE_SIZE must be >= 0"))
+	}
+
+	if c.PasswordHashConcurrency < 0 {
+		problems = append(problems, fmt.Errorf("PASSWORD_HASH_CONCURRENCY must be >= 0"))
+	}
+
+	if c.CalibrateHashParams && c.PasswordHashMaxMemoryMB < 8 {
+		problems = append(problems, fmt.Errorf("PASSWORD_HASH_MAX_MEMORY_MB must be >= 8 with CALIBRATE_HASH_PARAMS"))
+	}
+
+	if c.PasswordHashQueueTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("PASSWORD_HASH_QUEUE_TIMEOUT must be > 0"))
+	}
+
+	if c.AdminTokenMaxAge < 0 {
+		problems = append(problems, fmt.Errorf("ADMIN_TOKEN_MAX_AGE must be >= 0"))
+	}
+
+	if c.TokenHistoryRetention <= 0 {
+		problems = append(problems, fmt.Errorf("TOKEN_HISTORY_RETENTION must be > 0"))
+	}
+
+	if c.IsProduction() && strings.TrimSpace(c.DatabaseURL) == "" {
+		problems = append(problems, fmt.Errorf("DATABASE_URL is required in production environment"))
+	}
+	if strings.TrimSpace(c.DatabaseURL) != "" {
+		if err := database.CheckScheme(c.DatabaseURL); err != nil {
+			problems = append(problems, fmt.Errorf("DATABASE_URL: %w", err))
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// validateListenAddr checks addr is host:port with a numeric port and, when
+// a host is given, an IP literal or a hostname
+func validateListenAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("must be host:port, got %q", addr)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535, got %q", port)
+	}
+	if _, err := netip.ParseAddr(host); host == "" || err == nil {
+		return nil
+	}
+	if strings.ContainsAny(host, " /[]%") {
+		return fmt.Errorf("host must be an IP address or hostname, got %q", host)
+	}
 	return nil
 }
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DomainRoles maps email domains to the role signups from them are
+// assigned. It is read from SIGNUP_DOMAIN_ROLES as a JSON object, e.g.
+//
+//	{"acme.com": "acme-member"}
+type DomainRoles map[string]string
+
+// EnvDecode implements envconfig.Decoder. Domains are lower-cased, as
+// emails are matched ignoring case.
+func (r *DomainRoles) EnvDecode(val string) error {
+	if val == "" {
+		return nil
+	}
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(val), &raw); err != nil {
+		return fmt.Errorf("invalid SIGNUP_DOMAIN_ROLES: %w", err)
+	}
+	rules := DomainRoles{}
+	for domain, role := range raw {
+		rules[strings.ToLower(strings.TrimSpace(domain))] = strings.TrimSpace(role)
+	}
+	*r = rules
+	return nil
+}
+
+// validate checks every rule names a bare domain and a role
+func (r DomainRoles) validate() error {
+	for domain, role := range r {
+		if domain == "" || strings.ContainsAny(domain, "@ \t,") || !strings.Contains(domain, ".") {
+			return fmt.Errorf("SIGNUP_DOMAIN_ROLES contains an invalid domain %q", domain)
+		}
+		if role == "" || len(role) > 50 || strings.ContainsAny(role, " \t,") {
+			return fmt.Errorf("SIGNUP_DOMAIN_ROLES[%s] must be a role of at most 50 characters without spaces or commas", domain)
+		}
+	}
+	return nil
+}
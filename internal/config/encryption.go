@@ -0,0 +1,41 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"dvith.com/go-service-api/pkg/crypto"
+)
+
+// KeyRing builds the column encryption key ring from ENCRYPTION_KEYS or
+// ENCRYPTION_MASTER_KEY. It returns nil when neither is set.
+func (c Config) KeyRing() (*crypto.KeyRing, error) {
+	switch {
+	case c.EncryptionKeys != "" && c.EncryptionMasterKey != "":
+		return nil, fmt.Errorf("set either ENCRYPTION_KEYS or ENCRYPTION_MASTER_KEY, not both")
+	case c.EncryptionKeys != "":
+		kr, err := crypto.ParseKeyRing(c.EncryptionKeys)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENCRYPTION_KEYS: %w", err)
+		}
+		return kr, nil
+	case c.EncryptionMasterKey != "":
+		master, err := base64.StdEncoding.DecodeString(c.EncryptionMasterKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENCRYPTION_MASTER_KEY: %w", err)
+		}
+		var ids []string
+		for id := range strings.SplitSeq(c.EncryptionKeyIDs, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		kr, err := crypto.DeriveKeyRing(master, ids...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENCRYPTION_MASTER_KEY or ENCRYPTION_KEY_IDS: %w", err)
+		}
+		return kr, nil
+	}
+	return nil, nil
+}
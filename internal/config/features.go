@@ -0,0 +1,27 @@
+package config
+
+// Features is the list of enabled feature flags, read from FEATURES as a
+// comma-separated list, e.g. FEATURES="signup_v2,admin_api"
+type Features []string
+
+// EnvDecode implements envconfig.Decoder
+func (f *Features) EnvDecode(val string) error {
+	*f = parseFeatures(val)
+	return nil
+}
+
+// parseFeatures splits a comma list into trimmed, lower-case, unique names
+func parseFeatures(val string) Features {
+	return Features(parseList(val))
+}
+
+// FeatureEnabled reports whether the named feature is listed in FEATURES.
+// Runtime toggles are tracked by features.Flags, not by Config.
+func (c Config) FeatureEnabled(name string) bool {
+	for _, f := range c.Features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
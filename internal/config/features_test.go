@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatures_Parse(t *testing.T) {
+	var f Features
+	require.NoError(t, f.EnvDecode(" signup_v2, Admin_API ,,signup_v2"))
+	assert.Equal(t, Features{"signup_v2", "admin_api"}, f)
+
+	cfg := Config{Features: f}
+	assert.True(t, cfg.FeatureEnabled("admin_api"))
+	assert.False(t, cfg.FeatureEnabled("examples"))
+}
+
+func TestFeatures_Defaults(t *testing.T) {
+	fromEnv, err := LoadFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Features{"admin_api", "examples"}, fromEnv.Features)
+
+	fromFile, err := LoadFromFile(writeEnvFile(t, "FEATURES=signup_v2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Features{"signup_v2"}, fromFile.Features)
+	assert.Equal(t, SourceFile, fromFile.Source("FEATURES"))
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"time"
+)
+
+// Issuer is another service whose access tokens are accepted read-only,
+// verified with a PEM public key or the keys of a JWKS URL, e.g.
+//
+//	[{"issuer": "billing", "audience": "go-service-api", "jwks_url": "https://billing.internal/.well-known/jwks.json"}]
+type Issuer struct {
+	Issuer      string
+	Audience    string
+	Key         string
+	JWKSURL     string
+	JWKSRefresh time.Duration
+}
+
+// UnmarshalJSON parses jwks_refresh as a Go duration string
+func (i *Issuer) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Issuer      string `json:"issuer"`
+		Audience    string `json:"audience"`
+		Key         string `json:"key"`
+		JWKSURL     string `json:"jwks_url"`
+		JWKSRefresh string `json:"jwks_refresh"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*i = Issuer{Issuer: raw.Issuer, Audience: raw.Audience, Key: raw.Key, JWKSURL: raw.JWKSURL}
+	if raw.JWKSRefresh != "" {
+		d, err := time.ParseDuration(raw.JWKSRefresh)
+		if err != nil {
+			return fmt.Errorf("invalid jwks_refresh: %w", err)
+		}
+		i.JWKSRefresh = d
+	}
+	return nil
+}
+
+// Issuers are the additional token issuers. They are read from
+// JWT_ADDITIONAL_ISSUERS as a JSON array.
+type Issuers []Issuer
+
+// EnvDecode implements envconfig.Decoder
+func (p *Issuers) EnvDecode(val string) error {
+	if val == "" {
+		return nil
+	}
+	var issuers Issuers
+	if err := json.Unmarshal([]byte(val), &issuers); err != nil {
+		return fmt.Errorf("invalid JWT_ADDITIONAL_ISSUERS: %w", err)
+	}
+	*p = issuers
+	return nil
+}
+
+// validate checks every issuer is named once, apart from our own, and has
+// an audience and exactly one usable source of keys
+func (p Issuers) validate(own string) error {
+	seen := make(map[string]bool, len(p))
+	for n, iss := range p {
+		switch {
+		case iss.Issuer == "":
+			return fmt.Errorf("JWT_ADDITIONAL_ISSUERS[%d].issuer is required", n)
+		case iss.Issuer == own:
+			return fmt.Errorf("JWT_ADDITIONAL_ISSUERS[%d] repeats JWT_ISSUER", n)
+		case seen[iss.Issuer]:
+			return fmt.Errorf("JWT_ADDITIONAL_ISSUERS lists %s twice", iss.Issuer)
+		case iss.Audience == "":
+			return fmt.Errorf("JWT_ADDITIONAL_ISSUERS[%s].audience is required", iss.Issuer)
+		case (iss.Key == "") == (iss.JWKSURL == ""):
+			return fmt.Errorf("JWT_ADDITIONAL_ISSUERS[%s] needs exactly one of key and jwks_url", iss.Issuer)
+		case iss.JWKSRefresh < 0:
+			return fmt.Errorf("JWT_ADDITIONAL_ISSUERS[%s].jwks_refresh must be >= 0", iss.Issuer)
+		}
+		seen[iss.Issuer] = true
+		if iss.Key == "" {
+			continue
+		}
+		block, _ := pem.Decode([]byte(iss.Key))
+		if block == nil {
+			return fmt.Errorf("JWT_ADDITIONAL_ISSUERS[%s].key is not PEM", iss.Issuer)
+		}
+		if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return fmt.Errorf("JWT_ADDITIONAL_ISSUERS[%s].key: %w", iss.Issuer, err)
+		}
+	}
+	return nil
+}
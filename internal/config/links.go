@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// validateURL checks URL is an absolute http(s) URL that links can be built
+// under: no query, fragment or credentials, which joined paths would mangle
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("URL must be an absolute http(s) URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("URL must be an absolute http(s) URL, got scheme %q", u.Scheme)
+	}
+	if u.Host == "" || u.Opaque != "" {
+		return fmt.Errorf("URL must be an absolute http(s) URL with a host, got %q", raw)
+	}
+	if u.User != nil || u.RawQuery != "" || u.ForceQuery || u.Fragment != "" {
+		return fmt.Errorf("URL must not carry credentials, a query or a fragment, got %q", raw)
+	}
+	return nil
+}
+
+// AbsoluteURL returns the link to path under URL with query encoded. A
+// trailing slash on URL and a missing leading one on path are both
+// tolerated, so "https://example.com/app/" and "reset" give
+// "https://example.com/app/reset". Without URL the link is relative to the
+// host the client used.
+func (c Config) AbsoluteURL(path string, query url.Values) string {
+	base := strings.TrimRight(strings.TrimSpace(c.URL), "/")
+	link := base
+	if path = strings.TrimLeft(path, "/"); path != "" || base == "" {
+		link += "/" + (&url.URL{Path: path}).EscapedPath()
+	}
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	return link
+}
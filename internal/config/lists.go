@@ -0,0 +1,53 @@
+package config
+
+import "strings"
+
+// Domains is a list of email domains, read as a comma-separated list, e.g.
+// SIGNUP_BLOCKED_DOMAINS="example.org,spam.test"
+type Domains []string
+
+// EnvDecode implements envconfig.Decoder
+func (d *Domains) EnvDecode(val string) error {
+	*d = Domains(parseList(val))
+	return nil
+}
+
+// Keys is a list of case-sensitive names, read as a comma-separated list,
+// e.g. BAGGAGE_KEYS="tier,experiment"
+type Keys []string
+
+// EnvDecode implements envconfig.Decoder
+func (k *Keys) EnvDecode(val string) error {
+	*k = Keys(splitList(val))
+	return nil
+}
+
+// Contains reports whether key is listed
+func (k Keys) Contains(key string) bool {
+	for _, item := range k {
+		if item == key {
+			return true
+		}
+	}
+	return false
+}
+
+// parseList splits a comma list into trimmed, lower-case, unique values
+func parseList(val string) []string {
+	return splitList(strings.ToLower(val))
+}
+
+// splitList splits a comma list into trimmed, unique values
+func splitList(val string) []string {
+	items := []string{}
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		items = append(items, item)
+	}
+	return items
+}
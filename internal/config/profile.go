@@ -0,0 +1,95 @@
+package config
+
+import "strings"
+
+// Profile is a preset of defaults for a kind of environment
+type Profile string
+
+// Profiles
+const (
+	// ProfileDevelopment logs everything, puts debug details in error
+	// responses and turns rate limiting off
+	ProfileDevelopment Profile = "development"
+	// ProfileStaging logs at info and checks every query against the schema
+	// on start
+	ProfileStaging Profile = "staging"
+	// ProfileProduction logs errors only, as JSON, and checks every query
+	// against the schema on start
+	ProfileProduction Profile = "production"
+)
+
+// SourceProfile marks a value chosen by the profile preset rather than set
+// explicitly or left at its plain default
+const SourceProfile Source = "profile"
+
+// preset sets one setting when it wasn't configured explicitly
+type preset struct {
+	key   string
+	apply func(c *Config)
+}
+
+// presets lists what each profile changes from the plain defaults
+var presets = map[Profile][]preset{
+	ProfileDevelopment: {
+		{"LOG_LEVEL", func(c *Config) { c.LogLevel = "debug" }},
+		{"DEBUG_ERRORS", func(c *Config) { c.DebugErrors = true }},
+		{"RATE_LIMIT_ENABLED", func(c *Config) { c.RateLimitEnabled = false }},
+	},
+	ProfileStaging: {
+		{"LOG_LEVEL", func(c *Config) { c.LogLevel = "info" }},
+		{"VALIDATE_QUERIES_ON_START", func(c *Config) { c.ValidateQueriesOnStart = true }},
+	},
+	ProfileProduction: {
+		{"LOG_LEVEL", func(c *Config) { c.LogLevel = "error" }},
+		{"LOG_FORMAT", func(c *Config) { c.LogFormat = "json" }},
+		{"DEBUG_ERRORS", func(c *Config) { c.DebugErrors = false }},
+		{"VALIDATE_QUERIES_ON_START", func(c *Config) { c.ValidateQueriesOnStart = true }},
+	},
+}
+
+// PresetKeys are the settings a profile may choose, in log order
+var PresetKeys = []string{"LOG_LEVEL", "LOG_FORMAT", "DEBUG_ERRORS", "RATE_LIMIT_ENABLED", "VALIDATE_QUERIES_ON_START"}
+
+// Profile returns the preset ENV selects: development for development and
+// local, production for production, and staging for everything else,
+// test included
+func (c Config) Profile() Profile {
+	switch strings.ToLower(c.Env) {
+	case "development", "local":
+		return ProfileDevelopment
+	case "production":
+		return ProfileProduction
+	default:
+		return ProfileStaging
+	}
+}
+
+// Effective returns c with its profile's presets applied to every setting
+// that wasn't set explicitly in the environment or the config file. Those
+// settings report SourceProfile. Applying it twice changes nothing.
+func (c Config) Effective() Config {
+	sources := make(map[string]Source, len(c.sources))
+	for k, v := range c.sources {
+		sources[k] = v
+	}
+	for _, p := range presets[c.Profile()] {
+		if c.Source(p.key) != SourceDefault {
+			continue
+		}
+		p.apply(&c)
+		sources[p.key] = SourceProfile
+	}
+	c.sources = sources
+	return c
+}
+
+// PresetFields reports the settings profiles choose, with their values and
+// sources, for logging the resolved profile
+func (c Config) PresetFields() map[string]Field {
+	all := c.Redacted()
+	fields := make(map[string]Field, len(PresetKeys))
+	for _, key := range PresetKeys {
+		fields[key] = all[key]
+	}
+	return fields
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfile(t *testing.T) {
+	tests := map[string]Profile{
+		"development": ProfileDevelopment,
+		"Local":       ProfileDevelopment,
+		"staging":     ProfileStaging,
+		"test":        ProfileStaging,
+		"qa":          ProfileStaging,
+		"PRODUCTION":  ProfileProduction,
+	}
+	for env, want := range tests {
+		assert.Equal(t, want, Config{Env: env}.Profile(), env)
+	}
+}
+
+func TestEffective_ProfileDefaults(t *testing.T) {
+	tests := []struct {
+		env              string
+		logLevel         string
+		logFormat        string
+		debugErrors      bool
+		rateLimit        bool
+		validateQueries  bool
+		profileOverrides []string
+	}{
+		{"development", "debug", "text", true, false, false, []string{"LOG_LEVEL", "DEBUG_ERRORS", "RATE_LIMIT_ENABLED"}},
+		{"staging", "info", "text", false, true, true, []string{"LOG_LEVEL", "VALIDATE_QUERIES_ON_START"}},
+		{"production", "error", "json", false, true, true, []string{"LOG_LEVEL", "LOG_FORMAT", "DEBUG_ERRORS", "VALIDATE_QUERIES_ON_START"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			loaded, err := LoadFromFile(writeEnvFile(t, "ENV="+tt.env+"\n"))
+			require.NoError(t, err)
+
+			cfg := loaded.Effective()
+			assert.Equal(t, tt.logLevel, cfg.LogLevel)
+			assert.Equal(t, tt.logFormat, cfg.LogFormat)
+			assert.Equal(t, tt.debugErrors, cfg.DebugErrors)
+			assert.Equal(t, tt.rateLimit, cfg.RateLimitEnabled)
+			assert.Equal(t, tt.validateQueries, cfg.ValidateQueriesOnStart)
+			for _, key := range tt.profileOverrides {
+				assert.Equal(t, SourceProfile, cfg.Source(key), key)
+			}
+			assert.Equal(t, SourceFile, cfg.Source("ENV"))
+			assert.Equal(t, SourceDefault, loaded.Source("LOG_LEVEL"), "the loaded config is left as is")
+
+			assert.Equal(t, cfg, cfg.Effective(), "applying twice changes nothing")
+		})
+	}
+}
+
+func TestEffective_ExplicitSettingsWin(t *testing.T) {
+	cfg, err := LoadFromFile(writeEnvFile(t, "ENV=development\nLOG_LEVEL=warn\nRATE_LIMIT_ENABLED=true\nDEBUG_ERRORS=false\n"))
+	require.NoError(t, err)
+
+	cfg = cfg.Effective()
+	assert.Equal(t, "warn", cfg.LogLevel)
+	assert.True(t, cfg.RateLimitEnabled)
+	assert.False(t, cfg.DebugErrors)
+	assert.False(t, cfg.ErrorDebugEnabled(), "explicitly turned off in development")
+	assert.Equal(t, SourceFile, cfg.Source("LOG_LEVEL"))
+
+	t.Setenv("ENV", "production")
+	t.Setenv("LOG_FORMAT", "text")
+	t.Setenv("VALIDATE_QUERIES_ON_START", "false")
+	cfg, err = LoadFromEnv()
+	require.NoError(t, err)
+
+	cfg = cfg.Effective()
+	assert.Equal(t, "text", cfg.LogFormat)
+	assert.False(t, cfg.ValidateQueriesOnStart)
+	assert.Equal(t, "error", cfg.LogLevel, "unset settings still follow the profile")
+}
+
+func TestPresetFields(t *testing.T) {
+	cfg := Config{Env: "production", LogLevel: "info"}.Effective()
+
+	fields := cfg.PresetFields()
+	assert.Len(t, fields, len(PresetKeys))
+	assert.Equal(t, Field{Value: "error", Source: SourceProfile}, fields["LOG_LEVEL"])
+	assert.Equal(t, Field{Value: false, Source: SourceDefault}, fields["RATE_LIMIT_ENABLED"])
+}
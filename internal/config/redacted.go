@@ -0,0 +1,126 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Source records where a configuration value came from
+type Source string
+
+// Configuration sources
+const (
+	SourceDefault Source = "default"
+	SourceEnv     Source = "env"
+	SourceFile    Source = "file"
+)
+
+// mask replaces secret values in Redacted output
+const mask = "********"
+
+// Field is one configuration value as reported by Redacted
+type Field struct {
+	Value  any    `json:"value"`
+	Source Source `json:"source"`
+}
+
+// envKey returns the environment variable name from an env struct tag
+func envKey(f reflect.StructField) string {
+	key, _, _ := strings.Cut(f.Tag.Get("env"), ",")
+	return key
+}
+
+// envFields lists the fields of t that have an env tag, descending into
+// untagged struct fields such as TokenTTLs. Each field's Index is relative
+// to t.
+func envFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if envKey(f) != "" {
+			fields = append(fields, f)
+			continue
+		}
+		if f.IsExported() && f.Type.Kind() == reflect.Struct {
+			for _, nested := range envFields(f.Type) {
+				nested.Index = append([]int{i}, nested.Index...)
+				fields = append(fields, nested)
+			}
+		}
+	}
+	return fields
+}
+
+// trackSources records, for every configured field, whether isSet reports
+// its key as set by the loader's source or it kept its default
+func trackSources(source Source, isSet func(key string) bool) map[string]Source {
+	sources := make(map[string]Source)
+	for _, f := range envFields(reflect.TypeOf(Config{})) {
+		key := envKey(f)
+		if isSet(key) {
+			sources[key] = source
+		} else {
+			sources[key] = SourceDefault
+		}
+	}
+	return sources
+}
+
+// envIsSet reports whether key is set to a non-empty environment value
+func envIsSet(key string) bool {
+	v, ok := os.LookupEnv(key)
+	return ok && v != ""
+}
+
+// Source reports where the value for the environment key came from. Configs
+// not built by a loader report every field as a default.
+func (c Config) Source(key string) Source {
+	if s, ok := c.sources[key]; ok {
+		return s
+	}
+	return SourceDefault
+}
+
+// Redacted returns the effective configuration keyed by environment variable
+// name, with the source of each value. Fields tagged secret are always
+// masked; URLs tagged secret:"url" keep everything but their password.
+func (c Config) Redacted() map[string]Field {
+	out := make(map[string]Field)
+	v := reflect.ValueOf(c)
+	for _, f := range envFields(v.Type()) {
+		key := envKey(f)
+
+		var value any = v.FieldByIndex(f.Index).Interface()
+		switch f.Tag.Get("secret") {
+		case "true":
+			if value != "" {
+				value = mask
+			}
+		case "url":
+			value = redactURL(value.(string))
+		default:
+			if d, ok := value.(time.Duration); ok {
+				value = d.String()
+			}
+		}
+
+		out[key] = Field{Value: value, Source: c.Source(key)}
+	}
+	return out
+}
+
+// redactURL masks the password in a connection URL. Values that don't
+// parse are masked entirely, since they may be key=value DSNs.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return mask
+	}
+	return u.Redacted()
+}
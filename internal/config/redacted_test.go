@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeEnvFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadFromFile_TracksSources(t *testing.T) {
+	path := writeEnvFile(t, "PORT=9090\nREQUEST_TIMEOUT=5s\nLOG_LEVEL=\n")
+
+	cfg, err := LoadFromFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, SourceFile, cfg.Source("PORT"))
+	assert.Equal(t, SourceFile, cfg.Source("REQUEST_TIMEOUT"))
+	assert.Equal(t, SourceDefault, cfg.Source("LOG_LEVEL"), "empty values keep the default")
+	assert.Equal(t, SourceDefault, cfg.Source("READ_TIMEOUT"))
+
+	dump := cfg.Redacted()
+	assert.Equal(t, Field{Value: 9090, Source: SourceFile}, dump["PORT"])
+	assert.Equal(t, Field{Value: "5s", Source: SourceFile}, dump["REQUEST_TIMEOUT"])
+	assert.Equal(t, Field{Value: "5s", Source: SourceDefault}, dump["READ_TIMEOUT"])
+}
+
+func TestLoadFromEnv_TracksSources(t *testing.T) {
+	t.Setenv("PORT", "9191")
+
+	cfg, err := LoadFromEnv()
+	require.NoError(t, err)
+
+	assert.Equal(t, SourceEnv, cfg.Source("PORT"))
+	assert.Equal(t, SourceDefault, cfg.Source("JWT_ISSUER"))
+}
+
+func TestRedacted_MasksSecrets(t *testing.T) {
+	path := writeEnvFile(t, "JWT_SECRET_KEY=super-secret\n"+
+		"DATABASE_URL=postgres://app:hunter2@db:5432/app?sslmode=disable\n"+
+		"OUTBOX_WEBHOOK_URL=https://hooks.example.com/T000/secret-token\n")
+
+	cfg, err := LoadFromFile(path)
+	require.NoError(t, err)
+
+	dump := cfg.Redacted()
+	assert.Equal(t, mask, dump["JWT_SECRET_KEY"].Value)
+	assert.Equal(t, mask, dump["OUTBOX_WEBHOOK_URL"].Value)
+	assert.Equal(t, "postgres://app:xxxxx@db:5432/app?sslmode=disable", dump["DATABASE_URL"].Value)
+	for key, f := range dump {
+		value := fmt.Sprint(f.Value)
+		assert.NotContains(t, value, "super-secret", key)
+		assert.NotContains(t, value, "hunter2", key)
+		assert.NotContains(t, value, "secret-token", key)
+	}
+
+	// The default secret is masked too
+	assert.Equal(t, mask, Config{JWTSecretKey: "default"}.Redacted()["JWT_SECRET_KEY"].Value)
+}
+
+func TestRedacted_UnparseableDatabaseURL(t *testing.T) {
+	dump := Config{DatabaseURL: "host=db user=app password=hunter2"}.Redacted()
+	assert.Equal(t, mask, dump["DATABASE_URL"].Value)
+}
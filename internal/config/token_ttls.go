@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxImpersonationTTL caps impersonation tokens; an admin acting as a user
+// has to come back for a new token at least this often
+const MaxImpersonationTTL = 15 * time.Minute
+
+// TokenTTLs are the lifetimes of each kind of token the service issues
+type TokenTTLs struct {
+	// Access is how long access tokens stay valid
+	Access time.Duration `env:"JWT_EXPIRATION_TIME,default=1h"`
+
+	// Refresh is how long refresh tokens stay valid
+	Refresh time.Duration `env:"JWT_REFRESH_DURATION,default=168h"`
+
+	// MFA is how long a signin waits for its second factor
+	MFA time.Duration `env:"MFA_TOKEN_TTL,default=5m"`
+
+	// Reset is how long a password reset token stays valid
+	Reset time.Duration `env:"RESET_TOKEN_TTL,default=30m"`
+
+	// MagicLink is how long an emailed sign-in link stays valid
+	MagicLink time.Duration `env:"MAGIC_LINK_TOKEN_TTL,default=15m"`
+
+	// Verification is how long an email verification link stays valid
+	Verification time.Duration `env:"VERIFICATION_TOKEN_TTL,default=24h"`
+
+	// EmailChange is how long a requested email change waits for the new
+	// address to confirm it
+	EmailChange time.Duration `env:"EMAIL_CHANGE_TOKEN_TTL,default=24h"`
+
+	// Impersonation is how long an admin's impersonation token stays
+	// valid, at most MaxImpersonationTTL
+	Impersonation time.Duration `env:"IMPERSONATION_TOKEN_TTL,default=15m"`
+}
+
+// DefaultTokenTTLs returns the lifetimes used when nothing is configured
+func DefaultTokenTTLs() TokenTTLs {
+	return TokenTTLs{
+		Access:        1 * time.Hour,
+		Refresh:       7 * 24 * time.Hour,
+		MFA:           5 * time.Minute,
+		Reset:         30 * time.Minute,
+		MagicLink:     15 * time.Minute,
+		Verification:  24 * time.Hour,
+		EmailChange:   24 * time.Hour,
+		Impersonation: 15 * time.Minute,
+	}
+}
+
+// validate reports every lifetime that is unset or out of bounds
+func (t TokenTTLs) validate() []error {
+	var problems []error
+	for _, f := range []struct {
+		key string
+		ttl time.Duration
+	}{
+		{"JWT_EXPIRATION_TIME", t.Access},
+		{"JWT_REFRESH_DURATION", t.Refresh},
+		{"MFA_TOKEN_TTL", t.MFA},
+		{"RESET_TOKEN_TTL", t.Reset},
+		{"MAGIC_LINK_TOKEN_TTL", t.MagicLink},
+		{"VERIFICATION_TOKEN_TTL", t.Verification},
+		{"EMAIL_CHANGE_TOKEN_TTL", t.EmailChange},
+		{"IMPERSONATION_TOKEN_TTL", t.Impersonation},
+	} {
+		if f.ttl <= 0 {
+			problems = append(problems, fmt.Errorf("%s must be > 0", f.key))
+		}
+	}
+
+	// A refresh token that dies first can't renew anything
+	if t.Access > 0 && t.Refresh > 0 && t.Access >= t.Refresh {
+		problems = append(problems, fmt.Errorf("JWT_EXPIRATION_TIME (%s) must be shorter than JWT_REFRESH_DURATION (%s)", t.Access, t.Refresh))
+	}
+	if t.Impersonation > MaxImpersonationTTL {
+		problems = append(problems, fmt.Errorf("IMPERSONATION_TOKEN_TTL must be at most %s", MaxImpersonationTTL))
+	}
+	return problems
+}
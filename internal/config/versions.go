@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sunsetDateLayout is the date-only form of V1_SUNSET_DATE
+const sunsetDateLayout = "2006-01-02"
+
+// V1Sunset returns when /api/v1 stops being served, and false when
+// V1_SUNSET_DATE isn't set or doesn't parse. A bare date means its start,
+// in UTC.
+func (c Config) V1Sunset() (time.Time, bool) {
+	t, err := parseSunset(c.V1SunsetDate)
+	return t, err == nil && !t.IsZero()
+}
+
+func parseSunset(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(sunsetDateLayout, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("V1_SUNSET_DATE must be a date such as 2027-06-30 or an RFC 3339 time, got %q", raw)
+	}
+	return t.UTC(), nil
+}
@@ -0,0 +1,168 @@
+package admin
+
+import (
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/autorole"
+	"dvith.com/go-service-api/internal/domain/admin/configdump"
+	"dvith.com/go-service-api/internal/domain/admin/debugcapture"
+	"dvith.com/go-service-api/internal/domain/admin/domainroles"
+	"dvith.com/go-service-api/internal/domain/admin/faultinjection"
+	"dvith.com/go-service-api/internal/domain/admin/forcereset"
+	"dvith.com/go-service-api/internal/domain/admin/invitations"
+	"dvith.com/go-service-api/internal/domain/admin/purge"
+	"dvith.com/go-service-api/internal/domain/admin/reencrypt"
+	"dvith.com/go-service-api/internal/domain/admin/routes"
+	"dvith.com/go-service-api/internal/domain/admin/stats"
+	"dvith.com/go-service-api/internal/domain/admin/userimport"
+	"dvith.com/go-service-api/internal/domain/admin/users"
+	passwordreset "dvith.com/go-service-api/internal/domain/authentication/password_reset"
+	refreshtoken "dvith.com/go-service-api/internal/domain/authentication/refresh_token"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/features"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/routemeta"
+	"dvith.com/go-service-api/internal/web"
+	"github.com/gofiber/fiber/v3"
+)
+
+// RoleAdmin is the role required for every admin route
+const RoleAdmin = "admin"
+
+// AdminClientID is the client profile whose tokens may call admin routes.
+// When no such profile is configured, tokens of any client are accepted.
+const AdminClientID = "web"
+
+// Module serves the admin API, behind the admin_api feature flag
+type Module struct{}
+
+// Name identifies the module in the startup log
+func (Module) Name() string { return "admin" }
+
+// Feature is the flag that must be on at startup for the module to be
+// registered
+func (Module) Feature() string { return features.AdminAPI }
+
+// Register adds the module's routes to router
+func (Module) Register(router fiber.Router, deps *app.Deps) {
+	var audiences []string
+	if profile, ok := deps.TokenManager.ClientProfile(AdminClientID); ok {
+		audiences = append(audiences, profile.Audience)
+	}
+
+	// Admin routes are hidden unless admin_api is on, and require an
+	// authenticated user with the admin role holding a recent token
+	admin := router.Group("/admin",
+		middleware.RequireFeature(deps.Features, features.AdminAPI),
+		middleware.TenantResolver(deps.Tenants),
+		middleware.AuthMiddlewareWith(deps.TokenManager, middleware.AuthOptions{
+			Audiences:    audiences,
+			MaxTokenAge:  deps.Cfg.AdminTokenMaxAge,
+			RequireFresh: deps.Cfg.AdminRequireFreshToken,
+			// Other services' tokens don't administer this one
+			RejectForeign: true,
+		}),
+		middleware.RequireRole(RoleAdmin),
+	)
+
+	// Purge of soft-deleted users runs on a schedule and on demand
+	purgeService := purge.NewService(purge.NewPgRepository(deps.DB), deps.Cfg.UserPurgeAfter, deps.Cfg.UserPurgeMaxPerRun)
+	deps.Runner.Schedule(purgeService, deps.Cfg.UserPurgeInterval)
+	admin.Post("/purge-deleted-users", purge.PurgeHandler(purgeService)).Name("admin.purge")
+
+	// Expired refresh tokens are deleted in batches on a schedule and on
+	// demand
+	retention := refreshtoken.NewRetentionJob(deps.Stores.RefreshTokens, deps.Cfg.TokenHistoryRetention)
+	deps.Runner.Schedule(retention, refreshtoken.RetentionInterval)
+	admin.Post("/compact-refresh-tokens", refreshtoken.CompactHandler(retention)).Name("admin.refresh_tokens.compact")
+
+	// Values written before encryption was enabled, or under a rotated out
+	// key, are moved to the primary key in the background
+	if deps.KeyRing != nil && !deps.Cfg.UsesMemoryStore() {
+		reencryptService := reencrypt.NewService(reencrypt.NewPgRepository(deps.DB), deps.KeyRing, deps.Cfg.ReencryptBatchSize)
+		deps.Runner.Schedule(reencryptService, deps.Cfg.ReencryptInterval)
+		admin.Post("/reencrypt", reencrypt.ReencryptHandler(reencryptService)).Name("admin.reencrypt")
+		deps.Routes.Describe(routemeta.Route{Name: "admin.reencrypt", Summary: "Re-encrypt column values that are plaintext or under an old key", RequireAuth: true, Visibility: routemeta.Authenticated})
+	}
+
+	// Lists rendered into admin pages carry their timestamps formatted
+	// for the admin's locale and time zone under meta.display
+	display := middleware.DisplayTimes(deps.Stores.Preferences, deps.Clock)
+
+	admin.Get("/users", display, users.ListUsersHandler(model.NewUserRepository(deps.DB))).Name("admin.users.list")
+	admin.Get("/stats", stats.StatsHandler(stats.NewService(deps.DB, deps.Cache, deps.Cfg.TokenTTLs.Refresh).WithClock(deps.Clock))).Name("admin.stats")
+
+	// Forced resets after a credential leak end the users' sessions and can
+	// email them a reset link
+	resetMailer := passwordreset.NewService(passwordreset.NewPgRepository(deps.DB), deps.Stores.Users, deps.Mailer, deps.Cfg.TokenTTLs.Reset, deps.Links, web.ResetLinkPath(deps.Cfg)).WithHasher(deps.Hasher)
+	forceReset := forcereset.NewService(forcereset.NewPgRepository(deps.DB), resetMailer, deps.Revocations)
+	admin.Post("/users/require-password-reset", forcereset.BulkRequireResetHandler(forceReset)).Name("admin.users.require_reset.bulk")
+	admin.Post("/users/:id/require-password-reset",
+		middleware.ValidateParams(map[string]middleware.Rule{"id": middleware.UUIDRule()}),
+		forcereset.RequireResetHandler(forceReset),
+	).Name("admin.users.require_reset")
+
+	// Invitation codes gate signup while SIGNUP_REQUIRES_INVITE is on
+	admin.Post("/invitations", invitations.CreateHandler(deps.Stores.Invitations, deps.Clock)).Name("admin.invitations.create")
+	admin.Get("/invitations", display, invitations.ListHandler(deps.Stores.Invitations)).Name("admin.invitations.list")
+	admin.Delete("/invitations/:id",
+		middleware.ValidateParams(map[string]middleware.Rule{"id": middleware.UUIDRule()}),
+		invitations.RevokeHandler(deps.Stores.Invitations),
+	).Name("admin.invitations.revoke")
+
+	// Saved domain role rules override SIGNUP_DOMAIN_ROLES at signup
+	admin.Get("/signup-domain-roles", domainroles.ListHandler(autorole.Rules(deps.Cfg.SignupDomainRoles), deps.Stores.DomainRoles)).Name("admin.domain_roles.list")
+	admin.Put("/signup-domain-roles/:domain", domainroles.PutHandler(deps.Stores.DomainRoles)).Name("admin.domain_roles.put")
+	admin.Delete("/signup-domain-roles/:domain", domainroles.DeleteHandler(deps.Stores.DomainRoles)).Name("admin.domain_roles.delete")
+
+	// Bulk import reads its upload as a stream, so it is exempt from the
+	// body limit applied in domain.Init
+	importer := userimport.NewImporter(userimport.NewPgStore(deps.DB), deps.Cfg.UserImportBatchSize).WithHasher(deps.Hasher)
+	admin.Post("/users/import", userimport.ImportHandler(importer)).Name("admin.users.import")
+
+	admin.Get("/routes", routes.RoutesHandler(deps.Routes)).Name("admin.routes")
+	admin.Get("/config", configdump.ConfigHandler(deps.Cfg, deps.Logger, deps.Features)).Name("admin.config")
+	admin.Put("/config/features/:name",
+		middleware.ValidateParams(map[string]middleware.Rule{"name": middleware.PatternRule(features.NamePattern, "lower_snake_case")}),
+		configdump.SetFeatureHandler(deps.Features),
+	).Name("admin.features.set")
+
+	// Request capture records a user's traffic for debugging; see package capture
+	admin.Post("/capture", debugcapture.EnableHandler(deps.Capture)).Name("admin.capture.enable")
+	admin.Get("/capture/:user_id",
+		middleware.ValidateParams(map[string]middleware.Rule{"user_id": middleware.UUIDRule()}),
+		debugcapture.GetHandler(deps.Capture),
+	).Name("admin.capture.get")
+
+	// Fault injection rules can be changed at runtime, except in production
+	admin.Get("/faults", faultinjection.GetHandler(deps.Faults)).Name("admin.faults.get")
+	admin.Put("/faults", faultinjection.SetHandler(deps.Faults)).Name("admin.faults.set")
+
+	// Turning admin_api off at runtime would lock operators out of this
+	// endpoint until a restart
+	deps.Features.OnChange(func(name string, enabled bool) error {
+		if name == features.AdminAPI && !enabled {
+			return configdump.ErrFeatureLocked
+		}
+		return nil
+	})
+
+	deps.Routes.Describe(routemeta.Route{Name: "admin.purge", Summary: "Purge users soft-deleted past the retention period", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.users.list", Summary: "List the tenant's users with sorting, filtering and cursor pagination", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.stats", Summary: "Aggregate user, session and signin numbers for dashboards", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.users.require_reset", Summary: "Force a user to reset their password, ending their sessions", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.users.require_reset.bulk", Summary: "Force a list of users to reset their passwords", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.invitations.create", Summary: "Create a signup invitation code with a use limit and expiry", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.invitations.list", Summary: "List the tenant's signup invitations and their use counts", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.invitations.revoke", Summary: "Revoke a signup invitation code", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.domain_roles.list", Summary: "List the rules assigning roles to signups by email domain", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.domain_roles.put", Summary: "Save the role signups from an email domain get", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.domain_roles.delete", Summary: "Delete a saved domain role rule, restoring the configured one", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.users.import", Summary: "Import users from an NDJSON or CSV upload with a streamed report", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.routes", Summary: "List registered routes and the middleware order of each group", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.config", Summary: "Show the effective configuration and where each value came from", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.features.set", Summary: "Turn a feature flag on or off without a restart", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.capture.enable", Summary: "Record a user's requests and responses for a limited window", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.capture.get", Summary: "Show the redacted requests captured for a user", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.faults.get", Summary: "Show the active fault injection rules", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "admin.faults.set", Summary: "Replace the fault injection rules for resilience testing", RequireAuth: true, Visibility: routemeta.Authenticated})
+}
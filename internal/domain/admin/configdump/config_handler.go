@@ -0,0 +1,69 @@
+package configdump
+
+import (
+	"errors"
+
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/features"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// ErrFeatureLocked is returned by feature hooks that refuse a runtime change
+var ErrFeatureLocked = errors.New("feature cannot be changed at runtime")
+
+// ConfigResponse is the effective configuration reported to operators
+type ConfigResponse struct {
+	Config   map[string]config.Field `json:"config"`
+	LogLevel string                  `json:"log_level"`
+	Features []string                `json:"features"`
+}
+
+// ConfigHandler reports the configuration the service is running with,
+// where each value came from, the log level actually in effect and the
+// feature flags currently on. Secrets are masked by config.Redacted.
+func ConfigHandler(cfg config.Config, log *logger.Logger, flags *features.Flags) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		return c.Status(fiber.StatusOK).JSON(ConfigResponse{
+			Config:   cfg.Redacted(),
+			LogLevel: log.Level().String(),
+			Features: flags.List(),
+		})
+	}
+}
+
+// SetFeatureRequest turns a feature flag on or off
+type SetFeatureRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// SetFeatureHandler flips the feature named in the path at runtime
+func SetFeatureHandler(flags *features.Flags) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var req SetFeatureRequest
+		if err := c.Bind().Body(&req); err != nil {
+			return middleware.ValidationErrorResponse(c, "invalid request body")
+		}
+		if req.Enabled == nil {
+			return middleware.ValidationErrorResponse(c, "enabled is required")
+		}
+
+		name := c.Params("name")
+		if err := flags.Set(name, *req.Enabled); err != nil {
+			if errors.Is(err, features.ErrInvalidName) {
+				return middleware.ValidationErrorResponse(c, err.Error())
+			}
+			return middleware.ConflictResponse(c, err.Error())
+		}
+
+		logger.Info("feature flag changed", map[string]any{
+			"feature": name,
+			"enabled": *req.Enabled,
+		})
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"features": flags.List(),
+		})
+	}
+}
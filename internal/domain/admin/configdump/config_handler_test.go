@@ -0,0 +1,72 @@
+package configdump
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/features"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigHandler(t *testing.T) {
+	cfg := config.Config{Port: 8080, JWTSecretKey: "super-secret"}
+	app := fiber.New()
+	app.Get("/config", ConfigHandler(cfg, logger.NewLogger(io.Discard, logger.WarnLevel, false), features.NewFlags([]string{"signup_v2"})))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/config", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "super-secret")
+
+	var body struct {
+		Config   map[string]config.Field `json:"config"`
+		LogLevel string                  `json:"log_level"`
+		Features []string                `json:"features"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &body))
+	assert.Equal(t, "WARN", body.LogLevel)
+	assert.Equal(t, []string{"signup_v2"}, body.Features)
+	assert.Equal(t, float64(8080), body.Config["PORT"].Value)
+	assert.Equal(t, config.SourceDefault, body.Config["PORT"].Source)
+}
+
+func TestSetFeatureHandler(t *testing.T) {
+	flags := features.NewFlags([]string{features.AdminAPI})
+	flags.OnChange(func(name string, enabled bool) error {
+		if name == features.AdminAPI && !enabled {
+			return ErrFeatureLocked
+		}
+		return nil
+	})
+
+	app := fiber.New()
+	app.Put("/features/:name", SetFeatureHandler(flags))
+
+	put := func(name, body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/features/"+name, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, put("signup_v2", `{"enabled":true}`))
+	assert.True(t, flags.Enabled("signup_v2"))
+
+	assert.Equal(t, http.StatusBadRequest, put("signup_v2", `{}`))
+	assert.Equal(t, http.StatusBadRequest, put("Bad-Name", `{"enabled":true}`))
+
+	assert.Equal(t, http.StatusConflict, put(features.AdminAPI, `{"enabled":false}`))
+	assert.True(t, flags.Enabled(features.AdminAPI))
+}
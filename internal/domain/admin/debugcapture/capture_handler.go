@@ -0,0 +1,75 @@
+package debugcapture
+
+import (
+	"time"
+
+	"dvith.com/go-service-api/internal/capture"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// DefaultTTL is the capture window used when the request names none
+const DefaultTTL = 10 * time.Minute
+
+// EnableRequest turns capture on for a user
+type EnableRequest struct {
+	UserID string `json:"user_id"`
+	TTL    string `json:"ttl"`
+}
+
+// EnableResponse reports the capture window that was opened
+type EnableResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// EnableHandler turns on capture of a user's requests for a window of at
+// most capture.MaxWindow
+func EnableHandler(capturer *capture.Capturer) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var req EnableRequest
+		if err := c.Bind().Body(&req); err != nil {
+			return middleware.ValidationErrorResponse(c, "invalid request body")
+		}
+		userID, err := uuid.Parse(req.UserID)
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, "user_id must be a UUID")
+		}
+		ttl := DefaultTTL
+		if req.TTL != "" {
+			ttl, err = time.ParseDuration(req.TTL)
+			if err != nil || ttl <= 0 {
+				return middleware.ValidationErrorResponse(c, "ttl must be a positive duration such as 10m")
+			}
+		}
+		if ttl > capture.MaxWindow {
+			return middleware.ValidationErrorResponse(c, "ttl must be at most "+capture.MaxWindow.String())
+		}
+
+		expiresAt := capturer.Enable(userID, ttl)
+
+		fields := map[string]any{
+			"user_id":    userID.String(),
+			"expires_at": expiresAt,
+		}
+		if adminID, err := middleware.GetUserIDFromContext(c); err == nil {
+			fields["admin_id"] = adminID.String()
+		}
+		logger.Info("request capture enabled", fields)
+
+		return c.Status(fiber.StatusOK).JSON(EnableResponse{UserID: userID, ExpiresAt: expiresAt})
+	}
+}
+
+// GetHandler returns the requests captured for the user in the path
+func GetHandler(capturer *capture.Capturer) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, "user_id must be a UUID")
+		}
+		return c.Status(fiber.StatusOK).JSON(capturer.Get(userID))
+	}
+}
@@ -0,0 +1,52 @@
+package debugcapture
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dvith.com/go-service-api/internal/capture"
+	"dvith.com/go-service-api/pkg/cache"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureHandlers(t *testing.T) {
+	capturer := capture.NewCapturer(cache.NewMemory())
+	app := fiber.New()
+	app.Post("/capture", EnableHandler(capturer))
+	app.Get("/capture/:user_id", GetHandler(capturer))
+
+	enable := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/capture", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	userID := uuid.New()
+	assert.Equal(t, http.StatusBadRequest, enable(`{"user_id":"nope","ttl":"10m"}`))
+	assert.Equal(t, http.StatusBadRequest, enable(`{"user_id":"`+userID.String()+`","ttl":"soon"}`))
+	assert.Equal(t, http.StatusBadRequest, enable(`{"user_id":"`+userID.String()+`","ttl":"2h"}`))
+	assert.False(t, capturer.Active(userID))
+
+	assert.Equal(t, http.StatusOK, enable(`{"user_id":"`+userID.String()+`","ttl":"10m"}`))
+	assert.True(t, capturer.Active(userID))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/capture/"+userID.String(), nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var got capture.Capture
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.Equal(t, userID, got.UserID)
+	assert.True(t, got.Active)
+	assert.NotNil(t, got.ExpiresAt)
+	assert.Empty(t, got.Records)
+}
@@ -0,0 +1,114 @@
+// Package domainroles serves the admin endpoints managing the rules that
+// assign roles to signups by email domain. Saved rules override
+// SIGNUP_DOMAIN_ROLES for the same domain.
+package domainroles
+
+import (
+	"errors"
+	"strings"
+
+	"dvith.com/go-service-api/internal/autorole"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// MaxRoleLength matches the user_roles.role column
+const MaxRoleLength = 50
+
+// PutRequest is the body of PutHandler
+type PutRequest struct {
+	Role string `json:"role"`
+}
+
+// ListResponse lists the tenant's saved rules next to the static ones from
+// the configuration, which apply to the domains without a saved rule
+type ListResponse struct {
+	Rules  []autorole.Rule `json:"rules"`
+	Static autorole.Rules  `json:"static"`
+}
+
+// ListHandler lists the current tenant's rules
+func ListHandler(static autorole.Rules, store autorole.Store) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		rules, err := store.List(middleware.GetRequestContext(c))
+		if err != nil {
+			logger.Error("failed to list domain role rules", map[string]any{
+				"error": err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to list domain role rules", err)
+		}
+		if rules == nil {
+			rules = []autorole.Rule{}
+		}
+		if static == nil {
+			static = autorole.Rules{}
+		}
+		return c.Status(fiber.StatusOK).JSON(ListResponse{Rules: rules, Static: static})
+	}
+}
+
+// PutHandler saves the role signups from the domain in the path get.
+// Users who already signed up keep the roles they were granted.
+func PutHandler(store autorole.Store) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		domain := strings.ToLower(strings.TrimSpace(c.Params("domain")))
+		if !validDomain(domain) {
+			return middleware.ValidationErrorResponse(c, "domain must be an email domain such as example.com")
+		}
+		var req PutRequest
+		if err := c.Bind().Body(&req); err != nil {
+			return middleware.ValidationErrorResponse(c, "invalid request body")
+		}
+		req.Role = strings.TrimSpace(req.Role)
+		if req.Role == "" || len(req.Role) > MaxRoleLength || strings.ContainsAny(req.Role, " \t,") {
+			return middleware.ValidationErrorResponse(c, "role is required, without spaces or commas, and at most 50 characters")
+		}
+		adminID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		rule := &autorole.Rule{Domain: domain, Role: req.Role}
+		if err := store.Put(middleware.GetRequestContext(c), rule); err != nil {
+			logger.Error("failed to save domain role rule", map[string]any{
+				"admin_id": adminID.String(),
+				"domain":   domain,
+				"error":    err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to save domain role rule", err)
+		}
+
+		logger.Info("domain role rule saved", map[string]any{
+			"admin_id": adminID.String(),
+			"domain":   rule.Domain,
+			"role":     rule.Role,
+		})
+		return c.Status(fiber.StatusOK).JSON(rule)
+	}
+}
+
+// DeleteHandler removes the saved rule for the domain in the path, so the
+// static rule, if any, applies again
+func DeleteHandler(store autorole.Store) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		domain := strings.ToLower(strings.TrimSpace(c.Params("domain")))
+		if err := store.Delete(middleware.GetRequestContext(c), domain); err != nil {
+			if errors.Is(err, autorole.ErrNotFound) {
+				return middleware.NotFoundResponse(c, err.Error())
+			}
+			logger.Error("failed to delete domain role rule", map[string]any{
+				"domain": domain,
+				"error":  err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to delete domain role rule", err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// validDomain reports whether domain looks like the domain of an email
+func validDomain(domain string) bool {
+	return domain != "" && len(domain) <= 255 && strings.Contains(domain, ".") &&
+		!strings.ContainsAny(domain, "@ \t,/")
+}
@@ -0,0 +1,75 @@
+package domainroles
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dvith.com/go-service-api/internal/autorole"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainRoleHandlers(t *testing.T) {
+	store := autorole.NewMemoryStore()
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	static := autorole.Rules{"acme.com": "acme-member"}
+
+	app := fiber.New()
+	app.Use(func(c fiber.Ctx) error {
+		c.Locals(middleware.ContextKeyRequestContext, ctx)
+		c.Locals(middleware.ContextKeyUserID, uuid.New())
+		return c.Next()
+	})
+	app.Get("/signup-domain-roles", ListHandler(static, store))
+	app.Put("/signup-domain-roles/:domain", PutHandler(store))
+	app.Delete("/signup-domain-roles/:domain", DeleteHandler(store))
+
+	send := func(method, path, body string) (int, map[string]any) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		out := map[string]any{}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	status, _ := send(http.MethodPut, "/signup-domain-roles/no-dot", `{"role":"x"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = send(http.MethodPut, "/signup-domain-roles/acme.com", `{"role":"two words"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = send(http.MethodPut, "/signup-domain-roles/acme.com", `{}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, saved := send(http.MethodPut, "/signup-domain-roles/ACME.com", `{"role":"acme-staff"}`)
+	require.Equal(t, http.StatusOK, status, saved)
+	assert.Equal(t, "acme.com", saved["domain"], "domains are lower-cased")
+
+	a := autorole.NewAssigner(static, store, store, false)
+	role, err := a.Resolve(ctx, "jane@acme.com")
+	require.NoError(t, err)
+	assert.Equal(t, "acme-staff", role, "the saved rule overrides the static one")
+
+	status, list := send(http.MethodGet, "/signup-domain-roles", "")
+	require.Equal(t, http.StatusOK, status)
+	rules, _ := list["rules"].([]any)
+	require.Len(t, rules, 1)
+	assert.Equal(t, map[string]any{"acme.com": "acme-member"}, list["static"])
+
+	status, _ = send(http.MethodDelete, "/signup-domain-roles/acme.com", "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = send(http.MethodDelete, "/signup-domain-roles/acme.com", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	role, err = a.Resolve(ctx, "jane@acme.com")
+	require.NoError(t, err)
+	assert.Equal(t, "acme-member", role)
+}
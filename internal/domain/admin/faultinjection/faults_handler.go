@@ -0,0 +1,67 @@
+package faultinjection
+
+import (
+	"errors"
+	"strings"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// SetRequest replaces the fault rules, in the FAULT_INJECTION syntax. An
+// empty spec stops injection.
+type SetRequest struct {
+	Spec string `json:"spec"`
+}
+
+// FaultsResponse lists the active fault rules
+type FaultsResponse struct {
+	Enabled bool     `json:"enabled"`
+	Rules   []string `json:"rules"`
+}
+
+// GetHandler returns the active fault rules
+func GetHandler(faults *middleware.FaultInjector) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		return c.Status(fiber.StatusOK).JSON(response(faults))
+	}
+}
+
+// SetHandler replaces the fault rules without a restart. It is refused in
+// production, where the injector is hard-disabled.
+func SetHandler(faults *middleware.FaultInjector) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var req SetRequest
+		if err := c.Bind().Body(&req); err != nil {
+			return middleware.ValidationErrorResponse(c, "invalid request body")
+		}
+		rules, err := middleware.ParseFaultRules(req.Spec)
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, err.Error())
+		}
+		if err := faults.SetRules(rules); err != nil {
+			if errors.Is(err, middleware.ErrFaultInjectionDisabled) {
+				return middleware.ForbiddenResponse(c, err.Error())
+			}
+			return err
+		}
+
+		fields := map[string]any{"spec": strings.TrimSpace(req.Spec)}
+		if adminID, err := middleware.GetUserIDFromContext(c); err == nil {
+			fields["admin_id"] = adminID.String()
+		}
+		logger.Warn("fault injection rules replaced", fields)
+
+		return c.Status(fiber.StatusOK).JSON(response(faults))
+	}
+}
+
+func response(faults *middleware.FaultInjector) FaultsResponse {
+	rules := faults.Rules()
+	resp := FaultsResponse{Enabled: faults.Enabled(), Rules: make([]string, len(rules))}
+	for i, r := range rules {
+		resp.Rules[i] = r.String()
+	}
+	return resp
+}
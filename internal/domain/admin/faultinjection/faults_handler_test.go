@@ -0,0 +1,67 @@
+package faultinjection
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newApp(faults *middleware.FaultInjector) *fiber.App {
+	app := fiber.New()
+	app.Get("/faults", GetHandler(faults))
+	app.Put("/faults", SetHandler(faults))
+	return app
+}
+
+func set(t *testing.T, app *fiber.App, body string) (int, FaultsResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, "/faults", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var out FaultsResponse
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	}
+	return resp.StatusCode, out
+}
+
+func TestFaultHandlers(t *testing.T) {
+	faults := middleware.NewFaultInjector(nil, false)
+	app := newApp(faults)
+
+	status, _ := set(t, app, `{"spec":"latency:fast:0.1"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Empty(t, faults.Rules())
+
+	status, out := set(t, app, `{"spec":"latency:200ms:0.1,error:503:0.05:/api/v1/users"}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, out.Enabled)
+	assert.Equal(t, []string{"latency:200ms:0.1", "error:503:0.05:/api/v1/users"}, out.Rules)
+	assert.Len(t, faults.Rules(), 2)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/faults", nil))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Len(t, out.Rules, 2)
+
+	status, out = set(t, app, `{"spec":""}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, out.Rules)
+}
+
+func TestSetHandler_RefusedInProduction(t *testing.T) {
+	faults := middleware.NewFaultInjector(nil, true)
+	status, _ := set(t, newApp(faults), `{"spec":"error:503:1"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Empty(t, faults.Rules())
+}
@@ -0,0 +1,91 @@
+package forcereset
+
+import (
+	"fmt"
+	"slices"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// RequireResetRequest is the optional body of RequireResetHandler
+type RequireResetRequest struct {
+	SendEmail bool `json:"send_email"`
+}
+
+// BulkRequireResetRequest is the body of BulkRequireResetHandler
+type BulkRequireResetRequest struct {
+	UserIDs   []string `json:"user_ids"`
+	SendEmail bool     `json:"send_email"`
+}
+
+// BulkRequireResetResponse lists the outcome for every requested user
+type BulkRequireResetResponse struct {
+	Results []Result `json:"results"`
+}
+
+// RequireResetHandler forces the user in the path to reset their password
+func RequireResetHandler(svc *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := uuid.Parse(c.Params("id"))
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, "id must be a UUID")
+		}
+		var req RequireResetRequest
+		if len(c.Body()) > 0 {
+			if err := c.Bind().Body(&req); err != nil {
+				return middleware.ValidationErrorResponse(c, "invalid request body")
+			}
+		}
+		adminID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		result := svc.RequireReset(middleware.GetRequestContext(c), adminID, []uuid.UUID{userID}, req.SendEmail)[0]
+		switch result.Status {
+		case StatusNotFound:
+			return middleware.NotFoundResponse(c, "user not found")
+		case StatusFailed:
+			return middleware.InternalErrorResponse(c, result.Error)
+		}
+		return c.Status(fiber.StatusOK).JSON(result)
+	}
+}
+
+// BulkRequireResetHandler forces up to MaxBatch users to reset their
+// password. It answers 200 with a result per user even when some of them
+// failed; repeated IDs are handled once.
+func BulkRequireResetHandler(svc *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var req BulkRequireResetRequest
+		if err := c.Bind().Body(&req); err != nil {
+			return middleware.ValidationErrorResponse(c, "invalid request body")
+		}
+		if len(req.UserIDs) == 0 {
+			return middleware.ValidationErrorResponse(c, "user_ids must not be empty")
+		}
+		if len(req.UserIDs) > MaxBatch {
+			return middleware.ValidationErrorResponse(c, fmt.Sprintf("user_ids may list at most %d users", MaxBatch))
+		}
+
+		userIDs := make([]uuid.UUID, 0, len(req.UserIDs))
+		for _, raw := range req.UserIDs {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				return middleware.ValidationErrorResponse(c, fmt.Sprintf("user_ids: %q is not a UUID", raw))
+			}
+			if !slices.Contains(userIDs, id) {
+				userIDs = append(userIDs, id)
+			}
+		}
+		adminID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		results := svc.RequireReset(middleware.GetRequestContext(c), adminID, userIDs, req.SendEmail)
+		return c.Status(fiber.StatusOK).JSON(BulkRequireResetResponse{Results: results})
+	}
+}
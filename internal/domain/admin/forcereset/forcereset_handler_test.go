@@ -0,0 +1,92 @@
+package forcereset
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newApp(svc *Service) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c fiber.Ctx) error {
+		c.Locals(middleware.ContextKeyUserID, uuid.New())
+		return c.Next()
+	})
+	app.Post("/users/require-password-reset", BulkRequireResetHandler(svc))
+	app.Post("/users/:id/require-password-reset", RequireResetHandler(svc))
+	return app
+}
+
+func post(t *testing.T, app *fiber.App, path, body string) (*http.Response, []byte) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var raw json.RawMessage
+	_ = json.NewDecoder(resp.Body).Decode(&raw)
+	return resp, raw
+}
+
+func TestRequireResetHandler(t *testing.T) {
+	alice := &model.User{ID: uuid.New(), Email: "alice@example.com"}
+	mailer := &fakeMailer{}
+	app := newApp(NewService(newFakeRepository(alice), mailer, nil))
+
+	resp, body := post(t, app, "/users/"+alice.ID.String()+"/require-password-reset", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result Result
+	require.NoError(t, json.Unmarshal(body, &result))
+	assert.Equal(t, StatusResetRequired, result.Status)
+	assert.Equal(t, 2, result.SessionsRevoked)
+	assert.Empty(t, mailer.sent, "no email unless asked")
+
+	resp, _ = post(t, app, "/users/"+alice.ID.String()+"/require-password-reset", `{"send_email":true}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"alice@example.com"}, mailer.sent)
+
+	resp, _ = post(t, app, "/users/"+uuid.NewString()+"/require-password-reset", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = post(t, app, "/users/nope/require-password-reset", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestBulkRequireResetHandler(t *testing.T) {
+	alice := &model.User{ID: uuid.New(), Email: "alice@example.com"}
+	repo := newFakeRepository(alice)
+	app := newApp(NewService(repo, nil, nil))
+
+	unknown := uuid.NewString()
+	resp, body := post(t, app, "/users/require-password-reset", `{"user_ids":["`+alice.ID.String()+`","`+unknown+`","`+alice.ID.String()+`"]}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var out BulkRequireResetResponse
+	require.NoError(t, json.Unmarshal(body, &out))
+	require.Len(t, out.Results, 2, "repeated IDs are handled once")
+	assert.Equal(t, StatusResetRequired, out.Results[0].Status)
+	assert.Equal(t, StatusNotFound, out.Results[1].Status)
+	assert.Len(t, repo.events, 1)
+
+	resp, _ = post(t, app, "/users/require-password-reset", `{"user_ids":[]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = post(t, app, "/users/require-password-reset", `{"user_ids":["nope"]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	ids := make([]string, MaxBatch+1)
+	for i := range ids {
+		ids[i] = `"` + uuid.NewString() + `"`
+	}
+	resp, _ = post(t, app, "/users/require-password-reset", `{"user_ids":[`+strings.Join(ids, ",")+`]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
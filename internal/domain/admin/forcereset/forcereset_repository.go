@@ -0,0 +1,87 @@
+package forcereset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/crypto"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Target is a user whose password reset was required
+type Target struct {
+	ID       uuid.UUID              `db:"id"`
+	Email    string                 `db:"email"`
+	FullName crypto.EncryptedString `db:"full_name"`
+	// RevokedSessions are the sessions that were active until now
+	RevokedSessions []uuid.UUID `db:"-"`
+}
+
+// Repository is the persistence contract for the force reset service
+type Repository interface {
+	// RequireReset flags the user of the current tenant, revokes all of the
+	// user's sessions and refresh tokens and records event, all in one
+	// transaction. It returns model.ErrUserNotFound for unknown and deleted
+	// users.
+	RequireReset(ctx context.Context, userID uuid.UUID, event audit.Entry) (*Target, error)
+}
+
+// PgRepository implements Repository against Postgres
+type PgRepository struct {
+	db *database.DBPool
+}
+
+// NewPgRepository creates a new force reset repository
+func NewPgRepository(db *database.DBPool) *PgRepository {
+	return &PgRepository{db: db}
+}
+
+// RequireReset implements Repository. Requiring a reset again is allowed
+// and revokes whatever sessions were opened in between.
+func (repo *PgRepository) RequireReset(ctx context.Context, userID uuid.UUID, event audit.Entry) (*Target, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE users SET must_reset_password = true, updated_at = $3
+		WHERE deleted_at IS NULL AND tenant_id = $1 AND id = $2
+		RETURNING id, email, full_name
+	`
+
+	var target *Target
+	err = repo.db.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if target, err = database.QueryOne[Target](ctx, tx, query, tenantID, userID, clock.Now()); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return model.ErrUserNotFound
+			}
+			return fmt.Errorf("failed to flag user: %w", err)
+		}
+
+		revoked, err := session.NewRepository(tx).RevokeAll(ctx, userID)
+		if err != nil {
+			return err
+		}
+		target.RevokedSessions = revoked
+
+		metadata := map[string]any{"sessions_revoked": len(revoked)}
+		maps.Copy(metadata, event.Metadata)
+		event.UserID, event.Metadata = userID, metadata
+		return audit.Record(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
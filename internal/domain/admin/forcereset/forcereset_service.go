@@ -0,0 +1,116 @@
+package forcereset
+
+import (
+	"context"
+	"errors"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// MaxBatch is how many users one bulk request may flag
+const MaxBatch = 100
+
+// Result statuses
+const (
+	StatusResetRequired = "reset_required"
+	StatusNotFound      = "not_found"
+	StatusFailed        = "failed"
+)
+
+// ResetMailer emails a password reset link. passwordreset.Service
+// implements it.
+type ResetMailer interface {
+	RequestReset(ctx context.Context, email string) error
+}
+
+// RevokedSessions records revocations on this instance
+type RevokedSessions interface {
+	MarkRevoked(sessionID uuid.UUID)
+}
+
+// Result is the outcome for one user
+type Result struct {
+	UserID          uuid.UUID `json:"user_id"`
+	Status          string    `json:"status"`
+	SessionsRevoked int       `json:"sessions_revoked"`
+	EmailSent       bool      `json:"email_sent"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// Service forces users to choose a new password, e.g. after a credential
+// leak. Flagged users can't sign in until they reset it, and their
+// sessions end at once.
+type Service struct {
+	repo    Repository
+	mailer  ResetMailer
+	revoked RevokedSessions
+}
+
+// NewService creates a force reset service
+func NewService(repo Repository, mailer ResetMailer, revoked RevokedSessions) *Service {
+	return &Service{repo: repo, mailer: mailer, revoked: revoked}
+}
+
+// RequireReset flags each user and, with sendEmail, emails them a reset
+// link. adminID is recorded in the audit events. Users are handled one at a
+// time, so one failure doesn't undo the others; the results follow the
+// order of userIDs.
+func (s *Service) RequireReset(ctx context.Context, adminID uuid.UUID, userIDs []uuid.UUID, sendEmail bool) []Result {
+	results := make([]Result, 0, len(userIDs))
+	for _, userID := range userIDs {
+		results = append(results, s.requireOne(ctx, adminID, userID, sendEmail))
+	}
+	return results
+}
+
+func (s *Service) requireOne(ctx context.Context, adminID, userID uuid.UUID, sendEmail bool) Result {
+	result := Result{UserID: userID}
+
+	target, err := s.repo.RequireReset(ctx, userID, audit.Entry{
+		Action:   audit.ActionPasswordResetRequired,
+		Metadata: map[string]any{"required_by": adminID.String(), "email_sent": sendEmail},
+	})
+	if errors.Is(err, model.ErrUserNotFound) {
+		result.Status = StatusNotFound
+		return result
+	}
+	if err != nil {
+		logger.Error("failed to require password reset", map[string]any{
+			"user_id": userID.String(),
+			"error":   err.Error(),
+		})
+		result.Status, result.Error = StatusFailed, "failed to require password reset"
+		return result
+	}
+
+	result.Status = StatusResetRequired
+	result.SessionsRevoked = len(target.RevokedSessions)
+	if s.revoked != nil {
+		for _, id := range target.RevokedSessions {
+			s.revoked.MarkRevoked(id)
+		}
+	}
+	logger.Info("password reset required", map[string]any{
+		"user_id":          userID.String(),
+		"admin_id":         adminID.String(),
+		"sessions_revoked": result.SessionsRevoked,
+	})
+
+	// The flag stays set when the email fails; the user can still ask for
+	// a link through the forgot password flow
+	if sendEmail && s.mailer != nil {
+		if err := s.mailer.RequestReset(ctx, target.Email); err != nil {
+			logger.Warn("failed to send required password reset email", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+			result.Error = "password reset required but the email could not be sent"
+		} else {
+			result.EmailSent = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,128 @@
+package forcereset
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepository flags users in memory and keeps the audit events it was
+// asked to record
+type fakeRepository struct {
+	users    map[uuid.UUID]*model.User
+	sessions map[uuid.UUID][]uuid.UUID
+	events   []audit.Entry
+	err      error
+}
+
+func newFakeRepository(users ...*model.User) *fakeRepository {
+	r := &fakeRepository{users: map[uuid.UUID]*model.User{}, sessions: map[uuid.UUID][]uuid.UUID{}}
+	for _, u := range users {
+		r.users[u.ID] = u
+		r.sessions[u.ID] = []uuid.UUID{uuid.New(), uuid.New()}
+	}
+	return r
+}
+
+func (r *fakeRepository) RequireReset(ctx context.Context, userID uuid.UUID, event audit.Entry) (*Target, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	u, ok := r.users[userID]
+	if !ok {
+		return nil, model.ErrUserNotFound
+	}
+	u.MustResetPassword = true
+	revoked := r.sessions[userID]
+	r.sessions[userID] = nil
+
+	event.UserID = userID
+	r.events = append(r.events, event)
+	return &Target{ID: userID, Email: u.Email, FullName: u.FullName, RevokedSessions: revoked}, nil
+}
+
+type fakeMailer struct {
+	sent []string
+	err  error
+}
+
+func (m *fakeMailer) RequestReset(ctx context.Context, email string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+type markedSessions []uuid.UUID
+
+func (m *markedSessions) MarkRevoked(sessionID uuid.UUID) {
+	*m = append(*m, sessionID)
+}
+
+func TestRequireReset(t *testing.T) {
+	alice := &model.User{ID: uuid.New(), Email: "alice@example.com"}
+	bob := &model.User{ID: uuid.New(), Email: "bob@example.com"}
+	repo := newFakeRepository(alice, bob)
+	mailer := &fakeMailer{}
+	var marked markedSessions
+	svc := NewService(repo, mailer, &marked)
+
+	adminID, unknown := uuid.New(), uuid.New()
+	results := svc.RequireReset(context.Background(), adminID, []uuid.UUID{alice.ID, unknown, bob.ID}, true)
+
+	require.Len(t, results, 3)
+	assert.Equal(t, Result{UserID: alice.ID, Status: StatusResetRequired, SessionsRevoked: 2, EmailSent: true}, results[0])
+	assert.Equal(t, Result{UserID: unknown, Status: StatusNotFound}, results[1])
+	assert.Equal(t, StatusResetRequired, results[2].Status)
+
+	assert.True(t, alice.MustResetPassword)
+	assert.True(t, bob.MustResetPassword)
+	assert.Equal(t, []string{"alice@example.com", "bob@example.com"}, mailer.sent)
+	assert.Len(t, marked, 4, "revoked sessions are cut off on this instance at once")
+
+	require.Len(t, repo.events, 2, "one audit event per flagged user")
+	for i, user := range []*model.User{alice, bob} {
+		e := repo.events[i]
+		assert.Equal(t, audit.ActionPasswordResetRequired, e.Action)
+		assert.Equal(t, user.ID, e.UserID)
+		assert.Equal(t, adminID.String(), e.Metadata["required_by"])
+		assert.Equal(t, true, e.Metadata["email_sent"])
+	}
+}
+
+func TestRequireReset_EmailFailureKeepsFlag(t *testing.T) {
+	alice := &model.User{ID: uuid.New(), Email: "alice@example.com"}
+	svc := NewService(newFakeRepository(alice), &fakeMailer{err: errors.New("smtp down")}, nil)
+
+	result := svc.RequireReset(context.Background(), uuid.New(), []uuid.UUID{alice.ID}, true)[0]
+	assert.Equal(t, StatusResetRequired, result.Status)
+	assert.False(t, result.EmailSent)
+	assert.NotEmpty(t, result.Error)
+	assert.True(t, alice.MustResetPassword)
+}
+
+func TestRequireReset_WithoutEmail(t *testing.T) {
+	alice := &model.User{ID: uuid.New(), Email: "alice@example.com"}
+	mailer := &fakeMailer{}
+	svc := NewService(newFakeRepository(alice), mailer, nil)
+
+	result := svc.RequireReset(context.Background(), uuid.New(), []uuid.UUID{alice.ID}, false)[0]
+	assert.Equal(t, StatusResetRequired, result.Status)
+	assert.False(t, result.EmailSent)
+	assert.Empty(t, mailer.sent)
+}
+
+func TestRequireReset_RepositoryFailure(t *testing.T) {
+	repo := newFakeRepository()
+	repo.err = errors.New("conn closed")
+
+	result := NewService(repo, nil, nil).RequireReset(context.Background(), uuid.New(), []uuid.UUID{uuid.New()}, false)[0]
+	assert.Equal(t, StatusFailed, result.Status)
+}
@@ -0,0 +1,139 @@
+// Package invitations serves the admin endpoints creating, listing and
+// revoking the invitation codes signup requires while it is invite only
+package invitations
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/invitation"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultValidity is how long an invitation lasts when the request
+	// names no expires_at
+	DefaultValidity = 7 * 24 * time.Hour
+	// MaxValidity bounds expires_at, so forgotten codes don't stay usable
+	MaxValidity = 90 * 24 * time.Hour
+	// MaxUses bounds max_uses
+	MaxUses = 10000
+)
+
+// CreateRequest is the body of CreateHandler. max_uses defaults to 1.
+type CreateRequest struct {
+	MaxUses   int        `json:"max_uses"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateResponse is the new invitation with its code, which is only ever
+// shown here
+type CreateResponse struct {
+	Code string `json:"code"`
+	invitation.Invitation
+}
+
+// ListResponse lists the tenant's invitations, newest first
+type ListResponse struct {
+	Invitations []invitation.Invitation `json:"invitations"`
+}
+
+// CreateHandler creates an invitation code for the current tenant
+func CreateHandler(store invitation.Store, now func() time.Time) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var req CreateRequest
+		if len(c.Body()) > 0 {
+			if err := c.Bind().Body(&req); err != nil {
+				return middleware.ValidationErrorResponse(c, "invalid request body")
+			}
+		}
+		if req.MaxUses == 0 {
+			req.MaxUses = 1
+		}
+		if req.MaxUses < 0 || req.MaxUses > MaxUses {
+			return middleware.ValidationErrorResponse(c, fmt.Sprintf("max_uses must be between 1 and %d", MaxUses))
+		}
+		created := now()
+		expiresAt := created.Add(DefaultValidity)
+		if req.ExpiresAt != nil {
+			expiresAt = *req.ExpiresAt
+		}
+		if !expiresAt.After(created) || expiresAt.Sub(created) > MaxValidity {
+			return middleware.ValidationErrorResponse(c, "expires_at must be in the future and at most "+MaxValidity.String()+" away")
+		}
+		adminID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		code, err := invitation.NewCode()
+		if err != nil {
+			return middleware.InternalErrorResponse(c, "failed to create invitation", err)
+		}
+		inv := &invitation.Invitation{
+			CodeHash:  invitation.HashCode(code),
+			CreatedBy: &adminID,
+			MaxUses:   req.MaxUses,
+			ExpiresAt: expiresAt,
+		}
+		if err := store.Create(middleware.GetRequestContext(c), inv); err != nil {
+			logger.Error("failed to create invitation", map[string]any{
+				"admin_id": adminID.String(),
+				"error":    err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to create invitation", err)
+		}
+
+		logger.Info("invitation created", map[string]any{
+			"invitation_id": inv.ID.String(),
+			"admin_id":      adminID.String(),
+			"max_uses":      inv.MaxUses,
+			"expires_at":    inv.ExpiresAt,
+		})
+		return c.Status(fiber.StatusCreated).JSON(CreateResponse{Code: code, Invitation: *inv})
+	}
+}
+
+// ListHandler lists the current tenant's invitations, without their codes
+func ListHandler(store invitation.Store) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		invs, err := store.List(middleware.GetRequestContext(c))
+		if err != nil {
+			logger.Error("failed to list invitations", map[string]any{
+				"error": err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to list invitations", err)
+		}
+		if invs == nil {
+			invs = []invitation.Invitation{}
+		}
+		return c.Status(fiber.StatusOK).JSON(ListResponse{Invitations: invs})
+	}
+}
+
+// RevokeHandler stops the invitation in the path from being redeemed.
+// Accounts already created with it are kept.
+func RevokeHandler(store invitation.Store) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		id, err := uuid.Parse(c.Params("id"))
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, "id must be a UUID")
+		}
+
+		if err := store.Revoke(middleware.GetRequestContext(c), id); err != nil {
+			if errors.Is(err, invitation.ErrNotFound) {
+				return middleware.NotFoundResponse(c, err.Error())
+			}
+			logger.Error("failed to revoke invitation", map[string]any{
+				"invitation_id": id.String(),
+				"error":         err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to revoke invitation", err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
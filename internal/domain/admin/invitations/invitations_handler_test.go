@@ -0,0 +1,77 @@
+package invitations
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/invitation"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvitationHandlers(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	store := invitation.NewMemoryStore().WithClock(clock)
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	adminID := uuid.New()
+
+	app := fiber.New()
+	app.Use(func(c fiber.Ctx) error {
+		c.Locals(middleware.ContextKeyRequestContext, ctx)
+		c.Locals(middleware.ContextKeyUserID, adminID)
+		return c.Next()
+	})
+	app.Post("/invitations", CreateHandler(store, clock))
+	app.Get("/invitations", ListHandler(store))
+	app.Delete("/invitations/:id", RevokeHandler(store))
+
+	send := func(method, path, body string) (int, map[string]any) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		out := map[string]any{}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	status, _ := send(http.MethodPost, "/invitations", `{"max_uses":-1}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = send(http.MethodPost, "/invitations", `{"expires_at":"2027-06-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusBadRequest, status, "more than MaxValidity away")
+
+	status, created := send(http.MethodPost, "/invitations", `{"max_uses":3}`)
+	require.Equal(t, http.StatusCreated, status, created)
+	code, _ := created["code"].(string)
+	require.NotEmpty(t, code)
+	assert.EqualValues(t, 3, created["max_uses"])
+	assert.Equal(t, now.Add(DefaultValidity).Format(time.RFC3339), created["expires_at"])
+	assert.Equal(t, adminID.String(), created["created_by"])
+
+	_, err := store.Redeem(ctx, invitation.HashCode(code), now, func() error { return nil })
+	require.NoError(t, err, "the returned code redeems the invitation")
+
+	status, list := send(http.MethodGet, "/invitations", "")
+	require.Equal(t, http.StatusOK, status)
+	invs, _ := list["invitations"].([]any)
+	require.Len(t, invs, 1)
+	listed, _ := invs[0].(map[string]any)
+	assert.EqualValues(t, 1, listed["used_count"])
+	assert.NotContains(t, listed, "code", "codes are only shown when created")
+
+	id, _ := created["id"].(string)
+	status, _ = send(http.MethodDelete, "/invitations/"+id, "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = send(http.MethodDelete, "/invitations/"+id, "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -0,0 +1,25 @@
+package purge
+
+import (
+	"errors"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// PurgeHandler triggers a purge run on demand
+func PurgeHandler(svc *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		result, err := svc.Purge(middleware.GetRequestContext(c))
+		if err != nil {
+			if errors.Is(err, ErrPurgeRunning) {
+				return middleware.ConflictResponse(c, "a purge is already running")
+			}
+			logger.Error("manual purge failed", map[string]any{"error": err.Error()})
+			return middleware.InternalErrorResponse(c, "failed to purge deleted users", err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(result)
+	}
+}
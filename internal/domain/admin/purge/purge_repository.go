@@ -0,0 +1,119 @@
+package purge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/identity"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/crypto"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Candidate is a soft-deleted user eligible for purging
+type Candidate struct {
+	ID        uuid.UUID `db:"id"`
+	TenantID  uuid.UUID `db:"tenant_id"`
+	DeletedAt time.Time `db:"deleted_at"`
+}
+
+// Placeholders are the anonymized values written over a user's PII
+type Placeholders struct {
+	Email    string
+	FullName string
+	Username string
+}
+
+// Repository is the persistence contract for the purge service
+type Repository interface {
+	// ListCandidates returns up to limit users deleted before cutoff that
+	// have not been purged yet, oldest first.
+	ListCandidates(ctx context.Context, cutoff time.Time, limit int) ([]Candidate, error)
+	// PurgeUser anonymizes the user, removes dependent credentials, pending
+	// email changes and their past usernames, and writes an audit event in
+	// a single transaction.
+	PurgeUser(ctx context.Context, c Candidate, p Placeholders, now time.Time) error
+}
+
+// PgRepository implements Repository against Postgres
+type PgRepository struct {
+	db *database.DBPool
+}
+
+// NewPgRepository creates a new purge repository
+func NewPgRepository(db *database.DBPool) *PgRepository {
+	return &PgRepository{db: db}
+}
+
+// ListCandidates finds users eligible for purging across all tenants
+func (repo *PgRepository) ListCandidates(ctx context.Context, cutoff time.Time, limit int) ([]Candidate, error) {
+	query := `
+		SELECT id, tenant_id, deleted_at
+		FROM users
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1 AND purged_at IS NULL
+		ORDER BY deleted_at, id
+		LIMIT $2
+	`
+
+	candidates, err := database.QueryAll[Candidate](ctx, repo.db, query, cutoff.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list purge candidates: %w", err)
+	}
+	return candidates, nil
+}
+
+// PurgeUser anonymizes a single user inside a transaction. The purged_at
+// guard makes a concurrent or repeated purge of the same user a no-op.
+func (repo *PgRepository) PurgeUser(ctx context.Context, c Candidate, p Placeholders, now time.Time) error {
+	query := `
+		UPDATE users
+		SET email = $2, full_name = $3, username = $4, password = '', purged_at = $5, updated_at = $5
+		WHERE id = $1 AND deleted_at IS NOT NULL AND purged_at IS NULL
+	`
+
+	ctx = tenant.WithID(ctx, c.TenantID)
+	return repo.db.WithTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, query, c.ID, p.Email, crypto.EncryptedString(p.FullName), p.Username, now.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to anonymize user: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+
+		// Sessions hold IP addresses and user agents
+		if err := session.NewRepository(tx).DeleteByUser(ctx, c.ID); err != nil {
+			return err
+		}
+		// Linked identities keep the provider account's email
+		if err := identity.NewRepository(tx).DeleteByUser(ctx, c.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1`, c.ID); err != nil {
+			return fmt.Errorf("failed to delete reset tokens: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM magic_link_tokens WHERE user_id = $1`, c.ID); err != nil {
+			return fmt.Errorf("failed to delete magic link tokens: %w", err)
+		}
+		// Pending email changes hold the old and the new address
+		if _, err := tx.Exec(ctx, `DELETE FROM email_change_requests WHERE user_id = $1`, c.ID); err != nil {
+			return fmt.Errorf("failed to delete email change requests: %w", err)
+		}
+		// Old usernames would name the user and stay reserved for them
+		if err := model.NewUserRepository(tx).DeleteUsernameHistory(ctx, c.ID); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.Entry{
+			UserID:   c.ID,
+			Action:   audit.ActionUserPurged,
+			Metadata: map[string]any{"deleted_at": c.DeletedAt.UTC()},
+		})
+	})
+}
@@ -0,0 +1,106 @@
+package purge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/internal/testdb"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) { testdb.Main(m) }
+
+// defaultTenantID is the tenant seeded by the tenants migration
+var defaultTenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+func TestPgRepository_PurgeUserDeletesUsernameHistory(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+	users := model.NewUserRepository(db)
+	policy := model.UsernamePolicy{Reservation: 30 * 24 * time.Hour}
+
+	u, err := users.SaveUser(ctx, &model.User{Email: "alice@example.com", Password: "hash", Username: "alice", IsActive: true})
+	require.NoError(t, err)
+	for _, name := range []string{"alice_2", "alice_3"} {
+		_, err := users.ChangeProfile(ctx, u.ID, model.ProfileUpdate{Username: &name}, policy)
+		require.NoError(t, err)
+	}
+	require.NoError(t, users.SoftDelete(ctx, u.ID))
+
+	count := func() int {
+		var n int
+		require.NoError(t, db.QueryRow(ctx, `SELECT COUNT(*) FROM username_history WHERE user_id = $1`, u.ID).Scan(&n))
+		return n
+	}
+	require.Equal(t, 2, count())
+
+	purgeAll(t, db, ctx)
+	assert.Zero(t, count(), "the old usernames are gone with the rest of the user's PII")
+	a, err := users.Availability(ctx, "bob@example.com", "alice")
+	require.NoError(t, err)
+	assert.False(t, a.UsernameTaken, "and no longer reserved")
+}
+
+func TestPgRepository_PurgeUserDeletesEmailChangeRequests(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+	users := model.NewUserRepository(db)
+
+	u, err := users.SaveUser(ctx, &model.User{Email: "alice@example.com", Password: "hash", Username: "alice", IsActive: true})
+	require.NoError(t, err)
+	_, err = db.Exec(ctx, `
+		INSERT INTO email_change_requests (id, tenant_id, user_id, old_email, new_email, confirm_hash, cancel_hash, expires_at)
+		VALUES ($1, $2, $3, 'alice@example.com', 'alice@new.example', 'confirm', 'cancel', $4)
+	`, uuid.New(), defaultTenantID, u.ID, time.Now().Add(24*time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, users.SoftDelete(ctx, u.ID))
+
+	purgeAll(t, db, ctx)
+	var n int
+	require.NoError(t, db.QueryRow(ctx, `SELECT COUNT(*) FROM email_change_requests WHERE user_id = $1`, u.ID).Scan(&n))
+	assert.Zero(t, n, "the pending change held both addresses")
+}
+
+func TestPgRepository_PurgeUserDeletesMagicLinkTokens(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+	users := model.NewUserRepository(db)
+
+	u, err := users.SaveUser(ctx, &model.User{Email: "alice@example.com", Password: "hash", Username: "alice", IsActive: true})
+	require.NoError(t, err)
+	_, err = db.Exec(ctx, `
+		INSERT INTO magic_link_tokens (id, tenant_id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, 'hash', $4)
+	`, uuid.New(), defaultTenantID, u.ID, time.Now().Add(15*time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, users.SoftDelete(ctx, u.ID))
+
+	purgeAll(t, db, ctx)
+	var n int
+	require.NoError(t, db.QueryRow(ctx, `SELECT COUNT(*) FROM magic_link_tokens WHERE user_id = $1`, u.ID).Scan(&n))
+	assert.Zero(t, n)
+}
+
+// purgeAll purges every soft-deleted user, as the purge job would an hour
+// from now
+func purgeAll(t *testing.T, db *database.DBPool, ctx context.Context) {
+	t.Helper()
+	repo := NewPgRepository(db)
+	now := time.Now().Add(time.Hour)
+	candidates, err := repo.ListCandidates(ctx, now, 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, candidates)
+	for _, c := range candidates {
+		require.NoError(t, repo.PurgeUser(ctx, c, Placeholders{
+			Email:    "purged-" + c.ID.String() + "@invalid",
+			FullName: "Purged User",
+			Username: "purged_" + c.ID.String(),
+		}, now))
+	}
+}
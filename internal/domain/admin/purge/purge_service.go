@@ -0,0 +1,107 @@
+package purge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+// JobName identifies the purge job in the jobs runner
+const JobName = "purge_deleted_users"
+
+// ErrPurgeRunning is returned when a purge is already in progress
+var ErrPurgeRunning = errors.New("purge already running")
+
+// Result summarizes a purge run
+type Result struct {
+	Purged int  `json:"purged"`
+	Failed int  `json:"failed"`
+	Capped bool `json:"capped"`
+}
+
+// Service anonymizes users that were soft-deleted longer than the retention period
+type Service struct {
+	repo      Repository
+	retention time.Duration
+	maxPerRun int
+	now       func() time.Time
+	running   sync.Mutex
+}
+
+// NewService creates a new purge service
+func NewService(repo Repository, retention time.Duration, maxPerRun int) *Service {
+	return &Service{
+		repo:      repo,
+		retention: retention,
+		maxPerRun: maxPerRun,
+		now:       clock.Now,
+	}
+}
+
+// Name implements jobs.Job
+func (s *Service) Name() string { return JobName }
+
+// Run implements jobs.Job
+func (s *Service) Run(ctx context.Context) error {
+	_, err := s.Purge(ctx)
+	return err
+}
+
+// Purge anonymizes up to maxPerRun eligible users, each in its own
+// transaction so one failure doesn't roll back the others.
+func (s *Service) Purge(ctx context.Context) (*Result, error) {
+	if !s.running.TryLock() {
+		return nil, ErrPurgeRunning
+	}
+	defer s.running.Unlock()
+
+	now := s.now().UTC()
+	cutoff := now.Add(-s.retention)
+
+	candidates, err := s.repo.ListCandidates(ctx, cutoff, s.maxPerRun)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Capped: len(candidates) == s.maxPerRun}
+	for _, c := range candidates {
+		if err := s.repo.PurgeUser(ctx, c, placeholdersFor(c), now); err != nil {
+			result.Failed++
+			logger.Error("failed to purge user", map[string]any{
+				"user_id": c.ID.String(),
+				"error":   err.Error(),
+			})
+			continue
+		}
+		result.Purged++
+	}
+
+	if result.Purged > 0 || result.Failed > 0 {
+		logger.Info("purged deleted users", map[string]any{
+			"purged": result.Purged,
+			"failed": result.Failed,
+			"capped": result.Capped,
+			"cutoff": cutoff.Format(time.RFC3339),
+		})
+	}
+
+	return result, nil
+}
+
+// placeholdersFor derives deterministic, non-reversible replacements for a
+// user's PII. They stay unique per user so unique constraints still hold.
+func placeholdersFor(c Candidate) Placeholders {
+	sum := sha256.Sum256([]byte(c.ID.String()))
+	h := hex.EncodeToString(sum[:])[:24]
+	return Placeholders{
+		Email:    "purged-" + h + "@purged.invalid",
+		FullName: "purged-" + h,
+		Username: "purged_" + h,
+	}
+}
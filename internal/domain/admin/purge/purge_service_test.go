@@ -0,0 +1,141 @@
+package purge
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUser struct {
+	Candidate
+	email    string
+	purgedAt *time.Time
+}
+
+// fakeRepository mirrors the selection and guard semantics of PgRepository
+type fakeRepository struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]*fakeUser
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{users: make(map[uuid.UUID]*fakeUser)}
+}
+
+func (r *fakeRepository) add(deletedAt time.Time) uuid.UUID {
+	id := uuid.New()
+	r.users[id] = &fakeUser{
+		Candidate: Candidate{ID: id, TenantID: uuid.New(), DeletedAt: deletedAt},
+		email:     id.String() + "@example.com",
+	}
+	return id
+}
+
+func (r *fakeRepository) ListCandidates(ctx context.Context, cutoff time.Time, limit int) ([]Candidate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []Candidate
+	for _, u := range r.users {
+		if u.purgedAt == nil && u.DeletedAt.Before(cutoff) {
+			out = append(out, u.Candidate)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DeletedAt.Before(out[j].DeletedAt) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (r *fakeRepository) PurgeUser(ctx context.Context, c Candidate, p Placeholders, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u := r.users[c.ID]
+	if u.purgedAt != nil {
+		return nil
+	}
+	u.email = p.Email
+	u.purgedAt = &now
+	return nil
+}
+
+func newTestService(repo Repository, now time.Time, maxPerRun int) *Service {
+	s := NewService(repo, 30*24*time.Hour, maxPerRun)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestPurge_SelectionBoundaries(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	cutoff := now.Add(-30 * 24 * time.Hour)
+
+	repo := newFakeRepository()
+	old := repo.add(cutoff.Add(-time.Second))
+	exact := repo.add(cutoff)
+	recent := repo.add(cutoff.Add(time.Hour))
+
+	result, err := newTestService(repo, now, 100).Purge(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, result.Purged)
+	assert.NotNil(t, repo.users[old].purgedAt, "user deleted before the cutoff should be purged")
+	assert.Nil(t, repo.users[exact].purgedAt, "user deleted exactly at the cutoff is kept")
+	assert.Nil(t, repo.users[recent].purgedAt, "recently deleted user is kept")
+	assert.True(t, strings.HasSuffix(repo.users[old].email, "@purged.invalid"))
+	assert.NotContains(t, repo.users[old].email, old.String(), "placeholder must not contain the raw user id")
+}
+
+func TestPurge_Idempotent(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	repo := newFakeRepository()
+	repo.add(now.Add(-60 * 24 * time.Hour))
+	repo.add(now.Add(-45 * 24 * time.Hour))
+
+	svc := newTestService(repo, now, 100)
+
+	first, err := svc.Purge(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, first.Purged)
+
+	second, err := svc.Purge(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, second.Purged, "second run must not purge anyone again")
+}
+
+func TestPurge_CapPerRun(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	repo := newFakeRepository()
+	for i := 0; i < 5; i++ {
+		repo.add(now.Add(-time.Duration(40+i) * 24 * time.Hour))
+	}
+
+	svc := newTestService(repo, now, 2)
+
+	result, err := svc.Purge(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Purged)
+	assert.True(t, result.Capped)
+
+	for i := 0; i < 2; i++ {
+		_, err := svc.Purge(context.Background())
+		require.NoError(t, err)
+	}
+	for _, u := range repo.users {
+		assert.NotNil(t, u.purgedAt)
+	}
+}
+
+func TestPlaceholdersFor_Deterministic(t *testing.T) {
+	c := Candidate{ID: uuid.New()}
+	assert.Equal(t, placeholdersFor(c), placeholdersFor(c))
+	assert.NotEqual(t, placeholdersFor(c), placeholdersFor(Candidate{ID: uuid.New()}))
+}
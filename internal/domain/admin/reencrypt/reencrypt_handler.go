@@ -0,0 +1,26 @@
+package reencrypt
+
+import (
+	"errors"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// ReencryptHandler triggers a re-encryption run on demand, e.g. right
+// after rotating keys
+func ReencryptHandler(svc *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		result, err := svc.Reencrypt(middleware.GetRequestContext(c))
+		if err != nil {
+			if errors.Is(err, ErrRunning) {
+				return middleware.ConflictResponse(c, "a re-encryption is already running")
+			}
+			logger.Error("manual re-encryption failed", map[string]any{"error": err.Error()})
+			return middleware.InternalErrorResponse(c, "failed to re-encrypt columns", err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(result)
+	}
+}
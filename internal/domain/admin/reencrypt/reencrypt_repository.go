@@ -0,0 +1,82 @@
+package reencrypt
+
+import (
+	"context"
+	"fmt"
+
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+)
+
+// Column is a text column holding crypto.EncryptedString values
+type Column struct {
+	Table string
+	Name  string
+}
+
+// String returns table.column
+func (c Column) String() string {
+	return c.Table + "." + c.Name
+}
+
+// Columns are the columns encrypted at rest. Adding one here, and giving
+// its struct field the crypto.EncryptedString type, is all it takes to
+// encrypt another column.
+var Columns = []Column{
+	{Table: "users", Name: "full_name"},
+}
+
+// Stale is a stored value that is plaintext or under an old key
+type Stale struct {
+	ID    uuid.UUID `db:"id"`
+	Value string    `db:"value"`
+}
+
+// Repository is the persistence contract for the re-encryption service
+type Repository interface {
+	// ListStale returns up to limit rows of every tenant whose value in col
+	// is not encrypted under the primary key
+	ListStale(ctx context.Context, col Column, primary string, limit int) ([]Stale, error)
+	// Replace swaps old for value in the row's col. It reports false when
+	// the row changed in the meantime, leaving the newer value alone.
+	Replace(ctx context.Context, col Column, id uuid.UUID, old, value string) (bool, error)
+}
+
+// PgRepository implements Repository against Postgres
+type PgRepository struct {
+	db database.Querier
+}
+
+// NewPgRepository creates a new re-encryption repository
+func NewPgRepository(db database.Querier) *PgRepository {
+	return &PgRepository{db: db}
+}
+
+// ListStale implements Repository. Table and column names come from
+// Columns, never from input.
+func (repo *PgRepository) ListStale(ctx context.Context, col Column, primary string, limit int) ([]Stale, error) {
+	query := fmt.Sprintf(`
+		SELECT id, %[2]s AS value
+		FROM %[1]s
+		WHERE %[2]s IS NOT NULL AND %[2]s <> '' AND %[2]s NOT LIKE $1
+		ORDER BY id
+		LIMIT $2
+	`, col.Table, col.Name)
+
+	rows, err := database.QueryAll[Stale](ctx, repo.db, query, "enc:"+primary+":%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale %s values: %w", col, err)
+	}
+	return rows, nil
+}
+
+// Replace implements Repository
+func (repo *PgRepository) Replace(ctx context.Context, col Column, id uuid.UUID, old, value string) (bool, error) {
+	query := fmt.Sprintf(`UPDATE %[1]s SET %[2]s = $3 WHERE id = $1 AND %[2]s = $2`, col.Table, col.Name)
+
+	tag, err := repo.db.Exec(ctx, query, id, old, value)
+	if err != nil {
+		return false, fmt.Errorf("failed to re-encrypt %s: %w", col, err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
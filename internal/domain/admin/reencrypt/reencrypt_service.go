@@ -0,0 +1,113 @@
+// Package reencrypt moves encrypted columns onto the primary key: rows
+// written before encryption was turned on, and rows under a key rotated
+// out since, are rewritten in small batches
+package reencrypt
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"dvith.com/go-service-api/pkg/crypto"
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+// JobName identifies the re-encryption job in the jobs runner
+const JobName = "reencrypt_columns"
+
+// ErrRunning is returned when a re-encryption is already in progress
+var ErrRunning = errors.New("re-encryption already running")
+
+// Result summarizes a re-encryption run
+type Result struct {
+	Reencrypted int  `json:"reencrypted"`
+	Failed      int  `json:"failed"`
+	Capped      bool `json:"capped"`
+}
+
+// Service re-encrypts stale values of Columns under the primary key
+type Service struct {
+	repo      Repository
+	ring      *crypto.KeyRing
+	columns   []Column
+	batchSize int
+	running   sync.Mutex
+}
+
+// NewService re-encrypts up to batchSize values per column and run
+func NewService(repo Repository, ring *crypto.KeyRing, batchSize int) *Service {
+	return &Service{
+		repo:      repo,
+		ring:      ring,
+		columns:   Columns,
+		batchSize: batchSize,
+	}
+}
+
+// Name implements jobs.Job
+func (s *Service) Name() string { return JobName }
+
+// Run implements jobs.Job
+func (s *Service) Run(ctx context.Context) error {
+	_, err := s.Reencrypt(ctx)
+	return err
+}
+
+// Reencrypt rewrites one batch of stale values per column. A value that
+// fails to decrypt is logged and skipped; it needs a key the ring no
+// longer holds, or was tampered with.
+func (s *Service) Reencrypt(ctx context.Context) (*Result, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.running.Unlock()
+
+	result := &Result{}
+	for _, col := range s.columns {
+		stale, err := s.repo.ListStale(ctx, col, s.ring.Primary(), s.batchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(stale) == s.batchSize {
+			result.Capped = true
+		}
+
+		for _, row := range stale {
+			if err := s.reencrypt(ctx, col, row); err != nil {
+				result.Failed++
+				logger.Error("failed to re-encrypt value", map[string]any{
+					"column": col.String(),
+					"id":     row.ID.String(),
+					"error":  err.Error(),
+				})
+				continue
+			}
+			result.Reencrypted++
+		}
+	}
+
+	if result.Reencrypted > 0 || result.Failed > 0 {
+		logger.Info("re-encrypted column values", map[string]any{
+			"reencrypted": result.Reencrypted,
+			"failed":      result.Failed,
+			"capped":      result.Capped,
+			"key_id":      s.ring.Primary(),
+		})
+	}
+	return result, nil
+}
+
+func (s *Service) reencrypt(ctx context.Context, col Column, row Stale) error {
+	plaintext, err := s.ring.Decrypt(row.Value)
+	if err != nil {
+		return err
+	}
+	value, err := s.ring.Encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+	// A row changed since it was listed was written under the primary key
+	// already
+	_, err = s.repo.Replace(ctx, col, row.ID, row.Value, value)
+	return err
+}
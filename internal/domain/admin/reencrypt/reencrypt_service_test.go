@@ -0,0 +1,136 @@
+package reencrypt
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"dvith.com/go-service-api/pkg/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepository mirrors the selection and guard semantics of PgRepository
+type fakeRepository struct {
+	mu     sync.Mutex
+	values map[uuid.UUID]string
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{values: make(map[uuid.UUID]string)}
+}
+
+func (r *fakeRepository) add(value string) uuid.UUID {
+	id := uuid.New()
+	r.values[id] = value
+	return id
+}
+
+func (r *fakeRepository) ListStale(ctx context.Context, col Column, primary string, limit int) ([]Stale, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []Stale
+	for id, v := range r.values {
+		if !strings.HasPrefix(v, "enc:"+primary+":") {
+			out = append(out, Stale{ID: id, Value: v})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID.String() < out[j].ID.String() })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (r *fakeRepository) Replace(ctx context.Context, col Column, id uuid.UUID, old, value string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.values[id] != old {
+		return false, nil
+	}
+	r.values[id] = value
+	return true, nil
+}
+
+// newRing builds a key ring whose key bytes are derived from each ID, so
+// rings sharing an ID share its key
+func newRing(t *testing.T, primary string, ids ...string) *crypto.KeyRing {
+	t.Helper()
+	master := bytes.Repeat([]byte{7}, crypto.KeySize)
+	kr, err := crypto.DeriveKeyRing(master, append([]string{primary}, ids...)...)
+	require.NoError(t, err)
+	return kr
+}
+
+func TestReencrypt_MovesValuesToPrimaryKey(t *testing.T) {
+	old := newRing(t, "v1")
+	oldValue, err := old.Encrypt("Old Key")
+	require.NoError(t, err)
+
+	ring := newRing(t, "v2", "v1")
+	current, err := ring.Encrypt("Current Key")
+	require.NoError(t, err)
+
+	repo := newFakeRepository()
+	plainID := repo.add("Plain Text")
+	oldID := repo.add(oldValue)
+	currentID := repo.add(current)
+
+	svc := NewService(repo, ring, 10)
+	result, err := svc.Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Reencrypted: 2}, result)
+
+	for id, want := range map[uuid.UUID]string{plainID: "Plain Text", oldID: "Old Key", currentID: "Current Key"} {
+		stored := repo.values[id]
+		assert.False(t, ring.NeedsRotation(stored), "value is under the primary key")
+		plaintext, err := ring.Decrypt(stored)
+		require.NoError(t, err)
+		assert.Equal(t, want, plaintext)
+	}
+	assert.Equal(t, current, repo.values[currentID], "values under the primary key are left alone")
+
+	result, err = svc.Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &Result{}, result, "a second run has nothing to do")
+}
+
+func TestReencrypt_SkipsUndecryptableValues(t *testing.T) {
+	ring := newRing(t, "v2")
+	foreign, err := newRing(t, "v9").Encrypt("Unknown Key")
+	require.NoError(t, err)
+
+	repo := newFakeRepository()
+	foreignID := repo.add(foreign)
+	repo.add("Plain Text")
+
+	result, err := NewService(repo, ring, 10).Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Reencrypted)
+	assert.Equal(t, 1, result.Failed)
+	assert.Equal(t, foreign, repo.values[foreignID], "undecryptable values are kept")
+}
+
+func TestReencrypt_CapsBatch(t *testing.T) {
+	repo := newFakeRepository()
+	for range 3 {
+		repo.add("Plain Text")
+	}
+	svc := NewService(repo, newRing(t, "v1"), 2)
+
+	result, err := svc.Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Reencrypted)
+	assert.True(t, result.Capped)
+
+	result, err = svc.Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Reencrypted)
+	assert.False(t, result.Capped)
+}
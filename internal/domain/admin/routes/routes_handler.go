@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"sort"
+
+	"dvith.com/go-service-api/internal/routemeta"
+	"github.com/gofiber/fiber/v3"
+)
+
+// RouteInfo is a registered route with its metadata, when described
+type RouteInfo struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Name        string `json:"name,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	RequireAuth bool   `json:"require_auth"`
+}
+
+// RoutesResponse lists every route and the middleware stacks applied
+type RoutesResponse struct {
+	Routes     []RouteInfo       `json:"routes"`
+	Middleware []routemeta.Stack `json:"middleware"`
+}
+
+// RoutesHandler reports every registered route, sorted by path and
+// method, and the order middleware runs in for each group
+func RoutesHandler(registry *routemeta.Registry) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		described := make(map[string]routemeta.ResolvedRoute)
+		for _, r := range registry.Resolve(c.App()) {
+			described[r.Name] = r
+		}
+
+		var out []RouteInfo
+		for _, r := range c.App().GetRoutes(true) {
+			// Fiber registers a HEAD route alongside every GET
+			if r.Method == fiber.MethodHead {
+				continue
+			}
+			info := RouteInfo{Method: r.Method, Path: r.Path, Name: r.Name}
+			if meta, ok := described[r.Name]; ok && meta.Method == r.Method && meta.Path == r.Path {
+				info.Summary = meta.Summary
+				info.RequireAuth = meta.RequireAuth
+			}
+			out = append(out, info)
+		}
+		sort.Slice(out, func(i, j int) bool {
+			if out[i].Path != out[j].Path {
+				return out[i].Path < out[j].Path
+			}
+			return out[i].Method < out[j].Method
+		})
+
+		return c.Status(fiber.StatusOK).JSON(RoutesResponse{
+			Routes:     out,
+			Middleware: registry.Stacks(),
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dvith.com/go-service-api/internal/routemeta"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutesHandler(t *testing.T) {
+	registry := routemeta.NewRegistry()
+	registry.DescribeStack("/api/v1", []string{"recover", "timeout"})
+
+	app := fiber.New()
+	noop := func(c fiber.Ctx) error { return nil }
+	app.Post("/users", noop).Name("users.create")
+	app.Get("/users", noop).Name("users.list")
+	app.Get("/admin/routes", RoutesHandler(registry))
+	registry.Describe(routemeta.Route{Name: "users.list", Summary: "List users", RequireAuth: true})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/admin/routes", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body RoutesResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	require.Len(t, body.Routes, 3, "HEAD routes are not listed")
+	assert.Equal(t, "/admin/routes", body.Routes[0].Path)
+	assert.Equal(t, RouteInfo{Method: http.MethodGet, Path: "/users", Name: "users.list", Summary: "List users", RequireAuth: true}, body.Routes[1])
+	assert.Equal(t, RouteInfo{Method: http.MethodPost, Path: "/users", Name: "users.create"}, body.Routes[2])
+
+	assert.Equal(t, []routemeta.Stack{{Prefix: "/api/v1", Middleware: []string{"recover", "timeout"}}}, body.Middleware)
+}
@@ -0,0 +1,20 @@
+package stats
+
+import (
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// StatsHandler returns the tenant's aggregate stats for dashboards
+func StatsHandler(svc *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		stats, err := svc.Get(middleware.GetRequestContext(c))
+		if err != nil {
+			logger.Error("failed to compute admin stats", map[string]any{"error": err.Error()})
+			return middleware.InternalErrorResponse(c, "failed to compute stats", err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(stats)
+	}
+}
@@ -0,0 +1,233 @@
+// Package stats serves the aggregate numbers operational dashboards poll:
+// users, sessions, signins and refresh token revocations of the tenant.
+package stats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/errs"
+	"dvith.com/go-service-api/internal/security/authmetrics"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// CacheTTL is how long computed stats are served before being recomputed,
+// so dashboards can poll without each poll running the aggregates
+const CacheTTL = 30 * time.Second
+
+// SigninHours is how many hourly signin buckets are returned
+const SigninHours = 24
+
+// FailedLoginReasons are the signin failures counted as failed logins
+var FailedLoginReasons = []string{authmetrics.ReasonBadPassword, authmetrics.ReasonUnknownUser, authmetrics.ReasonLocked}
+
+// Querier runs the aggregate queries; database.DBPool satisfies it
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Stats are the tenant's aggregate numbers. A metric is null when the table
+// it is computed from hasn't been migrated yet.
+type Stats struct {
+	GeneratedAt     time.Time     `json:"generated_at"`
+	UsersTotal      *int64        `json:"users_total"`
+	UsersCreated24h *int64        `json:"users_created_24h"`
+	UsersCreated7d  *int64        `json:"users_created_7d"`
+	ActiveSessions  *int64        `json:"active_sessions"`
+	SigninsPerHour  []HourBucket  `json:"signins_per_hour"`
+	FailedLogins    *FailedLogins `json:"failed_logins"`
+	// RefreshFamiliesRevoked24h counts refresh token families with a
+	// token revoked in the last 24 hours, by sign out or reuse detection
+	RefreshFamiliesRevoked24h *int64 `json:"refresh_families_revoked_24h"`
+}
+
+// HourBucket is the number of signins in the hour starting at Hour
+type HourBucket struct {
+	Hour  time.Time `json:"hour"`
+	Count int64     `json:"count"`
+}
+
+// FailedLogins counts failed signins by reason. They aren't stored, so the
+// counts are this instance's since it started, across all tenants.
+type FailedLogins struct {
+	Since    time.Time        `json:"since"`
+	Total    int64            `json:"total"`
+	ByReason map[string]int64 `json:"by_reason"`
+}
+
+// Service computes and caches Stats
+type Service struct {
+	db            Querier
+	cache         cache.Cache
+	metrics       *authmetrics.Metrics
+	sessionWindow time.Duration
+	started       time.Time
+	now           func() time.Time
+}
+
+// NewService creates a stats service. Sessions used within sessionWindow,
+// the refresh token lifetime, count as active.
+func NewService(db Querier, c cache.Cache, sessionWindow time.Duration) *Service {
+	return &Service{
+		db:            db,
+		cache:         c,
+		metrics:       authmetrics.Default,
+		sessionWindow: sessionWindow,
+		started:       clock.Now(),
+		now:           clock.Now,
+	}
+}
+
+// WithMetrics replaces the metrics failed logins are read from
+func (s *Service) WithMetrics(m *authmetrics.Metrics) *Service {
+	s.metrics = m
+	return s
+}
+
+// WithClock replaces the time source, for tests
+func (s *Service) WithClock(now func() time.Time) *Service {
+	s.now = now
+	return s
+}
+
+// Get returns the current tenant's stats, computed at most CacheTTL ago
+func (s *Service) Get(ctx context.Context) (*Stats, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key := "admin_stats:" + tenantID.String()
+	if v, ok := s.cache.Get(key); ok {
+		return v.(*Stats), nil
+	}
+
+	stats, err := s.compute(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Set(key, stats, CacheTTL)
+	return stats, nil
+}
+
+func (s *Service) compute(ctx context.Context, tenantID uuid.UUID) (*Stats, error) {
+	now := s.now().UTC()
+	stats := &Stats{GeneratedAt: now}
+
+	var total, day, week int64
+	err := s.row(ctx, "users", []any{&total, &day, &week}, `
+		SELECT count(*), count(*) FILTER (WHERE created_at > $2), count(*) FILTER (WHERE created_at > $3)
+		FROM users
+		WHERE tenant_id = $1 AND deleted_at IS NULL
+	`, tenantID, now.Add(-24*time.Hour), now.Add(-7*24*time.Hour))
+	if err == nil {
+		stats.UsersTotal, stats.UsersCreated24h, stats.UsersCreated7d = &total, &day, &week
+	} else if !notMigrated(err) {
+		return nil, err
+	}
+
+	if stats.ActiveSessions, err = s.count(ctx, "sessions", `
+		SELECT count(*) FROM sessions
+		WHERE tenant_id = $1 AND revoked_at IS NULL AND last_used_at > $2
+	`, tenantID, now.Add(-s.sessionWindow)); err != nil {
+		return nil, err
+	}
+
+	if stats.SigninsPerHour, err = s.signinsPerHour(ctx, tenantID, now); err != nil {
+		return nil, err
+	}
+
+	if stats.RefreshFamiliesRevoked24h, err = s.count(ctx, "refresh token revocations", `
+		SELECT count(DISTINCT family_id) FROM refresh_tokens
+		WHERE tenant_id = $1 AND revoked_at > $2
+	`, tenantID, now.Add(-24*time.Hour)); err != nil {
+		return nil, err
+	}
+
+	stats.FailedLogins = s.failedLogins()
+	return stats, nil
+}
+
+// signinsPerHour counts signins in the SigninHours hourly buckets ending
+// with the current, partial hour, oldest first
+func (s *Service) signinsPerHour(ctx context.Context, tenantID uuid.UUID, now time.Time) ([]HourBucket, error) {
+	first := now.Truncate(time.Hour).Add(-(SigninHours - 1) * time.Hour)
+
+	rows, err := s.db.Query(ctx, `
+		SELECT date_trunc('hour', created_at) AS hour, count(*)
+		FROM audit_events
+		WHERE tenant_id = $1 AND action = $2 AND created_at >= $3
+		GROUP BY 1
+	`, tenantID, audit.ActionUserSignedIn, first)
+	if err != nil {
+		if notMigrated(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to count signins: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := make([]HourBucket, SigninHours)
+	for i := range buckets {
+		buckets[i].Hour = first.Add(time.Duration(i) * time.Hour)
+	}
+	for rows.Next() {
+		var hour time.Time
+		var n int64
+		if err := rows.Scan(&hour, &n); err != nil {
+			return nil, fmt.Errorf("failed to count signins: %w", err)
+		}
+		if i := int(hour.Sub(first) / time.Hour); i >= 0 && i < SigninHours {
+			buckets[i].Count += n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		if notMigrated(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to count signins: %w", err)
+	}
+	return buckets, nil
+}
+
+func (s *Service) failedLogins() *FailedLogins {
+	out := &FailedLogins{Since: s.started.UTC(), ByReason: make(map[string]int64, len(FailedLoginReasons))}
+	for _, reason := range FailedLoginReasons {
+		n := int64(s.metrics.SigninCount(reason))
+		out.ByReason[reason] = n
+		out.Total += n
+	}
+	return out
+}
+
+// count runs a single count query, returning nil if its table is missing
+func (s *Service) count(ctx context.Context, what, query string, args ...any) (*int64, error) {
+	var n int64
+	if err := s.row(ctx, what, []any{&n}, query, args...); err != nil {
+		if notMigrated(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (s *Service) row(ctx context.Context, what string, dest []any, query string, args ...any) error {
+	if err := s.db.QueryRow(ctx, query, args...).Scan(dest...); err != nil {
+		return fmt.Errorf("failed to count %s: %w", what, err)
+	}
+	return nil
+}
+
+// notMigrated reports whether err is a query on a table or column that
+// doesn't exist yet
+func notMigrated(err error) bool {
+	return errs.HasSQLState(err, errs.SQLStateUndefinedTable) || errs.HasSQLState(err, errs.SQLStateUndefinedColumn)
+}
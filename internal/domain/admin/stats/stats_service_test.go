@@ -0,0 +1,222 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/errs"
+	"dvith.com/go-service-api/internal/security/authmetrics"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/metrics"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var undefinedTable = &pgconn.PgError{Code: errs.SQLStateUndefinedTable, Message: `relation "refresh_tokens" does not exist`}
+
+// fakeQuerier answers each query by the table it reads from
+type fakeQuerier struct {
+	rows    map[string][]any
+	hours   []hourRow
+	errs    map[string]error
+	queries int
+}
+
+type hourRow struct {
+	hour  time.Time
+	count int64
+}
+
+func (q *fakeQuerier) table(sql string) string {
+	for _, name := range []string{"users", "sessions", "audit_events", "refresh_tokens"} {
+		if strings.Contains(sql, "FROM "+name) {
+			return name
+		}
+	}
+	return ""
+}
+
+func (q *fakeQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	q.queries++
+	table := q.table(sql)
+	return fakeRow{values: q.rows[table], err: q.errs[table]}
+}
+
+func (q *fakeQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	q.queries++
+	if err := q.errs[q.table(sql)]; err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: q.hours, i: -1}, nil
+}
+
+type fakeRow struct {
+	values []any
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i, d := range dest {
+		*d.(*int64) = r.values[i].(int64)
+	}
+	return nil
+}
+
+type fakeRows struct {
+	pgx.Rows
+	rows []hourRow
+	i    int
+}
+
+func (r *fakeRows) Next() bool { r.i++; return r.i < len(r.rows) }
+func (r *fakeRows) Close()     {}
+func (r *fakeRows) Err() error { return nil }
+
+func (r *fakeRows) Scan(dest ...any) error {
+	*dest[0].(*time.Time) = r.rows[r.i].hour
+	*dest[1].(*int64) = r.rows[r.i].count
+	return nil
+}
+
+var statsNow = time.Date(2026, 10, 14, 12, 40, 0, 0, time.UTC)
+
+func newStatsService(q *fakeQuerier) (*Service, *authmetrics.Metrics) {
+	m := authmetrics.New(metrics.NewRegistry())
+	svc := NewService(q, cache.NewMemory(), 168*time.Hour).
+		WithMetrics(m).
+		WithClock(func() time.Time { return statsNow })
+	return svc, m
+}
+
+func tenantContext() context.Context {
+	return tenant.WithID(context.Background(), uuid.New())
+}
+
+func TestGet_AllMetrics(t *testing.T) {
+	q := &fakeQuerier{
+		rows: map[string][]any{
+			"users":          {int64(120), int64(3), int64(17)},
+			"sessions":       {int64(42)},
+			"refresh_tokens": {int64(5)},
+		},
+		hours: []hourRow{
+			{time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC), 4},
+			{time.Date(2026, 10, 13, 13, 0, 0, 0, time.UTC), 2},
+			{time.Date(2026, 10, 14, 3, 0, 0, 0, time.UTC), 7},
+		},
+	}
+	svc, m := newStatsService(q)
+	m.Signin(authmetrics.ReasonBadPassword)
+	m.Signin(authmetrics.ReasonBadPassword)
+	m.Signin(authmetrics.ReasonUnknownUser)
+	m.Signin(authmetrics.ReasonNone)
+
+	stats, err := svc.Get(tenantContext())
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(120), *stats.UsersTotal)
+	assert.Equal(t, int64(3), *stats.UsersCreated24h)
+	assert.Equal(t, int64(17), *stats.UsersCreated7d)
+	assert.Equal(t, int64(42), *stats.ActiveSessions)
+	assert.Equal(t, int64(5), *stats.RefreshFamiliesRevoked24h)
+
+	require.NotNil(t, stats.FailedLogins)
+	assert.Equal(t, int64(3), stats.FailedLogins.Total)
+	assert.Equal(t, int64(2), stats.FailedLogins.ByReason[authmetrics.ReasonBadPassword])
+	assert.Equal(t, int64(1), stats.FailedLogins.ByReason[authmetrics.ReasonUnknownUser])
+}
+
+func TestGet_SigninBuckets(t *testing.T) {
+	q := &fakeQuerier{
+		rows: map[string][]any{"users": {int64(0), int64(0), int64(0)}, "sessions": {int64(0)}, "refresh_tokens": {int64(0)}},
+		hours: []hourRow{
+			{time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC), 4}, // current, partial hour
+			{time.Date(2026, 10, 13, 13, 0, 0, 0, time.UTC), 2}, // oldest bucket
+			{time.Date(2026, 10, 14, 3, 0, 0, 0, time.UTC), 7},
+			{time.Date(2026, 10, 13, 12, 0, 0, 0, time.UTC), 9}, // before the window
+		},
+	}
+	svc, _ := newStatsService(q)
+
+	stats, err := svc.Get(tenantContext())
+	require.NoError(t, err)
+
+	buckets := stats.SigninsPerHour
+	require.Len(t, buckets, SigninHours)
+	assert.Equal(t, time.Date(2026, 10, 13, 13, 0, 0, 0, time.UTC), buckets[0].Hour)
+	assert.Equal(t, time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC), buckets[SigninHours-1].Hour)
+	for i := 1; i < len(buckets); i++ {
+		assert.Equal(t, time.Hour, buckets[i].Hour.Sub(buckets[i-1].Hour))
+	}
+
+	assert.Equal(t, int64(2), buckets[0].Count)
+	assert.Equal(t, int64(7), buckets[14].Count)
+	assert.Equal(t, int64(4), buckets[SigninHours-1].Count)
+	var total int64
+	for _, b := range buckets {
+		total += b.Count
+	}
+	assert.Equal(t, int64(13), total, "hours outside the window are dropped and empty hours are zero")
+}
+
+func TestGet_MissingTablesAreNull(t *testing.T) {
+	q := &fakeQuerier{
+		rows: map[string][]any{"users": {int64(10), int64(1), int64(2)}},
+		errs: map[string]error{
+			"sessions":       undefinedTable,
+			"audit_events":   undefinedTable,
+			"refresh_tokens": &pgconn.PgError{Code: errs.SQLStateUndefinedColumn, Message: `column "tenant_id" does not exist`},
+		},
+	}
+	svc, _ := newStatsService(q)
+
+	stats, err := svc.Get(tenantContext())
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), *stats.UsersTotal)
+	assert.Nil(t, stats.ActiveSessions)
+	assert.Nil(t, stats.SigninsPerHour)
+	assert.Nil(t, stats.RefreshFamiliesRevoked24h)
+	assert.NotNil(t, stats.FailedLogins)
+}
+
+func TestGet_OtherErrorsFail(t *testing.T) {
+	q := &fakeQuerier{
+		rows: map[string][]any{"users": {int64(10), int64(1), int64(2)}},
+		errs: map[string]error{"sessions": errors.New("connection reset")},
+	}
+	svc, _ := newStatsService(q)
+
+	_, err := svc.Get(tenantContext())
+	assert.ErrorContains(t, err, "connection reset")
+}
+
+func TestGet_CachedPerTenant(t *testing.T) {
+	q := &fakeQuerier{rows: map[string][]any{"users": {int64(1), int64(0), int64(0)}, "sessions": {int64(0)}, "refresh_tokens": {int64(0)}}}
+	svc, _ := newStatsService(q)
+	ctx := tenantContext()
+
+	_, err := svc.Get(ctx)
+	require.NoError(t, err)
+	queries := q.queries
+
+	_, err = svc.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, queries, q.queries, "a second poll is served from the cache")
+
+	_, err = svc.Get(tenantContext())
+	require.NoError(t, err)
+	assert.Equal(t, 2*queries, q.queries, "another tenant has its own stats")
+
+	_, err = svc.Get(context.Background())
+	assert.Error(t, err, "a tenant is required")
+}
@@ -0,0 +1,89 @@
+package userimport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// ImportHandler loads users from an NDJSON or CSV upload. The body is
+// read as a stream and the response is an NDJSON report written as the
+// import progresses: one line per rejected row, one per committed batch,
+// and a final summary.
+func ImportHandler(im *Importer) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		format, err := FormatFromContentType(c.Get(fiber.HeaderContentType))
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, err.Error())
+		}
+		mode, err := ParseMode(c.Query("on_duplicate"))
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, err.Error())
+		}
+
+		reader, err := NewReader(requestBody(c), format)
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, err.Error())
+		}
+
+		// The import outlives the handler, which returns before the
+		// response body is written, so it can't use the request context
+		ctx, cancel := context.WithCancel(context.WithoutCancel(middleware.GetRequestContext(c)))
+
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+		return c.SendStreamWriter(func(w *bufio.Writer) {
+			defer cancel()
+			enc := json.NewEncoder(w)
+
+			emit := func(e Event) error {
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+				if e.Error != nil {
+					return nil
+				}
+				return w.Flush()
+			}
+
+			summary, err := im.Import(ctx, reader, mode, emit)
+			if err != nil {
+				logger.Error("user import failed", map[string]any{
+					"error":    err.Error(),
+					"rows":     summary.Rows,
+					"inserted": summary.Inserted,
+				})
+				fatal := "import failed"
+				if errors.Is(err, ErrMalformedUpload) {
+					fatal = err.Error()
+				}
+				_ = enc.Encode(Event{Summary: &summary, Fatal: fatal})
+				_ = w.Flush()
+				return
+			}
+
+			logger.Info("user import finished", map[string]any{
+				"rows":     summary.Rows,
+				"inserted": summary.Inserted,
+				"skipped":  summary.Skipped,
+				"invalid":  summary.Invalid,
+				"aborted":  summary.Aborted,
+			})
+		})
+	}
+}
+
+// requestBody returns the request body as a stream when the server has
+// request streaming enabled, or the buffered body otherwise
+func requestBody(c fiber.Ctx) io.Reader {
+	if stream := c.Request().BodyStream(); stream != nil {
+		return stream
+	}
+	return bytes.NewReader(c.Body())
+}
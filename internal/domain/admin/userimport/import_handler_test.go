@@ -0,0 +1,90 @@
+package userimport
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func importRequest(t *testing.T, app *fiber.App, contentType, query, body string) (*http.Response, []Event) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/import"+query, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, contentType)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	var events []Event
+	if resp.StatusCode == http.StatusOK {
+		s := bufio.NewScanner(resp.Body)
+		for s.Scan() {
+			var e Event
+			require.NoError(t, json.Unmarshal(s.Bytes(), &e), "report line: %s", s.Text())
+			events = append(events, e)
+		}
+	}
+	return resp, events
+}
+
+func TestImportHandler_StreamsReport(t *testing.T) {
+	store := newMemoryStore()
+	app := fiber.New()
+	app.Post("/import", ImportHandler(newTestImporter(store, 2)))
+
+	resp, events := importRequest(t, app, "application/x-ndjson", "", ndjsonUsers(3)+"{}\n")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get(fiber.HeaderContentType))
+
+	require.NotEmpty(t, events)
+	last := events[len(events)-1]
+	require.NotNil(t, last.Summary)
+	assert.Equal(t, Summary{Rows: 4, Inserted: 3, Invalid: 1}, *last.Summary)
+	assert.Empty(t, last.Fatal)
+}
+
+func TestImportHandler_FailMode(t *testing.T) {
+	store := newMemoryStore()
+	store.emails["user1@example.com"] = true
+	app := fiber.New()
+	app.Post("/import", ImportHandler(newTestImporter(store, 10)))
+
+	resp, events := importRequest(t, app, "application/x-ndjson", "?on_duplicate=fail", ndjsonUsers(3))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	last := events[len(events)-1]
+	require.NotNil(t, last.Summary)
+	assert.True(t, last.Summary.Aborted)
+	assert.Equal(t, 0, last.Summary.Inserted)
+}
+
+func TestImportHandler_RejectsRequest(t *testing.T) {
+	app := fiber.New()
+	app.Post("/import", ImportHandler(newTestImporter(newMemoryStore(), 10)))
+
+	resp, _ := importRequest(t, app, "application/json", "", "[]")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = importRequest(t, app, "text/csv", "?on_duplicate=overwrite", "email\n")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = importRequest(t, app, "text/csv", "", "email,role\n")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestImportHandler_FatalLineTooLong(t *testing.T) {
+	app := fiber.New()
+	app.Post("/import", ImportHandler(newTestImporter(newMemoryStore(), 10)))
+
+	body := ndjsonUsers(1) + `{"email":"` + strings.Repeat("x", maxLineBytes) + `"}` + "\n"
+	resp, events := importRequest(t, app, "application/x-ndjson", "", body)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	last := events[len(events)-1]
+	assert.Contains(t, last.Fatal, "line 2 exceeds")
+}
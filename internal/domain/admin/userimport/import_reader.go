@@ -0,0 +1,201 @@
+package userimport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Format is the encoding of an import upload
+type Format string
+
+// Supported upload formats
+const (
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+)
+
+// maxLineBytes bounds a single NDJSON line so one runaway row can't
+// exhaust memory
+const maxLineBytes = 64 * 1024
+
+var (
+	// ErrUnsupportedFormat is returned for content types other than NDJSON and CSV
+	ErrUnsupportedFormat = errors.New("unsupported import format, use application/x-ndjson or text/csv")
+	// ErrMalformedUpload is returned when the upload can't be read any further
+	ErrMalformedUpload = errors.New("malformed upload")
+)
+
+// FormatFromContentType maps a Content-Type header to a Format
+func FormatFromContentType(contentType string) (Format, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrUnsupportedFormat
+	}
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON, nil
+	case "text/csv":
+		return FormatCSV, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+// Row is a single user in an import file. Exactly one of Password and
+// PasswordHash must be set; PasswordHash holds a bcrypt hash from the
+// legacy system and is stored as-is.
+type Row struct {
+	Email         string `json:"email" validate:"required,email,max=255"`
+	Username      string `json:"username" validate:"required,min=3,max=100"`
+	FullName      string `json:"full_name" validate:"required,max=255"`
+	Password      string `json:"password" validate:"omitempty,min=8,max=255"`
+	PasswordHash  string `json:"password_hash" validate:"omitempty,max=255"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// Record is one entry read from an upload: either a row or the reason the
+// entry couldn't be parsed
+type Record struct {
+	Line int
+	Row  Row
+	Err  *RowError
+}
+
+// Reader yields records until io.EOF. Any other error is fatal and ends
+// the import, since the stream can no longer be resynchronized.
+type Reader interface {
+	Next() (Record, error)
+}
+
+// NewReader returns a streaming reader for the given format
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case FormatNDJSON:
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 0, 4096), maxLineBytes)
+		return &ndjsonReader{scanner: s}, nil
+	case FormatCSV:
+		return newCSVReader(r)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonReader) Next() (Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := r.scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+
+		rec := Record{Line: r.line}
+		if err := json.Unmarshal(data, &rec.Row); err != nil {
+			rec.Err = &RowError{Row: r.line, Reason: "malformed JSON: " + jsonReason(err)}
+		}
+		return rec, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return Record{}, fmt.Errorf("%w: line %d exceeds %d bytes", ErrMalformedUpload, r.line+1, maxLineBytes)
+		}
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+// jsonReason drops the package prefix from encoding/json errors
+func jsonReason(err error) string {
+	return strings.TrimPrefix(err.Error(), "json: ")
+}
+
+// csvColumns are the accepted CSV header names
+var csvColumns = map[string]bool{
+	"email": true, "username": true, "full_name": true,
+	"password": true, "password_hash": true, "email_verified": true,
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: a CSV header row is required", ErrMalformedUpload)
+		}
+		return nil, fmt.Errorf("%w: failed to read CSV header: %v", ErrMalformedUpload, err)
+	}
+
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !csvColumns[name] {
+			return nil, fmt.Errorf("%w: unknown CSV column %q", ErrMalformedUpload, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: duplicate CSV column %q", ErrMalformedUpload, name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+	cr.FieldsPerRecord = len(header)
+	cr.ReuseRecord = true
+
+	return &csvReader{r: cr, columns: header}, nil
+}
+
+func (r *csvReader) Next() (Record, error) {
+	fields, err := r.r.Read()
+	if err != nil {
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return Record{Line: perr.Line, Err: &RowError{Row: perr.Line, Reason: perr.Err.Error()}}, nil
+		}
+		return Record{}, err
+	}
+
+	line, _ := r.r.FieldPos(0)
+	rec := Record{Line: line}
+	for i, v := range fields {
+		switch r.columns[i] {
+		case "email":
+			rec.Row.Email = v
+		case "username":
+			rec.Row.Username = v
+		case "full_name":
+			rec.Row.FullName = v
+		case "password":
+			rec.Row.Password = v
+		case "password_hash":
+			rec.Row.PasswordHash = v
+		case "email_verified":
+			if v == "" {
+				continue
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				rec.Err = &RowError{Row: line, Field: "email_verified", Reason: "must be true or false"}
+				return rec, nil
+			}
+			rec.Row.EmailVerified = b
+		}
+	}
+	return rec, nil
+}
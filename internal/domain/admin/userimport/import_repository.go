@@ -0,0 +1,88 @@
+package userimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/crypto"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// PgStore implements Store against Postgres
+type PgStore struct {
+	db *database.DBPool
+}
+
+// NewPgStore creates a new import store
+func NewPgStore(db *database.DBPool) *PgStore {
+	return &PgStore{db: db}
+}
+
+// InsertBatch queues one INSERT per user on a single round trip. ON
+// CONFLICT DO NOTHING turns a taken email or username into a missing
+// RETURNING row instead of aborting the transaction, which also catches
+// duplicates within the same batch. password_changed_at stays NULL, since
+// the legacy system doesn't say when imported passwords were chosen.
+func (s *PgStore) InsertBatch(ctx context.Context, users []NewUser, failOnDuplicate bool) ([]int, error) {
+	query := `
+		INSERT INTO users (id, tenant_id, email, password, full_name, username, is_active, email_verified, verified_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, true, $7, $8, $9, $9)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	`
+
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var skipped []int
+	err = s.db.WithTx(ctx, func(tx pgx.Tx) error {
+		skipped = skipped[:0]
+		now := clock.Now()
+
+		batch := &pgx.Batch{}
+		for _, u := range users {
+			var verifiedAt *time.Time
+			if u.EmailVerified {
+				verifiedAt = &now
+			}
+			batch.Queue(query, uuid.New(), tenantID, u.Email, u.PasswordHash, crypto.EncryptedString(u.FullName), u.Username, u.EmailVerified, verifiedAt, now)
+		}
+
+		results := tx.SendBatch(ctx, batch)
+		for i := range users {
+			var id uuid.UUID
+			err := results.QueryRow().Scan(&id)
+			if errors.Is(err, pgx.ErrNoRows) {
+				if failOnDuplicate {
+					_ = results.Close()
+					return &DuplicateError{Index: i}
+				}
+				skipped = append(skipped, i)
+				continue
+			}
+			if err != nil {
+				_ = results.Close()
+				return fmt.Errorf("failed to insert imported user: %w", err)
+			}
+		}
+		if err := results.Close(); err != nil {
+			return fmt.Errorf("failed to insert imported users: %w", err)
+		}
+
+		return audit.Record(ctx, tx, audit.Entry{
+			Action:   audit.ActionUsersImported,
+			Metadata: map[string]any{"inserted": len(users) - len(skipped), "skipped": len(skipped)},
+		})
+	})
+
+	return skipped, err
+}
@@ -0,0 +1,244 @@
+package userimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"dvith.com/go-service-api/internal/middleware"
+	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
+)
+
+// Mode decides what happens when an imported email or username is taken
+type Mode string
+
+// Duplicate handling modes
+const (
+	// ModeSkip reports the duplicate row and carries on
+	ModeSkip Mode = "skip"
+	// ModeFail rolls back the batch containing the duplicate and stops;
+	// batches committed before it are kept
+	ModeFail Mode = "fail"
+)
+
+// ErrInvalidMode is returned for an unknown on_duplicate value
+var ErrInvalidMode = errors.New("on_duplicate must be skip or fail")
+
+// ParseMode parses the on_duplicate query parameter, defaulting to skip
+func ParseMode(v string) (Mode, error) {
+	switch Mode(v) {
+	case "", ModeSkip:
+		return ModeSkip, nil
+	case ModeFail:
+		return ModeFail, nil
+	default:
+		return "", ErrInvalidMode
+	}
+}
+
+// RowError explains why a row wasn't imported
+type RowError struct {
+	Row    int    `json:"row"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// DuplicateError is returned by Store.InsertBatch in fail mode
+type DuplicateError struct {
+	// Index is the position of the duplicate within the batch
+	Index int
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("duplicate user at batch index %d", e.Index)
+}
+
+// NewUser is a validated row ready to insert
+type NewUser struct {
+	Line          int
+	Email         string
+	Username      string
+	FullName      string
+	PasswordHash  string
+	EmailVerified bool
+}
+
+// Store inserts imported users
+type Store interface {
+	// InsertBatch inserts users in a single transaction and returns the
+	// indexes of users skipped because their email or username is taken.
+	// With failOnDuplicate, the first duplicate rolls the whole batch back
+	// and is returned as a *DuplicateError instead.
+	InsertBatch(ctx context.Context, users []NewUser, failOnDuplicate bool) ([]int, error)
+}
+
+// Summary counts the outcome of an import
+type Summary struct {
+	// Rows is every record read, including invalid ones
+	Rows     int `json:"rows"`
+	Inserted int `json:"inserted"`
+	Skipped  int `json:"skipped"`
+	Invalid  int `json:"invalid"`
+	// RolledBack counts valid rows discarded when a fail-mode duplicate
+	// aborted their batch
+	RolledBack int  `json:"rolled_back,omitempty"`
+	Aborted    bool `json:"aborted,omitempty"`
+}
+
+// Event is one line of the streamed import report
+type Event struct {
+	Error    *RowError `json:"error,omitempty"`
+	Progress *Summary  `json:"progress,omitempty"`
+	Summary  *Summary  `json:"summary,omitempty"`
+	// Fatal is set on the last line when the import stopped early
+	Fatal string `json:"fatal,omitempty"`
+}
+
+// Importer validates rows and inserts them in fixed-size batches
+type Importer struct {
+	store     Store
+	batchSize int
+	hash      func(password string) (string, error)
+}
+
+// NewImporter creates an importer committing batchSize users per transaction
+func NewImporter(store Store, batchSize int) *Importer {
+	return &Importer{
+		store:     store,
+		batchSize: batchSize,
+		hash:      hashpassword.HashPassword,
+	}
+}
+
+// WithHasher hashes the plaintext passwords of rows with h instead of
+// hashpassword.Default
+func (im *Importer) WithHasher(h hashpassword.Hasher) *Importer {
+	im.hash = h.Hash
+	return im
+}
+
+// Import reads every record from r, emitting an error event for each row
+// that isn't imported and a progress event after each committed batch.
+// The returned error is set only when the import couldn't run to the end
+// of the upload for reasons other than a fail-mode duplicate, which is
+// reported through emit and Summary.Aborted. Rows still buffered when the
+// import stops are not inserted.
+func (im *Importer) Import(ctx context.Context, r Reader, mode Mode, emit func(Event) error) (Summary, error) {
+	var summary Summary
+	batch := make([]NewUser, 0, im.batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		defer func() { batch = batch[:0] }()
+
+		skipped, err := im.store.InsertBatch(ctx, batch, mode == ModeFail)
+		var dup *DuplicateError
+		if errors.As(err, &dup) {
+			summary.RolledBack += len(batch)
+			summary.Aborted = true
+			return emit(Event{Error: &RowError{Row: batch[dup.Index].Line, Reason: "email or username already exists"}})
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, i := range skipped {
+			if err := emit(Event{Error: &RowError{Row: batch[i].Line, Reason: "email or username already exists, skipped"}}); err != nil {
+				return err
+			}
+		}
+		summary.Skipped += len(skipped)
+		summary.Inserted += len(batch) - len(skipped)
+
+		progress := summary
+		return emit(Event{Progress: &progress})
+	}
+
+	for !summary.Aborted {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return summary, err
+		}
+		summary.Rows++
+
+		if rec.Err == nil {
+			var user NewUser
+			user, rec.Err = im.prepare(rec)
+			if rec.Err == nil {
+				batch = append(batch, user)
+			}
+		}
+		if rec.Err != nil {
+			summary.Invalid++
+			if err := emit(Event{Error: rec.Err}); err != nil {
+				return summary, err
+			}
+		}
+
+		if len(batch) == im.batchSize {
+			if err := flush(); err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	if !summary.Aborted {
+		if err := flush(); err != nil {
+			return summary, err
+		}
+	}
+
+	final := summary
+	return summary, emit(Event{Summary: &final})
+}
+
+// prepare validates a row and resolves its password hash
+func (im *Importer) prepare(rec Record) (NewUser, *RowError) {
+	row := rec.Row
+	row.Email = strings.ToLower(strings.TrimSpace(row.Email))
+	row.Username = strings.TrimSpace(row.Username)
+	row.FullName = strings.TrimSpace(row.FullName)
+
+	if errs := middleware.ValidateStruct(&row); len(errs) > 0 {
+		return NewUser{}, &RowError{Row: rec.Line, Field: errs[0].Field, Reason: errs[0].Message}
+	}
+
+	user := NewUser{
+		Line:          rec.Line,
+		Email:         row.Email,
+		Username:      row.Username,
+		FullName:      row.FullName,
+		EmailVerified: row.EmailVerified,
+	}
+
+	switch {
+	case row.Password != "" && row.PasswordHash != "":
+		return NewUser{}, &RowError{Row: rec.Line, Field: "password", Reason: "set either password or password_hash, not both"}
+	case row.PasswordHash != "":
+		if !hashpassword.IsBcrypt(row.PasswordHash) {
+			return NewUser{}, &RowError{Row: rec.Line, Field: "password_hash", Reason: "must be a bcrypt hash"}
+		}
+		user.PasswordHash = row.PasswordHash
+	case row.Password != "":
+		hash, err := im.hash(row.Password)
+		if err != nil {
+			return NewUser{}, &RowError{Row: rec.Line, Field: "password", Reason: "could not be hashed"}
+		}
+		user.PasswordHash = hash
+	default:
+		return NewUser{}, &RowError{Row: rec.Line, Field: "password", Reason: "password or password_hash is required"}
+	}
+
+	return user, nil
+}
@@ -0,0 +1,259 @@
+package userimport
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// memoryStore enforces unique emails and usernames and applies each batch
+// atomically, like the Postgres store
+type memoryStore struct {
+	emails    map[string]bool
+	usernames map[string]bool
+	batches   [][]NewUser
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{emails: map[string]bool{}, usernames: map[string]bool{}}
+}
+
+func (s *memoryStore) InsertBatch(ctx context.Context, users []NewUser, failOnDuplicate bool) ([]int, error) {
+	emails := map[string]bool{}
+	usernames := map[string]bool{}
+	var skipped []int
+	for i, u := range users {
+		if s.emails[u.Email] || emails[u.Email] || s.usernames[u.Username] || usernames[u.Username] {
+			if failOnDuplicate {
+				return nil, &DuplicateError{Index: i}
+			}
+			skipped = append(skipped, i)
+			continue
+		}
+		emails[u.Email] = true
+		usernames[u.Username] = true
+	}
+	for e := range emails {
+		s.emails[e] = true
+	}
+	for u := range usernames {
+		s.usernames[u] = true
+	}
+	s.batches = append(s.batches, append([]NewUser(nil), users...))
+	return skipped, nil
+}
+
+var legacyHash = func() string {
+	h, _ := bcrypt.GenerateFromPassword([]byte("legacyPassword1"), bcrypt.MinCost)
+	return string(h)
+}()
+
+func ndjsonUsers(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, `{"email":"user%d@example.com","username":"user_%d","full_name":"User %d","password_hash":%q}`+"\n", i, i, i, legacyHash)
+	}
+	return b.String()
+}
+
+func newTestImporter(store Store, batchSize int) *Importer {
+	im := NewImporter(store, batchSize)
+	im.hash = func(p string) (string, error) { return "hashed:" + p, nil }
+	return im
+}
+
+func runImport(t *testing.T, im *Importer, format Format, body string, mode Mode) (Summary, []Event) {
+	t.Helper()
+	r, err := NewReader(strings.NewReader(body), format)
+	require.NoError(t, err)
+
+	var events []Event
+	summary, err := im.Import(context.Background(), r, mode, func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	require.NoError(t, err)
+	return summary, events
+}
+
+func rowErrors(events []Event) []RowError {
+	var out []RowError
+	for _, e := range events {
+		if e.Error != nil {
+			out = append(out, *e.Error)
+		}
+	}
+	return out
+}
+
+func TestImport_BatchBoundaries(t *testing.T) {
+	for _, tc := range []struct {
+		rows, batchSize int
+		batches         []int
+	}{
+		{rows: 5, batchSize: 2, batches: []int{2, 2, 1}},
+		{rows: 4, batchSize: 2, batches: []int{2, 2}},
+		{rows: 1, batchSize: 10, batches: []int{1}},
+		{rows: 0, batchSize: 3, batches: nil},
+	} {
+		t.Run(fmt.Sprintf("%d rows by %d", tc.rows, tc.batchSize), func(t *testing.T) {
+			store := newMemoryStore()
+			summary, events := runImport(t, newTestImporter(store, tc.batchSize), FormatNDJSON, ndjsonUsers(tc.rows), ModeSkip)
+
+			var sizes []int
+			for _, b := range store.batches {
+				sizes = append(sizes, len(b))
+			}
+			assert.Equal(t, tc.batches, sizes)
+			assert.Equal(t, tc.rows, summary.Inserted)
+			assert.Equal(t, tc.rows, summary.Rows)
+
+			progress := 0
+			for _, e := range events {
+				if e.Progress != nil {
+					progress++
+				}
+			}
+			assert.Equal(t, len(tc.batches), progress, "one progress event per committed batch")
+			require.NotNil(t, events[len(events)-1].Summary)
+		})
+	}
+}
+
+func TestImport_LineNumbersSurviveBatching(t *testing.T) {
+	store := newMemoryStore()
+	body := ndjsonUsers(3) + "\n" + `{"email":"bad","username":"x"}` + "\n" + ndjsonUsers(1)
+	summary, events := runImport(t, newTestImporter(store, 2), FormatNDJSON, body, ModeSkip)
+
+	assert.Equal(t, 5, summary.Rows, "blank lines aren't rows")
+	assert.Equal(t, 1, summary.Invalid)
+	errs := rowErrors(events)
+	require.Len(t, errs, 2)
+	assert.Equal(t, 5, errs[0].Row, "the blank line still counts toward line numbers")
+	assert.Equal(t, "Email", errs[0].Field)
+	assert.Equal(t, 6, errs[1].Row, "duplicate of line 1 reported with its own line")
+	assert.Equal(t, store.batches[0][0].Line, 1)
+	assert.Equal(t, store.batches[1][0].Line, 3)
+}
+
+func TestImport_MalformedRows(t *testing.T) {
+	body := strings.Join([]string{
+		`{"email":"a@example.com","username":"alice","full_name":"Alice","password_hash":"` + legacyHash + `"}`,
+		`{not json`,
+		`{"email":"b@example.com","username":"bob","full_name":"Bob"}`,
+		`{"email":"c@example.com","username":"carol","full_name":"Carol","password_hash":"$2a$10$notreal"}`,
+		`{"email":"d@example.com","username":"dave","full_name":"Dave","password":"plainPassword1","password_hash":"` + legacyHash + `"}`,
+		`{"email":"e@example.com","username":"erin","full_name":"Erin","password":"plainPassword1"}`,
+		`{"email":"f@example.com","username":"fr","full_name":"Frank","password":"plainPassword1"}`,
+	}, "\n")
+
+	store := newMemoryStore()
+	summary, events := runImport(t, newTestImporter(store, 100), FormatNDJSON, body, ModeSkip)
+
+	assert.Equal(t, 7, summary.Rows)
+	assert.Equal(t, 2, summary.Inserted)
+	assert.Equal(t, 5, summary.Invalid)
+
+	errs := rowErrors(events)
+	require.Len(t, errs, 5)
+	assert.Equal(t, 2, errs[0].Row)
+	assert.Contains(t, errs[0].Reason, "malformed JSON")
+	assert.Equal(t, RowError{Row: 3, Field: "password", Reason: "password or password_hash is required"}, errs[1])
+	assert.Equal(t, RowError{Row: 4, Field: "password_hash", Reason: "must be a bcrypt hash"}, errs[2])
+	assert.Equal(t, 5, errs[3].Row)
+	assert.Equal(t, 7, errs[4].Row)
+	assert.Equal(t, "Username", errs[4].Field)
+
+	require.Len(t, store.batches, 1)
+	assert.Equal(t, legacyHash, store.batches[0][0].PasswordHash, "bcrypt hashes are stored as-is")
+	assert.Equal(t, "hashed:plainPassword1", store.batches[0][1].PasswordHash)
+}
+
+func TestImport_CSV(t *testing.T) {
+	body := "email,username,full_name,password_hash,email_verified\n" +
+		"A@Example.com,alice,Alice," + legacyHash + ",true\n" +
+		"b@example.com,bob,Bob\n" +
+		"c@example.com,carol,Carol," + legacyHash + ",perhaps\n" +
+		"\"d@example.com,dave,Dave," + legacyHash + ",false\n"
+
+	store := newMemoryStore()
+	summary, events := runImport(t, newTestImporter(store, 100), FormatCSV, body, ModeSkip)
+
+	assert.Equal(t, 1, summary.Inserted)
+	assert.Equal(t, 3, summary.Invalid)
+	errs := rowErrors(events)
+	require.Len(t, errs, 3)
+	assert.Equal(t, 3, errs[0].Row)
+	assert.Contains(t, errs[0].Reason, "wrong number of fields")
+	assert.Equal(t, RowError{Row: 4, Field: "email_verified", Reason: "must be true or false"}, errs[1])
+	assert.Equal(t, 5, errs[2].Row)
+
+	require.Len(t, store.batches, 1)
+	assert.Equal(t, "a@example.com", store.batches[0][0].Email)
+	assert.True(t, store.batches[0][0].EmailVerified)
+}
+
+func TestNewReader_CSVHeader(t *testing.T) {
+	_, err := NewReader(strings.NewReader("email,password,role\n"), FormatCSV)
+	assert.ErrorIs(t, err, ErrMalformedUpload)
+
+	_, err = NewReader(strings.NewReader(""), FormatCSV)
+	assert.ErrorIs(t, err, ErrMalformedUpload)
+}
+
+func TestImport_DuplicatesSkipped(t *testing.T) {
+	store := newMemoryStore()
+	store.emails["user2@example.com"] = true
+
+	body := ndjsonUsers(4) + ndjsonUsers(1)
+	summary, events := runImport(t, newTestImporter(store, 2), FormatNDJSON, body, ModeSkip)
+
+	assert.Equal(t, 3, summary.Inserted)
+	assert.Equal(t, 2, summary.Skipped)
+	assert.False(t, summary.Aborted)
+
+	errs := rowErrors(events)
+	require.Len(t, errs, 2)
+	assert.Equal(t, 2, errs[0].Row)
+	assert.Equal(t, 5, errs[1].Row)
+	assert.Contains(t, errs[0].Reason, "skipped")
+}
+
+func TestImport_DuplicatesFail(t *testing.T) {
+	store := newMemoryStore()
+	store.usernames["user_4"] = true
+
+	summary, events := runImport(t, newTestImporter(store, 2), FormatNDJSON, ndjsonUsers(6), ModeFail)
+
+	assert.True(t, summary.Aborted)
+	assert.Equal(t, 2, summary.Inserted, "the batch before the duplicate stays committed")
+	assert.Equal(t, 2, summary.RolledBack, "the duplicate's batch is rolled back")
+	assert.Equal(t, 4, summary.Rows, "reading stops at the failed batch")
+	require.Len(t, store.batches, 1)
+
+	errs := rowErrors(events)
+	require.Len(t, errs, 1)
+	assert.Equal(t, 4, errs[0].Row)
+
+	last := events[len(events)-1]
+	require.NotNil(t, last.Summary)
+	assert.True(t, last.Summary.Aborted)
+}
+
+func TestParseMode(t *testing.T) {
+	m, err := ParseMode("")
+	require.NoError(t, err)
+	assert.Equal(t, ModeSkip, m)
+
+	m, err = ParseMode("fail")
+	require.NoError(t, err)
+	assert.Equal(t, ModeFail, m)
+
+	_, err = ParseMode("ignore")
+	assert.ErrorIs(t, err, ErrInvalidMode)
+}
@@ -0,0 +1,116 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"dvith.com/go-service-api/internal/domain/user/dto"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/pagination"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// UserLister loads a page of users for ListUsersHandler
+type UserLister interface {
+	List(ctx context.Context, req pagination.PageRequest, scope model.Scope) ([]*model.User, int64, error)
+	Search(ctx context.Context, q string, req pagination.PageRequest, scope model.Scope) ([]*model.Match, int64, error)
+}
+
+// ParamSearch narrows the listing to users whose email or username
+// contains it, ignoring case
+const ParamSearch = "q"
+
+// ParamIncludeDeleted selects soft-deleted users into the listing: "true"
+// lists them alongside the rest, "only" lists nothing else
+const ParamIncludeDeleted = "include_deleted"
+
+// parseScope reads ParamIncludeDeleted, deleted users being left out by
+// default
+func parseScope(c fiber.Ctx) (model.Scope, error) {
+	switch v := c.Query(ParamIncludeDeleted); v {
+	case "", "false":
+		return model.ActiveOnly, nil
+	case "true":
+		return model.IncludeDeleted, nil
+	case "only":
+		return model.DeletedOnly, nil
+	default:
+		return 0, fmt.Errorf("invalid %s: %q, expected true, false or only", ParamIncludeDeleted, v)
+	}
+}
+
+// ListUsersHandler lists the tenant's users a page at a time, sorted and
+// filtered as described by model.ListOptions. Soft-deleted users are
+// listed only as ParamIncludeDeleted asks. With ParamSearch it lists the
+// matching users instead, as described by model.SearchOptions, most
+// relevant first unless another sort is asked for.
+func ListUsersHandler(lister UserLister) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		q := strings.TrimSpace(c.Query(ParamSearch))
+		if utf8.RuneCountInString(q) > model.MaxSearchLength {
+			return middleware.ValidationErrorResponse(c, model.ErrSearchTooLong.Error())
+		}
+		opts := model.ListOptions
+		if q != "" {
+			opts = model.SearchOptions
+		}
+		req, err := pagination.ParsePageRequest(c, opts)
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, err.Error())
+		}
+		scope, err := parseScope(c)
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, err.Error())
+		}
+
+		ctx := middleware.GetRequestContext(c)
+		var rows []*model.Match
+		var total int64
+		if q != "" {
+			rows, total, err = lister.Search(ctx, q, req, scope)
+		} else {
+			var users []*model.User
+			users, total, err = lister.List(ctx, req, scope)
+			for _, u := range users {
+				rows = append(rows, &model.Match{User: *u})
+			}
+		}
+		if err != nil {
+			logger.Error("failed to list users", map[string]any{"error": err.Error()})
+			return middleware.InternalErrorResponse(c, "failed to list users", err)
+		}
+
+		rows, more := pagination.Trim(req, rows)
+
+		items := make([]dto.UserDTO, 0, len(rows))
+		for _, m := range rows {
+			items = append(items, dto.FromUser(&m.User))
+		}
+
+		var next string
+		if more {
+			last := rows[len(rows)-1]
+			next = req.NextCursor(sortValue(last, req.Sort), last.ID)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(pagination.BuildPageResponse(items, total, next))
+	}
+}
+
+// sortValue returns the column of m that the page is sorted by
+func sortValue(m *model.Match, sort string) any {
+	switch sort {
+	case model.SortRelevance:
+		return m.Rank
+	case "email":
+		return m.Email
+	case "username":
+		return m.Username
+	default:
+		return m.CreatedAt
+	}
+}
@@ -0,0 +1,210 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/pagination"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLister returns a window of users sorted by created_at descending
+type fakeLister struct {
+	users []*model.User
+	got   pagination.PageRequest
+	scope model.Scope
+	q     string
+}
+
+func (f *fakeLister) List(ctx context.Context, req pagination.PageRequest, scope model.Scope) ([]*model.User, int64, error) {
+	f.got = req
+	f.scope = scope
+	start := 0
+	if req.After != nil {
+		for i, u := range f.users {
+			if u.ID == req.After.Key {
+				start = i + 1
+			}
+		}
+	}
+	end := min(start+req.Limit+1, len(f.users))
+	return f.users[start:end], int64(len(f.users)), nil
+}
+
+// Search returns the users whose email contains q, ranked in list order
+// by thirds so cursors carry rank values that aren't round numbers
+func (f *fakeLister) Search(ctx context.Context, q string, req pagination.PageRequest, scope model.Scope) ([]*model.Match, int64, error) {
+	f.got = req
+	f.scope = scope
+	f.q = q
+	var matches []*model.Match
+	for _, u := range f.users {
+		if strings.Contains(u.Email, q) {
+			matches = append(matches, &model.Match{User: *u, Rank: float64(len(f.users)-len(matches)) / 3})
+		}
+	}
+	start := 0
+	if req.After != nil {
+		for i, m := range matches {
+			if m.ID == req.After.Key {
+				if req.After.Value != m.Rank {
+					return nil, 0, fmt.Errorf("cursor rank %v, want %v", req.After.Value, m.Rank)
+				}
+				start = i + 1
+			}
+		}
+	}
+	end := min(start+req.Limit+1, len(matches))
+	return matches[start:end], int64(len(matches)), nil
+}
+
+func newLister(n int) *fakeLister {
+	f := &fakeLister{}
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		f.users = append(f.users, &model.User{
+			ID:        uuid.New(),
+			Email:     uuid.NewString() + "@example.com",
+			CreatedAt: now.Add(-time.Duration(i) * time.Minute),
+		})
+	}
+	return f
+}
+
+type pageBody struct {
+	Items      []map[string]any `json:"items"`
+	Total      int64            `json:"total"`
+	NextCursor string           `json:"next_cursor"`
+}
+
+func list(t *testing.T, lister UserLister, query string) (int, pageBody) {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/users", ListUsersHandler(lister))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/users?"+query, nil))
+	require.NoError(t, err)
+
+	var body pageBody
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestListUsersHandler_Pages(t *testing.T) {
+	lister := newLister(5)
+
+	status, page := list(t, lister, "limit=2")
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, int64(5), page.Total)
+	require.NotEmpty(t, page.NextCursor)
+
+	seen := len(page.Items)
+	for page.NextCursor != "" {
+		status, page = list(t, lister, "limit=2&cursor="+page.NextCursor)
+		require.Equal(t, http.StatusOK, status)
+		seen += len(page.Items)
+	}
+	assert.Equal(t, 5, seen)
+	assert.NotNil(t, page.Items)
+}
+
+func TestListUsersHandler_NoPasswordInItems(t *testing.T) {
+	lister := newLister(1)
+	lister.users[0].Password = "hash"
+
+	_, page := list(t, lister, "")
+	require.Len(t, page.Items, 1)
+	assert.NotContains(t, page.Items[0], "password")
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestListUsersHandler_RejectsBadParams(t *testing.T) {
+	lister := newLister(1)
+
+	status, _ := list(t, lister, "sort=password")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = list(t, lister, "cursor=garbage")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = list(t, lister, "include_deleted=yes")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestListUsersHandler_IncludeDeleted(t *testing.T) {
+	tests := []struct {
+		query string
+		want  model.Scope
+	}{
+		{query: "", want: model.ActiveOnly},
+		{query: "include_deleted=false", want: model.ActiveOnly},
+		{query: "include_deleted=true", want: model.IncludeDeleted},
+		{query: "include_deleted=only", want: model.DeletedOnly},
+	}
+	for _, tt := range tests {
+		lister := newLister(1)
+		status, _ := list(t, lister, tt.query)
+		require.Equal(t, http.StatusOK, status)
+		assert.Equal(t, tt.want, lister.scope, tt.query)
+	}
+}
+
+func TestListUsersHandler_Search(t *testing.T) {
+	lister := newLister(5)
+	lister.users[1].Email = "jo.smith@example.com"
+	lister.users[2].Email = "john@example.com"
+	lister.users[4].Email = "ajo@example.com"
+
+	status, page := list(t, lister, "q=+jo+&limit=2")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "jo", lister.q, "the term is trimmed")
+	assert.Equal(t, model.SortRelevance, lister.got.Sort, "matches are ranked by default")
+	assert.Equal(t, int64(3), page.Total)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, "jo.smith@example.com", page.Items[0]["email"])
+	require.NotEmpty(t, page.NextCursor)
+
+	status, page = list(t, lister, "q=jo&limit=2&cursor="+page.NextCursor)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "ajo@example.com", page.Items[0]["email"])
+	assert.Empty(t, page.NextCursor)
+
+	status, _ = list(t, lister, "q=jo&sort=email&include_deleted=true")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "email", lister.got.Sort)
+	assert.Equal(t, model.IncludeDeleted, lister.scope)
+}
+
+func TestListUsersHandler_SearchCursorNotReusedForListing(t *testing.T) {
+	lister := newLister(3)
+	_, page := list(t, lister, "q=example&limit=1")
+	require.NotEmpty(t, page.NextCursor)
+
+	status, _ := list(t, lister, "limit=1&cursor="+page.NextCursor)
+	assert.Equal(t, http.StatusBadRequest, status, "a relevance cursor can't page the plain listing")
+
+	status, _ = list(t, lister, "sort=relevance")
+	assert.Equal(t, http.StatusBadRequest, status, "relevance needs a search term")
+}
+
+func TestListUsersHandler_SearchTooLong(t *testing.T) {
+	lister := newLister(1)
+
+	status, _ := list(t, lister, "q="+strings.Repeat("é", model.MaxSearchLength))
+	assert.Equal(t, http.StatusOK, status, "the limit is in characters, not bytes")
+
+	status, _ = list(t, lister, "q="+strings.Repeat("a", model.MaxSearchLength+1))
+	assert.Equal(t, http.StatusBadRequest, status)
+}
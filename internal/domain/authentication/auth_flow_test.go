@@ -0,0 +1,239 @@
+package authentication_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/domain/authentication/signin"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/testutil"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthFlow_SignupSigninRefreshProfile(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	resp := testutil.Request(t, a, http.MethodPost, "/api/v1/auth/signin", map[string]any{
+		"email":    user.Email,
+		"password": user.Password,
+	})
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+	refresh := resp.String("refresh_token")
+	require.NotEmpty(t, refresh)
+
+	resp = testutil.Request(t, a, http.MethodPost, "/api/v1/auth/refresh-token", map[string]any{"refresh_token": refresh})
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+	access := resp.String("access_token")
+	require.NotEmpty(t, access)
+
+	resp = testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/profile", nil, access)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+	profile := resp.Object("user")
+	assert.Equal(t, user.ID, profile["id"])
+	assert.Equal(t, user.Email, profile["email"])
+	assert.Equal(t, user.Username, profile["username"])
+}
+
+func TestAuthFlow_SignupResponse(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	body := map[string]any{
+		"email":     "john@example.com",
+		"password":  testutil.Password,
+		"full_name": "John Doe",
+		"username":  "john_doe",
+	}
+
+	resp := testutil.Request(t, a, http.MethodPost, "/api/v1/auth/signup", body)
+	require.Equal(t, http.StatusCreated, resp.Status, string(resp.Raw))
+	assert.Equal(t, a.Deps.Cfg.AbsoluteURL("/api/v1/user/profile", nil), resp.Header.Get(fiber.HeaderLocation))
+	user := resp.Object("user")
+	assert.Contains(t, user, "full_name")
+	assert.NotContains(t, user, "fullName")
+	assert.Equal(t, testutil.Epoch.Format(time.RFC3339), user["created_at"], "stamped by the frozen clock, in UTC")
+
+	resp = testutil.Request(t, a, http.MethodPost, "/api/v1/auth/signup", body)
+	assert.Equal(t, http.StatusBadRequest, resp.Status)
+	assert.Contains(t, string(resp.Raw), `"code":"email_taken"`)
+}
+
+func TestAuthFlow_DisposableDomainsFile(t *testing.T) {
+	t.Parallel()
+	list := filepath.Join(t.TempDir(), "disposable_email_blocklist.conf")
+	require.NoError(t, os.WriteFile(list, []byte("# upstream list\nthrowaway.test\n"), 0o600))
+	signup := func(blockDisposable bool) *testutil.Response {
+		a := testutil.NewTestApp(t, testutil.Options{Configure: func(c *config.Config) {
+			c.SignupBlockDisposable = blockDisposable
+			c.SignupDisposableDomainsFile = list
+		}})
+		return testutil.Request(t, a, http.MethodPost, "/api/v1/auth/signup", map[string]any{
+			"email":     "bot@mx.throwaway.test",
+			"password":  testutil.Password,
+			"full_name": "John Doe",
+			"username":  "john_doe",
+		})
+	}
+
+	resp := signup(true)
+	assert.Equal(t, http.StatusBadRequest, resp.Status)
+	assert.Contains(t, string(resp.Raw), "email_domain_blocked", "the file's domains join the embedded ones")
+
+	resp = signup(false)
+	assert.Equal(t, http.StatusCreated, resp.Status, "and go with them when disposable blocking is off")
+}
+
+func TestAuthFlow_SigninFailures(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	for name, creds := range map[string]map[string]any{
+		"unknown user":   {"email": "nobody@example.com", "password": testutil.Password},
+		"wrong password": {"email": user.Email, "password": "WrongPass123!"},
+	} {
+		resp := testutil.Request(t, a, http.MethodPost, "/api/v1/auth/signin", creds)
+		assert.Equal(t, http.StatusBadRequest, resp.Status, name)
+		assert.Equal(t, signin.ErrInvalidCredentials.Error(), resp.String("error"), name)
+	}
+}
+
+func TestAuthFlow_RefreshAfterAccessTokenExpires(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	a.Clock.Advance(a.Deps.TokenManager.AccessTokenTTL() + time.Second)
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/profile", nil, user.AccessToken)
+	require.Equal(t, http.StatusUnauthorized, resp.Status)
+
+	resp = testutil.Request(t, a, http.MethodPost, "/api/v1/auth/refresh-token", map[string]any{"refresh_token": user.RefreshToken})
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+
+	resp = testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/profile", nil, resp.String("access_token"))
+	assert.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+}
+
+func TestAuthFlow_RotatedRefreshTokenIsRejected(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	resp := testutil.Request(t, a, http.MethodPost, "/api/v1/auth/refresh-token", map[string]any{"refresh_token": user.RefreshToken})
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+
+	a.Clock.Advance(a.Deps.Cfg.RefreshRotationGrace + time.Second)
+	resp = testutil.Request(t, a, http.MethodPost, "/api/v1/auth/refresh-token", map[string]any{"refresh_token": user.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, resp.Status, "a rotated token can't be used after the grace period")
+}
+
+func TestAuthFlow_SigninJSONNaming(t *testing.T) {
+	t.Parallel()
+	snake := regexp.MustCompile(`^[a-z0-9_]+$`)
+	camel := regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+
+	signin := func(t *testing.T, a *testutil.App, header string) map[string]any {
+		t.Helper()
+		user := testutil.SignupUser(t, a)
+		req := testutil.NewRequest(t, http.MethodPost, "/api/v1/auth/signin", map[string]any{
+			"email":    user.Email,
+			"password": user.Password,
+		})
+		if header != "" {
+			req.Header.Set(middleware.HeaderJSONNaming, header)
+		}
+		resp := testutil.Send(t, a, req)
+		require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+		require.NotEmpty(t, resp.Object("user"), "the nested user is rendered")
+		return resp.Body
+	}
+
+	snakeApp := testutil.NewTestApp(t, testutil.Options{})
+	camelApp := testutil.NewTestApp(t, testutil.Options{Configure: func(c *config.Config) { c.JSONNaming = "camel" }})
+
+	tests := []struct {
+		name   string
+		app    *testutil.App
+		header string
+		want   *regexp.Regexp
+		token  string
+	}{
+		{"snake by default", snakeApp, "", snake, "access_token"},
+		{"camel from config", camelApp, "", camel, "accessToken"},
+		{"camel from header", snakeApp, "camel", camel, "accessToken"},
+		{"snake from header", camelApp, "snake", snake, "access_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := signin(t, tt.app, tt.header)
+			assert.NotEmpty(t, body[tt.token])
+			for _, key := range objectKeys(body) {
+				assert.Regexp(t, tt.want, key)
+			}
+		})
+	}
+}
+
+// objectKeys returns every key of v and of the objects nested in it
+func objectKeys(v any) []string {
+	var keys []string
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			keys = append(keys, k)
+			keys = append(keys, objectKeys(val)...)
+		}
+	case []any:
+		for _, val := range t {
+			keys = append(keys, objectKeys(val)...)
+		}
+	}
+	return keys
+}
+
+func TestAuthFlow_DoubleSubmittedSigninIsCoalesced(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	signin := func() *testutil.Response {
+		req := testutil.NewRequest(t, http.MethodPost, "/api/v1/auth/signin", map[string]any{
+			"email":    user.Email,
+			"password": user.Password,
+		})
+		req.Header.Set(middleware.HeaderClientRequestID, "3f1c9a52-double-tap")
+		return testutil.Send(t, a, req)
+	}
+
+	var wg sync.WaitGroup
+	responses := make([]*testutil.Response, 2)
+	for i := range responses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = signin()
+		}()
+	}
+	wg.Wait()
+
+	for _, resp := range responses {
+		require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+	}
+	assert.NotEmpty(t, responses[0].String("access_token"))
+	assert.Equal(t, responses[0].String("access_token"), responses[1].String("access_token"))
+	assert.Equal(t, responses[0].String("refresh_token"), responses[1].String("refresh_token"))
+
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/sessions", nil, user.AccessToken)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+	sessions, _ := resp.Body["sessions"].([]any)
+	assert.Len(t, sessions, 2, "the signup session and one signin session")
+}
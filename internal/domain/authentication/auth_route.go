@@ -1,17 +1,190 @@
 package authentication
 
 import (
+	"net"
+	"slices"
+
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/autorole"
 	"dvith.com/go-service-api/internal/config"
+	emailchange "dvith.com/go-service-api/internal/domain/authentication/email_change"
+	magiclink "dvith.com/go-service-api/internal/domain/authentication/magic_link"
+	"dvith.com/go-service-api/internal/domain/authentication/oauth"
+	passwordreset "dvith.com/go-service-api/internal/domain/authentication/password_reset"
 	refreshtoken "dvith.com/go-service-api/internal/domain/authentication/refresh_token"
 	"dvith.com/go-service-api/internal/domain/authentication/signin"
 	"dvith.com/go-service-api/internal/domain/authentication/signup"
-	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/identity"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/routemeta"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/web"
+	"dvith.com/go-service-api/pkg/emailaddr"
+	"dvith.com/go-service-api/pkg/ratelimit"
 	"github.com/gofiber/fiber/v3"
 )
 
-func Routers(app fiber.Router, db *database.DBPool, cfg config.Config) {
-	// Authentication routes
-	app.Post("/auth/signup", signup.SignupHandler(db, cfg))
-	app.Post("/auth/signin", signin.SigninHandler(db, cfg))
-	app.Post("/auth/refresh-token", refreshtoken.RefreshTokenHandler(db, cfg))
+// Module serves signup, signin, magic link signin, token refresh, password
+// reset, email change confirmation and social login
+type Module struct{}
+
+// Name identifies the module in the startup log
+func (Module) Name() string { return "authentication" }
+
+// Register adds the module's routes to router
+func (Module) Register(router fiber.Router, deps *app.Deps) {
+	// Services are built once at registration, not per request
+	signupService := newSignupService(deps)
+	loginNotifier := signin.NewLoginNotifier(deps.Stores.Sessions, deps.Mailer, deps.GeoIP, deps.Cfg.AbsoluteURL("/api/v1/user/sessions", nil)).WithGate(deps.Lifecycle).WithPreferences(deps.Stores.Preferences)
+	refreshService := refreshtoken.NewRefreshService(deps.Stores.RefreshTokens, deps.TokenManager, deps.Cache, deps.Cfg.RefreshRotationGrace).
+		WithGuard(refreshtoken.NewGuard(deps.Cache, deps.Cfg.RefreshRateLimit, deps.Cfg.RefreshMaxInvalid, deps.Cfg.RefreshRateWindow).WithClock(deps.Clock)).
+		WithEvents(deps.Events).
+		WithClock(deps.Clock)
+	signinService := signin.NewSigninService(deps.Stores.Signins, deps.TokenManager).
+		WithNotifier(loginNotifier).
+		WithEvents(deps.Events).
+		WithRehash(deps.Cfg.PasswordRehashOnSignin).
+		WithSessionLimit(sessionLimit(deps.Cfg), deps.Revocations).
+		WithGrantedRoles(deps.Stores.RoleGrants).
+		WithHasher(deps.Hasher).
+		WithClock(deps.Clock)
+	if deps.Cfg.PasswordBreachCheck {
+		signinService.WithBreachChecker(signin.NewPwnedPasswordsChecker(), deps.Cfg.PasswordBreachTimeout)
+	}
+	resetStore := passwordreset.NewPgRepository(deps.DB)
+	resetService := passwordreset.NewService(resetStore, deps.Stores.Users, deps.Mailer, deps.Cfg.TokenTTLs.Reset, deps.Links, web.ResetLinkPath(deps.Cfg)).
+		WithHasher(deps.Hasher).
+		WithClock(deps.Clock)
+	magicLinkService := magiclink.NewService(deps.Stores.MagicLinks, deps.Stores.Users, deps.Stores.Sessions, deps.TokenManager, deps.Mailer, deps.Links, deps.Cfg.TokenTTLs.MagicLink).
+		WithRateLimit(ratelimit.NewMemoryStore(deps.Cache).WithClock(deps.Clock), deps.Cfg.MagicLinkRateLimit, deps.Cfg.MagicLinkRateWindow).
+		WithSessionLimit(sessionLimit(deps.Cfg), deps.Revocations).
+		WithEvents(deps.Events).
+		WithGrantedRoles(deps.Stores.RoleGrants).
+		WithClock(deps.Clock)
+	changeStore := emailchange.NewPgRepository(deps.DB)
+	changeService := emailchange.NewService(changeStore, deps.Stores.Users, deps.Mailer, deps.Cfg.TokenTTLs.EmailChange, web.EmailChangeLinks(deps.Cfg)).
+		WithRevocations(deps.Revocations).
+		WithHasher(deps.Hasher).
+		WithClock(deps.Clock)
+
+	// Authentication routes are scoped to the tenant resolved for the request
+	auth := router.Group("/auth", middleware.TenantResolver(deps.Tenants))
+
+	// Each Argon2 hash takes 64MB, so the routes hashing passwords share a
+	// cap on requests in flight
+	hashing := middleware.ConcurrencyLimit(deps.Cfg.PasswordHashConcurrency, deps.Cfg.PasswordHashQueueTimeout)
+	auth.Post("/signup", hashing, signup.SignupHandler(signupService, deps.Cfg.AbsoluteURL("/api/v1/user/profile", nil))).Name("auth.signup")
+	// Mobile clients double-post signin; copies sharing an X-Client-Request-ID
+	// get the first one's tokens instead of a second session
+	signinCoalescer := middleware.NewCoalescer(deps.Cfg.SigninDedupeWindow).WithClock(deps.Clock)
+	auth.Post("/signin", signinCoalescer.Middleware(), hashing, signin.SigninHandler(signinService)).Name("auth.signin")
+	auth.Post("/magic-link", middleware.ValidateBody[magiclink.MagicLinkRequest](), magiclink.RequestHandler(magicLinkService)).Name("auth.magic_link")
+	auth.Get("/magic-link/consume", magiclink.ConsumeHandler(magicLinkService)).Name("auth.magic_link.consume")
+	auth.Post("/magic-link/consume", magiclink.ConsumeHandler(magicLinkService)).Name("auth.magic_link.consume.post")
+	auth.Post("/refresh-token", refreshtoken.RefreshTokenHandler(refreshService)).Name("auth.refresh")
+	auth.Get("/token-info", middleware.AuthMiddleware(deps.TokenManager), TokenInfoHandler()).Name("auth.token_info")
+	auth.Post("/password/forgot", middleware.ValidateForm[passwordreset.ForgotPasswordRequest](), passwordreset.ForgotPasswordHandler(resetService)).Name("auth.password.forgot")
+	auth.Get("/password/reset", passwordreset.VerifyLinkHandler(resetService)).Name("auth.password.reset.verify")
+	auth.Post("/password/reset", middleware.ValidateForm[passwordreset.ResetPasswordRequest](), hashing, passwordreset.ResetPasswordHandler(resetService)).Name("auth.password.reset")
+	// Opened from the emails sent when a signed-in user asks to change address
+	auth.Get("/confirm-email-change", emailchange.ConfirmHandler(changeService)).Name("auth.email_change.confirm")
+	auth.Post("/confirm-email-change", middleware.ValidateForm[emailchange.ConfirmEmailChangeRequest](), emailchange.ConfirmHandler(changeService)).Name("auth.email_change.confirm.post")
+	auth.Get("/cancel-email-change", emailchange.CancelHandler(changeService)).Name("auth.email_change.cancel")
+
+	// Social login is only served for providers configured in Config
+	if providers := oauth.ProvidersFromConfig(deps.Cfg); len(providers) > 0 {
+		oauthService := oauth.NewService(model.NewUserRepository(deps.DB), identity.NewRepository(deps.DB), session.NewRepository(deps.DB), deps.TokenManager, deps.Cache, providers...).
+			WithGrantedRoles(deps.Stores.RoleGrants)
+		auth.Get("/oauth/:provider/start", oauth.StartHandler(oauthService)).Name("auth.oauth.start")
+		auth.Get("/oauth/:provider/callback", oauth.CallbackHandler(oauthService)).Name("auth.oauth.callback")
+	}
+
+	// Expired reset tokens are useless but linger until swept
+	deps.Runner.Schedule(passwordreset.NewCleanupJob(resetStore), passwordreset.CleanupInterval)
+	deps.Runner.Schedule(emailchange.NewCleanupJob(changeStore), emailchange.CleanupInterval)
+	deps.Runner.Schedule(magiclink.NewCleanupJob(deps.Stores.MagicLinks), magiclink.CleanupInterval)
+
+	deps.Routes.Describe(routemeta.Route{Name: "auth.signup", Rel: "signup", Summary: "Create a new account"})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.signin", Rel: "signin", Summary: "Sign in with email and password"})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.magic_link", Rel: "magic-link", Summary: "Email a single-use sign-in link"})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.magic_link.consume", Summary: "Sign in with a link from the magic link email"})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.magic_link.consume.post", Summary: "Sign in with a link from the magic link email, forwarded by a client page"})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.refresh", Rel: "refresh-token", Summary: "Exchange a refresh token for a new access token"})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.token_info", Summary: "Show when the caller's access token was issued and expires", RequireAuth: true})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.password.forgot", Rel: "forgot-password", Summary: "Email a password reset link"})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.password.reset.verify", Summary: "Check a signed reset link from the email and return its token"})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.password.reset", Rel: "reset-password", Summary: "Set a new password with a reset token"})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.email_change.confirm", Summary: "Switch to the new email address with the token from the confirmation email"})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.email_change.confirm.post", Summary: "Switch to the new email address with a token posted by the confirm page"})
+	deps.Routes.Describe(routemeta.Route{Name: "auth.email_change.cancel", Summary: "Cancel a pending email change with the token from the notice to the old address"})
+}
+
+// sessionLimit is the cap on each user's sessions set in config
+func sessionLimit(cfg config.Config) session.Limit {
+	return session.Limit{Max: cfg.MaxSessionsPerUser, Policy: session.EvictionPolicy(cfg.SessionEvictionPolicy)}
+}
+
+// newSignupService builds the signup service with the bot defenses enabled
+// in config
+func newSignupService(deps *app.Deps) *signup.SignupService {
+	cfg := deps.Cfg
+	service := signup.NewSignupService(deps.Stores.Signups, deps.TokenManager).
+		WithSessions(deps.Stores.Sessions).
+		WithEvents(deps.Events).
+		WithRateLimiter(signup.NewRateLimiter(deps.Cache, cfg.SignupRateLimit, cfg.SignupRateWindow).WithClock(deps.Clock)).
+		WithEmailPolicy(newEmailPolicy(cfg)).
+		WithNamePolicy(newNamePolicy(cfg)).
+		WithDomainRoles(autorole.NewAssigner(autorole.Rules(cfg.SignupDomainRoles), deps.Stores.DomainRoles, deps.Stores.RoleGrants, cfg.SignupDomainRolesRequireVerified)).
+		WithHasher(deps.Hasher).
+		WithClock(deps.Clock)
+
+	if cfg.SignupRequiresInvite {
+		service.WithInvitations(deps.Stores.InvitedSignups)
+	}
+
+	if cfg.ValidateEmailMX {
+		service.WithMXCheck(emailaddr.NewMXChecker(net.DefaultResolver, emailaddr.DefaultMXTimeout))
+	}
+
+	if cfg.SignupCaptchaProvider != "" {
+		verifier, err := signup.NewCaptchaVerifier(cfg.SignupCaptchaProvider, cfg.SignupCaptchaSecret)
+		if err != nil {
+			// Config.Validate rejects unknown providers before routes are built
+			panic(err)
+		}
+		service.WithCaptcha(verifier)
+	}
+
+	return service
+}
+
+// newEmailPolicy builds the signup email policy. When disposable providers
+// are blocked, the domains of the configured list file are blocked too.
+func newEmailPolicy(cfg config.Config) *signup.EmailPolicy {
+	blocked := []string(cfg.SignupBlockedDomains)
+	if cfg.SignupBlockDisposable {
+		disposable, err := signup.LoadWordList(cfg.SignupDisposableDomainsFile)
+		if err != nil {
+			// Config.Validate checks the file exists before routes are built
+			panic(err)
+		}
+		blocked = append(slices.Clone(blocked), disposable...)
+	}
+	return signup.NewEmailPolicy(cfg.SignupAllowedDomains, blocked, cfg.SignupBlockDisposable)
+}
+
+// newNamePolicy builds the signup name policy, adding the words from the
+// configured list files to the embedded ones
+func newNamePolicy(cfg config.Config) *signup.NamePolicy {
+	reserved, err := signup.LoadWordList(cfg.SignupReservedNamesFile)
+	if err != nil {
+		// Config.Validate checks the files exist before routes are built
+		panic(err)
+	}
+	profane, err := signup.LoadWordList(cfg.SignupProfanityFile)
+	if err != nil {
+		panic(err)
+	}
+	return signup.NewNamePolicy(reserved, profane, cfg.SignupBlockProfanity, signup.NameMatch(cfg.SignupNameMatch))
 }
@@ -0,0 +1,118 @@
+package emailchange
+
+import (
+	"errors"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/requestmeta"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// ChangeEmailRequest asks to move the account to a new address
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,rfc_email" maxbytes:"320"`
+	Password string `json:"password" validate:"required" maxbytes:"1024"`
+}
+
+// RequestChangeHandler emails a confirmation link to the new address of
+// the authenticated user and a cancel link to the current one
+func RequestChangeHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+		req, err := middleware.GetValidatedBody[ChangeEmailRequest](c)
+		if err != nil {
+			return middleware.InternalErrorResponse(c, "validated body missing")
+		}
+
+		meta := requestmeta.FromCtx(c)
+		err = service.RequestChange(middleware.GetRequestContext(c), Request{
+			UserID:    userID,
+			NewEmail:  req.NewEmail,
+			Password:  req.Password,
+			IP:        meta.IP,
+			UserAgent: meta.UserAgent,
+		})
+		if err != nil {
+			if IsClientError(err) {
+				return middleware.ValidationErrorResponse(c, err.Error())
+			}
+
+			logger.Error("failed to request email change", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to request email change", err)
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"message": "A confirmation link has been sent to the new email address",
+		})
+	}
+}
+
+// ConfirmEmailChangeRequest carries the confirmation token in a body, as
+// JSON or a form post from the account pages
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" form:"token" validate:"required"`
+}
+
+// ConfirmHandler swaps in the new address with the token from the
+// confirmation email: the ?token of an opened link, or the body validated
+// as a ConfirmEmailChangeRequest on POST
+func ConfirmHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		token := c.Query("token")
+		if req, err := middleware.GetValidatedBody[ConfirmEmailChangeRequest](c); err == nil {
+			token = req.Token
+		}
+
+		meta := requestmeta.FromCtx(c)
+		confirmed, err := service.Confirm(middleware.GetRequestContext(c), token, meta.IP, meta.UserAgent)
+		if err != nil {
+			if errors.Is(err, ErrEmailTaken) {
+				return middleware.ConflictResponse(c, err.Error())
+			}
+			if IsClientError(err) {
+				return middleware.ValidationErrorResponse(c, err.Error())
+			}
+
+			logger.Error("failed to confirm email change", map[string]any{
+				"path":  c.Path(),
+				"error": err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to confirm email change", err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Email address has been changed, please sign in again",
+			"email":   confirmed.NewEmail,
+		})
+	}
+}
+
+// CancelHandler withdraws a pending change with the token from the notice
+// sent to the old address
+func CancelHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		meta := requestmeta.FromCtx(c)
+		if err := service.Cancel(middleware.GetRequestContext(c), c.Query("token"), meta.IP, meta.UserAgent); err != nil {
+			if IsClientError(err) {
+				return middleware.ValidationErrorResponse(c, err.Error())
+			}
+
+			logger.Error("failed to cancel email change", map[string]any{
+				"path":  c.Path(),
+				"error": err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to cancel email change", err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Email change has been cancelled",
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package emailchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/errs"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Record is a row in the email_change_requests table
+type Record struct {
+	ID          uuid.UUID  `db:"id"`
+	TenantID    uuid.UUID  `db:"tenant_id"`
+	UserID      uuid.UUID  `db:"user_id"`
+	OldEmail    string     `db:"old_email"`
+	NewEmail    string     `db:"new_email"`
+	ConfirmHash string     `db:"confirm_hash"`
+	CancelHash  string     `db:"cancel_hash"`
+	CreatedAt   time.Time  `db:"created_at"`
+	ExpiresAt   time.Time  `db:"expires_at"`
+	ConfirmedAt *time.Time `db:"confirmed_at"`
+	CancelledAt *time.Time `db:"cancelled_at"`
+}
+
+// Confirmed is the outcome of a confirmed change
+type Confirmed struct {
+	Record
+	// RevokedSessions are the sessions that were active until the swap
+	RevokedSessions []uuid.UUID
+}
+
+// Store persists email change requests. Each method that changes a request
+// records event in the same transaction, with the user and the addresses
+// filled in.
+type Store interface {
+	// Create inserts rec, replacing the user's pending request if any
+	Create(ctx context.Context, rec *Record, event audit.Entry) error
+	// Confirm completes the pending, unexpired request with the given ID if
+	// verify accepts its confirm hash: the user's email becomes the new
+	// address, verified, their token version is bumped and their sessions
+	// are revoked. It returns ErrEmailTaken, leaving the request pending,
+	// when another account holds the new address by then.
+	Confirm(ctx context.Context, id uuid.UUID, now time.Time, verify func(confirmHash string) bool, event audit.Entry) (*Confirmed, error)
+	// Cancel withdraws the pending, unexpired request with the given ID if
+	// verify accepts its cancel hash
+	Cancel(ctx context.Context, id uuid.UUID, now time.Time, verify func(cancelHash string) bool, event audit.Entry) (*Record, error)
+	// DeleteExpired removes requests that expired before cutoff
+	DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// PgRepository is the PostgreSQL Store
+type PgRepository struct {
+	db *database.DBPool
+}
+
+// NewPgRepository creates a new email change repository
+func NewPgRepository(db *database.DBPool) *PgRepository {
+	return &PgRepository{db: db}
+}
+
+// recordColumns are the columns every query returning a Record selects
+const recordColumns = `id, tenant_id, user_id, old_email, new_email, confirm_hash, cancel_hash, created_at, expires_at, confirmed_at, cancelled_at`
+
+// Create implements Store
+func (repo *PgRepository) Create(ctx context.Context, rec *Record, event audit.Entry) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+	rec.TenantID = tenantID
+
+	return repo.db.WithTx(ctx, func(tx pgx.Tx) error {
+		replace := `
+			DELETE FROM email_change_requests
+			WHERE user_id = $1 AND confirmed_at IS NULL AND cancelled_at IS NULL
+		`
+		if _, err := tx.Exec(ctx, replace, rec.UserID); err != nil {
+			return fmt.Errorf("failed to replace pending email change: %w", err)
+		}
+
+		insert := `
+			INSERT INTO email_change_requests (id, tenant_id, user_id, old_email, new_email, confirm_hash, cancel_hash, created_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`
+		if _, err := tx.Exec(ctx, insert, rec.ID, rec.TenantID, rec.UserID, rec.OldEmail, rec.NewEmail, rec.ConfirmHash, rec.CancelHash, rec.CreatedAt.UTC(), rec.ExpiresAt.UTC()); err != nil {
+			return fmt.Errorf("failed to create email change: %w", err)
+		}
+
+		return audit.Record(ctx, tx, withRecord(event, rec))
+	})
+}
+
+// Confirm implements Store. The user's row is only updated while it still
+// has the old email, so a change confirmed twice, or overtaken by another
+// change, can't apply.
+func (repo *PgRepository) Confirm(ctx context.Context, id uuid.UUID, now time.Time, verify func(confirmHash string) bool, event audit.Entry) (*Confirmed, error) {
+	var out *Confirmed
+	err := repo.db.WithTx(ctx, func(tx pgx.Tx) error {
+		rec, err := lockPending(ctx, tx, id, now)
+		if err != nil {
+			return err
+		}
+		if !verify(rec.ConfirmHash) {
+			return ErrInvalidToken
+		}
+
+		// Email is unique per tenant across deleted accounts too
+		var taken bool
+		check := `SELECT EXISTS (SELECT 1 FROM users WHERE tenant_id = $1 AND email = $2 AND id <> $3)`
+		if err := tx.QueryRow(ctx, check, rec.TenantID, rec.NewEmail, rec.UserID).Scan(&taken); err != nil {
+			return fmt.Errorf("failed to check new email: %w", err)
+		}
+		if taken {
+			return ErrEmailTaken
+		}
+
+		swap := `
+			UPDATE users
+			SET email = $4, email_verified = true, verified_at = $5, token_version = token_version + 1, updated_at = $5
+			WHERE deleted_at IS NULL AND tenant_id = $1 AND id = $2 AND email = $3
+		`
+		tag, err := tx.Exec(ctx, swap, rec.TenantID, rec.UserID, rec.OldEmail, rec.NewEmail, now.UTC())
+		if err != nil {
+			// A signup for the same address can commit after the check
+			if errs.HasSQLState(err, errs.SQLStateUniqueViolation) {
+				return ErrEmailTaken
+			}
+			return fmt.Errorf("failed to change email: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrInvalidToken
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE email_change_requests SET confirmed_at = $2 WHERE id = $1`, id, now.UTC()); err != nil {
+			return fmt.Errorf("failed to complete email change: %w", err)
+		}
+		confirmedAt := now
+		rec.ConfirmedAt = &confirmedAt
+
+		revoked, err := session.NewRepository(tx).RevokeAll(ctx, rec.UserID)
+		if err != nil {
+			return err
+		}
+		out = &Confirmed{Record: *rec, RevokedSessions: revoked}
+
+		event = withRecord(event, rec)
+		event.Metadata["sessions_revoked"] = len(revoked)
+		return audit.Record(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Cancel implements Store
+func (repo *PgRepository) Cancel(ctx context.Context, id uuid.UUID, now time.Time, verify func(cancelHash string) bool, event audit.Entry) (*Record, error) {
+	var out *Record
+	err := repo.db.WithTx(ctx, func(tx pgx.Tx) error {
+		rec, err := lockPending(ctx, tx, id, now)
+		if err != nil {
+			return err
+		}
+		if !verify(rec.CancelHash) {
+			return ErrInvalidToken
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE email_change_requests SET cancelled_at = $2 WHERE id = $1`, id, now.UTC()); err != nil {
+			return fmt.Errorf("failed to cancel email change: %w", err)
+		}
+		cancelledAt := now
+		rec.CancelledAt = &cancelledAt
+		out = rec
+
+		return audit.Record(ctx, tx, withRecord(event, rec))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteExpired implements Store
+func (repo *PgRepository) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := repo.db.Exec(ctx, `DELETE FROM email_change_requests WHERE expires_at <= $1`, cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired email changes: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// lockPending loads the pending, unexpired request id of the current
+// tenant and locks it for the rest of tx
+func lockPending(ctx context.Context, tx pgx.Tx, id uuid.UUID, now time.Time) (*Record, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + recordColumns + `
+		FROM email_change_requests
+		WHERE id = $1 AND tenant_id = $2 AND confirmed_at IS NULL AND cancelled_at IS NULL AND expires_at > $3
+		FOR UPDATE
+	`
+	rec, err := database.QueryOne[Record](ctx, tx, query, id, tenantID, now.UTC())
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to load email change: %w", err)
+	}
+	return rec, nil
+}
+
+// withRecord fills in the user and addresses of rec on event
+func withRecord(event audit.Entry, rec *Record) audit.Entry {
+	metadata := map[string]any{"old_email": rec.OldEmail, "new_email": rec.NewEmail}
+	maps.Copy(metadata, event.Metadata)
+	event.UserID, event.Metadata = rec.UserID, metadata
+	return event
+}
@@ -0,0 +1,256 @@
+// Package emailchange changes a user's email address. The new address has
+// to confirm the change, and the old one is told about it with a link to
+// cancel; until the confirmation the old email keeps working.
+package emailchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
+	"dvith.com/go-service-api/internal/security/onetime"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/mailer"
+	"dvith.com/go-service-api/pkg/textnorm"
+	"github.com/google/uuid"
+)
+
+const (
+	// ConfirmPath is where the link sent to the new address points
+	ConfirmPath = "/api/v1/auth/confirm-email-change"
+	// CancelPath is where the link sent to the old address points
+	CancelPath = "/api/v1/auth/cancel-email-change"
+	// CleanupJobName identifies the expired request cleanup in the jobs runner
+	CleanupJobName = "email_change_cleanup"
+	// CleanupInterval is how often expired requests are deleted
+	CleanupInterval = time.Hour
+)
+
+// Email change errors caused by the request rather than by the server
+var (
+	ErrInvalidToken  = errors.New("invalid or expired email change link")
+	ErrWrongPassword = errors.New("current password is incorrect")
+	ErrSameEmail     = errors.New("new email is the current email")
+	ErrEmailTaken    = errors.New("email is already registered")
+)
+
+// IsClientError reports whether err from the service should be reported
+// to the client rather than logged as a failure
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrWrongPassword) ||
+		errors.Is(err, ErrSameEmail) || errors.Is(err, ErrEmailTaken) || errors.Is(err, model.ErrUserNotFound)
+}
+
+// UserStore loads the user asking for a change
+type UserStore interface {
+	FindByID(ctx context.Context, userID uuid.UUID, scope model.Scope) (*model.User, error)
+}
+
+// RevokedSessions records revocations on this instance
+type RevokedSessions interface {
+	MarkRevoked(sessionID uuid.UUID)
+}
+
+// Request identifies who asks for a change, for the audit log
+type Request struct {
+	UserID    uuid.UUID
+	NewEmail  string
+	Password  string
+	IP        string
+	UserAgent string
+}
+
+// Service requests, confirms and cancels email changes. Confirm and cancel
+// tokens are "<id>.<secret>" like password reset tokens: the ID selects
+// the row and only a SHA-256 of the secret is stored.
+type Service struct {
+	store   Store
+	users   UserStore
+	mailer  mailer.Mailer
+	ttl     time.Duration
+	link    LinkFunc
+	revoked RevokedSessions
+	hasher  hashpassword.Hasher
+	now     func() time.Time
+}
+
+// LinkFunc builds an absolute link to path carrying query, as
+// config.Config.AbsoluteURL does
+type LinkFunc func(path string, query url.Values) string
+
+// NewService creates an email change service. Pending changes expire after
+// ttl; emailed links to ConfirmPath and CancelPath are built with link.
+func NewService(store Store, users UserStore, m mailer.Mailer, ttl time.Duration, link LinkFunc) *Service {
+	return &Service{
+		store:  store,
+		users:  users,
+		mailer: m,
+		ttl:    ttl,
+		link:   link,
+		hasher: hashpassword.Default,
+		now:    clock.Now,
+	}
+}
+
+// WithHasher replaces hashpassword.Default as the verifier of the current
+// password
+func (s *Service) WithHasher(h hashpassword.Hasher) *Service {
+	s.hasher = h
+	return s
+}
+
+// WithRevocations marks the sessions a confirmed change revokes as revoked
+// on this instance, so their access tokens stop working at once
+func (s *Service) WithRevocations(r RevokedSessions) *Service {
+	s.revoked = r
+	return s
+}
+
+// WithClock replaces the time source, for tests
+func (s *Service) WithClock(now func() time.Time) *Service {
+	s.now = now
+	return s
+}
+
+// RequestChange checks the user's password and emails a confirmation link
+// to the new address and a notice with a cancel link to the current one.
+// A new request replaces a pending one. Whether the new address is free is
+// only checked on confirmation, so requests don't reveal which addresses
+// are registered.
+func (s *Service) RequestChange(ctx context.Context, req Request) error {
+	newEmail := textnorm.Email(req.NewEmail)
+
+	user, err := s.users.FindByID(ctx, req.UserID, model.ActiveOnly)
+	if err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	if !s.hasher.Verify(req.Password, user.Password) {
+		return ErrWrongPassword
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return ErrSameEmail
+	}
+
+	confirmSecret, err := newSecret()
+	if err != nil {
+		return err
+	}
+	cancelSecret, err := newSecret()
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	rec := &Record{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		OldEmail:    user.Email,
+		NewEmail:    newEmail,
+		ConfirmHash: onetime.HashSecret(confirmSecret),
+		CancelHash:  onetime.HashSecret(cancelSecret),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.ttl),
+	}
+	event := audit.Entry{Action: audit.ActionEmailChangeRequested, IP: req.IP, UserAgent: req.UserAgent}
+	if err := s.store.Create(ctx, rec, event); err != nil {
+		return err
+	}
+
+	confirm := mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to use this address for your account. It expires in %s.\n\n%s\n\nIf you didn't ask for this, you can ignore this email.\n",
+			user.FullName, s.ttl, s.tokenLink(ConfirmPath, rec.ID, confirmSecret)),
+	}
+	if err := s.mailer.Send(ctx, confirm); err != nil {
+		return fmt.Errorf("failed to send confirmation email: %w", err)
+	}
+
+	notice := mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to change the email address of your account to %s. This address keeps working until the change is confirmed.\n\nIf it wasn't you, cancel the change and change your password:\n\n%s\n",
+			user.FullName, newEmail, s.tokenLink(CancelPath, rec.ID, cancelSecret)),
+	}
+	if err := s.mailer.Send(ctx, notice); err != nil {
+		// The change can still be cancelled by requesting another one; the
+		// confirmation is already on its way
+		logger.Warn("failed to send email change notice", map[string]any{
+			"user_id": user.ID.String(),
+			"error":   err.Error(),
+		})
+	}
+
+	return nil
+}
+
+// Confirm swaps in the new address with a token from the confirmation
+// email. The user's sessions are revoked, so every device signs in again.
+func (s *Service) Confirm(ctx context.Context, raw, ip, userAgent string) (*Confirmed, error) {
+	id, secret, ok := onetime.ParseToken(raw)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	event := audit.Entry{Action: audit.ActionEmailChanged, IP: ip, UserAgent: userAgent}
+	confirmed, err := s.store.Confirm(ctx, id, s.now(), func(hash string) bool {
+		return onetime.HashMatches(hash, secret)
+	}, event)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.revoked != nil {
+		for _, sessionID := range confirmed.RevokedSessions {
+			s.revoked.MarkRevoked(sessionID)
+		}
+	}
+	logger.Info("email changed", map[string]any{
+		"user_id":          confirmed.UserID.String(),
+		"sessions_revoked": len(confirmed.RevokedSessions),
+	})
+	return confirmed, nil
+}
+
+// Cancel withdraws a pending change with a token from the notice sent to
+// the old address
+func (s *Service) Cancel(ctx context.Context, raw, ip, userAgent string) error {
+	id, secret, ok := onetime.ParseToken(raw)
+	if !ok {
+		return ErrInvalidToken
+	}
+
+	event := audit.Entry{Action: audit.ActionEmailChangeCancelled, IP: ip, UserAgent: userAgent}
+	_, err := s.store.Cancel(ctx, id, s.now(), func(hash string) bool {
+		return onetime.HashMatches(hash, secret)
+	}, event)
+	return err
+}
+
+// tokenLink is the emailed link to path carrying the token of request id
+func (s *Service) tokenLink(path string, id uuid.UUID, secret string) string {
+	return s.link(path, url.Values{"token": {onetime.Token(id, secret)}})
+}
+
+func newSecret() (string, error) {
+	secret, err := onetime.NewSecret()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate email change token: %w", err)
+	}
+	return secret, nil
+}
+
+// NewCleanupJob creates the expired email change cleanup job
+func NewCleanupJob(store Store) *onetime.CleanupJob {
+	return onetime.NewCleanupJob(CleanupJobName, "email change requests", store)
+}
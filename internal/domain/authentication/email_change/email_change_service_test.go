@@ -0,0 +1,363 @@
+package emailchange
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
+	"dvith.com/go-service-api/internal/security/onetime"
+	"dvith.com/go-service-api/pkg/mailer"
+	"dvith.com/go-service-api/pkg/textnorm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const currentPassword = "Curr3nt-Passw0rd!"
+
+// memoryStore is an in-memory Store with the same semantics as the
+// PostgreSQL repository: emails maps each registered address to its user
+type memoryStore struct {
+	mu       sync.Mutex
+	requests map[uuid.UUID]*Record
+	emails   map[string]uuid.UUID
+	versions map[uuid.UUID]int
+	sessions map[uuid.UUID][]uuid.UUID
+	events   []audit.Entry
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		requests: map[uuid.UUID]*Record{},
+		emails:   map[string]uuid.UUID{},
+		versions: map[uuid.UUID]int{},
+		sessions: map[uuid.UUID][]uuid.UUID{},
+	}
+}
+
+func (m *memoryStore) Create(ctx context.Context, rec *Record, event audit.Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, r := range m.requests {
+		if r.UserID == rec.UserID && r.ConfirmedAt == nil && r.CancelledAt == nil {
+			delete(m.requests, id)
+		}
+	}
+	cp := *rec
+	m.requests[rec.ID] = &cp
+	m.events = append(m.events, withRecord(event, rec))
+	return nil
+}
+
+func (m *memoryStore) pending(id uuid.UUID, now time.Time) (*Record, bool) {
+	r, ok := m.requests[id]
+	if !ok || r.ConfirmedAt != nil || r.CancelledAt != nil || !r.ExpiresAt.After(now) {
+		return nil, false
+	}
+	return r, true
+}
+
+func (m *memoryStore) Confirm(ctx context.Context, id uuid.UUID, now time.Time, verify func(string) bool, event audit.Entry) (*Confirmed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.pending(id, now)
+	if !ok || !verify(r.ConfirmHash) {
+		return nil, ErrInvalidToken
+	}
+	if owner, taken := m.emails[r.NewEmail]; taken && owner != r.UserID {
+		return nil, ErrEmailTaken
+	}
+	if m.emails[r.OldEmail] != r.UserID {
+		return nil, ErrInvalidToken
+	}
+
+	delete(m.emails, r.OldEmail)
+	m.emails[r.NewEmail] = r.UserID
+	m.versions[r.UserID]++
+	r.ConfirmedAt = &now
+	revoked := m.sessions[r.UserID]
+	delete(m.sessions, r.UserID)
+
+	event = withRecord(event, r)
+	event.Metadata["sessions_revoked"] = len(revoked)
+	m.events = append(m.events, event)
+	return &Confirmed{Record: *r, RevokedSessions: revoked}, nil
+}
+
+func (m *memoryStore) Cancel(ctx context.Context, id uuid.UUID, now time.Time, verify func(string) bool, event audit.Entry) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.pending(id, now)
+	if !ok || !verify(r.CancelHash) {
+		return nil, ErrInvalidToken
+	}
+	r.CancelledAt = &now
+	m.events = append(m.events, withRecord(event, r))
+	cp := *r
+	return &cp, nil
+}
+
+func (m *memoryStore) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for id, r := range m.requests {
+		if !r.ExpiresAt.After(cutoff) {
+			delete(m.requests, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+type fakeUserStore struct {
+	user *model.User
+}
+
+func (f *fakeUserStore) FindByID(ctx context.Context, userID uuid.UUID, scope model.Scope) (*model.User, error) {
+	if f.user == nil || f.user.ID != userID {
+		return nil, model.ErrUserNotFound
+	}
+	return f.user, nil
+}
+
+type fakeMailer struct {
+	sent []mailer.Message
+}
+
+func (f *fakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+type fakeRevocations struct {
+	revoked []uuid.UUID
+}
+
+func (f *fakeRevocations) MarkRevoked(sessionID uuid.UUID) {
+	f.revoked = append(f.revoked, sessionID)
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+type changeFixture struct {
+	service *Service
+	store   *memoryStore
+	user    *model.User
+	mailer  *fakeMailer
+	revoked *fakeRevocations
+	clock   *fakeClock
+}
+
+func newChangeFixture(t *testing.T) *changeFixture {
+	t.Helper()
+	hash, err := hashpassword.HashPassword(currentPassword)
+	require.NoError(t, err)
+
+	f := &changeFixture{
+		store:   newMemoryStore(),
+		user:    &model.User{ID: uuid.New(), Email: "old@example.com", FullName: "John Doe", Password: hash},
+		mailer:  &fakeMailer{},
+		revoked: &fakeRevocations{},
+		clock:   &fakeClock{now: time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)},
+	}
+	f.store.emails[f.user.Email] = f.user.ID
+	f.service = NewService(f.store, &fakeUserStore{user: f.user}, f.mailer, 24*time.Hour, config.Config{URL: "https://example.com/"}.AbsoluteURL).
+		WithRevocations(f.revoked).
+		WithClock(f.clock.Now)
+	return f
+}
+
+// request asks to change to newEmail and returns the confirm and cancel
+// tokens from the two emails
+func (f *changeFixture) request(t *testing.T, newEmail string) (string, string) {
+	t.Helper()
+	f.mailer.sent = nil
+	require.NoError(t, f.service.RequestChange(context.Background(), Request{UserID: f.user.ID, NewEmail: newEmail, Password: currentPassword}))
+	require.Len(t, f.mailer.sent, 2)
+
+	assert.Equal(t, textnorm.Email(newEmail), f.mailer.sent[0].To)
+	assert.Equal(t, f.user.Email, f.mailer.sent[1].To)
+	return linkToken(t, f.mailer.sent[0].Body, ConfirmPath), linkToken(t, f.mailer.sent[1].Body, CancelPath)
+}
+
+func linkToken(t *testing.T, body, path string) string {
+	t.Helper()
+	i := strings.Index(body, "https://example.com"+path+"?")
+	require.NotEqual(t, -1, i, "email must contain a %s link: %s", path, body)
+	u, err := url.Parse(strings.Fields(body[i:])[0])
+	require.NoError(t, err)
+	return u.Query().Get("token")
+}
+
+func TestRequestChange_KeepsOldEmailUntilConfirmed(t *testing.T) {
+	f := newChangeFixture(t)
+	confirm, cancel := f.request(t, " new@example.com\u200b")
+
+	require.Len(t, f.store.requests, 1)
+	for _, rec := range f.store.requests {
+		assert.Equal(t, "new@example.com", rec.NewEmail)
+		assert.Equal(t, "old@example.com", rec.OldEmail)
+		assert.Equal(t, f.clock.now.Add(24*time.Hour), rec.ExpiresAt)
+		assert.Len(t, rec.ConfirmHash, 64)
+		assert.NotEqual(t, rec.ConfirmHash, rec.CancelHash)
+		assert.NotContains(t, confirm, rec.ConfirmHash)
+	}
+	assert.NotEqual(t, confirm, cancel)
+	assert.Equal(t, f.user.ID, f.store.emails["old@example.com"], "old email stays active")
+	assert.NotContains(t, f.store.emails, "new@example.com")
+
+	require.Len(t, f.store.events, 1)
+	assert.Equal(t, audit.ActionEmailChangeRequested, f.store.events[0].Action)
+	assert.Equal(t, "new@example.com", f.store.events[0].Metadata["new_email"])
+}
+
+func TestRequestChange_Rejected(t *testing.T) {
+	f := newChangeFixture(t)
+
+	err := f.service.RequestChange(context.Background(), Request{UserID: f.user.ID, NewEmail: "new@example.com", Password: "wrong"})
+	assert.ErrorIs(t, err, ErrWrongPassword)
+	assert.True(t, IsClientError(err))
+
+	err = f.service.RequestChange(context.Background(), Request{UserID: f.user.ID, NewEmail: "OLD@example.com", Password: currentPassword})
+	assert.ErrorIs(t, err, ErrSameEmail)
+
+	err = f.service.RequestChange(context.Background(), Request{UserID: uuid.New(), NewEmail: "new@example.com", Password: currentPassword})
+	assert.ErrorIs(t, err, model.ErrUserNotFound)
+
+	assert.Empty(t, f.store.requests)
+	assert.Empty(t, f.mailer.sent)
+}
+
+func TestConfirm_SwapsEmailAndRevokesSessions(t *testing.T) {
+	f := newChangeFixture(t)
+	sessions := []uuid.UUID{uuid.New(), uuid.New()}
+	f.store.sessions[f.user.ID] = sessions
+	confirm, _ := f.request(t, "new@example.com")
+
+	confirmed, err := f.service.Confirm(context.Background(), confirm, "203.0.113.7", "test-agent")
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", confirmed.NewEmail)
+	assert.Equal(t, f.user.ID, f.store.emails["new@example.com"])
+	assert.NotContains(t, f.store.emails, "old@example.com")
+	assert.Equal(t, 1, f.store.versions[f.user.ID])
+	assert.ElementsMatch(t, sessions, f.revoked.revoked)
+
+	last := f.store.events[len(f.store.events)-1]
+	assert.Equal(t, audit.ActionEmailChanged, last.Action)
+	assert.Equal(t, "203.0.113.7", last.IP)
+	assert.Equal(t, 2, last.Metadata["sessions_revoked"])
+
+	// A confirmation link works once
+	_, err = f.service.Confirm(context.Background(), confirm, "", "")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, 1, f.store.versions[f.user.ID])
+}
+
+func TestConfirm_EmailTakenSinceRequest(t *testing.T) {
+	f := newChangeFixture(t)
+	confirm, _ := f.request(t, "new@example.com")
+
+	// Someone signs up with the address before the link is opened
+	f.store.emails["new@example.com"] = uuid.New()
+
+	_, err := f.service.Confirm(context.Background(), confirm, "", "")
+	assert.ErrorIs(t, err, ErrEmailTaken)
+	assert.True(t, IsClientError(err))
+	assert.Equal(t, f.user.ID, f.store.emails["old@example.com"])
+	assert.Zero(t, f.store.versions[f.user.ID])
+	assert.Empty(t, f.revoked.revoked)
+
+	// The change stays pending until the address is free again
+	delete(f.store.emails, "new@example.com")
+	_, err = f.service.Confirm(context.Background(), confirm, "", "")
+	assert.NoError(t, err)
+}
+
+func TestCancel_WithdrawsChange(t *testing.T) {
+	f := newChangeFixture(t)
+	confirm, cancel := f.request(t, "new@example.com")
+
+	// The confirm token can't cancel and vice versa
+	assert.ErrorIs(t, f.service.Cancel(context.Background(), confirm, "", ""), ErrInvalidToken)
+	_, err := f.service.Confirm(context.Background(), cancel, "", "")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	require.NoError(t, f.service.Cancel(context.Background(), cancel, "", ""))
+	_, err = f.service.Confirm(context.Background(), confirm, "", "")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, f.user.ID, f.store.emails["old@example.com"])
+
+	last := f.store.events[len(f.store.events)-1]
+	assert.Equal(t, audit.ActionEmailChangeCancelled, last.Action)
+	assert.Equal(t, f.user.ID, last.UserID)
+}
+
+func TestConfirm_Expired(t *testing.T) {
+	f := newChangeFixture(t)
+	confirm, cancel := f.request(t, "new@example.com")
+
+	f.clock.now = f.clock.now.Add(24 * time.Hour)
+	_, err := f.service.Confirm(context.Background(), confirm, "", "")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.ErrorIs(t, f.service.Cancel(context.Background(), cancel, "", ""), ErrInvalidToken)
+	assert.Equal(t, f.user.ID, f.store.emails["old@example.com"])
+}
+
+func TestRequestChange_ReplacesPending(t *testing.T) {
+	f := newChangeFixture(t)
+	first, _ := f.request(t, "first@example.com")
+	second, _ := f.request(t, "second@example.com")
+
+	assert.Len(t, f.store.requests, 1)
+	_, err := f.service.Confirm(context.Background(), first, "", "")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	confirmed, err := f.service.Confirm(context.Background(), second, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "second@example.com", confirmed.NewEmail)
+}
+
+func TestConfirm_Malformed(t *testing.T) {
+	f := newChangeFixture(t)
+	confirm, _ := f.request(t, "new@example.com")
+	id, _, _ := onetime.ParseToken(confirm)
+
+	for _, raw := range []string{"", "abc", "not-a-uuid.secret", uuid.NewString() + ".", id.String() + ".not-the-secret"} {
+		_, err := f.service.Confirm(context.Background(), raw, "", "")
+		assert.ErrorIs(t, err, ErrInvalidToken, raw)
+	}
+
+	// Wrong guesses don't burn the real link
+	_, err := f.service.Confirm(context.Background(), confirm, "", "")
+	assert.NoError(t, err)
+}
+
+func TestCleanupJob_DeletesExpired(t *testing.T) {
+	f := newChangeFixture(t)
+	f.request(t, "new@example.com")
+
+	job := NewCleanupJob(f.store).WithClock(func() time.Time { return f.clock.now })
+	require.NoError(t, job.Run(context.Background()))
+	assert.Len(t, f.store.requests, 1)
+
+	job.WithClock(func() time.Time { return f.clock.now.Add(25 * time.Hour) })
+	require.NoError(t, job.Run(context.Background()))
+	assert.Empty(t, f.store.requests)
+	assert.Equal(t, CleanupJobName, job.Name())
+}
@@ -0,0 +1,85 @@
+package magiclink
+
+import (
+	"errors"
+	"strconv"
+
+	"dvith.com/go-service-api/internal/domain/user/dto"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/requestmeta"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// MagicLinkRequest asks for a sign-in link
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// RequestHandler emails a sign-in link. It always responds 202 so the
+// response doesn't reveal whether the email is registered; only the rate
+// limits, which count every email alike, answer otherwise.
+func RequestHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		req, err := middleware.GetValidatedBody[MagicLinkRequest](c)
+		if err != nil {
+			return middleware.InternalErrorResponse(c, "validated body missing")
+		}
+
+		err = service.RequestLink(middleware.GetRequestContext(c), req.Email, requestmeta.FromCtx(c).IP)
+		if errors.Is(err, ErrRateLimited) {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(service.limitWindow.Seconds())))
+			return middleware.TooManyRequestsResponse(c, err.Error())
+		}
+		if err != nil {
+			logger.Error("failed to request magic link", map[string]any{
+				"path":  c.Path(),
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"message": "If the email is registered, a sign-in link has been sent",
+		})
+	}
+}
+
+// ConsumeHandler signs in with the link the email points to. It serves GET
+// for the link itself and POST for client pages that open it on a click,
+// keeping mail scanners that prefetch links from spending it.
+func ConsumeHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		meta := requestmeta.FromCtx(c)
+		result, err := service.Consume(middleware.GetRequestContext(c), ConsumeRequest{
+			Link:      c.OriginalURL(),
+			IP:        meta.IP,
+			UserAgent: meta.UserAgent,
+		})
+		if err != nil {
+			if IsClientError(err) {
+				return middleware.ValidationErrorResponse(c, ErrInvalidLink.Error())
+			}
+			if errors.Is(err, ErrSecondFactorRequired) {
+				return middleware.ForbiddenResponse(c, err.Error())
+			}
+			if errors.Is(err, ErrSessionLimit) {
+				return middleware.ConflictResponse(c, err.Error())
+			}
+
+			logger.Error("failed to consume magic link", map[string]any{
+				"path":  c.Path(),
+				"error": err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to login user", err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":       "User logged in successfully",
+			"user":          dto.FromUser(result.User),
+			"access_token":  result.Tokens.AccessToken,
+			"refresh_token": result.Tokens.RefreshToken,
+			"token_type":    result.Tokens.TokenType,
+			"expires_in":    result.Tokens.ExpiresIn,
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package magiclink
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps magic link tokens in memory for development mode and
+// tests, with the same single-use semantics as PgRepository
+type MemoryStore struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]*Record
+}
+
+// NewMemoryStore creates an empty in-memory token store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tokens: map[uuid.UUID]*Record{}}
+}
+
+// Create implements Store
+func (m *MemoryStore) Create(ctx context.Context, rec *Record, maxOutstanding int) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+	rec.TenantID = tenantID
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cp := *rec
+	m.tokens[rec.ID] = &cp
+
+	var unused []*Record
+	for _, r := range m.tokens {
+		if r.UserID == rec.UserID && r.UsedAt == nil {
+			unused = append(unused, r)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool { return unused[i].CreatedAt.After(unused[j].CreatedAt) })
+	for _, r := range unused[min(maxOutstanding, len(unused)):] {
+		delete(m.tokens, r.ID)
+	}
+	return nil
+}
+
+// Consume implements Store
+func (m *MemoryStore) Consume(ctx context.Context, id uuid.UUID, now time.Time, verify func(string) bool) (*Record, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.tokens[id]
+	if !ok || r.TenantID != tenantID || r.UsedAt != nil || !r.ExpiresAt.After(now) || !verify(r.TokenHash) {
+		return nil, ErrInvalidLink
+	}
+	r.UsedAt = &now
+	cp := *r
+	return &cp, nil
+}
+
+// DeleteExpired implements Store
+func (m *MemoryStore) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for id, r := range m.tokens {
+		if !r.ExpiresAt.After(cutoff) {
+			delete(m.tokens, id)
+			n++
+		}
+	}
+	return n, nil
+}
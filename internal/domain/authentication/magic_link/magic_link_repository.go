@@ -0,0 +1,148 @@
+package magiclink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Record is a row in the magic_link_tokens table
+type Record struct {
+	ID        uuid.UUID  `db:"id"`
+	TenantID  uuid.UUID  `db:"tenant_id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
+
+// Store persists magic link tokens
+type Store interface {
+	// Create inserts rec and deletes the user's oldest unused tokens so at
+	// most maxOutstanding remain
+	Create(ctx context.Context, rec *Record, maxOutstanding int) error
+	// Consume marks the unused, unexpired token with the given ID as used if
+	// verify accepts its stored hash. Each token can be consumed once.
+	Consume(ctx context.Context, id uuid.UUID, now time.Time, verify func(tokenHash string) bool) (*Record, error)
+	// DeleteExpired removes tokens that expired before cutoff
+	DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+var (
+	_ Store = (*PgRepository)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// PgRepository is the PostgreSQL Store
+type PgRepository struct {
+	db *database.DBPool
+}
+
+// NewPgRepository creates a new magic link token repository
+func NewPgRepository(db *database.DBPool) *PgRepository {
+	return &PgRepository{db: db}
+}
+
+// Create implements Store. The user's row is locked first so concurrent
+// requests can't push the user past the cap.
+func (repo *PgRepository) Create(ctx context.Context, rec *Record, maxOutstanding int) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+	rec.TenantID = tenantID
+
+	return repo.db.WithTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, rec.UserID); err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+
+		insert := `
+			INSERT INTO magic_link_tokens (id, tenant_id, user_id, token_hash, created_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`
+		if _, err := tx.Exec(ctx, insert, rec.ID, rec.TenantID, rec.UserID, rec.TokenHash, rec.CreatedAt.UTC(), rec.ExpiresAt.UTC()); err != nil {
+			return fmt.Errorf("failed to create magic link token: %w", err)
+		}
+
+		trim := `
+			DELETE FROM magic_link_tokens
+			WHERE user_id = $1 AND used_at IS NULL AND id NOT IN (
+				SELECT id FROM magic_link_tokens
+				WHERE user_id = $1 AND used_at IS NULL
+				ORDER BY created_at DESC, id
+				LIMIT $2
+			)
+		`
+		if _, err := tx.Exec(ctx, trim, rec.UserID, maxOutstanding); err != nil {
+			return fmt.Errorf("failed to invalidate old magic link tokens: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Consume implements Store
+func (repo *PgRepository) Consume(ctx context.Context, id uuid.UUID, now time.Time, verify func(tokenHash string) bool) (*Record, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rec *Record
+	err = repo.db.WithTx(ctx, func(tx pgx.Tx) error {
+		query := `
+			SELECT id, tenant_id, user_id, token_hash, created_at, expires_at, used_at
+			FROM magic_link_tokens
+			WHERE id = $1 AND tenant_id = $2 AND used_at IS NULL AND expires_at > $3
+			FOR UPDATE
+		`
+		var err error
+		if rec, err = database.QueryOne[Record](ctx, tx, query, id, tenantID, now.UTC()); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvalidLink
+			}
+			return fmt.Errorf("failed to load magic link token: %w", err)
+		}
+
+		if !verify(rec.TokenHash) {
+			return ErrInvalidLink
+		}
+
+		// Only the first consumer sees used_at IS NULL
+		update := `
+			UPDATE magic_link_tokens SET used_at = $2
+			WHERE id = $1 AND used_at IS NULL
+			RETURNING used_at
+		`
+		if err := tx.QueryRow(ctx, update, id, now.UTC()).Scan(&rec.UsedAt); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvalidLink
+			}
+			return fmt.Errorf("failed to consume magic link token: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+// DeleteExpired implements Store
+func (repo *PgRepository) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := repo.db.Exec(ctx, `DELETE FROM magic_link_tokens WHERE expires_at <= $1`, cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired magic link tokens: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,358 @@
+// Package magiclink signs users in without a password: they ask for a link
+// by email and opening it creates a session.
+package magiclink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/internal/autorole"
+	"dvith.com/go-service-api/internal/domain/authentication/signin"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/events"
+	"dvith.com/go-service-api/internal/security/onetime"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/mailer"
+	"dvith.com/go-service-api/pkg/ratelimit"
+	"dvith.com/go-service-api/pkg/textnorm"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxOutstanding is how many unused links a user may hold; a new
+	// request invalidates the oldest beyond this
+	MaxOutstanding = 3
+	// CleanupJobName identifies the expired token cleanup in the jobs runner
+	CleanupJobName = "magic_link_cleanup"
+	// CleanupInterval is how often expired tokens are deleted
+	CleanupInterval = time.Hour
+	// LinkPath is where emailed links point; consuming one signs the user in
+	LinkPath = "/api/v1/auth/magic-link/consume"
+)
+
+// Magic link errors caused by the request rather than by the server
+var (
+	ErrInvalidLink = errors.New("invalid or expired sign-in link")
+	// ErrSecondFactorRequired is returned when opening a link of an account
+	// with two-factor authentication, which must sign in with its password
+	ErrSecondFactorRequired = errors.New("this account uses two-factor authentication, sign in with your password")
+	// ErrRateLimited is returned when an email address or IP has asked for
+	// too many links
+	ErrRateLimited = errors.New("too many sign-in links requested, try again later")
+	// ErrSessionLimit is returned when the user is at the session limit and
+	// the policy is session.DenyNew
+	ErrSessionLimit = session.ErrSessionLimit
+)
+
+// IsClientError reports whether err from Consume should be reported to the
+// client as a bad request
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrInvalidLink)
+}
+
+// LinkSigner signs the emailed links and checks them when opened.
+// signedurl.Signer implements it.
+type LinkSigner interface {
+	Sign(path string, params map[string]string, ttl time.Duration) (string, error)
+	Verify(rawURL string) (map[string]string, error)
+}
+
+// UserStore looks users up and marks their email verified
+type UserStore interface {
+	FindByEmail(ctx context.Context, email string, scope model.Scope) (*model.User, error)
+	FindByID(ctx context.Context, userID uuid.UUID, scope model.Scope) (*model.User, error)
+	MarkEmailVerified(ctx context.Context, userID uuid.UUID) error
+}
+
+// SessionStore opens the session of a signin
+type SessionStore interface {
+	CreateLimited(ctx context.Context, s *session.Session, limit session.Limit) ([]uuid.UUID, error)
+}
+
+// SecondFactors reports which users have two-factor authentication on.
+// Magic links would skip the second factor, so those users are refused.
+type SecondFactors interface {
+	Enabled(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+// ConsumeRequest is an opened link and the client opening it
+type ConsumeRequest struct {
+	// Link is the URL, or just the path and query, of the emailed link
+	Link      string
+	IP        string
+	UserAgent string
+}
+
+// LoginResult is the signed-in user and their tokens
+type LoginResult struct {
+	User   *model.User
+	Tokens *token.TokenPair
+}
+
+// Service issues and consumes magic links. The emailed link is signed and
+// carries a token "<id>.<secret>": the signature rejects tampered or
+// expired links before any lookup, and the stored SHA-256 of the secret
+// makes each link single-use.
+type Service struct {
+	store        Store
+	users        UserStore
+	sessions     SessionStore
+	tokenManager *token.TokenManager
+	mailer       mailer.Mailer
+	links        LinkSigner
+	ttl          time.Duration
+	now          func() time.Time
+
+	limits      ratelimit.Store
+	limit       int
+	limitWindow time.Duration
+
+	secondFactors SecondFactors
+	sessionLimit  session.Limit
+	revoked       signin.RevokedSessions
+	events        *events.Bus
+	grants        autorole.GrantStore
+}
+
+// NewService creates the magic link service; links stay valid for ttl
+func NewService(store Store, users UserStore, sessions SessionStore, tokenManager *token.TokenManager, m mailer.Mailer, links LinkSigner, ttl time.Duration) *Service {
+	return &Service{
+		store:        store,
+		users:        users,
+		sessions:     sessions,
+		tokenManager: tokenManager,
+		mailer:       m,
+		links:        links,
+		ttl:          ttl,
+		now:          clock.Now,
+	}
+}
+
+// WithClock replaces the time source, for tests
+func (s *Service) WithClock(now func() time.Time) *Service {
+	s.now = now
+	return s
+}
+
+// WithRateLimit allows limit link requests per email address, and as many
+// per IP, every window
+func (s *Service) WithRateLimit(limits ratelimit.Store, limit int, window time.Duration) *Service {
+	s.limits, s.limit, s.limitWindow = limits, limit, window
+	return s
+}
+
+// WithSecondFactors refuses links to users with two-factor authentication
+func (s *Service) WithSecondFactors(sf SecondFactors) *Service {
+	s.secondFactors = sf
+	return s
+}
+
+// WithSessionLimit caps each user's active sessions, as for password
+// signins
+func (s *Service) WithSessionLimit(limit session.Limit, revoked signin.RevokedSessions) *Service {
+	s.sessionLimit, s.revoked = limit, revoked
+	return s
+}
+
+// WithEvents publishes a UserSignedIn for each consumed link
+func (s *Service) WithEvents(bus *events.Bus) *Service {
+	s.events = bus
+	return s
+}
+
+// WithGrantedRoles embeds the roles granted to the user in tokens next to
+// their role. Consuming a link verifies the email, so roles waiting for it
+// are in the first token.
+func (s *Service) WithGrantedRoles(grants autorole.GrantStore) *Service {
+	s.grants = grants
+	return s
+}
+
+// RequestLink emails a sign-in link to the user with the given email. It
+// reports success for unknown emails, and for users with two-factor
+// authentication who get no link, so accounts can't be enumerated. The
+// rate limits count every request, known email or not.
+func (s *Service) RequestLink(ctx context.Context, email, ip string) error {
+	email = textnorm.Email(email)
+	// Addresses differing only in case reach the same mailbox
+	if !s.allow("email:"+strings.ToLower(email)) || !s.allow("ip:"+ip) {
+		return ErrRateLimited
+	}
+
+	user, err := s.users.FindByEmail(ctx, email, model.ActiveOnly)
+	if err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if refused, err := s.requiresSecondFactor(ctx, user.ID); err != nil || refused {
+		if refused {
+			logger.Info("magic link refused for two-factor account", map[string]any{
+				"user_id": user.ID.String(),
+			})
+		}
+		return err
+	}
+
+	raw, err := s.issue(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	link, err := s.links.Sign(LinkPath, map[string]string{"token": raw}, s.ttl)
+	if err != nil {
+		return fmt.Errorf("failed to sign magic link: %w", err)
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to sign in. It expires in %s and can be used once.\n\n%s\n\nIf you didn't ask to sign in, you can ignore this email.\n",
+			user.FullName, s.ttl, link),
+	}
+	// Sign-in links are critical email, sent whatever the user's preferences
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send magic link email: %w", err)
+	}
+
+	return nil
+}
+
+// Consume signs in with an emailed link: it checks the link's signature,
+// spends its token, opens a session and returns the tokens. The email
+// address is marked verified, as the link could only be read from it.
+func (s *Service) Consume(ctx context.Context, req ConsumeRequest) (*LoginResult, error) {
+	params, err := s.links.Verify(req.Link)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidLink, err)
+	}
+	id, secret, ok := onetime.ParseToken(params["token"])
+	if !ok {
+		return nil, ErrInvalidLink
+	}
+
+	rec, err := s.store.Consume(ctx, id, s.now(), func(tokenHash string) bool {
+		return onetime.HashMatches(tokenHash, secret)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.FindByID(ctx, rec.UserID, model.ActiveOnly)
+	if err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			return nil, ErrInvalidLink
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	// Same as FindByEmail at signin: deactivated accounts can't sign in
+	if !user.IsActive {
+		return nil, ErrInvalidLink
+	}
+	// Two-factor authentication may have been turned on since the link was sent
+	if refused, err := s.requiresSecondFactor(ctx, user.ID); err != nil || refused {
+		if refused {
+			return nil, ErrSecondFactorRequired
+		}
+		return nil, err
+	}
+
+	if !user.EmailVerified {
+		if err := s.users.MarkEmailVerified(ctx, user.ID); err != nil {
+			return nil, fmt.Errorf("failed to mark email verified: %w", err)
+		}
+		user.EmailVerified = true
+	}
+
+	sess := &session.Session{UserID: user.ID, IP: req.IP, UserAgent: req.UserAgent}
+	evicted, err := s.sessions.CreateLimited(ctx, sess, s.sessionLimit)
+	if errors.Is(err, session.ErrSessionLimit) {
+		return nil, ErrSessionLimit
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	for _, id := range evicted {
+		if s.revoked != nil {
+			s.revoked.MarkRevoked(id)
+		}
+	}
+
+	roles, err := autorole.TokenRoles(ctx, s.grants, user.Role, user.ID, user.EmailVerified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load roles: %w", err)
+	}
+	pair, err := s.tokenManager.GenerateTokenPair(user.ID, token.WithTenant(user.TenantID), token.WithRoles(roles...), token.WithSession(sess.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	s.events.Publish(ctx, events.UserSignedIn{
+		UserID:         user.ID,
+		TenantID:       user.TenantID,
+		SessionID:      sess.ID,
+		AccessTokenID:  pair.AccessTokenID,
+		RefreshTokenID: pair.RefreshTokenID,
+		IP:             req.IP,
+		UserAgent:      req.UserAgent,
+		At:             s.now(),
+	})
+
+	return &LoginResult{User: user, Tokens: pair}, nil
+}
+
+// allow counts a request against key, always allowing it without limits
+func (s *Service) allow(key string) bool {
+	if s.limits == nil {
+		return true
+	}
+	return s.limits.Take("magic_link:"+key, s.limit, s.limitWindow).Allowed
+}
+
+// requiresSecondFactor reports whether the user must sign in with their
+// password and second factor
+func (s *Service) requiresSecondFactor(ctx context.Context, userID uuid.UUID) (bool, error) {
+	if s.secondFactors == nil {
+		return false, nil
+	}
+	enabled, err := s.secondFactors.Enabled(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check two-factor authentication: %w", err)
+	}
+	return enabled, nil
+}
+
+// issue stores a new token for the user and returns its raw form
+func (s *Service) issue(ctx context.Context, userID uuid.UUID) (string, error) {
+	secret, err := onetime.NewSecret()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate magic link token: %w", err)
+	}
+
+	now := s.now()
+	rec := &Record{
+		ID:        uuid.New(),
+		UserID:    userID,
+		TokenHash: onetime.HashSecret(secret),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	if err := s.store.Create(ctx, rec, MaxOutstanding); err != nil {
+		return "", err
+	}
+
+	return onetime.Token(rec.ID, secret), nil
+}
+
+// NewCleanupJob creates the expired magic link token cleanup job
+func NewCleanupJob(store Store) *onetime.CleanupJob {
+	return onetime.NewCleanupJob(CleanupJobName, "magic link tokens", store)
+}
@@ -0,0 +1,239 @@
+package magiclink
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/autorole"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/mailer"
+	"dvith.com/go-service-api/pkg/ratelimit"
+	"dvith.com/go-service-api/pkg/signedurl"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMailer struct {
+	sent []mailer.Message
+}
+
+func (f *fakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// fakeSecondFactors has two-factor authentication on for the users in it
+type fakeSecondFactors map[uuid.UUID]bool
+
+func (f fakeSecondFactors) Enabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return f[userID], nil
+}
+
+type linkFixture struct {
+	ctx      context.Context
+	service  *Service
+	store    *MemoryStore
+	users    *model.MemoryStore
+	user     *model.User
+	sessions *session.MemoryStore
+	mailer   *fakeMailer
+	clock    *fakeClock
+}
+
+func newLinkFixture(t *testing.T) *linkFixture {
+	t.Helper()
+	f := &linkFixture{
+		ctx:    tenant.WithID(context.Background(), uuid.New()),
+		store:  NewMemoryStore(),
+		mailer: &fakeMailer{},
+		clock:  &fakeClock{now: time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)},
+	}
+	f.users = model.NewMemoryStore().WithClock(f.clock.Now)
+	f.sessions = session.NewMemoryStore().WithClock(f.clock.Now)
+
+	user, err := f.users.SaveUser(f.ctx, &model.User{
+		Email:    "user@example.com",
+		FullName: "John Doe",
+		IsActive: true,
+	})
+	require.NoError(t, err)
+	f.user = user
+
+	tokens := token.NewTokenManager(token.TokenConfig{
+		SecretKey:       "test-secret-key-for-testing",
+		ExpirationTime:  time.Hour,
+		RefreshDuration: 7 * 24 * time.Hour,
+		Issuer:          "go-service-api",
+	})
+	links := signedurl.MustNew("https://example.com", "test-secret").WithClock(f.clock.Now)
+	f.service = NewService(f.store, f.users, f.sessions, tokens, f.mailer, links, 15*time.Minute).WithClock(f.clock.Now)
+	return f
+}
+
+// requestLink asks for a link and returns it from the email
+func (f *linkFixture) requestLink(t *testing.T) string {
+	t.Helper()
+	require.NoError(t, f.service.RequestLink(f.ctx, f.user.Email, "203.0.113.7"))
+	require.NotEmpty(t, f.mailer.sent)
+
+	body := f.mailer.sent[len(f.mailer.sent)-1].Body
+	i := strings.Index(body, "https://example.com"+LinkPath+"?")
+	require.NotEqual(t, -1, i, "email must contain a sign-in link: %s", body)
+	return strings.Fields(body[i:])[0]
+}
+
+func (f *linkFixture) consume(link string) (*LoginResult, error) {
+	return f.service.Consume(f.ctx, ConsumeRequest{Link: link, IP: "203.0.113.7", UserAgent: "test"})
+}
+
+func TestConsume_SignsInAndVerifiesEmail(t *testing.T) {
+	f := newLinkFixture(t)
+	require.False(t, f.user.EmailVerified)
+
+	result, err := f.consume(f.requestLink(t))
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Tokens.AccessToken)
+	assert.NotEmpty(t, result.Tokens.RefreshToken)
+	assert.True(t, result.User.EmailVerified)
+
+	stored, err := f.users.FindByID(f.ctx, f.user.ID, model.ActiveOnly)
+	require.NoError(t, err)
+	assert.True(t, stored.EmailVerified)
+
+	sessions, err := f.sessions.ListRecent(f.ctx, f.user.ID, 10)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+func TestConsume_AppliesRolesWaitingForVerification(t *testing.T) {
+	f := newLinkFixture(t)
+	grants := autorole.NewMemoryStore()
+	require.NoError(t, grants.Grant(f.ctx, &autorole.Grant{UserID: f.user.ID, Role: "acme-member", RequiresVerifiedEmail: true}))
+	f.service.WithGrantedRoles(grants)
+
+	result, err := f.consume(f.requestLink(t))
+	require.NoError(t, err)
+	claims, err := f.service.tokenManager.ValidateAccessToken(result.Tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, []string{f.user.Role, "acme-member"}, claims.Roles, "the link verifies the email, so the role applies")
+}
+
+func TestConsume_Replay(t *testing.T) {
+	f := newLinkFixture(t)
+	link := f.requestLink(t)
+
+	_, err := f.consume(link)
+	require.NoError(t, err)
+
+	_, err = f.consume(link)
+	assert.ErrorIs(t, err, ErrInvalidLink)
+	assert.True(t, IsClientError(err))
+}
+
+func TestConsume_Expired(t *testing.T) {
+	f := newLinkFixture(t)
+	link := f.requestLink(t)
+
+	f.clock.now = f.clock.now.Add(16 * time.Minute)
+	_, err := f.consume(link)
+	assert.ErrorIs(t, err, ErrInvalidLink)
+}
+
+func TestConsume_TamperedLink(t *testing.T) {
+	f := newLinkFixture(t)
+	link := f.requestLink(t)
+
+	_, err := f.consume(strings.Replace(link, "token=", "token=x", 1))
+	assert.ErrorIs(t, err, ErrInvalidLink)
+}
+
+func TestRequestLink_UnknownEmail(t *testing.T) {
+	f := newLinkFixture(t)
+
+	require.NoError(t, f.service.RequestLink(f.ctx, "nobody@example.com", "203.0.113.7"))
+	assert.Empty(t, f.mailer.sent)
+	assert.Empty(t, f.store.tokens)
+}
+
+func TestRequestLink_RefusedForSecondFactor(t *testing.T) {
+	f := newLinkFixture(t)
+	f.service.WithSecondFactors(fakeSecondFactors{f.user.ID: true})
+
+	require.NoError(t, f.service.RequestLink(f.ctx, f.user.Email, "203.0.113.7"))
+	assert.Empty(t, f.mailer.sent)
+	assert.Empty(t, f.store.tokens)
+}
+
+func TestConsume_RefusedWhenSecondFactorTurnedOn(t *testing.T) {
+	f := newLinkFixture(t)
+	link := f.requestLink(t)
+	f.service.WithSecondFactors(fakeSecondFactors{f.user.ID: true})
+
+	_, err := f.consume(link)
+	assert.ErrorIs(t, err, ErrSecondFactorRequired)
+
+	sessions, err := f.sessions.ListRecent(f.ctx, f.user.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestRequestLink_RateLimited(t *testing.T) {
+	f := newLinkFixture(t)
+	limits := ratelimit.NewMemoryStore(cache.NewMemory().WithClock(f.clock.Now)).WithClock(f.clock.Now)
+	f.service.WithRateLimit(limits, 2, time.Minute)
+
+	for range 2 {
+		require.NoError(t, f.service.RequestLink(f.ctx, f.user.Email, "203.0.113.7"))
+	}
+	// Case doesn't make a new address
+	assert.ErrorIs(t, f.service.RequestLink(f.ctx, "USER@example.com", "198.51.100.1"), ErrRateLimited)
+	// Nor does asking for other addresses from the same IP
+	require.NoError(t, f.service.RequestLink(f.ctx, "a@example.com", "192.0.2.1"))
+	require.NoError(t, f.service.RequestLink(f.ctx, "b@example.com", "192.0.2.1"))
+	assert.ErrorIs(t, f.service.RequestLink(f.ctx, "c@example.com", "192.0.2.1"), ErrRateLimited)
+	assert.Len(t, f.mailer.sent, 2)
+
+	f.clock.now = f.clock.now.Add(time.Minute)
+	assert.NoError(t, f.service.RequestLink(f.ctx, f.user.Email, "203.0.113.7"))
+}
+
+func TestRequestHandler_SameResponseForUnknownEmail(t *testing.T) {
+	f := newLinkFixture(t)
+	app := fiber.New()
+	app.Post("/magic-link", func(c fiber.Ctx) error {
+		c.SetContext(f.ctx)
+		return c.Next()
+	}, middleware.ValidateBody[MagicLinkRequest](), RequestHandler(f.service))
+
+	var bodies []string
+	for _, email := range []string{f.user.Email, "nobody@example.com"} {
+		req := httptest.NewRequest(fiber.MethodPost, "/magic-link", strings.NewReader(`{"email":"`+email+`"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+	}
+	assert.Equal(t, bodies[0], bodies[1])
+	assert.Len(t, f.mailer.sent, 1)
+}
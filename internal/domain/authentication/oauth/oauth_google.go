@@ -0,0 +1,206 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Google's OAuth2 endpoints
+const (
+	googleAuthURL  = "https://accounts.google.com/o/oauth2/v2/auth"
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+	googleJWKSURL  = "https://www.googleapis.com/oauth2/v3/certs"
+)
+
+// googleIssuers are the iss values Google signs ID tokens with
+var googleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// exchangeTimeout bounds each call to the provider's token and key endpoints
+const exchangeTimeout = 10 * time.Second
+
+// GoogleConfig configures the Google provider. The endpoint URLs default
+// to Google's and are only overridden in tests.
+type GoogleConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	AuthURL  string
+	TokenURL string
+	JWKSURL  string
+}
+
+// GoogleProvider signs users in with Google using OpenID Connect
+type GoogleProvider struct {
+	cfg    GoogleConfig
+	client *http.Client
+	keys   *KeySet
+}
+
+// NewGoogleProvider creates the Google provider
+func NewGoogleProvider(cfg GoogleConfig) *GoogleProvider {
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = googleAuthURL
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = googleTokenURL
+	}
+	if cfg.JWKSURL == "" {
+		cfg.JWKSURL = googleJWKSURL
+	}
+
+	client := &http.Client{Timeout: exchangeTimeout}
+	return &GoogleProvider{
+		cfg:    cfg,
+		client: client,
+		keys:   NewKeySet(cfg.JWKSURL, client),
+	}
+}
+
+// Name implements Provider
+func (p *GoogleProvider) Name() string { return "google" }
+
+// AuthCodeURL implements Provider
+func (p *GoogleProvider) AuthCodeURL(state, codeChallenge string) string {
+	q := url.Values{
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"response_type":         {"code"},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	return p.cfg.AuthURL + "?" + q.Encode()
+}
+
+type googleTokenResponse struct {
+	IDToken string `json:"id_token"`
+	Error   string `json:"error"`
+}
+
+// googleClaims are the ID token claims the service relies on
+type googleClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// Exchange implements Provider
+func (p *GoogleProvider) Exchange(ctx context.Context, code, codeVerifier string) (*Identity, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+		"redirect_uri":  {p.cfg.RedirectURL},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body googleTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	// invalid_grant covers expired, reused and mismatched codes
+	if resp.StatusCode == http.StatusBadRequest && body.Error == "invalid_grant" {
+		return nil, ErrInvalidCode
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to exchange code: status %d %s", resp.StatusCode, body.Error)
+	}
+	if body.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrInvalidIDToken)
+	}
+
+	claims, err := p.verify(ctx, body.IDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{
+		Provider:      p.Name(),
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(claims.Email),
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// verify checks the ID token's signature against Google's keys, and its
+// issuer, audience and expiry
+func (p *GoogleProvider) verify(ctx context.Context, raw string) (*googleClaims, error) {
+	var claims googleClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.Key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		if errors.Is(err, ErrInvalidIDToken) {
+			return nil, err
+		}
+		// Key fetch failures are ours, not the client's
+		if errors.Is(err, jwt.ErrTokenUnverifiable) {
+			return nil, fmt.Errorf("failed to verify id token: %w", err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if !validIssuer(claims.Issuer) {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	}
+	if claims.Subject == "" || claims.Email == "" {
+		return nil, fmt.Errorf("%w: missing subject or email", ErrInvalidIDToken)
+	}
+
+	return &claims, nil
+}
+
+func validIssuer(iss string) bool {
+	for _, want := range googleIssuers {
+		if iss == want {
+			return true
+		}
+	}
+	return false
+}
+
+// ProvidersFromConfig returns the providers enabled in config
+func ProvidersFromConfig(cfg config.Config) []Provider {
+	var providers []Provider
+	if cfg.GoogleClientID != "" {
+		providers = append(providers, NewGoogleProvider(GoogleConfig{
+			ClientID:     cfg.GoogleClientID,
+			ClientSecret: cfg.GoogleClientSecret,
+			RedirectURL:  cfg.GoogleRedirectURL,
+		}))
+	}
+	return providers
+}
@@ -0,0 +1,188 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testClientID = "client-123.apps.googleusercontent.com"
+
+// googleStub serves Google's token and JWKS endpoints
+type googleStub struct {
+	t          *testing.T
+	key        *rsa.PrivateKey
+	kid        string
+	claims     jwt.MapClaims
+	tokenErr   string
+	jwksHits   atomic.Int32
+	lastForm   url.Values
+	server     *httptest.Server
+	signingKey *rsa.PrivateKey
+}
+
+func newGoogleStub(t *testing.T) *googleStub {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	s := &googleStub{t: t, key: key, signingKey: key, kid: "key-1"}
+	s.claims = jwt.MapClaims{
+		"iss":            "https://accounts.google.com",
+		"aud":            testClientID,
+		"sub":            "10769150350006150715113082367",
+		"email":          "Jane@Example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		s.lastForm = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		if s.tokenErr != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": s.tokenErr})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": s.idToken()})
+	})
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		s.jwksHits.Add(1)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": s.kid,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}}})
+	})
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *googleStub) idToken() string {
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, s.claims)
+	tok.Header["kid"] = s.kid
+	raw, err := tok.SignedString(s.signingKey)
+	require.NoError(s.t, err)
+	return raw
+}
+
+func (s *googleStub) provider() *GoogleProvider {
+	return NewGoogleProvider(GoogleConfig{
+		ClientID:     testClientID,
+		ClientSecret: "shh",
+		RedirectURL:  "https://api.example.com/api/v1/auth/oauth/google/callback",
+		AuthURL:      s.server.URL + "/auth",
+		TokenURL:     s.server.URL + "/token",
+		JWKSURL:      s.server.URL + "/certs",
+	})
+}
+
+func TestGoogleProvider_AuthCodeURL(t *testing.T) {
+	p := newGoogleStub(t).provider()
+
+	u, err := url.Parse(p.AuthCodeURL("the-state", "the-challenge"))
+	require.NoError(t, err)
+	q := u.Query()
+	assert.Equal(t, testClientID, q.Get("client_id"))
+	assert.Equal(t, "the-state", q.Get("state"))
+	assert.Equal(t, "the-challenge", q.Get("code_challenge"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Contains(t, q.Get("scope"), "openid")
+}
+
+func TestGoogleProvider_Exchange(t *testing.T) {
+	stub := newGoogleStub(t)
+	p := stub.provider()
+
+	identity, err := p.Exchange(context.Background(), "auth-code", "the-verifier")
+	require.NoError(t, err)
+	assert.Equal(t, "google", identity.Provider)
+	assert.Equal(t, "jane@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+	assert.Equal(t, "Jane Doe", identity.Name)
+	assert.Equal(t, "the-verifier", stub.lastForm.Get("code_verifier"))
+	assert.Equal(t, "auth-code", stub.lastForm.Get("code"))
+
+	// The key set is cached between exchanges
+	_, err = p.Exchange(context.Background(), "auth-code", "the-verifier")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), stub.jwksHits.Load())
+}
+
+func TestGoogleProvider_RejectsBadTokens(t *testing.T) {
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		mutate func(s *googleStub)
+	}{
+		{"wrong audience", func(s *googleStub) { s.claims["aud"] = "someone-else" }},
+		{"wrong issuer", func(s *googleStub) { s.claims["iss"] = "https://evil.example.com" }},
+		{"expired", func(s *googleStub) { s.claims["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{"bad signature", func(s *googleStub) { s.signingKey = other }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newGoogleStub(t)
+			tt.mutate(stub)
+
+			_, err := stub.provider().Exchange(context.Background(), "auth-code", "v")
+			assert.ErrorIs(t, err, ErrInvalidIDToken)
+			assert.True(t, IsClientError(err))
+		})
+	}
+}
+
+func TestGoogleProvider_InvalidGrant(t *testing.T) {
+	stub := newGoogleStub(t)
+	stub.tokenErr = "invalid_grant"
+
+	_, err := stub.provider().Exchange(context.Background(), "used-code", "v")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+}
+
+func TestKeySet_RefetchesOnUnknownKid(t *testing.T) {
+	stub := newGoogleStub(t)
+	now := time.Now()
+	ks := NewKeySet(stub.server.URL+"/certs", http.DefaultClient)
+	ks.now = func() time.Time { return now }
+
+	_, err := ks.Key(context.Background(), "key-1")
+	require.NoError(t, err)
+
+	// Rotated keys are picked up, but not more than once a minute
+	stub.kid = "key-2"
+	_, err = ks.Key(context.Background(), "key-2")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+	assert.Equal(t, int32(1), stub.jwksHits.Load())
+
+	now = now.Add(minRefetchInterval)
+	_, err = ks.Key(context.Background(), "key-2")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), stub.jwksHits.Load())
+}
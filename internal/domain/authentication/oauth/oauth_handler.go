@@ -0,0 +1,74 @@
+package oauth
+
+import (
+	"dvith.com/go-service-api/internal/domain/user/dto"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/requestmeta"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// StartHandler redirects the browser to the provider's consent screen
+func StartHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		redirectURL, err := service.Start(middleware.GetRequestContext(c), c.Params("provider"))
+		if err != nil {
+			if IsClientError(err) {
+				return middleware.NotFoundResponse(c, err.Error())
+			}
+
+			logger.Error("failed to start oauth login", map[string]any{
+				"path":  c.Path(),
+				"error": err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to start login", err)
+		}
+
+		return c.Redirect().Status(fiber.StatusFound).To(redirectURL)
+	}
+}
+
+// CallbackHandler completes the login the provider redirected back from
+func CallbackHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		// The user declined, or the provider rejected the request
+		if c.Query("error") != "" {
+			return middleware.ValidationErrorResponse(c, ErrAccessDenied.Error())
+		}
+
+		meta := requestmeta.FromCtx(c)
+		result, err := service.Callback(middleware.GetRequestContext(c), CallbackRequest{
+			Provider:  c.Params("provider"),
+			State:     c.Query("state"),
+			Code:      c.Query("code"),
+			IP:        meta.IP,
+			UserAgent: meta.UserAgent,
+		})
+		if err != nil {
+			if IsClientError(err) {
+				return middleware.ValidationErrorResponse(c, err.Error())
+			}
+
+			// Infrastructure failures are logged but never exposed to the client
+			logger.Error("failed to complete oauth login", map[string]any{
+				"path":  c.Path(),
+				"error": err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to login user", err)
+		}
+
+		status := fiber.StatusOK
+		if result.Created {
+			status = fiber.StatusCreated
+		}
+
+		return c.Status(status).JSON(fiber.Map{
+			"message":       "User logged in successfully",
+			"user":          dto.FromUser(result.User),
+			"access_token":  result.Tokens.AccessToken,
+			"refresh_token": result.Tokens.RefreshToken,
+			"token_type":    result.Tokens.TokenType,
+			"expires_in":    result.Tokens.ExpiresIn,
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOAuthTestApp(f *oauthFixture) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c fiber.Ctx) error {
+		middleware.SetRequestContext(c, f.ctx)
+		return c.Next()
+	})
+	app.Get("/oauth/:provider/start", StartHandler(f.service))
+	app.Get("/oauth/:provider/callback", CallbackHandler(f.service))
+	return app
+}
+
+func TestHandlers_RoundTrip(t *testing.T) {
+	f := newOAuthFixture(t)
+	app := newOAuthTestApp(f)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/oauth/google/start", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get(fiber.HeaderLocation))
+	require.NoError(t, err)
+	state := location.Query().Get("state")
+	require.NotEmpty(t, state)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/oauth/google/callback?code=auth-code&state="+state, nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestHandlers_Errors(t *testing.T) {
+	f := newOAuthFixture(t)
+	app := newOAuthTestApp(f)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/oauth/github/start", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/oauth/google/callback?error=access_denied", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/oauth/google/callback?code=x&state=forged", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func newIdentityTestApp(f *oauthFixture, userID uuid.UUID) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c fiber.Ctx) error {
+		middleware.SetRequestContext(c, f.ctx)
+		c.Locals(middleware.ContextKeyUserID, userID)
+		return c.Next()
+	})
+	app.Get("/identities", IdentitiesHandler(f.service))
+	app.Post("/identities/:provider/link", middleware.ValidateBody[LinkIdentityRequest](), LinkHandler(f.service))
+	app.Delete("/identities/:provider", UnlinkHandler(f.service))
+	return app
+}
+
+func TestIdentityHandlers_StatusCodes(t *testing.T) {
+	f := newOAuthFixture(t)
+	owner := f.addUser("owner@example.com", "")
+	other := f.addUser("other@example.com", "hash")
+
+	link := func(userID uuid.UUID) int {
+		body := `{"state":"` + f.linkState(t, userID) + `","code":"c"}`
+		req := httptest.NewRequest(http.MethodPost, "/identities/google/link", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := newIdentityTestApp(f, userID).Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusCreated, link(owner.ID))
+	assert.Equal(t, http.StatusConflict, link(other.ID), "already linked to another user")
+
+	resp, err := newIdentityTestApp(f, owner.ID).Test(httptest.NewRequest(http.MethodGet, "/identities", nil))
+	require.NoError(t, err)
+	var listed IdentitiesResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	require.Len(t, listed.Identities, 1)
+	assert.Equal(t, "google", listed.Identities[0].Provider)
+
+	resp, err = newIdentityTestApp(f, owner.ID).Test(httptest.NewRequest(http.MethodDelete, "/identities/google", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode, "last way to sign in")
+
+	resp, err = newIdentityTestApp(f, other.ID).Test(httptest.NewRequest(http.MethodDelete, "/identities/google", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
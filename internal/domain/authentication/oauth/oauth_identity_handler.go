@@ -0,0 +1,130 @@
+package oauth
+
+import (
+	"errors"
+
+	"dvith.com/go-service-api/internal/domain/user/dto"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// IdentitiesResponse lists the user's linked identities
+type IdentitiesResponse struct {
+	Identities []dto.IdentityDTO `json:"identities"`
+}
+
+// LinkIdentityRequest carries the provider's redirect back to the client
+type LinkIdentityRequest struct {
+	State string `json:"state" validate:"required"`
+	Code  string `json:"code" validate:"required"`
+}
+
+// IdentitiesHandler lists the authenticated user's linked identities
+func IdentitiesHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		identities, err := service.Identities(middleware.GetRequestContext(c), userID)
+		if err != nil {
+			logger.Error("failed to list identities", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to list identities", err)
+		}
+
+		response := IdentitiesResponse{Identities: make([]dto.IdentityDTO, 0, len(identities))}
+		for _, i := range identities {
+			response.Identities = append(response.Identities, dto.FromIdentity(i))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// StartLinkHandler returns the provider URL that begins linking an account
+// to the authenticated user. The client completes the flow by posting the
+// state and code it is redirected back with to LinkHandler.
+func StartLinkHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		authURL, err := service.StartLink(middleware.GetRequestContext(c), c.Params("provider"), userID)
+		if err != nil {
+			return identityError(c, "failed to start identity link", err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"authorization_url": authURL,
+		})
+	}
+}
+
+// LinkHandler completes a link flow and attaches the identity
+func LinkHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		req, err := middleware.GetValidatedBody[LinkIdentityRequest](c)
+		if err != nil {
+			return middleware.InternalErrorResponse(c, "validated body missing")
+		}
+
+		linked, err := service.Link(middleware.GetRequestContext(c), LinkRequest{
+			Provider: c.Params("provider"),
+			State:    req.State,
+			Code:     req.Code,
+			UserID:   userID,
+		})
+		if err != nil {
+			return identityError(c, "failed to link identity", err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(dto.FromIdentity(*linked))
+	}
+}
+
+// UnlinkHandler removes the authenticated user's identity for a provider
+func UnlinkHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		if err := service.Unlink(middleware.GetRequestContext(c), userID, c.Params("provider")); err != nil {
+			return identityError(c, "failed to unlink identity", err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// identityError maps identity management errors to responses
+func identityError(c fiber.Ctx, msg string, err error) error {
+	switch {
+	case errors.Is(err, ErrIdentityConflict), errors.Is(err, ErrLastAuthMethod):
+		return middleware.ConflictResponse(c, err.Error())
+	case errors.Is(err, ErrIdentityNotFound), errors.Is(err, ErrUnknownProvider), errors.Is(err, model.ErrUserNotFound):
+		return middleware.NotFoundResponse(c, err.Error())
+	case IsClientError(err):
+		return middleware.ValidationErrorResponse(c, err.Error())
+	}
+
+	logger.Error(msg, map[string]any{
+		"path":  c.Path(),
+		"error": err.Error(),
+	})
+	return middleware.InternalErrorResponse(c, msg, err)
+}
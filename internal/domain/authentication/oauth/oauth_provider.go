@@ -0,0 +1,59 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+
+	"dvith.com/go-service-api/internal/identity"
+)
+
+// OAuth errors caused by the request rather than by the server
+var (
+	ErrUnknownProvider  = errors.New("unknown oauth provider")
+	ErrInvalidState     = errors.New("invalid or expired oauth state")
+	ErrInvalidCode      = errors.New("invalid or expired authorization code")
+	ErrInvalidIDToken   = errors.New("invalid id token")
+	ErrEmailNotVerified = errors.New("provider account email is not verified")
+	ErrAccessDenied     = errors.New("authorization was denied")
+	ErrAccountDisabled  = errors.New("the linked account is not available")
+)
+
+// Identity management errors, reported with their own status codes
+var (
+	ErrIdentityConflict = errors.New("this provider account is already linked to another user")
+	ErrLastAuthMethod   = errors.New("cannot unlink the last way to sign in; set a password first")
+	ErrIdentityNotFound = identity.ErrNotFound
+)
+
+// IsClientError reports whether err from the OAuth flow should be reported
+// to the client as a bad request
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrUnknownProvider) ||
+		errors.Is(err, ErrInvalidState) ||
+		errors.Is(err, ErrInvalidCode) ||
+		errors.Is(err, ErrInvalidIDToken) ||
+		errors.Is(err, ErrEmailNotVerified) ||
+		errors.Is(err, ErrAccessDenied) ||
+		errors.Is(err, ErrAccountDisabled)
+}
+
+// Identity is the account the provider vouches for
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is an OAuth2 authorization code provider. Each provider owns
+// its endpoints and how it turns a code into a verified Identity.
+type Provider interface {
+	// Name is the path segment the provider is served under
+	Name() string
+	// AuthCodeURL is where the browser is sent to authorize, carrying the
+	// state and the S256 PKCE challenge
+	AuthCodeURL(state, codeChallenge string) string
+	// Exchange redeems the authorization code and returns the verified identity
+	Exchange(ctx context.Context, code, codeVerifier string) (*Identity, error)
+}
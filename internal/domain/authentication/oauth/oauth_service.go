@@ -0,0 +1,401 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/internal/autorole"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/identity"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/crypto"
+	"github.com/google/uuid"
+)
+
+// stateTTL is how long a user has to complete the provider's consent screen
+const stateTTL = 10 * time.Minute
+
+// UserStore finds and creates the users OAuth identities sign in as
+type UserStore interface {
+	FindByID(ctx context.Context, userID uuid.UUID, scope model.Scope) (*model.User, error)
+	FindByEmail(ctx context.Context, email string, scope model.Scope) (*model.User, error)
+	SaveUser(ctx context.Context, user *model.User) (*model.User, error)
+	MarkEmailVerified(ctx context.Context, userID uuid.UUID) error
+}
+
+// IdentityStore persists the provider accounts linked to users
+type IdentityStore interface {
+	Create(ctx context.Context, i *identity.Identity) error
+	FindBySubject(ctx context.Context, provider, subject string) (*identity.Identity, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]identity.Identity, error)
+	Delete(ctx context.Context, userID uuid.UUID, provider string) error
+}
+
+// SessionStore opens a session for each OAuth signin
+type SessionStore interface {
+	Create(ctx context.Context, s *session.Session) error
+}
+
+// pendingLogin is the server-side half of an authorization in progress.
+// Link flows carry the user the identity is being linked to; login flows
+// carry uuid.Nil.
+type pendingLogin struct {
+	provider string
+	verifier string
+	tenantID uuid.UUID
+	userID   uuid.UUID
+}
+
+// CallbackRequest is the provider's redirect back to the service
+type CallbackRequest struct {
+	Provider  string
+	State     string
+	Code      string
+	IP        string
+	UserAgent string
+}
+
+// LinkRequest completes a link flow for an authenticated user
+type LinkRequest struct {
+	Provider string
+	State    string
+	Code     string
+	UserID   uuid.UUID
+}
+
+// LoginResult is the signed-in user and their tokens
+type LoginResult struct {
+	User    *model.User
+	Tokens  *token.TokenPair
+	Created bool
+}
+
+// Service runs the authorization code flow with PKCE for each provider
+type Service struct {
+	providers    map[string]Provider
+	users        UserStore
+	identities   IdentityStore
+	sessions     SessionStore
+	tokenManager *token.TokenManager
+	cache        cache.Cache
+	grants       autorole.GrantStore
+}
+
+// NewService creates the OAuth login service. State is kept in c.
+func NewService(users UserStore, identities IdentityStore, sessions SessionStore, tokenManager *token.TokenManager, c cache.Cache, providers ...Provider) *Service {
+	s := &Service{
+		providers:    make(map[string]Provider, len(providers)),
+		users:        users,
+		identities:   identities,
+		sessions:     sessions,
+		tokenManager: tokenManager,
+		cache:        c,
+	}
+	for _, p := range providers {
+		s.providers[p.Name()] = p
+	}
+	return s
+}
+
+// WithGrantedRoles embeds the roles granted to the user in tokens next to
+// their role
+func (s *Service) WithGrantedRoles(grants autorole.GrantStore) *Service {
+	s.grants = grants
+	return s
+}
+
+// Start begins a login with the named provider and returns the URL to send
+// the browser to
+func (s *Service) Start(ctx context.Context, providerName string) (string, error) {
+	return s.start(ctx, providerName, uuid.Nil)
+}
+
+// StartLink begins linking the named provider to an authenticated user and
+// returns the URL to send the browser to
+func (s *Service) StartLink(ctx context.Context, providerName string, userID uuid.UUID) (string, error) {
+	return s.start(ctx, providerName, userID)
+}
+
+func (s *Service) start(ctx context.Context, providerName string, userID uuid.UUID) (string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", err
+	}
+
+	s.cache.Set(stateKey(state), pendingLogin{provider: providerName, verifier: verifier, tenantID: tenantID, userID: userID}, stateTTL)
+
+	challenge := sha256.Sum256([]byte(verifier))
+	return provider.AuthCodeURL(state, base64.RawURLEncoding.EncodeToString(challenge[:])), nil
+}
+
+// Callback completes a login: it checks the state and redeems the code,
+// then signs in as the user the provider account is linked to. An account
+// that isn't linked yet is matched to a user by verified email, or a new
+// user is created, and linked for next time.
+func (s *Service) Callback(ctx context.Context, req CallbackRequest) (*LoginResult, error) {
+	ident, err := s.redeem(ctx, req.Provider, req.State, req.Code, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	user, created, err := s.userFor(ctx, ident)
+	if err != nil {
+		return nil, err
+	}
+
+	sess := &session.Session{UserID: user.ID, IP: req.IP, UserAgent: req.UserAgent}
+	if err := s.sessions.Create(ctx, sess); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	roles, err := autorole.TokenRoles(ctx, s.grants, user.Role, user.ID, user.EmailVerified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load roles: %w", err)
+	}
+	pair, err := s.tokenManager.GenerateTokenPair(user.ID, token.WithTenant(user.TenantID), token.WithRoles(roles...), token.WithSession(sess.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	return &LoginResult{User: user, Tokens: pair, Created: created}, nil
+}
+
+// Link completes a link flow, attaching the provider account to the user.
+// Linking an account that is already linked to the same user is a no-op.
+func (s *Service) Link(ctx context.Context, req LinkRequest) (*identity.Identity, error) {
+	ident, err := s.redeem(ctx, req.Provider, req.State, req.Code, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.identities.FindBySubject(ctx, ident.Provider, ident.Subject)
+	switch {
+	case err == nil && existing.UserID == req.UserID:
+		return existing, nil
+	case err == nil:
+		return nil, ErrIdentityConflict
+	case !errors.Is(err, identity.ErrNotFound):
+		return nil, err
+	}
+
+	linked := &identity.Identity{UserID: req.UserID, Provider: ident.Provider, ProviderSubject: ident.Subject, Email: ident.Email}
+	if err := s.identities.Create(ctx, linked); err != nil {
+		if errors.Is(err, identity.ErrAlreadyLinked) {
+			return nil, ErrIdentityConflict
+		}
+		return nil, err
+	}
+	return linked, nil
+}
+
+// Unlink removes the user's identity for the provider. The last identity
+// of a user without a password can't be removed, or they couldn't sign in.
+func (s *Service) Unlink(ctx context.Context, userID uuid.UUID, providerName string) error {
+	user, err := s.users.FindByID(ctx, userID, model.ActiveOnly)
+	if err != nil {
+		return err
+	}
+
+	identities, err := s.identities.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, i := range identities {
+		if i.Provider == providerName {
+			found = true
+		}
+	}
+	if !found {
+		return ErrIdentityNotFound
+	}
+	if user.Password == "" && len(identities) == 1 {
+		return ErrLastAuthMethod
+	}
+
+	return s.identities.Delete(ctx, userID, providerName)
+}
+
+// Identities lists the provider accounts linked to the user
+func (s *Service) Identities(ctx context.Context, userID uuid.UUID) ([]identity.Identity, error) {
+	return s.identities.ListByUser(ctx, userID)
+}
+
+// redeem checks the state belongs to this flow and exchanges the code
+func (s *Service) redeem(ctx context.Context, providerName, state, code string, userID uuid.UUID) (*Identity, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	pending, err := s.takeState(ctx, providerName, state, userID)
+	if err != nil {
+		return nil, err
+	}
+	if code == "" {
+		return nil, ErrInvalidCode
+	}
+
+	return provider.Exchange(ctx, code, pending.verifier)
+}
+
+// takeState loads and deletes the pending login, so each state works once.
+// A login state can't complete a link, nor one user's link state another's.
+func (s *Service) takeState(ctx context.Context, providerName, state string, userID uuid.UUID) (*pendingLogin, error) {
+	if state == "" {
+		return nil, ErrInvalidState
+	}
+
+	v, ok := s.cache.Get(stateKey(state))
+	if !ok {
+		return nil, ErrInvalidState
+	}
+	s.cache.Delete(stateKey(state))
+
+	pending := v.(pendingLogin)
+	tenantID, _ := tenant.IDFromContext(ctx)
+	if pending.provider != providerName || pending.tenantID != tenantID || pending.userID != userID {
+		return nil, ErrInvalidState
+	}
+	return &pending, nil
+}
+
+// userFor returns the user the provider account signs in as, linking the
+// account on first use
+func (s *Service) userFor(ctx context.Context, ident *Identity) (*model.User, bool, error) {
+	linked, err := s.identities.FindBySubject(ctx, ident.Provider, ident.Subject)
+	if err == nil {
+		user, err := s.users.FindByID(ctx, linked.UserID, model.ActiveOnly)
+		if errors.Is(err, model.ErrUserNotFound) {
+			return nil, false, ErrAccountDisabled
+		}
+		return user, false, err
+	}
+	if !errors.Is(err, identity.ErrNotFound) {
+		return nil, false, err
+	}
+
+	// Linking by an unverified email would let anyone claim an account
+	if !ident.EmailVerified {
+		return nil, false, ErrEmailNotVerified
+	}
+
+	user, created, err := s.findOrCreate(ctx, ident)
+	if err != nil {
+		return nil, false, err
+	}
+
+	link := &identity.Identity{UserID: user.ID, Provider: ident.Provider, ProviderSubject: ident.Subject, Email: ident.Email}
+	if err := s.identities.Create(ctx, link); err != nil {
+		// The user already links a different account of this provider
+		if errors.Is(err, identity.ErrAlreadyLinked) {
+			return nil, false, ErrIdentityConflict
+		}
+		return nil, false, err
+	}
+
+	return user, created, nil
+}
+
+func (s *Service) findOrCreate(ctx context.Context, ident *Identity) (*model.User, bool, error) {
+	user, err := s.users.FindByEmail(ctx, ident.Email, model.ActiveOnly)
+	if err == nil {
+		if !user.EmailVerified {
+			if err := s.users.MarkEmailVerified(ctx, user.ID); err != nil {
+				return nil, false, err
+			}
+			now := clock.Now()
+			user.EmailVerified = true
+			user.VerifiedAt = &now
+		}
+		return user, false, nil
+	}
+	if !errors.Is(err, model.ErrUserNotFound) {
+		return nil, false, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	username, err := usernameFor(ident.Email)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := clock.Now()
+	fullName := ident.Name
+	if fullName == "" {
+		fullName = username
+	}
+
+	// OAuth users have no password until they set one through a reset
+	saved, err := s.users.SaveUser(ctx, &model.User{
+		Email:         ident.Email,
+		FullName:      crypto.EncryptedString(fullName),
+		Username:      username,
+		IsActive:      true,
+		EmailVerified: true,
+		VerifiedAt:    &now,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return saved, true, nil
+}
+
+var usernameUnsafe = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// usernameFor derives a unique-enough username from the email's local part
+func usernameFor(email string) (string, error) {
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	base := strings.Trim(usernameUnsafe.ReplaceAllString(local, "_"), "_")
+	if len(base) > 20 {
+		base = base[:20]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate username: %w", err)
+	}
+	return base + "_" + hex.EncodeToString(suffix), nil
+}
+
+func stateKey(state string) string {
+	return "oauth_state:" + state
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,321 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/identity"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/cache"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUsers struct {
+	byEmail  map[string]*model.User
+	verified []uuid.UUID
+}
+
+func (f *fakeUsers) FindByID(ctx context.Context, userID uuid.UUID, scope model.Scope) (*model.User, error) {
+	for _, u := range f.byEmail {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return nil, model.ErrUserNotFound
+}
+
+func (f *fakeUsers) FindByEmail(ctx context.Context, email string, scope model.Scope) (*model.User, error) {
+	if u, ok := f.byEmail[email]; ok {
+		return u, nil
+	}
+	return nil, model.ErrUserNotFound
+}
+
+func (f *fakeUsers) SaveUser(ctx context.Context, user *model.User) (*model.User, error) {
+	user.ID = uuid.New()
+	user.TenantID, _ = tenant.IDFromContext(ctx)
+	f.byEmail[user.Email] = user
+	return user, nil
+}
+
+func (f *fakeUsers) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	f.verified = append(f.verified, userID)
+	return nil
+}
+
+// fakeIdentities enforces the same unique constraints as the table
+type fakeIdentities struct {
+	rows []identity.Identity
+}
+
+func (f *fakeIdentities) Create(ctx context.Context, i *identity.Identity) error {
+	for _, r := range f.rows {
+		if (r.Provider == i.Provider && r.ProviderSubject == i.ProviderSubject) || (r.UserID == i.UserID && r.Provider == i.Provider) {
+			return identity.ErrAlreadyLinked
+		}
+	}
+	i.ID = uuid.New()
+	i.LinkedAt = time.Now()
+	f.rows = append(f.rows, *i)
+	return nil
+}
+
+func (f *fakeIdentities) FindBySubject(ctx context.Context, provider, subject string) (*identity.Identity, error) {
+	for _, r := range f.rows {
+		if r.Provider == provider && r.ProviderSubject == subject {
+			return &r, nil
+		}
+	}
+	return nil, identity.ErrNotFound
+}
+
+func (f *fakeIdentities) ListByUser(ctx context.Context, userID uuid.UUID) ([]identity.Identity, error) {
+	var out []identity.Identity
+	for _, r := range f.rows {
+		if r.UserID == userID {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeIdentities) Delete(ctx context.Context, userID uuid.UUID, provider string) error {
+	for i, r := range f.rows {
+		if r.UserID == userID && r.Provider == provider {
+			f.rows = append(f.rows[:i], f.rows[i+1:]...)
+			return nil
+		}
+	}
+	return identity.ErrNotFound
+}
+
+type fakeSessions struct {
+	created []*session.Session
+}
+
+func (f *fakeSessions) Create(ctx context.Context, s *session.Session) error {
+	f.created = append(f.created, s)
+	return nil
+}
+
+type oauthFixture struct {
+	service    *Service
+	stub       *googleStub
+	users      *fakeUsers
+	identities *fakeIdentities
+	sessions   *fakeSessions
+	ctx        context.Context
+}
+
+func newOAuthFixture(t *testing.T) *oauthFixture {
+	f := &oauthFixture{
+		stub:       newGoogleStub(t),
+		users:      &fakeUsers{byEmail: map[string]*model.User{}},
+		identities: &fakeIdentities{},
+		sessions:   &fakeSessions{},
+		ctx:        tenant.WithID(context.Background(), uuid.New()),
+	}
+	tm := token.NewTokenManager(token.TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  15 * time.Minute,
+		RefreshDuration: 24 * time.Hour,
+		Issuer:          "test",
+	})
+	f.service = NewService(f.users, f.identities, f.sessions, tm, cache.NewMemory(), f.stub.provider())
+	return f
+}
+
+// start begins a login and returns the state from the redirect URL
+func (f *oauthFixture) start(t *testing.T) (state, challenge string) {
+	t.Helper()
+	redirect, err := f.service.Start(f.ctx, "google")
+	require.NoError(t, err)
+	u, err := url.Parse(redirect)
+	require.NoError(t, err)
+	return u.Query().Get("state"), u.Query().Get("code_challenge")
+}
+
+func TestService_CreatesUser(t *testing.T) {
+	f := newOAuthFixture(t)
+	state, challenge := f.start(t)
+
+	result, err := f.service.Callback(f.ctx, CallbackRequest{Provider: "google", State: state, Code: "auth-code", IP: "203.0.113.7"})
+	require.NoError(t, err)
+	assert.True(t, result.Created)
+	assert.Equal(t, "jane@example.com", result.User.Email)
+	assert.True(t, result.User.EmailVerified)
+	assert.Empty(t, result.User.Password)
+	assert.Regexp(t, `^jane_[0-9a-f]{6}$`, result.User.Username)
+	assert.NotEmpty(t, result.Tokens.AccessToken)
+	assert.Len(t, f.sessions.created, 1)
+
+	// The PKCE verifier sent to the token endpoint matches the challenge
+	sum := sha256.Sum256([]byte(f.stub.lastForm.Get("code_verifier")))
+	assert.Equal(t, challenge, base64.RawURLEncoding.EncodeToString(sum[:]))
+}
+
+func TestService_LinksExistingUserByVerifiedEmail(t *testing.T) {
+	f := newOAuthFixture(t)
+	existing := &model.User{ID: uuid.New(), Email: "jane@example.com", Password: "hash"}
+	f.users.byEmail[existing.Email] = existing
+	state, _ := f.start(t)
+
+	result, err := f.service.Callback(f.ctx, CallbackRequest{Provider: "google", State: state, Code: "auth-code"})
+	require.NoError(t, err)
+	assert.False(t, result.Created)
+	assert.Equal(t, existing.ID, result.User.ID)
+	assert.Equal(t, []uuid.UUID{existing.ID}, f.users.verified)
+	assert.True(t, result.User.EmailVerified)
+}
+
+func TestService_RejectsUnverifiedEmail(t *testing.T) {
+	f := newOAuthFixture(t)
+	f.stub.claims["email_verified"] = false
+	f.users.byEmail["jane@example.com"] = &model.User{ID: uuid.New(), Email: "jane@example.com"}
+	state, _ := f.start(t)
+
+	_, err := f.service.Callback(f.ctx, CallbackRequest{Provider: "google", State: state, Code: "auth-code"})
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+	assert.Empty(t, f.users.verified)
+}
+
+func TestService_StateIsSingleUseAndTenantBound(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	state, _ := f.start(t)
+	_, err := f.service.Callback(f.ctx, CallbackRequest{Provider: "google", State: state, Code: "auth-code"})
+	require.NoError(t, err)
+	_, err = f.service.Callback(f.ctx, CallbackRequest{Provider: "google", State: state, Code: "auth-code"})
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	state, _ = f.start(t)
+	otherTenant := tenant.WithID(context.Background(), uuid.New())
+	_, err = f.service.Callback(otherTenant, CallbackRequest{Provider: "google", State: state, Code: "auth-code"})
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	_, err = f.service.Callback(f.ctx, CallbackRequest{Provider: "google", State: "forged", Code: "auth-code"})
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
+
+func TestService_UnknownProvider(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	_, err := f.service.Start(f.ctx, "github")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
+
+// linkState begins a link flow for the user and returns its state
+func (f *oauthFixture) linkState(t *testing.T, userID uuid.UUID) string {
+	t.Helper()
+	redirect, err := f.service.StartLink(f.ctx, "google", userID)
+	require.NoError(t, err)
+	u, err := url.Parse(redirect)
+	require.NoError(t, err)
+	return u.Query().Get("state")
+}
+
+func (f *oauthFixture) addUser(email, password string) *model.User {
+	u := &model.User{ID: uuid.New(), Email: email, Password: password, EmailVerified: true}
+	f.users.byEmail[email] = u
+	return u
+}
+
+func TestService_SigninUsesLinkedIdentity(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	// The Google account is linked to a user with a different email, so
+	// email matching would have picked the wrong user
+	linked := f.addUser("jane.work@corp.test", "hash")
+	f.addUser("jane@example.com", "hash")
+	_, err := f.service.Link(f.ctx, LinkRequest{Provider: "google", State: f.linkState(t, linked.ID), Code: "c", UserID: linked.ID})
+	require.NoError(t, err)
+
+	// Even an unverified email signs in through an existing link
+	f.stub.claims["email_verified"] = false
+	state, _ := f.start(t)
+	result, err := f.service.Callback(f.ctx, CallbackRequest{Provider: "google", State: state, Code: "c"})
+	require.NoError(t, err)
+	assert.Equal(t, linked.ID, result.User.ID)
+}
+
+func TestService_FirstSigninCreatesIdentity(t *testing.T) {
+	f := newOAuthFixture(t)
+	state, _ := f.start(t)
+
+	result, err := f.service.Callback(f.ctx, CallbackRequest{Provider: "google", State: state, Code: "c"})
+	require.NoError(t, err)
+
+	require.Len(t, f.identities.rows, 1)
+	assert.Equal(t, result.User.ID, f.identities.rows[0].UserID)
+	assert.Equal(t, "10769150350006150715113082367", f.identities.rows[0].ProviderSubject)
+}
+
+func TestService_LinkConflict(t *testing.T) {
+	f := newOAuthFixture(t)
+	owner := f.addUser("owner@example.com", "hash")
+	other := f.addUser("other@example.com", "hash")
+
+	_, err := f.service.Link(f.ctx, LinkRequest{Provider: "google", State: f.linkState(t, owner.ID), Code: "c", UserID: owner.ID})
+	require.NoError(t, err)
+
+	// Linking again to the same user is a no-op
+	_, err = f.service.Link(f.ctx, LinkRequest{Provider: "google", State: f.linkState(t, owner.ID), Code: "c", UserID: owner.ID})
+	require.NoError(t, err)
+
+	_, err = f.service.Link(f.ctx, LinkRequest{Provider: "google", State: f.linkState(t, other.ID), Code: "c", UserID: other.ID})
+	assert.ErrorIs(t, err, ErrIdentityConflict)
+	assert.Len(t, f.identities.rows, 1)
+}
+
+func TestService_LinkStateBoundToUser(t *testing.T) {
+	f := newOAuthFixture(t)
+	victim := f.addUser("victim@example.com", "hash")
+	attacker := f.addUser("attacker@example.com", "hash")
+
+	_, err := f.service.Link(f.ctx, LinkRequest{Provider: "google", State: f.linkState(t, attacker.ID), Code: "c", UserID: victim.ID})
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	// A login state can't complete a link either
+	state, _ := f.start(t)
+	_, err = f.service.Link(f.ctx, LinkRequest{Provider: "google", State: state, Code: "c", UserID: victim.ID})
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
+
+func TestService_UnlinkLastMethodGuard(t *testing.T) {
+	f := newOAuthFixture(t)
+	state, _ := f.start(t)
+	result, err := f.service.Callback(f.ctx, CallbackRequest{Provider: "google", State: state, Code: "c"})
+	require.NoError(t, err)
+	require.Empty(t, result.User.Password)
+
+	err = f.service.Unlink(f.ctx, result.User.ID, "google")
+	assert.ErrorIs(t, err, ErrLastAuthMethod)
+	assert.Len(t, f.identities.rows, 1)
+
+	// Once a password is set, the identity can go
+	result.User.Password = "hash"
+	require.NoError(t, f.service.Unlink(f.ctx, result.User.ID, "google"))
+	assert.Empty(t, f.identities.rows)
+
+	assert.ErrorIs(t, f.service.Unlink(f.ctx, result.User.ID, "google"), ErrIdentityNotFound)
+}
+
+func TestService_UnlinkWithAnotherIdentity(t *testing.T) {
+	f := newOAuthFixture(t)
+	user := f.addUser("jane@example.com", "")
+	f.identities.rows = append(f.identities.rows, identity.Identity{UserID: user.ID, Provider: "github", ProviderSubject: "42"})
+	_, err := f.service.Link(f.ctx, LinkRequest{Provider: "google", State: f.linkState(t, user.ID), Code: "c", UserID: user.ID})
+	require.NoError(t, err)
+
+	require.NoError(t, f.service.Unlink(f.ctx, user.ID, "google"), "another identity still signs the user in")
+	assert.ErrorIs(t, f.service.Unlink(f.ctx, user.ID, "github"), ErrLastAuthMethod)
+}
@@ -0,0 +1,84 @@
+package passwordreset
+
+import (
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// ForgotPasswordRequest asks for a reset link, as JSON or a form post
+type ForgotPasswordRequest struct {
+	Email string `json:"email" form:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest sets a new password with a reset token, as JSON or
+// a form post
+type ResetPasswordRequest struct {
+	Token    string `json:"token" form:"token" validate:"required"`
+	Password string `json:"password" form:"password" validate:"required,min=8,max=128"`
+}
+
+// ForgotPasswordHandler emails a reset link. It always responds 202 so the
+// response doesn't reveal whether the email is registered.
+func ForgotPasswordHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		req, err := middleware.GetValidatedBody[ForgotPasswordRequest](c)
+		if err != nil {
+			return middleware.InternalErrorResponse(c, "validated body missing")
+		}
+
+		if err := service.RequestReset(middleware.GetRequestContext(c), req.Email); err != nil {
+			logger.Error("failed to request password reset", map[string]any{
+				"path":  c.Path(),
+				"error": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"message": "If the email is registered, a reset link has been sent",
+		})
+	}
+}
+
+// VerifyLinkHandler checks the signed reset link the email points to and
+// returns its token for the reset form. Tampered or expired links are
+// rejected before anything touches the database.
+func VerifyLinkHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		token, err := service.VerifyLink(c.OriginalURL())
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, ErrInvalidLink.Error())
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"valid": true,
+			"token": token,
+		})
+	}
+}
+
+// ResetPasswordHandler sets a new password using a reset token
+func ResetPasswordHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		req, err := middleware.GetValidatedBody[ResetPasswordRequest](c)
+		if err != nil {
+			return middleware.InternalErrorResponse(c, "validated body missing")
+		}
+
+		if err := service.ResetPassword(middleware.GetRequestContext(c), req.Token, req.Password); err != nil {
+			if IsClientError(err) {
+				return middleware.ValidationErrorResponse(c, err.Error())
+			}
+
+			logger.Error("failed to reset password", map[string]any{
+				"path":  c.Path(),
+				"error": err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to reset password", err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Password has been reset",
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package passwordreset
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newResetTestApp(service *Service) *fiber.App {
+	app := fiber.New()
+	app.Post("/forgot", middleware.ValidateBody[ForgotPasswordRequest](), ForgotPasswordHandler(service))
+	app.Post("/reset", middleware.ValidateBody[ResetPasswordRequest](), ResetPasswordHandler(service))
+	app.Get("/api/v1/auth/password/reset", VerifyLinkHandler(service))
+	return app
+}
+
+func postJSON(t *testing.T, app *fiber.App, path, body string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestForgotPasswordHandler_SameResponseForUnknownEmail(t *testing.T) {
+	f := newResetFixture()
+	app := newResetTestApp(f.service)
+
+	known := postJSON(t, app, "/forgot", `{"email":"user@example.com"}`)
+	unknown := postJSON(t, app, "/forgot", `{"email":"nobody@example.com"}`)
+
+	assert.Equal(t, http.StatusAccepted, known.StatusCode)
+	assert.Equal(t, http.StatusAccepted, unknown.StatusCode)
+	assert.Len(t, f.mailer.sent, 1)
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	f := newResetFixture()
+	app := newResetTestApp(f.service)
+	raw := f.requestToken(t)
+
+	resp := postJSON(t, app, "/reset", `{"token":"`+raw+`","password":"`+strongPassword+`"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = postJSON(t, app, "/reset", `{"token":"`+raw+`","password":"`+strongPassword+`"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestVerifyLinkHandler(t *testing.T) {
+	f := newResetFixture()
+	app := newResetTestApp(f.service)
+	link := f.requestLink(t)
+	before := len(f.store.tokens)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, link, nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Valid bool   `json:"valid"`
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.True(t, body.Valid)
+	assert.NotEmpty(t, body.Token)
+	assert.Len(t, f.store.tokens, before, "opening the link doesn't spend the token")
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, link+"&utm_source=scanner", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,206 @@
+package passwordreset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/domain/authentication/signup"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
+	"dvith.com/go-service-api/internal/security/onetime"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/mailer"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxOutstanding is how many unused reset tokens a user may hold; a new
+	// request invalidates the oldest beyond this
+	MaxOutstanding = 3
+	// CleanupJobName identifies the expired token cleanup in the jobs runner
+	CleanupJobName = "password_reset_cleanup"
+	// CleanupInterval is how often expired tokens are deleted
+	CleanupInterval = time.Hour
+	// LinkPath is where emailed reset links point; opening one verifies its
+	// signature and returns the token for the reset form
+	LinkPath = "/api/v1/auth/password/reset"
+)
+
+// Reset errors caused by the request rather than by the server
+var (
+	ErrInvalidToken = errors.New("invalid or expired reset token")
+	ErrInvalidLink  = errors.New("invalid or expired reset link")
+	ErrWeakPassword = signup.ErrWeakPassword
+)
+
+// IsClientError reports whether err from ResetPassword or VerifyLink
+// should be reported to the client as a bad request
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrInvalidLink) || errors.Is(err, ErrWeakPassword)
+}
+
+// LinkSigner signs the emailed reset links and checks them when opened.
+// signedurl.Signer implements it.
+type LinkSigner interface {
+	Sign(path string, params map[string]string, ttl time.Duration) (string, error)
+	Verify(rawURL string) (map[string]string, error)
+}
+
+// UserStore looks users up and changes their password
+type UserStore interface {
+	FindByEmail(ctx context.Context, email string, scope model.Scope) (*model.User, error)
+	SetPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error
+}
+
+// Service issues and redeems password reset tokens. A token is
+// "<id>.<secret>": the ID selects the row and only a SHA-256 of the secret
+// is stored, compared in constant time.
+type Service struct {
+	store     Store
+	users     UserStore
+	mailer    mailer.Mailer
+	ttl       time.Duration
+	links     LinkSigner
+	resetPath string
+	hasher    hashpassword.Hasher
+	now       func() time.Time
+}
+
+// NewService creates a password reset service. The emailed link is a
+// signed link to resetPath carrying the token, so opening it can be
+// checked without a database lookup.
+func NewService(store Store, users UserStore, m mailer.Mailer, ttl time.Duration, links LinkSigner, resetPath string) *Service {
+	return &Service{
+		store:     store,
+		users:     users,
+		mailer:    m,
+		ttl:       ttl,
+		links:     links,
+		resetPath: resetPath,
+		hasher:    hashpassword.Default,
+		now:       clock.Now,
+	}
+}
+
+// WithHasher replaces hashpassword.Default as the password hasher
+func (s *Service) WithHasher(h hashpassword.Hasher) *Service {
+	s.hasher = h
+	return s
+}
+
+// WithClock replaces the time source, for tests
+func (s *Service) WithClock(now func() time.Time) *Service {
+	s.now = now
+	return s
+}
+
+// RequestReset emails a reset link to the user with the given email. It
+// reports success for unknown emails so accounts can't be enumerated.
+func (s *Service) RequestReset(ctx context.Context, email string) error {
+	user, err := s.users.FindByEmail(ctx, email, model.ActiveOnly)
+	if err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+
+	raw, err := s.issue(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	link, err := s.links.Sign(s.resetPath, map[string]string{"token": raw}, s.ttl)
+	if err != nil {
+		return fmt.Errorf("failed to sign reset link: %w", err)
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can be used once.\n\n%s\n\nIf you didn't ask to reset your password, you can ignore this email.\n",
+			user.FullName, s.ttl, link),
+	}
+	// Reset links are critical email, sent whatever the user's preferences
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send reset email: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyLink checks a reset link opened from the email and returns its
+// token. Only the signature and expiry are checked: mail scanners that
+// preview links never reach the database, and the token stays unspent.
+func (s *Service) VerifyLink(rawURL string) (string, error) {
+	params, err := s.links.Verify(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidLink, err)
+	}
+	token := params["token"]
+	if token == "" {
+		return "", ErrInvalidLink
+	}
+	return token, nil
+}
+
+// ResetPassword sets a new password using a reset token. Changing the
+// password invalidates the user's other outstanding reset tokens.
+func (s *Service) ResetPassword(ctx context.Context, raw, password string) error {
+	id, secret, ok := onetime.ParseToken(raw)
+	if !ok {
+		return ErrInvalidToken
+	}
+
+	// Checked before consuming, so a weak password doesn't burn the token
+	if !signup.ValidatePasswordStrength(password).IsValid {
+		return ErrWeakPassword
+	}
+
+	rec, err := s.store.Consume(ctx, id, s.now(), func(tokenHash string) bool {
+		return onetime.HashMatches(tokenHash, secret)
+	})
+	if err != nil {
+		return err
+	}
+
+	hashed, err := s.hasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.users.SetPassword(ctx, rec.UserID, hashed); err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+
+	return nil
+}
+
+// issue stores a new token for the user and returns its raw form
+func (s *Service) issue(ctx context.Context, userID uuid.UUID) (string, error) {
+	secret, err := onetime.NewSecret()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	now := s.now()
+	rec := &Record{
+		ID:        uuid.New(),
+		UserID:    userID,
+		TokenHash: onetime.HashSecret(secret),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	if err := s.store.Create(ctx, rec, MaxOutstanding); err != nil {
+		return "", err
+	}
+
+	return onetime.Token(rec.ID, secret), nil
+}
+
+// NewCleanupJob creates the expired reset token cleanup job
+func NewCleanupJob(store Store) *onetime.CleanupJob {
+	return onetime.NewCleanupJob(CleanupJobName, "password reset tokens", store)
+}
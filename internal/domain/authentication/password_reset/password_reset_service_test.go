@@ -0,0 +1,279 @@
+package passwordreset
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/security/onetime"
+	"dvith.com/go-service-api/pkg/mailer"
+	"dvith.com/go-service-api/pkg/signedurl"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const strongPassword = "N3w-Passw0rd!"
+
+// memoryStore is an in-memory Store with the same single-use semantics as
+// the PostgreSQL repository
+type memoryStore struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]*Record
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{tokens: map[uuid.UUID]*Record{}}
+}
+
+func (m *memoryStore) Create(ctx context.Context, rec *Record, maxOutstanding int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cp := *rec
+	m.tokens[rec.ID] = &cp
+
+	var unused []*Record
+	for _, r := range m.tokens {
+		if r.UserID == rec.UserID && r.UsedAt == nil {
+			unused = append(unused, r)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool { return unused[i].CreatedAt.After(unused[j].CreatedAt) })
+	for _, r := range unused[min(maxOutstanding, len(unused)):] {
+		delete(m.tokens, r.ID)
+	}
+	return nil
+}
+
+func (m *memoryStore) Consume(ctx context.Context, id uuid.UUID, now time.Time, verify func(string) bool) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.tokens[id]
+	if !ok || r.UsedAt != nil || !r.ExpiresAt.After(now) || !verify(r.TokenHash) {
+		return nil, ErrInvalidToken
+	}
+	r.UsedAt = &now
+	cp := *r
+	return &cp, nil
+}
+
+func (m *memoryStore) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for id, r := range m.tokens {
+		if !r.ExpiresAt.After(cutoff) {
+			delete(m.tokens, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+type fakeUserStore struct {
+	user      *model.User
+	passwords []string
+}
+
+func (f *fakeUserStore) FindByEmail(ctx context.Context, email string, scope model.Scope) (*model.User, error) {
+	if f.user == nil || f.user.Email != email {
+		return nil, model.ErrUserNotFound
+	}
+	return f.user, nil
+}
+
+func (f *fakeUserStore) SetPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	f.passwords = append(f.passwords, passwordHash)
+	return nil
+}
+
+type fakeMailer struct {
+	sent []mailer.Message
+}
+
+func (f *fakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+type resetFixture struct {
+	service *Service
+	store   *memoryStore
+	users   *fakeUserStore
+	mailer  *fakeMailer
+	clock   *fakeClock
+}
+
+func newResetFixture() *resetFixture {
+	f := &resetFixture{
+		store: newMemoryStore(),
+		users: &fakeUserStore{user: &model.User{
+			ID:       uuid.New(),
+			Email:    "user@example.com",
+			FullName: "John Doe",
+		}},
+		mailer: &fakeMailer{},
+		clock:  &fakeClock{now: time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)},
+	}
+	links := signedurl.MustNew("https://example.com", "test-secret").WithClock(f.clock.Now)
+	f.service = NewService(f.store, f.users, f.mailer, 30*time.Minute, links, "/api/v1/auth/password/reset").WithClock(f.clock.Now)
+	return f
+}
+
+// requestLink asks for a reset and returns the link from the email
+func (f *resetFixture) requestLink(t *testing.T) string {
+	t.Helper()
+	require.NoError(t, f.service.RequestReset(context.Background(), "user@example.com"))
+	require.NotEmpty(t, f.mailer.sent)
+
+	body := f.mailer.sent[len(f.mailer.sent)-1].Body
+	i := strings.Index(body, "https://example.com/api/v1/auth/password/reset?")
+	require.NotEqual(t, -1, i, "email must contain a reset link: %s", body)
+	return strings.Fields(body[i:])[0]
+}
+
+// requestToken asks for a reset and returns the token from the email link
+func (f *resetFixture) requestToken(t *testing.T) string {
+	t.Helper()
+	raw, err := f.service.VerifyLink(f.requestLink(t))
+	require.NoError(t, err)
+	return raw
+}
+
+func TestRequestReset_StoresOnlyHash(t *testing.T) {
+	f := newResetFixture()
+	raw := f.requestToken(t)
+
+	require.Len(t, f.store.tokens, 1)
+	for _, rec := range f.store.tokens {
+		assert.NotContains(t, rec.TokenHash, raw)
+		_, secret, ok := onetime.ParseToken(raw)
+		require.True(t, ok)
+		assert.NotContains(t, rec.TokenHash, secret)
+		assert.Len(t, rec.TokenHash, 64)
+		assert.Equal(t, f.clock.now.Add(30*time.Minute), rec.ExpiresAt)
+	}
+}
+
+func TestVerifyLink_RejectsTamperedAndExpiredLinks(t *testing.T) {
+	f := newResetFixture()
+	link := f.requestLink(t)
+
+	_, err := f.service.VerifyLink(strings.Replace(link, "token=", "token=x", 1))
+	assert.ErrorIs(t, err, ErrInvalidLink)
+	assert.True(t, IsClientError(err))
+
+	f.clock.now = f.clock.now.Add(time.Hour)
+	_, err = f.service.VerifyLink(link)
+	assert.ErrorIs(t, err, ErrInvalidLink)
+}
+
+func TestRequestReset_UnknownEmail(t *testing.T) {
+	f := newResetFixture()
+
+	require.NoError(t, f.service.RequestReset(context.Background(), "nobody@example.com"))
+	assert.Empty(t, f.mailer.sent)
+	assert.Empty(t, f.store.tokens)
+}
+
+func TestResetPassword_Success(t *testing.T) {
+	f := newResetFixture()
+	raw := f.requestToken(t)
+
+	require.NoError(t, f.service.ResetPassword(context.Background(), raw, strongPassword))
+	require.Len(t, f.users.passwords, 1)
+	assert.NotEqual(t, strongPassword, f.users.passwords[0])
+}
+
+func TestResetPassword_Replay(t *testing.T) {
+	f := newResetFixture()
+	raw := f.requestToken(t)
+
+	require.NoError(t, f.service.ResetPassword(context.Background(), raw, strongPassword))
+	err := f.service.ResetPassword(context.Background(), raw, strongPassword)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Len(t, f.users.passwords, 1)
+}
+
+func TestResetPassword_Expired(t *testing.T) {
+	f := newResetFixture()
+	raw := f.requestToken(t)
+
+	f.clock.now = f.clock.now.Add(30 * time.Minute)
+	err := f.service.ResetPassword(context.Background(), raw, strongPassword)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Empty(t, f.users.passwords)
+}
+
+func TestResetPassword_WrongSecret(t *testing.T) {
+	f := newResetFixture()
+	raw := f.requestToken(t)
+	id, _, _ := onetime.ParseToken(raw)
+
+	err := f.service.ResetPassword(context.Background(), id.String()+".not-the-secret", strongPassword)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// A wrong guess must not burn the real token
+	require.NoError(t, f.service.ResetPassword(context.Background(), raw, strongPassword))
+}
+
+func TestResetPassword_WeakPasswordKeepsToken(t *testing.T) {
+	f := newResetFixture()
+	raw := f.requestToken(t)
+
+	err := f.service.ResetPassword(context.Background(), raw, "weakpassword")
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	assert.True(t, IsClientError(err))
+
+	require.NoError(t, f.service.ResetPassword(context.Background(), raw, strongPassword))
+}
+
+func TestResetPassword_Malformed(t *testing.T) {
+	f := newResetFixture()
+
+	for _, raw := range []string{"", "abc", "not-a-uuid.secret", uuid.NewString() + "."} {
+		err := f.service.ResetPassword(context.Background(), raw, strongPassword)
+		assert.ErrorIs(t, err, ErrInvalidToken, raw)
+	}
+}
+
+func TestRequestReset_CapsOutstandingTokens(t *testing.T) {
+	f := newResetFixture()
+
+	var tokens []string
+	for i := 0; i < MaxOutstanding+1; i++ {
+		tokens = append(tokens, f.requestToken(t))
+		f.clock.now = f.clock.now.Add(time.Second)
+	}
+
+	assert.Len(t, f.store.tokens, MaxOutstanding)
+	assert.ErrorIs(t, f.service.ResetPassword(context.Background(), tokens[0], strongPassword), ErrInvalidToken)
+	assert.NoError(t, f.service.ResetPassword(context.Background(), tokens[len(tokens)-1], strongPassword))
+}
+
+func TestCleanupJob_DeletesExpired(t *testing.T) {
+	f := newResetFixture()
+	f.requestToken(t)
+
+	job := NewCleanupJob(f.store).WithClock(func() time.Time { return f.clock.now })
+	require.NoError(t, job.Run(context.Background()))
+	assert.Len(t, f.store.tokens, 1)
+
+	job.WithClock(func() time.Time { return f.clock.now.Add(time.Hour) })
+	require.NoError(t, job.Run(context.Background()))
+	assert.Empty(t, f.store.tokens)
+	assert.Equal(t, CleanupJobName, job.Name())
+}
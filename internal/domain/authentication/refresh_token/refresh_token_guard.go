@@ -0,0 +1,139 @@
+package refreshtoken
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/clock"
+)
+
+// MaxTokenLength bounds the refresh tokens worth parsing. Real ones are a
+// few hundred bytes.
+const MaxTokenLength = 2048
+
+// WellFormed reports whether raw looks like a compact JWS: three
+// dot-separated, non-empty base64url segments within MaxTokenLength. It is
+// far cheaper than parsing, so garbage never reaches the JWT library.
+func WellFormed(raw string) bool {
+	if len(raw) == 0 || len(raw) > MaxTokenLength {
+		return false
+	}
+	segments := 0
+	for segment := range strings.SplitSeq(raw, ".") {
+		segments++
+		if segments > 3 || segment == "" {
+			return false
+		}
+		for i := 0; i < len(segment); i++ {
+			if !isBase64URL(segment[i]) {
+				return false
+			}
+		}
+	}
+	return segments == 3
+}
+
+func isBase64URL(b byte) bool {
+	return b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '-' || b == '_'
+}
+
+// Guard throttles refresh attempts per client IP over a fixed window. It
+// caps attempts overall, and refuses an IP outright once it has submitted
+// too many rejected tokens, so guessing tokens costs the server nothing
+// but a cache lookup.
+type Guard struct {
+	cache      cache.Cache
+	limit      int
+	maxInvalid int
+	window     time.Duration
+	now        func() time.Time
+}
+
+// guardWindow is one IP's counter for the current window
+type guardWindow struct {
+	mu      sync.Mutex
+	count   int
+	resetAt time.Time
+}
+
+// NewGuard allows limit refresh attempts and maxInvalid rejected tokens
+// per IP every window. A limit or maxInvalid of 0 turns that check off.
+func NewGuard(c cache.Cache, limit, maxInvalid int, window time.Duration) *Guard {
+	return &Guard{
+		cache:      c,
+		limit:      limit,
+		maxInvalid: maxInvalid,
+		window:     window,
+		now:        clock.Now,
+	}
+}
+
+// WithClock replaces the time source, for tests
+func (g *Guard) WithClock(now func() time.Time) *Guard {
+	g.now = now
+	return g
+}
+
+// Allow counts an attempt from ip and reports whether it may proceed.
+// When it may not, retryAfter is the time left in the window.
+func (g *Guard) Allow(ip string) (ok bool, retryAfter time.Duration) {
+	now := g.now()
+	if g.maxInvalid > 0 {
+		if count, resetAt := g.count("refresh_invalid:"+ip, now, false); count >= g.maxInvalid {
+			return false, resetAt.Sub(now)
+		}
+	}
+	if g.limit > 0 {
+		if count, resetAt := g.count("refresh_rate:"+ip, now, true); count > g.limit {
+			return false, resetAt.Sub(now)
+		}
+	}
+	return true, 0
+}
+
+// Rejected counts a token from ip that was malformed or failed validation
+func (g *Guard) Rejected(ip string) {
+	if g.maxInvalid > 0 {
+		g.count("refresh_invalid:"+ip, g.now(), true)
+	}
+}
+
+// count returns the events counted against key in the current window,
+// first adding one when add is set
+func (g *Guard) count(key string, now time.Time, add bool) (int, time.Time) {
+	if !add {
+		v, found := g.cache.Get(key)
+		if !found {
+			return 0, now
+		}
+		w := v.(*guardWindow)
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if !now.Before(w.resetAt) {
+			return 0, now
+		}
+		return w.count, w.resetAt
+	}
+
+	for {
+		g.cache.Add(key, &guardWindow{resetAt: now.Add(g.window)}, g.window)
+		v, found := g.cache.Get(key)
+		if !found {
+			// The window expired between Add and Get; open a new one
+			continue
+		}
+		w := v.(*guardWindow)
+		w.mu.Lock()
+		if !now.Before(w.resetAt) {
+			// Expired but not yet evicted by the cache
+			w.count = 0
+			w.resetAt = now.Add(g.window)
+		}
+		w.count++
+		count, resetAt := w.count, w.resetAt
+		w.mu.Unlock()
+		return count, resetAt
+	}
+}
@@ -0,0 +1,102 @@
+package refreshtoken
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/security/authmetrics"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/metrics"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWellFormed(t *testing.T) {
+	f := newRefreshFixture()
+	assert.True(t, WellFormed(f.signin(t)))
+	assert.True(t, WellFormed("a.b.c"))
+
+	for _, raw := range []string{
+		"",
+		"not-a-jwt",
+		"a.b",
+		"a.b.c.d",
+		"a..c",
+		"a.b.c=",
+		"a.b+c.d",
+		"a.b c.d",
+		"a.b." + strings.Repeat("c", MaxTokenLength),
+	} {
+		assert.False(t, WellFormed(raw), raw)
+	}
+}
+
+func TestRefreshTokenHandler_Guard(t *testing.T) {
+	f := newRefreshFixture()
+	m := authmetrics.New(metrics.NewRegistry())
+	now := time.Now()
+	guard := NewGuard(cache.NewMemory(), 100, 3, time.Minute).WithClock(func() time.Time { return now })
+	f.service.WithMetrics(m).WithGuard(guard)
+
+	// Trust the test client so X-Forwarded-For picks the caller's IP
+	app := fiber.New(fiber.Config{
+		ProxyHeader:      fiber.HeaderXForwardedFor,
+		TrustProxy:       true,
+		TrustProxyConfig: fiber.TrustProxyConfig{Proxies: []string{"0.0.0.0"}},
+	})
+	app.Post("/refresh-token", RefreshTokenHandler(f.service))
+	refresh := func(ip, raw string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/refresh-token", strings.NewReader(`{"refresh_token":"`+raw+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(fiber.HeaderXForwardedFor, ip)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	for range 3 {
+		assert.Equal(t, http.StatusUnauthorized, refresh("203.0.113.7", "garbage").StatusCode)
+	}
+	assert.Equal(t, float64(3), m.RefreshCount(authmetrics.ReasonMalformedToken))
+
+	// Even a valid token is refused once the IP is locked out
+	resp := refresh("203.0.113.7", f.signin(t))
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get(fiber.HeaderRetryAfter))
+	assert.Equal(t, float64(1), m.RefreshCount(authmetrics.ReasonLocked))
+	assert.Equal(t, float64(3), m.RefreshCount(authmetrics.ReasonMalformedToken), "locked out attempts are never inspected")
+
+	// Other IPs refresh as usual during the window
+	assert.Equal(t, http.StatusOK, refresh("198.51.100.20", f.signin(t)).StatusCode)
+
+	// The lockout lifts with the window
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, refresh("203.0.113.7", f.signin(t)).StatusCode)
+}
+
+func TestGuard_RateLimit(t *testing.T) {
+	now := time.Now()
+	guard := NewGuard(cache.NewMemory(), 2, 0, time.Minute).WithClock(func() time.Time { return now })
+
+	for range 2 {
+		ok, _ := guard.Allow("203.0.113.7")
+		assert.True(t, ok)
+	}
+	ok, retryAfter := guard.Allow("203.0.113.7")
+	assert.False(t, ok)
+	assert.Equal(t, time.Minute, retryAfter)
+
+	ok, _ = guard.Allow("198.51.100.20")
+	assert.True(t, ok, "limits are per IP")
+
+	// maxInvalid of 0 never locks out
+	for range 10 {
+		guard.Rejected("198.51.100.20")
+	}
+	ok, _ = guard.Allow("198.51.100.20")
+	assert.True(t, ok)
+}
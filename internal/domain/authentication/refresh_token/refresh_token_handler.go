@@ -1,67 +1,93 @@
 package refreshtoken
 
 import (
-	"dvith.com/go-service-api/internal/config"
+	"errors"
+	"math"
+	"strconv"
+
 	"dvith.com/go-service-api/internal/middleware"
-	"dvith.com/go-service-api/internal/security/token"
-	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/internal/requestmeta"
+	"dvith.com/go-service-api/internal/security/authmetrics"
 	"dvith.com/go-service-api/pkg/logger"
 	"github.com/gofiber/fiber/v3"
 )
 
 // RefreshTokenRequest represents a refresh token request
 type RefreshTokenRequest struct {
-	RefreshToken string `json:"refresh_token" validate:"required"`
+	RefreshToken string `json:"refresh_token" validate:"required" maxbytes:"4096"`
 }
 
 // RefreshTokenHandler handles refresh token requests
-func RefreshTokenHandler(db *database.DBPool, cfg config.Config) fiber.Handler {
-	tm := token.NewTokenManager(token.TokenConfig{
-		SecretKey:       cfg.JWTSecretKey,
-		ExpirationTime:  cfg.JWTExpirationTime,
-		RefreshDuration: cfg.JWTRefreshDuration,
-		Issuer:          cfg.JWTIssuer,
-	})
-
+func RefreshTokenHandler(service *RefreshService) fiber.Handler {
 	return func(c fiber.Ctx) error {
+		// Throttled IPs are turned away before the body is even read
+		ip := requestmeta.FromCtx(c).IP
+		if service.guard != nil {
+			if ok, retryAfter := service.guard.Allow(ip); !ok {
+				service.metrics.Refresh(authmetrics.ReasonLocked)
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				return middleware.TooManyRequestsResponse(c, "too many refresh attempts, try again later")
+			}
+		}
+
 		var req RefreshTokenRequest
 
 		// Parse request body
-		if err := c.Bind().Body(&req); err != nil {
+		if err := middleware.BindBody(c, &req); err != nil {
 			logger.Warn("invalid refresh token request", map[string]any{
 				"error": err.Error(),
 			})
+			service.metrics.Refresh(authmetrics.ReasonInvalid)
 			return middleware.ValidationErrorResponse(c, "invalid request body")
 		}
 
-		// Validate refresh token
-		claims, err := tm.ValidateRefreshToken(req.RefreshToken)
+		if req.RefreshToken == "" {
+			service.metrics.Refresh(authmetrics.ReasonMissingToken)
+			return middleware.ValidationErrorResponse(c, "refresh_token is required")
+		}
+		if errs := middleware.ValidateStruct(&req); len(errs) > 0 {
+			service.metrics.Refresh(authmetrics.ReasonInvalid)
+			return middleware.FieldErrorsResponse(c, errs)
+		}
+
+		// Rotate the refresh token and issue a new pair
+		meta := requestmeta.FromCtx(c)
+		pair, err := service.RefreshFrom(middleware.GetRequestContext(c), req.RefreshToken, Client{IP: meta.IP, UserAgent: meta.UserAgent})
+		service.metrics.Refresh(refreshReason(err))
 		if err != nil {
-			logger.Warn("invalid or expired refresh token", map[string]any{
+			if IsClientError(err) {
+				if service.guard != nil {
+					service.guard.Rejected(ip)
+				}
+				logger.Warn("refresh token rejected", map[string]any{
+					"error": err.Error(),
+				})
+				return middleware.AuthErrorResponse(c, "invalid or expired refresh token")
+			}
+
+			logger.Error("failed to refresh token", map[string]any{
+				"path":  c.Path(),
 				"error": err.Error(),
 			})
-			return middleware.AuthErrorResponse(c, "invalid or expired refresh token")
+			return middleware.InternalErrorResponse(c, "failed to refresh token", err)
 		}
 
-		// Generate new access token
-		newAccessToken, err := tm.GenerateAccessToken(claims.UserID)
+		return c.Status(fiber.StatusOK).JSON(pair)
+	}
+}
+
+// CompactHandler runs the refresh token retention on demand
+func CompactHandler(job *RetentionJob) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		result, err := job.Compact(middleware.GetRequestContext(c))
 		if err != nil {
-			logger.Error("failed to generate access token", map[string]any{
-				"user_id": claims.UserID.String(),
-				"error":   err.Error(),
-			})
-			return middleware.InternalErrorResponse(c, "failed to generate access token")
+			if errors.Is(err, ErrRetentionRunning) {
+				return middleware.ConflictResponse(c, "a refresh token retention run is already in progress")
+			}
+			logger.Error("manual refresh token retention failed", map[string]any{"error": err.Error()})
+			return middleware.InternalErrorResponse(c, "failed to delete expired refresh tokens", err)
 		}
 
-		logger.Info("refresh token used", map[string]any{
-			"user_id": claims.UserID.String(),
-		})
-
-		return c.Status(fiber.StatusOK).JSON(token.TokenPair{
-			AccessToken:  newAccessToken,
-			RefreshToken: req.RefreshToken, // Return same refresh token
-			TokenType:    "Bearer",
-			ExpiresIn:    int64(cfg.JWTExpirationTime.Seconds()),
-		})
+		return c.Status(fiber.StatusOK).JSON(result)
 	}
 }
@@ -0,0 +1,132 @@
+package refreshtoken
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/security/authmetrics"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/metrics"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshTokenHandler_ErrorClasses(t *testing.T) {
+	tm := token.NewTokenManager(token.TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  15 * time.Minute,
+		RefreshDuration: 24 * time.Hour,
+		Issuer:          "test",
+	})
+
+	app := fiber.New()
+	app.Post("/refresh-token", RefreshTokenHandler(NewRefreshService(NewMemoryStore(), tm, cache.NewMemory(), 10*time.Second)))
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "malformed body", body: `{`, want: http.StatusBadRequest},
+		{name: "missing token", body: `{}`, want: http.StatusBadRequest},
+		{name: "invalid token", body: `{"refresh_token":"not-a-jwt"}`, want: http.StatusUnauthorized},
+		{name: "number for token", body: `{"refresh_token":12345}`, want: http.StatusBadRequest},
+		{name: "oversized token", body: `{"refresh_token":"` + strings.Repeat("x", 4097) + `"}`, want: http.StatusBadRequest},
+		{name: "deeply nested", body: strings.Repeat("[", 1000), want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/refresh-token", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+}
+
+func TestRefreshTokenHandler_Metrics(t *testing.T) {
+	m := authmetrics.New(metrics.NewRegistry())
+	f := newRefreshFixture()
+	f.service.WithMetrics(m)
+
+	app := fiber.New()
+	app.Post("/refresh-token", RefreshTokenHandler(f.service))
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/refresh-token", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+	refresh := func(raw string) int { return post(`{"refresh_token":"` + raw + `"}`) }
+
+	expiring := token.NewTokenManager(token.TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  time.Minute,
+		RefreshDuration: time.Millisecond,
+		Issuer:          "test",
+	})
+	expired, err := expiring.GenerateTokenPair(uuid.New())
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	forged, err := token.NewTokenManager(token.TokenConfig{
+		SecretKey:       "another-secret-key",
+		ExpirationTime:  time.Minute,
+		RefreshDuration: time.Hour,
+		Issuer:          "test",
+	}).GenerateTokenPair(uuid.New())
+	require.NoError(t, err)
+
+	original := f.signin(t)
+	assert.Equal(t, http.StatusOK, refresh(original))
+	f.now = f.now.Add(11 * time.Second)
+	assert.Equal(t, http.StatusUnauthorized, refresh(original), "replay after the grace window")
+	assert.Equal(t, http.StatusUnauthorized, refresh(expired.RefreshToken))
+	assert.Equal(t, http.StatusUnauthorized, refresh(forged.RefreshToken))
+	assert.Equal(t, http.StatusUnauthorized, refresh("not-a-jwt"))
+	assert.Equal(t, http.StatusBadRequest, post(`{}`))
+
+	assert.Equal(t, float64(1), m.RefreshCount(authmetrics.ReasonNone))
+	assert.Equal(t, float64(1), m.RefreshCount(authmetrics.ReasonRevokedToken))
+	assert.Equal(t, float64(1), m.RefreshCount(authmetrics.ReasonExpiredToken))
+	assert.Equal(t, float64(1), m.RefreshCount(authmetrics.ReasonInvalidToken))
+	assert.Equal(t, float64(1), m.RefreshCount(authmetrics.ReasonMalformedToken))
+	assert.Equal(t, float64(1), m.RefreshCount(authmetrics.ReasonMissingToken))
+}
+
+func FuzzRefreshTokenHandler(f *testing.F) {
+	f.Add([]byte(`{"refresh_token":"not-a-jwt"}`))
+	f.Add([]byte(`{"refresh_token":"eyJhbGciOiJub25lIn0.eyJzdWIiOiJ4In0."}`))
+	f.Add([]byte(`{"refresh_token":["eyJ"]}`))
+	f.Add([]byte(`{"refresh_token":null,"extra":{"a":{"b":{}}}}`))
+
+	tm := token.NewTokenManager(token.TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  15 * time.Minute,
+		RefreshDuration: 24 * time.Hour,
+		Issuer:          "test",
+	})
+	app := fiber.New()
+	app.Post("/refresh-token", RefreshTokenHandler(NewRefreshService(NewMemoryStore(), tm, cache.NewMemory(), 10*time.Second)))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/refresh-token", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		// Nothing a client sends may look like a server failure
+		if resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("got status %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package refreshtoken
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is an in-memory Store for development mode and tests. A
+// single mutex stands in for the row lock, and changes are applied to
+// copies that are only kept if fn succeeds.
+type MemoryStore struct {
+	mu       sync.Mutex
+	records  map[string]*Record
+	sessions RevokedSessions
+}
+
+// RevokedSessions reports whether a session has been revoked
+type RevokedSessions interface {
+	IsRevoked(sessionID uuid.UUID) bool
+}
+
+// NewMemoryStore creates an empty in-memory refresh token store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*Record)}
+}
+
+// WithSessions makes Lock treat the tokens of sessions revoked in s as
+// revoked, as the Postgres store does through the sessions table
+func (m *MemoryStore) WithSessions(s RevokedSessions) *MemoryStore {
+	m.sessions = s
+	return m
+}
+
+// Lock implements Store
+func (m *MemoryStore) Lock(ctx context.Context, presented Record, fn func(tx FamilyTx) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	working := make(map[string]*Record, len(m.records)+1)
+	for k, r := range m.records {
+		cp := *r
+		working[k] = &cp
+	}
+
+	rec, ok := working[presented.TokenHash]
+	if !ok {
+		rec = &presented
+		rec.ID = uuid.New()
+		rec.FamilyID = uuid.New()
+		working[rec.TokenHash] = rec
+	}
+
+	tx := &memoryFamilyTx{records: working, rec: rec}
+	if m.sessions != nil && rec.SessionID != nil && rec.RevokedAt == nil && m.sessions.IsRevoked(*rec.SessionID) {
+		_ = tx.RevokeFamily(ctx, clock.Now())
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	m.records = working
+	return nil
+}
+
+type memoryFamilyTx struct {
+	records map[string]*Record
+	rec     *Record
+}
+
+func (f *memoryFamilyTx) Token() *Record {
+	return f.rec
+}
+
+func (f *memoryFamilyTx) Successor(ctx context.Context) (*Record, error) {
+	if f.rec.ReplacedBy == nil {
+		return nil, nil
+	}
+	for _, r := range f.records {
+		if r.ID == *f.rec.ReplacedBy {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *memoryFamilyTx) Rotate(ctx context.Context, next *Record) error {
+	next.FamilyID = f.rec.FamilyID
+	next.ParentID = &f.rec.ID
+	next.SessionID = f.rec.SessionID
+	f.records[next.TokenHash] = next
+
+	rotatedAt := next.IssuedAt
+	f.rec.RotatedAt = &rotatedAt
+	f.rec.ReplacedBy = &next.ID
+	return nil
+}
+
+func (f *memoryFamilyTx) RevokeFamily(ctx context.Context, at time.Time) error {
+	for _, r := range f.records {
+		if r.FamilyID == f.rec.FamilyID && r.RevokedAt == nil {
+			revokedAt := at
+			r.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+// DeleteExpired implements Store
+func (m *MemoryStore) DeleteExpired(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []*Record
+	for _, r := range m.records {
+		if r.ExpiresAt.Before(cutoff) {
+			expired = append(expired, r)
+		}
+	}
+	slices.SortFunc(expired, func(a, b *Record) int { return a.ExpiresAt.Compare(b.ExpiresAt) })
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+	for _, r := range expired {
+		delete(m.records, r.TokenHash)
+	}
+	return int64(len(expired)), nil
+}
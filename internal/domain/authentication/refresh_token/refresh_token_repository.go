@@ -0,0 +1,167 @@
+package refreshtoken
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Record is a row in the refresh_tokens table. Tokens are stored by hash
+// only, never in the clear.
+type Record struct {
+	ID         uuid.UUID  `db:"id"`
+	FamilyID   uuid.UUID  `db:"family_id"`
+	TenantID   *uuid.UUID `db:"tenant_id"`
+	UserID     uuid.UUID  `db:"user_id"`
+	SessionID  *uuid.UUID `db:"session_id"`
+	TokenHash  string     `db:"token_hash"`
+	ParentID   *uuid.UUID `db:"parent_id"`
+	ReplacedBy *uuid.UUID `db:"replaced_by"`
+	IssuedAt   time.Time  `db:"issued_at"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	RotatedAt  *time.Time `db:"rotated_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+// HashToken returns the value stored in token_hash for a raw refresh token
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// FamilyTx operates on one refresh token family while the presented
+// token's row is locked
+type FamilyTx interface {
+	// Token returns the locked record of the presented token
+	Token() *Record
+	// Successor returns the record that replaced the presented token, or
+	// nil if it has not been rotated
+	Successor(ctx context.Context) (*Record, error)
+	// Rotate records next as the replacement of the presented token
+	Rotate(ctx context.Context, next *Record) error
+	// RevokeFamily revokes every token in the presented token's family
+	RevokeFamily(ctx context.Context, at time.Time) error
+}
+
+// Store persists refresh token families
+type Store interface {
+	// Lock runs fn with the record for presented.TokenHash locked against
+	// concurrent refreshes. A token the store has never seen is adopted as
+	// the root of a new family using the fields of presented. Changes made
+	// through the FamilyTx commit only if fn returns nil. fn may run more
+	// than once, so it must reset whatever it reports back before starting.
+	Lock(ctx context.Context, presented Record, fn func(tx FamilyTx) error) error
+	// DeleteExpired deletes up to limit records that expired before
+	// cutoff, oldest first, and returns how many it deleted
+	DeleteExpired(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
+
+// Repository is the PostgreSQL Store
+type Repository struct {
+	db *database.DBPool
+}
+
+// NewRepository creates a new refresh token repository
+func NewRepository(db *database.DBPool) *Repository {
+	return &Repository{db: db}
+}
+
+const recordColumns = `id, family_id, tenant_id, user_id, session_id, token_hash, parent_id, replaced_by, issued_at, expires_at, rotated_at, revoked_at`
+
+// Lock implements Store. Concurrent refreshes of the same token serialize
+// on SELECT ... FOR UPDATE, so only one of them can rotate it. A deadlock
+// runs the transaction, fn included, again.
+func (repo *Repository) Lock(ctx context.Context, presented Record, fn func(tx FamilyTx) error) error {
+	return repo.db.WithTxRetry(ctx, database.TxRetryOptions{}, func(tx pgx.Tx) error {
+		// Tokens issued before tracking existed are adopted on first use.
+		// ON CONFLICT makes concurrent adoption of the same token safe. A
+		// token of an already revoked session is adopted revoked.
+		adopt := `
+			INSERT INTO refresh_tokens (id, family_id, tenant_id, user_id, session_id, token_hash, issued_at, expires_at, revoked_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, (SELECT revoked_at FROM sessions WHERE id = $5))
+			ON CONFLICT (token_hash) DO NOTHING
+		`
+		if _, err := tx.Exec(ctx, adopt, uuid.New(), uuid.New(), presented.TenantID, presented.UserID, presented.SessionID, presented.TokenHash, presented.IssuedAt.UTC(), presented.ExpiresAt.UTC()); err != nil {
+			return fmt.Errorf("failed to adopt refresh token: %w", err)
+		}
+
+		rec, err := database.QueryOne[Record](ctx, tx, `SELECT `+recordColumns+` FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, presented.TokenHash)
+		if err != nil {
+			return fmt.Errorf("failed to lock refresh token: %w", err)
+		}
+
+		return fn(&pgFamilyTx{tx: tx, rec: rec})
+	})
+}
+
+type pgFamilyTx struct {
+	tx  pgx.Tx
+	rec *Record
+}
+
+func (f *pgFamilyTx) Token() *Record {
+	return f.rec
+}
+
+func (f *pgFamilyTx) Successor(ctx context.Context) (*Record, error) {
+	if f.rec.ReplacedBy == nil {
+		return nil, nil
+	}
+	rec, err := database.QueryOne[Record](ctx, f.tx, `SELECT `+recordColumns+` FROM refresh_tokens WHERE id = $1`, *f.rec.ReplacedBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load successor refresh token: %w", err)
+	}
+	return rec, nil
+}
+
+func (f *pgFamilyTx) Rotate(ctx context.Context, next *Record) error {
+	next.FamilyID = f.rec.FamilyID
+	next.ParentID = &f.rec.ID
+	next.SessionID = f.rec.SessionID
+
+	insert := `
+		INSERT INTO refresh_tokens (id, family_id, tenant_id, user_id, session_id, token_hash, parent_id, issued_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	if _, err := f.tx.Exec(ctx, insert, next.ID, next.FamilyID, next.TenantID, next.UserID, next.SessionID, next.TokenHash, next.ParentID, next.IssuedAt.UTC(), next.ExpiresAt.UTC()); err != nil {
+		return fmt.Errorf("failed to insert rotated refresh token: %w", err)
+	}
+
+	if _, err := f.tx.Exec(ctx, `UPDATE refresh_tokens SET rotated_at = $2, replaced_by = $3 WHERE id = $1`, f.rec.ID, next.IssuedAt.UTC(), next.ID); err != nil {
+		return fmt.Errorf("failed to mark refresh token rotated: %w", err)
+	}
+
+	rotatedAt := next.IssuedAt
+	f.rec.RotatedAt = &rotatedAt
+	f.rec.ReplacedBy = &next.ID
+	return nil
+}
+
+func (f *pgFamilyTx) RevokeFamily(ctx context.Context, at time.Time) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL`
+	if _, err := f.tx.Exec(ctx, query, f.rec.FamilyID, at.UTC()); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired implements Store. Each call is one short DELETE, so a large
+// backlog is worked through in batches rather than one long lock.
+func (repo *Repository) DeleteExpired(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM refresh_tokens WHERE id IN (
+			SELECT id FROM refresh_tokens WHERE expires_at < $1 ORDER BY expires_at LIMIT $2
+		)
+	`
+	tag, err := repo.db.Exec(ctx, query, cutoff.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,57 @@
+package refreshtoken
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/internal/testdb"
+	"dvith.com/go-service-api/pkg/cache"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) { testdb.Main(m) }
+
+// defaultTenantID is the tenant seeded by the tenants migration
+var defaultTenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+func TestRepository_RevokedSessionStaysRevokedAfterCacheExpiry(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+
+	user, err := model.NewUserRepository(db).SaveUser(ctx, &model.User{Email: "alice@example.com", Password: "hash", Username: "alice", IsActive: true})
+	require.NoError(t, err)
+	sessions := session.NewRepository(db)
+	sess := &session.Session{UserID: user.ID}
+	require.NoError(t, sessions.Create(ctx, sess))
+
+	// Revocations outlive access tokens only, far shorter than refresh tokens
+	revocations := session.NewRevocations(cache.NewMemory(), time.Millisecond)
+	tm := token.NewTokenManager(token.TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  15 * time.Minute,
+		RefreshDuration: 24 * time.Hour,
+		Issuer:          "test",
+	}).WithSessionRevocations(revocations)
+	service := NewRefreshService(NewRepository(db), tm, cache.NewMemory(), 0)
+
+	pair, err := tm.GenerateTokenPair(user.ID, token.WithTenant(defaultTenantID), token.WithSession(sess.ID))
+	require.NoError(t, err)
+	// Rotated once, so the current token has a row of its own
+	pair, err = service.Refresh(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+
+	require.NoError(t, sessions.Revoke(ctx, user.ID, sess.ID))
+	revocations.MarkRevoked(sess.ID)
+	time.Sleep(5 * time.Millisecond)
+	require.False(t, revocations.IsRevoked(sess.ID), "the cache entry has expired")
+
+	_, err = service.Refresh(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, ErrTokenRevoked, "the session's refresh tokens were revoked with it")
+}
@@ -0,0 +1,130 @@
+package refreshtoken
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/metrics"
+)
+
+const (
+	// RetentionJobName identifies the refresh token retention in the jobs
+	// runner
+	RetentionJobName = "refresh_token_retention"
+	// RetentionInterval is how often expired refresh tokens are deleted
+	RetentionInterval = time.Hour
+	// DefaultRetentionBatchSize is how many rows each DELETE removes
+	DefaultRetentionBatchSize = 1000
+	// DefaultRetentionPause is the wait between batches, leaving room for
+	// other writes and autovacuum
+	DefaultRetentionPause = 100 * time.Millisecond
+)
+
+// ErrRetentionRunning is returned when a retention run is already in
+// progress
+var ErrRetentionRunning = errors.New("refresh token retention already running")
+
+var (
+	retentionDeleted  = metrics.NewCounter("refresh_token_retention_deleted_total", "Expired refresh tokens deleted by the retention job.")
+	retentionDuration = metrics.NewHistogram("refresh_token_retention_duration_seconds", "Time taken by refresh token retention runs.", metrics.DefaultBuckets)
+)
+
+// RetentionResult summarizes a retention run
+type RetentionResult struct {
+	Deleted int64     `json:"deleted"`
+	Batches int       `json:"batches"`
+	Cutoff  time.Time `json:"cutoff"`
+}
+
+// RetentionJob deletes refresh tokens that expired more than the retention
+// period ago, rotated and revoked ones included. Only expired rows go:
+// Lock adopts a token it has never seen as a new family, so deleting the
+// row of a token that still validates would let a replayed rotated token
+// in. Expired tokens fail validation before their row is looked up, so the
+// families still in use keep every row reuse detection needs.
+type RetentionJob struct {
+	store     Store
+	retention time.Duration
+	batchSize int
+	pause     time.Duration
+	now       func() time.Time
+	running   sync.Mutex
+}
+
+// NewRetentionJob creates the retention job for store
+func NewRetentionJob(store Store, retention time.Duration) *RetentionJob {
+	return &RetentionJob{
+		store:     store,
+		retention: retention,
+		batchSize: DefaultRetentionBatchSize,
+		pause:     DefaultRetentionPause,
+		now:       clock.Now,
+	}
+}
+
+// WithBatches replaces the batch size and the pause between batches
+func (j *RetentionJob) WithBatches(size int, pause time.Duration) *RetentionJob {
+	j.batchSize = max(size, 1)
+	j.pause = pause
+	return j
+}
+
+// WithClock replaces the time source for the cutoff, for tests
+func (j *RetentionJob) WithClock(now func() time.Time) *RetentionJob {
+	j.now = now
+	return j
+}
+
+// Name implements jobs.Job
+func (j *RetentionJob) Name() string { return RetentionJobName }
+
+// Run implements jobs.Job
+func (j *RetentionJob) Run(ctx context.Context) error {
+	_, err := j.Compact(ctx)
+	return err
+}
+
+// Compact deletes the expired refresh tokens a batch at a time until a
+// batch comes back short, pausing between batches
+func (j *RetentionJob) Compact(ctx context.Context) (*RetentionResult, error) {
+	if !j.running.TryLock() {
+		return nil, ErrRetentionRunning
+	}
+	defer j.running.Unlock()
+
+	started := time.Now()
+	defer func() { retentionDuration.Observe(time.Since(started).Seconds()) }()
+
+	result := &RetentionResult{Cutoff: j.now().UTC().Add(-j.retention)}
+	for {
+		n, err := j.store.DeleteExpired(ctx, result.Cutoff, j.batchSize)
+		result.Deleted += n
+		retentionDeleted.Add(float64(n))
+		if err != nil {
+			return result, err
+		}
+		result.Batches++
+		if n < int64(j.batchSize) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(j.pause):
+		}
+	}
+
+	if result.Deleted > 0 {
+		logger.Info("deleted expired refresh tokens", map[string]any{
+			"deleted": result.Deleted,
+			"batches": result.Batches,
+			"cutoff":  result.Cutoff.Format(time.RFC3339),
+		})
+	}
+	return result, nil
+}
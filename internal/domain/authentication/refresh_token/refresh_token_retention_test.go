@@ -0,0 +1,160 @@
+package refreshtoken
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seed stores a token record that expires at expiresAt, returning its hash
+func (m *MemoryStore) seed(expiresAt time.Time) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hash := HashToken(uuid.NewString())
+	rotatedAt := expiresAt.Add(-time.Hour)
+	m.records[hash] = &Record{
+		ID:        uuid.New(),
+		FamilyID:  uuid.New(),
+		UserID:    uuid.New(),
+		TokenHash: hash,
+		IssuedAt:  expiresAt.Add(-24 * time.Hour),
+		ExpiresAt: expiresAt,
+		RotatedAt: &rotatedAt,
+	}
+	return hash
+}
+
+func (m *MemoryStore) has(hash string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.records[hash]
+	return ok
+}
+
+func TestRetentionJob_RetentionMath(t *testing.T) {
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	job := NewRetentionJob(store, 30*24*time.Hour).WithClock(clock.NewFrozen(now).Now).WithBatches(10, 0)
+	cutoff := now.Add(-30 * 24 * time.Hour)
+
+	old := store.seed(cutoff.Add(-time.Second))
+	atCutoff := store.seed(cutoff)
+	recent := store.seed(now.Add(-time.Hour))
+	live := store.seed(now.Add(time.Hour))
+
+	deletedBefore, runsBefore := retentionDeleted.Value(), retentionDuration.Count()
+	result, err := job.Compact(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Deleted)
+	assert.Equal(t, float64(1), retentionDeleted.Value()-deletedBefore)
+	assert.Equal(t, uint64(1), retentionDuration.Count()-runsBefore)
+	assert.True(t, cutoff.Equal(result.Cutoff), result.Cutoff)
+
+	assert.False(t, store.has(old), "expired longer than the retention")
+	assert.True(t, store.has(atCutoff), "expired exactly the retention ago")
+	assert.True(t, store.has(recent), "expired within the retention")
+	assert.True(t, store.has(live))
+}
+
+func TestRetentionJob_Batches(t *testing.T) {
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	expired := now.Add(-31 * 24 * time.Hour)
+
+	tests := []struct {
+		rows, batchSize, batches int
+	}{
+		{rows: 0, batchSize: 2, batches: 1},
+		{rows: 3, batchSize: 2, batches: 2},
+		{rows: 4, batchSize: 2, batches: 3},
+		{rows: 5, batchSize: 10, batches: 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d rows by %d", tt.rows, tt.batchSize), func(t *testing.T) {
+			store := NewMemoryStore()
+			for i := range tt.rows {
+				store.seed(expired.Add(time.Duration(i) * time.Minute))
+			}
+			kept := store.seed(now)
+
+			job := NewRetentionJob(store, 30*24*time.Hour).WithClock(clock.NewFrozen(now).Now).WithBatches(tt.batchSize, 0)
+			result, err := job.Compact(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, int64(tt.rows), result.Deleted)
+			assert.Equal(t, tt.batches, result.Batches)
+			assert.True(t, store.has(kept))
+		})
+	}
+}
+
+func TestRetentionJob_OldestFirst(t *testing.T) {
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	oldest := store.seed(now.Add(-40 * 24 * time.Hour))
+	older := store.seed(now.Add(-35 * 24 * time.Hour))
+
+	deleted, err := store.DeleteExpired(context.Background(), now.Add(-30*24*time.Hour), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.False(t, store.has(oldest))
+	assert.True(t, store.has(older))
+}
+
+func TestRetentionJob_StopsWhenCanceled(t *testing.T) {
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	for range 3 {
+		store.seed(now.Add(-31 * 24 * time.Hour))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	job := NewRetentionJob(store, 30*24*time.Hour).WithClock(clock.NewFrozen(now).Now).WithBatches(1, time.Hour)
+	result, err := job.Compact(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(1), result.Deleted, "the pause after the first batch is cut short")
+}
+
+func TestRetentionJob_LeavesValidFamiliesIntact(t *testing.T) {
+	f := newRefreshFixture()
+	original := f.signin(t)
+	first, err := f.service.Refresh(context.Background(), original)
+	require.NoError(t, err)
+	f.now = f.now.Add(time.Minute)
+	second, err := f.service.Refresh(context.Background(), first.RefreshToken)
+	require.NoError(t, err)
+	store := f.store
+	for range 5 {
+		store.seed(time.Now().Add(-31 * 24 * time.Hour))
+	}
+
+	job := NewRetentionJob(store, 30*24*time.Hour).WithBatches(2, 0)
+	result, err := job.Compact(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), result.Deleted)
+	for _, raw := range []string{original, first.RefreshToken, second.RefreshToken} {
+		assert.True(t, store.has(HashToken(raw)), "rotated tokens of a live family are kept")
+	}
+
+	// Replaying a rotated token is still detected as reuse and revokes the
+	// family, rather than the token being adopted as a new one
+	f.now = f.now.Add(time.Minute)
+	_, err = f.service.Refresh(context.Background(), original)
+	assert.ErrorIs(t, err, ErrTokenReused)
+	_, err = f.service.Refresh(context.Background(), second.RefreshToken)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+}
+
+func TestRetentionJob_RejectsConcurrentRuns(t *testing.T) {
+	job := NewRetentionJob(NewMemoryStore(), time.Hour)
+	job.running.Lock()
+	defer job.running.Unlock()
+
+	_, err := job.Compact(context.Background())
+	assert.ErrorIs(t, err, ErrRetentionRunning)
+}
@@ -0,0 +1,269 @@
+package refreshtoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/events"
+	"dvith.com/go-service-api/internal/security/authmetrics"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Refresh errors caused by the presented token rather than by the server
+var (
+	ErrInvalidToken = errors.New("invalid or expired refresh token")
+	// ErrTokenRevoked is returned for tokens in a revoked family
+	ErrTokenRevoked = errors.New("refresh token has been revoked")
+	// ErrTokenReused is returned when an already-rotated token is presented
+	// outside the grace window; the whole family is revoked
+	ErrTokenReused = errors.New("refresh token reuse detected")
+	// ErrRotationConflict is returned for a duplicate refresh inside the
+	// grace window whose original response is not cached on this instance
+	ErrRotationConflict = errors.New("refresh token was just rotated")
+	// ErrMalformedToken is returned, without parsing, for input that can't
+	// be a JWT at all
+	ErrMalformedToken = fmt.Errorf("%w: malformed token", ErrInvalidToken)
+)
+
+// IsClientError reports whether err from Refresh should be reported to the
+// client as an authentication failure
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrInvalidToken) ||
+		errors.Is(err, ErrTokenRevoked) ||
+		errors.Is(err, ErrTokenReused) ||
+		errors.Is(err, ErrRotationConflict)
+}
+
+// refreshReason maps a Refresh error to its metrics reason
+func refreshReason(err error) string {
+	switch {
+	case err == nil:
+		return authmetrics.ReasonNone
+	case errors.Is(err, ErrMalformedToken):
+		return authmetrics.ReasonMalformedToken
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return authmetrics.ReasonExpiredToken
+	case errors.Is(err, ErrTokenRevoked), errors.Is(err, ErrTokenReused):
+		return authmetrics.ReasonRevokedToken
+	case IsClientError(err):
+		return authmetrics.ReasonInvalidToken
+	default:
+		return authmetrics.ReasonError
+	}
+}
+
+// rotation is the response cached per family for the grace window
+type rotation struct {
+	parentHash string
+	pair       token.TokenPair
+}
+
+// RefreshService rotates refresh tokens, detecting reuse of rotated tokens
+type RefreshService struct {
+	store        Store
+	tokenManager *token.TokenManager
+	cache        cache.Cache
+	grace        time.Duration
+	now          func() time.Time
+	metrics      *authmetrics.Metrics
+	guard        *Guard
+	events       *events.Bus
+}
+
+// Client is who presented a refresh token, recorded with the refresh
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// NewRefreshService creates a refresh service. grace is how long the token
+// a family was just rotated from is still accepted.
+func NewRefreshService(store Store, tokenManager *token.TokenManager, c cache.Cache, grace time.Duration) *RefreshService {
+	return &RefreshService{
+		store:        store,
+		tokenManager: tokenManager,
+		cache:        c,
+		grace:        grace,
+		now:          clock.Now,
+		metrics:      authmetrics.Default,
+	}
+}
+
+// WithMetrics replaces the metrics RefreshTokenHandler records refreshes on
+func (s *RefreshService) WithMetrics(m *authmetrics.Metrics) *RefreshService {
+	s.metrics = m
+	return s
+}
+
+// WithGuard makes RefreshTokenHandler throttle attempts per client IP
+func (s *RefreshService) WithGuard(g *Guard) *RefreshService {
+	s.guard = g
+	return s
+}
+
+// WithEvents publishes events.TokenRefreshed for every rotation
+func (s *RefreshService) WithEvents(bus *events.Bus) *RefreshService {
+	s.events = bus
+	return s
+}
+
+// WithClock replaces the time source, for tests
+func (s *RefreshService) WithClock(now func() time.Time) *RefreshService {
+	s.now = now
+	return s
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// token is rotated out; presenting it again within the grace window returns
+// the same pair, and presenting it later revokes its family.
+func (s *RefreshService) Refresh(ctx context.Context, raw string) (*token.TokenPair, error) {
+	return s.RefreshFrom(ctx, raw, Client{})
+}
+
+// RefreshFrom is Refresh for a refresh token presented by client
+func (s *RefreshService) RefreshFrom(ctx context.Context, raw string, client Client) (*token.TokenPair, error) {
+	if !WellFormed(raw) {
+		return nil, ErrMalformedToken
+	}
+	claims, err := s.tokenManager.ValidateRefreshToken(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	// Refresh tokens are only valid within the tenant that issued them
+	if tenantID, ok := tenant.IDFromContext(ctx); ok && claims.TenantID != tenantID {
+		return nil, fmt.Errorf("%w: issued for another tenant", ErrInvalidToken)
+	}
+
+	// The client profile may have been removed since the token was issued
+	if !s.tokenManager.HasClient(claims.ClientID) {
+		return nil, fmt.Errorf("%w: unknown client", ErrInvalidToken)
+	}
+
+	// A revoked session can't mint new tokens either
+	if err := s.tokenManager.CheckSession(claims.SessionID); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenRevoked, err)
+	}
+
+	pair, err := s.tokenManager.GenerateTokenPair(claims.UserID, token.WithTenant(claims.TenantID), token.WithClient(claims.ClientID), token.WithSession(claims.SessionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	var tenantID *uuid.UUID
+	if claims.TenantID != uuid.Nil {
+		tenantID = &claims.TenantID
+	}
+	var sessionID *uuid.UUID
+	if claims.SessionID != uuid.Nil {
+		sessionID = &claims.SessionID
+	}
+
+	presented := Record{
+		TenantID:  tenantID,
+		UserID:    claims.UserID,
+		SessionID: sessionID,
+		TokenHash: HashToken(raw),
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+
+	var result *token.TokenPair
+	var rejected error
+	var rotated bool
+	err = s.store.Lock(ctx, presented, func(tx FamilyTx) error {
+		// The store may run fn again after a deadlock
+		result, rejected, rotated = nil, nil, false
+		rec := tx.Token()
+		now := s.now()
+
+		if rec.RevokedAt != nil {
+			rejected = ErrTokenRevoked
+			return nil
+		}
+
+		if rec.RotatedAt == nil {
+			next := &Record{
+				ID:        uuid.New(),
+				TenantID:  tenantID,
+				UserID:    claims.UserID,
+				TokenHash: HashToken(pair.RefreshToken),
+				IssuedAt:  now,
+				ExpiresAt: now.Add(s.tokenManager.ClientRefreshTTL(claims.ClientID)),
+			}
+			if err := tx.Rotate(ctx, next); err != nil {
+				return err
+			}
+
+			// Cached before commit, so a concurrent duplicate waiting on the
+			// row lock finds it once the lock is released
+			if s.grace > 0 {
+				s.cache.Set(rotationKey(rec.FamilyID), rotation{parentHash: rec.TokenHash, pair: *pair}, s.grace)
+			}
+			result, rotated = pair, true
+			return nil
+		}
+
+		successor, err := tx.Successor(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Only the token the family was most recently rotated from is
+		// covered by the grace window
+		if now.Sub(*rec.RotatedAt) <= s.grace && successor != nil && successor.RotatedAt == nil && successor.RevokedAt == nil {
+			if v, ok := s.cache.Get(rotationKey(rec.FamilyID)); ok {
+				if r := v.(rotation); r.parentHash == rec.TokenHash {
+					result = &r.pair
+					return nil
+				}
+			}
+			rejected = ErrRotationConflict
+			return nil
+		}
+
+		if err := tx.RevokeFamily(ctx, now); err != nil {
+			return err
+		}
+		logger.Warn("refresh token reuse detected, family revoked", map[string]any{
+			"user_id":   claims.UserID.String(),
+			"family_id": rec.FamilyID.String(),
+		})
+		rejected = ErrTokenReused
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if rejected != nil {
+		return nil, rejected
+	}
+
+	if rotated {
+		s.events.Publish(ctx, events.TokenRefreshed{
+			UserID:         claims.UserID,
+			TenantID:       claims.TenantID,
+			SessionID:      claims.SessionID,
+			ClientID:       claims.ClientID,
+			ParentTokenID:  claims.TokenID(),
+			AccessTokenID:  pair.AccessTokenID,
+			RefreshTokenID: pair.RefreshTokenID,
+			IP:             client.IP,
+			UserAgent:      client.UserAgent,
+			At:             s.now(),
+		})
+	}
+	return result, nil
+}
+
+func rotationKey(familyID uuid.UUID) string {
+	return "refresh:rotation:" + familyID.String()
+}
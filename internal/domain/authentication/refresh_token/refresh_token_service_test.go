@@ -0,0 +1,206 @@
+package refreshtoken
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/events"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/pkg/cache"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// get returns the stored record for a raw refresh token
+func (m *MemoryStore) get(raw string) *Record {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.records[HashToken(raw)]
+}
+
+type refreshFixture struct {
+	service *RefreshService
+	store   *MemoryStore
+	tm      *token.TokenManager
+	now     time.Time
+}
+
+func newRefreshFixture() *refreshFixture {
+	f := &refreshFixture{
+		store: NewMemoryStore(),
+		tm: token.NewTokenManager(token.TokenConfig{
+			SecretKey:       "test-secret-key",
+			ExpirationTime:  15 * time.Minute,
+			RefreshDuration: 24 * time.Hour,
+			Issuer:          "test",
+		}),
+		now: time.Now(),
+	}
+	f.service = NewRefreshService(f.store, f.tm, cache.NewMemory(), 10*time.Second).
+		WithClock(func() time.Time { return f.now })
+	return f
+}
+
+func (f *refreshFixture) signin(t *testing.T) string {
+	pair, err := f.tm.GenerateTokenPair(uuid.New())
+	require.NoError(t, err)
+	return pair.RefreshToken
+}
+
+func TestRefresh_RotatesToken(t *testing.T) {
+	f := newRefreshFixture()
+	original := f.signin(t)
+
+	pair, err := f.service.Refresh(context.Background(), original)
+	require.NoError(t, err)
+	assert.NotEqual(t, original, pair.RefreshToken)
+
+	// The new token continues the same family
+	f.now = f.now.Add(time.Minute)
+	next, err := f.service.Refresh(context.Background(), pair.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, f.store.get(original).FamilyID, f.store.get(next.RefreshToken).FamilyID)
+}
+
+func TestRefresh_TokensAreNotFresh(t *testing.T) {
+	f := newRefreshFixture()
+	signin, err := f.tm.GenerateTokenPair(uuid.New(), token.WithFresh())
+	require.NoError(t, err)
+	claims, err := f.tm.ValidateAccessToken(signin.AccessToken)
+	require.NoError(t, err)
+	require.True(t, claims.Fresh)
+
+	pair, err := f.service.Refresh(context.Background(), signin.RefreshToken)
+	require.NoError(t, err)
+	claims, err = f.tm.ValidateAccessToken(pair.AccessToken)
+	require.NoError(t, err)
+	assert.False(t, claims.Fresh, "only password signin issues fresh tokens")
+}
+
+func TestRefreshFrom_PublishesTokenIDs(t *testing.T) {
+	f := newRefreshFixture()
+	bus := events.NewBus()
+	var refreshed []events.TokenRefreshed
+	events.On(bus, "test", func(ctx context.Context, e events.TokenRefreshed) error {
+		refreshed = append(refreshed, e)
+		return nil
+	})
+	f.service.WithEvents(bus)
+	original := f.signin(t)
+	parent, err := f.tm.ValidateRefreshToken(original)
+	require.NoError(t, err)
+
+	client := Client{IP: "203.0.113.7", UserAgent: "test"}
+	pair, err := f.service.RefreshFrom(context.Background(), original, client)
+	require.NoError(t, err)
+	// A replay within the grace window gets the same pair, not a new event
+	_, err = f.service.RefreshFrom(context.Background(), original, client)
+	require.NoError(t, err)
+
+	require.Len(t, refreshed, 1)
+	e := refreshed[0]
+	assert.Equal(t, parent.TokenID(), e.ParentTokenID)
+	assert.Equal(t, pair.AccessTokenID, e.AccessTokenID)
+	assert.Equal(t, pair.RefreshTokenID, e.RefreshTokenID)
+	assert.NotEqual(t, e.ParentTokenID, e.RefreshTokenID)
+	assert.Equal(t, client.IP, e.IP)
+}
+
+func TestRefresh_ConcurrentDoubleRefresh(t *testing.T) {
+	f := newRefreshFixture()
+	original := f.signin(t)
+
+	const callers = 2
+	pairs := make([]*token.TokenPair, callers)
+	errs := make([]error, callers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pairs[i], errs[i] = f.service.Refresh(context.Background(), original)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < callers; i++ {
+		require.NoError(t, errs[i])
+	}
+	assert.Equal(t, *pairs[0], *pairs[1], "both callers must receive the same rotated pair")
+	assert.Nil(t, f.store.get(original).RevokedAt, "a rotation race must not revoke the family")
+
+	// The shared pair keeps working
+	f.now = f.now.Add(time.Minute)
+	_, err := f.service.Refresh(context.Background(), pairs[0].RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestRefresh_ReplayAfterGraceRevokesFamily(t *testing.T) {
+	f := newRefreshFixture()
+	original := f.signin(t)
+
+	pair, err := f.service.Refresh(context.Background(), original)
+	require.NoError(t, err)
+
+	// An attacker replays the stolen token after the window
+	f.now = f.now.Add(11 * time.Second)
+	_, err = f.service.Refresh(context.Background(), original)
+	assert.ErrorIs(t, err, ErrTokenReused)
+
+	// The legitimate client's token dies with the family
+	_, err = f.service.Refresh(context.Background(), pair.RefreshToken)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	assert.NotNil(t, f.store.get(pair.RefreshToken).RevokedAt)
+}
+
+func TestRefresh_OlderAncestorInsideGraceIsReuse(t *testing.T) {
+	f := newRefreshFixture()
+	original := f.signin(t)
+
+	first, err := f.service.Refresh(context.Background(), original)
+	require.NoError(t, err)
+	_, err = f.service.Refresh(context.Background(), first.RefreshToken)
+	require.NoError(t, err)
+
+	// Only the immediately previous token is covered by the window
+	_, err = f.service.Refresh(context.Background(), original)
+	assert.ErrorIs(t, err, ErrTokenReused)
+}
+
+func TestRefresh_InvalidToken(t *testing.T) {
+	f := newRefreshFixture()
+
+	_, err := f.service.Refresh(context.Background(), "not-a-jwt")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.True(t, IsClientError(err))
+}
+
+type revokedSessions map[uuid.UUID]bool
+
+func (r revokedSessions) IsRevoked(sessionID uuid.UUID) bool {
+	return r[sessionID]
+}
+
+func TestRefresh_RevokedSessionRevokesFamily(t *testing.T) {
+	f := newRefreshFixture()
+	revoked := revokedSessions{}
+	f.store.WithSessions(revoked)
+
+	sessionID := uuid.New()
+	pair, err := f.tm.GenerateTokenPair(uuid.New(), token.WithSession(sessionID))
+	require.NoError(t, err)
+	next, err := f.service.Refresh(context.Background(), pair.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, sessionID, *f.store.get(next.RefreshToken).SessionID, "rotation keeps the session")
+
+	// Evicting the session revokes its tokens even once the access token
+	// revocation cache has forgotten it
+	revoked[sessionID] = true
+	_, err = f.service.Refresh(context.Background(), next.RefreshToken)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	assert.NotNil(t, f.store.get(pair.RefreshToken).RevokedAt)
+}
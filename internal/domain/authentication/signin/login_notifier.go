@@ -0,0 +1,174 @@
+package signin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/preferences"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/useragent"
+	"dvith.com/go-service-api/pkg/geoip"
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/mailer"
+	"github.com/google/uuid"
+)
+
+const (
+	// knownDeviceLookback is how many recent sessions a signin is compared against
+	knownDeviceLookback = 20
+	// notifyTimeout bounds the background check and email delivery
+	notifyTimeout = 30 * time.Second
+)
+
+// SessionHistory lists a user's recent sessions for the login notifier
+type SessionHistory interface {
+	ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]session.Session, error)
+}
+
+// WorkGate reports whether new background work may start, so nothing is
+// started once the process begins draining
+type WorkGate interface {
+	AcceptingWork() bool
+}
+
+// LoginNotifier emails users when they sign in from a device or network
+// that none of their recent sessions used
+type LoginNotifier struct {
+	sessions    SessionHistory
+	mailer      mailer.Mailer
+	geo         geoip.Resolver
+	sessionsURL string
+	gate        WorkGate
+	prefs       preferences.Getter
+	wg          sync.WaitGroup
+}
+
+// NewLoginNotifier creates a login notifier. sessionsURL is linked from the
+// email so the user can review and revoke sessions.
+func NewLoginNotifier(sessions SessionHistory, m mailer.Mailer, geo geoip.Resolver, sessionsURL string) *LoginNotifier {
+	return &LoginNotifier{
+		sessions:    sessions,
+		mailer:      m,
+		geo:         geo,
+		sessionsURL: sessionsURL,
+	}
+}
+
+// WithGate makes the notifier skip notifications the gate refuses
+func (n *LoginNotifier) WithGate(gate WorkGate) *LoginNotifier {
+	n.gate = gate
+	return n
+}
+
+// WithPreferences makes the notifier skip users who turned security emails
+// off. Notices are sent to everyone without it.
+func (n *LoginNotifier) WithPreferences(prefs preferences.Getter) *LoginNotifier {
+	n.prefs = prefs
+	return n
+}
+
+// NotifyAsync checks current against the user's recent sessions in the
+// background, so signin latency is unaffected. Failures are only logged.
+func (n *LoginNotifier) NotifyAsync(ctx context.Context, user *User, current *session.Session) {
+	if n.gate != nil && !n.gate.AcceptingWork() {
+		logger.Info("skipping login notification while draining", map[string]any{
+			"user_id": user.ID.String(),
+		})
+		return
+	}
+
+	// The request context is cancelled once the response is sent, but its
+	// values (tenant) are still needed
+	ctx = context.WithoutCancel(ctx)
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+
+		ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		defer cancel()
+
+		if err := n.notify(ctx, user, current); err != nil {
+			logger.Warn("failed to send login notification", map[string]any{
+				"user_id": user.ID.String(),
+				"error":   err.Error(),
+			})
+		}
+	}()
+}
+
+// Wait blocks until every pending notification has finished
+func (n *LoginNotifier) Wait() {
+	n.wg.Wait()
+}
+
+func (n *LoginNotifier) notify(ctx context.Context, user *User, current *session.Session) error {
+	recent, err := n.sessions.ListRecent(ctx, user.ID, knownDeviceLookback+1)
+	if err != nil {
+		return fmt.Errorf("failed to list recent sessions: %w", err)
+	}
+
+	prior := 0
+	for _, s := range recent {
+		if s.ID == current.ID {
+			continue
+		}
+		if s.Fingerprint == current.Fingerprint {
+			return nil
+		}
+		prior++
+	}
+
+	// A user's very first signin has nothing to compare against
+	if prior == 0 {
+		return nil
+	}
+
+	if !preferences.Allowed(ctx, n.prefs, user.ID, preferences.Security) {
+		return nil
+	}
+
+	location, err := n.geo.Locate(ctx, current.IP)
+	if err != nil {
+		logger.Warn("failed to resolve signin location", map[string]any{
+			"user_id": user.ID.String(),
+			"error":   err.Error(),
+		})
+		location = ""
+	}
+
+	if err := n.mailer.Send(ctx, n.message(user, current, location)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+func (n *LoginNotifier) message(user *User, current *session.Session, location string) mailer.Message {
+	valueOrUnknown := func(s string) string {
+		if s == "" {
+			return "Unknown"
+		}
+		return s
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hi %s,\n\n", user.FullName)
+	body.WriteString("Your account was just accessed from a new device or location.\n\n")
+	fmt.Fprintf(&body, "Time: %s\n", current.CreatedAt.UTC().Format(time.RFC1123))
+	fmt.Fprintf(&body, "IP address: %s\n", valueOrUnknown(current.IP))
+	fmt.Fprintf(&body, "Location: %s\n", valueOrUnknown(location))
+	agent := useragent.Parse(current.UserAgent)
+	fmt.Fprintf(&body, "Device: %s on %s (%s)\n\n", agent.Browser, agent.OS, agent.Device)
+	body.WriteString("If this was you, no action is needed. Otherwise, review your sessions and change your password:\n")
+	body.WriteString(n.sessionsURL + "\n")
+
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "New sign-in to your account",
+		Body:    body.String(),
+	}
+}
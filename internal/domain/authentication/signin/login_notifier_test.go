@@ -0,0 +1,176 @@
+package signin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/preferences"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/pkg/geoip"
+	"dvith.com/go-service-api/pkg/mailer"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *fakeMailer) messages() []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mailer.Message(nil), m.sent...)
+}
+
+type fakeSessionHistory struct {
+	sessions []session.Session
+	err      error
+	calls    int
+}
+
+func (h *fakeSessionHistory) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]session.Session, error) {
+	h.calls++
+	return h.sessions, h.err
+}
+
+type fakeResolver struct{}
+
+func (fakeResolver) Locate(ctx context.Context, ip string) (string, error) {
+	return "Bangkok, TH", nil
+}
+
+func newSession(userAgent, ip string) session.Session {
+	return session.Session{
+		ID:          uuid.New(),
+		IP:          ip,
+		UserAgent:   userAgent,
+		Fingerprint: session.Fingerprint(userAgent, ip),
+		CreatedAt:   time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC),
+	}
+}
+
+func notify(n *LoginNotifier, current session.Session) {
+	user := &User{ID: uuid.New(), Email: "john@example.com", FullName: "John Doe"}
+	n.NotifyAsync(context.Background(), user, &current)
+	n.Wait()
+}
+
+func TestLoginNotifier_NewDevice(t *testing.T) {
+	current := newSession("Mozilla/5.0 (X11; Linux x86_64) Firefox/131.0", "203.0.113.7")
+	history := &fakeSessionHistory{sessions: []session.Session{
+		current,
+		newSession("Mozilla/5.0 (iPhone) Safari/604.1", "198.51.100.2"),
+	}}
+	m := &fakeMailer{}
+
+	notify(NewLoginNotifier(history, m, fakeResolver{}, "https://example.com/api/v1/user/sessions"), current)
+
+	sent := m.messages()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "john@example.com", sent[0].To)
+	assert.Contains(t, sent[0].Body, "203.0.113.7")
+	assert.Contains(t, sent[0].Body, "Bangkok, TH")
+	assert.Contains(t, sent[0].Body, "Firefox on Linux (Desktop)")
+	assert.Contains(t, sent[0].Body, "Wed, 14 Oct 2026 09:30:00 UTC")
+	assert.Contains(t, sent[0].Body, "https://example.com/api/v1/user/sessions")
+}
+
+func TestLoginNotifier_KnownDeviceSuppressed(t *testing.T) {
+	current := newSession("Mozilla/5.0 (X11; Linux x86_64) Firefox/131.0", "203.0.113.7")
+	previous := newSession(current.UserAgent, current.IP)
+	history := &fakeSessionHistory{sessions: []session.Session{current, previous}}
+	m := &fakeMailer{}
+
+	notify(NewLoginNotifier(history, m, geoip.NewNoopResolver(), ""), current)
+
+	assert.Empty(t, m.messages())
+	assert.Equal(t, 1, history.calls)
+}
+
+func TestLoginNotifier_FirstSigninSuppressed(t *testing.T) {
+	current := newSession("curl/8.5.0", "203.0.113.7")
+	m := &fakeMailer{}
+
+	notify(NewLoginNotifier(&fakeSessionHistory{sessions: []session.Session{current}}, m, geoip.NewNoopResolver(), ""), current)
+
+	assert.Empty(t, m.messages())
+}
+
+func TestLoginNotifier_UnknownLocation(t *testing.T) {
+	current := newSession("curl/8.5.0", "203.0.113.7")
+	history := &fakeSessionHistory{sessions: []session.Session{newSession("curl/8.4.0", "203.0.113.7")}}
+	m := &fakeMailer{}
+
+	notify(NewLoginNotifier(history, m, geoip.NewNoopResolver(), ""), current)
+
+	sent := m.messages()
+	require.Len(t, sent, 1)
+	assert.Contains(t, sent[0].Body, "Location: Unknown")
+}
+
+type closedGate struct{}
+
+func (closedGate) AcceptingWork() bool { return false }
+
+func TestLoginNotifier_SkippedWhileDraining(t *testing.T) {
+	current := newSession("curl/8.5.0", "203.0.113.7")
+	history := &fakeSessionHistory{sessions: []session.Session{newSession("curl/8.4.0", "198.51.100.2")}}
+	m := &fakeMailer{}
+
+	notify(NewLoginNotifier(history, m, geoip.NewNoopResolver(), "").WithGate(closedGate{}), current)
+
+	assert.Empty(t, m.messages())
+	assert.Zero(t, history.calls, "no background work starts while draining")
+}
+
+type fixedPreferences struct {
+	prefs preferences.Preferences
+}
+
+func (f fixedPreferences) Get(ctx context.Context, userID uuid.UUID) (*preferences.Preferences, error) {
+	return &f.prefs, nil
+}
+
+func TestLoginNotifier_RespectsSecurityEmailPreference(t *testing.T) {
+	current := newSession("curl/8.5.0", "203.0.113.7")
+	history := &fakeSessionHistory{sessions: []session.Session{current, newSession("curl/8.4.0", "198.51.100.2")}}
+
+	optedOut := &fakeMailer{}
+	notify(NewLoginNotifier(history, optedOut, geoip.NewNoopResolver(), "").WithPreferences(fixedPreferences{preferences.Preferences{SecurityEmails: false}}), current)
+	assert.Empty(t, optedOut.messages())
+
+	optedIn := &fakeMailer{}
+	notify(NewLoginNotifier(history, optedIn, geoip.NewNoopResolver(), "").WithPreferences(fixedPreferences{preferences.Defaults(uuid.New())}), current)
+	assert.Len(t, optedIn.messages(), 1)
+}
+
+func TestSigninHandler_NotificationFailureDoesNotFailSignin(t *testing.T) {
+	user := newSigninUser(t)
+	history := &fakeSessionHistory{err: errors.New("conn closed")}
+	m := &fakeMailer{err: errors.New("smtp unavailable")}
+	notifier := NewLoginNotifier(history, m, geoip.NewNoopResolver(), "")
+
+	resp, _ := postSignin(t, newSigninTestAppWithNotifier(&stubRepository{user: user}, notifier), "SecurePass123!")
+	notifier.Wait()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, history.calls)
+	assert.Empty(t, m.messages())
+}
@@ -0,0 +1,82 @@
+package signin
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pwnedPasswordsEndpoint is the Pwned Passwords range API. It is queried
+// with the first five hex characters of the password's SHA-1, so neither
+// the password nor its full hash leaves the service.
+const pwnedPasswordsEndpoint = "https://api.pwnedpasswords.com/range/"
+
+// DefaultBreachTimeout bounds the breach check of one signin
+const DefaultBreachTimeout = 500 * time.Millisecond
+
+// BreachChecker reports whether a password appears in known breaches
+type BreachChecker interface {
+	Breached(ctx context.Context, password string) (bool, error)
+}
+
+// PwnedPasswordsChecker checks passwords against Pwned Passwords using its
+// k-anonymity range API
+type PwnedPasswordsChecker struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewPwnedPasswordsChecker creates a checker against the public API
+func NewPwnedPasswordsChecker() *PwnedPasswordsChecker {
+	return &PwnedPasswordsChecker{
+		endpoint: pwnedPasswordsEndpoint,
+		client:   &http.Client{},
+	}
+}
+
+// WithEndpoint replaces the range API URL, for tests
+func (c *PwnedPasswordsChecker) WithEndpoint(endpoint string) *PwnedPasswordsChecker {
+	c.endpoint = endpoint
+	return c
+}
+
+// Breached implements BreachChecker. The API answers with every hash
+// suffix sharing the prefix and its breach count; padded entries have a
+// count of zero.
+func (c *PwnedPasswordsChecker) Breached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+prefix, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to build breach check request: %w", err)
+	}
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to check password breaches: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("breach check returned status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if ok && strings.EqualFold(candidate, suffix) {
+			return count != "0", nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read breach check response: %w", err)
+	}
+	return false, nil
+}
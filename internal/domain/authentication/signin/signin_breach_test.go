@@ -0,0 +1,43 @@
+package signin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPwnedPasswordsChecker(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	var prefix string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix = r.URL.Path[len("/range/"):]
+		assert.Equal(t, "true", r.Header.Get("Add-Padding"))
+		fmt.Fprint(w, "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9659365\r\n0000000000000000000000000000000000A:0\r\n")
+	}))
+	defer srv.Close()
+	checker := NewPwnedPasswordsChecker().WithEndpoint(srv.URL + "/range/")
+
+	breached, err := checker.Breached(context.Background(), "password")
+	require.NoError(t, err)
+	assert.True(t, breached)
+	assert.Equal(t, "5BAA6", prefix, "only the hash prefix is sent")
+
+	breached, err = checker.Breached(context.Background(), "correct horse battery staple 42")
+	require.NoError(t, err)
+	assert.False(t, breached)
+}
+
+func TestPwnedPasswordsChecker_Outage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, err := NewPwnedPasswordsChecker().WithEndpoint(srv.URL+"/").Breached(context.Background(), "password")
+	assert.Error(t, err)
+}
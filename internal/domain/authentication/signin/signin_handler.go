@@ -1,65 +1,96 @@
 package signin
 
 import (
-	"dvith.com/go-service-api/internal/config"
-	"dvith.com/go-service-api/internal/security/token"
-	"dvith.com/go-service-api/pkg/database"
+	"encoding/xml"
+	"errors"
+
+	"dvith.com/go-service-api/internal/domain/user/dto"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/requestmeta"
+	"dvith.com/go-service-api/pkg/logger"
 	"github.com/gofiber/fiber/v3"
 )
 
+// CodePasswordResetRequired is the error code of a signin refused until the
+// user resets their password
+const CodePasswordResetRequired = "password_reset_required"
+
+// SigninSuccessResponse is the body of a successful signin
+type SigninSuccessResponse struct {
+	XMLName      xml.Name    `json:"-" xml:"signin"`
+	Message      string      `json:"message" xml:"message"`
+	User         dto.UserDTO `json:"user" xml:"user"`
+	AccessToken  string      `json:"access_token" xml:"access_token"`
+	RefreshToken string      `json:"refresh_token" xml:"refresh_token"`
+	TokenType    string      `json:"token_type" xml:"token_type"`
+	ExpiresIn    int64       `json:"expires_in" xml:"expires_in"`
+	// Security is omitted when no password check ran
+	Security *SecurityNotices `json:"security,omitempty" xml:"security,omitempty"`
+}
+
 // SigninHandler handles user signin requests
-func SigninHandler(db *database.DBPool, cfg config.Config) fiber.Handler {
+func SigninHandler(service *SigninService) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		// Parse signin request
 		var req SigninRequest
-		if err := c.Bind().Body(&req); err != nil {
+		if err := middleware.BindBody(c, &req); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Invalid request body",
 			})
 		}
 
+		meta := requestmeta.FromCtx(c)
+		req.IP, req.UserAgent = meta.IP, meta.UserAgent
+
 		// Validate request fields
 		validationErrors := ValidateSigninRequest(&req)
 		if len(validationErrors) > 0 {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			body := fiber.Map{
 				"error":  "Validation failed",
-				"errors": validationErrors,
-			})
+				"fields": validationErrors,
+			}
+			if legacy := middleware.LegacyFieldErrors(c, validationErrors); legacy != nil {
+				body["errors"] = legacy
+			}
+			return c.Status(fiber.StatusBadRequest).JSON(body)
 		}
 
-		// Create repository and service with token manager
-		repo := NewSigninRepository(db)
-		tokenManager := token.NewTokenManager(token.TokenConfig{
-			SecretKey:       cfg.JWTSecretKey,
-			ExpirationTime:  cfg.JWTExpirationTime,
-			RefreshDuration: cfg.JWTRefreshDuration,
-			Issuer:          cfg.JWTIssuer,
-		})
-		service := NewSigninService(repo, tokenManager)
-
 		// Login user and generate tokens
-		response, err := service.LoginUser(c.Context(), &req)
+		response, err := service.LoginUser(middleware.GetRequestContext(c), &req)
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			if errors.Is(err, ErrPasswordResetRequired) {
+				return middleware.Respond(c, fiber.StatusForbidden, middleware.ErrorResponse{
+					Error:   CodePasswordResetRequired,
+					Message: err.Error(),
+					Code:    fiber.StatusForbidden,
+				})
+			}
+			if errors.Is(err, ErrSessionLimit) {
+				return middleware.ConflictResponse(c, err.Error())
+			}
+			if IsClientError(err) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+
+			// Infrastructure failures are logged but never exposed to the client
+			logger.Error("failed to login user", map[string]any{
+				"path":  c.Path(),
 				"error": err.Error(),
 			})
+			return middleware.InternalErrorResponse(c, "failed to login user", err)
 		}
 
 		// Return success response with user data and tokens
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"message": "User logged in successfully",
-			"user": fiber.Map{
-				"id":        response.User.ID,
-				"email":     response.User.Email,
-				"fullName":  response.User.FullName,
-				"username":  response.User.Username,
-				"isActive":  response.User.IsActive,
-				"createdAt": response.User.CreatedAt,
-			},
-			"access_token":  response.AccessToken,
-			"refresh_token": response.RefreshToken,
-			"token_type":    response.TokenType,
-			"expires_in":    response.ExpiresIn,
+		return middleware.OK(c, SigninSuccessResponse{
+			Message:      "User logged in successfully",
+			User:         dto.FromUser(response.User),
+			AccessToken:  response.AccessToken,
+			RefreshToken: response.RefreshToken,
+			TokenType:    response.TokenType,
+			ExpiresIn:    response.ExpiresIn,
+			Security:     response.Security,
 		})
 	}
 }
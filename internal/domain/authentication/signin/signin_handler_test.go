@@ -0,0 +1,228 @@
+package signin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/security/authmetrics"
+	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/pkg/metrics"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/shamaton/msgpack/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubRepository struct {
+	user     *User
+	err      error
+	email    string
+	rehashed string
+}
+
+func (r *stubRepository) FindUser(ctx context.Context, email string) (*User, error) {
+	r.email = email
+	return r.user, r.err
+}
+
+func (r *stubRepository) CreateSession(ctx context.Context, s *session.Session, limit session.Limit) ([]uuid.UUID, error) {
+	s.ID = uuid.New()
+	s.Fingerprint = session.Fingerprint(s.UserAgent, s.IP)
+	s.CreatedAt = time.Now()
+	return nil, nil
+}
+
+func (r *stubRepository) RehashPassword(ctx context.Context, userID uuid.UUID, oldHash, newHash string) error {
+	r.rehashed = newHash
+	return nil
+}
+
+func newSigninService(repo Repository) *SigninService {
+	tm := token.NewTokenManager(token.TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  15 * time.Minute,
+		RefreshDuration: 24 * time.Hour,
+		Issuer:          "test",
+	})
+	return NewSigninService(repo, tm)
+}
+
+func newSigninTestApp(repo Repository) *fiber.App {
+	app := fiber.New()
+	app.Post("/signin", SigninHandler(newSigninService(repo)))
+	return app
+}
+
+func newSigninTestAppWithNotifier(repo Repository, n *LoginNotifier) *fiber.App {
+	app := fiber.New()
+	app.Post("/signin", SigninHandler(newSigninService(repo).WithNotifier(n)))
+	return app
+}
+
+func newSigninUser(t *testing.T) *User {
+	hashed, err := hashpassword.HashPassword("SecurePass123!")
+	require.NoError(t, err)
+	return &User{ID: uuid.New(), Email: "john@example.com", Password: hashed}
+}
+
+func postSignin(t *testing.T, app *fiber.App, password string) (*http.Response, string) {
+	body, _ := json.Marshal(SigninRequest{Email: "john@example.com", Password: password})
+	req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(raw)
+}
+
+func TestSigninHandler_InfrastructureFailure(t *testing.T) {
+	repo := &stubRepository{err: errors.New("failed to find user: conn closed")}
+
+	resp, body := postSignin(t, newSigninTestApp(repo), "SecurePass123!")
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Contains(t, body, "failed to login user")
+	assert.NotContains(t, body, "conn closed", "underlying error must not leak to the client")
+}
+
+func TestSigninHandler_NormalizesEmail(t *testing.T) {
+	repo := &stubRepository{user: newSigninUser(t)}
+	body, _ := json.Marshal(SigninRequest{Email: " john@example.com\u200b\t", Password: "SecurePass123!"})
+	req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := newSigninTestApp(repo).Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "john@example.com", repo.email, "looked up the way signup stored it")
+}
+
+func TestSigninHandler_UnknownClient(t *testing.T) {
+	body, _ := json.Marshal(SigninRequest{Email: "john@example.com", Password: "SecurePass123!", ClientID: "desktop"})
+	req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := newSigninTestApp(&stubRepository{}).Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSigninHandler_ResponseEncodings(t *testing.T) {
+	user := newSigninUser(t)
+	app := newSigninTestApp(&stubRepository{user: user})
+
+	signin := func(accept string) (*http.Response, []byte) {
+		body, _ := json.Marshal(SigninRequest{Email: "john@example.com", Password: "SecurePass123!"})
+		req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", accept)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(raw))
+		return resp, raw
+	}
+
+	t.Run("json", func(t *testing.T) {
+		resp, raw := signin("application/json")
+		assert.Contains(t, resp.Header.Get("Content-Type"), "application/json")
+		var out SigninSuccessResponse
+		require.NoError(t, json.Unmarshal(raw, &out))
+		assert.Equal(t, user.ID, out.User.ID)
+		assert.NotEmpty(t, out.AccessToken)
+		assert.Equal(t, "Bearer", out.TokenType)
+	})
+
+	t.Run("xml", func(t *testing.T) {
+		resp, raw := signin("application/xml")
+		assert.Contains(t, resp.Header.Get("Content-Type"), "application/xml")
+		assert.Contains(t, string(raw), "<signin><message>")
+		var out SigninSuccessResponse
+		require.NoError(t, xml.Unmarshal(raw, &out))
+		assert.Equal(t, user.ID, out.User.ID)
+		assert.Equal(t, user.Email, out.User.Email)
+		assert.NotEmpty(t, out.AccessToken)
+		assert.NotEmpty(t, out.RefreshToken)
+		assert.Positive(t, out.ExpiresIn)
+	})
+
+	t.Run("msgpack", func(t *testing.T) {
+		resp, raw := signin("application/msgpack")
+		assert.Equal(t, "application/msgpack", resp.Header.Get("Content-Type"))
+		var out map[string]any
+		require.NoError(t, msgpack.Unmarshal(raw, &out))
+		assert.NotEmpty(t, out["access_token"])
+		assert.Equal(t, "Bearer", out["token_type"])
+		assert.EqualValues(t, 900, out["expires_in"])
+		u, ok := out["user"].(map[any]any)
+		require.True(t, ok, "user is %T", out["user"])
+		assert.Equal(t, user.ID.String(), u["id"])
+	})
+
+	t.Run("unsupported falls back to json", func(t *testing.T) {
+		resp, raw := signin("text/csv")
+		assert.Contains(t, resp.Header.Get("Content-Type"), "application/json")
+		assert.True(t, json.Valid(raw))
+	})
+}
+
+func TestLoginUser_Metrics(t *testing.T) {
+	m := authmetrics.New(metrics.NewRegistry())
+	user := newSigninUser(t)
+	login := func(repo Repository, req SigninRequest) {
+		_, _ = newSigninService(repo).WithMetrics(m).LoginUser(context.Background(), &req)
+	}
+
+	login(&stubRepository{user: user}, SigninRequest{Email: user.Email, Password: "SecurePass123!"})
+	login(&stubRepository{user: user}, SigninRequest{Email: user.Email, Password: "WrongPass123!"})
+	login(&stubRepository{}, SigninRequest{Email: "nobody@example.com", Password: "SecurePass123!"})
+	login(&stubRepository{}, SigninRequest{Email: user.Email, Password: "SecurePass123!", ClientID: "desktop"})
+	login(&stubRepository{err: errors.New("conn closed")}, SigninRequest{Email: user.Email, Password: "SecurePass123!"})
+
+	assert.Equal(t, float64(1), m.SigninCount(authmetrics.ReasonNone))
+	assert.Equal(t, float64(1), m.SigninCount(authmetrics.ReasonBadPassword))
+	assert.Equal(t, float64(1), m.SigninCount(authmetrics.ReasonUnknownUser))
+	assert.Equal(t, float64(1), m.SigninCount(authmetrics.ReasonUnknownClient))
+	assert.Equal(t, float64(1), m.SigninCount(authmetrics.ReasonError))
+	assert.Equal(t, uint64(2), m.PasswordVerifications(), "only found users have their password checked")
+}
+
+func TestSigninHandler_RejectsHostileBodies(t *testing.T) {
+	app := newSigninTestApp(&stubRepository{})
+	tests := []struct {
+		name  string
+		ctype string
+		body  string
+	}{
+		{"number for the email", "application/json", `{"email":42,"password":"SecurePass123!"}`},
+		{"operator object for the password", "application/json", `{"email":"john@example.com","password":{"$gt":""}}`},
+		{"deeply nested", "application/json", `{"email":` + strings.Repeat("[", 1000) + `}`},
+		{"msgpack", "application/msgpack", "\x82\xa5email\xa1a\xa8password\xa1b"},
+		{"cbor", "application/cbor", "\xa1eemailaa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/signin", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.ctype)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
@@ -2,75 +2,51 @@ package signin
 
 import (
 	"context"
-	"fmt"
-	"time"
+	"errors"
 
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/session"
 	"dvith.com/go-service-api/pkg/database"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
 )
 
-// User represents a user in the system
-type User struct {
-	ID            uuid.UUID  `db:"id" json:"id"`
-	Email         string     `db:"email" json:"email"`
-	Password      string     `db:"password" json:"-"`
-	FullName      string     `db:"full_name" json:"full_name"`
-	Username      string     `db:"username" json:"username"`
-	IsActive      bool       `db:"is_active" json:"is_active"`
-	EmailVerified bool       `db:"email_verified" json:"email_verified"`
-	VerifiedAt    *time.Time `db:"verified_at" json:"verified_at"`
-	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
-	UpdatedAt     time.Time  `db:"updated_at" json:"updated_at"`
-	DeletedAt     *time.Time `db:"deleted_at" json:"deleted_at"`
-}
+// User is the shared user model
+type User = model.User
 
 type SigninRepository struct {
-	db *database.DBPool
+	users    *model.UserRepository
+	sessions *session.Repository
 }
 
-// NewSignupRepository creates a new signup repository
+// NewSigninRepository creates a new signin repository
 func NewSigninRepository(db *database.DBPool) *SigninRepository {
 	return &SigninRepository{
-		db: db,
+		users:    model.NewUserRepository(db),
+		sessions: session.NewRepository(db),
 	}
 }
 
+// FindUser returns the active user with the given email, or nil if there
+// is none
 func (repo *SigninRepository) FindUser(ctx context.Context, email string) (*User, error) {
-	if email == "" {
-		return nil, fmt.Errorf("email cannot be nil")
-	}
-
-	query := `
-		SELECT id, email, password, full_name, username, is_active, email_verified, verified_at, created_at, updated_at, deleted_at
-		FROM users
-		WHERE is_active = true AND email = $1
-	`
-
-	row := repo.db.QueryRow(ctx, query, email)
-
-	// Scan the returned row
-	var user User
-	err := row.Scan(
-		&user.ID,
-		&user.Email,
-		&user.Password,
-		&user.FullName,
-		&user.Username,
-		&user.IsActive,
-		&user.EmailVerified,
-		&user.VerifiedAt,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.DeletedAt,
-	)
-
+	user, err := repo.users.FindByEmail(ctx, email, model.ActiveOnly)
 	if err != nil {
-		if err == pgx.ErrNoRows {
+		if errors.Is(err, model.ErrUserNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
+}
+
+// CreateSession records a new session for the signin, enforcing limit in
+// the same transaction
+func (repo *SigninRepository) CreateSession(ctx context.Context, s *session.Session, limit session.Limit) ([]uuid.UUID, error) {
+	return repo.sessions.CreateLimited(ctx, s, limit)
+}
+
+// RehashPassword swaps the user's password hash for a stronger one
+func (repo *SigninRepository) RehashPassword(ctx context.Context, userID uuid.UUID, oldHash, newHash string) error {
+	return repo.users.RehashPassword(ctx, userID, oldHash, newHash)
 }
@@ -0,0 +1,43 @@
+package signin
+
+import (
+	"context"
+	"testing"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/internal/testdb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) { testdb.Main(m) }
+
+// defaultTenantID is the tenant seeded by the tenants migration
+var defaultTenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+func TestSigninRepository_FindUser(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewSigninRepository(db)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+
+	saved, err := model.NewUserRepository(db).SaveUser(ctx, &User{Email: "alice@example.com", Password: "hash", Username: "alice", IsActive: true})
+	require.NoError(t, err)
+
+	found, err := repo.FindUser(ctx, "alice@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, saved.ID, found.ID)
+	assert.Equal(t, "hash", found.Password)
+
+	found, err = repo.FindUser(ctx, "missing@example.com")
+	assert.NoError(t, err)
+	assert.Nil(t, found, "an unknown email is no user rather than an error")
+
+	var otherID uuid.UUID
+	require.NoError(t, db.QueryRow(ctx, "INSERT INTO tenants (slug, name) VALUES ('other', 'Other') RETURNING id").Scan(&otherID))
+	found, err = repo.FindUser(tenant.WithID(context.Background(), otherID), "alice@example.com")
+	assert.NoError(t, err)
+	assert.Nil(t, found, "users are found in their own tenant only")
+}
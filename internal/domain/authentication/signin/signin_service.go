@@ -2,15 +2,29 @@ package signin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"dvith.com/go-service-api/internal/autorole"
+	"dvith.com/go-service-api/internal/events"
+	"dvith.com/go-service-api/internal/security/authmetrics"
 	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
 	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/google/uuid"
 )
 
 type SigninRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" maxbytes:"320"`
+	Password string `json:"password" maxbytes:"1024"`
+	ClientID string `json:"client_id,omitempty" maxbytes:"100"`
+
+	// Set by the handler from the request, never from the body
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
 // SigninResponse represents the signin response with user and tokens
@@ -20,51 +34,336 @@ type SigninResponse struct {
 	RefreshToken string `json:"refresh_token"`
 	TokenType    string `json:"token_type"`
 	ExpiresIn    int64  `json:"expires_in"`
+	// Security is omitted when no password check ran
+	Security *SecurityNotices `json:"security,omitempty"`
+}
+
+// SecurityNotices nudge the client about the user's password. Each field
+// is omitted when its check is turned off or couldn't run, so a missing
+// field never reads as a clean bill of health.
+type SecurityNotices struct {
+	// PasswordRehashPerformed reports whether this signin upgraded the
+	// stored hash to the current parameters
+	PasswordRehashPerformed *bool `json:"password_rehash_performed,omitempty" xml:"password_rehash_performed,omitempty"`
+	// PasswordBreached reports whether the password appears in known breaches
+	PasswordBreached *bool `json:"password_breached,omitempty" xml:"password_breached,omitempty"`
+	// PasswordAgeDays is how long ago the password was set, when known
+	PasswordAgeDays *int `json:"password_age_days,omitempty" xml:"password_age_days,omitempty"`
+}
+
+// Signin errors caused by the request rather than by the server. Anything
+// else returned by LoginUser is an infrastructure failure.
+var (
+	ErrNilRequest         = errors.New("signin request cannot be nil")
+	ErrInvalidCredentials = errors.New("login failed please recheck the username and password and try again")
+	ErrUnknownClient      = token.ErrUnknownClient
+	// ErrPasswordResetRequired is returned for a correct password when an
+	// admin has required the user to choose a new one
+	ErrPasswordResetRequired = errors.New("a password reset is required before signing in, use the forgot password flow")
+	// ErrSessionLimit is returned when the user is at the session limit and
+	// the policy is session.DenyNew
+	ErrSessionLimit = session.ErrSessionLimit
+)
+
+// IsClientError reports whether err from LoginUser should be reported to
+// the client as a bad request
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrNilRequest) ||
+		errors.Is(err, ErrInvalidCredentials) ||
+		errors.Is(err, ErrUnknownClient)
+}
+
+// Repository loads users and records signins for the signin service
+type Repository interface {
+	FindUser(ctx context.Context, email string) (*User, error)
+	// CreateSession opens a session within limit, returning the IDs of the
+	// sessions evicted to make room
+	CreateSession(ctx context.Context, s *session.Session, limit session.Limit) ([]uuid.UUID, error)
+	// RehashPassword replaces oldHash with a stronger hash of the same
+	// password, failing if the hash changed in the meantime
+	RehashPassword(ctx context.Context, userID uuid.UUID, oldHash, newHash string) error
 }
 
 // SigninService handles user signin operations
 type SigninService struct {
-	repo         *SigninRepository
+	repo         Repository
 	tokenManager *token.TokenManager
+	notifier     *LoginNotifier
+	metrics      *authmetrics.Metrics
+	events       *events.Bus
+
+	sessionLimit session.Limit
+	revoked      RevokedSessions
+
+	rehash        bool
+	breaches      BreachChecker
+	breachTimeout time.Duration
+
+	grants autorole.GrantStore
+	hasher hashpassword.Hasher
+	now    func() time.Time
 }
 
 // NewSigninService creates a new signin service with token manager
-func NewSigninService(repo *SigninRepository, tokenManager *token.TokenManager) *SigninService {
+func NewSigninService(repo Repository, tokenManager *token.TokenManager) *SigninService {
 	return &SigninService{
 		repo:         repo,
 		tokenManager: tokenManager,
+		metrics:      authmetrics.Default,
+		hasher:       hashpassword.Default,
+		now:          clock.Now,
+	}
+}
+
+// WithClock replaces the time source, for tests
+func (s *SigninService) WithClock(now func() time.Time) *SigninService {
+	s.now = now
+	return s
+}
+
+// WithHasher replaces hashpassword.Default as the password hasher. With
+// WithRehash, hashes made with other parameters are upgraded to its own.
+func (s *SigninService) WithHasher(h hashpassword.Hasher) *SigninService {
+	s.hasher = h
+	return s
+}
+
+// WithNotifier enables new-device login notifications
+func (s *SigninService) WithNotifier(n *LoginNotifier) *SigninService {
+	s.notifier = n
+	return s
+}
+
+// RevokedSessions records revocations on this instance
+type RevokedSessions interface {
+	MarkRevoked(sessionID uuid.UUID)
+}
+
+// WithSessionLimit caps each user's active sessions. Sessions evicted to
+// make room are marked in revoked so their access tokens stop working here
+// at once; other instances learn of them through the revocation
+// notification.
+func (s *SigninService) WithSessionLimit(limit session.Limit, revoked RevokedSessions) *SigninService {
+	s.sessionLimit, s.revoked = limit, revoked
+	return s
+}
+
+// WithRehash upgrades password hashes made with outdated parameters on
+// successful signins and reports doing so in the security notices
+func (s *SigninService) WithRehash(enabled bool) *SigninService {
+	s.rehash = enabled
+	return s
+}
+
+// WithBreachChecker checks every signin's password against known breaches,
+// alongside the rest of the signin. A check that takes longer than timeout
+// is dropped from the response; a non-positive timeout means
+// DefaultBreachTimeout.
+func (s *SigninService) WithBreachChecker(c BreachChecker, timeout time.Duration) *SigninService {
+	if timeout <= 0 {
+		timeout = DefaultBreachTimeout
 	}
+	s.breaches, s.breachTimeout = c, timeout
+	return s
+}
+
+// WithMetrics replaces the metrics signins are recorded on
+func (s *SigninService) WithMetrics(m *authmetrics.Metrics) *SigninService {
+	s.metrics = m
+	return s
+}
+
+// WithEvents publishes an events.UserSignedIn for every successful signin,
+// which is how signins reach the audit log
+func (s *SigninService) WithEvents(bus *events.Bus) *SigninService {
+	s.events = bus
+	return s
+}
+
+// WithGrantedRoles embeds the roles granted to the user, such as by email
+// domain at signup, in tokens next to their role
+func (s *SigninService) WithGrantedRoles(grants autorole.GrantStore) *SigninService {
+	s.grants = grants
+	return s
 }
 
 // LoginUser logs in a user with password hashing and returns tokens
 func (s *SigninService) LoginUser(ctx context.Context, req *SigninRequest) (*SigninResponse, error) {
+	resp, reason, err := s.login(ctx, req)
+	s.metrics.Signin(reason)
+	return resp, err
+}
+
+// login is LoginUser, also returning the metrics reason for the outcome.
+// Unknown users and wrong passwords are told apart only there.
+func (s *SigninService) login(ctx context.Context, req *SigninRequest) (*SigninResponse, string, error) {
 	if req == nil {
-		return nil, fmt.Errorf("signin request cannot be nil")
+		return nil, authmetrics.ReasonInvalid, ErrNilRequest
+	}
+	req.Normalize()
+
+	// Tokens can only be issued to configured clients
+	if !s.tokenManager.HasClient(req.ClientID) {
+		return nil, authmetrics.ReasonUnknownClient, ErrUnknownClient
 	}
 
 	// Find user with email
 	user, err := s.repo.FindUser(ctx, req.Email)
 	if err != nil {
-		return nil, fmt.Errorf("failed to login user: %w", err)
+		return nil, authmetrics.ReasonError, fmt.Errorf("failed to login user: %w", err)
+	}
+
+	// Unknown emails fail the same way as wrong passwords
+	if user == nil {
+		return nil, authmetrics.ReasonUnknownUser, ErrInvalidCredentials
 	}
 
 	// Check the password matches
-	isPasswordMatch := hashpassword.CheckPassword(req.Password, user.Password)
+	start := time.Now()
+	isPasswordMatch := s.hasher.Verify(req.Password, user.Password)
+	s.metrics.PasswordVerified(time.Since(start))
 	if isPasswordMatch == false {
-		return nil, fmt.Errorf("login failed please recheck the username and password and try again")
+		return nil, authmetrics.ReasonBadPassword, ErrInvalidCredentials
+	}
+
+	// Only told to someone who knows the password, so it doesn't reveal
+	// which accounts were flagged
+	if user.MustResetPassword {
+		return nil, authmetrics.ReasonResetRequired, ErrPasswordResetRequired
+	}
+
+	// Runs while the session and tokens are created
+	breached := s.checkBreach(ctx, user.ID, req.Password)
+
+	var notices SecurityNotices
+	if s.rehash {
+		performed := s.rehashPassword(ctx, user, req.Password)
+		notices.PasswordRehashPerformed = &performed
+	}
+	if user.PasswordChangedAt != nil {
+		days := int(s.now().Sub(*user.PasswordChangedAt) / (24 * time.Hour))
+		notices.PasswordAgeDays = &days
 	}
 
-	// Generate JWT tokens
-	tokenPair, err := s.tokenManager.GenerateTokenPair(user.ID)
+	// Every signin opens a session the user can review later; its tokens
+	// carry the session so revoking it cuts them off
+	sess := &session.Session{UserID: user.ID, IP: req.IP, UserAgent: req.UserAgent}
+	evicted, err := s.repo.CreateSession(ctx, sess, s.sessionLimit)
+	if errors.Is(err, session.ErrSessionLimit) {
+		return nil, authmetrics.ReasonSessionLimit, ErrSessionLimit
+	}
+	if err != nil {
+		return nil, authmetrics.ReasonError, fmt.Errorf("failed to create session: %w", err)
+	}
+	for _, id := range evicted {
+		if s.revoked != nil {
+			s.revoked.MarkRevoked(id)
+		}
+		logger.Info("session evicted by session limit", map[string]any{
+			"user_id":    user.ID.String(),
+			"session_id": id.String(),
+		})
+	}
+
+	roles, err := autorole.TokenRoles(ctx, s.grants, user.Role, user.ID, user.EmailVerified)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+		return nil, authmetrics.ReasonError, fmt.Errorf("failed to load roles: %w", err)
+	}
+	tokenPair, err := s.tokenManager.GenerateTokenPair(user.ID, token.WithTenant(user.TenantID), token.WithRoles(roles...), token.WithClient(req.ClientID), token.WithSession(sess.ID), token.WithFresh())
+	if err != nil {
+		return nil, authmetrics.ReasonError, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	if s.notifier != nil {
+		s.notifier.NotifyAsync(ctx, user, sess)
 	}
 
-	return &SigninResponse{
+	// Subscriber failures, audit included, never fail the signin
+	s.events.Publish(ctx, events.UserSignedIn{
+		UserID:         user.ID,
+		TenantID:       user.TenantID,
+		SessionID:      sess.ID,
+		ClientID:       req.ClientID,
+		AccessTokenID:  tokenPair.AccessTokenID,
+		RefreshTokenID: tokenPair.RefreshTokenID,
+		IP:             req.IP,
+		UserAgent:      req.UserAgent,
+		At:             s.now(),
+	})
+
+	notices.PasswordBreached = breached()
+
+	resp := &SigninResponse{
 		User:         user,
 		AccessToken:  tokenPair.AccessToken,
 		RefreshToken: tokenPair.RefreshToken,
 		TokenType:    tokenPair.TokenType,
 		ExpiresIn:    tokenPair.ExpiresIn,
-	}, nil
+	}
+	if notices != (SecurityNotices{}) {
+		resp.Security = &notices
+	}
+	return resp, authmetrics.ReasonNone, nil
+}
+
+// rehashPassword replaces an outdated hash of the user's password and
+// reports whether it did. Failures only cost the upgrade, not the signin.
+func (s *SigninService) rehashPassword(ctx context.Context, user *User, password string) bool {
+	if !s.hasher.NeedsRehash(user.Password) {
+		return false
+	}
+	hashed, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.repo.RehashPassword(ctx, user.ID, user.Password, hashed)
+	}
+	if err != nil {
+		logger.Warn("failed to rehash password on signin", map[string]any{
+			"user_id": user.ID.String(),
+			"error":   err.Error(),
+		})
+		return false
+	}
+	user.Password = hashed
+	return true
+}
+
+// checkBreach starts the breach check of password and returns a func that
+// waits for its result. The result is nil when no checker is configured or
+// the check failed or timed out. An abandoned check ends at its timeout.
+func (s *SigninService) checkBreach(ctx context.Context, userID uuid.UUID, password string) func() *bool {
+	if s.breaches == nil {
+		return func() *bool { return nil }
+	}
+
+	type result struct {
+		breached bool
+		err      error
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.breachTimeout)
+	done := make(chan result, 1)
+	go func() {
+		breached, err := s.breaches.Breached(ctx, password)
+		done <- result{breached, err}
+	}()
+
+	return func() *bool {
+		defer cancel()
+		select {
+		case r := <-done:
+			if r.err != nil {
+				logger.Warn("password breach check failed", map[string]any{
+					"user_id": userID.String(),
+					"error":   r.err.Error(),
+				})
+				return nil
+			}
+			return &r.breached
+		case <-ctx.Done():
+			logger.Warn("password breach check timed out", map[string]any{
+				"user_id": userID.String(),
+				"timeout": s.breachTimeout.String(),
+			})
+			return nil
+		}
+	}
 }
@@ -0,0 +1,235 @@
+package signin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/events"
+	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type stubBreachChecker struct {
+	breached bool
+	err      error
+}
+
+func (c stubBreachChecker) Breached(ctx context.Context, password string) (bool, error) {
+	return c.breached, c.err
+}
+
+// hungBreachChecker never answers, and reports on stopped how long it ran
+// before its context ended
+type hungBreachChecker struct {
+	stopped chan time.Duration
+}
+
+func (c hungBreachChecker) Breached(ctx context.Context, password string) (bool, error) {
+	start := time.Now()
+	<-ctx.Done()
+	c.stopped <- time.Since(start)
+	return false, ctx.Err()
+}
+
+func login(t *testing.T, svc *SigninService) *SigninResponse {
+	t.Helper()
+	resp, err := svc.LoginUser(context.Background(), &SigninRequest{Email: "john@example.com", Password: "SecurePass123!"})
+	require.NoError(t, err)
+	return resp
+}
+
+func TestLoginUser_SecurityNoticesOmittedWhenChecksOff(t *testing.T) {
+	resp := login(t, newSigninService(&stubRepository{user: newSigninUser(t)}))
+	assert.Nil(t, resp.Security)
+
+	_, body := postSignin(t, newSigninTestApp(&stubRepository{user: newSigninUser(t)}), "SecurePass123!")
+	assert.NotContains(t, body, "security")
+}
+
+func TestLoginUser_IssuesFreshTokens(t *testing.T) {
+	svc := newSigninService(&stubRepository{user: newSigninUser(t)})
+	resp := login(t, svc)
+
+	claims, err := svc.tokenManager.ValidateAccessToken(resp.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, claims.Fresh, "password signin issues fresh access tokens")
+}
+
+func TestLoginUser_Rehash(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("SecurePass123!"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := newSigninUser(t)
+	user.Password = string(legacy)
+	repo := &stubRepository{user: user}
+
+	resp := login(t, newSigninService(repo).WithRehash(true))
+	require.NotNil(t, resp.Security)
+	require.NotNil(t, resp.Security.PasswordRehashPerformed)
+	assert.True(t, *resp.Security.PasswordRehashPerformed)
+	assert.NotEmpty(t, repo.rehashed)
+	assert.False(t, strings.HasPrefix(repo.rehashed, "$2"), "stored as the current scheme")
+	assert.Nil(t, resp.Security.PasswordBreached)
+	assert.Nil(t, resp.Security.PasswordAgeDays)
+
+	// A current hash is left alone, and the notice says so
+	repo = &stubRepository{user: newSigninUser(t)}
+	resp = login(t, newSigninService(repo).WithRehash(true))
+	require.NotNil(t, resp.Security.PasswordRehashPerformed)
+	assert.False(t, *resp.Security.PasswordRehashPerformed)
+	assert.Empty(t, repo.rehashed)
+}
+
+func TestLoginUser_RehashToHasherParams(t *testing.T) {
+	// A hash with the defaults is outdated for a hasher calibrated otherwise
+	calibrated := hashpassword.NewArgon2(hashpassword.Argon2Params{Time: 1, Memory: 8 * 1024, Parallelism: 4, SaltLength: 16, KeyLength: 32})
+	repo := &stubRepository{user: newSigninUser(t)}
+
+	resp := login(t, newSigninService(repo).WithHasher(calibrated).WithRehash(true))
+	require.NotNil(t, resp.Security.PasswordRehashPerformed)
+	assert.True(t, *resp.Security.PasswordRehashPerformed)
+	assert.True(t, strings.HasPrefix(repo.rehashed, "$argon2id$v=19$m=8192,t=1,p=4$"), repo.rehashed)
+	assert.True(t, calibrated.Verify("SecurePass123!", repo.rehashed))
+}
+
+func TestLoginUser_Breached(t *testing.T) {
+	newService := func(c BreachChecker) *SigninService {
+		return newSigninService(&stubRepository{user: newSigninUser(t)}).WithBreachChecker(c, 50*time.Millisecond)
+	}
+
+	resp := login(t, newService(stubBreachChecker{breached: true}))
+	require.NotNil(t, resp.Security)
+	require.NotNil(t, resp.Security.PasswordBreached)
+	assert.True(t, *resp.Security.PasswordBreached)
+	assert.Nil(t, resp.Security.PasswordRehashPerformed)
+
+	resp = login(t, newService(stubBreachChecker{}))
+	require.NotNil(t, resp.Security.PasswordBreached)
+	assert.False(t, *resp.Security.PasswordBreached)
+
+	// Failed and slow checks leave the field out rather than claim false
+	resp = login(t, newService(stubBreachChecker{err: errors.New("unavailable")}))
+	assert.Nil(t, resp.Security)
+
+	// The bound is on the check alone, as verifying the password takes
+	// its own time, more of it under -race
+	hung := hungBreachChecker{stopped: make(chan time.Duration, 1)}
+	resp = login(t, newService(hung))
+	assert.Nil(t, resp.Security)
+	ran := <-hung.stopped
+	assert.Less(t, ran, 500*time.Millisecond, "the check is abandoned at its timeout")
+}
+
+func TestLoginUser_PasswordAge(t *testing.T) {
+	frozen := clock.NewFrozen(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	user := newSigninUser(t)
+	changed := frozen.Now().Add(-10*24*time.Hour - time.Hour)
+	user.PasswordChangedAt = &changed
+
+	resp := login(t, newSigninService(&stubRepository{user: user}).WithClock(frozen.Now))
+	require.NotNil(t, resp.Security)
+	require.NotNil(t, resp.Security.PasswordAgeDays)
+	assert.Equal(t, 10, *resp.Security.PasswordAgeDays)
+
+	frozen.Advance(24 * time.Hour)
+	app := fiber.New()
+	app.Post("/signin", SigninHandler(newSigninService(&stubRepository{user: user}).WithClock(frozen.Now)))
+	_, body := postSignin(t, app, "SecurePass123!")
+	assert.Contains(t, body, `"security":{"password_age_days":11}`, "counted by the service's clock")
+}
+
+// limitedRepository opens sessions in a real in-memory session store
+type limitedRepository struct {
+	*stubRepository
+	sessions *session.MemoryStore
+	tenantID uuid.UUID
+}
+
+func (r limitedRepository) CreateSession(ctx context.Context, s *session.Session, limit session.Limit) ([]uuid.UUID, error) {
+	return r.sessions.CreateLimited(tenant.WithID(ctx, r.tenantID), s, limit)
+}
+
+type markedSessions []uuid.UUID
+
+func (m *markedSessions) MarkRevoked(sessionID uuid.UUID) {
+	*m = append(*m, sessionID)
+}
+
+func TestLoginUser_SessionLimit(t *testing.T) {
+	user := newSigninUser(t)
+	repo := limitedRepository{&stubRepository{user: user}, session.NewMemoryStore(), uuid.New()}
+
+	var marked markedSessions
+	svc := newSigninService(repo).WithSessionLimit(session.Limit{Max: 1, Policy: session.EvictOldest}, &marked)
+	login(t, svc)
+	login(t, svc)
+	assert.Len(t, marked, 1, "the evicted session's access tokens are cut off here at once")
+
+	svc = newSigninService(repo).WithSessionLimit(session.Limit{Max: 1, Policy: session.DenyNew}, &marked)
+	_, err := svc.LoginUser(context.Background(), &SigninRequest{Email: "john@example.com", Password: "SecurePass123!"})
+	assert.ErrorIs(t, err, ErrSessionLimit)
+
+	app := fiber.New()
+	app.Post("/signin", SigninHandler(svc))
+	resp, _ := postSignin(t, app, "SecurePass123!")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestLoginUser_PasswordResetRequired(t *testing.T) {
+	user := newSigninUser(t)
+	user.MustResetPassword = true
+
+	_, err := newSigninService(&stubRepository{user: user}).LoginUser(context.Background(), &SigninRequest{Email: "john@example.com", Password: "SecurePass123!"})
+	assert.ErrorIs(t, err, ErrPasswordResetRequired)
+
+	resp, body := postSignin(t, newSigninTestApp(&stubRepository{user: user}), "SecurePass123!")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, body, `"error":"password_reset_required"`)
+	assert.NotContains(t, body, "access_token")
+
+	// A wrong password gets the usual answer, so the flag isn't revealed
+	resp, _ = postSignin(t, newSigninTestApp(&stubRepository{user: user}), "WrongPass123!")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestLoginUser_PublishesEvent(t *testing.T) {
+	bus := events.NewBus()
+	var got []events.UserSignedIn
+	events.On(bus, "capture", func(ctx context.Context, e events.UserSignedIn) error {
+		got = append(got, e)
+		return nil
+	})
+	events.On(bus, "panics", func(ctx context.Context, e events.UserSignedIn) error {
+		panic("subscriber bug")
+	})
+
+	user := newSigninUser(t)
+	user.TenantID = uuid.New()
+	frozen := clock.NewFrozen(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	svc := newSigninService(&stubRepository{user: user}).WithEvents(bus).WithClock(frozen.Now)
+	resp, err := svc.LoginUser(context.Background(), &SigninRequest{Email: "john@example.com", Password: "SecurePass123!", IP: "10.0.0.1", UserAgent: "curl/8.0"})
+	require.NoError(t, err, "a panicking subscriber doesn't fail the signin")
+	require.NotNil(t, resp)
+
+	require.Len(t, got, 1)
+	assert.Equal(t, user.ID, got[0].UserID)
+	assert.Equal(t, user.TenantID, got[0].TenantID)
+	assert.NotEqual(t, uuid.Nil, got[0].SessionID)
+	assert.Equal(t, "10.0.0.1", got[0].IP)
+	assert.Equal(t, "curl/8.0", got[0].UserAgent)
+	assert.Equal(t, frozen.Now(), got[0].At)
+
+	_, err = svc.LoginUser(context.Background(), &SigninRequest{Email: "john@example.com", Password: "WrongPass123!"})
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Len(t, got, 1, "failed signins publish nothing")
+}
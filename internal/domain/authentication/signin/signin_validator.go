@@ -1,45 +1,22 @@
 package signin
 
 import (
-	"fmt"
-
-	"github.com/go-playground/validator/v10"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/textnorm"
 )
 
-var validate = validator.New()
+// ValidationError represents a validation error, with the JSON pointer
+// and code of middleware.FieldError
+type ValidationError = middleware.FieldError
 
-// ValidationError represents a validation error
-type ValidationError struct {
-	Field   string `json:"field"`
-	Message string `json:"message"`
+// Normalize cleans the email the way signup does, so an address pasted
+// with surrounding spaces finds the account it registered
+func (req *SigninRequest) Normalize() {
+	req.Email = textnorm.Email(req.Email)
 }
 
-// ValidateSignupRequest validates the signup request
+// ValidateSigninRequest normalizes and validates the signin request
 func ValidateSigninRequest(req *SigninRequest) []ValidationError {
-	var errors []ValidationError
-
-	if err := validate.Struct(req); err != nil {
-		for _, err := range err.(validator.ValidationErrors) {
-			ve := ValidationError{
-				Field: err.Field(),
-			}
-
-			switch err.Tag() {
-			case "required":
-				ve.Message = fmt.Sprintf("%s is required", err.Field())
-			case "email":
-				ve.Message = fmt.Sprintf("%s must be a valid email address", err.Field())
-			case "min":
-				ve.Message = fmt.Sprintf("%s must be at least %s characters", err.Field(), err.Param())
-			case "max":
-				ve.Message = fmt.Sprintf("%s must not exceed %s characters", err.Field(), err.Param())
-			default:
-				ve.Message = fmt.Sprintf("%s is invalid", err.Field())
-			}
-
-			errors = append(errors, ve)
-		}
-	}
-
-	return errors
+	req.Normalize()
+	return middleware.ValidateStruct(req)
 }
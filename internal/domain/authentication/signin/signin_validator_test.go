@@ -0,0 +1,53 @@
+package signin
+
+import (
+	"strings"
+	"testing"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSigninRequest_ByteLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      SigninRequest
+		wantPath string
+	}{
+		{"email over 320 bytes", SigninRequest{Email: strings.Repeat("a", 309) + "@example.com", Password: "x"}, "/email"},
+		{"password over 1KB", SigninRequest{Email: "john@example.com", Password: strings.Repeat("x", 1025)}, "/password"},
+		{"client ID over 100 bytes", SigninRequest{Email: "john@example.com", Password: "x", ClientID: strings.Repeat("x", 101)}, "/client_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateSigninRequest(&tt.req)
+			require.Len(t, errs, 1)
+			assert.Equal(t, tt.wantPath, errs[0].JSONPath)
+			assert.Equal(t, "too_large", errs[0].Code)
+		})
+	}
+
+	req := SigninRequest{Email: strings.Repeat("a", 308) + "@example.com", Password: strings.Repeat("x", 1024)}
+	assert.Empty(t, ValidateSigninRequest(&req), "values at the limits are accepted")
+}
+
+func FuzzSigninRequest(f *testing.F) {
+	f.Add([]byte(`{"email":"john@example.com","password":"SecurePass123!"}`))
+	f.Add([]byte(`{"email":1,"password":-0.5e10,"client_id":false}`))
+	f.Add([]byte(`{"email":" JOHN@EXAMPLE.COM\u200b ","password":"\ud83d"}`))
+	f.Add([]byte(`[{"email":[]}]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var req SigninRequest
+		if err := middleware.DecodeJSON(data, &req); err != nil {
+			return
+		}
+		if errs := ValidateSigninRequest(&req); len(errs) > 0 {
+			return
+		}
+		if len(req.Email) > 320 || len(req.Password) > 1024 || len(req.ClientID) > 100 {
+			t.Fatalf("oversized request passed validation: %d/%d/%d bytes", len(req.Email), len(req.Password), len(req.ClientID))
+		}
+	})
+}
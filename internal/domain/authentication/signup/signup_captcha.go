@@ -0,0 +1,114 @@
+package signup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/pkg/httpclient"
+)
+
+// CAPTCHA providers accepted by NewCaptchaVerifier
+const (
+	CaptchaTurnstile = "turnstile"
+	CaptchaRecaptcha = "recaptcha"
+)
+
+// CAPTCHA rejections
+var (
+	ErrCaptchaRequired = errors.New("captcha token is required")
+	ErrCaptchaFailed   = errors.New("captcha verification failed")
+)
+
+// captchaTimeout bounds a single call to the provider's verify API
+const captchaTimeout = 5 * time.Second
+
+var captchaEndpoints = map[string]string{
+	CaptchaTurnstile: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+	CaptchaRecaptcha: "https://www.google.com/recaptcha/api/siteverify",
+}
+
+// CaptchaVerifier checks the CAPTCHA token submitted with a signup
+type CaptchaVerifier interface {
+	VerifyCaptcha(ctx context.Context, token, ip string) error
+}
+
+// HTTPCaptchaVerifier verifies tokens against a provider's siteverify API.
+// Turnstile and reCAPTCHA share the same request and response shape.
+type HTTPCaptchaVerifier struct {
+	endpoint string
+	secret   string
+	client   *httpclient.Client
+}
+
+// NewCaptchaVerifier creates a verifier for the named provider. The
+// siteverify POST is never retried, as a token can only be redeemed once.
+func NewCaptchaVerifier(provider, secret string) (*HTTPCaptchaVerifier, error) {
+	endpoint, ok := captchaEndpoints[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown captcha provider %q", provider)
+	}
+	return &HTTPCaptchaVerifier{
+		endpoint: endpoint,
+		secret:   secret,
+		client:   httpclient.New(httpclient.Options{Name: "captcha", Timeout: captchaTimeout}),
+	}, nil
+}
+
+// WithEndpoint replaces the provider's verify URL, for tests
+func (v *HTTPCaptchaVerifier) WithEndpoint(endpoint string) *HTTPCaptchaVerifier {
+	v.endpoint = endpoint
+	return v
+}
+
+type captchaResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// VerifyCaptcha implements CaptchaVerifier. A rejected token returns
+// ErrCaptchaFailed; a provider outage returns a wrapped transport error.
+func (v *HTTPCaptchaVerifier) VerifyCaptcha(ctx context.Context, token, ip string) error {
+	if strings.TrimSpace(token) == "" {
+		return ErrCaptchaRequired
+	}
+
+	form := url.Values{
+		"secret":   {v.secret},
+		"response": {token},
+	}
+	if ip != "" {
+		form.Set("remoteip", ip)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to build captcha request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to verify captcha: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to verify captcha: provider returned status %d", resp.StatusCode)
+	}
+
+	var body captchaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode captcha response: %w", err)
+	}
+	if !body.Success {
+		return fmt.Errorf("%w: %s", ErrCaptchaFailed, strings.Join(body.ErrorCodes, ","))
+	}
+
+	return nil
+}
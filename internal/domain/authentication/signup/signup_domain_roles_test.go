@@ -0,0 +1,55 @@
+package signup
+
+import (
+	"context"
+	"testing"
+
+	"dvith.com/go-service-api/internal/autorole"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterUser_DomainRoles(t *testing.T) {
+	rules := autorole.Rules{"acme.com": "acme-member"}
+
+	tests := []struct {
+		name            string
+		email           string
+		requireVerified bool
+		wantRoles       []string
+		wantVerified    []string
+	}{
+		{"applied right away", "Jane@ACME.com", false, []string{"user", "acme-member"}, []string{"user", "acme-member"}},
+		{"held until verified", "jane@acme.com", true, []string{"user"}, []string{"user", "acme-member"}},
+		{"no matching rule", "jane@example.com", false, []string{"user"}, []string{"user"}},
+		{"subdomains don't match", "jane@eu.acme.com", false, []string{"user"}, []string{"user"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tenant.WithID(context.Background(), uuid.New())
+			users := model.NewMemoryStore()
+			grants := autorole.NewMemoryStore()
+			service := newSignupService(users).WithDomainRoles(autorole.NewAssigner(rules, grants, grants, tt.requireVerified))
+
+			resp, err := service.RegisterUser(ctx, &SignupRequest{
+				Email:    tt.email,
+				Password: "SecurePass123!",
+				FullName: "Jane Doe",
+				Username: "jane_doe",
+			})
+			require.NoError(t, err)
+			claims, err := service.tokenManager.ValidateAccessToken(resp.AccessToken)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRoles, claims.Roles)
+
+			// The grant is saved either way, for the tokens issued once the
+			// email is verified
+			roles, err := autorole.TokenRoles(ctx, grants, resp.User.Role, resp.User.ID, true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVerified, roles)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package signup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/cache"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dryRunVerdictBody struct {
+	Valid  bool              `json:"valid"`
+	Errors []ValidationError `json:"errors"`
+}
+
+// postSignupAs posts req to path, with ctx as the request context and the
+// given headers
+func postSignupAs(t *testing.T, service *SignupService, ctx context.Context, path string, req *SignupRequest, headers map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	app := fiber.New()
+	app.Post("/signup", func(c fiber.Ctx) error {
+		c.Locals(middleware.ContextKeyRequestContext, ctx)
+		return c.Next()
+	}, SignupHandler(service, "https://example.com/api/v1/user/profile"))
+
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+	httpReq := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := app.Test(httpReq)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, raw
+}
+
+func dryRun(t *testing.T, service *SignupService, ctx context.Context, req *SignupRequest) dryRunVerdictBody {
+	t.Helper()
+	resp, raw := postSignupAs(t, service, ctx, "/signup?dry_run=true", req, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(raw))
+	var verdict dryRunVerdictBody
+	require.NoError(t, json.Unmarshal(raw, &verdict))
+	return verdict
+}
+
+func TestSignupHandler_DryRunMatchesFieldErrors(t *testing.T) {
+	f := newInviteFixture(t)
+	req := &SignupRequest{Email: "not-an-email", Password: "short", FullName: "Dry Run", Username: "dry run"}
+
+	resp, raw := postSignupAs(t, f.service, f.ctx, "/signup", req, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	var real struct {
+		Fields []ValidationError `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &real))
+	require.NotEmpty(t, real.Fields)
+
+	verdict := dryRun(t, f.service, f.ctx, req)
+	assert.False(t, verdict.Valid)
+	assert.Equal(t, real.Fields, verdict.Errors)
+}
+
+func TestSignupHandler_DryRunMatchesRejections(t *testing.T) {
+	f := newInviteFixture(t)
+	f.invite(t, "BETA", 1)
+	_, err := f.repo.users.SaveUser(f.ctx, &User{Email: "beta1@example.com", Username: "someone"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		req  *SignupRequest
+		path string
+		code string
+	}{
+		{name: "taken email", req: inviteRequest(1, "BETA"), path: "/email", code: "email_taken"},
+		{name: "missing invitation", req: inviteRequest(2, ""), path: "/invite_code", code: "invite_required"},
+		{name: "unknown invitation", req: inviteRequest(2, "GUESS"), path: "/invite_code", code: "invite_invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := dryRun(t, f.service, f.ctx, tt.req)
+			require.False(t, verdict.Valid)
+			require.Len(t, verdict.Errors, 1)
+			assert.Equal(t, tt.path, verdict.Errors[0].JSONPath)
+			assert.Equal(t, tt.code, verdict.Errors[0].Code)
+
+			resp, raw := postSignupAs(t, f.service, f.ctx, "/signup", tt.req, nil)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			var real struct {
+				Fields []ValidationError `json:"fields"`
+			}
+			require.NoError(t, json.Unmarshal(raw, &real))
+			assert.Equal(t, real.Fields, verdict.Errors)
+		})
+	}
+}
+
+func TestSignupHandler_DryRunSavesNothing(t *testing.T) {
+	f := newInviteFixture(t)
+	f.invite(t, "ONCE", 1)
+
+	for _, headers := range []map[string]string{nil, {HeaderDryRun: "true"}} {
+		path := "/signup?dry_run=true"
+		if headers != nil {
+			path = "/signup"
+		}
+		resp, raw := postSignupAs(t, f.service, f.ctx, path, inviteRequest(1, "ONCE"), headers)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(raw))
+		assert.JSONEq(t, `{"valid": true, "errors": []}`, string(raw))
+		assert.Empty(t, resp.Header.Get(fiber.HeaderLocation))
+	}
+
+	_, err := f.repo.users.FindByEmail(f.ctx, "beta1@example.com", model.ActiveOnly)
+	assert.ErrorIs(t, err, model.ErrUserNotFound)
+
+	resp, raw := postSignupAs(t, f.service, f.ctx, "/signup", inviteRequest(1, "ONCE"), nil)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, "dry runs don't use the invitation: %s", raw)
+}
+
+func TestSignupHandler_DryRunRateLimited(t *testing.T) {
+	service := newSignupService(model.NewMemoryStore()).WithRateLimiter(NewRateLimiter(cache.NewMemory(), 2, time.Minute))
+	f := newInviteFixture(t)
+
+	for i := 0; i < 2; i++ {
+		dryRun(t, service, f.ctx, inviteRequest(i, ""))
+	}
+	resp, _ := postSignupAs(t, service, f.ctx, "/signup?dry_run=true", inviteRequest(3, ""), nil)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, "dry runs count against the signup limit")
+	assert.Equal(t, "60", resp.Header.Get(fiber.HeaderRetryAfter))
+}
@@ -0,0 +1,91 @@
+package signup
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+)
+
+// disposableDomains is a snapshot of known disposable email providers
+//
+//go:embed disposable_domains.txt
+var disposableDomains string
+
+// Email domain rejections
+var (
+	ErrEmailDomainBlocked    = errors.New("email domain is not accepted")
+	ErrEmailDomainNotAllowed = errors.New("email domain is not on the allow list")
+)
+
+// EmailPolicy decides which email domains may sign up. Matching is
+// case-insensitive and covers subdomains, so blocking example.org also
+// blocks mail.example.org.
+type EmailPolicy struct {
+	allowed map[string]bool
+	blocked map[string]bool
+}
+
+// NewEmailPolicy creates a policy from an allow list and a deny list. An
+// empty allow list allows every domain that isn't denied. When
+// blockDisposable is set, the embedded disposable provider list is denied
+// too.
+func NewEmailPolicy(allowed, blocked []string, blockDisposable bool) *EmailPolicy {
+	p := &EmailPolicy{
+		allowed: domainSet(allowed),
+		blocked: domainSet(blocked),
+	}
+	if blockDisposable {
+		for _, line := range strings.Split(disposableDomains, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			p.blocked[strings.ToLower(line)] = true
+		}
+	}
+	return p
+}
+
+// Check returns ErrEmailDomainBlocked or ErrEmailDomainNotAllowed when the
+// email's domain may not sign up
+func (p *EmailPolicy) Check(email string) error {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ErrEmailDomainBlocked
+	}
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(email[at+1:])), ".")
+
+	if len(p.allowed) > 0 && !matchDomain(p.allowed, domain) {
+		return ErrEmailDomainNotAllowed
+	}
+	if matchDomain(p.blocked, domain) {
+		return ErrEmailDomainBlocked
+	}
+	return nil
+}
+
+// matchDomain reports whether domain or any parent domain is in set
+func matchDomain(set map[string]bool, domain string) bool {
+	for domain != "" {
+		if set[domain] {
+			return true
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			return false
+		}
+		domain = parent
+	}
+	return false
+}
+
+func domainSet(domains []string) map[string]bool {
+	set := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d != "" {
+			set[d] = true
+		}
+	}
+	return set
+}
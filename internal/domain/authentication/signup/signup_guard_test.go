@@ -0,0 +1,206 @@
+package signup
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/emailaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailPolicy_Blocked(t *testing.T) {
+	p := NewEmailPolicy(nil, []string{" Spam.Test "}, false)
+
+	assert.ErrorIs(t, p.Check("bot@spam.test"), ErrEmailDomainBlocked)
+	assert.ErrorIs(t, p.Check("bot@SPAM.TEST"), ErrEmailDomainBlocked)
+	assert.ErrorIs(t, p.Check("bot@mx.Spam.Test"), ErrEmailDomainBlocked, "subdomains are blocked too")
+	assert.NoError(t, p.Check("user@notspam.test"))
+	assert.NoError(t, p.Check("user@example.com"))
+}
+
+func TestEmailPolicy_Allowed(t *testing.T) {
+	p := NewEmailPolicy([]string{"corp.test"}, []string{"contractors.corp.test"}, false)
+
+	assert.NoError(t, p.Check("alice@Corp.Test"))
+	assert.NoError(t, p.Check("bob@eu.corp.test"))
+	assert.ErrorIs(t, p.Check("eve@contractors.corp.test"), ErrEmailDomainBlocked)
+	assert.ErrorIs(t, p.Check("mallory@example.com"), ErrEmailDomainNotAllowed)
+}
+
+// noMailResolver answers that no domain exists
+type noMailResolver struct{}
+
+func (noMailResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (noMailResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestSignupHandler_MXCheck(t *testing.T) {
+	service := newSignupService(&stubRepository{}).WithMXCheck(emailaddr.NewMXChecker(noMailResolver{}, emailaddr.DefaultMXTimeout))
+
+	resp, body := postSignup(t, newSignupTestAppWithService(service))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, `"error_code":"email_undeliverable"`)
+}
+
+func TestEmailPolicy_Disposable(t *testing.T) {
+	assert.ErrorIs(t, NewEmailPolicy(nil, nil, true).Check("bot@Mailinator.com"), ErrEmailDomainBlocked)
+	assert.ErrorIs(t, NewEmailPolicy(nil, nil, true).Check("bot@yopmail.com"), ErrEmailDomainBlocked)
+	assert.NoError(t, NewEmailPolicy(nil, nil, true).Check("user@gmail.com"))
+	assert.NoError(t, NewEmailPolicy(nil, nil, false).Check("bot@mailinator.com"))
+}
+
+func TestRateLimiter_Window(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	l := NewRateLimiter(cache.NewMemory().WithClock(clock), 2, time.Hour).WithClock(clock)
+
+	ok, _ := l.Allow("10.0.0.1")
+	assert.True(t, ok)
+	ok, _ = l.Allow("10.0.0.1")
+	assert.True(t, ok)
+
+	now = now.Add(20 * time.Minute)
+	ok, retryAfter := l.Allow("10.0.0.1")
+	assert.False(t, ok)
+	assert.Equal(t, 40*time.Minute, retryAfter)
+
+	ok, _ = l.Allow("10.0.0.2")
+	assert.True(t, ok, "limits are per IP")
+
+	now = now.Add(40 * time.Minute)
+	ok, _ = l.Allow("10.0.0.1")
+	assert.True(t, ok, "a new window starts once the old one expires")
+}
+
+func newCaptchaServer(t *testing.T, body string, status int) (*httptest.Server, *http.Request) {
+	t.Helper()
+	var got http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		got = *r
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+func TestCaptchaVerifier_Success(t *testing.T) {
+	srv, got := newCaptchaServer(t, `{"success":true}`, http.StatusOK)
+	v, err := NewCaptchaVerifier(CaptchaTurnstile, "secret")
+	require.NoError(t, err)
+	v.WithEndpoint(srv.URL)
+
+	require.NoError(t, v.VerifyCaptcha(context.Background(), "tok", "203.0.113.7"))
+	assert.Equal(t, "secret", got.PostForm.Get("secret"))
+	assert.Equal(t, "tok", got.PostForm.Get("response"))
+	assert.Equal(t, "203.0.113.7", got.PostForm.Get("remoteip"))
+}
+
+func TestCaptchaVerifier_Rejected(t *testing.T) {
+	srv, _ := newCaptchaServer(t, `{"success":false,"error-codes":["invalid-input-response"]}`, http.StatusOK)
+	v, err := NewCaptchaVerifier(CaptchaRecaptcha, "secret")
+	require.NoError(t, err)
+	v.WithEndpoint(srv.URL)
+
+	err = v.VerifyCaptcha(context.Background(), "bad", "")
+	assert.ErrorIs(t, err, ErrCaptchaFailed)
+	assert.True(t, IsClientError(err))
+	assert.Equal(t, "captcha_failed", ErrorCode(err))
+}
+
+func TestCaptchaVerifier_ProviderDown(t *testing.T) {
+	srv, _ := newCaptchaServer(t, `oops`, http.StatusBadGateway)
+	v, err := NewCaptchaVerifier(CaptchaTurnstile, "secret")
+	require.NoError(t, err)
+	v.WithEndpoint(srv.URL)
+
+	err = v.VerifyCaptcha(context.Background(), "tok", "")
+	require.Error(t, err)
+	assert.False(t, IsClientError(err), "an outage is not the client's fault")
+}
+
+func TestCaptchaVerifier_MissingToken(t *testing.T) {
+	v, err := NewCaptchaVerifier(CaptchaTurnstile, "secret")
+	require.NoError(t, err)
+	assert.ErrorIs(t, v.VerifyCaptcha(context.Background(), " ", ""), ErrCaptchaRequired)
+
+	_, err = NewCaptchaVerifier("hcaptcha", "secret")
+	assert.Error(t, err)
+}
+
+func TestNamePolicy_Usernames(t *testing.T) {
+	tests := []struct {
+		name      string
+		username  string
+		word      bool // rejected in MatchWord mode
+		substring bool // rejected in MatchSubstring mode
+	}{
+		{"reserved", "admin", true, true},
+		{"reserved any case", "Support", true, true},
+		{"folded", "4dm1n", true, true},
+		{"folded symbols", "$y$tem", true, true},
+		{"trailing digits", "admin42", true, true},
+		{"separated word", "admin_team", true, true},
+		{"embedded in a word", "radmin", false, true},
+		{"profane", "fuck_this", true, true},
+		{"profane folded", "sh1t", true, true},
+		{"ordinary", "john_doe", false, false},
+		{"digits only fold", "j0hn", false, false},
+	}
+
+	word := NewNamePolicy(nil, nil, true, MatchWord)
+	substring := NewNamePolicy(nil, nil, true, MatchSubstring)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.word, word.CheckUsername(tt.username) != nil, "word mode")
+			assert.Equal(t, tt.substring, substring.CheckUsername(tt.username) != nil, "substring mode")
+		})
+	}
+}
+
+func TestNamePolicy_DoesNotRevealList(t *testing.T) {
+	p := NewNamePolicy(nil, nil, true, MatchWord)
+
+	assert.Equal(t, ErrUsernameUnavailable, p.CheckUsername("admin"))
+	assert.Equal(t, ErrUsernameUnavailable, p.CheckUsername("bitch"))
+}
+
+func TestNamePolicy_FullNames(t *testing.T) {
+	p := NewNamePolicy(nil, nil, true, MatchWord)
+
+	assert.NoError(t, p.CheckFullName("Root Admin"), "reserved names only apply to usernames")
+	assert.NoError(t, p.CheckFullName("Dick Van Dyke"), "words that are also given names aren't listed")
+	assert.NoError(t, p.CheckFullName("Scunthorpe Resident"))
+	assert.ErrorIs(t, p.CheckFullName("John Fuck"), ErrFullNameRejected)
+}
+
+func TestNamePolicy_Extended(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reserved.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# brand names\nAcme\n\nwidgetco\n"), 0o644))
+	reserved, err := LoadWordList(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Acme", "widgetco"}, reserved)
+
+	p := NewNamePolicy(reserved, []string{"frak"}, false, "")
+	assert.ErrorIs(t, p.CheckUsername("acme"), ErrUsernameUnavailable)
+	assert.ErrorIs(t, p.CheckUsername("admin"), ErrUsernameUnavailable, "built-in reserved names stay")
+	assert.ErrorIs(t, p.CheckUsername("fr4k"), ErrUsernameUnavailable)
+	assert.ErrorIs(t, p.CheckFullName("Frak Off"), ErrFullNameRejected)
+	assert.NoError(t, p.CheckUsername("shit_happens"), "embedded profanity is off")
+
+	_, err = LoadWordList(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
@@ -1,61 +1,78 @@
 package signup
 
 import (
-	"dvith.com/go-service-api/internal/config"
-	"dvith.com/go-service-api/internal/security/token"
-	"dvith.com/go-service-api/pkg/database"
+	"errors"
+	"math"
+	"strconv"
+
+	"dvith.com/go-service-api/internal/domain/user/dto"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/requestmeta"
+	"dvith.com/go-service-api/pkg/logger"
 	"github.com/gofiber/fiber/v3"
 )
 
-// SignupHandler handles user signup requests
-func SignupHandler(db *database.DBPool, cfg config.Config) fiber.Handler {
+// HeaderDryRun set to true, like the dry_run query parameter, makes
+// SignupHandler only check the request
+const HeaderDryRun = "X-Dry-Run"
+
+// SignupHandler handles user signup requests. The response's Location is
+// profileURL, the new user's profile.
+//
+// A dry run, ?dry_run=true or X-Dry-Run: true, runs the same binding and
+// checks without creating the account, see SignupService.CheckSignup, and
+// answers 200 with the verdict: {"valid": false, "errors": [...]}, errors
+// in the shape of the validation "fields".
+func SignupHandler(service *SignupService, profileURL string) fiber.Handler {
 	return func(c fiber.Ctx) error {
+		dryRun := isDryRun(c)
+
 		// Parse signup request
 		var req SignupRequest
-		if err := c.Bind().Body(&req); err != nil {
+		if err := middleware.BindBody(c, &req); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Invalid request body",
 			})
 		}
 
-		// Validate request fields
-		validationErrors := ValidateSignupRequest(&req)
+		// Validate the shape of the request; the rest is checked by the
+		// service, see ValidationErrors
+		validationErrors := service.ValidateRequest(&req)
 		if len(validationErrors) > 0 {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error":  "Validation failed",
-				"errors": validationErrors,
-			})
+			if dryRun {
+				return dryRunVerdict(c, validationErrors)
+			}
+			return c.Status(fiber.StatusBadRequest).JSON(validationBody(c, validationErrors))
 		}
 
-		// Create repository and service with token manager
-		repo := NewSignupRepository(db)
-		tokenManager := token.NewTokenManager(token.TokenConfig{
-			SecretKey:       cfg.JWTSecretKey,
-			ExpirationTime:  cfg.JWTExpirationTime,
-			RefreshDuration: cfg.JWTRefreshDuration,
-			Issuer:          cfg.JWTIssuer,
-		})
-		service := NewSignupService(repo, tokenManager)
+		meta := requestmeta.FromCtx(c)
+		req.IP, req.UserAgent = meta.IP, meta.UserAgent
+
+		if dryRun {
+			err := service.CheckSignup(middleware.GetRequestContext(c), &req)
+			var problems ValidationErrors
+			switch {
+			case err == nil:
+				return dryRunVerdict(c, nil)
+			case errors.As(err, &problems):
+				return dryRunVerdict(c, problems.Fields())
+			case IsClientError(err) && !errors.Is(err, ErrSignupRateLimited):
+				return dryRunVerdict(c, []ValidationError{rejectionError(err)})
+			}
+			return signupError(c, err)
+		}
 
 		// Register user (hash password and save to database)
-		response, err := service.RegisterUser(c.Context(), &req)
+		response, err := service.RegisterUser(middleware.GetRequestContext(c), &req)
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return signupError(c, err)
 		}
 
 		// Return success response with user data and tokens
+		c.Set(fiber.HeaderLocation, profileURL)
 		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"message": "User registered successfully",
-			"user": fiber.Map{
-				"id":        response.User.ID,
-				"email":     response.User.Email,
-				"fullName":  response.User.FullName,
-				"username":  response.User.Username,
-				"isActive":  response.User.IsActive,
-				"createdAt": response.User.CreatedAt,
-			},
+			"message":       "User registered successfully",
+			"user":          dto.FromUser(response.User),
 			"access_token":  response.AccessToken,
 			"refresh_token": response.RefreshToken,
 			"token_type":    response.TokenType,
@@ -63,3 +80,134 @@ func SignupHandler(db *database.DBPool, cfg config.Config) fiber.Handler {
 		})
 	}
 }
+
+// validationBody is the validation error shape of a signup with problems
+func validationBody(c fiber.Ctx, fields []ValidationError) fiber.Map {
+	body := fiber.Map{
+		"error":  "Validation failed",
+		"fields": fields,
+	}
+	if legacy := middleware.LegacyFieldErrors(c, fields); legacy != nil {
+		body["errors"] = legacy
+	}
+	return body
+}
+
+// signupError answers a rejected or failed signup
+func signupError(c fiber.Ctx, err error) error {
+	var problems ValidationErrors
+	if errors.As(err, &problems) {
+		// error_code stays the first problem's, for clients that predate fields
+		body := validationBody(c, problems.Fields())
+		if code := ErrorCode(problems[0]); code != "" {
+			body["error_code"] = code
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(body)
+	}
+	var limited *RateLimitError
+	if errors.As(err, &limited) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error":      err.Error(),
+			"error_code": ErrorCode(err),
+		})
+	}
+	if IsClientError(err) {
+		body := fiber.Map{"error": err.Error()}
+		if code := ErrorCode(err); code != "" {
+			body["error_code"] = code
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(body)
+	}
+
+	// Infrastructure failures are logged but never exposed to the client
+	logger.Error("failed to register user", map[string]any{
+		"path":  c.Path(),
+		"error": err.Error(),
+	})
+	return middleware.InternalErrorResponse(c, "failed to register user", err)
+}
+
+// isDryRun reports whether the request asks for a dry run
+func isDryRun(c fiber.Ctx) bool {
+	for _, v := range []string{c.Query("dry_run"), c.Get(HeaderDryRun)} {
+		if ok, _ := strconv.ParseBool(v); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// dryRunVerdict answers a dry run, valid when errs is empty
+func dryRunVerdict(c fiber.Ctx, errs []ValidationError) error {
+	if errs == nil {
+		errs = []ValidationError{}
+	}
+	return c.JSON(fiber.Map{
+		"valid":  len(errs) == 0,
+		"errors": errs,
+	})
+}
+
+// rejectionError reports a signup rejection as a field error, on the field
+// it is about. An email or username taken by a signup racing this one is
+// reported on neither, as saving doesn't say which.
+func rejectionError(err error) ValidationError {
+	ve := ValidationError{Code: ErrorCode(err), Message: err.Error()}
+	switch {
+	case errors.Is(err, ErrEmailDomainBlocked), errors.Is(err, ErrEmailDomainNotAllowed), errors.Is(err, ErrEmailUndeliverable), errors.Is(err, ErrEmailTaken):
+		ve.Field, ve.JSONPath = "Email", "/email"
+	case errors.Is(err, ErrUsernameTaken):
+		ve.Field, ve.JSONPath = "Username", "/username"
+	case errors.Is(err, ErrUsernameUnavailable):
+		ve.Field, ve.JSONPath, ve.Code = "Username", "/username", "name_not_allowed"
+	case errors.Is(err, ErrFullNameRejected):
+		ve.Field, ve.JSONPath, ve.Code = "FullName", "/full_name", "name_not_allowed"
+	case errors.Is(err, ErrUnknownClient):
+		ve.Field, ve.JSONPath, ve.Code = "ClientID", "/client_id", "unknown_client"
+	case errors.Is(err, ErrWeakPassword):
+		ve.Field, ve.JSONPath, ve.Code, ve.Message = "Password", "/password", "weak_password", weakPasswordMessage
+	case errors.Is(err, ErrInviteRequired), errors.Is(err, ErrInviteInvalid), errors.Is(err, ErrInviteExhausted), errors.Is(err, ErrInviteExpired):
+		ve.Field, ve.JSONPath = "InviteCode", "/invite_code"
+	case errors.Is(err, ErrUserExists):
+		ve.Code = "user_exists"
+	}
+	return ve
+}
+
+// ErrorCode returns the machine-readable code for a signup rejection, so the
+// frontend can react (show a CAPTCHA, suggest another address, back off)
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrSignupRateLimited):
+		return "signup_rate_limited"
+	case errors.Is(err, ErrEmailTaken):
+		return "email_taken"
+	case errors.Is(err, ErrUsernameTaken):
+		return "username_taken"
+	case errors.Is(err, ErrEmailDomainBlocked):
+		return "email_domain_blocked"
+	case errors.Is(err, ErrEmailDomainNotAllowed):
+		return "email_domain_not_allowed"
+	case errors.Is(err, ErrEmailUndeliverable):
+		return "email_undeliverable"
+	case errors.Is(err, ErrUsernameUnavailable):
+		return "username_unavailable"
+	case errors.Is(err, ErrFullNameRejected):
+		return "full_name_rejected"
+	case errors.Is(err, ErrCaptchaRequired):
+		return "captcha_required"
+	case errors.Is(err, ErrCaptchaFailed):
+		return "captcha_failed"
+	case errors.Is(err, ErrInviteRequired):
+		return "invite_required"
+	case errors.Is(err, ErrInviteInvalid):
+		return "invite_invalid"
+	case errors.Is(err, ErrInviteExhausted):
+		return "invite_exhausted"
+	case errors.Is(err, ErrInviteExpired):
+		return "invite_expired"
+	default:
+		return ""
+	}
+}
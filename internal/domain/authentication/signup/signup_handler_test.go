@@ -0,0 +1,279 @@
+package signup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/events"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/security/authmetrics"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/metrics"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubRepository struct {
+	err   error
+	saved *User
+}
+
+func (r *stubRepository) SaveUser(ctx context.Context, user *User) (*User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	user.ID = uuid.New()
+	r.saved = user
+	return user, nil
+}
+
+func newSignupService(repo Repository) *SignupService {
+	tm := token.NewTokenManager(token.TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  15 * time.Minute,
+		RefreshDuration: 24 * time.Hour,
+		Issuer:          "test",
+	})
+	return NewSignupService(repo, tm)
+}
+
+func newSignupTestApp(repo Repository) *fiber.App {
+	return newSignupTestAppWithService(newSignupService(repo))
+}
+
+func newSignupTestAppWithService(service *SignupService) *fiber.App {
+	app := fiber.New()
+	app.Post("/signup", SignupHandler(service, "https://example.com/api/v1/user/profile"))
+	return app
+}
+
+func postSignup(t *testing.T, app *fiber.App) (*http.Response, string) {
+	body, _ := json.Marshal(SignupRequest{
+		Email:    "john@example.com",
+		Password: "SecurePass123!",
+		FullName: "John Doe",
+		Username: "john_doe",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(raw)
+}
+
+func TestSignupHandler_InfrastructureFailure(t *testing.T) {
+	dbErr := fmt.Errorf("failed to save user: %w", errors.New("dial tcp 10.0.0.5:5432: connection refused"))
+
+	resp, body := postSignup(t, newSignupTestApp(&stubRepository{err: dbErr}))
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Contains(t, body, "failed to register user")
+	assert.NotContains(t, body, "connection refused", "underlying error must not leak to the client")
+	assert.NotContains(t, body, "10.0.0.5")
+}
+
+func TestIsClientError(t *testing.T) {
+	assert.True(t, IsClientError(ErrWeakPassword))
+	assert.True(t, IsClientError(fmt.Errorf("wrapped: %w", ErrUserExists)))
+	assert.False(t, IsClientError(errors.New("connection reset by peer")))
+}
+
+func TestSignupHandler_RejectionCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		service *SignupService
+		status  int
+		code    string
+	}{
+		{
+			name:    "blocked domain",
+			service: newSignupService(&stubRepository{}).WithEmailPolicy(NewEmailPolicy(nil, []string{"EXAMPLE.com"}, false)),
+			status:  http.StatusBadRequest,
+			code:    "email_domain_blocked",
+		},
+		{
+			name:    "not on allow list",
+			service: newSignupService(&stubRepository{}).WithEmailPolicy(NewEmailPolicy([]string{"corp.test"}, nil, false)),
+			status:  http.StatusBadRequest,
+			code:    "email_domain_not_allowed",
+		},
+		{
+			name:    "captcha missing",
+			service: newSignupService(&stubRepository{}).WithCaptcha(&HTTPCaptchaVerifier{}),
+			status:  http.StatusBadRequest,
+			code:    "captcha_required",
+		},
+		{
+			name:    "rate limited",
+			service: newSignupService(&stubRepository{}).WithRateLimiter(NewRateLimiter(cache.NewMemory(), 0, time.Minute)),
+			status:  http.StatusTooManyRequests,
+			code:    "signup_rate_limited",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := postSignup(t, newSignupTestAppWithService(tt.service))
+			assert.Equal(t, tt.status, resp.StatusCode)
+
+			var got struct {
+				ErrorCode string `json:"error_code"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(body), &got))
+			assert.Equal(t, tt.code, got.ErrorCode)
+		})
+	}
+}
+
+func TestSignupHandler_RateLimitRetryAfter(t *testing.T) {
+	service := newSignupService(&stubRepository{}).WithRateLimiter(NewRateLimiter(cache.NewMemory(), 2, time.Minute))
+	app := newSignupTestAppWithService(service)
+
+	// Attempts count even when they fail for other reasons
+	for i := 0; i < 2; i++ {
+		resp, _ := postSignup(t, app)
+		assert.NotEqual(t, http.StatusTooManyRequests, resp.StatusCode)
+	}
+
+	resp, _ := postSignup(t, app)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get(fiber.HeaderRetryAfter))
+}
+
+func TestRegisterUser_Metrics(t *testing.T) {
+	m := authmetrics.New(metrics.NewRegistry())
+	register := func(service *SignupService, password string) {
+		_, _ = service.WithMetrics(m).RegisterUser(context.Background(), &SignupRequest{
+			Email: "john@example.com", Password: password, FullName: "John Doe", Username: "john_doe", IP: "10.0.0.1",
+		})
+	}
+
+	register(newSignupService(&stubRepository{}), "SecurePass123!")
+	register(newSignupService(&stubRepository{err: ErrUserExists}), "SecurePass123!")
+	register(newSignupService(&stubRepository{}), "weak")
+	register(newSignupService(&stubRepository{}).WithRateLimiter(NewRateLimiter(cache.NewMemory(), 0, time.Minute)), "SecurePass123!")
+	register(newSignupService(&stubRepository{}).WithEmailPolicy(NewEmailPolicy(nil, []string{"example.com"}, false)), "SecurePass123!")
+	register(newSignupService(&stubRepository{err: errors.New("conn closed")}), "SecurePass123!")
+
+	assert.Equal(t, float64(1), m.SignupCount(authmetrics.ReasonNone))
+	assert.Equal(t, float64(1), m.SignupCount(authmetrics.ReasonUserExists))
+	assert.Equal(t, float64(1), m.SignupCount(authmetrics.ReasonWeakPassword))
+	assert.Equal(t, float64(1), m.SignupCount(authmetrics.ReasonLocked))
+	assert.Equal(t, float64(1), m.SignupCount(authmetrics.ReasonRejected))
+	assert.Equal(t, float64(1), m.SignupCount(authmetrics.ReasonError))
+}
+
+func TestRegisterUser_NormalizesInputs(t *testing.T) {
+	repo := &stubRepository{}
+
+	_, err := newSignupService(repo).RegisterUser(context.Background(), &SignupRequest{
+		Email: " john@example.com\t", Password: "SecurePass123!", FullName: "  John \t Doe ", Username: "\u200bjohn_doe ",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "john@example.com", repo.saved.Email)
+	assert.Equal(t, "John Doe", repo.saved.FullName.String())
+	assert.Equal(t, "john_doe", repo.saved.Username)
+}
+
+func TestSignupHandler_ReservedUsername(t *testing.T) {
+	service := newSignupService(&stubRepository{}).WithNamePolicy(NewNamePolicy(nil, nil, true, MatchWord))
+	body, _ := json.Marshal(SignupRequest{
+		Email:    "john@example.com",
+		Password: "SecurePass123!",
+		FullName: "John Doe",
+		Username: "4dm1n",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.HeaderValidationErrors, middleware.ValidationErrorsLegacy)
+
+	resp, err := newSignupTestAppWithService(service).Test(req)
+	require.NoError(t, err)
+	raw, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, string(raw), `"fields":[{"field":"Username","json_path":"/username","code":"name_not_allowed","message":"username is not available"}]`)
+	assert.Contains(t, string(raw), `"errors":[{"field":"Username","message":"username is not available"}]`)
+	assert.NotContains(t, string(raw), "reserved")
+
+	_, err = service.RegisterUser(context.Background(), &SignupRequest{
+		Email: "john@example.com", Password: "SecurePass123!", FullName: "John Doe", Username: "admin",
+	})
+	assert.ErrorIs(t, err, ErrUsernameUnavailable)
+	assert.Equal(t, "username_unavailable", ErrorCode(err))
+}
+
+func TestRegisterUser_PublishesEvent(t *testing.T) {
+	bus := events.NewBus()
+	var got []events.UserRegistered
+	events.On(bus, "capture", func(ctx context.Context, e events.UserRegistered) error {
+		got = append(got, e)
+		return nil
+	})
+	events.On(bus, "panics", func(ctx context.Context, e events.UserRegistered) error {
+		panic("subscriber bug")
+	})
+
+	repo := &stubRepository{}
+	resp, err := newSignupService(repo).WithEvents(bus).RegisterUser(context.Background(), &SignupRequest{
+		Email: "john@example.com", Password: "SecurePass123!", FullName: "John Doe", Username: "john_doe", IP: "10.0.0.1", UserAgent: "curl/8.0",
+	})
+	require.NoError(t, err, "a panicking subscriber doesn't fail the signup")
+	require.Len(t, got, 1)
+	assert.Equal(t, resp.User.ID, got[0].UserID)
+	assert.Equal(t, "john@example.com", got[0].Email)
+	assert.Equal(t, "10.0.0.1", got[0].IP)
+	assert.Equal(t, "curl/8.0", got[0].UserAgent)
+
+	// Rejected signups publish nothing
+	_, err = newSignupService(&stubRepository{err: ErrUserExists}).WithEvents(bus).RegisterUser(context.Background(), &SignupRequest{
+		Email: "john@example.com", Password: "SecurePass123!", FullName: "John Doe", Username: "john_doe",
+	})
+	require.Error(t, err)
+	assert.Len(t, got, 1)
+}
+
+func TestSignupHandler_ReportsEveryProblemAtOnce(t *testing.T) {
+	f := newInviteFixture(t)
+	_, err := f.repo.users.SaveUser(f.ctx, &User{Email: "beta1@example.com", Username: "beta_1"})
+	require.NoError(t, err)
+
+	req := inviteRequest(1, "")
+	req.Password = "weakpassword"
+	resp, raw := postSignupAs(t, f.service, f.ctx, "/signup", req, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode, string(raw))
+
+	var got struct {
+		Error  string            `json:"error"`
+		Fields []ValidationError `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &got))
+	assert.Equal(t, "Validation failed", got.Error)
+
+	codes := map[string]string{}
+	for _, ve := range got.Fields {
+		codes[ve.JSONPath] = ve.Code
+	}
+	assert.Equal(t, map[string]string{
+		"/password":    "weak_password",
+		"/email":       "email_taken",
+		"/username":    "username_taken",
+		"/invite_code": "invite_required",
+	}, codes)
+}
@@ -0,0 +1,190 @@
+package signup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/invitation"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var inviteEpoch = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+// invitedUsers redeems invitations on the in-memory stores, as app's
+// memory stores do
+type invitedUsers struct {
+	users       *model.MemoryStore
+	invitations *invitation.MemoryStore
+}
+
+func (r invitedUsers) SaveInvitedUser(ctx context.Context, user *User, codeHash string, now time.Time) (*User, error) {
+	var saved *User
+	_, err := r.invitations.Redeem(ctx, codeHash, now, func() error {
+		var err error
+		saved, err = r.users.SaveUser(ctx, user)
+		return err
+	})
+	return saved, err
+}
+
+func (r invitedUsers) CheckInvitation(ctx context.Context, codeHash string, now time.Time) error {
+	return r.invitations.Check(ctx, codeHash, now)
+}
+
+type inviteFixture struct {
+	ctx     context.Context
+	repo    invitedUsers
+	service *SignupService
+	now     time.Time
+}
+
+func newInviteFixture(t *testing.T) *inviteFixture {
+	f := &inviteFixture{
+		ctx: tenant.WithID(context.Background(), uuid.New()),
+		repo: invitedUsers{
+			users:       model.NewMemoryStore(),
+			invitations: invitation.NewMemoryStore(),
+		},
+		now: inviteEpoch,
+	}
+	f.service = newSignupService(f.repo.users).WithInvitations(f.repo).WithClock(func() time.Time { return f.now })
+	return f
+}
+
+func (f *inviteFixture) invite(t *testing.T, code string, maxUses int) {
+	t.Helper()
+	require.NoError(t, f.repo.invitations.Create(f.ctx, &invitation.Invitation{
+		CodeHash:  invitation.HashCode(code),
+		MaxUses:   maxUses,
+		ExpiresAt: inviteEpoch.Add(24 * time.Hour),
+	}))
+}
+
+func inviteRequest(n int, code string) *SignupRequest {
+	return &SignupRequest{
+		Email:      fmt.Sprintf("beta%d@example.com", n),
+		Password:   "SecurePass123!",
+		FullName:   "Beta Tester",
+		Username:   fmt.Sprintf("beta_%d", n),
+		InviteCode: code,
+	}
+}
+
+func TestRegisterUser_ConcurrentRedemptionsOfSingleUseCode(t *testing.T) {
+	f := newInviteFixture(t)
+	f.invite(t, "ONLYONCE", 1)
+
+	errs := make([]error, 10)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = f.service.RegisterUser(f.ctx, inviteRequest(i, "ONLYONCE"))
+		}()
+	}
+	wg.Wait()
+
+	var succeeded int
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrInviteExhausted)
+		assert.Equal(t, "invite_exhausted", ErrorCode(err))
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestRegisterUser_InviteCodeChecks(t *testing.T) {
+	f := newInviteFixture(t)
+	f.invite(t, "BETA", 5)
+
+	_, err := f.service.RegisterUser(f.ctx, inviteRequest(1, ""))
+	assert.ErrorIs(t, err, ErrInviteRequired)
+	_, err = f.service.RegisterUser(f.ctx, inviteRequest(1, "GUESS"))
+	assert.ErrorIs(t, err, ErrInviteInvalid)
+
+	f.now = inviteEpoch.Add(24 * time.Hour)
+	_, err = f.service.RegisterUser(f.ctx, inviteRequest(1, "BETA"))
+	assert.ErrorIs(t, err, ErrInviteExpired)
+	assert.Equal(t, "invite_expired", ErrorCode(err))
+
+	_, err = f.repo.users.FindByEmail(f.ctx, "beta1@example.com", model.ActiveOnly)
+	assert.ErrorIs(t, err, model.ErrUserNotFound, "rejected signups save nothing")
+}
+
+func TestRegisterUser_FailedSignupDoesNotUseInvite(t *testing.T) {
+	f := newInviteFixture(t)
+	f.invite(t, "BETA", 1)
+	_, err := f.repo.users.SaveUser(f.ctx, &User{Email: "beta1@example.com", Username: "taken"})
+	require.NoError(t, err)
+
+	_, err = f.service.RegisterUser(f.ctx, inviteRequest(1, "BETA"))
+	assert.ErrorIs(t, err, ErrUserExists)
+
+	_, err = f.service.RegisterUser(f.ctx, inviteRequest(2, "BETA"))
+	assert.NoError(t, err, "the code is only used by a signup that succeeds")
+}
+
+func postInvitedSignup(t *testing.T, service *SignupService, ctx context.Context, req *SignupRequest) (int, string) {
+	t.Helper()
+	app := fiber.New()
+	app.Post("/signup", func(c fiber.Ctx) error {
+		c.Locals(middleware.ContextKeyRequestContext, ctx)
+		return c.Next()
+	}, SignupHandler(service, "https://example.com/api/v1/user/profile"))
+
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+	httpReq := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(httpReq)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(raw)
+}
+
+func TestSignupHandler_Invitations(t *testing.T) {
+	f := newInviteFixture(t)
+	f.invite(t, "SECRETCODE", 1)
+
+	status, body := postInvitedSignup(t, f.service, f.ctx, inviteRequest(1, ""))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, `"error_code":"invite_required"`)
+
+	status, body = postInvitedSignup(t, f.service, f.ctx, inviteRequest(1, "SECRETCODE"))
+	require.Equal(t, http.StatusCreated, status, body)
+	assert.NotContains(t, body, "SECRETCODE", "the response doesn't echo the code")
+
+	status, body = postInvitedSignup(t, f.service, f.ctx, inviteRequest(2, "SECRETCODE"))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, `"error_code":"invite_exhausted"`)
+}
+
+func TestSignupHandler_InvitationsOff(t *testing.T) {
+	repo := &stubRepository{}
+	service := newSignupService(repo)
+
+	status, body := postInvitedSignup(t, service, context.Background(), inviteRequest(1, ""))
+	assert.Equal(t, http.StatusCreated, status, body)
+
+	status, body = postInvitedSignup(t, service, context.Background(), inviteRequest(2, "ANYTHING"))
+	assert.Equal(t, http.StatusCreated, status, "a code is ignored while signup is open: %s", body)
+}
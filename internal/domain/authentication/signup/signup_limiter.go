@@ -0,0 +1,63 @@
+package signup
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/clock"
+)
+
+// ErrSignupRateLimited is returned when an IP has used up its signup allowance
+var ErrSignupRateLimited = errors.New("too many signups from this address, try again later")
+
+// RateLimiter caps signup attempts per client IP over a fixed window
+type RateLimiter struct {
+	cache  cache.Cache
+	limit  int
+	window time.Duration
+	now    func() time.Time
+}
+
+// window is the counter for one IP's current window
+type window struct {
+	count   atomic.Int64
+	resetAt time.Time
+}
+
+// NewRateLimiter allows limit signup attempts per IP every window
+func NewRateLimiter(c cache.Cache, limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		cache:  c,
+		limit:  limit,
+		window: window,
+		now:    clock.Now,
+	}
+}
+
+// WithClock replaces the time source, for tests
+func (l *RateLimiter) WithClock(now func() time.Time) *RateLimiter {
+	l.now = now
+	return l
+}
+
+// Allow counts an attempt from ip and reports whether it is within the
+// limit. When it isn't, retryAfter is the time left in the window.
+func (l *RateLimiter) Allow(ip string) (ok bool, retryAfter time.Duration) {
+	key := "signup_rate:" + ip
+	now := l.now()
+
+	l.cache.Add(key, &window{resetAt: now.Add(l.window)}, l.window)
+	v, found := l.cache.Get(key)
+	if !found {
+		// Expired between Add and Get; the attempt opens a new window
+		return true, 0
+	}
+
+	w := v.(*window)
+	if w.count.Add(1) > int64(l.limit) {
+		return false, w.resetAt.Sub(now)
+	}
+	return true, 0
+}
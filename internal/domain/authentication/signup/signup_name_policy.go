@@ -0,0 +1,166 @@
+package signup
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// reservedUsernames are names that could pass for the service or its staff
+//
+//go:embed reserved_usernames.txt
+var reservedUsernames string
+
+// profaneWords is a starter list of profanity, extended through config
+//
+//go:embed profane_words.txt
+var profaneWords string
+
+// Name rejections. Both are deliberately vague, so clients can't tell a
+// reserved name from a profane one or learn what the lists contain.
+var (
+	ErrUsernameUnavailable = errors.New("username is not available")
+	ErrFullNameRejected    = errors.New("full name is not accepted")
+)
+
+// NameMatch is how NamePolicy finds listed words in a name
+type NameMatch string
+
+const (
+	// MatchWord matches listed words only as whole words of the name, split
+	// on anything that isn't a letter or digit: "admin_team" matches admin,
+	// "radmin" doesn't
+	MatchWord NameMatch = "word"
+	// MatchSubstring matches listed words anywhere in the name, so "radmin"
+	// matches admin. It catches more evasions but also innocent names that
+	// happen to contain a listed word.
+	MatchSubstring NameMatch = "substring"
+)
+
+// NamePolicy screens usernames against reserved names and profanity, and
+// full names against profanity. Matching is case-insensitive and also
+// tries the name with leetspeak folded, so "4dm1n" is caught as admin.
+type NamePolicy struct {
+	reserved map[string]bool
+	profane  map[string]bool
+	match    NameMatch
+}
+
+// NewNamePolicy creates a policy from extra reserved names and profane
+// words on top of the embedded reserved list. When blockProfanity is set,
+// the embedded profanity list is used too. An empty match means MatchWord.
+func NewNamePolicy(reserved, profane []string, blockProfanity bool, match NameMatch) *NamePolicy {
+	if match == "" {
+		match = MatchWord
+	}
+	p := &NamePolicy{
+		reserved: wordSet(append(listLines(reservedUsernames), reserved...)),
+		profane:  wordSet(profane),
+		match:    match,
+	}
+	if blockProfanity {
+		for w := range wordSet(listLines(profaneWords)) {
+			p.profane[w] = true
+		}
+	}
+	return p
+}
+
+// LoadWordList reads a word list file: one word per line, blank lines and
+// lines starting with # ignored. An empty path returns no words.
+func LoadWordList(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read word list: %w", err)
+	}
+	return listLines(string(data)), nil
+}
+
+// CheckUsername returns ErrUsernameUnavailable when username is reserved
+// or profane
+func (p *NamePolicy) CheckUsername(username string) error {
+	if p.contains(p.reserved, username) || p.contains(p.profane, username) {
+		return ErrUsernameUnavailable
+	}
+	return nil
+}
+
+// CheckFullName returns ErrFullNameRejected when the name is profane.
+// Reserved names are fine here; someone may well be called Root.
+func (p *NamePolicy) CheckFullName(name string) error {
+	if p.contains(p.profane, name) {
+		return ErrFullNameRejected
+	}
+	return nil
+}
+
+// contains reports whether name, as typed or leet-folded, contains a word
+// of set under the policy's match mode
+func (p *NamePolicy) contains(set map[string]bool, name string) bool {
+	if len(set) == 0 {
+		return false
+	}
+	lower := strings.ToLower(name)
+	for _, variant := range []string{lower, foldLeet(lower)} {
+		if p.match == MatchSubstring {
+			for w := range set {
+				if strings.Contains(variant, w) {
+					return true
+				}
+			}
+			continue
+		}
+		for _, word := range strings.FieldsFunc(variant, isSeparator) {
+			// "admin42" is as much admin as "admin"
+			if set[word] || set[strings.TrimRight(word, "0123456789")] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// leet maps the usual letter substitutions back to letters
+var leet = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b",
+	"@", "a", "$", "s", "!", "i", "|", "l",
+)
+
+func foldLeet(s string) string {
+	return leet.Replace(s)
+}
+
+// isSeparator splits names into words. The leetspeak symbols are kept
+// inside words so folding can turn them into letters.
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("@$!|", r)
+}
+
+func listLines(list string) []string {
+	var words []string
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words
+}
+
+func wordSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w != "" {
+			set[w] = true
+		}
+	}
+	return set
+}
@@ -5,24 +5,16 @@ import (
 	"fmt"
 	"time"
 
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/invitation"
+	"dvith.com/go-service-api/internal/outbox"
 	"dvith.com/go-service-api/pkg/database"
-	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
-// User represents a user in the system
-type User struct {
-	ID            uuid.UUID  `db:"id" json:"id"`
-	Email         string     `db:"email" json:"email"`
-	Password      string     `db:"password" json:"-"`
-	FullName      string     `db:"full_name" json:"full_name"`
-	Username      string     `db:"username" json:"username"`
-	IsActive      bool       `db:"is_active" json:"is_active"`
-	EmailVerified bool       `db:"email_verified" json:"email_verified"`
-	VerifiedAt    *time.Time `db:"verified_at" json:"verified_at"`
-	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
-	UpdatedAt     time.Time  `db:"updated_at" json:"updated_at"`
-	DeletedAt     *time.Time `db:"deleted_at" json:"deleted_at"`
-}
+// User is the shared user model
+type User = model.User
 
 // SignupRepository handles user signup operations
 type SignupRepository struct {
@@ -36,66 +28,74 @@ func NewSignupRepository(db *database.DBPool) *SignupRepository {
 	}
 }
 
-// SaveUser saves a new user to the database
+// SaveUser saves a new user to the database and records a user.registered
+// outbox event in the same transaction
 func (repo *SignupRepository) SaveUser(ctx context.Context, user *User) (*User, error) {
+	return repo.saveUser(ctx, user, nil)
+}
+
+// SaveInvitedUser implements InviteRepository. The invitation is redeemed
+// first, so signups racing for its last use wait on its row.
+func (repo *SignupRepository) SaveInvitedUser(ctx context.Context, user *User, codeHash string, now time.Time) (*User, error) {
+	return repo.saveUser(ctx, user, func(tx pgx.Tx) error {
+		_, err := invitation.NewRepository(tx).Redeem(ctx, codeHash, now)
+		return err
+	})
+}
+
+// Availability implements AvailabilityChecker
+func (repo *SignupRepository) Availability(ctx context.Context, email, username string) (model.Availability, error) {
+	return model.NewUserRepository(repo.db).Availability(ctx, email, username)
+}
+
+// CheckInvitation implements InviteChecker
+func (repo *SignupRepository) CheckInvitation(ctx context.Context, codeHash string, now time.Time) error {
+	return invitation.NewRepository(repo.db).Check(ctx, codeHash, now)
+}
+
+// saveUser saves user, running before first in the transaction
+func (repo *SignupRepository) saveUser(ctx context.Context, user *User, before func(tx pgx.Tx) error) (*User, error) {
 	if user == nil {
 		return nil, fmt.Errorf("user cannot be nil")
 	}
 
-	// Generate new UUID if not provided
-	if user.ID == uuid.Nil {
-		user.ID = uuid.New()
-	}
-
-	// Set timestamps
-	now := time.Now()
-	user.CreatedAt = now
-	user.UpdatedAt = now
-
 	// Set default values
 	if !user.IsActive {
 		user.IsActive = true // default is active
 	}
 
-	query := `
-		INSERT INTO users (id, email, password, full_name, username, is_active, email_verified, verified_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		RETURNING id, email, password, full_name, username, is_active, email_verified, verified_at, created_at, updated_at, deleted_at
-	`
-
-	row := repo.db.QueryRow(
-		ctx,
-		query,
-		user.ID,
-		user.Email,
-		user.Password,
-		user.FullName,
-		user.Username,
-		user.IsActive,
-		user.EmailVerified,
-		user.VerifiedAt,
-		user.CreatedAt,
-		user.UpdatedAt,
-	)
-
-	// Scan the returned row
-	err := row.Scan(
-		&user.ID,
-		&user.Email,
-		&user.Password,
-		&user.FullName,
-		&user.Username,
-		&user.IsActive,
-		&user.EmailVerified,
-		&user.VerifiedAt,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.DeletedAt,
-	)
+	var saved *User
+	reset := func() { saved = nil }
+	err := repo.db.WithTxRetry(ctx, database.TxRetryOptions{Reset: reset}, func(tx pgx.Tx) error {
+		if before != nil {
+			if err := before(tx); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		saved, err = model.NewUserRepository(tx).SaveUser(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		if err := audit.Record(ctx, tx, audit.Entry{UserID: saved.ID, Action: audit.ActionUserRegistered}); err != nil {
+			return err
+		}
+
+		return outbox.Enqueue(ctx, tx, outbox.EventUserRegistered, saved.ID, map[string]any{
+			"tenant_id": saved.TenantID,
+			"email":     saved.Email,
+			"username":  saved.Username,
+		})
+	})
 
 	if err != nil {
+		if IsClientError(err) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to save user: %w", err)
 	}
 
-	return user, nil
+	return saved, nil
 }
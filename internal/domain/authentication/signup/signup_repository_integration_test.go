@@ -0,0 +1,56 @@
+package signup
+
+import (
+	"context"
+	"testing"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/outbox"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/internal/testdb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) { testdb.Main(m) }
+
+// defaultTenantID is the tenant seeded by the tenants migration
+var defaultTenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+func TestSignupRepository_SaveUser(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewSignupRepository(db)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+
+	saved, err := repo.SaveUser(ctx, &User{Email: "alice@example.com", Password: "hash", Username: "alice"})
+	require.NoError(t, err)
+	assert.True(t, saved.IsActive, "signups are active")
+
+	var action string
+	require.NoError(t, db.QueryRow(ctx, "SELECT action FROM audit_events WHERE user_id = $1", saved.ID).Scan(&action))
+	assert.Equal(t, audit.ActionUserRegistered, action)
+
+	var eventType string
+	require.NoError(t, db.QueryRow(ctx, "SELECT event_type FROM events_outbox WHERE aggregate_id = $1", saved.ID).Scan(&eventType))
+	assert.Equal(t, outbox.EventUserRegistered, eventType)
+}
+
+func TestSignupRepository_SaveUser_Duplicate(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewSignupRepository(db)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+
+	_, err := repo.SaveUser(ctx, &User{Email: "alice@example.com", Password: "hash", Username: "alice"})
+	require.NoError(t, err)
+
+	_, err = repo.SaveUser(ctx, &User{Email: "alice@example.com", Password: "hash", Username: "other"})
+	assert.ErrorIs(t, err, ErrUserExists)
+	assert.True(t, IsClientError(err), "the handler answers 409 rather than 500")
+	_, err = repo.SaveUser(ctx, &User{Email: "other@example.com", Password: "hash", Username: "alice"})
+	assert.ErrorIs(t, err, ErrUserExists)
+
+	var events int
+	require.NoError(t, db.QueryRow(ctx, "SELECT count(*) FROM events_outbox").Scan(&events))
+	assert.Equal(t, 1, events, "failed signups leave no outbox event behind")
+}
@@ -2,18 +2,43 @@ package signup
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"dvith.com/go-service-api/internal/autorole"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/events"
+	"dvith.com/go-service-api/internal/invitation"
+	"dvith.com/go-service-api/internal/security/authmetrics"
 	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
 	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/crypto"
+	"dvith.com/go-service-api/pkg/emailaddr"
+	"dvith.com/go-service-api/pkg/logger"
 )
 
 // SignupRequest represents the user signup request
 type SignupRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8,max=255"`
-	FullName string `json:"full_name" validate:"required,max=255"`
-	Username string `json:"username" validate:"required,min=3,max=100"`
+	// maxbytes bounds each field before validation, see
+	// middleware.ValidateStruct
+	Email    string `json:"email" validate:"required,rfc_email" maxbytes:"320"`
+	Password string `json:"password" validate:"required,min=8,max=255" maxbytes:"1024"`
+	FullName string `json:"full_name" validate:"required,max=255" maxbytes:"1024"`
+	Username string `json:"username" validate:"required,min=3,max=100" maxbytes:"100"`
+	ClientID string `json:"client_id,omitempty" validate:"omitempty,max=100" maxbytes:"100"`
+	// CaptchaToken is required when a CAPTCHA verifier is configured
+	CaptchaToken string `json:"captcha_token,omitempty" maxbytes:"8192"`
+	// InviteCode is required while signup is invite only, see
+	// SignupService.WithInvitations
+	InviteCode string `json:"invite_code,omitempty" maxbytes:"100"`
+	// IP is the client address, set by the handler
+	IP string `json:"-"`
+	// UserAgent is the client's User-Agent header, set by the handler
+	UserAgent string `json:"-"`
 }
 
 // SignupResponse represents the signup response with user and tokens
@@ -25,34 +50,363 @@ type SignupResponse struct {
 	ExpiresIn    int64  `json:"expires_in"`
 }
 
+// Signup errors caused by the request rather than by the server. Anything
+// else returned by RegisterUser is an infrastructure failure.
+var (
+	ErrNilRequest    = errors.New("signup request cannot be nil")
+	ErrWeakPassword  = errors.New("password must contain uppercase letters, lowercase letters, numbers, and special characters")
+	ErrUserExists    = model.ErrUserExists
+	ErrUnknownClient = token.ErrUnknownClient
+	// ErrEmailUndeliverable means DNS says the email's domain takes no mail
+	ErrEmailUndeliverable = emailaddr.ErrNoMailServer
+	ErrInviteRequired     = errors.New("an invitation code is required to sign up")
+	ErrInviteInvalid      = invitation.ErrInvalid
+	ErrInviteExhausted    = invitation.ErrExhausted
+	ErrInviteExpired      = invitation.ErrExpired
+)
+
+var (
+	// ErrEmailTaken is ErrUserExists for the email
+	ErrEmailTaken error = &takenError{"email is already registered"}
+	// ErrUsernameTaken is ErrUserExists for the username
+	ErrUsernameTaken error = &takenError{"username is already taken"}
+)
+
+// takenError is ErrUserExists narrowed to one field
+type takenError struct {
+	msg string
+}
+
+func (e *takenError) Error() string { return e.msg }
+
+// Is makes errors.Is(err, ErrUserExists) match
+func (e *takenError) Is(target error) bool { return target == ErrUserExists }
+
+// IsClientError reports whether err from RegisterUser should be reported
+// to the client as a bad request
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrNilRequest) ||
+		errors.Is(err, ErrWeakPassword) ||
+		errors.Is(err, ErrUserExists) ||
+		errors.Is(err, ErrUnknownClient) ||
+		errors.Is(err, ErrSignupRateLimited) ||
+		errors.Is(err, ErrEmailDomainBlocked) ||
+		errors.Is(err, ErrEmailDomainNotAllowed) ||
+		errors.Is(err, ErrEmailUndeliverable) ||
+		errors.Is(err, ErrUsernameUnavailable) ||
+		errors.Is(err, ErrFullNameRejected) ||
+		errors.Is(err, ErrCaptchaRequired) ||
+		errors.Is(err, ErrCaptchaFailed) ||
+		errors.Is(err, ErrInviteRequired) ||
+		errors.Is(err, ErrInviteInvalid) ||
+		errors.Is(err, ErrInviteExhausted) ||
+		errors.Is(err, ErrInviteExpired)
+}
+
+// RateLimitError carries how long a rate-limited client should wait
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string { return ErrSignupRateLimited.Error() }
+
+// Is makes errors.Is(err, ErrSignupRateLimited) match
+func (e *RateLimitError) Is(target error) bool { return target == ErrSignupRateLimited }
+
+// Repository persists new users for the signup service
+type Repository interface {
+	SaveUser(ctx context.Context, user *User) (*User, error)
+}
+
+// InviteRepository saves users who sign up with an invitation code,
+// redeeming one use of the invitation with codeHash at now in the same
+// transaction. It returns an invitation error, saving nothing, when the
+// code can't be redeemed.
+type InviteRepository interface {
+	SaveInvitedUser(ctx context.Context, user *User, codeHash string, now time.Time) (*User, error)
+}
+
+// AvailabilityChecker is implemented by repositories that can tell, without
+// saving, whether the email and the username of a new user are already
+// taken
+type AvailabilityChecker interface {
+	Availability(ctx context.Context, email, username string) (model.Availability, error)
+}
+
+// InviteChecker is implemented by invite repositories that can tell,
+// without redeeming, why an invitation code couldn't be redeemed at now
+type InviteChecker interface {
+	CheckInvitation(ctx context.Context, codeHash string, now time.Time) error
+}
+
+// SessionCreator opens the session a new account is signed in with
+type SessionCreator interface {
+	Create(ctx context.Context, s *session.Session) error
+}
+
 // SignupService handles user signup operations
 type SignupService struct {
-	repo         *SignupRepository
+	repo         Repository
 	tokenManager *token.TokenManager
+	limiter      *RateLimiter
+	emailPolicy  *EmailPolicy
+	mx           *emailaddr.MXChecker
+	namePolicy   *NamePolicy
+	captcha      CaptchaVerifier
+	sessions     SessionCreator
+	metrics      *authmetrics.Metrics
+	events       *events.Bus
+	invites      InviteRepository
+	domainRoles  *autorole.Assigner
+	hasher       hashpassword.Hasher
+	now          func() time.Time
 }
 
 // NewSignupService creates a new signup service with token manager
-func NewSignupService(repo *SignupRepository, tokenManager *token.TokenManager) *SignupService {
+func NewSignupService(repo Repository, tokenManager *token.TokenManager) *SignupService {
 	return &SignupService{
 		repo:         repo,
 		tokenManager: tokenManager,
+		metrics:      authmetrics.Default,
+		hasher:       hashpassword.Default,
+		now:          clock.Now,
+	}
+}
+
+// WithHasher replaces hashpassword.Default as the password hasher
+func (s *SignupService) WithHasher(h hashpassword.Hasher) *SignupService {
+	s.hasher = h
+	return s
+}
+
+// WithClock replaces the time invitation expiry is checked against, for
+// tests
+func (s *SignupService) WithClock(now func() time.Time) *SignupService {
+	s.now = now
+	return s
+}
+
+// WithRateLimiter caps signup attempts per client IP
+func (s *SignupService) WithRateLimiter(l *RateLimiter) *SignupService {
+	s.limiter = l
+	return s
+}
+
+// WithEmailPolicy restricts which email domains may sign up
+func (s *SignupService) WithEmailPolicy(p *EmailPolicy) *SignupService {
+	s.emailPolicy = p
+	return s
+}
+
+// WithMXCheck rejects emails whose domain DNS says can't receive mail.
+// Lookups that time out or fail let the signup through.
+func (s *SignupService) WithMXCheck(c *emailaddr.MXChecker) *SignupService {
+	s.mx = c
+	return s
+}
+
+// WithNamePolicy screens usernames and full names against reserved and
+// profane words
+func (s *SignupService) WithNamePolicy(p *NamePolicy) *SignupService {
+	s.namePolicy = p
+	return s
+}
+
+// ValidateRequest is ValidateSignupRequest without the password policy: the
+// shape of the request. The password and name policies are left to
+// RegisterUser, which reports them along with the email and username being
+// taken, so a request failing several of them hears of all at once.
+func (s *SignupService) ValidateRequest(req *SignupRequest) []ValidationError {
+	var errs []ValidationError
+	for _, ve := range ValidateSignupRequest(req) {
+		if ve.Code != "weak_password" {
+			errs = append(errs, ve)
+		}
 	}
+	return errs
+}
+
+// WithCaptcha requires a verified CAPTCHA token on every signup
+func (s *SignupService) WithCaptcha(v CaptchaVerifier) *SignupService {
+	s.captcha = v
+	return s
+}
+
+// WithSessions opens a session for each new account and binds its tokens
+// to it, as signin does
+func (s *SignupService) WithSessions(sessions SessionCreator) *SignupService {
+	s.sessions = sessions
+	return s
+}
+
+// WithMetrics replaces the metrics signups are recorded on
+func (s *SignupService) WithMetrics(m *authmetrics.Metrics) *SignupService {
+	s.metrics = m
+	return s
+}
+
+// WithEvents publishes an events.UserRegistered for every new account
+func (s *SignupService) WithEvents(bus *events.Bus) *SignupService {
+	s.events = bus
+	return s
+}
+
+// WithInvitations makes signup invite only: every request needs an
+// invite_code, and users are saved through repo, which redeems it
+func (s *SignupService) WithInvitations(repo InviteRepository) *SignupService {
+	s.invites = repo
+	return s
 }
 
 // RegisterUser registers a new user with password hashing and returns tokens
+// WithDomainRoles grants new users the role their email domain is assigned
+// and embeds it in the signup tokens, unless it waits for the email to be
+// verified
+func (s *SignupService) WithDomainRoles(a *autorole.Assigner) *SignupService {
+	s.domainRoles = a
+	return s
+}
+
 func (s *SignupService) RegisterUser(ctx context.Context, req *SignupRequest) (*SignupResponse, error) {
+	resp, err := s.registerUser(ctx, req)
+	s.metrics.Signup(signupReason(err))
+	return resp, err
+}
+
+// signupReason maps a RegisterUser error to its metrics reason
+func signupReason(err error) string {
+	switch {
+	case err == nil:
+		return authmetrics.ReasonNone
+	case errors.Is(err, ErrSignupRateLimited):
+		return authmetrics.ReasonLocked
+	case errors.Is(err, ErrUserExists):
+		return authmetrics.ReasonUserExists
+	case errors.Is(err, ErrWeakPassword):
+		return authmetrics.ReasonWeakPassword
+	case errors.Is(err, ErrUnknownClient):
+		return authmetrics.ReasonUnknownClient
+	case errors.Is(err, ErrNilRequest):
+		return authmetrics.ReasonInvalid
+	case IsClientError(err):
+		// Email and name policies, captcha
+		return authmetrics.ReasonRejected
+	default:
+		return authmetrics.ReasonError
+	}
+}
+
+// CheckSignup runs the checks of RegisterUser without saving anything or
+// issuing tokens, for dry runs. Each call counts against the IP's rate
+// limit like a signup, so it can't be used to enumerate accounts any
+// faster. The CAPTCHA isn't verified, as its token can only be redeemed
+// once.
+func (s *SignupService) CheckSignup(ctx context.Context, req *SignupRequest) error {
+	return s.checkRequest(ctx, req)
+}
+
+// checkRequest runs the checks RegisterUser and CheckSignup share, the
+// ones that need nothing saved. The rate limit and infrastructure failures
+// end them at once; the problems with the request itself, the policies on
+// its fields, the email and username being taken and the invitation not
+// being redeemable, are independent and returned together as
+// ValidationErrors. Availability and invitations are looked up when the
+// repositories are an AvailabilityChecker and an InviteChecker.
+func (s *SignupService) checkRequest(ctx context.Context, req *SignupRequest) error {
 	if req == nil {
-		return nil, fmt.Errorf("signup request cannot be nil")
+		return ErrNilRequest
+	}
+	req.Normalize()
+
+	// Every attempt counts against the IP, so bots can't probe for free
+	if s.limiter != nil {
+		if ok, retryAfter := s.limiter.Allow(req.IP); !ok {
+			return &RateLimitError{RetryAfter: retryAfter}
+		}
+	}
+
+	var problems ValidationErrors
+	if s.emailPolicy != nil {
+		if err := s.emailPolicy.Check(req.Email); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if s.mx != nil {
+		if err := s.mx.Check(ctx, req.Email); err != nil {
+			if !IsClientError(err) {
+				return err
+			}
+			problems = append(problems, err)
+		}
+	}
+
+	if s.namePolicy != nil {
+		if err := s.namePolicy.CheckUsername(req.Username); err != nil {
+			problems = append(problems, err)
+		}
+		if err := s.namePolicy.CheckFullName(req.FullName); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	// Tokens can only be issued to configured clients
+	if !s.tokenManager.HasClient(req.ClientID) {
+		problems = append(problems, ErrUnknownClient)
 	}
 
 	// Validate password strength
 	strength := ValidatePasswordStrength(req.Password)
 	if !strength.IsValid {
-		return nil, fmt.Errorf("password must contain uppercase letters, lowercase letters, numbers, and special characters")
+		problems = append(problems, ErrWeakPassword)
+	}
+
+	if checker, ok := s.repo.(AvailabilityChecker); ok {
+		a, err := checker.Availability(ctx, req.Email, req.Username)
+		if err != nil {
+			return fmt.Errorf("failed to check availability: %w", err)
+		}
+		if a.EmailTaken {
+			problems = append(problems, ErrEmailTaken)
+		}
+		if a.UsernameTaken {
+			problems = append(problems, ErrUsernameTaken)
+		}
+	}
+
+	if s.invites != nil {
+		if strings.TrimSpace(req.InviteCode) == "" {
+			problems = append(problems, ErrInviteRequired)
+		} else if checker, ok := s.invites.(InviteChecker); ok {
+			if err := checker.CheckInvitation(ctx, invitation.HashCode(req.InviteCode), s.now()); err != nil {
+				if !IsClientError(err) {
+					return fmt.Errorf("failed to check invitation: %w", err)
+				}
+				problems = append(problems, err)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+func (s *SignupService) registerUser(ctx context.Context, req *SignupRequest) (*SignupResponse, error) {
+	if err := s.checkRequest(ctx, req); err != nil {
+		return nil, err
+	}
+
+	// The provider is called last, once the request is otherwise acceptable
+	if s.captcha != nil {
+		if err := s.captcha.VerifyCaptcha(ctx, req.CaptchaToken, req.IP); err != nil {
+			return nil, err
+		}
 	}
 
 	// Hash the password
-	hashedPassword, err := hashpassword.HashPassword(req.Password)
+	hashedPassword, err := s.hasher.Hash(req.Password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -61,19 +415,46 @@ func (s *SignupService) RegisterUser(ctx context.Context, req *SignupRequest) (*
 	user := &User{
 		Email:    req.Email,
 		Password: hashedPassword,
-		FullName: req.FullName,
+		FullName: crypto.EncryptedString(req.FullName),
 		Username: req.Username,
 		IsActive: true,
 	}
 
-	// Save user to database
-	savedUser, err := s.repo.SaveUser(ctx, user)
+	// Save user to database, redeeming the invitation with it
+	var savedUser *User
+	if s.invites != nil {
+		savedUser, err = s.invites.SaveInvitedUser(ctx, user, invitation.HashCode(req.InviteCode), s.now())
+	} else {
+		savedUser, err = s.repo.SaveUser(ctx, user)
+	}
 	if err != nil {
+		// Lost a race with another signup or redemption since the checks
+		if IsClientError(err) {
+			return nil, ValidationErrors{err}
+		}
 		return nil, fmt.Errorf("failed to register user: %w", err)
 	}
+	s.events.Publish(ctx, events.UserRegistered{
+		UserID:    savedUser.ID,
+		TenantID:  savedUser.TenantID,
+		Email:     savedUser.Email,
+		ClientID:  req.ClientID,
+		IP:        req.IP,
+		UserAgent: req.UserAgent,
+		At:        savedUser.CreatedAt,
+	})
+
+	opts := []token.ClaimOption{token.WithTenant(savedUser.TenantID), token.WithRoles(s.signupRoles(ctx, savedUser)...), token.WithClient(req.ClientID)}
+	if s.sessions != nil {
+		sess := &session.Session{UserID: savedUser.ID, IP: req.IP, UserAgent: req.UserAgent}
+		if err := s.sessions.Create(ctx, sess); err != nil {
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+		opts = append(opts, token.WithSession(sess.ID))
+	}
 
 	// Generate JWT tokens
-	tokenPair, err := s.tokenManager.GenerateTokenPair(savedUser.ID)
+	tokenPair, err := s.tokenManager.GenerateTokenPair(savedUser.ID, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate tokens: %w", err)
 	}
@@ -86,3 +467,25 @@ func (s *SignupService) RegisterUser(ctx context.Context, req *SignupRequest) (*
 		ExpiresIn:    tokenPair.ExpiresIn,
 	}, nil
 }
+
+// signupRoles grants user the role of their email domain and returns the
+// roles of their first token. The account is already saved, so a failed
+// grant is logged and signup goes on without the role.
+func (s *SignupService) signupRoles(ctx context.Context, user *User) []string {
+	roles := []string{user.Role}
+	if s.domainRoles == nil {
+		return roles
+	}
+	role, err := s.domainRoles.Assign(ctx, user.ID, user.Email, user.EmailVerified)
+	if err != nil {
+		logger.Error("failed to assign domain role", map[string]any{
+			"user_id": user.ID.String(),
+			"error":   err.Error(),
+		})
+		return roles
+	}
+	if role != "" && role != user.Role {
+		roles = append(roles, role)
+	}
+	return roles
+}
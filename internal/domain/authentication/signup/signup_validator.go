@@ -1,14 +1,13 @@
 package signup
 
 import (
-	"fmt"
 	"regexp"
+	"strings"
 
-	"github.com/go-playground/validator/v10"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/textnorm"
 )
 
-var validate = validator.New()
-
 // PasswordStrength represents password strength validation rules
 type PasswordStrength struct {
 	HasUppercase bool
@@ -18,10 +17,36 @@ type PasswordStrength struct {
 	IsValid      bool
 }
 
-// ValidationError represents a validation error
-type ValidationError struct {
-	Field   string `json:"field"`
-	Message string `json:"message"`
+// ValidationError represents a validation error, with the JSON pointer
+// and code of middleware.FieldError
+type ValidationError = middleware.FieldError
+
+// weakPasswordMessage is the field error message of ErrWeakPassword
+const weakPasswordMessage = "Password must contain uppercase letters, lowercase letters, numbers, and special characters"
+
+// ValidationErrors are the independent problems found with a signup
+// request, reported together so they can all be fixed in one go. errors.Is
+// matches each of them.
+type ValidationErrors []error
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the problems, for errors.Is and errors.As
+func (e ValidationErrors) Unwrap() []error { return e }
+
+// Fields returns the problems as field errors, see rejectionError
+func (e ValidationErrors) Fields() []ValidationError {
+	fields := make([]ValidationError, len(e))
+	for i, err := range e {
+		fields[i] = rejectionError(err)
+	}
+	return fields
 }
 
 // ValidatePasswordStrength checks if password contains uppercase, lowercase, numbers, and special characters
@@ -37,31 +62,28 @@ func ValidatePasswordStrength(password string) PasswordStrength {
 	return strength
 }
 
-// ValidateSignupRequest validates the signup request
-func ValidateSignupRequest(req *SignupRequest) []ValidationError {
-	var errors []ValidationError
+// Normalize trims the email, username and full name, strips invisible
+// characters from them and collapses the spacing of the full name, see
+// textnorm. It is idempotent, so both the validator and the service apply it.
+func (req *SignupRequest) Normalize() {
+	req.Email = textnorm.Email(req.Email)
+	req.Username = textnorm.Username(req.Username)
+	req.FullName = textnorm.FullName(req.FullName)
+}
 
-	if err := validate.Struct(req); err != nil {
-		for _, err := range err.(validator.ValidationErrors) {
-			ve := ValidationError{
-				Field: err.Field(),
-			}
+// ValidateSignupRequest normalizes and validates the signup request
+func ValidateSignupRequest(req *SignupRequest) []ValidationError {
+	req.Normalize()
 
-			switch err.Tag() {
-			case "required":
-				ve.Message = fmt.Sprintf("%s is required", err.Field())
-			case "email":
-				ve.Message = fmt.Sprintf("%s must be a valid email address", err.Field())
-			case "min":
-				ve.Message = fmt.Sprintf("%s must be at least %s characters", err.Field(), err.Param())
-			case "max":
-				ve.Message = fmt.Sprintf("%s must not exceed %s characters", err.Field(), err.Param())
-			default:
-				ve.Message = fmt.Sprintf("%s is invalid", err.Field())
-			}
+	errors := middleware.ValidateStruct(req)
 
-			errors = append(errors, ve)
-		}
+	if textnorm.HasSpace(req.Username) {
+		errors = append(errors, ValidationError{
+			Field:    "Username",
+			JSONPath: "/username",
+			Code:     "contains_whitespace",
+			Message:  "Username must not contain whitespace",
+		})
 	}
 
 	// Validate password strength if no structural errors
@@ -69,8 +91,10 @@ func ValidateSignupRequest(req *SignupRequest) []ValidationError {
 		strength := ValidatePasswordStrength(req.Password)
 		if !strength.IsValid {
 			ve := ValidationError{
-				Field:   "Password",
-				Message: "Password must contain uppercase letters, lowercase letters, numbers, and special characters",
+				Field:    "Password",
+				JSONPath: "/password",
+				Code:     "weak_password",
+				Message:  weakPasswordMessage,
 			}
 			errors = append(errors, ve)
 		}
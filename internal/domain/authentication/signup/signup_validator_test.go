@@ -1,7 +1,12 @@
 package signup
 
 import (
+	"strings"
 	"testing"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestValidatePasswordStrength(t *testing.T) {
@@ -208,3 +213,161 @@ func TestValidateSignupRequest_PasswordStrength(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSignupRequest_Normalizes(t *testing.T) {
+	tests := []struct {
+		name         string
+		req          SignupRequest
+		wantEmail    string
+		wantUsername string
+		wantFullName string
+		wantErrField string
+	}{
+		{
+			name:         "surrounding whitespace",
+			req:          SignupRequest{Email: " john@example.com ", Username: " john_doe ", FullName: "John Doe  "},
+			wantEmail:    "john@example.com",
+			wantUsername: "john_doe",
+			wantFullName: "John Doe",
+		},
+		{
+			name:         "tabs in full name",
+			req:          SignupRequest{Email: "john@example.com", Username: "john_doe", FullName: "\tJohn\t\tDoe"},
+			wantEmail:    "john@example.com",
+			wantUsername: "john_doe",
+			wantFullName: "John Doe",
+		},
+		{
+			name:         "unicode full name",
+			req:          SignupRequest{Email: "somchai@example.com", Username: "somchai", FullName: "Renée  สมชาย"},
+			wantEmail:    "somchai@example.com",
+			wantUsername: "somchai",
+			wantFullName: "Renée สมชาย",
+		},
+		{
+			name:         "zero-width characters stripped",
+			req:          SignupRequest{Email: "john\u200b@example.com", Username: "\ufeffjohn_doe", FullName: "John\u200b Doe"},
+			wantEmail:    "john@example.com",
+			wantUsername: "john_doe",
+			wantFullName: "John Doe",
+		},
+		{
+			name:         "internationalized domain",
+			req:          SignupRequest{Email: "somchai@ไทย.example", Username: "somchai", FullName: "Somchai"},
+			wantEmail:    "somchai@xn--o3cw4h.example",
+			wantUsername: "somchai",
+			wantFullName: "Somchai",
+		},
+		{
+			name:         "domain without a dot",
+			req:          SignupRequest{Email: "john@localhost", Username: "john_doe", FullName: "John Doe"},
+			wantEmail:    "john@localhost",
+			wantUsername: "john_doe",
+			wantFullName: "John Doe",
+			wantErrField: "Email",
+		},
+		{
+			name:         "username with inner space",
+			req:          SignupRequest{Email: "john@example.com", Username: "john doe", FullName: "John Doe"},
+			wantEmail:    "john@example.com",
+			wantUsername: "john doe",
+			wantFullName: "John Doe",
+			wantErrField: "Username",
+		},
+		{
+			name:         "username with tab",
+			req:          SignupRequest{Email: "john@example.com", Username: "john\tdoe", FullName: "John Doe"},
+			wantEmail:    "john@example.com",
+			wantUsername: "john\tdoe",
+			wantFullName: "John Doe",
+			wantErrField: "Username",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			req.Password = "SecurePass123!"
+
+			errs := ValidateSignupRequest(&req)
+
+			if req.Email != tt.wantEmail || req.Username != tt.wantUsername || req.FullName != tt.wantFullName {
+				t.Errorf("normalized to (%q, %q, %q), want (%q, %q, %q)",
+					req.Email, req.Username, req.FullName, tt.wantEmail, tt.wantUsername, tt.wantFullName)
+			}
+			if tt.wantErrField == "" {
+				if len(errs) != 0 {
+					t.Errorf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || errs[0].Field != tt.wantErrField {
+				t.Errorf("errors = %v, want one for %s", errs, tt.wantErrField)
+			}
+		})
+	}
+}
+
+func TestValidateSignupRequest_ByteLimits(t *testing.T) {
+	valid := func() SignupRequest {
+		return SignupRequest{Email: "john@example.com", Password: "SecurePass123!", FullName: "John Doe", Username: "john_doe"}
+	}
+	tests := []struct {
+		name     string
+		mutate   func(*SignupRequest)
+		wantPath string
+		wantMax  string
+	}{
+		{"email over 320 bytes", func(r *SignupRequest) { r.Email = strings.Repeat("a", 309) + "@example.com" }, "/email", "320"},
+		{"password over 1KB", func(r *SignupRequest) { r.Password = "Aa1!" + strings.Repeat("x", 1021) }, "/password", "1024"},
+		// 34 Thai letters are 34 characters but 102 bytes
+		{"username over 100 bytes", func(r *SignupRequest) { r.Username = strings.Repeat("ก", 34) }, "/username", "100"},
+		{"full name over 1KB", func(r *SignupRequest) { r.FullName = strings.Repeat("x", 1025) }, "/full_name", "1024"},
+		{"captcha token over 8KB", func(r *SignupRequest) { r.CaptchaToken = strings.Repeat("x", 8193) }, "/captcha_token", "8192"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.mutate(&req)
+			req.Username += " " // also invalid, but the size check runs first
+
+			errs := ValidateSignupRequest(&req)
+			require.Len(t, errs, 1, "only the oversized field is reported")
+			assert.Equal(t, tt.wantPath, errs[0].JSONPath)
+			assert.Equal(t, "too_large", errs[0].Code)
+			assert.Equal(t, middleware.Params{"max_bytes": tt.wantMax}, errs[0].Params)
+		})
+	}
+
+	req := valid()
+	label := strings.Repeat("d", 63)
+	req.Email = strings.Repeat("a", 64) + "@" + strings.Join([]string{label, label, label, strings.Repeat("e", 59) + ".com"}, ".")
+	require.Len(t, req.Email, 320)
+	req.Password = "Aa1!" + strings.Repeat("x", 251)
+	req.Username = strings.Repeat("a", 100)
+	assert.Empty(t, ValidateSignupRequest(&req), "values at the limits are accepted")
+}
+
+func FuzzSignupRequest(f *testing.F) {
+	f.Add([]byte(`{"email":"john@example.com","password":"SecurePass123!","full_name":"John Doe","username":"john_doe"}`))
+	f.Add([]byte(`{"email":42,"password":true,"full_name":null,"username":["a"]}`))
+	f.Add([]byte(`{"email":"\u0000@x","username":"\ud800","full_name":"\u200b\u200b"}`))
+	f.Add([]byte(`{"captcha_token":{"a":[[[[]]]]}}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var req SignupRequest
+		if err := middleware.DecodeJSON(data, &req); err != nil {
+			return
+		}
+		errs := ValidateSignupRequest(&req)
+		if len(errs) > 0 {
+			return
+		}
+		if len(req.Email) > 320 || len(req.Password) > 1024 || len(req.Username) > 100 || len(req.FullName) > 1024 {
+			t.Fatalf("oversized request passed validation: %d/%d/%d/%d bytes", len(req.Email), len(req.Password), len(req.Username), len(req.FullName))
+		}
+		if again := ValidateSignupRequest(&req); len(again) > 0 {
+			t.Fatalf("valid request failed revalidation: %v", again)
+		}
+	})
+}
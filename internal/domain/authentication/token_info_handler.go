@@ -0,0 +1,51 @@
+package authentication
+
+import (
+	"time"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// TokenInfoResponse describes the access token a request was made with
+type TokenInfoResponse struct {
+	// TokenID is the jti claim; tokens issued before it existed have none
+	TokenID   string     `json:"token_id,omitempty"`
+	UserID    uuid.UUID  `json:"user_id"`
+	SessionID *uuid.UUID `json:"session_id,omitempty"`
+	Roles     []string   `json:"roles"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+// TokenInfoHandler returns the claims of the caller's access token, so
+// clients can schedule a refresh without decoding the JWT. It must be
+// registered after AuthMiddleware.
+func TokenInfoHandler() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		claims, err := middleware.GetClaimsFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "unauthorized")
+		}
+
+		resp := TokenInfoResponse{
+			TokenID: claims.TokenID(),
+			UserID:  claims.UserID,
+			Roles:   claims.Roles,
+		}
+		if resp.Roles == nil {
+			resp.Roles = []string{}
+		}
+		if claims.SessionID != uuid.Nil {
+			resp.SessionID = &claims.SessionID
+		}
+		if claims.IssuedAt != nil {
+			resp.IssuedAt = claims.IssuedAt.UTC()
+		}
+		if claims.ExpiresAt != nil {
+			resp.ExpiresAt = claims.ExpiresAt.UTC()
+		}
+		return c.JSON(resp)
+	}
+}
@@ -0,0 +1,54 @@
+package authentication
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/security/token"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenInfoHandler(t *testing.T) {
+	tm := token.NewTokenManager(token.TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  15 * time.Minute,
+		RefreshDuration: time.Hour,
+		Issuer:          "test",
+	})
+	userID, sessionID := uuid.New(), uuid.New()
+	access, err := tm.GenerateAccessToken(userID, token.WithRoles("user"), token.WithSession(sessionID))
+	require.NoError(t, err)
+	claims, err := tm.ValidateAccessToken(access)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Get("/token-info", middleware.AuthMiddleware(tm), TokenInfoHandler())
+	req := httptest.NewRequest(http.MethodGet, "/token-info", nil)
+	req.Header.Set("Authorization", "Bearer "+access)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, map[string]any{
+		"token_id":   claims.TokenID(),
+		"user_id":    userID.String(),
+		"session_id": sessionID.String(),
+		"roles":      []any{"user"},
+		"issued_at":  claims.IssuedAt.UTC().Format(time.RFC3339),
+		"expires_at": claims.ExpiresAt.UTC().Format(time.RFC3339),
+	}, body)
+	assert.Equal(t, body["expires_at"], resp.Header.Get(middleware.HeaderTokenExpiresAt))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/token-info", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -1,12 +1,46 @@
 package common
 
 import (
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/domain/common/docs"
 	"dvith.com/go-service-api/internal/domain/common/health"
 	"dvith.com/go-service-api/internal/domain/common/home"
+	"dvith.com/go-service-api/internal/domain/common/metrics"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/routemeta"
 	"github.com/gofiber/fiber/v3"
 )
 
-func Routers(app fiber.Router) {
-	app.Get("/", home.HomeHandler)
-	app.Get("/health", health.HealthHandler)
+// Module serves the home, health, metrics and docs routes
+type Module struct{}
+
+// Name identifies the module in the startup log
+func (Module) Name() string { return "common" }
+
+// Register adds the module's routes to router
+func (Module) Register(router fiber.Router, deps *app.Deps) {
+	router.Get("/", middleware.OptionalAuth(deps.TokenManager), home.HomeHandler(deps.Routes)).Name("home")
+	router.Get("/health", health.HealthHandler).Name("health")
+	router.Get("/health/ready", health.ReadyHandlerWith(deps.Lifecycle, readyChecks(deps), health.ReadyConfig{
+		CheckTimeout:        deps.Cfg.HealthCheckTimeout,
+		Budget:              deps.Cfg.HealthReadyBudget,
+		DegradedUnavailable: deps.Cfg.HealthDegradedUnavailable,
+	})).Name("health.ready")
+	router.Get("/metrics", metrics.MetricsHandler).Name("metrics")
+	router.Get("/openapi.json", docs.OpenAPIHandler(deps.Routes, home.APIVersion)).Name(docs.RouteNameOpenAPI)
+	router.Get("/docs", docs.DocsHandler).Name("docs")
+
+	deps.Routes.Describe(routemeta.Route{Name: "health.ready", Rel: "ready", Summary: "Readiness probe including database connectivity"})
+	deps.Routes.Describe(routemeta.Route{Name: docs.RouteNameOpenAPI, Rel: "openapi", Summary: "OpenAPI document generated from registered routes"})
+	deps.Routes.Describe(routemeta.Route{Name: "docs", Rel: "docs", Summary: "Interactive API documentation"})
+}
+
+// readyChecks are the checks of the readiness probe: the database, and the
+// error rate when HEALTH_ERROR_RATE_THRESHOLD is set
+func readyChecks(deps *app.Deps) []health.Checker {
+	checks := []health.Checker{health.DatabaseCheck(deps.DB)}
+	if deps.ErrorRate != nil {
+		checks = append(checks, health.ErrorRateCheck(deps.ErrorRate))
+	}
+	return checks
 }
@@ -0,0 +1,81 @@
+package docs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"dvith.com/go-service-api/internal/routemeta"
+	"github.com/gofiber/fiber/v3"
+)
+
+// RouteNameOpenAPI is the route name the docs page uses to locate the spec
+const RouteNameOpenAPI = "openapi"
+
+var pathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)[?+*]?`)
+
+// OpenAPIHandler serves an OpenAPI 3 document generated from the route
+// metadata registry
+func OpenAPIHandler(registry *routemeta.Registry, version string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		paths := map[string]map[string]any{}
+		for _, r := range registry.Resolve(c.App()) {
+			path := pathParam.ReplaceAllString(r.Path, "{$1}")
+			if paths[path] == nil {
+				paths[path] = map[string]any{}
+			}
+
+			op := map[string]any{
+				"operationId": r.Name,
+				"summary":     r.Summary,
+				"responses": map[string]any{
+					"default": map[string]any{"description": "See the error response schema"},
+				},
+			}
+			if r.RequireAuth {
+				op["security"] = []map[string][]string{{"bearerAuth": {}}}
+			}
+			paths[path][strings.ToLower(r.Method)] = op
+		}
+
+		return c.JSON(fiber.Map{
+			"openapi": "3.0.3",
+			"info": fiber.Map{
+				"title":   "Go Service API",
+				"version": version,
+			},
+			"paths": paths,
+			"components": fiber.Map{
+				"securitySchemes": fiber.Map{
+					"bearerAuth": fiber.Map{
+						"type":         "http",
+						"scheme":       "bearer",
+						"bearerFormat": "JWT",
+					},
+				},
+			},
+		})
+	}
+}
+
+// DocsHandler serves a Swagger UI page pointing at the OpenAPI document
+func DocsHandler(c fiber.Ctx) error {
+	specURL := c.App().GetRoute(RouteNameOpenAPI).Path
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.SendString(fmt.Sprintf(docsPage, specURL))
+}
+
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Go Service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: %q, dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
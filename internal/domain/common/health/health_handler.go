@@ -1,7 +1,236 @@
 package health
 
-import "github.com/gofiber/fiber/v3"
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"golang.org/x/sync/errgroup"
+)
+
+// Check statuses reported by the readiness probe
+const (
+	StatusOK          = "ok"
+	StatusDown        = "down"
+	StatusTimeout     = "timeout"
+	StatusUnavailable = "unavailable"
+	// StatusDegraded is a check, or the service, still serving but unwell
+	StatusDegraded = "degraded"
+)
+
+// ErrNotConfigured is reported by checks for dependencies that aren't set up
+var ErrNotConfigured = errors.New("not configured")
+
+// DegradedError is returned by checks that aren't failing outright but
+// want the service reported degraded, with details for the probe body
+type DegradedError struct {
+	Reason string
+	Detail any
+}
+
+func (e *DegradedError) Error() string { return e.Reason }
+
+// Checker is a dependency the readiness probe pings
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c checkFunc) Name() string                    { return c.name }
+func (c checkFunc) Check(ctx context.Context) error { return c.fn(ctx) }
+
+// NewCheck wraps fn as a named Checker
+func NewCheck(name string, fn func(ctx context.Context) error) Checker {
+	return checkFunc{name: name, fn: fn}
+}
+
+// DatabaseCheck pings the database pool
+func DatabaseCheck(db *database.DBPool) Checker {
+	return NewCheck("database", func(ctx context.Context) error {
+		if db == nil {
+			return ErrNotConfigured
+		}
+		return db.Health(ctx)
+	})
+}
+
+// ErrorRateCheck reports degraded while tracker's 5xx rate is above its
+// threshold
+func ErrorRateCheck(tracker *middleware.ErrorRateTracker) Checker {
+	return NewCheck("error_rate", func(ctx context.Context) error {
+		rate := tracker.Rate()
+		if !rate.Degraded {
+			return nil
+		}
+		cfg := tracker.Config()
+		return &DegradedError{
+			Reason: "error rate above threshold",
+			Detail: errorRateDetail{
+				ErrorRate:     rate,
+				Threshold:     cfg.Threshold,
+				Recovery:      cfg.Recovery,
+				WindowSeconds: int(middleware.ErrorRateWindow / time.Second),
+			},
+		}
+	})
+}
+
+type errorRateDetail struct {
+	middleware.ErrorRate
+	Threshold     float64 `json:"threshold"`
+	Recovery      float64 `json:"recovery"`
+	WindowSeconds int     `json:"window_seconds"`
+}
+
+// CheckResult is a single dependency's outcome
+type CheckResult struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Detail    any    `json:"detail,omitempty"`
+	Error     string `json:"-"`
+}
+
+// ReadyResponse is the readiness probe body
+type ReadyResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
 
 func HealthHandler(c fiber.Ctx) error {
 	return c.JSON(fiber.Map{"status": "ok"})
 }
+
+// ReadyConfig configures the readiness probe
+type ReadyConfig struct {
+	// CheckTimeout bounds each check
+	CheckTimeout time.Duration
+	// Budget bounds the whole probe
+	Budget time.Duration
+	// DegradedUnavailable answers 503 rather than 200 while a check
+	// reports degraded
+	DegradedUnavailable bool
+}
+
+// ReadyHandler is ReadyHandlerWith answering 200 while degraded
+func ReadyHandler(lifecycle *app.Lifecycle, checks []Checker, checkTimeout, budget time.Duration) fiber.Handler {
+	return ReadyHandlerWith(lifecycle, checks, ReadyConfig{CheckTimeout: checkTimeout, Budget: budget})
+}
+
+// ReadyHandlerWith reports whether the service can serve traffic. Checks
+// run concurrently, each bounded by CheckTimeout, and the probe answers
+// within Budget even if a check ignores its context: checks still running
+// are reported as timed out, so a hung dependency can't hang the probe.
+// A check returning a DegradedError makes the service degraded rather than
+// unavailable, answered with 200 unless DegradedUnavailable is set.
+// While the lifecycle is starting or draining the probe answers 503 with
+// the state name and skips the checks; a nil lifecycle is always ready.
+func ReadyHandlerWith(lifecycle *app.Lifecycle, checks []Checker, cfg ReadyConfig) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if lifecycle != nil && !lifecycle.Ready() {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(ReadyResponse{Status: string(lifecycle.State())})
+		}
+
+		response := runChecks(middleware.GetRequestContext(c), checks, cfg.CheckTimeout, cfg.Budget)
+
+		if response.Status != StatusOK {
+			for name, result := range response.Checks {
+				if result.Status != StatusOK {
+					logger.Warn("readiness check failed", map[string]any{
+						"check":      name,
+						"status":     result.Status,
+						"latency_ms": result.LatencyMS,
+						"error":      result.Error,
+					})
+				}
+			}
+			if response.Status == StatusUnavailable || cfg.DegradedUnavailable {
+				return c.Status(fiber.StatusServiceUnavailable).JSON(response)
+			}
+		}
+
+		return c.JSON(response)
+	}
+}
+
+func runChecks(ctx context.Context, checks []Checker, checkTimeout, budget time.Duration) ReadyResponse {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	var mu sync.Mutex
+	results := make(map[string]CheckResult, len(checks))
+
+	var g errgroup.Group
+	for _, check := range checks {
+		g.Go(func() error {
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			checkStart := time.Now()
+			err := check.Check(checkCtx)
+			result := CheckResult{Status: StatusOK, LatencyMS: time.Since(checkStart).Milliseconds()}
+			var degraded *DegradedError
+			switch {
+			case err == nil:
+			case errors.As(err, &degraded):
+				result.Status = StatusDegraded
+				result.Detail = degraded.Detail
+				result.Error = degraded.Reason
+			case errors.Is(err, context.DeadlineExceeded) || checkCtx.Err() != nil:
+				result.Status = StatusTimeout
+			default:
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[check.Name()] = result
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_ = g.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	response := ReadyResponse{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, check := range checks {
+		result, ok := results[check.Name()]
+		if !ok {
+			result = CheckResult{Status: StatusTimeout, LatencyMS: time.Since(start).Milliseconds()}
+		}
+		switch {
+		case result.Status == StatusDegraded:
+			if response.Status == StatusOK {
+				response.Status = StatusDegraded
+			}
+		case result.Status != StatusOK:
+			response.Status = StatusUnavailable
+		}
+		response.Checks[check.Name()] = result
+	}
+
+	return response
+}
@@ -0,0 +1,225 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sleepCheck succeeds after delay, or fails early if its context ends
+func sleepCheck(name string, delay time.Duration) Checker {
+	return NewCheck(name, func(ctx context.Context) error {
+		select {
+		case <-time.After(delay):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// stuckCheck ignores its context entirely, like a wedged driver call
+func stuckCheck(name string, release <-chan struct{}) Checker {
+	return NewCheck(name, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+}
+
+func ready(t *testing.T, checks []Checker, checkTimeout, budget time.Duration) (int, ReadyResponse, time.Duration) {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/ready", ReadyHandler(nil, checks, checkTimeout, budget))
+
+	start := time.Now()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/ready", nil))
+	elapsed := time.Since(start)
+	require.NoError(t, err)
+
+	var body ReadyResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body, elapsed
+}
+
+func TestReadyHandler_AllHealthy(t *testing.T) {
+	status, body, _ := ready(t, []Checker{
+		sleepCheck("database", 10*time.Millisecond),
+		sleepCheck("cache", 0),
+	}, 500*time.Millisecond, time.Second)
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, StatusOK, body.Status)
+	require.Len(t, body.Checks, 2)
+	assert.Equal(t, StatusOK, body.Checks["database"].Status)
+	assert.GreaterOrEqual(t, body.Checks["database"].LatencyMS, int64(10))
+	assert.Equal(t, StatusOK, body.Checks["cache"].Status)
+}
+
+func TestReadyHandler_CheckTimeout(t *testing.T) {
+	status, body, elapsed := ready(t, []Checker{
+		sleepCheck("database", time.Second),
+		sleepCheck("cache", 0),
+	}, 50*time.Millisecond, time.Second)
+
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, StatusUnavailable, body.Status)
+	assert.Equal(t, StatusTimeout, body.Checks["database"].Status)
+	assert.Equal(t, StatusOK, body.Checks["cache"].Status)
+	assert.Less(t, elapsed, 500*time.Millisecond)
+}
+
+func TestReadyHandler_BudgetHonoredWhenCheckIgnoresContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	status, body, elapsed := ready(t, []Checker{
+		stuckCheck("database", release),
+		sleepCheck("cache", 0),
+	}, time.Second, 100*time.Millisecond)
+
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, StatusTimeout, body.Checks["database"].Status)
+	assert.GreaterOrEqual(t, body.Checks["database"].LatencyMS, int64(100))
+	assert.Equal(t, StatusOK, body.Checks["cache"].Status)
+	assert.Less(t, elapsed, 500*time.Millisecond)
+}
+
+func TestReadyHandler_ChecksRunConcurrently(t *testing.T) {
+	checks := make([]Checker, 0, 5)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		checks = append(checks, sleepCheck(name, 80*time.Millisecond))
+	}
+
+	status, _, elapsed := ready(t, checks, 500*time.Millisecond, time.Second)
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Less(t, elapsed, 300*time.Millisecond)
+}
+
+func TestReadyHandler_FailingCheckIsDown(t *testing.T) {
+	status, body, _ := ready(t, []Checker{
+		NewCheck("database", func(ctx context.Context) error { return errors.New("connection refused") }),
+	}, 500*time.Millisecond, time.Second)
+
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, StatusDown, body.Checks["database"].Status)
+}
+
+func TestReadyHandler_DatabaseNotConfigured(t *testing.T) {
+	status, body, _ := ready(t, []Checker{DatabaseCheck(nil)}, 500*time.Millisecond, time.Second)
+
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, StatusDown, body.Checks["database"].Status)
+}
+
+func TestReadyHandler_FollowsLifecycle(t *testing.T) {
+	lifecycle := app.NewLifecycle()
+	handler := fiber.New()
+	handler.Get("/ready", ReadyHandler(lifecycle, []Checker{sleepCheck("database", 0)}, 500*time.Millisecond, time.Second))
+
+	probe := func() (int, ReadyResponse) {
+		resp, err := handler.Test(httptest.NewRequest(http.MethodGet, "/ready", nil))
+		require.NoError(t, err)
+		var body ReadyResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	status, body := probe()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "starting", body.Status)
+	assert.Empty(t, body.Checks, "checks are skipped while warming up")
+
+	require.NoError(t, lifecycle.MarkReady())
+	status, body = probe()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, StatusOK, body.Status)
+
+	require.NoError(t, lifecycle.Drain())
+	status, body = probe()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "draining", body.Status)
+}
+
+// degradedTracker is an error rate tracker over a window of 200 requests,
+// a quarter of them failed
+func degradedTracker() *middleware.ErrorRateTracker {
+	tracker := middleware.NewErrorRateTracker(middleware.ErrorRateConfig{Threshold: 0.2, Recovery: 0.1, MinRequests: 100})
+	for i := range 200 {
+		status := fiber.StatusOK
+		if i%4 == 0 {
+			status = fiber.StatusInternalServerError
+		}
+		tracker.Record(status)
+	}
+	return tracker
+}
+
+func TestReadyHandler_ErrorRateDegraded(t *testing.T) {
+	tracker := degradedTracker()
+
+	for _, unavailable := range []bool{false, true} {
+		server := fiber.New()
+		server.Get("/ready", ReadyHandlerWith(nil, []Checker{sleepCheck("database", 0), ErrorRateCheck(tracker)}, ReadyConfig{
+			CheckTimeout:        500 * time.Millisecond,
+			Budget:              time.Second,
+			DegradedUnavailable: unavailable,
+		}))
+		resp, err := server.Test(httptest.NewRequest(http.MethodGet, "/ready", nil))
+		require.NoError(t, err)
+
+		want := http.StatusOK
+		if unavailable {
+			want = http.StatusServiceUnavailable
+		}
+		assert.Equal(t, want, resp.StatusCode)
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, StatusDegraded, body["status"])
+		checks := body["checks"].(map[string]any)
+		assert.Equal(t, StatusOK, checks["database"].(map[string]any)["status"])
+		errorRate := checks["error_rate"].(map[string]any)
+		assert.Equal(t, StatusDegraded, errorRate["status"])
+		assert.Equal(t, map[string]any{
+			"error_rate":     0.25,
+			"requests":       float64(200),
+			"errors":         float64(50),
+			"threshold":      0.2,
+			"recovery":       0.1,
+			"window_seconds": float64(300),
+		}, errorRate["detail"])
+	}
+}
+
+func TestReadyHandler_ErrorRateHealthy(t *testing.T) {
+	tracker := middleware.NewErrorRateTracker(middleware.ErrorRateConfig{Threshold: 0.2, Recovery: 0.1})
+	tracker.Record(fiber.StatusOK)
+
+	status, body, _ := ready(t, []Checker{ErrorRateCheck(tracker)}, 500*time.Millisecond, time.Second)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, StatusOK, body.Status)
+	assert.Equal(t, StatusOK, body.Checks["error_rate"].Status)
+	assert.Nil(t, body.Checks["error_rate"].Detail)
+}
+
+func TestReadyHandler_UnavailableOutranksDegraded(t *testing.T) {
+	status, body, _ := ready(t, []Checker{
+		ErrorRateCheck(degradedTracker()),
+		NewCheck("database", func(ctx context.Context) error { return errors.New("connection refused") }),
+	}, 500*time.Millisecond, time.Second)
+
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, StatusUnavailable, body.Status)
+	assert.Equal(t, StatusDegraded, body.Checks["error_rate"].Status)
+}
@@ -1,9 +1,64 @@
 package home
 
 import (
+	"dvith.com/go-service-api/internal/apiversion"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/routemeta"
 	"github.com/gofiber/fiber/v3"
 )
 
-func HomeHandler(c fiber.Ctx) error {
-	return c.JSON(fiber.Map{"message": "Welcome to the Go Service API!"})
+// APIVersion is the version the OpenAPI document describes
+const APIVersion = "v1"
+
+// SupportedContentTypes lists the request and response media types the API accepts
+var SupportedContentTypes = []string{fiber.MIMEApplicationJSON}
+
+// Link is a single discovery link
+type Link struct {
+	Href        string `json:"href"`
+	Method      string `json:"method"`
+	Description string `json:"description,omitempty"`
+	RequireAuth bool   `json:"requires_auth,omitempty"`
+}
+
+// HomeResponse is the discovery document served at the API root
+type HomeResponse struct {
+	Message      string          `json:"message"`
+	Version      string          `json:"version"`
+	ContentTypes []string        `json:"content_types"`
+	Links        map[string]Link `json:"_links"`
+}
+
+// HomeHandler serves a discovery document built from the route metadata
+// registry. Routes marked Authenticated are only listed for callers that
+// present a valid token, so it must run after middleware.OptionalAuth.
+// Links point into the API version the request is served as.
+func HomeHandler(registry *routemeta.Registry) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		_, err := middleware.GetUserIDFromContext(c)
+		authenticated := err == nil
+
+		links := map[string]Link{
+			"self": {Href: c.Path(), Method: fiber.MethodGet},
+		}
+		version := apiversion.FromCtx(c)
+		for _, r := range registry.ResolveAs(c.App(), version.RouteName) {
+			if r.Rel == "" || (r.Visibility == routemeta.Authenticated && !authenticated) {
+				continue
+			}
+			links[r.Rel] = Link{
+				Href:        r.Path,
+				Method:      r.Method,
+				Description: r.Summary,
+				RequireAuth: r.RequireAuth,
+			}
+		}
+
+		return c.JSON(HomeResponse{
+			Message:      "Welcome to the Go Service API!",
+			Version:      string(version),
+			ContentTypes: SupportedContentTypes,
+			Links:        links,
+		})
+	}
 }
@@ -0,0 +1,106 @@
+package home_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/domain"
+	"dvith.com/go-service-api/internal/domain/common/home"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHomeTestApp(t *testing.T) (*fiber.App, *app.Deps) {
+	cfg, err := config.LoadFromEnv()
+	require.NoError(t, err)
+
+	deps := app.NewDeps(nil, cfg)
+	server := fiber.New()
+	domain.Init(server, deps)
+	return server, deps
+}
+
+func getHome(t *testing.T, server *fiber.App, accessToken string) home.HomeResponse {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/", nil)
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := server.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body home.HomeResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
+func rels(links map[string]home.Link) []string {
+	out := make([]string, 0, len(links))
+	for rel := range links {
+		out = append(out, rel)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestHomeHandler_AnonymousLinks(t *testing.T) {
+	server, _ := newHomeTestApp(t)
+
+	body := getHome(t, server, "")
+
+	assert.Equal(t, home.APIVersion, body.Version)
+	assert.Contains(t, body.ContentTypes, fiber.MIMEApplicationJSON)
+	assert.Equal(t, []string{
+		"docs", "forgot-password", "magic-link", "openapi", "profile", "ready", "refresh-token", "reset-password", "self", "signin", "signup",
+	}, rels(body.Links))
+
+	assert.Equal(t, "/api/v1/auth/signup", body.Links["signup"].Href)
+	assert.Equal(t, fiber.MethodPost, body.Links["signup"].Method)
+	assert.Equal(t, "/api/v1/health/ready", body.Links["ready"].Href)
+	assert.True(t, body.Links["profile"].RequireAuth)
+}
+
+func TestHomeHandler_AuthenticatedLinks(t *testing.T) {
+	server, deps := newHomeTestApp(t)
+
+	accessToken, err := deps.TokenManager.GenerateAccessToken(uuid.New())
+	require.NoError(t, err)
+
+	body := getHome(t, server, accessToken)
+
+	assert.Equal(t, []string{
+		"change-email", "delete-account", "docs", "export", "forgot-password", "magic-link", "openapi", "preferences", "profile", "ready", "refresh-token", "reset-password",
+		"self", "sessions", "signin", "signup",
+	}, rels(body.Links))
+	assert.Equal(t, fiber.MethodDelete, body.Links["delete-account"].Method)
+}
+
+func TestHomeHandler_InvalidTokenIsAnonymous(t *testing.T) {
+	server, _ := newHomeTestApp(t)
+
+	body := getHome(t, server, "not-a-token")
+
+	assert.NotContains(t, body.Links, "export")
+}
+
+func TestHomeHandler_LinksResolve(t *testing.T) {
+	server, _ := newHomeTestApp(t)
+
+	// Every advertised public GET link must actually be routable
+	for rel, link := range getHome(t, server, "").Links {
+		if link.Method != fiber.MethodGet || link.RequireAuth || rel == "ready" {
+			continue
+		}
+		resp, err := server.Test(httptest.NewRequest(http.MethodGet, link.Href, nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, rel)
+	}
+}
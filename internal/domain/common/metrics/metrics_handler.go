@@ -0,0 +1,19 @@
+package metrics
+
+import (
+	"bytes"
+
+	"dvith.com/go-service-api/pkg/metrics"
+	"github.com/gofiber/fiber/v3"
+)
+
+// MetricsHandler exposes the default metrics registry in the Prometheus text format
+func MetricsHandler(c fiber.Ctx) error {
+	var buf bytes.Buffer
+	if err := metrics.Default.WriteText(&buf); err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	return c.Send(buf.Bytes())
+}
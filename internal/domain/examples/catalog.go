@@ -0,0 +1,123 @@
+package examples
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Route describes one example endpoint. Everything the catalog reports is
+// derived from the Route passed to Catalog.Add, so it cannot drift from
+// what is actually registered.
+type Route struct {
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	Summary     string            `json:"summary"`
+	PathParams  map[string]string `json:"path_params,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	RequestBody any               `json:"request_body,omitempty"`
+	Responses   []int             `json:"responses"`
+}
+
+// CatalogEntry is a Route as rendered by the catalog endpoint
+type CatalogEntry struct {
+	Route
+	Curl string `json:"curl"`
+}
+
+// Catalog registers example routes and records their metadata
+type Catalog struct {
+	router fiber.Router
+	mu     sync.RWMutex
+	routes []Route
+}
+
+// NewCatalog creates a catalog that registers routes on router
+func NewCatalog(router fiber.Router) *Catalog {
+	return &Catalog{router: router}
+}
+
+// Add registers handlers for route and records it in the catalog
+func (cat *Catalog) Add(route Route, handler fiber.Handler, handlers ...fiber.Handler) {
+	rest := make([]any, len(handlers))
+	for i, h := range handlers {
+		rest[i] = h
+	}
+	cat.router.Add([]string{route.Method}, route.Path, handler, rest...)
+
+	cat.mu.Lock()
+	defer cat.mu.Unlock()
+	cat.routes = append(cat.routes, route)
+}
+
+// Routes returns the registered routes sorted by path and method
+func (cat *Catalog) Routes() []Route {
+	cat.mu.RLock()
+	out := make([]Route, len(cat.routes))
+	copy(out, cat.routes)
+	cat.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
+
+// Handler serves the catalog. Paths are reported relative to the mount
+// point of the catalog handler itself, so it must be registered on the
+// same router the routes were added to.
+func (cat *Catalog) Handler() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		base := strings.TrimSuffix(c.Path(), "/")
+
+		routes := cat.Routes()
+		entries := make([]CatalogEntry, 0, len(routes))
+		for _, r := range routes {
+			r.Path = base + r.Path
+			entries = append(entries, CatalogEntry{
+				Route: r,
+				Curl:  curlSnippet(c.BaseURL(), r),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"examples": entries,
+		})
+	}
+}
+
+// curlSnippet renders a copy-pasteable curl command for r
+func curlSnippet(baseURL string, r Route) string {
+	path := r.Path
+	for name, value := range r.PathParams {
+		path = strings.ReplaceAll(path, ":"+name, value)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "curl -X %s '%s%s'", r.Method, baseURL, path)
+
+	names := make([]string, 0, len(r.Headers))
+	for name := range r.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, " -H '%s: %s'", name, r.Headers[name])
+	}
+
+	if r.RequestBody != nil {
+		body, err := json.Marshal(r.RequestBody)
+		if err == nil {
+			fmt.Fprintf(&b, " -H 'Content-Type: application/json' -d '%s'", body)
+		}
+	}
+
+	return b.String()
+}
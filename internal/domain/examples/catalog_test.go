@@ -0,0 +1,185 @@
+package examples
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/features"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/security/token"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newExamplesTestApp(env string) (*fiber.App, *Catalog) {
+	deps := &app.Deps{
+		Cfg: config.Config{Env: env},
+		TokenManager: token.NewTokenManager(token.TokenConfig{
+			SecretKey:       "test-secret-key",
+			ExpirationTime:  15 * time.Minute,
+			RefreshDuration: 24 * time.Hour,
+			Issuer:          "go-service-api",
+		}),
+		Features: features.NewFlags([]string{features.Examples}),
+	}
+
+	server := fiber.New()
+	catalog := RegisterRoutes(server.Group("/api/v1", middleware.ErrorHandler()), deps)
+	return server, catalog
+}
+
+type catalogBody struct {
+	Examples []CatalogEntry `json:"examples"`
+}
+
+func getCatalog(t *testing.T, server *fiber.App) catalogBody {
+	resp, err := server.Test(httptest.NewRequest(http.MethodGet, "/api/v1/examples", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body catalogBody
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
+// TestCatalog_InSyncWithRoutes fails if a route is registered on the
+// examples group without going through the catalog, or vice versa
+func TestCatalog_InSyncWithRoutes(t *testing.T) {
+	server, _ := newExamplesTestApp("development")
+
+	var registered []string
+	for _, r := range server.GetRoutes(true) {
+		if r.Method == fiber.MethodHead || !strings.HasPrefix(r.Path, "/api/v1/examples/") {
+			continue
+		}
+		registered = append(registered, r.Method+" "+r.Path)
+	}
+
+	var cataloged []string
+	for _, e := range getCatalog(t, server).Examples {
+		cataloged = append(cataloged, e.Method+" "+e.Path)
+	}
+
+	// The catalog endpoint itself is the only uncataloged route
+	registered = filter(registered, "GET /api/v1/examples/")
+
+	sort.Strings(registered)
+	sort.Strings(cataloged)
+	assert.Equal(t, registered, cataloged)
+}
+
+func TestCatalog_CurlSnippets(t *testing.T) {
+	server, _ := newExamplesTestApp("development")
+
+	entries := map[string]CatalogEntry{}
+	for _, e := range getCatalog(t, server).Examples {
+		entries[e.Method+" "+e.Path] = e
+	}
+
+	create := entries["POST /api/v1/examples/users"]
+	assert.Contains(t, create.Curl, "-X POST")
+	assert.Contains(t, create.Curl, "/api/v1/examples/users'")
+	assert.Contains(t, create.Curl, `"email":"john@example.com"`)
+	assert.Contains(t, create.Responses, http.StatusBadRequest)
+
+	get := entries["GET /api/v1/examples/users/:id"]
+	assert.Contains(t, get.Curl, "/api/v1/examples/users/123'")
+}
+
+func TestCatalog_TokenEndpointHiddenInProduction(t *testing.T) {
+	server, catalog := newExamplesTestApp("production")
+
+	for _, r := range catalog.Routes() {
+		assert.NotEqual(t, "/token", r.Path)
+	}
+
+	resp, err := server.Test(httptest.NewRequest(http.MethodPost, "/api/v1/examples/token", nil))
+	require.NoError(t, err)
+	assert.NotEqual(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestExamples_HiddenWhenFeatureOff(t *testing.T) {
+	server, _ := newExamplesTestApp("development")
+
+	resp, err := server.Test(httptest.NewRequest(http.MethodGet, "/api/v1/examples/users/123", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	deps := &app.Deps{Cfg: config.Config{Env: "development"}, Features: features.NewFlags(nil)}
+	dark := fiber.New()
+	RegisterRoutes(dark.Group("/api/v1"), deps)
+
+	resp, err = dark.Test(httptest.NewRequest(http.MethodGet, "/api/v1/examples/users/123", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestExamples_GetUserRejectsBadID(t *testing.T) {
+	server, _ := newExamplesTestApp("development")
+
+	for _, id := range []string{"abc", "0", "99999999999"} {
+		resp, err := server.Test(httptest.NewRequest(http.MethodGet, "/api/v1/examples/users/"+id, nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, id)
+	}
+
+	resp, err := server.Test(httptest.NewRequest(http.MethodGet, "/api/v1/examples/users/7", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestExamples_TokenThenProtected(t *testing.T) {
+	server, _ := newExamplesTestApp("development")
+
+	resp, err := server.Test(httptest.NewRequest(http.MethodPost, "/api/v1/examples/token", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var issued struct {
+		AccessToken string `json:"access_token"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&issued))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/examples/me", nil)
+	req.Header.Set("Authorization", "Bearer "+issued.AccessToken)
+	resp, err = server.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = server.Test(httptest.NewRequest(http.MethodGet, "/api/v1/examples/me", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestExamples_Validated(t *testing.T) {
+	server, _ := newExamplesTestApp("development")
+
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/examples/validated", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusCreated, post(`{"email":"john@example.com","username":"john_doe","age":30}`))
+	assert.Equal(t, http.StatusBadRequest, post(`{"email":"not-an-email","username":"jd","age":3}`))
+}
+
+func filter(items []string, drop string) []string {
+	out := items[:0]
+	for _, s := range items {
+		if s != drop {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -1,8 +1,13 @@
 package examples
 
 import (
+	"math"
+	"strconv"
+
 	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/security/token"
 	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
 )
 
 // ExampleUserRequest represents a user creation request.
@@ -38,15 +43,19 @@ func ExampleCreateUserHandler(c fiber.Ctx) error {
 	})
 }
 
-// ExampleGetUserHandler shows how to use NotFoundResponse.
+// ExampleGetUserHandler shows how to use ParamInt and NotFoundResponse.
 // GET /api/v1/examples/users/:id
 func ExampleGetUserHandler(c fiber.Ctx) error {
-	userID := c.Params("id")
+	// A non-numeric or out-of-range ID is rendered as a 400 by ErrorHandler
+	userID, err := middleware.ParamInt(c, "id", 1, math.MaxInt32)
+	if err != nil {
+		return err
+	}
 
 	// Simulate user lookup
-	if userID != "123" {
+	if userID != 123 {
 		// Return a not found error (404)
-		return middleware.NotFoundResponse(c, "user with id "+userID+" not found")
+		return middleware.NotFoundResponse(c, "user with id "+strconv.Itoa(userID)+" not found")
 	}
 
 	return c.JSON(fiber.Map{
@@ -60,14 +69,14 @@ func ExampleGetUserHandler(c fiber.Ctx) error {
 // GET /api/v1/examples/protected
 func ExampleAuthenticatedHandler(c fiber.Ctx) error {
 	// Get token from Authorization header
-	token := c.Get("Authorization")
+	authHeader := c.Get("Authorization")
 
 	// Check if token is valid
-	if token == "" {
+	if authHeader == "" {
 		return middleware.AuthErrorResponse(c, "missing authorization header")
 	}
 
-	if !isValidToken(token) {
+	if !isValidToken(authHeader) {
 		// Return an authorization error (401)
 		return middleware.AuthErrorResponse(c, "invalid or expired token")
 	}
@@ -75,7 +84,7 @@ func ExampleAuthenticatedHandler(c fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"message": "authenticated access granted",
 		"user":    "john_doe",
-		"token":   token,
+		"token":   authHeader,
 	})
 }
 
@@ -123,3 +132,57 @@ func simulateDatabaseOperation() ([]string, error) {
 	// Uncomment to test error handling:
 	// return nil, errors.New("database connection timeout")
 }
+
+// ExampleSignupRequest is validated by middleware.ValidateBody before the
+// handler runs.
+type ExampleSignupRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Username string `json:"username" validate:"required,min=3,max=100"`
+	Age      int    `json:"age" validate:"gte=13"`
+}
+
+// ExampleTokenHandler issues a throwaway access token for a random user so
+// the AuthMiddleware example can be tried without signing up. Only
+// registered outside production.
+// POST /api/v1/examples/token
+func ExampleTokenHandler(tm *token.TokenManager) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		accessToken, err := tm.GenerateAccessToken(uuid.New())
+		if err != nil {
+			return middleware.InternalErrorResponse(c, "failed to generate access token")
+		}
+
+		return c.JSON(fiber.Map{
+			"access_token": accessToken,
+			"token_type":   "Bearer",
+			"expires_in":   int64(tm.AccessTokenTTL().Seconds()),
+		})
+	}
+}
+
+// ExampleMeHandler shows reading the user set by AuthMiddleware.
+// GET /api/v1/examples/me
+func ExampleMeHandler(c fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return middleware.AuthErrorResponse(c, "user not authenticated")
+	}
+
+	return c.JSON(fiber.Map{
+		"user_id": userID,
+	})
+}
+
+// ExampleValidatedHandler shows reading a body checked by ValidateBody.
+// POST /api/v1/examples/validated
+func ExampleValidatedHandler(c fiber.Ctx) error {
+	req, err := middleware.GetValidatedBody[ExampleSignupRequest](c)
+	if err != nil {
+		return middleware.InternalErrorResponse(c, "validated body missing")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"email":    req.Email,
+		"username": req.Username,
+	})
+}
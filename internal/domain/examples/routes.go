@@ -1,21 +1,102 @@
 package examples
 
-import "github.com/gofiber/fiber/v3"
+import (
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/features"
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+)
 
-// RegisterRoutes registers all example handler routes.
-func RegisterRoutes(router fiber.Router) {
-	examples := router.Group("/examples")
+// Module serves the example handlers, behind the examples feature flag
+type Module struct{}
+
+// Name identifies the module in the startup log
+func (Module) Name() string { return "examples" }
+
+// Feature is the flag that must be on at startup for the module to be
+// registered
+func (Module) Feature() string { return features.Examples }
+
+// Register adds the example routes to router
+func (Module) Register(router fiber.Router, deps *app.Deps) {
+	RegisterRoutes(router, deps)
+}
+
+// RegisterRoutes registers all example handler routes and the catalog
+// describing them at GET /examples.
+func RegisterRoutes(router fiber.Router, deps *app.Deps) *Catalog {
+	examples := router.Group("/examples", middleware.RequireFeature(deps.Features, features.Examples))
+	catalog := NewCatalog(examples)
 
 	// User management examples
-	examples.Post("/users", ExampleCreateUserHandler)
-	examples.Get("/users/:id", ExampleGetUserHandler)
+	catalog.Add(Route{
+		Method:      fiber.MethodPost,
+		Path:        "/users",
+		Summary:     "Create a user; shows ValidationErrorResponse",
+		RequestBody: ExampleUserRequest{Name: "John Doe", Email: "john@example.com"},
+		Responses:   []int{fiber.StatusOK, fiber.StatusBadRequest},
+	}, ExampleCreateUserHandler)
+	catalog.Add(Route{
+		Method:     fiber.MethodGet,
+		Path:       "/users/:id",
+		Summary:    "Fetch a user; shows ParamInt and NotFoundResponse",
+		PathParams: map[string]string{"id": "123"},
+		Responses:  []int{fiber.StatusOK, fiber.StatusBadRequest, fiber.StatusNotFound},
+	}, ExampleGetUserHandler)
 
 	// Authentication example
-	examples.Get("/protected", ExampleAuthenticatedHandler)
+	catalog.Add(Route{
+		Method:    fiber.MethodGet,
+		Path:      "/protected",
+		Summary:   "Static token check; shows AuthErrorResponse",
+		Headers:   map[string]string{"Authorization": "Bearer valid-token-123"},
+		Responses: []int{fiber.StatusOK, fiber.StatusUnauthorized},
+	}, ExampleAuthenticatedHandler)
+
+	// Real auth middleware example. The token endpoint hands out throwaway
+	// tokens, so it only exists outside production.
+	if !deps.Cfg.IsProduction() {
+		catalog.Add(Route{
+			Method:    fiber.MethodPost,
+			Path:      "/token",
+			Summary:   "Issue a throwaway access token (non-production only)",
+			Responses: []int{fiber.StatusOK},
+		}, ExampleTokenHandler(deps.TokenManager))
+	}
+	catalog.Add(Route{
+		Method:    fiber.MethodGet,
+		Path:      "/me",
+		Summary:   "Protected by AuthMiddleware; use a token from POST /examples/token",
+		Headers:   map[string]string{"Authorization": "Bearer <access_token>"},
+		Responses: []int{fiber.StatusOK, fiber.StatusUnauthorized},
+	}, middleware.AuthMiddleware(deps.TokenManager), ExampleMeHandler)
+
+	// Validation middleware example
+	catalog.Add(Route{
+		Method:      fiber.MethodPost,
+		Path:        "/validated",
+		Summary:     "Body checked by ValidateBody before the handler runs",
+		RequestBody: ExampleSignupRequest{Email: "john@example.com", Username: "john_doe", Age: 30},
+		Responses:   []int{fiber.StatusCreated, fiber.StatusBadRequest},
+	}, middleware.ValidateBody[ExampleSignupRequest](), ExampleValidatedHandler)
 
 	// Database error example
-	examples.Get("/data", ExampleDatabaseErrorHandler)
+	catalog.Add(Route{
+		Method:    fiber.MethodGet,
+		Path:      "/data",
+		Summary:   "Load data; shows InternalErrorResponse",
+		Responses: []int{fiber.StatusOK, fiber.StatusInternalServerError},
+	}, ExampleDatabaseErrorHandler)
 
 	// Panic recovery example
-	examples.Get("/panic", ExamplePanicHandler)
+	catalog.Add(Route{
+		Method:    fiber.MethodGet,
+		Path:      "/panic",
+		Summary:   "Panic recovered by ErrorHandler",
+		Responses: []int{fiber.StatusInternalServerError},
+	}, ExamplePanicHandler)
+
+	examples.Get("/", catalog.Handler())
+
+	return catalog
 }
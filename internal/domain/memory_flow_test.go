@@ -0,0 +1,126 @@
+package domain_test
+
+import (
+	"net/http"
+	"testing"
+
+	"fmt"
+
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func call(t *testing.T, a *testutil.App, method, path, bearer string, body any) (int, map[string]any) {
+	t.Helper()
+	resp := testutil.AuthenticatedRequest(t, a, method, path, body, bearer)
+	return resp.Status, resp.Body
+}
+
+func TestMemoryStores_AuthFlow(t *testing.T) {
+	server := testutil.NewTestApp(t, testutil.Options{})
+
+	signup := map[string]any{
+		"email":     "dev@example.com",
+		"password":  "SecurePass123!",
+		"full_name": "Dev User",
+		"username":  "dev_user",
+	}
+	status, body := call(t, server, http.MethodPost, "/api/v1/auth/signup", "", signup)
+	require.Equal(t, http.StatusCreated, status, body)
+
+	status, body = call(t, server, http.MethodPost, "/api/v1/auth/signup", "", signup)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, fmt.Sprint(body["fields"]), "email is already registered", "email uniqueness is enforced")
+
+	status, body = call(t, server, http.MethodPost, "/api/v1/auth/signin", "", map[string]any{
+		"email":    "dev@example.com",
+		"password": "SecurePass123!",
+	})
+	require.Equal(t, http.StatusOK, status, body)
+	refresh, _ := body["refresh_token"].(string)
+	require.NotEmpty(t, refresh)
+
+	status, _ = call(t, server, http.MethodPost, "/api/v1/auth/signin", "", map[string]any{
+		"email":    "dev@example.com",
+		"password": "wrong-password",
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, body = call(t, server, http.MethodPost, "/api/v1/auth/refresh-token", "", map[string]any{"refresh_token": refresh})
+	require.Equal(t, http.StatusOK, status, body)
+	access, _ := body["access_token"].(string)
+	require.NotEmpty(t, access)
+
+	status, body = call(t, server, http.MethodGet, "/api/v1/user/profile", access, nil)
+	require.Equal(t, http.StatusOK, status, body)
+	user, _ := body["user"].(map[string]any)
+	assert.Equal(t, "dev@example.com", user["email"])
+	assert.Equal(t, "dev_user", user["username"])
+
+	status, body = call(t, server, http.MethodGet, "/api/v1/user/sessions", access, nil)
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Len(t, body["sessions"], 2, "signup and signin each opened a session")
+
+	status, _ = call(t, server, http.MethodDelete, "/api/v1/user/account", access, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = call(t, server, http.MethodGet, "/api/v1/user/profile", access, nil)
+	assert.Equal(t, http.StatusNotFound, status, "soft-deleted users are hidden")
+
+	status, body = call(t, server, http.MethodPost, "/api/v1/auth/signin", "", map[string]any{
+		"email":    "dev@example.com",
+		"password": "SecurePass123!",
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body["error"], "login failed", "soft-deleted users can't sign in")
+
+	status, body = call(t, server, http.MethodPost, "/api/v1/auth/signup", "", signup)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, fmt.Sprint(body["fields"]), "email is already registered", "a soft-deleted user's email stays taken")
+}
+
+func TestMemoryStores_RevokedSessionRejectsTokens(t *testing.T) {
+	server := testutil.NewTestApp(t, testutil.Options{})
+
+	status, body := call(t, server, http.MethodPost, "/api/v1/auth/signup", "", map[string]any{
+		"email":     "revoke@example.com",
+		"password":  "SecurePass123!",
+		"full_name": "Revoke Me",
+		"username":  "revoke_me",
+	})
+	require.Equal(t, http.StatusCreated, status, body)
+	access, _ := body["access_token"].(string)
+	refresh, _ := body["refresh_token"].(string)
+
+	status, body = call(t, server, http.MethodGet, "/api/v1/user/sessions", access, nil)
+	require.Equal(t, http.StatusOK, status, body)
+	sessions, _ := body["sessions"].([]any)
+	require.Len(t, sessions, 1)
+	sessionID, _ := sessions[0].(map[string]any)["id"].(string)
+
+	status, _ = call(t, server, http.MethodDelete, "/api/v1/user/sessions/"+sessionID, access, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = call(t, server, http.MethodGet, "/api/v1/user/profile", access, nil)
+	assert.Equal(t, http.StatusUnauthorized, status, "the session's access token is cut off before it expires")
+
+	status, _ = call(t, server, http.MethodPost, "/api/v1/auth/refresh-token", "", map[string]any{"refresh_token": refresh})
+	assert.NotEqual(t, http.StatusOK, status, "the session can't mint new tokens")
+}
+
+func TestAccountPages_ServedWhenEnabled(t *testing.T) {
+	off := testutil.NewTestApp(t, testutil.Options{})
+	resp := testutil.Request(t, off, http.MethodGet, "/account/forgot-password", nil)
+	assert.Equal(t, http.StatusNotFound, resp.Status)
+
+	on := testutil.NewTestApp(t, testutil.Options{Configure: func(cfg *config.Config) { cfg.ServeAccountPages = true }})
+	resp = testutil.Request(t, on, http.MethodGet, "/account/forgot-password", nil)
+	require.Equal(t, http.StatusOK, resp.Status)
+	assert.Contains(t, string(resp.Raw), `action="/api/v1/auth/password/forgot"`)
+	assert.Contains(t, resp.Header.Get("Content-Security-Policy"), "'nonce-")
+
+	resp = testutil.Request(t, on, http.MethodGet, "/account/static/account.js", nil)
+	assert.Equal(t, http.StatusOK, resp.Status)
+}
@@ -1,26 +1,181 @@
 package domain
 
 import (
+	"net/url"
+
+	"dvith.com/go-service-api/internal/apiversion"
+	"dvith.com/go-service-api/internal/app"
 	"dvith.com/go-service-api/internal/config"
-	"dvith.com/go-service-api/internal/domain/authentication"
-	"dvith.com/go-service-api/internal/domain/common"
-	"dvith.com/go-service-api/internal/domain/examples"
 	"dvith.com/go-service-api/internal/middleware"
-	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/internal/middleware/chain"
+	"dvith.com/go-service-api/internal/requestmeta"
+	"dvith.com/go-service-api/internal/web"
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/ratelimit"
 	"github.com/gofiber/fiber/v3"
 )
 
-func Init(app *fiber.App, db *database.DBPool, cfg config.Config) {
-	// Group all routes under /api/v1 prefix
-	apiV1 := app.Group("/api/v1")
+// userImportPath streams its upload and is exempt from the body limit. It
+// is relative to the version prefix, such as /api/v1.
+const userImportPath = "/admin/users/import"
+
+// Init registers the DefaultModules under each of versions, all supported
+// versions when none are given, behind the shared middleware stack
+func Init(server *fiber.App, deps *app.Deps, versions ...apiversion.Version) {
+	InitModules(server, deps, DefaultModules(), versions...)
+}
+
+// InitModules is Init with the given modules. Each module registers once,
+// and its routes are served in every version; see versionRouter.
+func InitModules(server *fiber.App, deps *app.Deps, modules *Registry, versions ...apiversion.Version) {
+	if len(versions) == 0 {
+		versions = apiversion.Supported
+	}
+
+	// Accept-Version picks the version tree before routing
+	server.Use(apiversion.Negotiate())
 
-	// Apply centralized error handling middleware to all /api/v1 routes
-	apiV1.Use(middleware.ErrorHandler())
+	groups := make([]versionGroup, len(versions))
+	for i, v := range versions {
+		groups[i] = versionGroup{router: initVersion(server, deps, v), version: v}
+	}
 
 	// Register route handlers
-	common.Routers(apiV1)
-	authentication.Routers(apiV1, db, cfg)
+	router := newVersionRouter(groups)
+	modules.Register(router, deps)
+	router.flush()
+
+	// The account pages emailed links open sit outside the versioned API
+	if deps.Cfg.ServeAccountPages {
+		web.Register(server, deps)
+	}
+
+	// Must come after every route: requests no route handled get a JSON
+	// 404, or a 405 with Allow when the path exists under other methods
+	for _, g := range groups {
+		g.router.Use(middleware.RouteFallback())
+	}
+
+	// The shared stack already has one; route files adding their own only
+	// cost a pass-through per request, but shouldn't go unnoticed
+	if dups := middleware.DuplicateErrorHandlers(server); len(dups) > 0 {
+		logger.Warn("ErrorHandler is registered more than once", map[string]any{
+			"routes": dups,
+		})
+	}
+}
+
+// initVersion creates the group serving version v behind the shared
+// middleware stack
+func initVersion(server *fiber.App, deps *app.Deps, v apiversion.Version) fiber.Router {
+	group := server.Group(v.Prefix())
+
+	// Cross-cutting middleware is declared by role; chain applies it in
+	// canonical order so centralized error handling always wraps the rest
+	order := chain.Stack{
+		// Outbound calls carry the ID, so logs correlate across services
+		RequestID: middleware.RequestID(),
+		// IP, user agent and user are read once, the same way everywhere
+		RequestMeta: requestmeta.Middleware(),
+		// Gateway metadata such as the account tier, forwarded downstream
+		Baggage: acceptBaggage(deps.Cfg),
+		// Handlers read the version from it; deprecated versions say so
+		Version: apiversion.Middleware(v, deprecation(deps.Cfg, v)),
+		// Server-Timing on every response, and a log of the slow ones
+		Timing: middleware.Timing(deps.Cfg.SlowRequestThreshold),
+		// The 5xx rate the readiness probe reports degraded on
+		ErrorRate: errorRate(deps),
+		// snake_case unless JSON_NAMING or X-JSON-Naming asks for camelCase
+		Naming:  middleware.JSONNaming(deps.Cfg.JSONNaming),
+		Recover: middleware.ErrorHandlerWith(middleware.ErrorHandlerConfig{Debug: deps.Cfg.ErrorDebugEnabled()}),
+		// Without it, clients asking for an encoding we lack get JSON
+		Negotiate: negotiate(deps.Cfg.StrictAccept),
+		// Users get their own, larger quota than anonymous clients sharing an IP
+		RateLimit: rateLimit(deps),
+		// Only in the cookie auth mode; bearer-token requests are exempt
+		CSRF: csrf(deps.Cfg),
+		// Only ever present outside production; see FAULT_INJECTION
+		Faults: middleware.FaultInjection(deps.Faults),
+		// The server streams request bodies; cap them everywhere but the import
+		BodyLimit: middleware.BodyLimit(deps.Cfg.BodyLimit, v.Prefix()+userImportPath),
+		// Every request gets a context cancelled on disconnect or timeout
+		Timeout: middleware.RequestContext(deps.Cfg.RequestTimeout),
+	}.Apply(group)
+	deps.Routes.DescribeStack(v.Prefix(), order)
+	return group
+}
+
+// deprecation announces the sunset of v1 once V1_SUNSET_DATE is set
+func deprecation(cfg config.Config, v apiversion.Version) *apiversion.Deprecation {
+	sunset, ok := cfg.V1Sunset()
+	if v != apiversion.V1 || !ok {
+		return nil
+	}
+	return &apiversion.Deprecation{Sunset: sunset, Successor: apiversion.Latest.Prefix()}
+}
+
+// acceptBaggage returns the baggage layer, or nil when BAGGAGE_KEYS accepts
+// no members
+func acceptBaggage(cfg config.Config) fiber.Handler {
+	if len(cfg.BaggageKeys) == 0 {
+		return nil
+	}
+	return middleware.Baggage(middleware.BaggageConfig{
+		Keys:     cfg.BaggageKeys,
+		LogKeys:  cfg.BaggageLogKeys,
+		MaxBytes: cfg.BaggageMaxBytes,
+	})
+}
+
+// negotiate returns the strict content negotiation layer, or nil to let
+// Respond fall back to JSON
+func negotiate(strict bool) fiber.Handler {
+	if !strict {
+		return nil
+	}
+	return middleware.Negotiate()
+}
+
+// errorRate feeds the error rate tracker, or returns nil without one. The
+// health probes aren't counted, so a 503 from readiness doesn't keep it
+// degraded.
+func errorRate(deps *app.Deps) fiber.Handler {
+	if deps.ErrorRate == nil {
+		return nil
+	}
+	return middleware.TrackErrorRate(deps.ErrorRate, "health", "health.ready")
+}
+
+// rateLimit returns the request quota layer, or nil when RATE_LIMIT_ENABLED
+// is off, as it is by default in development
+// csrf returns the CSRF check of the cookie auth mode, nil without it. The
+// public URL's origin is trusted besides the request's own.
+func csrf(cfg config.Config) fiber.Handler {
+	if !cfg.AuthCookieEnabled {
+		return nil
+	}
+	var trusted []string
+	if u, err := url.Parse(cfg.URL); err == nil && u.Host != "" {
+		trusted = append(trusted, u.Scheme+"://"+u.Host)
+	}
+	return middleware.CSRF(middleware.CSRFConfig{
+		Secret:         []byte(cfg.JWTSecretKey),
+		SessionCookie:  middleware.CookieRefreshToken,
+		TrustedOrigins: trusted,
+		Secure:         cfg.IsProduction(),
+	})
+}
 
-	// Register example handlers (demonstrating error handling)
-	examples.RegisterRoutes(apiV1)
+func rateLimit(deps *app.Deps) fiber.Handler {
+	if !deps.Cfg.RateLimitEnabled {
+		return nil
+	}
+	return middleware.RateLimit(middleware.RateLimitConfig{
+		Store:          ratelimit.NewMemoryStore(deps.Cache),
+		Window:         deps.Cfg.RateLimitWindow,
+		AnonymousLimit: deps.Cfg.RateLimitAnonymous,
+		UserLimit:      deps.Cfg.RateLimitAuthenticated,
+		Tokens:         deps.TokenManager,
+		IETFHeaders:    deps.Cfg.RateLimitIETFHeaders,
+	})
 }
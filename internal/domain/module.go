@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"fmt"
+
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/domain/admin"
+	"dvith.com/go-service-api/internal/domain/authentication"
+	"dvith.com/go-service-api/internal/domain/common"
+	"dvith.com/go-service-api/internal/domain/examples"
+	user "dvith.com/go-service-api/internal/domain/user"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// Module is a domain's set of routes. Adding a domain means adding its
+// module to DefaultModules, not another call in Init.
+type Module interface {
+	// Name identifies the module in the startup log
+	Name() string
+	// Register adds the module's routes, once, to the group of every API
+	// version
+	Register(r fiber.Router, deps *app.Deps)
+}
+
+// Gated is a Module registered only when its feature flag is on at
+// startup. A module left out this way stays out until a restart, even if
+// the flag is turned on at runtime.
+type Gated interface {
+	Module
+	Feature() string
+}
+
+// Registry holds the modules Init registers, in order
+type Registry struct {
+	modules []Module
+	names   map[string]bool
+}
+
+// NewRegistry creates a registry of modules
+func NewRegistry(modules ...Module) *Registry {
+	r := &Registry{names: make(map[string]bool)}
+	for _, m := range modules {
+		r.Add(m)
+	}
+	return r
+}
+
+// Add appends m. Names must be unique, as they are all the startup log
+// tells modules apart by.
+func (r *Registry) Add(m Module) *Registry {
+	if r.names[m.Name()] {
+		panic(fmt.Sprintf("domain: module %q is registered twice", m.Name()))
+	}
+	r.names[m.Name()] = true
+	r.modules = append(r.modules, m)
+	return r
+}
+
+// Register registers every module whose feature flag is on and returns
+// the names of those it registered
+func (r *Registry) Register(router fiber.Router, deps *app.Deps) []string {
+	var registered, skipped []string
+	for _, m := range r.modules {
+		if g, ok := m.(Gated); ok && !deps.Features.Enabled(g.Feature()) {
+			skipped = append(skipped, m.Name())
+			continue
+		}
+		m.Register(router, deps)
+		registered = append(registered, m.Name())
+	}
+
+	logger.Info("domain modules registered", map[string]any{
+		"modules": registered,
+		"skipped": skipped,
+	})
+	return registered
+}
+
+// DefaultModules are the domains the server serves
+func DefaultModules() *Registry {
+	return NewRegistry(
+		common.Module{},
+		authentication.Module{},
+		user.Module{},
+		admin.Module{},
+		// Example handlers demonstrating error handling
+		examples.Module{},
+	)
+}
@@ -0,0 +1,71 @@
+package domain_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/domain"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeModule struct {
+	name, feature string
+}
+
+func (m fakeModule) Name() string { return m.name }
+
+func (m fakeModule) Register(r fiber.Router, deps *app.Deps) {
+	r.Get("/"+m.name, func(c fiber.Ctx) error { return c.SendString(m.name) })
+}
+
+type gatedModule struct{ fakeModule }
+
+func (m gatedModule) Feature() string { return m.feature }
+
+func TestRegistry_RegistersModules(t *testing.T) {
+	cfg, err := config.LoadFromEnv()
+	require.NoError(t, err)
+	cfg.Env = "development"
+	cfg.DatabaseURL = ""
+	cfg.Features = config.Features{"beta"}
+	deps := app.NewDeps(nil, cfg)
+
+	registry := domain.NewRegistry(
+		fakeModule{name: "plain"},
+		gatedModule{fakeModule{name: "beta", feature: "beta"}},
+		gatedModule{fakeModule{name: "dark", feature: "dark_launch"}},
+	)
+	server := fiber.New()
+	domain.InitModules(server, deps, registry)
+
+	get := func(path string) int {
+		resp, err := server.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, get("/api/v1/plain"))
+	assert.Equal(t, http.StatusOK, get("/api/v1/beta"), "a gated module whose flag is on is registered")
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/dark"), "a gated module whose flag is off is left out")
+}
+
+func TestRegistry_RejectsDuplicateNames(t *testing.T) {
+	assert.Panics(t, func() {
+		domain.NewRegistry(fakeModule{name: "users"}, fakeModule{name: "users"})
+	})
+}
+
+func TestDefaultModules_FeatureFlags(t *testing.T) {
+	cfg, err := config.LoadFromEnv()
+	require.NoError(t, err)
+	cfg.Env = "development"
+	cfg.DatabaseURL = ""
+	cfg.Features = nil
+
+	registered := domain.DefaultModules().Register(fiber.New().Group("/api/v1"), app.NewDeps(nil, cfg))
+	assert.Equal(t, []string{"common", "authentication", "user"}, registered, "admin and examples sit behind their flags")
+}
@@ -0,0 +1,21 @@
+package dto
+
+import (
+	"dvith.com/go-service-api/internal/identity"
+)
+
+// IdentityDTO is the public representation of a linked OAuth identity
+type IdentityDTO struct {
+	Provider string  `json:"provider" xml:"provider"`
+	Email    string  `json:"email,omitempty" xml:"email,omitempty"`
+	LinkedAt UTCTime `json:"linked_at" xml:"linked_at"`
+}
+
+// FromIdentity maps an identity to its response DTO
+func FromIdentity(i identity.Identity) IdentityDTO {
+	return IdentityDTO{
+		Provider: i.Provider,
+		Email:    i.Email,
+		LinkedAt: UTCTime(i.LinkedAt),
+	}
+}
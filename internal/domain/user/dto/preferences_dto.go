@@ -0,0 +1,24 @@
+package dto
+
+import "dvith.com/go-service-api/internal/preferences"
+
+// PreferencesDTO is the public representation of a user's preferences in
+// API responses
+type PreferencesDTO struct {
+	SecurityEmails bool    `json:"security_emails" xml:"security_emails"`
+	ProductEmails  bool    `json:"product_emails" xml:"product_emails"`
+	Locale         string  `json:"locale" xml:"locale"`
+	Timezone       string  `json:"timezone" xml:"timezone"`
+	UpdatedAt      UTCTime `json:"updated_at" xml:"updated_at"`
+}
+
+// FromPreferences maps preferences to their response DTO
+func FromPreferences(p *preferences.Preferences) PreferencesDTO {
+	return PreferencesDTO{
+		SecurityEmails: p.SecurityEmails,
+		ProductEmails:  p.ProductEmails,
+		Locale:         p.Locale,
+		Timezone:       p.Timezone,
+		UpdatedAt:      UTCTime(p.UpdatedAt),
+	}
+}
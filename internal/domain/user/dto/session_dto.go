@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"dvith.com/go-service-api/internal/session"
+	"github.com/google/uuid"
+)
+
+// SessionDTO is the public representation of a session in API responses
+type SessionDTO struct {
+	ID         uuid.UUID `json:"id" xml:"id"`
+	IP         string    `json:"ip,omitempty" xml:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty" xml:"user_agent,omitempty"`
+	Browser    string    `json:"browser" xml:"browser"`
+	OS         string    `json:"os" xml:"os"`
+	DeviceType string    `json:"device_type" xml:"device_type"`
+	CreatedAt  UTCTime   `json:"created_at" xml:"created_at"`
+	LastUsedAt UTCTime   `json:"last_used_at" xml:"last_used_at"`
+	RevokedAt  *UTCTime  `json:"revoked_at,omitempty" xml:"revoked_at,omitempty"`
+}
+
+// FromSession maps a session to its response DTO
+func FromSession(s session.Session) SessionDTO {
+	out := SessionDTO{
+		ID:         s.ID,
+		IP:         s.IP,
+		UserAgent:  s.UserAgent,
+		Browser:    s.Browser,
+		OS:         s.OS,
+		DeviceType: s.DeviceType,
+		CreatedAt:  UTCTime(s.CreatedAt),
+		LastUsedAt: UTCTime(s.LastUsedAt),
+	}
+	if s.RevokedAt != nil {
+		revokedAt := UTCTime(*s.RevokedAt)
+		out.RevokedAt = &revokedAt
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"time"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"github.com/google/uuid"
+)
+
+// UTCTime marshals as an RFC3339 timestamp in UTC regardless of the zone
+// the value was read in
+type UTCTime time.Time
+
+// MarshalJSON implements json.Marshaler
+func (t UTCTime) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + time.Time(t).UTC().Format(time.RFC3339) + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *UTCTime) UnmarshalJSON(data []byte) error {
+	var parsed time.Time
+	if err := parsed.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*t = UTCTime(parsed.UTC())
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, which XML encoding uses
+func (t UTCTime) MarshalText() ([]byte, error) {
+	return []byte(time.Time(t).UTC().Format(time.RFC3339)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (t *UTCTime) UnmarshalText(data []byte) error {
+	parsed, err := time.Parse(time.RFC3339, string(data))
+	if err != nil {
+		return err
+	}
+	*t = UTCTime(parsed.UTC())
+	return nil
+}
+
+// UserDTO is the public representation of a user in API responses
+type UserDTO struct {
+	ID            uuid.UUID `json:"id" xml:"id"`
+	Email         string    `json:"email" xml:"email"`
+	Username      string    `json:"username" xml:"username"`
+	FullName      string    `json:"full_name" xml:"full_name"`
+	IsActive      bool      `json:"is_active" xml:"is_active"`
+	EmailVerified bool      `json:"email_verified" xml:"email_verified"`
+	CreatedAt     UTCTime   `json:"created_at" xml:"created_at"`
+}
+
+// FromUser maps a user model to its response DTO
+func FromUser(u *model.User) UserDTO {
+	return UserDTO{
+		ID:            u.ID,
+		Email:         u.Email,
+		Username:      u.Username,
+		FullName:      string(u.FullName),
+		IsActive:      u.IsActive,
+		EmailVerified: u.EmailVerified,
+		CreatedAt:     UTCTime(u.CreatedAt),
+	}
+}
@@ -0,0 +1,61 @@
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromUser_NormalizesToUTC(t *testing.T) {
+	bangkok := time.FixedZone("ICT", 7*60*60)
+	u := &model.User{
+		ID:        uuid.New(),
+		Email:     "john@example.com",
+		Password:  "hashed",
+		FullName:  "John Doe",
+		Username:  "john_doe",
+		IsActive:  true,
+		CreatedAt: time.Date(2026, 3, 1, 9, 30, 0, 0, bangkok),
+	}
+
+	raw, err := json.Marshal(FromUser(u))
+	require.NoError(t, err)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(raw, &got))
+
+	assert.Equal(t, "2026-03-01T02:30:00Z", got["created_at"])
+}
+
+func TestFromUser_KeyNaming(t *testing.T) {
+	raw, err := json.Marshal(FromUser(&model.User{ID: uuid.New(), Password: "hashed"}))
+	require.NoError(t, err)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(raw, &got))
+
+	keys := make([]string, 0, len(got))
+	for k := range got {
+		keys = append(keys, k)
+	}
+	assert.ElementsMatch(t, []string{
+		"id", "email", "username", "full_name", "is_active", "email_verified", "created_at",
+	}, keys)
+	assert.NotContains(t, string(raw), "hashed")
+}
+
+func TestUTCTime_RoundTrip(t *testing.T) {
+	in := UTCTime(time.Date(2026, 3, 1, 2, 30, 0, 0, time.UTC))
+
+	raw, err := json.Marshal(in)
+	require.NoError(t, err)
+
+	var out UTCTime
+	require.NoError(t, json.Unmarshal(raw, &out))
+	assert.True(t, time.Time(in).Equal(time.Time(out)))
+}
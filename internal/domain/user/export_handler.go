@@ -0,0 +1,235 @@
+package private
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/domain/user/dto"
+	"dvith.com/go-service-api/internal/domain/user/exportjob"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/preferences"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// ExportInterval is the minimum time between two exports for the same user
+const ExportInterval = time.Hour
+
+// ExportSource provides the data included in a user export
+type ExportSource interface {
+	FindAccount(ctx context.Context, userID uuid.UUID) (*AccountData, error)
+	FindPreferences(ctx context.Context, userID uuid.UUID) (*preferences.Preferences, error)
+	StreamSessions(ctx context.Context, userID uuid.UUID, fn func(session.Session) error) error
+	StreamAuditEvents(ctx context.Context, userID uuid.UUID, fn func(audit.Event) error) error
+	RecordExport(ctx context.Context, userID uuid.UUID, format string) error
+}
+
+type dbExportSource struct {
+	*UserRepository
+	audits      *audit.Repository
+	sessions    *session.Repository
+	preferences *preferences.Repository
+}
+
+// NewExportSource creates an ExportSource backed by the database
+func NewExportSource(db *database.DBPool) ExportSource {
+	return &dbExportSource{
+		UserRepository: NewUserRepository(db),
+		audits:         audit.NewRepository(db),
+		sessions:       session.NewRepository(db),
+		preferences:    preferences.NewRepository(db),
+	}
+}
+
+func (s *dbExportSource) FindPreferences(ctx context.Context, userID uuid.UUID) (*preferences.Preferences, error) {
+	return s.preferences.Get(ctx, userID)
+}
+
+func (s *dbExportSource) StreamSessions(ctx context.Context, userID uuid.UUID, fn func(session.Session) error) error {
+	return s.sessions.StreamByUser(ctx, userID, fn)
+}
+
+func (s *dbExportSource) StreamAuditEvents(ctx context.Context, userID uuid.UUID, fn func(audit.Event) error) error {
+	return s.audits.StreamByUser(ctx, userID, fn)
+}
+
+// ExportHandler streams the authenticated user's data as a JSON attachment,
+// or as a ZIP archive containing that JSON when ?format=zip. The hourly
+// limit and the export's timestamp use now, clock.Now when nil.
+func ExportHandler(src ExportSource, limiter cache.Cache, now func() time.Time) fiber.Handler {
+	if now == nil {
+		now = clock.Now
+	}
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		format := c.Query("format", "json")
+		if format != "json" && format != "zip" {
+			return middleware.ValidationErrorResponse(c, "format must be json or zip")
+		}
+
+		limitKey := "user_export:" + userID.String()
+		exportedAt := now()
+		if !limiter.Add(limitKey, exportedAt, ExportInterval) {
+			retryAfter := ExportInterval
+			if v, ok := limiter.Get(limitKey); ok {
+				retryAfter = ExportInterval - exportedAt.Sub(v.(time.Time))
+			}
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(retryAfter.Seconds())+1))
+			return middleware.TooManyRequestsResponse(c, "data export is limited to once per hour")
+		}
+
+		// The stream below outlives the handler, which cancels the request
+		// context on return, so it gets an uncancellable copy.
+		ctx := context.WithoutCancel(middleware.GetRequestContext(c))
+		account, err := src.FindAccount(ctx, userID)
+		if err != nil {
+			limiter.Delete(limitKey)
+			if errors.Is(err, ErrUserNotFound) {
+				return middleware.NotFoundResponse(c, "user not found")
+			}
+			logger.Error("failed to load user for export", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to export user data", err)
+		}
+
+		if err := src.RecordExport(ctx, userID, format); err != nil {
+			logger.Warn("failed to record export audit event", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+		}
+
+		filename := "user-data-" + userID.String()
+		c.Attachment(filename + "." + format)
+
+		// The stream writer runs after the handler returns, so it must only
+		// use values captured here and never touch c.
+		return c.SendStreamWriter(func(w *bufio.Writer) {
+			err := writeExportFile(ctx, w, src, account, format, filename, exportedAt)
+			if err == nil {
+				err = w.Flush()
+			}
+
+			if err != nil {
+				logger.Error("user export stream failed", map[string]any{
+					"user_id": account.ID.String(),
+					"error":   err.Error(),
+				})
+			}
+		})
+	}
+}
+
+// ExportBuilder builds the files of export jobs like ExportHandler
+// streams them, auditing each export
+func ExportBuilder(src ExportSource, now func() time.Time) exportjob.Builder {
+	return func(ctx context.Context, w io.Writer, job *exportjob.Record) error {
+		account, err := src.FindAccount(ctx, job.UserID)
+		if err != nil {
+			return err
+		}
+		if err := src.RecordExport(ctx, job.UserID, job.Format); err != nil {
+			logger.Warn("failed to record export audit event", map[string]any{
+				"user_id": job.UserID.String(),
+				"error":   err.Error(),
+			})
+		}
+		return writeExportFile(ctx, w, src, account, job.Format, "user-data-"+job.UserID.String(), now())
+	}
+}
+
+// writeExportFile writes the export document to w, inside a ZIP archive as
+// filename.json when format is zip
+func writeExportFile(ctx context.Context, w io.Writer, src ExportSource, account *AccountData, format, filename string, now time.Time) error {
+	if format != "zip" {
+		return writeExport(ctx, w, src, account, now)
+	}
+
+	zw := zip.NewWriter(w)
+	f, err := zw.Create(filename + ".json")
+	if err == nil {
+		err = writeExport(ctx, f, src, account, now)
+	}
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeExport writes the export document to w, encoding sessions and audit
+// events one at a time as they are read from the source.
+func writeExport(ctx context.Context, w io.Writer, src ExportSource, account *AccountData, now time.Time) error {
+	enc := json.NewEncoder(w)
+
+	header, err := json.Marshal(now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, `{"exported_at":%s,"account":`, header); err != nil {
+		return err
+	}
+	if err := enc.Encode(account); err != nil {
+		return err
+	}
+
+	prefs, err := src.FindPreferences(ctx, account.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"preferences":`); err != nil {
+		return err
+	}
+	if err := enc.Encode(dto.FromPreferences(prefs)); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, `,"sessions":[`); err != nil {
+		return err
+	}
+	if err := src.StreamSessions(ctx, account.ID, streamElements[session.Session](w, enc)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `],"audit_events":[`); err != nil {
+		return err
+	}
+	if err := src.StreamAuditEvents(ctx, account.ID, streamElements[audit.Event](w, enc)); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+// streamElements returns a func encoding each value it is called with as
+// the next element of a JSON array already opened on w
+func streamElements[T any](w io.Writer, enc *json.Encoder) func(T) error {
+	first := true
+	return func(v T) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return enc.Encode(v)
+	}
+}
@@ -0,0 +1,234 @@
+package private
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/preferences"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeExportSource struct {
+	account  *AccountData
+	prefs    *preferences.Preferences
+	sessions []session.Session
+	events   []audit.Event
+}
+
+func (f *fakeExportSource) FindAccount(ctx context.Context, userID uuid.UUID) (*AccountData, error) {
+	if f.account == nil || f.account.ID != userID {
+		return nil, ErrUserNotFound
+	}
+	return f.account, nil
+}
+
+func (f *fakeExportSource) FindPreferences(ctx context.Context, userID uuid.UUID) (*preferences.Preferences, error) {
+	if f.prefs == nil {
+		defaults := preferences.Defaults(userID)
+		return &defaults, nil
+	}
+	return f.prefs, nil
+}
+
+func (f *fakeExportSource) StreamSessions(ctx context.Context, userID uuid.UUID, fn func(session.Session) error) error {
+	for _, s := range f.sessions {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeExportSource) StreamAuditEvents(ctx context.Context, userID uuid.UUID, fn func(audit.Event) error) error {
+	for _, e := range f.events {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeExportSource) RecordExport(ctx context.Context, userID uuid.UUID, format string) error {
+	return nil
+}
+
+func newExportTestApp(src ExportSource, userID uuid.UUID) *fiber.App {
+	return newExportTestAppAt(src, userID, nil)
+}
+
+func newExportTestAppAt(src ExportSource, userID uuid.UUID, now func() time.Time) *fiber.App {
+	app := fiber.New()
+	app.Get("/export", func(c fiber.Ctx) error {
+		c.Locals(middleware.ContextKeyUserID, userID)
+		return c.Next()
+	}, ExportHandler(src, cache.NewMemory(), now))
+	return app
+}
+
+func newFakeExportSource(userID uuid.UUID, events int) *fakeExportSource {
+	src := &fakeExportSource{
+		account: &AccountData{
+			ID:        userID,
+			Email:     "user@example.com",
+			Username:  "john_doe",
+			FullName:  "John Doe",
+			IsActive:  true,
+			CreatedAt: time.Now(),
+		},
+	}
+	for i := 0; i < events; i++ {
+		src.events = append(src.events, audit.Event{
+			ID:        uuid.New(),
+			UserID:    &userID,
+			Action:    audit.ActionUserSignedIn,
+			CreatedAt: time.Now(),
+		})
+	}
+	return src
+}
+
+type exportDocument struct {
+	ExportedAt  string           `json:"exported_at"`
+	Account     map[string]any   `json:"account"`
+	Preferences map[string]any   `json:"preferences"`
+	Sessions    []map[string]any `json:"sessions"`
+	AuditEvents []map[string]any `json:"audit_events"`
+}
+
+func TestExportHandler_StreamsJSON(t *testing.T) {
+	userID := uuid.New()
+	app := newExportTestApp(newFakeExportSource(userID, 3), userID)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/export", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), ".json")
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var doc exportDocument
+	require.NoError(t, json.Unmarshal(body, &doc), "export must be valid JSON: %s", body)
+
+	assert.NotEmpty(t, doc.ExportedAt)
+	assert.Equal(t, "user@example.com", doc.Account["email"])
+	assert.Len(t, doc.AuditEvents, 3)
+	assert.NotContains(t, doc.Account, "password")
+	assert.NotContains(t, string(body), "password")
+}
+
+func TestExportHandler_IncludesSessionsAndPreferences(t *testing.T) {
+	userID := uuid.New()
+	src := newFakeExportSource(userID, 1)
+	src.prefs = &preferences.Preferences{UserID: userID, SecurityEmails: true, ProductEmails: true, Locale: "th-TH", Timezone: "Asia/Bangkok"}
+	revoked := time.Now()
+	src.sessions = []session.Session{
+		{ID: uuid.New(), UserID: userID, IP: "203.0.113.7", Browser: "Firefox", OS: "Linux", DeviceType: "desktop", Fingerprint: "secret", CreatedAt: time.Now(), LastUsedAt: time.Now(), RevokedAt: &revoked},
+		{ID: uuid.New(), UserID: userID, Browser: "Safari", OS: "iOS", DeviceType: "mobile", CreatedAt: time.Now(), LastUsedAt: time.Now()},
+	}
+	app := newExportTestApp(src, userID)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/export", nil))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var doc exportDocument
+	require.NoError(t, json.Unmarshal(body, &doc), "export must be valid JSON: %s", body)
+	assert.Equal(t, "th-TH", doc.Preferences["locale"])
+	assert.Equal(t, "Asia/Bangkok", doc.Preferences["timezone"])
+	assert.Equal(t, true, doc.Preferences["product_emails"])
+
+	require.Len(t, doc.Sessions, 2)
+	assert.Equal(t, src.sessions[0].ID.String(), doc.Sessions[0]["id"])
+	assert.Equal(t, "203.0.113.7", doc.Sessions[0]["ip"])
+	assert.Contains(t, doc.Sessions[0], "revoked_at", "revoked sessions are exported too")
+	assert.Equal(t, "Safari", doc.Sessions[1]["browser"])
+	assert.NotContains(t, string(body), "secret", "fingerprints stay internal")
+	assert.Len(t, doc.AuditEvents, 1)
+}
+
+func TestExportHandler_EmptyAuditHistory(t *testing.T) {
+	userID := uuid.New()
+	app := newExportTestApp(newFakeExportSource(userID, 0), userID)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/export", nil))
+	require.NoError(t, err)
+
+	var doc exportDocument
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	assert.Empty(t, doc.AuditEvents)
+	assert.Empty(t, doc.Sessions)
+	assert.Equal(t, "en", doc.Preferences["locale"], "users who never changed anything export the defaults")
+}
+
+func TestExportHandler_Zip(t *testing.T) {
+	userID := uuid.New()
+	app := newExportTestApp(newFakeExportSource(userID, 2), userID)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/export?format=zip", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), ".zip")
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 1)
+
+	f, err := zr.File[0].Open()
+	require.NoError(t, err)
+	defer f.Close()
+
+	var doc exportDocument
+	require.NoError(t, json.NewDecoder(f).Decode(&doc))
+	assert.Len(t, doc.AuditEvents, 2)
+}
+
+func TestExportHandler_RateLimited(t *testing.T) {
+	userID := uuid.New()
+	frozen := clock.NewFrozen(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	app := newExportTestAppAt(newFakeExportSource(userID, 1), userID, frozen.Now)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/export", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	frozen.Advance(20 * time.Minute)
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/export", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "2401", resp.Header.Get("Retry-After"), "counted by the handler's clock")
+}
+
+func TestExportHandler_InvalidFormat(t *testing.T) {
+	userID := uuid.New()
+	app := newExportTestApp(newFakeExportSource(userID, 0), userID)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/export?format=xml", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// A rejected request must not consume the hourly allowance.
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/export", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
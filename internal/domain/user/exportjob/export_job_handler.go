@@ -0,0 +1,124 @@
+package exportjob
+
+import (
+	"errors"
+	"time"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// JobResponse is the body of the start and status routes
+type JobResponse struct {
+	Job JobDTO `json:"job"`
+}
+
+// JobDTO is an export job as shown to its user. DownloadURL is set once
+// the job is done.
+type JobDTO struct {
+	ID          uuid.UUID  `json:"id"`
+	Status      Status     `json:"status"`
+	Format      string     `json:"format"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	SizeBytes   int64      `json:"size_bytes,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+// fromRecord converts rec, leaving out why a failed job failed
+func fromRecord(rec *Record) JobDTO {
+	return JobDTO{
+		ID:         rec.ID,
+		Status:     rec.Status,
+		Format:     rec.Format,
+		CreatedAt:  rec.CreatedAt.UTC(),
+		StartedAt:  rec.StartedAt,
+		FinishedAt: rec.FinishedAt,
+		ExpiresAt:  rec.ExpiresAt.UTC(),
+		SizeBytes:  rec.SizeBytes,
+	}
+}
+
+// StartHandler queues an export of the authenticated user's data, as JSON
+// or as a ZIP archive when ?format=zip, and answers 202 with the job
+func StartHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		rec, err := service.Start(middleware.GetRequestContext(c), userID, c.Query("format", "json"))
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrInvalidFormat):
+				return middleware.ValidationErrorResponse(c, err.Error())
+			case errors.Is(err, ErrJobActive):
+				return middleware.ConflictResponse(c, err.Error())
+			}
+			logger.Error("failed to start export job", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to start export", err)
+		}
+
+		c.Location(c.Path() + "/" + rec.ID.String())
+		return middleware.Respond(c, fiber.StatusAccepted, JobResponse{Job: fromRecord(rec)})
+	}
+}
+
+// StatusHandler reports the authenticated user's job, with a download link
+// once it is done
+func StatusHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+		// ValidateParams has checked the ID
+		jobID := uuid.MustParse(c.Params("job_id"))
+
+		rec, err := service.Get(middleware.GetRequestContext(c), userID, jobID)
+		if err != nil {
+			if errors.Is(err, ErrJobNotFound) {
+				return middleware.NotFoundResponse(c, err.Error())
+			}
+			return middleware.InternalErrorResponse(c, "failed to load export job", err)
+		}
+
+		job := fromRecord(rec)
+		if rec.Status == StatusDone {
+			if job.DownloadURL, err = service.DownloadURL(rec); err != nil {
+				return middleware.InternalErrorResponse(c, "failed to sign download link", err)
+			}
+		}
+		return middleware.OK(c, JobResponse{Job: job})
+	}
+}
+
+// DownloadHandler serves the file of a signed download link. The link is
+// the credential, so the route needs no access token and can be opened by
+// a browser.
+func DownloadHandler(service *Service) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		rec, f, err := service.Open(middleware.GetRequestContext(c), c.OriginalURL())
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrInvalidLink):
+				return middleware.ValidationErrorResponse(c, ErrInvalidLink.Error())
+			case errors.Is(err, ErrJobNotFound), errors.Is(err, ErrNotReady):
+				return middleware.NotFoundResponse(c, ErrJobNotFound.Error())
+			}
+			return middleware.InternalErrorResponse(c, "failed to open export", err)
+		}
+
+		c.Attachment("user-data-" + rec.UserID.String() + "." + rec.Format)
+		// fasthttp closes f once it is sent
+		return c.SendStream(f, int(rec.SizeBytes))
+	}
+}
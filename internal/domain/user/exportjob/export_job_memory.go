@@ -0,0 +1,118 @@
+package exportjob
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps export jobs in memory for development mode and tests,
+// with the same one-active-job rule as PgRepository
+type MemoryStore struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*Record
+}
+
+// NewMemoryStore creates an empty in-memory job store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: map[uuid.UUID]*Record{}}
+}
+
+// Create implements Store
+func (m *MemoryStore) Create(ctx context.Context, rec *Record) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+	rec.TenantID = tenantID
+	rec.Status = StatusPending
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.jobs {
+		if r.UserID == rec.UserID && r.active() {
+			return ErrJobActive
+		}
+	}
+	cp := *rec
+	m.jobs[rec.ID] = &cp
+	return nil
+}
+
+// Find implements Store
+func (m *MemoryStore) Find(ctx context.Context, id uuid.UUID) (*Record, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.jobs[id]
+	if !ok || r.TenantID != tenantID {
+		return nil, ErrJobNotFound
+	}
+	cp := *r
+	return &cp, nil
+}
+
+// ClaimNext implements Store
+func (m *MemoryStore) ClaimNext(ctx context.Context, now, staleBefore time.Time) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var claimable []*Record
+	for _, r := range m.jobs {
+		if r.Status == StatusPending || (r.Status == StatusRunning && r.StartedAt.Before(staleBefore)) {
+			claimable = append(claimable, r)
+		}
+	}
+	if len(claimable) == 0 {
+		return nil, ErrJobNotFound
+	}
+	sort.Slice(claimable, func(i, j int) bool { return claimable[i].CreatedAt.Before(claimable[j].CreatedAt) })
+
+	r := claimable[0]
+	r.Status = StatusRunning
+	r.StartedAt = &now
+	cp := *r
+	return &cp, nil
+}
+
+// Finish implements Store
+func (m *MemoryStore) Finish(ctx context.Context, rec *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.jobs[rec.ID]
+	if !ok {
+		return ErrJobNotFound
+	}
+	r.Status = rec.Status
+	r.StorageKey = rec.StorageKey
+	r.SizeBytes = rec.SizeBytes
+	r.Error = rec.Error
+	r.FinishedAt = rec.FinishedAt
+	return nil
+}
+
+// DeleteExpired implements Store
+func (m *MemoryStore) DeleteExpired(ctx context.Context, cutoff time.Time) ([]Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted []Record
+	for id, r := range m.jobs {
+		if !r.ExpiresAt.After(cutoff) {
+			deleted = append(deleted, *r)
+			delete(m.jobs, id)
+		}
+	}
+	return deleted, nil
+}
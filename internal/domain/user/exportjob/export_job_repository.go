@@ -0,0 +1,174 @@
+package exportjob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/errs"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Status is where a job is in its lifecycle
+type Status string
+
+// Job statuses; pending and running jobs are active
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// Record is a row in the export_jobs table
+type Record struct {
+	ID         uuid.UUID  `db:"id"`
+	TenantID   uuid.UUID  `db:"tenant_id"`
+	UserID     uuid.UUID  `db:"user_id"`
+	Format     string     `db:"format"`
+	Status     Status     `db:"status"`
+	StorageKey string     `db:"storage_key"`
+	SizeBytes  int64      `db:"size_bytes"`
+	Error      string     `db:"error"`
+	CreatedAt  time.Time  `db:"created_at"`
+	StartedAt  *time.Time `db:"started_at"`
+	FinishedAt *time.Time `db:"finished_at"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+}
+
+// active reports whether r is pending or running
+func (r *Record) active() bool {
+	return r.Status == StatusPending || r.Status == StatusRunning
+}
+
+// Store persists export jobs
+type Store interface {
+	// Create inserts rec as pending, or returns ErrJobActive if the user
+	// already has a pending or running job
+	Create(ctx context.Context, rec *Record) error
+	// Find returns the job with the given ID in the current tenant
+	Find(ctx context.Context, id uuid.UUID) (*Record, error)
+	// ClaimNext marks the oldest pending job of any tenant running, or a
+	// running one started before staleBefore whose worker died, and returns
+	// it. It returns ErrJobNotFound when there is none.
+	ClaimNext(ctx context.Context, now, staleBefore time.Time) (*Record, error)
+	// Finish records the outcome of a running job: its status, storage
+	// key, size, error and finished_at
+	Finish(ctx context.Context, rec *Record) error
+	// DeleteExpired removes jobs that expired before cutoff and returns
+	// them, so their files can be deleted too
+	DeleteExpired(ctx context.Context, cutoff time.Time) ([]Record, error)
+}
+
+var (
+	_ Store = (*PgRepository)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+const recordColumns = `id, tenant_id, user_id, format, status, storage_key, size_bytes, error, created_at, started_at, finished_at, expires_at`
+
+// PgRepository is the PostgreSQL Store
+type PgRepository struct {
+	db *database.DBPool
+}
+
+// NewPgRepository creates a new export job repository
+func NewPgRepository(db *database.DBPool) *PgRepository {
+	return &PgRepository{db: db}
+}
+
+// Create implements Store. The partial unique index on active jobs turns a
+// concurrent second request into ErrJobActive.
+func (repo *PgRepository) Create(ctx context.Context, rec *Record) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+	rec.TenantID = tenantID
+	rec.Status = StatusPending
+
+	query := `
+		INSERT INTO export_jobs (id, tenant_id, user_id, format, status, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := repo.db.Exec(ctx, query, rec.ID, rec.TenantID, rec.UserID, rec.Format, rec.Status, rec.CreatedAt.UTC(), rec.ExpiresAt.UTC()); err != nil {
+		if errs.HasSQLState(err, errs.SQLStateUniqueViolation) {
+			return ErrJobActive
+		}
+		return fmt.Errorf("failed to create export job: %w", err)
+	}
+	return nil
+}
+
+// Find implements Store
+func (repo *PgRepository) Find(ctx context.Context, id uuid.UUID) (*Record, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + recordColumns + ` FROM export_jobs WHERE id = $1 AND tenant_id = $2`
+	rec, err := database.QueryOne[Record](ctx, repo.db, query, id, tenantID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load export job: %w", err)
+	}
+	return rec, nil
+}
+
+// ClaimNext implements Store. SKIP LOCKED lets several workers claim
+// different jobs at once.
+func (repo *PgRepository) ClaimNext(ctx context.Context, now, staleBefore time.Time) (*Record, error) {
+	query := `
+		UPDATE export_jobs SET status = 'running', started_at = $1
+		WHERE id = (
+			SELECT id FROM export_jobs
+			WHERE status = 'pending' OR (status = 'running' AND started_at < $2)
+			ORDER BY created_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + recordColumns
+	rec, err := database.QueryOne[Record](ctx, repo.db, query, now.UTC(), staleBefore.UTC())
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim export job: %w", err)
+	}
+	return rec, nil
+}
+
+// Finish implements Store
+func (repo *PgRepository) Finish(ctx context.Context, rec *Record) error {
+	query := `
+		UPDATE export_jobs
+		SET status = $2, storage_key = $3, size_bytes = $4, error = $5, finished_at = $6
+		WHERE id = $1
+	`
+	var finishedAt *time.Time
+	if rec.FinishedAt != nil {
+		t := rec.FinishedAt.UTC()
+		finishedAt = &t
+	}
+	if _, err := repo.db.Exec(ctx, query, rec.ID, rec.Status, rec.StorageKey, rec.SizeBytes, rec.Error, finishedAt); err != nil {
+		return fmt.Errorf("failed to finish export job: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired implements Store
+func (repo *PgRepository) DeleteExpired(ctx context.Context, cutoff time.Time) ([]Record, error) {
+	query := `DELETE FROM export_jobs WHERE expires_at <= $1 RETURNING ` + recordColumns
+	recs, err := database.QueryAll[Record](ctx, repo.db, query, cutoff.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete expired export jobs: %w", err)
+	}
+	return recs, nil
+}
@@ -0,0 +1,316 @@
+// Package exportjob builds a user's data export in the background, for
+// accounts whose export takes too long to stream in one request. Clients
+// start a job, poll it and download the file through a signed link.
+package exportjob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// Retention is how long a job and its file are kept after it starts
+	Retention = 7 * 24 * time.Hour
+	// LinkTTL is how long a download link is valid; polling the job again
+	// returns a fresh one
+	LinkTTL = 15 * time.Minute
+	// RunTimeout is how long a job may run before another worker takes it
+	// over, assuming the first one died
+	RunTimeout = 30 * time.Minute
+	// WorkerJobName identifies the export worker in the jobs runner
+	WorkerJobName = "export_jobs"
+	// WorkerInterval is how often the worker looks for pending jobs
+	WorkerInterval = 5 * time.Second
+	// CleanupJobName identifies the expired job cleanup in the jobs runner
+	CleanupJobName = "export_job_cleanup"
+	// CleanupInterval is how often expired jobs are deleted
+	CleanupInterval = time.Hour
+	// DownloadRoute is the route of download links, under /api/v1
+	DownloadRoute = "/exports/:job_id/download"
+)
+
+// Export job errors caused by the request rather than by the server
+var (
+	ErrJobNotFound = errors.New("export job not found")
+	// ErrJobActive is returned when starting a job while the user's
+	// previous one is still pending or running
+	ErrJobActive = errors.New("an export is already in progress")
+	// ErrInvalidFormat is returned for a format other than json or zip
+	ErrInvalidFormat = errors.New("format must be json or zip")
+	// ErrNotReady is returned when downloading a job that isn't done
+	ErrNotReady = errors.New("export is not ready")
+	// ErrInvalidLink is returned for a tampered or expired download link
+	ErrInvalidLink = errors.New("invalid or expired download link")
+)
+
+// DownloadPath is the path of a job's download link
+func DownloadPath(jobID uuid.UUID) string {
+	return "/api/v1/exports/" + jobID.String() + "/download"
+}
+
+// Builder writes the export of job's user in job.Format to w
+type Builder func(ctx context.Context, w io.Writer, job *Record) error
+
+// LinkSigner signs download links and checks them when opened.
+// signedurl.Signer implements it.
+type LinkSigner interface {
+	Sign(path string, params map[string]string, ttl time.Duration) (string, error)
+	Verify(rawURL string) (map[string]string, error)
+}
+
+// Service starts export jobs, runs them and serves their files
+type Service struct {
+	store Store
+	files storage.Backend
+	links LinkSigner
+	build Builder
+	now   func() time.Time
+}
+
+// NewService creates an export job service writing files built by build
+// to files
+func NewService(store Store, files storage.Backend, links LinkSigner, build Builder) *Service {
+	return &Service{store: store, files: files, links: links, build: build, now: clock.Now}
+}
+
+// WithClock replaces the time source, for tests
+func (s *Service) WithClock(now func() time.Time) *Service {
+	s.now = now
+	return s
+}
+
+// Start queues an export of the user's data in format, json or zip
+func (s *Service) Start(ctx context.Context, userID uuid.UUID, format string) (*Record, error) {
+	if format != "json" && format != "zip" {
+		return nil, ErrInvalidFormat
+	}
+
+	now := s.now()
+	rec := &Record{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Format:    format,
+		CreatedAt: now,
+		ExpiresAt: now.Add(Retention),
+	}
+	if err := s.store.Create(ctx, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Get returns the user's job with the given ID. Jobs of other users and
+// expired ones are not found.
+func (s *Service) Get(ctx context.Context, userID, jobID uuid.UUID) (*Record, error) {
+	rec, err := s.store.Find(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if rec.UserID != userID || !rec.ExpiresAt.After(s.now()) {
+		return nil, ErrJobNotFound
+	}
+	return rec, nil
+}
+
+// DownloadURL signs a link to the file of a done job, valid for LinkTTL or
+// until the job expires if that is sooner
+func (s *Service) DownloadURL(rec *Record) (string, error) {
+	if rec.Status != StatusDone {
+		return "", ErrNotReady
+	}
+	ttl := min(LinkTTL, rec.ExpiresAt.Sub(s.now()))
+	return s.links.Sign(DownloadPath(rec.ID), map[string]string{"job": rec.ID.String()}, ttl)
+}
+
+// Open checks a download link and opens the job's file; the caller closes
+// it
+func (s *Service) Open(ctx context.Context, link string) (*Record, io.ReadCloser, error) {
+	params, err := s.links.Verify(link)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidLink, err)
+	}
+	jobID, err := uuid.Parse(params["job"])
+	if err != nil {
+		return nil, nil, ErrInvalidLink
+	}
+
+	rec, err := s.store.Find(ctx, jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rec.ExpiresAt.After(s.now()) {
+		return nil, nil, ErrJobNotFound
+	}
+	if rec.Status != StatusDone {
+		return nil, nil, ErrNotReady
+	}
+
+	f, err := s.files.Open(ctx, rec.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return rec, f, nil
+}
+
+// RunNext claims a pending job and builds its file. It reports whether
+// there was a job; a failed build marks the job failed rather than
+// returning an error.
+func (s *Service) RunNext(ctx context.Context) (bool, error) {
+	now := s.now()
+	rec, err := s.store.ClaimNext(ctx, now, now.Add(-RunTimeout))
+	if errors.Is(err, ErrJobNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The runner has no tenant; the job's own is used for its queries
+	ctx = tenant.WithID(ctx, rec.TenantID)
+	key := fmt.Sprintf("exports/%s/%s.%s", rec.TenantID, rec.ID, rec.Format)
+	size, buildErr := s.write(ctx, key, rec)
+
+	finishedAt := s.now()
+	rec.FinishedAt = &finishedAt
+	if buildErr != nil {
+		rec.Status = StatusFailed
+		rec.Error = buildErr.Error()
+		_ = s.files.Delete(ctx, key)
+		logger.Error("export job failed", map[string]any{
+			"job_id":  rec.ID.String(),
+			"user_id": rec.UserID.String(),
+			"error":   buildErr.Error(),
+		})
+	} else {
+		rec.Status = StatusDone
+		rec.StorageKey = key
+		rec.SizeBytes = size
+		logger.Info("export job done", map[string]any{
+			"job_id":   rec.ID.String(),
+			"user_id":  rec.UserID.String(),
+			"bytes":    size,
+			"duration": finishedAt.Sub(now).String(),
+		})
+	}
+
+	// The file is written by now, so a failure here leaves the job running
+	// until another worker retries it after RunTimeout
+	if err := s.store.Finish(ctx, rec); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// write streams the build of rec into the file under key and returns its
+// size
+func (s *Service) write(ctx context.Context, key string, rec *Record) (int64, error) {
+	pr, pw := io.Pipe()
+	counter := &countingWriter{w: pw}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(s.build(ctx, counter, rec))
+	}()
+
+	err := s.files.Put(ctx, key, pr)
+	// Unblocks the builder if Put gave up before reading everything
+	pr.CloseWithError(err)
+	<-done
+	return counter.n, err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// DeleteExpired deletes the jobs that expired and their files
+func (s *Service) DeleteExpired(ctx context.Context) (int, error) {
+	recs, err := s.store.DeleteExpired(ctx, s.now())
+	if err != nil {
+		return 0, err
+	}
+	for _, rec := range recs {
+		if rec.StorageKey == "" {
+			continue
+		}
+		if err := s.files.Delete(ctx, rec.StorageKey); err != nil {
+			logger.Warn("failed to delete expired export file", map[string]any{
+				"job_id": rec.ID.String(),
+				"key":    rec.StorageKey,
+				"error":  err.Error(),
+			})
+		}
+	}
+	return len(recs), nil
+}
+
+// Worker is the jobs runner job building pending exports
+type Worker struct {
+	service *Service
+}
+
+// NewWorker creates the export worker
+func NewWorker(service *Service) *Worker {
+	return &Worker{service: service}
+}
+
+// Name implements jobs.Job
+func (w *Worker) Name() string { return WorkerJobName }
+
+// Run implements jobs.Job, building pending jobs until there are none
+func (w *Worker) Run(ctx context.Context) error {
+	for ctx.Err() == nil {
+		ran, err := w.service.RunNext(ctx)
+		if err != nil || !ran {
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanupJob deletes expired export jobs and their files
+type CleanupJob struct {
+	service *Service
+}
+
+// NewCleanupJob creates the expired export job cleanup job
+func NewCleanupJob(service *Service) *CleanupJob {
+	return &CleanupJob{service: service}
+}
+
+// Name implements jobs.Job
+func (j *CleanupJob) Name() string { return CleanupJobName }
+
+// Run implements jobs.Job
+func (j *CleanupJob) Run(ctx context.Context) error {
+	deleted, err := j.service.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logger.Info("deleted expired export jobs", map[string]any{
+			"deleted": deleted,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package exportjob
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/signedurl"
+	"dvith.com/go-service-api/pkg/storage"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEnv is a service on in-memory stores with a movable clock
+type testEnv struct {
+	mu      sync.Mutex
+	now     time.Time
+	ctx     context.Context
+	store   *MemoryStore
+	files   *storage.Memory
+	service *Service
+	// fail makes the builder fail for the listed users
+	fail map[uuid.UUID]bool
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	env := &testEnv{
+		now:   time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC),
+		ctx:   tenant.WithID(context.Background(), uuid.New()),
+		store: NewMemoryStore(),
+		files: storage.NewMemory(),
+		fail:  map[uuid.UUID]bool{},
+	}
+	links := signedurl.MustNew("https://api.example.com", "test-secret").WithClock(env.clock).WithSkew(0)
+	build := func(ctx context.Context, w io.Writer, job *Record) error {
+		if env.fail[job.UserID] {
+			return errors.New("audit log unavailable")
+		}
+		_, err := io.WriteString(w, `{"user_id":"`+job.UserID.String()+`"}`)
+		return err
+	}
+	env.service = NewService(env.store, env.files, links, build).WithClock(env.clock)
+	return env
+}
+
+func (e *testEnv) clock() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.now
+}
+
+func (e *testEnv) advance(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.now = e.now.Add(d)
+}
+
+// runWorker drains the queue like the jobs runner would
+func (e *testEnv) runWorker(t *testing.T) {
+	t.Helper()
+	require.NoError(t, NewWorker(e.service).Run(context.Background()))
+}
+
+// newApp routes requests like the user module, as userID
+func (e *testEnv) newApp(userID uuid.UUID) *fiber.App {
+	app := fiber.New()
+	asUser := func(c fiber.Ctx) error {
+		c.Locals(middleware.ContextKeyRequestContext, e.ctx)
+		c.Locals(middleware.ContextKeyUserID, userID)
+		return c.Next()
+	}
+	app.Post("/api/v1/user/export", asUser, StartHandler(e.service))
+	app.Get("/api/v1/user/export/:job_id", asUser, StatusHandler(e.service))
+	app.Get("/api/v1"+DownloadRoute, func(c fiber.Ctx) error {
+		c.Locals(middleware.ContextKeyRequestContext, e.ctx)
+		return c.Next()
+	}, DownloadHandler(e.service))
+	return app
+}
+
+func doJSON(t *testing.T, app *fiber.App, method, target string, out any) int {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(method, target, nil))
+	require.NoError(t, err)
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestExportJob_WorkerBuildsDownloadableFile(t *testing.T) {
+	env := newTestEnv(t)
+	userID := uuid.New()
+	app := env.newApp(userID)
+
+	var started JobResponse
+	require.Equal(t, fiber.StatusAccepted, doJSON(t, app, fiber.MethodPost, "/api/v1/user/export?format=json", &started))
+	assert.Equal(t, StatusPending, started.Job.Status)
+	assert.Equal(t, env.now.Add(Retention), started.Job.ExpiresAt)
+
+	statusURL := "/api/v1/user/export/" + started.Job.ID.String()
+	var polled JobResponse
+	require.Equal(t, fiber.StatusOK, doJSON(t, app, fiber.MethodGet, statusURL, &polled))
+	assert.Equal(t, StatusPending, polled.Job.Status)
+	assert.Empty(t, polled.Job.DownloadURL, "no link before the file exists")
+
+	env.advance(time.Minute)
+	env.runWorker(t)
+
+	require.Equal(t, fiber.StatusOK, doJSON(t, app, fiber.MethodGet, statusURL, &polled))
+	assert.Equal(t, StatusDone, polled.Job.Status)
+	require.NotEmpty(t, polled.Job.DownloadURL)
+	assert.NotNil(t, polled.Job.FinishedAt)
+
+	link, err := url.Parse(polled.Job.DownloadURL)
+	require.NoError(t, err)
+	assert.Equal(t, DownloadPath(started.Job.ID), link.Path)
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, link.RequestURI(), nil))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get(fiber.HeaderContentDisposition), "user-data-"+userID.String()+".json")
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"user_id":"`+userID.String()+`"}`, string(body))
+	assert.Equal(t, int64(len(body)), polled.Job.SizeBytes)
+}
+
+func TestExportJob_OneActiveJobPerUser(t *testing.T) {
+	env := newTestEnv(t)
+	userID := uuid.New()
+	app := env.newApp(userID)
+
+	require.Equal(t, fiber.StatusAccepted, doJSON(t, app, fiber.MethodPost, "/api/v1/user/export", nil))
+	assert.Equal(t, fiber.StatusConflict, doJSON(t, app, fiber.MethodPost, "/api/v1/user/export?format=zip", nil))
+	_, err := env.service.Start(env.ctx, uuid.New(), "json")
+	assert.NoError(t, err, "other users are not affected")
+	env.runWorker(t)
+
+	_, err = env.service.Start(env.ctx, userID, "json")
+	require.NoError(t, err)
+
+	// Running still counts as active
+	rec, err := env.store.ClaimNext(env.ctx, env.now, env.now.Add(-RunTimeout))
+	require.NoError(t, err)
+	_, err = env.service.Start(env.ctx, userID, "json")
+	assert.ErrorIs(t, err, ErrJobActive)
+
+	rec.Status = StatusDone
+	require.NoError(t, env.store.Finish(env.ctx, rec))
+	_, err = env.service.Start(env.ctx, userID, "zip")
+	assert.NoError(t, err, "a finished job no longer blocks a new one")
+}
+
+func TestExportJob_FailedBuild(t *testing.T) {
+	env := newTestEnv(t)
+	userID := uuid.New()
+	env.fail[userID] = true
+	rec, err := env.service.Start(env.ctx, userID, "json")
+	require.NoError(t, err)
+
+	env.runWorker(t)
+
+	rec, err = env.service.Get(env.ctx, userID, rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, rec.Status)
+	assert.Empty(t, env.files.Keys(), "a partial file is not kept")
+	_, err = env.service.DownloadURL(rec)
+	assert.ErrorIs(t, err, ErrNotReady)
+
+	_, err = env.service.Start(env.ctx, userID, "json")
+	assert.NoError(t, err, "the user may retry")
+}
+
+func TestExportJob_StaleRunningJobIsRetried(t *testing.T) {
+	env := newTestEnv(t)
+	rec, err := env.service.Start(env.ctx, uuid.New(), "json")
+	require.NoError(t, err)
+
+	// A worker claims the job and dies
+	_, err = env.store.ClaimNext(env.ctx, env.now, env.now.Add(-RunTimeout))
+	require.NoError(t, err)
+	env.runWorker(t)
+	got, _ := env.store.Find(env.ctx, rec.ID)
+	assert.Equal(t, StatusRunning, got.Status, "a running job isn't taken over early")
+
+	env.advance(RunTimeout + time.Second)
+	env.runWorker(t)
+	got, _ = env.store.Find(env.ctx, rec.ID)
+	assert.Equal(t, StatusDone, got.Status)
+}
+
+func TestExportJob_Expiry(t *testing.T) {
+	env := newTestEnv(t)
+	userID := uuid.New()
+	app := env.newApp(userID)
+	rec, err := env.service.Start(env.ctx, userID, "zip")
+	require.NoError(t, err)
+	env.runWorker(t)
+	rec, err = env.service.Get(env.ctx, userID, rec.ID)
+	require.NoError(t, err)
+
+	// Download links are short-lived; polling again signs a new one
+	link, err := env.service.DownloadURL(rec)
+	require.NoError(t, err)
+	env.advance(LinkTTL + time.Second)
+	_, _, err = env.service.Open(env.ctx, link)
+	assert.ErrorIs(t, err, ErrInvalidLink)
+	link, err = env.service.DownloadURL(rec)
+	require.NoError(t, err)
+	_, f, err := env.service.Open(env.ctx, link)
+	require.NoError(t, err)
+	f.Close()
+
+	// A job is gone once its retention passes, even before the cleanup runs
+	env.advance(Retention)
+	assert.Equal(t, fiber.StatusNotFound, doJSON(t, app, fiber.MethodGet, "/api/v1/user/export/"+rec.ID.String(), nil))
+	_, _, err = env.service.Open(env.ctx, link)
+	assert.Error(t, err)
+
+	require.NoError(t, NewCleanupJob(env.service).Run(context.Background()))
+	_, err = env.store.Find(env.ctx, rec.ID)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	assert.Empty(t, env.files.Keys(), "the file is deleted with the job")
+}
+
+func TestExportJob_OtherUsersJobsAreHidden(t *testing.T) {
+	env := newTestEnv(t)
+	owner := uuid.New()
+	rec, err := env.service.Start(env.ctx, owner, "json")
+	require.NoError(t, err)
+
+	app := env.newApp(uuid.New())
+	assert.Equal(t, fiber.StatusNotFound, doJSON(t, app, fiber.MethodGet, "/api/v1/user/export/"+rec.ID.String(), nil))
+	var body map[string]any
+	assert.Equal(t, fiber.StatusBadRequest, doJSON(t, app, fiber.MethodPost, "/api/v1/user/export?format=xml", &body))
+	assert.True(t, strings.Contains(body["message"].(string), "json or zip"))
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"dvith.com/go-service-api/pkg/crypto"
+	"github.com/google/uuid"
+)
+
+// User represents a user in the system
+type User struct {
+	ID       uuid.UUID `db:"id" json:"id"`
+	TenantID uuid.UUID `db:"tenant_id" json:"tenant_id"`
+	Email    string    `db:"email" json:"email"`
+	Password string    `db:"password" json:"-"`
+	// FullName is encrypted at rest
+	FullName      crypto.EncryptedString `db:"full_name" json:"full_name"`
+	Username      string                 `db:"username" json:"username"`
+	Role          string                 `db:"role" json:"role"`
+	IsActive      bool                   `db:"is_active" json:"is_active"`
+	EmailVerified bool                   `db:"email_verified" json:"email_verified"`
+	VerifiedAt    *time.Time             `db:"verified_at" json:"verified_at"`
+	CreatedAt     time.Time              `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time              `db:"updated_at" json:"updated_at"`
+	DeletedAt     *time.Time             `db:"deleted_at" json:"deleted_at"`
+	// PasswordChangedAt is nil when the password predates tracking or came
+	// from an import
+	PasswordChangedAt *time.Time `db:"password_changed_at" json:"-"`
+	// MustResetPassword blocks signin until the password is reset, set by
+	// an admin after a credential leak
+	MustResetPassword bool `db:"must_reset_password" json:"-"`
+	// TokenVersion counts changes to how the user signs in, such as a new
+	// email
+	TokenVersion int `db:"token_version" json:"-"`
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/cache"
+	"github.com/google/uuid"
+)
+
+// DefaultUserCacheTTL is how long CachedStore keeps a user by default
+const DefaultUserCacheTTL = 30 * time.Second
+
+var _ Store = (*CachedStore)(nil)
+
+// CachedStore is a Store that also serves FindCachedByID from a cache
+// shared across requests. FindByID still reads the store, for callers that
+// must see the latest row. Every write made through it purges the user's
+// entry; writes made elsewhere, such as an email change or a forced reset,
+// revoke the user's sessions and are seen once the entry expires.
+type CachedStore struct {
+	Store
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// NewCachedStore caches the users of store in c for ttl, DefaultUserCacheTTL
+// when zero
+func NewCachedStore(store Store, c cache.Cache, ttl time.Duration) *CachedStore {
+	if ttl <= 0 {
+		ttl = DefaultUserCacheTTL
+	}
+	return &CachedStore{Store: store, cache: c, ttl: ttl}
+}
+
+// userCacheKey is scoped by tenant, like the users table
+func userCacheKey(ctx context.Context, userID uuid.UUID) (string, bool) {
+	tenantID, ok := tenant.IDFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return "user:" + tenantID.String() + ":" + userID.String(), true
+}
+
+// FindCachedByID is FindByID from the cache when it can. Callers get a
+// copy, so changing it doesn't change the cached user.
+func (s *CachedStore) FindCachedByID(ctx context.Context, userID uuid.UUID) (*User, error) {
+	key, ok := userCacheKey(ctx, userID)
+	if !ok {
+		return s.Store.FindByID(ctx, userID, ActiveOnly)
+	}
+	if v, ok := s.cache.Get(key); ok {
+		u := *v.(*User)
+		return &u, nil
+	}
+
+	user, err := s.Store.FindByID(ctx, userID, ActiveOnly)
+	if err != nil {
+		return nil, err
+	}
+	cached := *user
+	s.cache.Set(key, &cached, s.ttl)
+	return user, nil
+}
+
+// Purge drops the cached copy of the user
+func (s *CachedStore) Purge(ctx context.Context, userID uuid.UUID) {
+	if key, ok := userCacheKey(ctx, userID); ok {
+		s.cache.Delete(key)
+	}
+}
+
+// ChangeProfile implements Store
+func (s *CachedStore) ChangeProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate, policy UsernamePolicy) (*User, error) {
+	s.Purge(ctx, userID)
+	return s.Store.ChangeProfile(ctx, userID, update, policy)
+}
+
+// UpdateProfile implements Store
+func (s *CachedStore) UpdateProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate) (*User, error) {
+	defer s.Purge(ctx, userID)
+	return s.Store.UpdateProfile(ctx, userID, update)
+}
+
+// SetPassword implements Store
+func (s *CachedStore) SetPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	defer s.Purge(ctx, userID)
+	return s.Store.SetPassword(ctx, userID, passwordHash)
+}
+
+// RehashPassword implements Store
+func (s *CachedStore) RehashPassword(ctx context.Context, userID uuid.UUID, oldHash, newHash string) error {
+	defer s.Purge(ctx, userID)
+	return s.Store.RehashPassword(ctx, userID, oldHash, newHash)
+}
+
+// MarkEmailVerified implements Store
+func (s *CachedStore) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	defer s.Purge(ctx, userID)
+	return s.Store.MarkEmailVerified(ctx, userID)
+}
+
+// SetRole implements Store
+func (s *CachedStore) SetRole(ctx context.Context, userID uuid.UUID, role string) error {
+	defer s.Purge(ctx, userID)
+	return s.Store.SetRole(ctx, userID, role)
+}
+
+// SoftDelete implements Store
+func (s *CachedStore) SoftDelete(ctx context.Context, userID uuid.UUID) error {
+	defer s.Purge(ctx, userID)
+	return s.Store.SoftDelete(ctx, userID)
+}
+
+// Restore implements Store
+func (s *CachedStore) Restore(ctx context.Context, userID uuid.UUID) error {
+	defer s.Purge(ctx, userID)
+	return s.Store.Restore(ctx, userID)
+}
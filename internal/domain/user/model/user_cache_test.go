@@ -0,0 +1,69 @@
+package model
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/cache"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore counts the FindByID calls that reach the store
+type countingStore struct {
+	Store
+	finds atomic.Int32
+}
+
+func (s *countingStore) FindByID(ctx context.Context, userID uuid.UUID, scope Scope) (*User, error) {
+	s.finds.Add(1)
+	return s.Store.FindByID(ctx, userID, scope)
+}
+
+func TestCachedStore_WritesPurgeTheCache(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	inner := &countingStore{Store: NewMemoryStore()}
+	store := NewCachedStore(inner, cache.NewMemory(), 0)
+	saved, err := store.SaveUser(ctx, &User{Email: "a@example.com", Username: "alice", Password: "old"})
+	require.NoError(t, err)
+
+	user, err := store.FindCachedByID(ctx, saved.ID)
+	require.NoError(t, err)
+	user.Username = "changed by the caller"
+	user, err = store.FindCachedByID(ctx, saved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username, "callers get a copy")
+	assert.Equal(t, int32(1), inner.finds.Load())
+
+	name := "Alice"
+	_, err = store.UpdateProfile(ctx, saved.ID, ProfileUpdate{FullName: &name})
+	require.NoError(t, err)
+	user, err = store.FindCachedByID(ctx, saved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", string(user.FullName))
+
+	require.NoError(t, store.SetPassword(ctx, saved.ID, "new"))
+	user, err = store.FindCachedByID(ctx, saved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new", user.Password)
+	assert.Equal(t, int32(3), inner.finds.Load(), "each write sends the next read to the store")
+
+	_, err = store.FindByID(ctx, saved.ID, ActiveOnly)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), inner.finds.Load(), "FindByID bypasses the cache")
+}
+
+func TestCachedStore_ScopedByTenant(t *testing.T) {
+	store := NewCachedStore(NewMemoryStore(), cache.NewMemory(), 0)
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	saved, err := store.SaveUser(ctx, &User{Email: "a@example.com", Username: "alice"})
+	require.NoError(t, err)
+	_, err = store.FindCachedByID(ctx, saved.ID)
+	require.NoError(t, err)
+
+	_, err = store.FindCachedByID(tenant.WithID(context.Background(), uuid.New()), saved.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
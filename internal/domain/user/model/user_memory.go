@@ -0,0 +1,309 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/crypto"
+	"github.com/google/uuid"
+)
+
+// Store is the user persistence contract shared by UserRepository and
+// MemoryStore
+type Store interface {
+	SaveUser(ctx context.Context, user *User) (*User, error)
+	FindByEmail(ctx context.Context, email string, scope Scope) (*User, error)
+	FindByID(ctx context.Context, userID uuid.UUID, scope Scope) (*User, error)
+	UpdateProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate) (*User, error)
+	ChangeProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate, policy UsernamePolicy) (*User, error)
+	SetPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error
+	RehashPassword(ctx context.Context, userID uuid.UUID, oldHash, newHash string) error
+	MarkEmailVerified(ctx context.Context, userID uuid.UUID) error
+	SetRole(ctx context.Context, userID uuid.UUID, role string) error
+	SoftDelete(ctx context.Context, userID uuid.UUID) error
+	Restore(ctx context.Context, userID uuid.UUID) error
+}
+
+var (
+	_ Store = (*UserRepository)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// MemoryStore keeps users in memory with the same semantics as the users
+// table: email and username are unique per tenant, soft-deleted users keep
+// holding theirs, and lookups are limited to their Scope. It backs
+// development mode when no database is configured and doubles as a test
+// fake.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]*User
+	// history holds the usernames renamed away from, see ChangeProfile
+	history []usernameChange
+	now     func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory user store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{users: make(map[uuid.UUID]*User), now: clock.Now}
+}
+
+// WithClock replaces the time source for timestamps, for tests
+func (s *MemoryStore) WithClock(now func() time.Time) *MemoryStore {
+	s.now = now
+	return s
+}
+
+// SaveUser implements Store
+func (s *MemoryStore) SaveUser(ctx context.Context, user *User) (*User, error) {
+	if user == nil {
+		return nil, fmt.Errorf("user cannot be nil")
+	}
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.taken(tenantID, uuid.Nil, user.Email, user.Username) || s.reserved(tenantID, user.ID, user.Username, s.now()) {
+		return nil, ErrUserExists
+	}
+
+	saved := *user
+	saved.TenantID = tenantID
+	if saved.ID == uuid.Nil {
+		saved.ID = uuid.New()
+	}
+	if saved.Role == "" {
+		saved.Role = "user"
+	}
+	now := s.now()
+	saved.CreatedAt = now
+	saved.UpdatedAt = now
+	if saved.Password != "" {
+		saved.PasswordChangedAt = &now
+	}
+	s.users[saved.ID] = &saved
+
+	*user = saved
+	out := saved
+	return &out, nil
+}
+
+// FindByEmail implements Store
+func (s *MemoryStore) FindByEmail(ctx context.Context, email string, scope Scope) (*User, error) {
+	if email == "" {
+		return nil, fmt.Errorf("email cannot be empty")
+	}
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.TenantID == tenantID && u.Email == email && scope.Includes(u) {
+			out := *u
+			return &out, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// Availability reports whether a user, deleted or not, already holds email
+// and whether one holds username, or it is reserved for a user who renamed
+// away from it
+func (s *MemoryStore) Availability(ctx context.Context, email, username string) (Availability, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return Availability{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Availability{
+		EmailTaken:    s.taken(tenantID, uuid.Nil, email, ""),
+		UsernameTaken: s.taken(tenantID, uuid.Nil, "", username) || s.reserved(tenantID, uuid.Nil, username, s.now()),
+	}, nil
+}
+
+// FindByID implements Store
+func (s *MemoryStore) FindByID(ctx context.Context, userID uuid.UUID, scope Scope) (*User, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok || u.TenantID != tenantID || !scope.Includes(u) {
+		return nil, ErrUserNotFound
+	}
+	out := *u
+	return &out, nil
+}
+
+// UpdateProfile implements Store
+func (s *MemoryStore) UpdateProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate) (*User, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.live(tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if update.Username != nil && s.taken(tenantID, userID, "", *update.Username) {
+		return nil, ErrUserExists
+	}
+
+	if update.FullName != nil {
+		u.FullName = crypto.EncryptedString(*update.FullName)
+	}
+	if update.Username != nil {
+		u.Username = *update.Username
+	}
+	u.UpdatedAt = s.now()
+
+	out := *u
+	return &out, nil
+}
+
+// SetPassword implements Store
+func (s *MemoryStore) SetPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	if passwordHash == "" {
+		return fmt.Errorf("password hash cannot be empty")
+	}
+	return s.update(ctx, userID, func(u *User, now time.Time) {
+		u.Password = passwordHash
+		u.PasswordChangedAt = &now
+		u.MustResetPassword = false
+	})
+}
+
+// RehashPassword implements Store
+func (s *MemoryStore) RehashPassword(ctx context.Context, userID uuid.UUID, oldHash, newHash string) error {
+	if newHash == "" {
+		return fmt.Errorf("password hash cannot be empty")
+	}
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.live(tenantID, userID)
+	if err != nil {
+		return err
+	}
+	if u.Password != oldHash {
+		return ErrUserNotFound
+	}
+	u.Password = newHash
+	u.UpdatedAt = s.now()
+	return nil
+}
+
+// MarkEmailVerified implements Store
+func (s *MemoryStore) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	return s.update(ctx, userID, func(u *User, now time.Time) {
+		u.EmailVerified = true
+		if u.VerifiedAt == nil {
+			u.VerifiedAt = &now
+		}
+	})
+}
+
+// SetRole implements Store
+func (s *MemoryStore) SetRole(ctx context.Context, userID uuid.UUID, role string) error {
+	return s.update(ctx, userID, func(u *User, now time.Time) {
+		u.Role = role
+	})
+}
+
+// SoftDelete deactivates the user and sets deleted_at, like
+// UserRepository.SoftDelete. The email and username stay taken.
+func (s *MemoryStore) SoftDelete(ctx context.Context, userID uuid.UUID) error {
+	return s.update(ctx, userID, func(u *User, now time.Time) {
+		u.IsActive = false
+		u.DeletedAt = &now
+	})
+}
+
+// Restore undoes SoftDelete
+func (s *MemoryStore) Restore(ctx context.Context, userID uuid.UUID) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok || u.TenantID != tenantID || u.DeletedAt == nil {
+		return ErrUserNotFound
+	}
+	u.IsActive = true
+	u.DeletedAt = nil
+	u.UpdatedAt = s.now()
+	return nil
+}
+
+// update applies fn to a non-deleted user of the current tenant
+func (s *MemoryStore) update(ctx context.Context, userID uuid.UUID, fn func(u *User, now time.Time)) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.live(tenantID, userID)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	fn(u, now)
+	u.UpdatedAt = now
+	return nil
+}
+
+// live returns the stored non-deleted user. Callers hold the lock.
+func (s *MemoryStore) live(tenantID, userID uuid.UUID) (*User, error) {
+	u, ok := s.users[userID]
+	if !ok || u.TenantID != tenantID || u.DeletedAt != nil {
+		return nil, ErrUserNotFound
+	}
+	return u, nil
+}
+
+// taken reports whether another user of the tenant, deleted or not, holds
+// email or username. Callers hold the lock.
+func (s *MemoryStore) taken(tenantID, except uuid.UUID, email, username string) bool {
+	for _, u := range s.users {
+		if u.TenantID != tenantID || u.ID == except {
+			continue
+		}
+		if (email != "" && u.Email == email) || (username != "" && u.Username == username) {
+			return true
+		}
+	}
+	return false
+}
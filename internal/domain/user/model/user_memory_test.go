@@ -0,0 +1,188 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_UniquePerTenant(t *testing.T) {
+	store := NewMemoryStore()
+	ctxA := tenant.WithID(context.Background(), uuid.New())
+	ctxB := tenant.WithID(context.Background(), uuid.New())
+
+	_, err := store.SaveUser(ctxA, &User{Email: "a@example.com", Username: "alice"})
+	require.NoError(t, err)
+
+	_, err = store.SaveUser(ctxA, &User{Email: "a@example.com", Username: "other"})
+	assert.ErrorIs(t, err, ErrUserExists)
+	_, err = store.SaveUser(ctxA, &User{Email: "other@example.com", Username: "alice"})
+	assert.ErrorIs(t, err, ErrUserExists)
+
+	_, err = store.SaveUser(ctxB, &User{Email: "a@example.com", Username: "alice"})
+	assert.NoError(t, err, "another tenant may reuse the email")
+
+	_, err = store.FindByEmail(ctxB, "missing@example.com", ActiveOnly)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestMemoryStore_SoftDeleteKeepsEmailTaken(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := tenant.WithID(context.Background(), uuid.New())
+
+	u, err := store.SaveUser(ctx, &User{Email: "a@example.com", Username: "alice"})
+	require.NoError(t, err)
+	require.NoError(t, store.SoftDelete(ctx, u.ID))
+
+	_, err = store.FindByID(ctx, u.ID, ActiveOnly)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = store.FindByEmail(ctx, "a@example.com", ActiveOnly)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	_, err = store.SaveUser(ctx, &User{Email: "a@example.com", Username: "alice2"})
+	assert.ErrorIs(t, err, ErrUserExists)
+}
+
+func TestMemoryStore_Scopes(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := tenant.WithID(context.Background(), uuid.New())
+
+	live, err := store.SaveUser(ctx, &User{Email: "live@example.com", Username: "live", IsActive: true})
+	require.NoError(t, err)
+	deleted, err := store.SaveUser(ctx, &User{Email: "deleted@example.com", Username: "deleted", IsActive: true})
+	require.NoError(t, err)
+	require.NoError(t, store.SoftDelete(ctx, deleted.ID))
+
+	tests := []struct {
+		scope       Scope
+		live, found bool
+	}{
+		{scope: ActiveOnly, live: true, found: false},
+		{scope: IncludeDeleted, live: true, found: true},
+		{scope: DeletedOnly, live: false, found: true},
+	}
+	for _, tt := range tests {
+		_, err := store.FindByID(ctx, live.ID, tt.scope)
+		assert.Equal(t, tt.live, err == nil, "scope %d, live user", tt.scope)
+		_, err = store.FindByEmail(ctx, "live@example.com", tt.scope)
+		assert.Equal(t, tt.live, err == nil, "scope %d, live user by email", tt.scope)
+
+		user, err := store.FindByID(ctx, deleted.ID, tt.scope)
+		assert.Equal(t, tt.found, err == nil, "scope %d, deleted user", tt.scope)
+		if err == nil {
+			assert.False(t, user.IsActive)
+			assert.NotNil(t, user.DeletedAt)
+		}
+	}
+}
+
+func TestMemoryStore_Restore(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := tenant.WithID(context.Background(), uuid.New())
+
+	u, err := store.SaveUser(ctx, &User{Email: "a@example.com", Username: "alice", IsActive: true})
+	require.NoError(t, err)
+	assert.ErrorIs(t, store.Restore(ctx, u.ID), ErrUserNotFound, "only deleted users can be restored")
+
+	require.NoError(t, store.SoftDelete(ctx, u.ID))
+	assert.ErrorIs(t, store.SoftDelete(ctx, u.ID), ErrUserNotFound, "already deleted")
+	require.NoError(t, store.Restore(ctx, u.ID))
+
+	restored, err := store.FindByEmail(ctx, "a@example.com", ActiveOnly)
+	require.NoError(t, err)
+	assert.True(t, restored.IsActive)
+	assert.Nil(t, restored.DeletedAt)
+
+	other := tenant.WithID(context.Background(), uuid.New())
+	require.NoError(t, store.SoftDelete(ctx, u.ID))
+	assert.ErrorIs(t, store.Restore(other, u.ID), ErrUserNotFound)
+}
+
+func TestMemoryStore_RequiresTenant(t *testing.T) {
+	_, err := NewMemoryStore().SaveUser(context.Background(), &User{Email: "a@example.com"})
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+}
+
+func TestMemoryStore_ConcurrentSaveAllowsOneWinner(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := tenant.WithID(context.Background(), uuid.New())
+
+	var wg sync.WaitGroup
+	var saved atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.SaveUser(ctx, &User{Email: "race@example.com", Username: uuid.NewString()}); err == nil {
+				saved.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), saved.Load())
+}
+
+func TestMemoryStore_PasswordChanges(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := tenant.WithID(context.Background(), uuid.New())
+
+	saved, err := store.SaveUser(ctx, &User{Email: "a@example.com", Username: "alice", Password: "old"})
+	require.NoError(t, err)
+	require.NotNil(t, saved.PasswordChangedAt)
+	changedAt := *saved.PasswordChangedAt
+
+	// A rehash swaps the hash but keeps when the password was chosen
+	require.NoError(t, store.RehashPassword(ctx, saved.ID, "old", "stronger"))
+	user, err := store.FindByID(ctx, saved.ID, ActiveOnly)
+	require.NoError(t, err)
+	assert.Equal(t, "stronger", user.Password)
+	assert.Equal(t, changedAt, *user.PasswordChangedAt)
+	assert.ErrorIs(t, store.RehashPassword(ctx, saved.ID, "old", "again"), ErrUserNotFound, "the hash changed in the meantime")
+
+	require.NoError(t, store.SetPassword(ctx, saved.ID, "new"))
+	user, err = store.FindByID(ctx, saved.ID, ActiveOnly)
+	require.NoError(t, err)
+	assert.Equal(t, "new", user.Password)
+	assert.False(t, user.PasswordChangedAt.Before(changedAt))
+}
+
+func TestMemoryStore_SetPasswordClearsRequiredReset(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := tenant.WithID(context.Background(), uuid.New())
+
+	saved, err := store.SaveUser(ctx, &User{Email: "a@example.com", Username: "alice", Password: "leaked", MustResetPassword: true})
+	require.NoError(t, err)
+	require.True(t, saved.MustResetPassword)
+
+	require.NoError(t, store.SetPassword(ctx, saved.ID, "new"))
+	user, err := store.FindByID(ctx, saved.ID, ActiveOnly)
+	require.NoError(t, err)
+	assert.False(t, user.MustResetPassword)
+}
+
+func TestMemoryStore_StoresUTC(t *testing.T) {
+	ctx, _ := tenantCtx()
+	frozen := clock.NewFrozen(time.Date(2026, 3, 1, 9, 30, 0, 0, time.FixedZone("ICT", 7*60*60)))
+	store := NewMemoryStore().WithClock(frozen.Now)
+
+	saved, err := store.SaveUser(ctx, &User{Email: "john@example.com", Username: "john"})
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, saved.CreatedAt.Location())
+	assert.Equal(t, frozen.Now(), saved.CreatedAt)
+
+	frozen.Advance(time.Hour)
+	require.NoError(t, store.MarkEmailVerified(ctx, saved.ID))
+	found, err := store.FindByID(ctx, saved.ID, ActiveOnly)
+	require.NoError(t, err)
+	assert.Equal(t, frozen.Now(), found.UpdatedAt)
+}
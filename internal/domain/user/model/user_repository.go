@@ -0,0 +1,387 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"dvith.com/go-service-api/internal/errs"
+	"dvith.com/go-service-api/internal/pagination"
+	"dvith.com/go-service-api/internal/queries"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/crypto"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrUserNotFound is returned when no matching user exists in scope
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned when the email or username is already taken
+	ErrUserExists = errors.New("email or username is already registered")
+)
+
+func init() {
+	errs.Register(errs.Translation{
+		Name:   "user_not_found",
+		Match:  func(err error) bool { return errors.Is(err, ErrUserNotFound) },
+		Status: http.StatusNotFound,
+		Code:   "not_found",
+	})
+	errs.Register(errs.Translation{
+		Name:   "user_exists",
+		Match:  func(err error) bool { return errors.Is(err, ErrUserExists) },
+		Status: http.StatusConflict,
+		Code:   "conflict",
+	})
+}
+
+// ProfileUpdate holds the profile fields to change; nil fields are left as is
+type ProfileUpdate struct {
+	FullName *string
+	Username *string
+}
+
+// UserRepository reads and writes users in the current tenant. It runs on
+// any Querier, so callers can bind it to a transaction to combine user
+// writes with audit or outbox records.
+type UserRepository struct {
+	q   database.Querier
+	now func() time.Time
+	// trigram caches whether pg_trgm is installed; nil until Search asks
+	trigram atomic.Pointer[bool]
+}
+
+// NewUserRepository creates a user repository on q
+func NewUserRepository(q database.Querier) *UserRepository {
+	return &UserRepository{
+		q:   q,
+		now: clock.Now,
+	}
+}
+
+// WithClock replaces the time source for timestamps, for tests
+func (repo *UserRepository) WithClock(now func() time.Time) *UserRepository {
+	repo.now = now
+	return repo
+}
+
+// SaveUser inserts a new user into the current tenant
+func (repo *UserRepository) SaveUser(ctx context.Context, user *User) (*User, error) {
+	if user == nil {
+		return nil, fmt.Errorf("user cannot be nil")
+	}
+
+	// Users always belong to the tenant resolved for the request
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user.TenantID = tenantID
+
+	// Generate new UUID if not provided
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+
+	// Set timestamps
+	now := repo.now()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	if user.Password != "" {
+		user.PasswordChangedAt = &now
+	}
+
+	// Usernames others renamed away from stay reserved for them
+	if user.Username != "" {
+		var reserved bool
+		if err := repo.q.QueryRow(ctx, queries.UsernameReserved.SQL, tenantID, user.Username, user.ID, now.UTC()).Scan(&reserved); err != nil {
+			return nil, fmt.Errorf("failed to check username reservation: %w", err)
+		}
+		if reserved {
+			return nil, ErrUserExists
+		}
+	}
+
+	saved, err := database.QueryOne[User](
+		ctx,
+		repo.q,
+		queries.UserInsert.SQL,
+		user.ID,
+		user.TenantID,
+		user.Email,
+		user.Password,
+		user.FullName,
+		user.Username,
+		user.IsActive,
+		user.EmailVerified,
+		user.VerifiedAt,
+		user.CreatedAt,
+		user.UpdatedAt,
+		user.PasswordChangedAt,
+	)
+	if err != nil {
+		if errs.HasSQLState(err, errs.SQLStateUniqueViolation) {
+			return nil, ErrUserExists
+		}
+		return nil, fmt.Errorf("failed to save user: %w", err)
+	}
+
+	return saved, nil
+}
+
+// FindByEmail returns the user in scope with the given email
+func (repo *UserRepository) FindByEmail(ctx context.Context, email string, scope Scope) (*User, error) {
+	if email == "" {
+		return nil, fmt.Errorf("email cannot be empty")
+	}
+
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return findOne(database.QueryOne[User](ctx, repo.q, queries.UserFindByEmail.SQL+scope.And(), tenantID, email))
+}
+
+// Availability says which of an email and a username are already taken
+type Availability struct {
+	EmailTaken    bool `db:"email_taken"`
+	UsernameTaken bool `db:"username_taken"`
+}
+
+// Any reports whether the email or the username is taken
+func (a Availability) Any() bool {
+	return a.EmailTaken || a.UsernameTaken
+}
+
+// Availability reports whether a user, deleted or not, already holds email
+// and whether one holds username, or it is reserved for a user who renamed
+// away from it
+func (repo *UserRepository) Availability(ctx context.Context, email, username string) (Availability, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return Availability{}, err
+	}
+
+	a, err := database.QueryOne[Availability](ctx, repo.q, queries.UserTaken.SQL, tenantID, email, username, repo.now().UTC())
+	if err != nil {
+		return Availability{}, fmt.Errorf("failed to check email and username: %w", err)
+	}
+	return *a, nil
+}
+
+// FindByID returns the user in scope with the given ID
+func (repo *UserRepository) FindByID(ctx context.Context, userID uuid.UUID, scope Scope) (*User, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return findOne(database.QueryOne[User](ctx, repo.q, queries.UserFindByID.SQL+scope.And(), tenantID, userID))
+}
+
+// UpdateProfile changes the non-nil fields of update and returns the updated user
+func (repo *UserRepository) UpdateProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate) (*User, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A nil name stays NULL so COALESCE keeps the stored one
+	var fullName any
+	if update.FullName != nil {
+		fullName = crypto.EncryptedString(*update.FullName)
+	}
+
+	user, err := database.QueryOne[User](ctx, repo.q, queries.UserUpdateProfile.SQL, tenantID, userID, fullName, update.Username, repo.now())
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		if errs.HasSQLState(err, errs.SQLStateUniqueViolation) {
+			return nil, ErrUserExists
+		}
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	return user, nil
+}
+
+// SetPassword replaces the user's password hash. Every outstanding password
+// reset token of the user is invalidated in the same statement, so a
+// password change through any path kills pending reset links.
+func (repo *UserRepository) SetPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	if passwordHash == "" {
+		return fmt.Errorf("password hash cannot be empty")
+	}
+
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := repo.q.Exec(ctx, queries.UserSetPassword.SQL, tenantID, userID, passwordHash, repo.now())
+	if err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// RehashPassword replaces oldHash with newHash, a stronger hash of the same
+// password, leaving password_changed_at as is. It returns ErrUserNotFound
+// when the hash changed in the meantime.
+func (repo *UserRepository) RehashPassword(ctx context.Context, userID uuid.UUID, oldHash, newHash string) error {
+	if newHash == "" {
+		return fmt.Errorf("password hash cannot be empty")
+	}
+
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := repo.q.Exec(ctx, queries.UserRehashPassword.SQL, tenantID, userID, oldHash, newHash, repo.now())
+	if err != nil {
+		return fmt.Errorf("failed to rehash password: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// MarkEmailVerified records that the user's email address is verified. The
+// first verification time is kept.
+func (repo *UserRepository) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := repo.q.Exec(ctx, queries.UserMarkEmailVerified.SQL, tenantID, userID, repo.now())
+	if err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetRole changes the user's role
+func (repo *UserRepository) SetRole(ctx context.Context, userID uuid.UUID, role string) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := repo.q.Exec(ctx, queries.UserSetRole.SQL, tenantID, userID, role, repo.now())
+	if err != nil {
+		return fmt.Errorf("failed to set role: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SoftDelete marks the user deleted and inactive. The email and username
+// stay taken until the user is purged.
+func (repo *UserRepository) SoftDelete(ctx context.Context, userID uuid.UUID) error {
+	return repo.setDeleted(ctx, queries.UserSoftDelete, userID, "delete")
+}
+
+// Restore undoes SoftDelete, unless the user has been purged since
+func (repo *UserRepository) Restore(ctx context.Context, userID uuid.UUID) error {
+	return repo.setDeleted(ctx, queries.UserRestore, userID, "restore")
+}
+
+// setDeleted runs the SoftDelete or Restore query, which match no row when
+// the user is missing or already in the state asked for
+func (repo *UserRepository) setDeleted(ctx context.Context, query queries.Query, userID uuid.UUID, action string) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := repo.q.Exec(ctx, query.SQL, tenantID, userID, repo.now())
+	if err != nil {
+		return fmt.Errorf("failed to %s user: %w", action, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// findOne maps a missing user row to ErrUserNotFound
+func findOne(user *User, err error) (*User, error) {
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	return user, nil
+}
+
+// ListOptions is what the admin users listing accepts for sorting and filtering
+var ListOptions = pagination.Options{
+	Sorts: map[string]pagination.Field{
+		"created_at": {Column: "created_at", Kind: pagination.KindTime},
+		"email":      {Column: "email", Kind: pagination.KindString},
+		"username":   {Column: "username", Kind: pagination.KindString},
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: pagination.Desc,
+	Filters: map[string]pagination.Field{
+		"role":           {Column: "role", Kind: pagination.KindString},
+		"is_active":      {Column: "is_active", Kind: pagination.KindBool},
+		"email_verified": {Column: "email_verified", Kind: pagination.KindBool},
+	},
+}
+
+// List returns a page of the tenant's users in scope built from
+// ListOptions, plus the number of users matching the filters. Rows include
+// the extra row fetched for next-page detection; pass them to
+// pagination.Trim.
+func (repo *UserRepository) List(ctx context.Context, req pagination.PageRequest, scope Scope) ([]*User, int64, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	filters := req.FilterClauses(2)
+	var total int64
+	countQuery := queries.UserCount.SQL + scope.And() + filters.And()
+	if err := repo.q.QueryRow(ctx, countQuery, append([]any{tenantID}, filters.Args...)...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	page := req.Clauses(2)
+	query := queries.UserList.SQL + scope.And() + page.And() + " " + page.OrderBy + " " + page.Limit
+
+	rows, err := database.QueryAll[User](ctx, repo.q, query, append([]any{tenantID}, page.Args...)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	users := make([]*User, len(rows))
+	for i := range rows {
+		users[i] = &rows[i]
+	}
+	return users, total, nil
+}
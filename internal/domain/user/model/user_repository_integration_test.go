@@ -0,0 +1,288 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/pagination"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/internal/testdb"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// defaultTenantID is the tenant seeded by the tenants migration
+var defaultTenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// createTenant adds a tenant to db and returns its ID
+func createTenant(t *testing.T, db *database.DBPool, slug string) uuid.UUID {
+	t.Helper()
+	var id uuid.UUID
+	require.NoError(t, db.QueryRow(context.Background(),
+		"INSERT INTO tenants (slug, name) VALUES ($1, $1) RETURNING id", slug).Scan(&id))
+	return id
+}
+
+func TestUserRepository_SaveAndFind(t *testing.T) {
+	db := testdb.Open(t)
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	repo := NewUserRepository(db).WithClock(clock.NewFrozen(now).Now)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+
+	saved, err := repo.SaveUser(ctx, &User{
+		Email:    "alice@example.com",
+		Password: "hash",
+		FullName: "Alice Liddell",
+		Username: "alice",
+		IsActive: true,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, saved.ID)
+	assert.Equal(t, defaultTenantID, saved.TenantID)
+	assert.Equal(t, "user", saved.Role, "role defaults in the schema")
+	assert.True(t, now.Equal(saved.CreatedAt), saved.CreatedAt)
+	require.NotNil(t, saved.PasswordChangedAt)
+	assert.True(t, now.Equal(*saved.PasswordChangedAt), *saved.PasswordChangedAt)
+
+	byEmail, err := repo.FindByEmail(ctx, "alice@example.com", ActiveOnly)
+	require.NoError(t, err)
+	assert.Equal(t, saved, byEmail)
+
+	byID, err := repo.FindByID(ctx, saved.ID, ActiveOnly)
+	require.NoError(t, err)
+	assert.Equal(t, saved, byID)
+	assert.Equal(t, "Alice Liddell", string(byID.FullName))
+}
+
+func TestUserRepository_FindMissing(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewUserRepository(db)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+
+	_, err := repo.FindByEmail(ctx, "missing@example.com", ActiveOnly)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = repo.FindByID(ctx, uuid.New(), ActiveOnly)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	deleted, err := repo.SaveUser(ctx, &User{Email: "deleted@example.com", Username: "deleted", IsActive: true})
+	require.NoError(t, err)
+	require.NoError(t, repo.SoftDelete(ctx, deleted.ID))
+	_, err = repo.FindByEmail(ctx, "deleted@example.com", ActiveOnly)
+	assert.ErrorIs(t, err, ErrUserNotFound, "signin lookups skip deleted users")
+
+	_, err = repo.FindByEmail(context.Background(), "alice@example.com", ActiveOnly)
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+}
+
+func TestUserRepository_SaveUser_UniqueViolations(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewUserRepository(db)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+
+	_, err := repo.SaveUser(ctx, &User{Email: "alice@example.com", Username: "alice", IsActive: true})
+	require.NoError(t, err)
+
+	_, err = repo.SaveUser(ctx, &User{Email: "alice@example.com", Username: "other", IsActive: true})
+	assert.ErrorIs(t, err, ErrUserExists, "email is taken")
+	_, err = repo.SaveUser(ctx, &User{Email: "other@example.com", Username: "alice", IsActive: true})
+	assert.ErrorIs(t, err, ErrUserExists, "username is taken")
+
+	otherTenant := tenant.WithID(context.Background(), createTenant(t, db, "other"))
+	_, err = repo.SaveUser(otherTenant, &User{Email: "alice@example.com", Username: "alice", IsActive: true})
+	assert.NoError(t, err, "another tenant may reuse both")
+
+	var count int
+	require.NoError(t, db.QueryRow(context.Background(), "SELECT count(*) FROM users").Scan(&count))
+	assert.Equal(t, 2, count)
+}
+
+func TestUserRepository_UpdateProfile_UsernameTaken(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewUserRepository(db)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+
+	_, err := repo.SaveUser(ctx, &User{Email: "alice@example.com", Username: "alice", IsActive: true})
+	require.NoError(t, err)
+	bob, err := repo.SaveUser(ctx, &User{Email: "bob@example.com", Username: "bob", IsActive: true})
+	require.NoError(t, err)
+
+	taken := "alice"
+	_, err = repo.UpdateProfile(ctx, bob.ID, ProfileUpdate{Username: &taken})
+	assert.ErrorIs(t, err, ErrUserExists)
+
+	_, err = repo.UpdateProfile(ctx, uuid.New(), ProfileUpdate{Username: &taken})
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+// firstPage is the users listing's first page with default options
+func firstPage(t *testing.T) pagination.PageRequest {
+	t.Helper()
+	var req pagination.PageRequest
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) (err error) {
+		req, err = pagination.ParsePageRequest(c, ListOptions)
+		return err
+	})
+	_, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	return req
+}
+
+func TestUserRepository_Scopes(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewUserRepository(db)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+
+	live, err := repo.SaveUser(ctx, &User{Email: "live@example.com", Username: "live", IsActive: true})
+	require.NoError(t, err)
+	deleted, err := repo.SaveUser(ctx, &User{Email: "deleted@example.com", Username: "deleted", IsActive: true})
+	require.NoError(t, err)
+	require.NoError(t, repo.SoftDelete(ctx, deleted.ID))
+
+	tests := []struct {
+		name          string
+		scope         Scope
+		live, deleted bool
+	}{
+		{name: "active only", scope: ActiveOnly, live: true},
+		{name: "include deleted", scope: IncludeDeleted, live: true, deleted: true},
+		{name: "deleted only", scope: DeletedOnly, deleted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.FindByID(ctx, live.ID, tt.scope)
+			assert.Equal(t, tt.live, err == nil, "live user by ID: %v", err)
+			_, err = repo.FindByEmail(ctx, "live@example.com", tt.scope)
+			assert.Equal(t, tt.live, err == nil, "live user by email: %v", err)
+			_, err = repo.FindByID(ctx, deleted.ID, tt.scope)
+			assert.Equal(t, tt.deleted, err == nil, "deleted user by ID: %v", err)
+			_, err = repo.FindByEmail(ctx, "deleted@example.com", tt.scope)
+			assert.Equal(t, tt.deleted, err == nil, "deleted user by email: %v", err)
+
+			rows, total, err := repo.List(ctx, firstPage(t), tt.scope)
+			require.NoError(t, err)
+			var ids []uuid.UUID
+			for _, u := range rows {
+				ids = append(ids, u.ID)
+			}
+			assert.Equal(t, int64(len(ids)), total)
+			assert.Equal(t, tt.live, slices.Contains(ids, live.ID))
+			assert.Equal(t, tt.deleted, slices.Contains(ids, deleted.ID))
+		})
+	}
+}
+
+func TestUserRepository_SoftDeleteAndRestore(t *testing.T) {
+	db := testdb.Open(t)
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	repo := NewUserRepository(db).WithClock(clock.NewFrozen(now).Now)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+
+	u, err := repo.SaveUser(ctx, &User{Email: "alice@example.com", Username: "alice", IsActive: true})
+	require.NoError(t, err)
+	assert.ErrorIs(t, repo.Restore(ctx, u.ID), ErrUserNotFound, "only deleted users can be restored")
+
+	require.NoError(t, repo.SoftDelete(ctx, u.ID))
+	assert.ErrorIs(t, repo.SoftDelete(ctx, u.ID), ErrUserNotFound, "already deleted")
+	deleted, err := repo.FindByID(ctx, u.ID, DeletedOnly)
+	require.NoError(t, err)
+	assert.False(t, deleted.IsActive)
+	require.NotNil(t, deleted.DeletedAt)
+	assert.True(t, now.Equal(*deleted.DeletedAt), *deleted.DeletedAt)
+
+	otherTenant := tenant.WithID(context.Background(), createTenant(t, db, "other"))
+	assert.ErrorIs(t, repo.Restore(otherTenant, u.ID), ErrUserNotFound)
+
+	require.NoError(t, repo.Restore(ctx, u.ID))
+	restored, err := repo.FindByEmail(ctx, "alice@example.com", ActiveOnly)
+	require.NoError(t, err)
+	assert.True(t, restored.IsActive)
+	assert.Nil(t, restored.DeletedAt)
+}
+
+func TestUserRepository_RestorePurged(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewUserRepository(db)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+
+	u, err := repo.SaveUser(ctx, &User{Email: "alice@example.com", Username: "alice", IsActive: true})
+	require.NoError(t, err)
+	require.NoError(t, repo.SoftDelete(ctx, u.ID))
+	_, err = db.Exec(context.Background(), "UPDATE users SET purged_at = now() WHERE id = $1", u.ID)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, repo.Restore(ctx, u.ID), ErrUserNotFound, "purged users stay deleted")
+	_, err = repo.FindByID(ctx, u.ID, DeletedOnly)
+	assert.NoError(t, err)
+}
+
+func TestUserRepository_Search(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := tenant.WithID(context.Background(), defaultTenantID)
+	seed := NewUserRepository(db)
+	for _, u := range []struct{ email, username string }{
+		{"100%@example.com", "percent"},
+		{"1000@example.com", "thousand"},
+		{"jo@example.com", "jo"},
+		{"john@example.com", "johnny"},
+		{"majo@example.com", "ma_jo"},
+		{"maxjo@example.com", "maxjo"},
+		{"alice@example.com", "alice"},
+	} {
+		_, err := seed.SaveUser(ctx, &User{Email: u.email, Username: u.username, IsActive: true})
+		require.NoError(t, err)
+	}
+
+	emails := func(matches []*Match) []string {
+		var out []string
+		for _, m := range matches {
+			out = append(out, m.Email)
+		}
+		return out
+	}
+
+	for _, trigram := range []bool{true, false} {
+		repo := NewUserRepository(db)
+		if !trigram {
+			repo.trigram.Store(&trigram)
+		}
+		t.Run(fmt.Sprintf("trigram %v", trigram), func(t *testing.T) {
+			matches, total, err := repo.Search(ctx, "100%", searchPage(t, ""), ActiveOnly)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), total, "the percent sign matches only itself")
+			assert.Equal(t, []string{"100%@example.com"}, emails(matches))
+
+			matches, _, err = repo.Search(ctx, "A_J", searchPage(t, ""), ActiveOnly)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"majo@example.com"}, emails(matches), "_ matches only itself, ignoring case")
+
+			// Every page of the ranked results, prefix matches first
+			var seen []string
+			req := searchPage(t, "limit=2")
+			for {
+				page, total, err := repo.Search(ctx, "jo", req, ActiveOnly)
+				require.NoError(t, err)
+				assert.Equal(t, int64(4), total)
+				page, more := pagination.Trim(req, page)
+				seen = append(seen, emails(page)...)
+				if !more {
+					break
+				}
+				last := page[len(page)-1]
+				req = searchPage(t, "limit=2&cursor="+req.NextCursor(last.Rank, last.ID))
+			}
+			require.Len(t, seen, 4)
+			assert.ElementsMatch(t, []string{"jo@example.com", "john@example.com"}, seen[:2])
+			assert.ElementsMatch(t, []string{"majo@example.com", "maxjo@example.com"}, seen[2:])
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/errs"
+	"dvith.com/go-service-api/internal/queries"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/crypto"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRows returns one row of fixed values under the given column names
+type fakeRows struct {
+	columns []string
+	values  []any
+	err     error
+	read    bool
+}
+
+func (r *fakeRows) Close()                        {}
+func (r *fakeRows) Err() error                    { return r.err }
+func (r *fakeRows) CommandTag() pgconn.CommandTag { return pgconn.CommandTag{} }
+func (r *fakeRows) Values() ([]any, error)        { return r.values, nil }
+func (r *fakeRows) RawValues() [][]byte           { return nil }
+func (r *fakeRows) Conn() *pgx.Conn               { return nil }
+
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription {
+	fields := make([]pgconn.FieldDescription, len(r.columns))
+	for i, name := range r.columns {
+		fields[i].Name = name
+	}
+	return fields
+}
+
+func (r *fakeRows) Next() bool {
+	if r.err != nil || r.read || r.values == nil {
+		return false
+	}
+	r.read = true
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	if len(dest) != len(r.values) {
+		return errors.New("scan: column count mismatch")
+	}
+	for i, d := range dest {
+		if r.values[i] == nil {
+			continue
+		}
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.values[i]))
+	}
+	return nil
+}
+
+// fakeQuerier records statements and returns canned results: those in
+// before for the first queries, then rows
+type fakeQuerier struct {
+	sql    string
+	args   []any
+	before []fakeRows
+	rows   fakeRows
+	tag    pgconn.CommandTag
+	err    error
+}
+
+func (q *fakeQuerier) next() *fakeRows {
+	rows := q.rows
+	if len(q.before) > 0 {
+		rows, q.before = q.before[0], q.before[1:]
+	}
+	return &rows
+}
+
+func (q *fakeQuerier) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	q.sql, q.args = sql, args
+	return q.next(), nil
+}
+
+func (q *fakeQuerier) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	q.sql, q.args = sql, args
+	return q.next()
+}
+
+func (q *fakeQuerier) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	q.sql, q.args = sql, args
+	return q.tag, q.err
+}
+
+// userColumns are the users columns every query returning users selects
+var userColumns = []string{
+	"id", "tenant_id", "email", "password", "full_name", "username", "role", "is_active",
+	"email_verified", "verified_at", "created_at", "updated_at", "deleted_at", "password_changed_at", "must_reset_password",
+	"token_version",
+}
+
+// userRow returns u under userColumns, in a different order than the
+// struct fields to prove columns are matched by name
+func userRow(u User) fakeRows {
+	columns := append([]string{}, userColumns...)
+	values := []any{
+		u.ID, u.TenantID, u.Email, u.Password, u.FullName, u.Username, u.Role,
+		u.IsActive, u.EmailVerified, nil, u.CreatedAt, u.UpdatedAt, nil, u.PasswordChangedAt, u.MustResetPassword,
+		u.TokenVersion,
+	}
+	// Swap email and full_name, the classic positional scan bug
+	columns[2], columns[4] = columns[4], columns[2]
+	values[2], values[4] = values[4], values[2]
+	return fakeRows{columns: columns, values: values}
+}
+
+// reservedRow answers the UsernameReserved check
+func reservedRow(reserved bool) fakeRows {
+	return fakeRows{columns: []string{"exists"}, values: []any{reserved}}
+}
+
+func tenantCtx() (context.Context, uuid.UUID) {
+	id := uuid.New()
+	return tenant.WithID(context.Background(), id), id
+}
+
+func TestUserRepository_RequiresTenant(t *testing.T) {
+	repo := NewUserRepository(&fakeQuerier{})
+	ctx := context.Background()
+
+	_, err := repo.SaveUser(ctx, &User{Email: "a@example.com"})
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+	_, err = repo.FindByEmail(ctx, "a@example.com", ActiveOnly)
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+	_, err = repo.FindByID(ctx, uuid.New(), ActiveOnly)
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+	_, err = repo.UpdateProfile(ctx, uuid.New(), ProfileUpdate{})
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+	assert.ErrorIs(t, repo.SetPassword(ctx, uuid.New(), "hash"), tenant.ErrNoTenant)
+	assert.ErrorIs(t, repo.RehashPassword(ctx, uuid.New(), "old", "hash"), tenant.ErrNoTenant)
+}
+
+func TestUserRepository_SaveUser(t *testing.T) {
+	ctx, tenantID := tenantCtx()
+	stored := User{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		Email:     "john@example.com",
+		Username:  "john_doe",
+		Role:      "user",
+		IsActive:  true,
+		CreatedAt: time.Now(),
+	}
+	q := &fakeQuerier{before: []fakeRows{reservedRow(false)}, rows: userRow(stored)}
+
+	saved, err := NewUserRepository(q).SaveUser(ctx, &User{Email: "john@example.com", Username: "john_doe", IsActive: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, stored.ID, saved.ID)
+	assert.Equal(t, "user", saved.Role, "role comes from the RETURNING clause")
+	assert.Equal(t, queries.UserInsert.SQL, q.sql)
+	assert.Equal(t, tenantID, q.args[1], "insert is scoped to the request tenant")
+
+	_, err = NewUserRepository(q).SaveUser(ctx, &User{Email: "jane@example.com", Password: "hash"})
+	require.NoError(t, err)
+	assert.NotNil(t, q.args[11], "a new password records when it was set")
+}
+
+func TestUserRepository_SaveUser_Duplicate(t *testing.T) {
+	ctx, _ := tenantCtx()
+	q := &fakeQuerier{rows: fakeRows{err: &pgconn.PgError{Code: errs.SQLStateUniqueViolation}}}
+
+	_, err := NewUserRepository(q).SaveUser(ctx, &User{Email: "john@example.com"})
+	assert.ErrorIs(t, err, ErrUserExists)
+}
+
+func TestUserRepository_SaveUser_ReservedUsername(t *testing.T) {
+	ctx, tenantID := tenantCtx()
+	q := &fakeQuerier{before: []fakeRows{reservedRow(true)}, rows: userRow(User{ID: uuid.New(), TenantID: tenantID})}
+
+	_, err := NewUserRepository(q).SaveUser(ctx, &User{Email: "john@example.com", Username: "alice"})
+	assert.ErrorIs(t, err, ErrUserExists)
+	assert.Equal(t, queries.UsernameReserved.SQL, q.sql, "nothing is inserted")
+	assert.Equal(t, "alice", q.args[1])
+}
+
+func TestUserRepository_FindByEmail(t *testing.T) {
+	ctx, tenantID := tenantCtx()
+	q := &fakeQuerier{rows: userRow(User{ID: uuid.New(), TenantID: tenantID, Email: "john@example.com"})}
+
+	user, err := NewUserRepository(q).FindByEmail(ctx, "john@example.com", ActiveOnly)
+	require.NoError(t, err)
+	assert.Equal(t, "john@example.com", user.Email)
+	assert.Contains(t, q.sql, "tenant_id = $1")
+	assert.Equal(t, []any{tenantID, "john@example.com"}, q.args)
+}
+
+func TestUserRepository_FindNotFound(t *testing.T) {
+	ctx, _ := tenantCtx()
+	q := &fakeQuerier{rows: fakeRows{columns: userColumns}}
+	repo := NewUserRepository(q)
+
+	_, err := repo.FindByEmail(ctx, "missing@example.com", ActiveOnly)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	_, err = repo.FindByID(ctx, uuid.New(), ActiveOnly)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	_, err = repo.UpdateProfile(ctx, uuid.New(), ProfileUpdate{})
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestUserRepository_ColumnDrift(t *testing.T) {
+	ctx, _ := tenantCtx()
+	rows := userRow(User{ID: uuid.New(), Email: "john@example.com"})
+
+	// A column the struct doesn't know
+	extra := rows
+	extra.columns = append(append([]string{}, rows.columns...), "nickname")
+	extra.values = append(append([]any{}, rows.values...), "johnny")
+	_, err := NewUserRepository(&fakeQuerier{rows: extra}).FindByID(ctx, uuid.New(), ActiveOnly)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUserNotFound)
+
+	// A struct field the query doesn't select
+	missing := rows
+	missing.columns, missing.values = rows.columns[1:], rows.values[1:]
+	_, err = NewUserRepository(&fakeQuerier{rows: missing}).FindByID(ctx, uuid.New(), ActiveOnly)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestUserRepository_FindByID_InfrastructureError(t *testing.T) {
+	ctx, _ := tenantCtx()
+	q := &fakeQuerier{rows: fakeRows{err: errors.New("conn closed")}}
+
+	_, err := NewUserRepository(q).FindByID(ctx, uuid.New(), ActiveOnly)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestUserRepository_UpdateProfile(t *testing.T) {
+	ctx, tenantID := tenantCtx()
+	id := uuid.New()
+	q := &fakeQuerier{rows: userRow(User{ID: id, TenantID: tenantID, FullName: "Jane Doe"})}
+
+	name := "Jane Doe"
+	user, err := NewUserRepository(q).UpdateProfile(ctx, id, ProfileUpdate{FullName: &name})
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe", user.FullName.String())
+	assert.True(t, strings.Contains(q.sql, "COALESCE($3, full_name)"))
+	assert.Equal(t, crypto.EncryptedString(name), q.args[2], "the new name is encrypted on the way in")
+	assert.Nil(t, q.args[3], "nil fields are passed through so COALESCE keeps the old value")
+}
+
+func TestUserRepository_SetPassword(t *testing.T) {
+	ctx, _ := tenantCtx()
+	repo := func(tag string) *UserRepository {
+		return NewUserRepository(&fakeQuerier{tag: pgconn.NewCommandTag(tag)})
+	}
+
+	assert.NoError(t, repo("UPDATE 1").SetPassword(ctx, uuid.New(), "hash"))
+	assert.ErrorIs(t, repo("UPDATE 0").SetPassword(ctx, uuid.New(), "hash"), ErrUserNotFound)
+	assert.Error(t, repo("UPDATE 1").SetPassword(ctx, uuid.New(), ""))
+
+	q := &fakeQuerier{tag: pgconn.NewCommandTag("UPDATE 1")}
+	require.NoError(t, NewUserRepository(q).SetPassword(ctx, uuid.New(), "hash"))
+	assert.Contains(t, q.sql, "DELETE FROM password_reset_tokens", "a password change must invalidate reset tokens")
+	assert.Contains(t, q.sql, "must_reset_password = false", "a password change satisfies a required reset")
+}
+
+func TestUserRepository_MarkEmailVerified(t *testing.T) {
+	ctx, _ := tenantCtx()
+
+	q := &fakeQuerier{tag: pgconn.NewCommandTag("UPDATE 1")}
+	require.NoError(t, NewUserRepository(q).MarkEmailVerified(ctx, uuid.New()))
+	assert.Contains(t, q.sql, "COALESCE(verified_at, $3)", "the first verification time is kept")
+
+	q = &fakeQuerier{tag: pgconn.NewCommandTag("UPDATE 0")}
+	assert.ErrorIs(t, NewUserRepository(q).MarkEmailVerified(ctx, uuid.New()), ErrUserNotFound)
+}
+
+func TestUserRepository_StoresUTC(t *testing.T) {
+	ctx, tenantID := tenantCtx()
+	bangkok := time.FixedZone("ICT", 7*60*60)
+	frozen := clock.NewFrozen(time.Date(2026, 3, 1, 9, 30, 0, 0, bangkok))
+	q := &fakeQuerier{rows: userRow(User{ID: uuid.New(), TenantID: tenantID})}
+	repo := NewUserRepository(q).WithClock(frozen.Now)
+
+	user := &User{Email: "john@example.com", Password: "hash"}
+	_, err := repo.SaveUser(ctx, user)
+	require.NoError(t, err)
+
+	want := time.Date(2026, 3, 1, 2, 30, 0, 0, time.UTC)
+	assert.Equal(t, want, user.CreatedAt, "timestamps come from the injected clock, in UTC")
+	assert.Equal(t, want, user.UpdatedAt)
+	assert.Equal(t, want, *user.PasswordChangedAt)
+
+	q.tag = pgconn.NewCommandTag("UPDATE 1")
+	frozen.Advance(time.Minute)
+	require.NoError(t, repo.SetPassword(ctx, uuid.New(), "hash"))
+	assert.Equal(t, want.Add(time.Minute), q.args[len(q.args)-1])
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sort"
+	"testing"
+
+	"dvith.com/go-service-api/internal/testdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) { testdb.Main(m) }
+
+// unmappedUserColumns are the users columns User deliberately leaves out
+var unmappedUserColumns = []string{"purged_at"}
+
+// dbTags returns the db tag of every field of v's struct type
+func dbTags(v any) []string {
+	var tags []string
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("db"); tag != "" && tag != "-" {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func TestUserSchema_MatchesStructTags(t *testing.T) {
+	db := testdb.Open(t)
+
+	rows, err := db.Query(context.Background(), `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'users'
+	`)
+	require.NoError(t, err)
+	var columns []string
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		if !slices.Contains(unmappedUserColumns, name) {
+			columns = append(columns, name)
+		}
+	}
+	require.NoError(t, rows.Err())
+	sort.Strings(columns)
+
+	assert.Equal(t, columns, dbTags(User{}), "users columns and User db tags diverged; map the new column or list it in unmappedUserColumns")
+}
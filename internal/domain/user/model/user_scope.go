@@ -0,0 +1,49 @@
+package model
+
+// Scope selects users by whether they are soft-deleted. SoftDelete and
+// Restore keep is_active in step with deleted_at, so a scope only needs to
+// look at deleted_at.
+type Scope int
+
+const (
+	// ActiveOnly selects users that aren't deleted
+	ActiveOnly Scope = iota
+	// IncludeDeleted selects every user
+	IncludeDeleted
+	// DeletedOnly selects soft-deleted users, awaiting purge or restore
+	DeletedOnly
+)
+
+// Condition is the scope's SQL condition on the users table, "" for
+// IncludeDeleted
+func (s Scope) Condition() string {
+	switch s {
+	case IncludeDeleted:
+		return ""
+	case DeletedOnly:
+		return "deleted_at IS NOT NULL"
+	default:
+		return "deleted_at IS NULL"
+	}
+}
+
+// And is Condition to append to a WHERE clause, such as
+// " AND deleted_at IS NOT NULL", or "" when there is none
+func (s Scope) And() string {
+	if c := s.Condition(); c != "" {
+		return " AND " + c
+	}
+	return ""
+}
+
+// Includes reports whether u is in the scope, as Condition does in SQL
+func (s Scope) Includes(u *User) bool {
+	switch s {
+	case IncludeDeleted:
+		return true
+	case DeletedOnly:
+		return u.DeletedAt != nil
+	default:
+		return u.DeletedAt == nil
+	}
+}
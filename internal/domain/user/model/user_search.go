@@ -0,0 +1,110 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"unicode/utf8"
+
+	"dvith.com/go-service-api/internal/pagination"
+	"dvith.com/go-service-api/internal/queries"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/database"
+)
+
+// MaxSearchLength is the longest term Search accepts, in characters
+const MaxSearchLength = 64
+
+// SortRelevance orders search results best match first: prefix matches,
+// then by trigram similarity where pg_trgm is installed
+const SortRelevance = "relevance"
+
+// ErrSearchTooLong is returned for terms longer than MaxSearchLength
+var ErrSearchTooLong = fmt.Errorf("search term must be at most %d characters", MaxSearchLength)
+
+// SearchOptions is ListOptions for searches, which can also be sorted by
+// relevance and are by default
+var SearchOptions = func() pagination.Options {
+	opts := ListOptions
+	opts.Sorts = maps.Clone(ListOptions.Sorts)
+	opts.Sorts[SortRelevance] = pagination.Field{Column: "search_rank", Kind: pagination.KindFloat}
+	opts.DefaultSort = SortRelevance
+	opts.DefaultOrder = pagination.Desc
+	return opts
+}()
+
+// Match is a user found by Search
+type Match struct {
+	User
+	// Rank is how well the user matched, for paging by relevance
+	Rank float64 `db:"search_rank" json:"-"`
+}
+
+// EscapeLike escapes the LIKE metacharacters in s, for patterns matched
+// with ESCAPE '\'
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Search returns a page of the tenant's users in scope whose email or
+// username contains q, ignoring case, plus the number of matches. It pages
+// like List with SearchOptions. Without pg_trgm the matches are still
+// found, but relevance only puts prefix matches first.
+func (repo *UserRepository) Search(ctx context.Context, q string, req pagination.PageRequest, scope Scope) ([]*Match, int64, error) {
+	if utf8.RuneCountInString(q) > MaxSearchLength {
+		return nil, 0, ErrSearchTooLong
+	}
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	ranked, err := repo.trigramAvailable(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	escaped := EscapeLike(q)
+	args := []any{tenantID, "%" + escaped + "%"}
+
+	filters := req.FilterClauses(len(args) + 1)
+	var total int64
+	countQuery := queries.UserCountSearch.SQL + scope.And() + filters.And()
+	if err := repo.q.QueryRow(ctx, countQuery, append(args, filters.Args...)...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count user matches: %w", err)
+	}
+
+	search := queries.UserSearch
+	args = append(args, escaped+"%")
+	if ranked {
+		search = queries.UserSearchRanked
+		args = append(args, q)
+	}
+	page := req.Clauses(len(args) + 1)
+	query := search.SQL + scope.And() + page.And() + " " + page.OrderBy + " " + page.Limit
+
+	rows, err := database.QueryAll[Match](ctx, repo.q, query, append(args, page.Args...)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	matches := make([]*Match, len(rows))
+	for i := range rows {
+		matches[i] = &rows[i]
+	}
+	return matches, total, nil
+}
+
+// trigramAvailable reports whether pg_trgm's similarity is on the search
+// path, asking the database once
+func (repo *UserRepository) trigramAvailable(ctx context.Context) (bool, error) {
+	if known := repo.trigram.Load(); known != nil {
+		return *known, nil
+	}
+	var ok bool
+	if err := repo.q.QueryRow(ctx, "SELECT to_regproc('similarity') IS NOT NULL").Scan(&ok); err != nil {
+		return false, fmt.Errorf("failed to check for pg_trgm: %w", err)
+	}
+	repo.trigram.Store(&ok)
+	return ok, nil
+}
@@ -0,0 +1,165 @@
+package model
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dvith.com/go-service-api/internal/pagination"
+	"dvith.com/go-service-api/internal/queries"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// searchQuerier answers the pg_trgm probe, the count and the search,
+// recording every statement
+type searchQuerier struct {
+	trigram bool
+	match   User
+	sqls    []string
+	args    [][]any
+}
+
+func (q *searchQuerier) record(sql string, args []any) {
+	q.sqls = append(q.sqls, sql)
+	q.args = append(q.args, args)
+}
+
+func (q *searchQuerier) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	q.record(sql, args)
+	rows := userRow(q.match)
+	rows.columns = append(rows.columns, "search_rank")
+	rows.values = append(rows.values, 1.25)
+	return &rows, nil
+}
+
+func (q *searchQuerier) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	q.record(sql, args)
+	if strings.Contains(sql, "to_regproc") {
+		return &fakeRows{values: []any{q.trigram}}
+	}
+	return &fakeRows{values: []any{int64(1)}}
+}
+
+func (q *searchQuerier) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	q.record(sql, args)
+	return pgconn.CommandTag{}, nil
+}
+
+// last returns the statement and arguments of the search itself
+func (q *searchQuerier) last() (string, []any) {
+	return q.sqls[len(q.sqls)-1], q.args[len(q.args)-1]
+}
+
+// searchPage parses query as a page of the users search
+func searchPage(t *testing.T, query string) pagination.PageRequest {
+	t.Helper()
+	var req pagination.PageRequest
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) (err error) {
+		req, err = pagination.ParsePageRequest(c, SearchOptions)
+		return err
+	})
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return req
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"jo":         "jo",
+		"100%":       `100\%`,
+		"first_last": `first\_last`,
+		`back\slash`: `back\\slash`,
+		`%_\`:        `\%\_\\`,
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, EscapeLike(in), in)
+	}
+}
+
+func TestUserRepository_Search_EscapesTerm(t *testing.T) {
+	ctx, tenantID := tenantCtx()
+	q := &searchQuerier{trigram: true, match: User{ID: uuid.New(), Email: "100%@example.com"}}
+	repo := NewUserRepository(q)
+
+	matches, total, err := repo.Search(ctx, "100%", searchPage(t, ""), ActiveOnly)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "100%@example.com", matches[0].Email)
+	assert.Equal(t, 1.25, matches[0].Rank)
+
+	sql, args := q.last()
+	assert.True(t, strings.HasPrefix(sql, queries.UserSearchRanked.SQL))
+	assert.Equal(t, []any{tenantID, `%100\%%`, `100\%%`, "100%", 21}, args, "the raw term only feeds similarity")
+	assert.NotContains(t, sql, "100%", "the term is never part of the statement")
+}
+
+func TestUserRepository_Search_FallsBackWithoutTrigram(t *testing.T) {
+	ctx, tenantID := tenantCtx()
+	q := &searchQuerier{match: User{ID: uuid.New(), Email: "jo@example.com"}}
+	repo := NewUserRepository(q)
+
+	_, _, err := repo.Search(ctx, "jo", searchPage(t, ""), ActiveOnly)
+	require.NoError(t, err)
+	sql, args := q.last()
+	assert.True(t, strings.HasPrefix(sql, queries.UserSearch.SQL))
+	assert.NotContains(t, sql, "similarity")
+	assert.Equal(t, []any{tenantID, "%jo%", "jo%", 21}, args)
+
+	_, _, err = repo.Search(ctx, "jo", searchPage(t, ""), ActiveOnly)
+	require.NoError(t, err)
+	probes := 0
+	for _, s := range q.sqls {
+		if strings.Contains(s, "to_regproc") {
+			probes++
+		}
+	}
+	assert.Equal(t, 1, probes, "the database is asked about pg_trgm once")
+}
+
+func TestUserRepository_Search_Pages(t *testing.T) {
+	ctx, _ := tenantCtx()
+	first := searchPage(t, "limit=1")
+	next := searchPage(t, "limit=1&role=admin&cursor="+first.NextCursor(1.0/3, uuid.New()))
+
+	for _, trigram := range []bool{true, false} {
+		q := &searchQuerier{trigram: trigram}
+		repo := NewUserRepository(q)
+		_, _, err := repo.Search(ctx, "jo", next, IncludeDeleted)
+		require.NoError(t, err)
+
+		// The count only takes the filters, after the tenant and pattern
+		count := q.sqls[len(q.sqls)-2]
+		assert.True(t, strings.HasSuffix(count, " AND role = $3"), count)
+
+		sql, args := q.last()
+		params := 3
+		if trigram {
+			params = 4
+		}
+		assert.Contains(t, sql, "ORDER BY search_rank DESC, id DESC")
+		assert.Contains(t, sql, "(search_rank, id) < ")
+		assert.Equal(t, "admin", args[params])
+		assert.Equal(t, 1.0/3, args[params+1], "the rank survives the cursor exactly")
+		assert.Equal(t, 2, args[len(args)-1])
+	}
+}
+
+func TestUserRepository_Search_TooLong(t *testing.T) {
+	ctx, _ := tenantCtx()
+	q := &searchQuerier{}
+	repo := NewUserRepository(q)
+
+	_, _, err := repo.Search(ctx, strings.Repeat("a", MaxSearchLength+1), searchPage(t, ""), ActiveOnly)
+	assert.ErrorIs(t, err, ErrSearchTooLong)
+	assert.Empty(t, q.sqls)
+}
@@ -0,0 +1,252 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"dvith.com/go-service-api/internal/errs"
+	"dvith.com/go-service-api/internal/queries"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/crypto"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// RenameWindow is the period UsernamePolicy.MaxRenames is counted over
+const RenameWindow = 30 * 24 * time.Hour
+
+var (
+	// ErrUsernameReserved is returned for a username another user renamed
+	// away from while it is still reserved for them
+	ErrUsernameReserved = errors.New("username is reserved")
+	// ErrRenameLimit is returned when the user already changed their
+	// username UsernamePolicy.MaxRenames times in RenameWindow
+	ErrRenameLimit = errors.New("username changed too many times, try again later")
+)
+
+func init() {
+	errs.Register(errs.Translation{
+		Name:   "username_reserved",
+		Match:  func(err error) bool { return errors.Is(err, ErrUsernameReserved) },
+		Status: http.StatusConflict,
+		Code:   "username_reserved",
+	})
+	errs.Register(errs.Translation{
+		Name:   "rename_limit",
+		Match:  func(err error) bool { return errors.Is(err, ErrRenameLimit) },
+		Status: http.StatusTooManyRequests,
+		Code:   "rename_limit",
+	})
+}
+
+// UsernamePolicy governs username changes. A username renamed away from
+// stays reserved for its user for Reservation, so nobody else can take it
+// to impersonate them while they can still reclaim it. MaxRenames of 0
+// means no cap.
+type UsernamePolicy struct {
+	Reservation time.Duration
+	MaxRenames  int
+}
+
+// usernameChange is a row in the username_history table
+type usernameChange struct {
+	TenantID      uuid.UUID
+	UserID        uuid.UUID
+	Username      string
+	ChangedAt     time.Time
+	ReservedUntil time.Time
+}
+
+// beginner starts transactions, or savepoints inside one
+type beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// ChangeProfile is UpdateProfile for users changing their own profile. A
+// new username is checked against the names others renamed away from and
+// against policy.MaxRenames, and the old one is recorded and reserved. The
+// checks and writes run in one transaction, holding locks on the user and
+// both names, so concurrent renames can't slip past them.
+func (repo *UserRepository) ChangeProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate, policy UsernamePolicy) (*User, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := repo.q.(beginner)
+	if !ok {
+		return nil, fmt.Errorf("profile changes need a database that can begin transactions")
+	}
+
+	var user *User
+	err = pgx.BeginFunc(ctx, b, func(tx pgx.Tx) error {
+		now := repo.now()
+		var current string
+		if err := tx.QueryRow(ctx, queries.UsernameLockUser.SQL, tenantID, userID).Scan(&current); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+
+		if update.Username != nil && *update.Username != current {
+			if err := renameChecks(ctx, tx, tenantID, userID, current, *update.Username, policy, now); err != nil {
+				return err
+			}
+		}
+
+		var fullName any
+		if update.FullName != nil {
+			fullName = crypto.EncryptedString(*update.FullName)
+		}
+		user, err = database.QueryOne[User](ctx, tx, queries.UserUpdateProfile.SQL, tenantID, userID, fullName, update.Username, now)
+		if err != nil {
+			if errs.HasSQLState(err, errs.SQLStateUniqueViolation) {
+				return ErrUserExists
+			}
+			return fmt.Errorf("failed to update profile: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// renameChecks enforces policy on renaming userID from current to username
+// and records the rename, on tx
+func renameChecks(ctx context.Context, tx pgx.Tx, tenantID, userID uuid.UUID, current, username string, policy UsernamePolicy, now time.Time) error {
+	// In a fixed order, so two users swapping names can't deadlock
+	names := []string{current, username}
+	if username < current {
+		names[0], names[1] = username, current
+	}
+	for _, name := range names {
+		if _, err := tx.Exec(ctx, queries.UsernameLockName.SQL, tenantID.String(), name); err != nil {
+			return fmt.Errorf("failed to lock username: %w", err)
+		}
+	}
+
+	if policy.MaxRenames > 0 {
+		var renames int
+		if err := tx.QueryRow(ctx, queries.UsernameCountSince.SQL, userID, now.Add(-RenameWindow).UTC()).Scan(&renames); err != nil {
+			return fmt.Errorf("failed to count renames: %w", err)
+		}
+		if renames >= policy.MaxRenames {
+			return ErrRenameLimit
+		}
+	}
+
+	var reserved bool
+	if err := tx.QueryRow(ctx, queries.UsernameReserved.SQL, tenantID, username, userID, now.UTC()).Scan(&reserved); err != nil {
+		return fmt.Errorf("failed to check username reservation: %w", err)
+	}
+	if reserved {
+		return ErrUsernameReserved
+	}
+
+	// Reclaiming a name ends its reservation; the row still counts as a rename
+	if _, err := tx.Exec(ctx, queries.UsernameRelease.SQL, userID, username, now.UTC()); err != nil {
+		return fmt.Errorf("failed to release username: %w", err)
+	}
+	if current == "" {
+		return nil
+	}
+	_, err := tx.Exec(ctx, queries.UsernameInsert.SQL, uuid.New(), tenantID, userID, current, now.UTC(), now.Add(policy.Reservation).UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record username change: %w", err)
+	}
+	return nil
+}
+
+// DeleteUsernameHistory removes the usernames the user renamed away from,
+// ending their reservations
+func (repo *UserRepository) DeleteUsernameHistory(ctx context.Context, userID uuid.UUID) error {
+	if _, err := repo.q.Exec(ctx, queries.UsernameDeleteByUser.SQL, userID); err != nil {
+		return fmt.Errorf("failed to delete username history: %w", err)
+	}
+	return nil
+}
+
+// ChangeProfile implements Store
+func (s *MemoryStore) ChangeProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate, policy UsernamePolicy) (*User, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.live(tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	rename := update.Username != nil && *update.Username != u.Username
+	if rename {
+		if policy.MaxRenames > 0 && s.renamesSince(userID, now.Add(-RenameWindow)) >= policy.MaxRenames {
+			return nil, ErrRenameLimit
+		}
+		if s.reserved(tenantID, userID, *update.Username, now) {
+			return nil, ErrUsernameReserved
+		}
+		if s.taken(tenantID, userID, "", *update.Username) {
+			return nil, ErrUserExists
+		}
+	}
+
+	if update.FullName != nil {
+		u.FullName = crypto.EncryptedString(*update.FullName)
+	}
+	if rename {
+		for i := range s.history {
+			if h := &s.history[i]; h.UserID == userID && h.Username == *update.Username && h.ReservedUntil.After(now) {
+				h.ReservedUntil = now
+			}
+		}
+		if u.Username != "" {
+			s.history = append(s.history, usernameChange{
+				TenantID:      tenantID,
+				UserID:        userID,
+				Username:      u.Username,
+				ChangedAt:     now,
+				ReservedUntil: now.Add(policy.Reservation),
+			})
+		}
+		u.Username = *update.Username
+	}
+	u.UpdatedAt = now
+
+	out := *u
+	return &out, nil
+}
+
+// renamesSince counts the user's renames after since. Callers hold the lock.
+func (s *MemoryStore) renamesSince(userID uuid.UUID, since time.Time) int {
+	n := 0
+	for _, h := range s.history {
+		if h.UserID == userID && h.ChangedAt.After(since) {
+			n++
+		}
+	}
+	return n
+}
+
+// reserved reports whether username is reserved at now for a user of the
+// tenant other than except. Callers hold the lock.
+func (s *MemoryStore) reserved(tenantID, except uuid.UUID, username string, now time.Time) bool {
+	if username == "" {
+		return false
+	}
+	for _, h := range s.history {
+		if h.TenantID == tenantID && h.UserID != except && h.Username == username && h.ReservedUntil.After(now) {
+			return true
+		}
+	}
+	return false
+}
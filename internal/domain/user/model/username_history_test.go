@@ -0,0 +1,110 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renameTo(ctx context.Context, t *testing.T, store *MemoryStore, userID uuid.UUID, username string, policy UsernamePolicy) error {
+	t.Helper()
+	_, err := store.ChangeProfile(ctx, userID, ProfileUpdate{Username: &username}, policy)
+	return err
+}
+
+func TestChangeProfile_ReservesOldUsername(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	frozen := clock.NewFrozen(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	store := NewMemoryStore().WithClock(frozen.Now)
+	policy := UsernamePolicy{Reservation: 90 * 24 * time.Hour}
+
+	alice, err := store.SaveUser(ctx, &User{Email: "alice@example.com", Username: "alice"})
+	require.NoError(t, err)
+	bob, err := store.SaveUser(ctx, &User{Email: "bob@example.com", Username: "bob"})
+	require.NoError(t, err)
+
+	require.NoError(t, renameTo(ctx, t, store, alice.ID, "alice_new", policy))
+
+	assert.ErrorIs(t, renameTo(ctx, t, store, bob.ID, "alice", policy), ErrUsernameReserved, "someone else can't take a reserved name")
+	_, err = store.SaveUser(ctx, &User{Email: "mallory@example.com", Username: "alice"})
+	assert.ErrorIs(t, err, ErrUserExists, "nor sign up with it")
+	a, err := store.Availability(ctx, "mallory@example.com", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, Availability{UsernameTaken: true}, a, "the availability check counts reserved names")
+
+	other := tenant.WithID(context.Background(), uuid.New())
+	_, err = store.SaveUser(other, &User{Email: "alice@example.com", Username: "alice"})
+	assert.NoError(t, err, "reservations are scoped to their tenant")
+
+	frozen.Advance(90*24*time.Hour + time.Second)
+	require.NoError(t, renameTo(ctx, t, store, bob.ID, "alice", policy), "the name is free once the reservation ends")
+}
+
+func TestChangeProfile_OwnerReclaims(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	frozen := clock.NewFrozen(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	store := NewMemoryStore().WithClock(frozen.Now)
+	policy := UsernamePolicy{Reservation: 90 * 24 * time.Hour}
+
+	alice, err := store.SaveUser(ctx, &User{Email: "alice@example.com", Username: "alice"})
+	require.NoError(t, err)
+	require.NoError(t, renameTo(ctx, t, store, alice.ID, "alice_new", policy))
+
+	frozen.Advance(24 * time.Hour)
+	require.NoError(t, renameTo(ctx, t, store, alice.ID, "alice", policy), "the owner takes the name back within the window")
+	found, err := store.FindByID(ctx, alice.ID, ActiveOnly)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", found.Username)
+
+	// Now alice_new is the reserved one
+	bob, err := store.SaveUser(ctx, &User{Email: "bob@example.com", Username: "bob"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, renameTo(ctx, t, store, bob.ID, "alice_new", policy), ErrUsernameReserved)
+}
+
+func TestChangeProfile_RenameCap(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	frozen := clock.NewFrozen(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	store := NewMemoryStore().WithClock(frozen.Now)
+	policy := UsernamePolicy{Reservation: time.Hour, MaxRenames: 2}
+
+	u, err := store.SaveUser(ctx, &User{Email: "jane@example.com", Username: "jane"})
+	require.NoError(t, err)
+	require.NoError(t, renameTo(ctx, t, store, u.ID, "jane_1", policy))
+	require.NoError(t, renameTo(ctx, t, store, u.ID, "jane_2", policy))
+	assert.ErrorIs(t, renameTo(ctx, t, store, u.ID, "jane_3", policy), ErrRenameLimit)
+
+	name := "Jane Doe"
+	_, err = store.ChangeProfile(ctx, u.ID, ProfileUpdate{FullName: &name, Username: strPtr("jane_2")}, policy)
+	assert.NoError(t, err, "keeping the username isn't a rename")
+
+	frozen.Advance(RenameWindow)
+	assert.NoError(t, renameTo(ctx, t, store, u.ID, "jane_3", policy), "the cap counts renames in the last RenameWindow")
+
+	unlimited := UsernamePolicy{Reservation: time.Hour}
+	for _, name := range []string{"jane_4", "jane_5", "jane_6"} {
+		require.NoError(t, renameTo(ctx, t, store, u.ID, name, unlimited))
+	}
+}
+
+func TestChangeProfile_TakenAndMissing(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	store := NewMemoryStore()
+	policy := UsernamePolicy{Reservation: time.Hour}
+
+	u, err := store.SaveUser(ctx, &User{Email: "jane@example.com", Username: "jane"})
+	require.NoError(t, err)
+	_, err = store.SaveUser(ctx, &User{Email: "john@example.com", Username: "john"})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, renameTo(ctx, t, store, u.ID, "john", policy), ErrUserExists)
+	assert.ErrorIs(t, renameTo(ctx, t, store, uuid.New(), "nobody", policy), ErrUserNotFound)
+}
+
+func strPtr(s string) *string { return &s }
@@ -0,0 +1,81 @@
+package private
+
+import (
+	"errors"
+
+	"dvith.com/go-service-api/internal/domain/user/dto"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/preferences"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// PreferencesResponse is the body of both preferences routes
+type PreferencesResponse struct {
+	Preferences dto.PreferencesDTO `json:"preferences" xml:"preferences"`
+}
+
+// UpdatePreferencesRequest changes the fields it sets; omitted fields keep
+// their value
+type UpdatePreferencesRequest struct {
+	SecurityEmails *bool   `json:"security_emails"`
+	ProductEmails  *bool   `json:"product_emails"`
+	Locale         *string `json:"locale" validate:"omitnil,max=35,bcp47_language_tag"`
+	Timezone       *string `json:"timezone" validate:"omitnil,max=64,timezone"`
+}
+
+// PreferencesHandler returns the authenticated user's preferences, the
+// defaults when they never changed them
+func PreferencesHandler(store preferences.Store) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		prefs, err := store.Get(middleware.GetRequestContext(c), userID)
+		if err != nil {
+			logger.Error("failed to load preferences", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to load preferences", err)
+		}
+
+		return middleware.OK(c, PreferencesResponse{Preferences: dto.FromPreferences(prefs)})
+	}
+}
+
+// UpdatePreferencesHandler applies an UpdatePreferencesRequest validated by
+// ValidateBody
+func UpdatePreferencesHandler(store preferences.Store) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+		req, err := middleware.GetValidatedBody[UpdatePreferencesRequest](c)
+		if err != nil {
+			return middleware.InternalErrorResponse(c, "validated body missing")
+		}
+
+		prefs, err := store.Update(middleware.GetRequestContext(c), userID, preferences.Patch{
+			SecurityEmails: req.SecurityEmails,
+			ProductEmails:  req.ProductEmails,
+			Locale:         req.Locale,
+			Timezone:       req.Timezone,
+		})
+		if err != nil {
+			if errors.Is(err, preferences.ErrInvalidLocale) || errors.Is(err, preferences.ErrInvalidTimezone) {
+				return middleware.ValidationErrorResponse(c, err.Error())
+			}
+			logger.Error("failed to update preferences", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to update preferences", err)
+		}
+
+		return middleware.OK(c, PreferencesResponse{Preferences: dto.FromPreferences(prefs)})
+	}
+}
@@ -0,0 +1,76 @@
+package private_test
+
+import (
+	"net/http"
+	"testing"
+
+	"dvith.com/go-service-api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferences_DefaultsOnFirstRead(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/preferences", nil, user.AccessToken)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+	prefs := resp.Object("preferences")
+	assert.Equal(t, true, prefs["security_emails"])
+	assert.Equal(t, false, prefs["product_emails"])
+	assert.Equal(t, "en", prefs["locale"])
+	assert.Equal(t, "UTC", prefs["timezone"])
+	assert.Equal(t, testutil.Epoch.Format("2006-01-02T15:04:05Z"), prefs["updated_at"])
+}
+
+func TestPreferences_Update(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/preferences", map[string]any{
+		"product_emails": true,
+		"locale":         "th-th",
+		"timezone":       "Asia/Bangkok",
+	}, user.AccessToken)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+
+	resp = testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/preferences", map[string]any{
+		"security_emails": false,
+	}, user.AccessToken)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+
+	resp = testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/preferences", nil, user.AccessToken)
+	prefs := resp.Object("preferences")
+	assert.Equal(t, false, prefs["security_emails"])
+	assert.Equal(t, true, prefs["product_emails"], "omitted fields keep their value")
+	assert.Equal(t, "th-TH", prefs["locale"], "locales are stored canonically")
+	assert.Equal(t, "Asia/Bangkok", prefs["timezone"])
+}
+
+func TestPreferences_RejectsInvalidValues(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	tests := map[string]struct {
+		body map[string]any
+		code string
+	}{
+		"unknown timezone": {map[string]any{"timezone": "Mars/Olympus_Mons"}, "invalid_timezone"},
+		"local timezone":   {map[string]any{"timezone": "Local"}, "invalid_timezone"},
+		"empty timezone":   {map[string]any{"timezone": ""}, "invalid_timezone"},
+		"invalid locale":   {map[string]any{"locale": "not a locale"}, "invalid_locale"},
+	}
+	for name, tt := range tests {
+		resp := testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/preferences", tt.body, user.AccessToken)
+		require.Equal(t, http.StatusBadRequest, resp.Status, name)
+		fields, _ := resp.Body["fields"].([]any)
+		require.Len(t, fields, 1, name)
+		assert.Equal(t, tt.code, fields[0].(map[string]any)["code"], name)
+	}
+
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/preferences", nil, user.AccessToken)
+	assert.Equal(t, "UTC", resp.Object("preferences")["timezone"], "rejected updates aren't saved")
+}
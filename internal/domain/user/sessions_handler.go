@@ -0,0 +1,98 @@
+package private
+
+import (
+	"context"
+	"errors"
+
+	"dvith.com/go-service-api/internal/domain/user/dto"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// maxListedSessions caps how many sessions SessionsHandler returns
+const maxListedSessions = 50
+
+// SessionsResponse lists the user's sessions, most recently used first
+type SessionsResponse struct {
+	Sessions []dto.SessionDTO `json:"sessions"`
+}
+
+// SessionLister loads the sessions shown by SessionsHandler
+type SessionLister interface {
+	ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]session.Session, error)
+}
+
+// SessionsHandler lists the authenticated user's sessions with their
+// parsed device details
+func SessionsHandler(lister SessionLister) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		sessions, err := lister.ListRecent(middleware.GetRequestContext(c), userID, maxListedSessions)
+		if err != nil {
+			logger.Error("failed to list sessions", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to list sessions", err)
+		}
+
+		response := SessionsResponse{Sessions: make([]dto.SessionDTO, 0, len(sessions))}
+		for _, s := range sessions {
+			response.Sessions = append(response.Sessions, dto.FromSession(s))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// SessionRevoker revokes one of the user's sessions
+type SessionRevoker interface {
+	Revoke(ctx context.Context, userID, sessionID uuid.UUID) error
+}
+
+// RevokedSessions records revocations on this instance
+type RevokedSessions interface {
+	MarkRevoked(sessionID uuid.UUID)
+}
+
+// RevokeSessionHandler revokes the session in the path. Its access tokens
+// are rejected at once here and, through the revocation notification,
+// within seconds on the other instances.
+func RevokeSessionHandler(revoker SessionRevoker, revoked RevokedSessions) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+		sessionID, err := uuid.Parse(c.Params("session_id"))
+		if err != nil {
+			return middleware.ValidationErrorResponse(c, "session_id must be a UUID")
+		}
+
+		if err := revoker.Revoke(middleware.GetRequestContext(c), userID, sessionID); err != nil {
+			if errors.Is(err, session.ErrSessionNotFound) {
+				return middleware.NotFoundResponse(c, "session not found")
+			}
+			logger.Error("failed to revoke session", map[string]any{
+				"user_id":    userID.String(),
+				"session_id": sessionID.String(),
+				"error":      err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to revoke session", err)
+		}
+		revoked.MarkRevoked(sessionID)
+
+		logger.Info("session revoked", map[string]any{
+			"user_id":    userID.String(),
+			"session_id": sessionID.String(),
+		})
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
@@ -0,0 +1,116 @@
+package private
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSessionLister struct {
+	sessions []session.Session
+	err      error
+}
+
+func (f *fakeSessionLister) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]session.Session, error) {
+	return f.sessions, f.err
+}
+
+func newSessionsTestApp(lister SessionLister) *fiber.App {
+	app := fiber.New()
+	app.Get("/sessions", func(c fiber.Ctx) error {
+		c.Locals(middleware.ContextKeyUserID, uuid.New())
+		return c.Next()
+	}, SessionsHandler(lister))
+	return app
+}
+
+func TestSessionsHandler_ReturnsParsedDevices(t *testing.T) {
+	bangkok := time.FixedZone("ICT", 7*60*60)
+	lister := &fakeSessionLister{sessions: []session.Session{{
+		ID:         uuid.New(),
+		IP:         "203.0.113.7",
+		UserAgent:  "Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) Version/18.0 Mobile/15E148 Safari/604.1",
+		Browser:    "Safari",
+		OS:         "iOS",
+		DeviceType: "Mobile",
+		CreatedAt:  time.Date(2026, 10, 14, 16, 0, 0, 0, bangkok),
+		LastUsedAt: time.Date(2026, 10, 14, 16, 0, 0, 0, bangkok),
+	}}}
+
+	resp, err := newSessionsTestApp(lister).Test(httptest.NewRequest(http.MethodGet, "/sessions", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Sessions []map[string]any `json:"sessions"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Sessions, 1)
+	assert.Equal(t, "Safari", body.Sessions[0]["browser"])
+	assert.Equal(t, "iOS", body.Sessions[0]["os"])
+	assert.Equal(t, "Mobile", body.Sessions[0]["device_type"])
+	assert.Equal(t, "2026-10-14T09:00:00Z", body.Sessions[0]["created_at"])
+	assert.NotContains(t, body.Sessions[0], "revoked_at")
+}
+
+func TestSessionsHandler_Empty(t *testing.T) {
+	resp, err := newSessionsTestApp(&fakeSessionLister{}).Test(httptest.NewRequest(http.MethodGet, "/sessions", nil))
+	require.NoError(t, err)
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, []any{}, body["sessions"])
+}
+
+func TestSessionsHandler_RepositoryFailure(t *testing.T) {
+	resp, err := newSessionsTestApp(&fakeSessionLister{err: errors.New("conn closed")}).Test(httptest.NewRequest(http.MethodGet, "/sessions", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+type recordedRevocations []uuid.UUID
+
+func (r *recordedRevocations) MarkRevoked(id uuid.UUID) { *r = append(*r, id) }
+
+func TestRevokeSessionHandler(t *testing.T) {
+	store := session.NewMemoryStore()
+	userID := uuid.New()
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	s := &session.Session{UserID: userID}
+	require.NoError(t, store.Create(ctx, s))
+
+	var revoked recordedRevocations
+	app := fiber.New()
+	app.Delete("/sessions/:session_id", func(c fiber.Ctx) error {
+		c.Locals(middleware.ContextKeyUserID, userID)
+		c.Locals(middleware.ContextKeyRequestContext, ctx)
+		return c.Next()
+	}, RevokeSessionHandler(store, &revoked))
+
+	del := func(id string) int {
+		resp, err := app.Test(httptest.NewRequest(http.MethodDelete, "/sessions/"+id, nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusNotFound, del(uuid.NewString()))
+	assert.Equal(t, http.StatusNoContent, del(s.ID.String()))
+	assert.Equal(t, recordedRevocations{s.ID}, revoked)
+
+	sessions, err := store.ListRecent(ctx, userID, 10)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.NotNil(t, sessions[0].RevokedAt)
+}
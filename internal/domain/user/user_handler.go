@@ -1,20 +1,43 @@
 package private
 
 import (
+	"context"
+	"errors"
+
+	"dvith.com/go-service-api/internal/apiversion"
+	"dvith.com/go-service-api/internal/domain/user/dto"
+	"dvith.com/go-service-api/internal/domain/user/model"
 	"dvith.com/go-service-api/internal/middleware"
-	"dvith.com/go-service-api/pkg/database"
 	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/textnorm"
 	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
 )
 
 // ProfileResponse represents the user profile response
 type ProfileResponse struct {
-	UserID string `json:"user_id"`
-	// Add more fields as needed
+	User dto.UserDTO `json:"user"`
+}
+
+// ProfileHandler retrieves the authenticated user's profile, see
+// middleware.GetCurrentUser
+func ProfileHandler() fiber.Handler {
+	return profileHandler(func(c fiber.Ctx, user *model.User) error {
+		return c.Status(fiber.StatusOK).JSON(ProfileResponse{
+			User: dto.FromUser(user),
+		})
+	})
+}
+
+// ProfileV2Handler is ProfileHandler in the v2 envelope, with the user
+// under data
+func ProfileV2Handler() fiber.Handler {
+	return profileHandler(func(c fiber.Ctx, user *model.User) error {
+		return middleware.OK(c, apiversion.Envelope{Data: dto.FromUser(user)})
+	})
 }
 
-// ProfileHandler retrieves the authenticated user's profile
-func ProfileHandler(db *database.DBPool) fiber.Handler {
+func profileHandler(render func(c fiber.Ctx, user *model.User) error) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		// Get user ID from context (set by AuthMiddleware)
 		userID, err := middleware.GetUserIDFromContext(c)
@@ -29,11 +52,116 @@ func ProfileHandler(db *database.DBPool) fiber.Handler {
 			"user_id": userID.String(),
 		})
 
-		// TODO: Query database for user profile
-		// For now, return the user ID
+		user, err := middleware.GetCurrentUser(c)
+		if err != nil {
+			if errors.Is(err, ErrUserNotFound) {
+				return middleware.NotFoundResponse(c, "user not found")
+			}
+			logger.Error("failed to load user profile", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to load profile", err)
+		}
 
-		return c.Status(fiber.StatusOK).JSON(ProfileResponse{
-			UserID: userID.String(),
+		return render(c, user)
+	}
+}
+
+// AccountStore is what the account deletion route needs
+type AccountStore interface {
+	SoftDeleteUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// DeleteAccountHandler soft-deletes the authenticated user's account
+func DeleteAccountHandler(repo AccountStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+
+		if err := repo.SoftDeleteUser(middleware.GetRequestContext(c), userID); err != nil {
+			if errors.Is(err, ErrUserNotFound) {
+				return middleware.NotFoundResponse(c, "user not found")
+			}
+			logger.Error("failed to delete user account", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to delete account", err)
+		}
+
+		middleware.ForgetCurrentUser(c)
+
+		logger.Info("user account deleted", map[string]any{
+			"user_id": userID.String(),
 		})
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// UpdateProfileRequest changes the fields it sets; omitted fields keep
+// their value
+type UpdateProfileRequest struct {
+	FullName *string `json:"full_name" validate:"omitnil,min=1,max=255"`
+	Username *string `json:"username" validate:"omitnil,min=3,max=100"`
+}
+
+// ProfileStore is what the profile update route needs
+type ProfileStore interface {
+	ChangeProfile(ctx context.Context, userID uuid.UUID, update model.ProfileUpdate, policy model.UsernamePolicy) (*model.User, error)
+}
+
+// UpdateProfileHandler applies an UpdateProfileRequest validated by
+// ValidateBody. Renames follow policy: the old username stays reserved for
+// the user, who can take it back meanwhile, and a username someone else
+// renamed away from is refused until its reservation ends.
+func UpdateProfileHandler(store ProfileStore, policy model.UsernamePolicy) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := middleware.GetUserIDFromContext(c)
+		if err != nil {
+			return middleware.AuthErrorResponse(c, "user not authenticated")
+		}
+		req, err := middleware.GetValidatedBody[UpdateProfileRequest](c)
+		if err != nil {
+			return middleware.InternalErrorResponse(c, "validated body missing")
+		}
+
+		update := model.ProfileUpdate{}
+		if req.FullName != nil {
+			name := textnorm.FullName(*req.FullName)
+			if name == "" {
+				return middleware.ValidationErrorResponse(c, "full name must not be blank")
+			}
+			update.FullName = &name
+		}
+		if req.Username != nil {
+			username := textnorm.Username(*req.Username)
+			if len(username) < 3 || textnorm.HasSpace(username) {
+				return middleware.ValidationErrorResponse(c, "username must be at least 3 characters without whitespace")
+			}
+			update.Username = &username
+		}
+
+		user, err := store.ChangeProfile(middleware.GetRequestContext(c), userID, update, policy)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrUserNotFound):
+				return middleware.NotFoundResponse(c, "user not found")
+			case errors.Is(err, model.ErrUserExists), errors.Is(err, model.ErrUsernameReserved), errors.Is(err, model.ErrRenameLimit):
+				// Translated by the error middleware
+				return err
+			}
+			logger.Error("failed to update user profile", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+			return middleware.InternalErrorResponse(c, "failed to update profile", err)
+		}
+
+		middleware.ForgetCurrentUser(c)
+		return middleware.OK(c, ProfileResponse{User: dto.FromUser(user)})
 	}
 }
@@ -0,0 +1,85 @@
+package private_test
+
+import (
+	"net/http"
+	"testing"
+
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/profile", map[string]any{
+		"full_name": "  Jane   Doe ",
+	}, user.AccessToken)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+	assert.Equal(t, "Jane Doe", resp.Object("user")["full_name"])
+	assert.Equal(t, user.Username, resp.Object("user")["username"], "omitted fields keep their value")
+
+	resp = testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/profile", map[string]any{
+		"username": "jane doe",
+	}, user.AccessToken)
+	assert.Equal(t, http.StatusBadRequest, resp.Status, "usernames can't contain whitespace")
+
+	resp = testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/profile", nil, user.AccessToken)
+	assert.Equal(t, "Jane Doe", resp.Object("user")["full_name"], "the cached user is refreshed")
+}
+
+func TestUpdateProfile_RenameKeepsOldUsernameReserved(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	owner := testutil.SignupUser(t, a)
+	other := testutil.SignupUser(t, a)
+
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/profile", map[string]any{
+		"username": owner.Username + "_new",
+	}, owner.AccessToken)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+
+	resp = testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/profile", map[string]any{
+		"username": owner.Username,
+	}, other.AccessToken)
+	assert.Equal(t, http.StatusConflict, resp.Status)
+	assert.Equal(t, "username_reserved", resp.String("error"))
+
+	resp = testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/profile", map[string]any{
+		"username": other.Username,
+	}, owner.AccessToken)
+	assert.Equal(t, http.StatusConflict, resp.Status, "taken usernames are refused too")
+
+	resp = testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/profile", map[string]any{
+		"username": owner.Username,
+	}, owner.AccessToken)
+	require.Equal(t, http.StatusOK, resp.Status, "the owner reclaims it: %s", resp.Raw)
+	assert.Equal(t, owner.Username, resp.Object("user")["username"])
+}
+
+func TestUpdateProfile_RenameCap(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{Configure: func(cfg *config.Config) {
+		cfg.UsernameMaxRenames = 1
+	}})
+	user := testutil.SignupUser(t, a)
+
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/profile", map[string]any{
+		"username": user.Username + "_a",
+	}, user.AccessToken)
+	require.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+
+	resp = testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/profile", map[string]any{
+		"username": user.Username + "_b",
+	}, user.AccessToken)
+	assert.Equal(t, http.StatusTooManyRequests, resp.Status)
+	assert.Equal(t, "rename_limit", resp.String("error"))
+
+	resp = testutil.AuthenticatedRequest(t, a, http.MethodPatch, "/api/v1/user/profile", map[string]any{
+		"full_name": "Still Allowed",
+	}, user.AccessToken)
+	assert.Equal(t, http.StatusOK, resp.Status, "the cap only applies to renames")
+}
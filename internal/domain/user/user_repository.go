@@ -0,0 +1,100 @@
+package private
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/outbox"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/crypto"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrUserNotFound is returned when the user does not exist or is already deleted
+var ErrUserNotFound = model.ErrUserNotFound
+
+// AccountData is the exportable view of a user row. It deliberately has
+// no password field so the hash can never leak into an export.
+type AccountData struct {
+	ID            uuid.UUID              `db:"id" json:"id"`
+	TenantID      uuid.UUID              `db:"tenant_id" json:"tenant_id"`
+	Email         string                 `db:"email" json:"email"`
+	FullName      crypto.EncryptedString `db:"full_name" json:"full_name"`
+	Username      string                 `db:"username" json:"username"`
+	IsActive      bool                   `db:"is_active" json:"is_active"`
+	EmailVerified bool                   `db:"email_verified" json:"email_verified"`
+	VerifiedAt    *time.Time             `db:"verified_at" json:"verified_at"`
+	CreatedAt     time.Time              `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time              `db:"updated_at" json:"updated_at"`
+}
+
+// UserRepository handles user account persistence
+type UserRepository struct {
+	db *database.DBPool
+}
+
+// NewUserRepository creates a new user repository
+func NewUserRepository(db *database.DBPool) *UserRepository {
+	return &UserRepository{
+		db: db,
+	}
+}
+
+// SoftDeleteUser marks the user as deleted and records a user.deleted
+// outbox event in the same transaction
+func (repo *UserRepository) SoftDeleteUser(ctx context.Context, userID uuid.UUID) error {
+	return repo.db.WithTx(ctx, func(tx pgx.Tx) error {
+		now := clock.Now()
+		if err := model.NewUserRepository(tx).WithClock(func() time.Time { return now }).SoftDelete(ctx, userID); err != nil {
+			return err
+		}
+
+		if err := audit.Record(ctx, tx, audit.Entry{UserID: userID, Action: audit.ActionUserDeleted}); err != nil {
+			return err
+		}
+
+		return outbox.Enqueue(ctx, tx, outbox.EventUserDeleted, userID, map[string]any{
+			"deleted_at": now,
+		})
+	})
+}
+
+// FindAccount returns the exportable account data for a non-deleted user
+func (repo *UserRepository) FindAccount(ctx context.Context, userID uuid.UUID) (*AccountData, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, tenant_id, email, full_name, username, is_active, email_verified, verified_at, created_at, updated_at
+		FROM users
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+	`
+
+	a, err := database.QueryOne[AccountData](ctx, repo.db, query, userID, tenantID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	return a, nil
+}
+
+// RecordExport writes a user.data_exported audit event
+func (repo *UserRepository) RecordExport(ctx context.Context, userID uuid.UUID, format string) error {
+	return audit.Record(ctx, repo.db, audit.Entry{
+		UserID:   userID,
+		Action:   audit.ActionDataExported,
+		Metadata: map[string]any{"format": format},
+	})
+}
@@ -1,26 +1,89 @@
 package private
 
 import (
-	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/apiversion"
+	"dvith.com/go-service-api/internal/app"
+	emailchange "dvith.com/go-service-api/internal/domain/authentication/email_change"
+	"dvith.com/go-service-api/internal/domain/authentication/oauth"
+	"dvith.com/go-service-api/internal/domain/user/exportjob"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/identity"
 	"dvith.com/go-service-api/internal/middleware"
-	"dvith.com/go-service-api/internal/security/token"
-	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/internal/routemeta"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/web"
 	"github.com/gofiber/fiber/v3"
 )
 
-func Routers(app fiber.Router, db *database.DBPool, cfg config.Config) {
-	// Initialize token manager for protected routes
-	tm := token.NewTokenManager(token.TokenConfig{
-		SecretKey:       cfg.JWTSecretKey,
-		ExpirationTime:  cfg.JWTExpirationTime,
-		RefreshDuration: cfg.JWTRefreshDuration,
-		Issuer:          cfg.JWTIssuer,
-	})
+// Module serves the authenticated user's profile, sessions and account
+type Module struct{}
 
-	// Create a group for protected routes that require authentication
-	withAuth := app.Group("/user", middleware.AuthMiddleware(tm))
+// Name identifies the module in the startup log
+func (Module) Name() string { return "user" }
+
+// Register adds the module's routes to router
+func (Module) Register(router fiber.Router, deps *app.Deps) {
+	// Create a group for protected routes that require authentication. The
+	// tenant is resolved first so AuthMiddleware can reject cross-tenant tokens;
+	// capture then records the traffic of users an admin is debugging.
+	withAuth := router.Group("/user", middleware.TenantResolver(deps.Tenants), middleware.AuthMiddleware(deps.TokenManager), middleware.CurrentUser(deps.Stores.Users), deps.Capture.Middleware())
 
 	// Protected routes (require valid access token)
-	withAuth.Get("/profile", ProfileHandler(db))
+	var accounts AccountStore = NewUserRepository(deps.DB)
+	if deps.Stores.Accounts != nil {
+		accounts = deps.Stores.Accounts
+	}
+	// v2 wraps the profile in its envelope; see apiversion.Envelope
+	withAuth.Get("/profile", apiversion.Handlers{
+		apiversion.V1: ProfileHandler(),
+		apiversion.V2: ProfileV2Handler(),
+	}.Handler()).Name("user.profile")
+	policy := model.UsernamePolicy{Reservation: deps.Cfg.UsernameReservation, MaxRenames: deps.Cfg.UsernameMaxRenames}
+	withAuth.Patch("/profile", middleware.ValidateBody[UpdateProfileRequest](), UpdateProfileHandler(deps.Stores.Users, policy)).Name("user.profile.update")
+	withAuth.Delete("/account", DeleteAccountHandler(accounts)).Name("user.delete")
+	changeService := emailchange.NewService(emailchange.NewPgRepository(deps.DB), deps.Stores.Users, deps.Mailer, deps.Cfg.TokenTTLs.EmailChange, web.EmailChangeLinks(deps.Cfg)).WithHasher(deps.Hasher).WithClock(deps.Clock)
+	withAuth.Post("/change-email", middleware.ValidateBody[emailchange.ChangeEmailRequest](), emailchange.RequestChangeHandler(changeService)).Name("user.change_email")
+	withAuth.Get("/preferences", PreferencesHandler(deps.Stores.Preferences)).Name("user.preferences")
+	withAuth.Patch("/preferences", middleware.ValidateBody[UpdatePreferencesRequest](), UpdatePreferencesHandler(deps.Stores.Preferences)).Name("user.preferences.update")
+	exportSource := NewExportSource(deps.DB)
+	withAuth.Get("/export", ExportHandler(exportSource, deps.Cache, deps.Clock)).Name("user.export")
+	// Heavy accounts export in the background; the download link is signed,
+	// so its route sits outside /user and needs no access token
+	exportJobs := exportjob.NewService(deps.Stores.ExportJobs, deps.Storage, deps.Links, ExportBuilder(exportSource, deps.Clock)).WithClock(deps.Clock)
+	withAuth.Post("/export", exportjob.StartHandler(exportJobs)).Name("user.export.start")
+	withAuth.Get("/export/:job_id",
+		middleware.ValidateParams(map[string]middleware.Rule{"job_id": middleware.UUIDRule()}),
+		exportjob.StatusHandler(exportJobs),
+	).Name("user.export.status")
+	router.Get(exportjob.DownloadRoute, middleware.TenantResolver(deps.Tenants), exportjob.DownloadHandler(exportJobs)).Name("user.export.download")
+	deps.Runner.Schedule(exportjob.NewWorker(exportJobs), exportjob.WorkerInterval)
+	deps.Runner.Schedule(exportjob.NewCleanupJob(exportJobs), exportjob.CleanupInterval)
+	withAuth.Get("/sessions", SessionsHandler(deps.Stores.Sessions)).Name("user.sessions")
+	withAuth.Delete("/sessions/:session_id",
+		middleware.ValidateParams(map[string]middleware.Rule{"session_id": middleware.UUIDRule()}),
+		RevokeSessionHandler(deps.Stores.Sessions, deps.Revocations),
+	).Name("user.sessions.revoke")
+
+	// Linked OAuth identities; linking needs at least one provider configured
+	if providers := oauth.ProvidersFromConfig(deps.Cfg); len(providers) > 0 {
+		oauthService := oauth.NewService(model.NewUserRepository(deps.DB), identity.NewRepository(deps.DB), session.NewRepository(deps.DB), deps.TokenManager, deps.Cache, providers...)
+		withAuth.Get("/identities", oauth.IdentitiesHandler(oauthService)).Name("user.identities")
+		withAuth.Get("/identities/:provider/link", oauth.StartLinkHandler(oauthService)).Name("user.identities.link.start")
+		withAuth.Post("/identities/:provider/link", middleware.ValidateBody[oauth.LinkIdentityRequest](), oauth.LinkHandler(oauthService)).Name("user.identities.link")
+		withAuth.Delete("/identities/:provider", oauth.UnlinkHandler(oauthService)).Name("user.identities.unlink")
+	}
 	// Add more protected routes here as needed
+
+	deps.Routes.Describe(routemeta.Route{Name: "user.profile", Rel: "profile", Summary: "Get the authenticated user's profile", RequireAuth: true})
+	deps.Routes.Describe(routemeta.Route{Name: "user.profile.update", Summary: "Change the authenticated user's full name or username", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "user.delete", Rel: "delete-account", Summary: "Delete the authenticated user's account", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "user.change_email", Rel: "change-email", Summary: "Email a confirmation link to a new address for the account", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "user.preferences", Rel: "preferences", Summary: "Get the authenticated user's email opt-ins, locale and time zone", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "user.preferences.update", Summary: "Change the authenticated user's email opt-ins, locale or time zone", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "user.export", Rel: "export", Summary: "Download a copy of the authenticated user's data", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "user.export.start", Summary: "Start building a copy of the authenticated user's data in the background", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "user.export.status", Summary: "Get the status and download link of a data export job", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "user.export.download", Summary: "Download the file of a data export job through its signed link"})
+	deps.Routes.Describe(routemeta.Route{Name: "user.sessions", Rel: "sessions", Summary: "List the authenticated user's sessions and devices", RequireAuth: true, Visibility: routemeta.Authenticated})
+	deps.Routes.Describe(routemeta.Route{Name: "user.sessions.revoke", Summary: "Revoke a session and the access tokens issued for it", RequireAuth: true, Visibility: routemeta.Authenticated})
 }
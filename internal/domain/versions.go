@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"dvith.com/go-service-api/internal/apiversion"
+	"github.com/gofiber/fiber/v3"
+)
+
+// versionGroup is the route group of one API version
+type versionGroup struct {
+	router  fiber.Router
+	version apiversion.Version
+}
+
+// versionRouter registers every route on the group of each API version,
+// so modules register once and the versions share their handlers, and the
+// services and jobs behind them. Routes named through it are named per
+// version with Version.RouteName. Routes that differ between versions
+// pick their handler with apiversion.Handlers.
+//
+// fiber names the route registered last, so a route is registered on the
+// first group at once and on the others when it is named or the next
+// route is registered; flush registers whatever is still pending.
+type versionRouter struct {
+	groups  []versionGroup
+	pending *[]func(name string)
+}
+
+var _ fiber.Router = (*versionRouter)(nil)
+
+// newVersionRouter creates a router registering on every group
+func newVersionRouter(groups []versionGroup) *versionRouter {
+	return &versionRouter{groups: groups, pending: new([]func(string))}
+}
+
+// add registers a route through register on every group
+func (r *versionRouter) add(register func(fiber.Router) fiber.Router) fiber.Router {
+	r.flush()
+	register(r.groups[0].router)
+	for _, g := range r.groups[1:] {
+		*r.pending = append(*r.pending, func(name string) {
+			register(g.router)
+			if name != "" {
+				g.router.Name(g.version.RouteName(name))
+			}
+		})
+	}
+	return r
+}
+
+// flush registers the routes still pending on the later groups
+func (r *versionRouter) flush() {
+	pending := *r.pending
+	*r.pending = nil
+	for _, register := range pending {
+		register("")
+	}
+}
+
+// Name names the route just registered in every version. Groups can't be
+// named, as their names would prefix the versioned route names again.
+func (r *versionRouter) Name(name string) fiber.Router {
+	if len(*r.pending) == 0 && len(r.groups) > 1 {
+		panic("domain: Name must follow the registration of a route")
+	}
+	r.groups[0].router.Name(r.groups[0].version.RouteName(name))
+	pending := *r.pending
+	*r.pending = nil
+	for _, register := range pending {
+		register(name)
+	}
+	return r
+}
+
+func (r *versionRouter) Use(args ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.Use(args...) })
+}
+
+func (r *versionRouter) Get(path string, handler any, handlers ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.Get(path, handler, handlers...) })
+}
+
+func (r *versionRouter) Head(path string, handler any, handlers ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.Head(path, handler, handlers...) })
+}
+
+func (r *versionRouter) Post(path string, handler any, handlers ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.Post(path, handler, handlers...) })
+}
+
+func (r *versionRouter) Put(path string, handler any, handlers ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.Put(path, handler, handlers...) })
+}
+
+func (r *versionRouter) Delete(path string, handler any, handlers ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.Delete(path, handler, handlers...) })
+}
+
+func (r *versionRouter) Connect(path string, handler any, handlers ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.Connect(path, handler, handlers...) })
+}
+
+func (r *versionRouter) Options(path string, handler any, handlers ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.Options(path, handler, handlers...) })
+}
+
+func (r *versionRouter) Trace(path string, handler any, handlers ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.Trace(path, handler, handlers...) })
+}
+
+func (r *versionRouter) Patch(path string, handler any, handlers ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.Patch(path, handler, handlers...) })
+}
+
+func (r *versionRouter) Add(methods []string, path string, handler any, handlers ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.Add(methods, path, handler, handlers...) })
+}
+
+func (r *versionRouter) All(path string, handler any, handlers ...any) fiber.Router {
+	return r.add(func(g fiber.Router) fiber.Router { return g.All(path, handler, handlers...) })
+}
+
+// Group creates the group under prefix in every version
+func (r *versionRouter) Group(prefix string, handlers ...any) fiber.Router {
+	r.flush()
+	groups := make([]versionGroup, len(r.groups))
+	for i, g := range r.groups {
+		groups[i] = versionGroup{router: g.router.Group(prefix, handlers...), version: g.version}
+	}
+	return &versionRouter{groups: groups, pending: r.pending}
+}
+
+// Route calls fn with the group under prefix. Groups can't be named.
+func (r *versionRouter) Route(prefix string, fn func(router fiber.Router), name ...string) fiber.Router {
+	if len(name) > 0 {
+		panic("domain: versioned groups can't be named")
+	}
+	group := r.Group(prefix)
+	fn(group)
+	return group
+}
+
+// RouteChain isn't supported, as its routes can't be fanned out one by one
+func (r *versionRouter) RouteChain(path string) fiber.Register {
+	panic("domain: RouteChain isn't supported on versioned routers")
+}
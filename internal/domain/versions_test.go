@@ -0,0 +1,143 @@
+package domain_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dvith.com/go-service-api/internal/apiversion"
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/domain"
+	"dvith.com/go-service-api/internal/testutil"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// versionedModule serves /shared the same in every version and /shape
+// differently in v2
+type versionedModule struct {
+	registrations *int
+}
+
+func (versionedModule) Name() string { return "versioned" }
+
+func (m versionedModule) Register(r fiber.Router, deps *app.Deps) {
+	*m.registrations++
+	r.Get("/shared", func(c fiber.Ctx) error {
+		return c.SendString("shared in " + string(apiversion.FromCtx(c)))
+	}).Name("versioned.shared")
+	group := r.Group("/things")
+	group.Get("/shape", apiversion.Handlers{
+		apiversion.V1: func(c fiber.Ctx) error { return c.SendString("flat") },
+		apiversion.V2: func(c fiber.Ctx) error { return c.SendString("enveloped") },
+	}.Handler()).Name("versioned.shape")
+}
+
+func newVersionedServer(t *testing.T, sunset string) (*fiber.App, int) {
+	t.Helper()
+	cfg, err := config.LoadFromEnv()
+	require.NoError(t, err)
+	cfg.Env = "development"
+	cfg.DatabaseURL = ""
+	cfg.V1SunsetDate = sunset
+
+	registrations := 0
+	server := fiber.New()
+	domain.InitModules(server, app.NewDeps(nil, cfg), domain.NewRegistry(versionedModule{registrations: &registrations}))
+	return server, registrations
+}
+
+func get(t *testing.T, server *fiber.App, path string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := server.Test(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestVersions_BothTreesServe(t *testing.T) {
+	server, registrations := newVersionedServer(t, "")
+	assert.Equal(t, 1, registrations, "modules register once for every version")
+
+	_, body := get(t, server, "/api/v1/shared", nil)
+	assert.Equal(t, "shared in v1", body)
+	resp, body := get(t, server, "/api/v2/shared", nil)
+	assert.Equal(t, "shared in v2", body)
+	assert.Equal(t, "v2", resp.Header.Get(apiversion.HeaderAPIVersion))
+
+	_, body = get(t, server, "/api/v1/things/shape", nil)
+	assert.Equal(t, "flat", body)
+	_, body = get(t, server, "/api/v2/things/shape", nil)
+	assert.Equal(t, "enveloped", body)
+
+	resp, _ = get(t, server, "/api/v2/missing", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "each version has its fallback")
+
+	assert.Equal(t, "/api/v1/shared", server.GetRoute("versioned.shared").Path)
+	assert.Equal(t, "/api/v2/shared", server.GetRoute("v2.versioned.shared").Path)
+	assert.Equal(t, "/api/v2/things/shape", server.GetRoute("v2.versioned.shape").Path)
+}
+
+func TestVersions_AcceptVersionRewrites(t *testing.T) {
+	server, _ := newVersionedServer(t, "")
+
+	resp, body := get(t, server, "/api/v1/things/shape", http.Header{apiversion.HeaderAcceptVersion: {"latest"}})
+	assert.Equal(t, "enveloped", body)
+	assert.Equal(t, string(apiversion.Latest), resp.Header.Get(apiversion.HeaderAPIVersion))
+	assert.Contains(t, resp.Header.Get(fiber.HeaderVary), apiversion.HeaderAcceptVersion)
+
+	_, body = get(t, server, "/api/v2/things/shape", http.Header{apiversion.HeaderAcceptVersion: {"v1"}})
+	assert.Equal(t, "flat", body)
+
+	resp, _ = get(t, server, "/api/v1/things/shape", http.Header{apiversion.HeaderAcceptVersion: {"v7"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestVersions_V1DeprecationHeaders(t *testing.T) {
+	server, _ := newVersionedServer(t, "")
+	resp, _ := get(t, server, "/api/v1/shared", nil)
+	assert.Empty(t, resp.Header.Get("Deprecation"), "v1 isn't deprecated until V1_SUNSET_DATE is set")
+	assert.Empty(t, resp.Header.Get("Sunset"))
+
+	server, _ = newVersionedServer(t, "2027-06-30")
+	resp, _ = get(t, server, "/api/v1/shared", nil)
+	assert.Equal(t, "true", resp.Header.Get("Deprecation"))
+	assert.Equal(t, "Wed, 30 Jun 2027 00:00:00 GMT", resp.Header.Get("Sunset"))
+	assert.Equal(t, `</api/v2>; rel="successor-version"`, resp.Header.Get(fiber.HeaderLink))
+
+	resp, _ = get(t, server, "/api/v1/missing", nil)
+	assert.Equal(t, "true", resp.Header.Get("Deprecation"), "errors are announced too")
+
+	resp, _ = get(t, server, "/api/v2/shared", nil)
+	assert.Empty(t, resp.Header.Get("Deprecation"))
+	assert.Empty(t, resp.Header.Get("Sunset"))
+}
+
+func TestVersions_ProfileEnvelope(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	v1 := testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/profile", nil, user.AccessToken)
+	require.Equal(t, http.StatusOK, v1.Status, string(v1.Raw))
+	assert.Equal(t, user.ID, v1.Object("user")["id"])
+
+	v2 := testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v2/user/profile", nil, user.AccessToken)
+	require.Equal(t, http.StatusOK, v2.Status, string(v2.Raw))
+	assert.Nil(t, v2.Body["user"])
+	assert.Equal(t, user.ID, v2.Object("data")["id"])
+
+	home := testutil.Request(t, a, http.MethodGet, "/api/v2/", nil)
+	require.Equal(t, http.StatusOK, home.Status, string(home.Raw))
+	assert.Equal(t, "v2", home.String("version"))
+	signin, _ := home.Object("_links")["signin"].(map[string]any)
+	assert.Equal(t, "/api/v2/auth/signin", signin["href"], "links stay in the version")
+}
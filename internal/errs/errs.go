@@ -0,0 +1,156 @@
+// Package errs classifies errors that escape handlers into HTTP statuses.
+// ErrorHandler consults the translation table; domains extend it from init
+// with Register.
+package errs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PostgreSQL SQLSTATE codes the default translations and repositories
+// recognise
+const (
+	SQLStateUniqueViolation      = "23505"
+	SQLStateForeignKeyViolation  = "23503"
+	SQLStateSerializationFailure = "40001"
+	SQLStateDeadlockDetected     = "40P01"
+	SQLStateUndefinedTable       = "42P01"
+	SQLStateUndefinedColumn      = "42703"
+)
+
+// NotFoundError marks a missing resource. Repositories wrap pgx.ErrNoRows in
+// it when a miss is a client-visible 404 rather than a bug.
+type NotFoundError struct {
+	Resource string
+	Err      error
+}
+
+// NotFound wraps err as a missing resource
+func NotFound(resource string, err error) error {
+	return &NotFoundError{Resource: resource, Err: err}
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Resource + " not found"
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// Translation maps a class of errors to a response. Message is sent to the
+// client; when empty, 4xx responses use the error text and 5xx responses a
+// generic message so driver details never leak. RetryAfter, when set, is
+// sent as a Retry-After hint.
+type Translation struct {
+	Name       string
+	Match      func(err error) bool
+	Status     int
+	Code       string
+	Message    string
+	RetryAfter time.Duration
+}
+
+var (
+	mu         sync.RWMutex
+	registered []Translation
+)
+
+// defaults are consulted after every registered translation
+var defaults = []Translation{
+	{
+		Name: "timeout",
+		Match: func(err error) bool {
+			return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+		},
+		Status:  http.StatusServiceUnavailable,
+		Code:    "timeout",
+		Message: "the request timed out",
+	},
+	{
+		Name:   "not_found",
+		Match:  func(err error) bool { var nf *NotFoundError; return errors.As(err, &nf) },
+		Status: http.StatusNotFound,
+		Code:   "not_found",
+	},
+	{
+		Name:    "unique_violation",
+		Match:   func(err error) bool { return HasSQLState(err, SQLStateUniqueViolation) },
+		Status:  http.StatusConflict,
+		Code:    "conflict",
+		Message: "the resource already exists",
+	},
+	{
+		Name:    "foreign_key_violation",
+		Match:   func(err error) bool { return HasSQLState(err, SQLStateForeignKeyViolation) },
+		Status:  http.StatusUnprocessableEntity,
+		Code:    "unprocessable_entity",
+		Message: "a referenced resource does not exist",
+	},
+	{
+		// WithTxRetry already ran the transaction again and gave up
+		Name:       "tx_conflict",
+		Match:      func(err error) bool { return errors.Is(err, database.ErrTxConflict) },
+		Status:     http.StatusServiceUnavailable,
+		Code:       "tx_conflict",
+		Message:    "the request kept conflicting with concurrent updates, retry it later",
+		RetryAfter: time.Second,
+	},
+	{
+		Name: "serialization_failure",
+		Match: func(err error) bool {
+			return HasSQLState(err, SQLStateSerializationFailure) || HasSQLState(err, SQLStateDeadlockDetected)
+		},
+		Status:     http.StatusServiceUnavailable,
+		Code:       "serialization_failure",
+		Message:    "the request conflicted with a concurrent update, retry it",
+		RetryAfter: time.Second,
+	},
+}
+
+// Register adds a translation ahead of the defaults. Domains call it from
+// init; translations registered earlier win.
+func Register(t Translation) {
+	if t.Match == nil || t.Status == 0 {
+		panic("errs: translation " + t.Name + " needs Match and Status")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	registered = append(registered, t)
+}
+
+// Classify returns the first translation matching err
+func Classify(err error) (Translation, bool) {
+	if err == nil {
+		return Translation{}, false
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, t := range registered {
+		if t.Match(err) {
+			return t, true
+		}
+	}
+	for _, t := range defaults {
+		if t.Match(err) {
+			return t, true
+		}
+	}
+	return Translation{}, false
+}
+
+// HasSQLState reports whether err wraps a PostgreSQL error with the given
+// SQLSTATE code
+func HasSQLState(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
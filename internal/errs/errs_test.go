@@ -0,0 +1,82 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify_Defaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, "timeout"},
+		{"canceled", context.Canceled, http.StatusServiceUnavailable, "timeout"},
+		{"not found", NotFound("user", pgx.ErrNoRows), http.StatusNotFound, "not_found"},
+		{"unique", fmt.Errorf("save: %w", &pgconn.PgError{Code: SQLStateUniqueViolation}), http.StatusConflict, "conflict"},
+		{"foreign key", &pgconn.PgError{Code: SQLStateForeignKeyViolation}, http.StatusUnprocessableEntity, "unprocessable_entity"},
+		{"serialization", &pgconn.PgError{Code: SQLStateSerializationFailure}, http.StatusServiceUnavailable, "serialization_failure"},
+		{"deadlock", &pgconn.PgError{Code: SQLStateDeadlockDetected}, http.StatusServiceUnavailable, "serialization_failure"},
+		{"tx conflict", fmt.Errorf("%w after 3 attempts: %w", database.ErrTxConflict, &pgconn.PgError{Code: SQLStateSerializationFailure}), http.StatusServiceUnavailable, "tx_conflict"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Classify(tt.err)
+			require.True(t, ok)
+			assert.Equal(t, tt.status, got.Status)
+			assert.Equal(t, tt.code, got.Code)
+		})
+	}
+}
+
+func TestClassify_Unknown(t *testing.T) {
+	_, ok := Classify(errors.New("boom"))
+	assert.False(t, ok)
+
+	_, ok = Classify(pgx.ErrNoRows)
+	assert.False(t, ok, "a bare ErrNoRows is a bug, not a 404")
+
+	_, ok = Classify(nil)
+	assert.False(t, ok)
+}
+
+func TestRegister_TakesPrecedence(t *testing.T) {
+	t.Cleanup(func() { registered = nil })
+
+	errQuota := errors.New("quota exceeded")
+	Register(Translation{
+		Name:   "quota",
+		Match:  func(err error) bool { return errors.Is(err, errQuota) },
+		Status: http.StatusTooManyRequests,
+	})
+	Register(Translation{
+		Name:   "plan_unique",
+		Match:  func(err error) bool { return HasSQLState(err, SQLStateUniqueViolation) },
+		Status: http.StatusBadRequest,
+		Code:   "plan_exists",
+	})
+
+	got, ok := Classify(fmt.Errorf("charge: %w", errQuota))
+	require.True(t, ok)
+	assert.Equal(t, "quota", got.Name)
+
+	got, ok = Classify(&pgconn.PgError{Code: SQLStateUniqueViolation})
+	require.True(t, ok)
+	assert.Equal(t, "plan_exists", got.Code, "domain translations win over defaults")
+}
+
+func TestRegister_RequiresMatchAndStatus(t *testing.T) {
+	assert.Panics(t, func() { Register(Translation{Name: "broken"}) })
+}
@@ -0,0 +1,242 @@
+// Package events is an in-process bus for domain events. Services publish
+// what happened, such as a signup or a signin, and subscribers registered
+// at startup react to it, so audit, metrics and webhooks don't have to be
+// wired into every service.
+//
+// The bus is for side effects that may be lost on a crash. Records that
+// must commit with a mutation, such as the signup audit and outbox rows,
+// are still written in its transaction.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/metrics"
+)
+
+// Defaults for the delivery options
+const (
+	DefaultTimeout   = 2 * time.Second
+	DefaultQueueSize = 256
+)
+
+var (
+	handlerFailures = metrics.NewCounter("event_handler_failures_total", "Event subscribers that returned an error, panicked or timed out.", "event", "subscriber", "reason")
+	queueDropped    = metrics.NewCounter("event_queue_dropped_total", "Events dropped because an asynchronous subscriber's queue was full.", "event", "subscriber")
+)
+
+// errTimeout is logged when a synchronous subscriber overruns its timeout
+var errTimeout = errors.New("subscriber timed out")
+
+// Event is something that happened in the domain. Its name identifies the
+// type, e.g. "user.registered".
+type Event interface {
+	EventName() string
+}
+
+// Option configures how a subscriber is delivered events
+type Option func(*subscriber)
+
+// Sync delivers each event during Publish, waiting at most timeout for the
+// subscriber. This is the default, with DefaultTimeout.
+func Sync(timeout time.Duration) Option {
+	return func(s *subscriber) {
+		s.queue = nil
+		s.timeout = timeout
+	}
+}
+
+// Async delivers events from a queue of size on the subscriber's own
+// goroutine, so Publish never waits. Events arriving while the queue is
+// full are dropped and counted.
+func Async(size int) Option {
+	return func(s *subscriber) {
+		if size <= 0 {
+			size = DefaultQueueSize
+		}
+		s.queue = make(chan delivery, size)
+	}
+}
+
+type subscriber struct {
+	name    string
+	event   string
+	handle  func(ctx context.Context, e Event) error
+	timeout time.Duration
+	queue   chan delivery
+}
+
+type delivery struct {
+	ctx   context.Context
+	event Event
+}
+
+// Bus dispatches published events to their subscribers. A nil *Bus drops
+// every event, so services work without one.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[string][]*subscriber
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subs: make(map[string][]*subscriber)}
+}
+
+// On subscribes fn, named name in logs and metrics, to events of type T.
+// Subscribers are meant to be registered at startup, before Publish is
+// first called.
+func On[T Event](b *Bus, name string, fn func(ctx context.Context, e T) error, opts ...Option) {
+	var zero T
+	s := &subscriber{
+		name:    name,
+		event:   zero.EventName(),
+		timeout: DefaultTimeout,
+		handle: func(ctx context.Context, e Event) error {
+			return fn(ctx, e.(T))
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.timeout <= 0 {
+		s.timeout = DefaultTimeout
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[s.event] = append(b.subs[s.event], s)
+	if s.queue != nil {
+		b.wg.Add(1)
+		go b.drain(s)
+	}
+}
+
+// Publish delivers e to its subscribers: synchronous ones in turn, each
+// bounded by its timeout, asynchronous ones by queueing. Subscriber errors
+// and panics are logged and never reach the publisher.
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+
+	for _, s := range b.subs[e.EventName()] {
+		if s.queue == nil {
+			b.deliverSync(ctx, s, e)
+			continue
+		}
+		// Queued events outlive the request that published them
+		select {
+		case s.queue <- delivery{ctx: context.WithoutCancel(ctx), event: e}:
+		default:
+			queueDropped.Inc(s.event, s.name)
+			logger.Warn("event dropped, subscriber queue is full", map[string]any{
+				"event":      s.event,
+				"subscriber": s.name,
+			})
+		}
+	}
+}
+
+// Close stops accepting events and waits until the asynchronous
+// subscribers have worked through their queues or ctx is done
+func (b *Bus) Close(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, subs := range b.subs {
+			for _, s := range subs {
+				if s.queue != nil {
+					close(s.queue)
+				}
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliverSync runs s on its own goroutine so a subscriber that ignores its
+// context still can't hold the publisher past the timeout
+func (b *Bus) deliverSync(ctx context.Context, s *subscriber, e Event) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.deliver(ctx, s, e)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// A subscriber finishing just as the deadline hits has reported
+		// its own outcome
+		select {
+		case <-done:
+		default:
+			b.failed(s, "timeout", errTimeout)
+		}
+	}
+}
+
+// drain delivers an asynchronous subscriber's queue until Close
+func (b *Bus) drain(s *subscriber) {
+	defer b.wg.Done()
+	for d := range s.queue {
+		ctx, cancel := context.WithTimeout(d.ctx, s.timeout)
+		b.deliver(ctx, s, d.event)
+		cancel()
+	}
+}
+
+// deliver calls s, turning a panic into a logged failure
+func (b *Bus) deliver(ctx context.Context, s *subscriber, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.failed(s, "panic", fmt.Errorf("panic: %v", r))
+		}
+	}()
+	if err := s.handle(ctx, e); err != nil {
+		b.failed(s, "error", err)
+	}
+}
+
+func (b *Bus) failed(s *subscriber, reason string, err error) {
+	handlerFailures.Inc(s.event, s.name, reason)
+	logger.Error("event subscriber failed", map[string]any{
+		"event":      s.event,
+		"subscriber": s.name,
+		"reason":     reason,
+		"error":      err.Error(),
+	})
+}
@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capture collects the events of type T a subscriber received
+type capture[T Event] struct {
+	mu     sync.Mutex
+	events []T
+}
+
+func (c *capture[T]) handle(ctx context.Context, e T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, e)
+	return nil
+}
+
+func (c *capture[T]) received() []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]T(nil), c.events...)
+}
+
+func TestBus_DeliversTypedEvents(t *testing.T) {
+	bus := NewBus()
+	var registered capture[UserRegistered]
+	var signedIn capture[UserSignedIn]
+	On(bus, "registered", registered.handle)
+	On(bus, "signed-in", signedIn.handle)
+
+	e := UserRegistered{UserID: uuid.New(), TenantID: uuid.New(), Email: "jane@example.com", At: time.Now()}
+	bus.Publish(context.Background(), e)
+
+	assert.Equal(t, []UserRegistered{e}, registered.received())
+	assert.Empty(t, signedIn.received(), "subscribers only get their event type")
+}
+
+func TestBus_IsolatesFailingSubscribers(t *testing.T) {
+	bus := NewBus()
+	var before, after capture[UserSignedIn]
+	On(bus, "before", before.handle)
+	On(bus, "panics", func(ctx context.Context, e UserSignedIn) error {
+		panic("subscriber bug")
+	})
+	On(bus, "errors", func(ctx context.Context, e UserSignedIn) error {
+		return errors.New("downstream unavailable")
+	})
+	On(bus, "panics-async", func(ctx context.Context, e UserSignedIn) error {
+		panic("subscriber bug")
+	}, Async(1))
+	On(bus, "after", after.handle)
+
+	e := UserSignedIn{UserID: uuid.New(), SessionID: uuid.New()}
+	assert.NotPanics(t, func() { bus.Publish(context.Background(), e) })
+
+	assert.Equal(t, []UserSignedIn{e}, before.received())
+	assert.Equal(t, []UserSignedIn{e}, after.received(), "a panicking subscriber doesn't stop the ones after it")
+	assert.NoError(t, bus.Close(context.Background()), "a panicking async subscriber keeps draining")
+}
+
+func TestBus_SyncTimeout(t *testing.T) {
+	bus := NewBus()
+	release := make(chan struct{})
+	defer close(release)
+	On(bus, "stuck", func(ctx context.Context, e UserSignedIn) error {
+		// Ignores its context on purpose
+		<-release
+		return nil
+	}, Sync(20*time.Millisecond))
+
+	start := time.Now()
+	bus.Publish(context.Background(), UserSignedIn{})
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "the publisher only waits for the timeout")
+}
+
+func TestBus_AsyncQueue(t *testing.T) {
+	bus := NewBus()
+	release := make(chan struct{})
+	var got capture[UserSignedIn]
+	On(bus, "slow", func(ctx context.Context, e UserSignedIn) error {
+		<-release
+		assert.NoError(t, ctx.Err(), "queued events outlive the publisher's context")
+		return got.handle(ctx, e)
+	}, Async(2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	for range 4 {
+		bus.Publish(ctx, UserSignedIn{UserID: uuid.New()})
+	}
+	cancel()
+	assert.Less(t, time.Since(start), 100*time.Millisecond, "publish doesn't wait for async subscribers")
+
+	close(release)
+	require.NoError(t, bus.Close(context.Background()))
+	// One event is in flight and two are queued; the fourth is dropped
+	n := len(got.received())
+	assert.True(t, n == 2 || n == 3, "got %d events", n)
+
+	bus.Publish(context.Background(), UserSignedIn{})
+	assert.Len(t, got.received(), n, "events published after Close are dropped")
+}
+
+func TestBus_CloseTimesOut(t *testing.T) {
+	bus := NewBus()
+	release := make(chan struct{})
+	defer close(release)
+	On(bus, "stuck", func(ctx context.Context, e UserSignedIn) error {
+		<-release
+		return nil
+	}, Async(1))
+	bus.Publish(context.Background(), UserSignedIn{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bus.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestBus_Nil(t *testing.T) {
+	var bus *Bus
+	assert.NotPanics(t, func() { bus.Publish(context.Background(), UserSignedIn{}) })
+	assert.NoError(t, bus.Close(context.Background()))
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/outbox"
+	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/pkg/metrics"
+)
+
+// SubscribeAudit records signins and token refreshes in the audit log,
+// with the jti of the tokens issued. Registrations are audited in the
+// signup transaction, not here.
+func SubscribeAudit(b *Bus, q database.Querier) {
+	On(b, "audit", func(ctx context.Context, e UserSignedIn) error {
+		return audit.Record(ctx, q, audit.Entry{
+			UserID:    e.UserID,
+			Action:    audit.ActionUserSignedIn,
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Metadata: map[string]any{
+				"session_id":       e.SessionID.String(),
+				"access_token_id":  e.AccessTokenID,
+				"refresh_token_id": e.RefreshTokenID,
+			},
+		})
+	})
+	On(b, "audit", func(ctx context.Context, e TokenRefreshed) error {
+		return audit.Record(ctx, q, audit.Entry{
+			UserID:    e.UserID,
+			Action:    audit.ActionTokenRefreshed,
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Metadata: map[string]any{
+				"session_id":       e.SessionID.String(),
+				"parent_token_id":  e.ParentTokenID,
+				"access_token_id":  e.AccessTokenID,
+				"refresh_token_id": e.RefreshTokenID,
+			},
+		})
+	})
+}
+
+// SubscribeMetrics counts published user events on r
+func SubscribeMetrics(b *Bus, r *metrics.Registry) {
+	published := r.Counter("user_events_total", "User domain events published, by event.", "event")
+	On(b, "metrics", func(ctx context.Context, e UserRegistered) error {
+		published.Inc(e.EventName())
+		return nil
+	})
+	On(b, "metrics", func(ctx context.Context, e UserSignedIn) error {
+		published.Inc(e.EventName())
+		return nil
+	})
+}
+
+// SubscribeWebhooks queues signins on the outbox, which the webhook
+// publisher delivers. Registrations are queued in the signup transaction,
+// not here. The insert runs off the signin's path on a queue.
+func SubscribeWebhooks(b *Bus, q database.Querier) {
+	On(b, "webhook", func(ctx context.Context, e UserSignedIn) error {
+		return outbox.Enqueue(ctx, q, outbox.EventUserSignedIn, e.UserID, map[string]any{
+			"tenant_id":  e.TenantID,
+			"session_id": e.SessionID,
+			"client_id":  e.ClientID,
+		})
+	}, Async(DefaultQueueSize))
+}
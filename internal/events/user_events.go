@@ -0,0 +1,66 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event names
+const (
+	NameUserRegistered = "user.registered"
+	NameUserSignedIn   = "user.signed_in"
+	NameTokenRefreshed = "user.token_refreshed"
+)
+
+// UserRegistered is published once a signup has saved the new user
+type UserRegistered struct {
+	UserID    uuid.UUID
+	TenantID  uuid.UUID
+	Email     string
+	ClientID  string
+	IP        string
+	UserAgent string
+	At        time.Time
+}
+
+// EventName implements Event
+func (UserRegistered) EventName() string { return NameUserRegistered }
+
+// UserSignedIn is published when a password signin has opened a session
+// and issued its tokens
+type UserSignedIn struct {
+	UserID    uuid.UUID
+	TenantID  uuid.UUID
+	SessionID uuid.UUID
+	ClientID  string
+	// AccessTokenID and RefreshTokenID are the jti claims of the tokens
+	AccessTokenID  string
+	RefreshTokenID string
+	IP             string
+	UserAgent      string
+	At             time.Time
+}
+
+// EventName implements Event
+func (UserSignedIn) EventName() string { return NameUserSignedIn }
+
+// TokenRefreshed is published when a refresh token was rotated for a new
+// token pair. Replays within the grace window, which get the same pair,
+// are not published again.
+type TokenRefreshed struct {
+	UserID    uuid.UUID
+	TenantID  uuid.UUID
+	SessionID uuid.UUID
+	ClientID  string
+	// ParentTokenID is the jti of the refresh token presented
+	ParentTokenID  string
+	AccessTokenID  string
+	RefreshTokenID string
+	IP             string
+	UserAgent      string
+	At             time.Time
+}
+
+// EventName implements Event
+func (TokenRefreshed) EventName() string { return NameTokenRefreshed }
@@ -0,0 +1,94 @@
+package features
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Feature flags gating built-in route groups
+const (
+	AdminAPI = "admin_api"
+	Examples = "examples"
+)
+
+// ErrInvalidName is returned by Set for names that aren't lower_snake_case
+var ErrInvalidName = errors.New("feature name must be lower_snake_case")
+
+// NamePattern is the form every feature name must take
+var NamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Hook is called before a flag changes at runtime. Returning an error
+// vetoes the change.
+type Hook func(name string, enabled bool) error
+
+// Flags holds the feature flags in effect. They start from Config.Features
+// and can be flipped at runtime without a restart.
+type Flags struct {
+	mu      sync.RWMutex
+	enabled map[string]bool
+	hooks   []Hook
+}
+
+// NewFlags creates a flag set with the given features enabled
+func NewFlags(enabled []string) *Flags {
+	f := &Flags{enabled: make(map[string]bool, len(enabled))}
+	for _, name := range enabled {
+		f.enabled[name] = true
+	}
+	return f
+}
+
+// Enabled reports whether the named feature is on
+func (f *Flags) Enabled(name string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.enabled[name]
+}
+
+// OnChange registers a hook run before every runtime change
+func (f *Flags) OnChange(h Hook) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hooks = append(f.hooks, h)
+}
+
+// Set turns the named feature on or off, unless a hook vetoes the change
+func (f *Flags) Set(name string, enabled bool) error {
+	if !NamePattern.MatchString(name) {
+		return ErrInvalidName
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.enabled[name] == enabled {
+		return nil
+	}
+	for _, h := range f.hooks {
+		if err := h(name, enabled); err != nil {
+			return err
+		}
+	}
+
+	if enabled {
+		f.enabled[name] = true
+	} else {
+		delete(f.enabled, name)
+	}
+	return nil
+}
+
+// List returns the enabled features in name order
+func (f *Flags) List() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	names := make([]string, 0, len(f.enabled))
+	for name := range f.enabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
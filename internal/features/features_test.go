@@ -0,0 +1,45 @@
+package features
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlags_Set(t *testing.T) {
+	f := NewFlags([]string{AdminAPI})
+
+	assert.True(t, f.Enabled(AdminAPI))
+	assert.False(t, f.Enabled("signup_v2"))
+
+	assert.NoError(t, f.Set("signup_v2", true))
+	assert.True(t, f.Enabled("signup_v2"))
+	assert.Equal(t, []string{AdminAPI, "signup_v2"}, f.List())
+
+	assert.NoError(t, f.Set(AdminAPI, false))
+	assert.False(t, f.Enabled(AdminAPI))
+
+	assert.ErrorIs(t, f.Set("Signup-V2", true), ErrInvalidName)
+}
+
+func TestFlags_HookVeto(t *testing.T) {
+	f := NewFlags([]string{AdminAPI})
+	errLocked := errors.New("locked")
+
+	var calls int
+	f.OnChange(func(name string, enabled bool) error {
+		calls++
+		if name == AdminAPI && !enabled {
+			return errLocked
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, f.Set(AdminAPI, false), errLocked)
+	assert.True(t, f.Enabled(AdminAPI), "a vetoed change must not apply")
+
+	// Setting a flag to its current value is not a change
+	assert.NoError(t, f.Set(AdminAPI, true))
+	assert.Equal(t, 1, calls)
+}
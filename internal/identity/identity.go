@@ -0,0 +1,137 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/errs"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrNotFound is returned when no matching identity exists
+	ErrNotFound = errors.New("identity not found")
+	// ErrAlreadyLinked is returned when the provider account is linked to a
+	// user already, or the user already links an account of that provider
+	ErrAlreadyLinked = errors.New("identity is already linked")
+)
+
+// Identity represents a row in the identities table: an OAuth provider
+// account a user can sign in with
+type Identity struct {
+	ID              uuid.UUID `db:"id" json:"id"`
+	TenantID        uuid.UUID `db:"tenant_id" json:"-"`
+	UserID          uuid.UUID `db:"user_id" json:"-"`
+	Provider        string    `db:"provider" json:"provider"`
+	ProviderSubject string    `db:"provider_subject" json:"-"`
+	Email           string    `db:"email" json:"email,omitempty"`
+	LinkedAt        time.Time `db:"linked_at" json:"linked_at"`
+}
+
+const identityColumns = `id, tenant_id, user_id, provider, provider_subject, COALESCE(email, '') AS email, linked_at`
+
+// Repository reads and writes identities in the current tenant
+type Repository struct {
+	q database.Querier
+}
+
+// NewRepository creates an identity repository on q
+func NewRepository(q database.Querier) *Repository {
+	return &Repository{q: q}
+}
+
+// Create links i to its user, filling in its ID, tenant and link time
+func (repo *Repository) Create(ctx context.Context, i *Identity) error {
+	if i == nil {
+		return fmt.Errorf("identity cannot be nil")
+	}
+
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	i.ID = uuid.New()
+	i.TenantID = tenantID
+	i.LinkedAt = clock.Now()
+
+	query := `
+		INSERT INTO identities (id, tenant_id, user_id, provider, provider_subject, email, linked_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+	`
+
+	if _, err := repo.q.Exec(ctx, query, i.ID, i.TenantID, i.UserID, i.Provider, i.ProviderSubject, i.Email, i.LinkedAt); err != nil {
+		if errs.HasSQLState(err, errs.SQLStateUniqueViolation) {
+			return ErrAlreadyLinked
+		}
+		return fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	return nil
+}
+
+// FindBySubject returns the identity for the provider account
+func (repo *Repository) FindBySubject(ctx context.Context, provider, subject string) (*Identity, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + identityColumns + ` FROM identities WHERE tenant_id = $1 AND provider = $2 AND provider_subject = $3`
+
+	i, err := database.QueryOne[Identity](ctx, repo.q, query, tenantID, provider, subject)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to find identity: %w", err)
+	}
+	return i, nil
+}
+
+// ListByUser returns the user's identities, oldest link first
+func (repo *Repository) ListByUser(ctx context.Context, userID uuid.UUID) ([]Identity, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + identityColumns + ` FROM identities WHERE tenant_id = $1 AND user_id = $2 ORDER BY linked_at, id`
+
+	identities, err := database.QueryAll[Identity](ctx, repo.q, query, tenantID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query identities: %w", err)
+	}
+	return identities, nil
+}
+
+// Delete unlinks the user's identity for provider
+func (repo *Repository) Delete(ctx context.Context, userID uuid.UUID, provider string) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := repo.q.Exec(ctx, `DELETE FROM identities WHERE tenant_id = $1 AND user_id = $2 AND provider = $3`, tenantID, userID, provider)
+	if err != nil {
+		return fmt.Errorf("failed to delete identity: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteByUser removes every identity belonging to the user
+func (repo *Repository) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	if _, err := repo.q.Exec(ctx, `DELETE FROM identities WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete identities: %w", err)
+	}
+	return nil
+}
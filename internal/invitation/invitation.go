@@ -0,0 +1,223 @@
+// Package invitation stores the codes that signup requires while it is
+// invite only (Config.SignupRequiresInvite). A code can be redeemed up to
+// its max uses before it expires; only its SHA-256 is stored.
+package invitation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Redemption errors, reported to the client as distinct signup errors
+var (
+	ErrInvalid   = errors.New("invalid invitation code")
+	ErrExhausted = errors.New("invitation code has been used up")
+	ErrExpired   = errors.New("invitation code has expired")
+)
+
+// ErrNotFound is returned when revoking an invitation that doesn't exist in
+// the tenant or is already revoked
+var ErrNotFound = errors.New("invitation not found")
+
+// Invitation is a row in the invitations table
+type Invitation struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	TenantID  uuid.UUID  `db:"tenant_id" json:"-"`
+	CodeHash  string     `db:"code_hash" json:"-"`
+	CreatedBy *uuid.UUID `db:"created_by" json:"created_by,omitempty"`
+	MaxUses   int        `db:"max_uses" json:"max_uses"`
+	UsedCount int        `db:"used_count" json:"used_count"`
+	ExpiresAt time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+}
+
+// redeemable returns why inv can't be redeemed at now, if it can't
+func (inv *Invitation) redeemable(now time.Time) error {
+	switch {
+	case inv.RevokedAt != nil:
+		return ErrInvalid
+	case !inv.ExpiresAt.After(now):
+		return ErrExpired
+	case inv.UsedCount >= inv.MaxUses:
+		return ErrExhausted
+	default:
+		return nil
+	}
+}
+
+// NewCode returns a random code to hand out, 16 characters of base32
+func NewCode() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate invitation code: %w", err)
+	}
+	return base32.StdEncoding.EncodeToString(raw), nil
+}
+
+// HashCode is the stored form of code. Case and surrounding spaces are
+// ignored, as codes are often typed in.
+func HashCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// Store persists invitations in the current tenant
+type Store interface {
+	// Create inserts inv
+	Create(ctx context.Context, inv *Invitation) error
+	// List returns the tenant's invitations, newest first
+	List(ctx context.Context) ([]Invitation, error)
+	// Revoke stops the invitation with the given ID from being redeemed
+	Revoke(ctx context.Context, id uuid.UUID) error
+}
+
+var (
+	_ Store = (*Repository)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+const columns = `id, tenant_id, code_hash, created_by, max_uses, used_count, expires_at, revoked_at, created_at`
+
+// Repository reads and writes invitations in the current tenant
+type Repository struct {
+	q   database.Querier
+	now func() time.Time
+}
+
+// NewRepository creates an invitation repository on q. Created on the
+// signup transaction, Redeem commits or rolls back with the new user.
+func NewRepository(q database.Querier) *Repository {
+	return &Repository{q: q, now: clock.Now}
+}
+
+// WithClock replaces the time source for timestamps, for tests
+func (repo *Repository) WithClock(now func() time.Time) *Repository {
+	repo.now = now
+	return repo
+}
+
+// Create implements Store
+func (repo *Repository) Create(ctx context.Context, inv *Invitation) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+	inv.TenantID = tenantID
+	if inv.ID == uuid.Nil {
+		inv.ID = uuid.New()
+	}
+	inv.CreatedAt = repo.now()
+
+	query := `
+		INSERT INTO invitations (id, tenant_id, code_hash, created_by, max_uses, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := repo.q.Exec(ctx, query, inv.ID, inv.TenantID, inv.CodeHash, inv.CreatedBy, inv.MaxUses, inv.ExpiresAt.UTC(), inv.CreatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to create invitation: %w", err)
+	}
+	return nil
+}
+
+// List implements Store
+func (repo *Repository) List(ctx context.Context) ([]Invitation, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + columns + ` FROM invitations WHERE tenant_id = $1 ORDER BY created_at DESC, id`
+	invs, err := database.QueryAll[Invitation](ctx, repo.q, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	return invs, nil
+}
+
+// Revoke implements Store
+func (repo *Repository) Revoke(ctx context.Context, id uuid.UUID) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE invitations SET revoked_at = $3 WHERE id = $1 AND tenant_id = $2 AND revoked_at IS NULL`
+	tag, err := repo.q.Exec(ctx, query, id, tenantID, repo.now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Check reports why the invitation with codeHash couldn't be redeemed at
+// now, without redeeming it
+func (repo *Repository) Check(ctx context.Context, codeHash string, now time.Time) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT ` + columns + ` FROM invitations WHERE tenant_id = $1 AND code_hash = $2`
+	inv, err := database.QueryOne[Invitation](ctx, repo.q, query, tenantID, codeHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load invitation: %w", err)
+	}
+	return inv.redeemable(now)
+}
+
+// Redeem counts one use of the invitation with codeHash at now. The
+// conditional update locks the row, so concurrent signups can't redeem
+// more uses than the invitation has. When it matches nothing, the row is
+// read to tell the caller why.
+func (repo *Repository) Redeem(ctx context.Context, codeHash string, now time.Time) (*Invitation, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE invitations SET used_count = used_count + 1
+		WHERE tenant_id = $1 AND code_hash = $2
+			AND revoked_at IS NULL AND expires_at > $3 AND used_count < max_uses
+		RETURNING ` + columns
+	inv, err := database.QueryOne[Invitation](ctx, repo.q, query, tenantID, codeHash, now.UTC())
+	if err == nil {
+		return inv, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to redeem invitation: %w", err)
+	}
+
+	query = `SELECT ` + columns + ` FROM invitations WHERE tenant_id = $1 AND code_hash = $2`
+	inv, err = database.QueryOne[Invitation](ctx, repo.q, query, tenantID, codeHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invitation: %w", err)
+	}
+	if err := inv.redeemable(now); err != nil {
+		return nil, err
+	}
+	// Changed between the two statements, such as by a concurrent signup
+	return nil, ErrExhausted
+}
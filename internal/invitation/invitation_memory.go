@@ -0,0 +1,143 @@
+package invitation
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps invitations in memory for development mode and tests
+type MemoryStore struct {
+	mu          sync.Mutex
+	invitations map[uuid.UUID]*Invitation
+	now         func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory invitation store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{invitations: map[uuid.UUID]*Invitation{}, now: clock.Now}
+}
+
+// WithClock replaces the time source for timestamps, for tests
+func (m *MemoryStore) WithClock(now func() time.Time) *MemoryStore {
+	m.now = now
+	return m
+}
+
+// Create implements Store
+func (m *MemoryStore) Create(ctx context.Context, inv *Invitation) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+	inv.TenantID = tenantID
+	if inv.ID == uuid.Nil {
+		inv.ID = uuid.New()
+	}
+	inv.CreatedAt = m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *inv
+	m.invitations[inv.ID] = &cp
+	return nil
+}
+
+// List implements Store
+func (m *MemoryStore) List(ctx context.Context) ([]Invitation, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []Invitation
+	for _, inv := range m.invitations {
+		if inv.TenantID == tenantID {
+			out = append(out, *inv)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Revoke implements Store
+func (m *MemoryStore) Revoke(ctx context.Context, id uuid.UUID) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inv, ok := m.invitations[id]
+	if !ok || inv.TenantID != tenantID || inv.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := m.now()
+	inv.RevokedAt = &now
+	return nil
+}
+
+// Check reports why the invitation with codeHash couldn't be redeemed at
+// now, without redeeming it
+func (m *MemoryStore) Check(ctx context.Context, codeHash string, now time.Time) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inv := m.find(tenantID, codeHash)
+	if inv == nil {
+		return ErrInvalid
+	}
+	return inv.redeemable(now)
+}
+
+// Redeem counts one use of the invitation with codeHash at now if fn, run
+// while no other redemption can, succeeds. It stands in for redeeming on
+// the signup transaction: fn saves the user.
+func (m *MemoryStore) Redeem(ctx context.Context, codeHash string, now time.Time, fn func() error) (*Invitation, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inv := m.find(tenantID, codeHash)
+	if inv == nil {
+		return nil, ErrInvalid
+	}
+	if err := inv.redeemable(now); err != nil {
+		return nil, err
+	}
+	if err := fn(); err != nil {
+		return nil, err
+	}
+	inv.UsedCount++
+	out := *inv
+	return &out, nil
+}
+
+// find returns the tenant's invitation with codeHash; the caller holds mu
+func (m *MemoryStore) find(tenantID uuid.UUID, codeHash string) *Invitation {
+	for _, inv := range m.invitations {
+		if inv.TenantID == tenantID && inv.CodeHash == codeHash {
+			return inv
+		}
+	}
+	return nil
+}
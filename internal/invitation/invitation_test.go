@@ -0,0 +1,94 @@
+package invitation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+func newInvitation(t *testing.T, ctx context.Context, store *MemoryStore, code string, maxUses int, ttl time.Duration) *Invitation {
+	t.Helper()
+	inv := &Invitation{CodeHash: HashCode(code), MaxUses: maxUses, ExpiresAt: epoch.Add(ttl)}
+	require.NoError(t, store.Create(ctx, inv))
+	return inv
+}
+
+func noop() error { return nil }
+
+func TestMemoryStore_RedeemCountsUses(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	store := NewMemoryStore().WithClock(func() time.Time { return epoch })
+	newInvitation(t, ctx, store, "BETA2026", 2, time.Hour)
+
+	inv, err := store.Redeem(ctx, HashCode(" beta2026 "), epoch, noop)
+	require.NoError(t, err, "codes are matched ignoring case and spaces")
+	assert.Equal(t, 1, inv.UsedCount)
+
+	_, err = store.Redeem(ctx, HashCode("BETA2026"), epoch, func() error { return errors.New("email taken") })
+	assert.EqualError(t, err, "email taken")
+
+	_, err = store.Redeem(ctx, HashCode("BETA2026"), epoch, noop)
+	require.NoError(t, err, "a failed signup doesn't use up the code")
+	_, err = store.Redeem(ctx, HashCode("BETA2026"), epoch, noop)
+	assert.ErrorIs(t, err, ErrExhausted)
+
+	_, err = store.Redeem(ctx, HashCode("NOPE"), epoch, noop)
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = store.Redeem(tenant.WithID(context.Background(), uuid.New()), HashCode("BETA2026"), epoch, noop)
+	assert.ErrorIs(t, err, ErrInvalid, "codes are scoped to their tenant")
+}
+
+func TestMemoryStore_RedeemExpiredOrRevoked(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	store := NewMemoryStore().WithClock(func() time.Time { return epoch })
+	newInvitation(t, ctx, store, "SHORT", 5, time.Hour)
+	revoked := newInvitation(t, ctx, store, "REVOKED", 5, time.Hour)
+
+	_, err := store.Redeem(ctx, HashCode("SHORT"), epoch.Add(time.Hour), noop)
+	assert.ErrorIs(t, err, ErrExpired)
+
+	require.NoError(t, store.Revoke(ctx, revoked.ID))
+	assert.ErrorIs(t, store.Revoke(ctx, revoked.ID), ErrNotFound)
+	_, err = store.Redeem(ctx, HashCode("REVOKED"), epoch, noop)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	invs, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, invs, 2)
+}
+
+func TestMemoryStore_ConcurrentRedeemOfSingleUse(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	store := NewMemoryStore().WithClock(func() time.Time { return epoch })
+	newInvitation(t, ctx, store, "ONCE", 1, time.Hour)
+
+	var redeemed, exhausted atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Redeem(ctx, HashCode("ONCE"), epoch, noop)
+			switch {
+			case err == nil:
+				redeemed.Add(1)
+			case errors.Is(err, ErrExhausted):
+				exhausted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), redeemed.Load())
+	assert.Equal(t, int32(19), exhausted.Load())
+}
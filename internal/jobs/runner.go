@@ -0,0 +1,142 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+// ErrJobRunning is returned when a job is triggered while a previous run is
+// still in progress
+var ErrJobRunning = errors.New("job is already running")
+
+// ErrJobNotFound is returned when triggering a job that was never scheduled
+var ErrJobNotFound = errors.New("job not found")
+
+// Job is a unit of background work run periodically by the Runner
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+type entry struct {
+	job      Job
+	interval time.Duration
+	running  sync.Mutex
+}
+
+// Runner runs scheduled jobs on fixed intervals. Runs of the same job never
+// overlap: a tick that fires while the job is still running is skipped.
+type Runner struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	order   []string
+	wg      sync.WaitGroup
+	started bool
+}
+
+// NewRunner creates an empty job runner
+func NewRunner() *Runner {
+	return &Runner{entries: make(map[string]*entry)}
+}
+
+// Schedule registers job to run every interval once the runner is started.
+// Scheduling a name twice replaces the earlier registration.
+func (r *Runner) Schedule(job Job, interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.entries[job.Name()]; !exists {
+		r.order = append(r.order, job.Name())
+	}
+	r.entries[job.Name()] = &entry{job: job, interval: interval}
+}
+
+// Jobs returns the names of scheduled jobs in registration order
+func (r *Runner) Jobs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.order...)
+}
+
+// Start launches a goroutine per scheduled job. It returns immediately; the
+// goroutines stop when ctx is cancelled. Use Wait to block until they exit.
+func (r *Runner) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started {
+		return
+	}
+	r.started = true
+
+	for _, name := range r.order {
+		e := r.entries[name]
+		if e.interval <= 0 {
+			continue
+		}
+		r.wg.Add(1)
+		go r.loop(ctx, e)
+	}
+}
+
+// Wait blocks until every job goroutine has exited
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+// Trigger runs the named job immediately in the calling goroutine
+func (r *Runner) Trigger(ctx context.Context, name string) error {
+	r.mu.Lock()
+	e, ok := r.entries[name]
+	r.mu.Unlock()
+	if !ok {
+		return ErrJobNotFound
+	}
+	return r.run(ctx, e)
+}
+
+func (r *Runner) loop(ctx context.Context, e *entry) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.run(ctx, e); err != nil && !errors.Is(err, ErrJobRunning) && ctx.Err() == nil {
+				logger.Error("scheduled job failed", map[string]any{
+					"job":   e.job.Name(),
+					"error": err.Error(),
+				})
+			}
+		}
+	}
+}
+
+func (r *Runner) run(ctx context.Context, e *entry) (err error) {
+	if !e.running.TryLock() {
+		return ErrJobRunning
+	}
+	defer e.running.Unlock()
+
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			logger.Error("job panic", map[string]any{"job": e.job.Name(), "panic": p})
+			err = errors.New("job panicked")
+		}
+		logger.Debug("job finished", map[string]any{
+			"job":      e.job.Name(),
+			"duration": time.Since(start).String(),
+		})
+	}()
+
+	return e.job.Run(ctx)
+}
@@ -1,22 +1,75 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
+	"dvith.com/go-service-api/internal/requestmeta"
+	"dvith.com/go-service-api/internal/security/authmetrics"
 	"dvith.com/go-service-api/internal/security/token"
 	"dvith.com/go-service-api/pkg/logger"
 	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 // Context key constants
 const (
-	ContextKeyUserID = "user_id"
+	ContextKeyUserID    = "user_id"
+	ContextKeyRoles     = "roles"
+	ContextKeySessionID = "session_id"
+	ContextKeyClaims    = "claims"
 )
 
-// AuthMiddleware validates JWT access token from Authorization header
-func AuthMiddleware(tm *token.TokenManager) fiber.Handler {
+// Token expiry headers set by AuthMiddleware, so clients can schedule a
+// refresh without decoding the JWT
+const (
+	HeaderTokenExpiresAt = "X-Token-Expires-At"
+	HeaderTokenExpiresIn = "X-Token-Expires-In"
+)
+
+// authMetrics records the tokens AuthMiddleware rejects. Tests swap it for
+// one on their own registry.
+var authMetrics = authmetrics.Default
+
+// Errors of the 401s AuthMiddlewareWith answers for tokens that are valid
+// but don't meet the route group's AuthOptions
+const (
+	// ErrorStaleToken is resolved by refreshing the token
+	ErrorStaleToken = "stale_token"
+	// ErrorReauthenticationRequired is resolved by signing in again, as
+	// refreshed tokens aren't fresh
+	ErrorReauthenticationRequired = "reauthentication_required"
+)
+
+// AuthOptions are the constraints a route group puts on access tokens
+type AuthOptions struct {
+	// Audiences, when given, must include one the token carries
+	Audiences []string
+	// MaxTokenAge rejects tokens issued longer ago, by their iat claim,
+	// even if they haven't expired. 0 accepts any age.
+	MaxTokenAge time.Duration
+	// RequireFresh rejects tokens not issued on direct password
+	// authentication, see token.WithFresh
+	RequireFresh bool
+	// RejectForeign rejects the tokens of token.TokenConfig's additional
+	// issuers, which are otherwise accepted on safe methods only
+	RejectForeign bool
+}
+
+// AuthMiddleware validates JWT access token from Authorization header. When
+// audiences are given, the token must carry at least one of them.
+func AuthMiddleware(tm *token.TokenManager, audiences ...string) fiber.Handler {
+	return AuthMiddlewareWith(tm, AuthOptions{Audiences: audiences})
+}
+
+// AuthMiddlewareWith is AuthMiddleware for route groups with stricter
+// constraints on tokens, such as the admin API
+func AuthMiddlewareWith(tm *token.TokenManager, opts AuthOptions) fiber.Handler {
+	audiences := opts.Audiences
 	return func(c fiber.Ctx) error {
 		// Extract bearer token from authorization header
 		authHeader := c.Get("Authorization", "")
@@ -25,6 +78,7 @@ func AuthMiddleware(tm *token.TokenManager) fiber.Handler {
 				"path":   c.Path(),
 				"method": c.Method(),
 			})
+			authMetrics.TokenRejected(authmetrics.ReasonMissingToken)
 			return AuthErrorResponse(c, "missing authorization header")
 		}
 
@@ -35,6 +89,7 @@ func AuthMiddleware(tm *token.TokenManager) fiber.Handler {
 				"path":  c.Path(),
 				"error": err.Error(),
 			})
+			authMetrics.TokenRejected(authmetrics.ReasonInvalidToken)
 			return AuthErrorResponse(c, "invalid authorization header format")
 		}
 
@@ -45,11 +100,93 @@ func AuthMiddleware(tm *token.TokenManager) fiber.Handler {
 				"path":  c.Path(),
 				"error": err.Error(),
 			})
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				authMetrics.TokenRejected(authmetrics.ReasonExpiredToken)
+			} else {
+				authMetrics.TokenRejected(authmetrics.ReasonInvalidToken)
+			}
+			return AuthErrorResponse(c, "invalid or expired access token")
+		}
+
+		// Reject tokens issued to clients this route group does not serve
+		if len(audiences) > 0 && !hasAnyAudience(claims, audiences) {
+			logger.Warn("access token audience not allowed", map[string]any{
+				"path":      c.Path(),
+				"user_id":   claims.UserID.String(),
+				"client_id": claims.ClientID,
+			})
+			authMetrics.TokenRejected(authmetrics.ReasonRejected)
+			return ForbiddenResponse(c, "token not valid for this client")
+		}
+
+		// Other services' tokens only read, where they are accepted at all
+		if claims.Foreign() && (opts.RejectForeign || !isSafeMethod(c.Method())) {
+			logger.Warn("foreign access token not allowed", map[string]any{
+				"path":    c.Path(),
+				"method":  c.Method(),
+				"user_id": claims.UserID.String(),
+				"issuer":  claims.ForeignIssuer,
+			})
+			authMetrics.TokenRejected(authmetrics.ReasonRejected)
+			return ForbiddenResponse(c, foreignTokenMessage(opts.RejectForeign))
+		}
+
+		// Reject tokens issued for a different tenant than the one resolved
+		// for this request
+		if tenantID, err := GetTenantIDFromContext(c); err == nil && claims.TenantID != tenantID {
+			logger.Warn("access token used against another tenant", map[string]any{
+				"path":         c.Path(),
+				"user_id":      claims.UserID.String(),
+				"token_tenant": claims.TenantID.String(),
+				"tenant":       tenantID.String(),
+			})
+			authMetrics.TokenRejected(authmetrics.ReasonInvalidToken)
+			return AuthErrorResponse(c, "invalid or expired access token")
+		}
+
+		// Tokens of a revoked session stop working before they expire.
+		// Foreign tokens carry no session of ours.
+		if err := checkSession(tm, claims); err != nil {
+			logger.Warn("access token session rejected", map[string]any{
+				"path":       c.Path(),
+				"user_id":    claims.UserID.String(),
+				"session_id": claims.SessionID.String(),
+				"error":      err.Error(),
+			})
+			if errors.Is(err, token.ErrSessionRevoked) {
+				authMetrics.TokenRejected(authmetrics.ReasonRevokedToken)
+			} else {
+				authMetrics.TokenRejected(authmetrics.ReasonInvalidToken)
+			}
 			return AuthErrorResponse(c, "invalid or expired access token")
 		}
 
-		// Store user ID in context for use in handlers
-		c.Locals(ContextKeyUserID, claims.UserID)
+		// Route groups may want tokens younger than their lifetime, or ones
+		// a refresh didn't mint
+		if opts.MaxTokenAge > 0 {
+			if age := tm.TokenAge(claims); age > opts.MaxTokenAge {
+				logger.Warn("access token too old for route", map[string]any{
+					"path":    c.Path(),
+					"user_id": claims.UserID.String(),
+					"age":     age.String(),
+					"max_age": opts.MaxTokenAge.String(),
+				})
+				authMetrics.TokenRejected(authmetrics.ReasonStaleToken)
+				return tokenConstraintResponse(c, ErrorStaleToken, "access token is too old, refresh it")
+			}
+		}
+		if opts.RequireFresh && !claims.Fresh {
+			logger.Warn("access token not fresh", map[string]any{
+				"path":    c.Path(),
+				"user_id": claims.UserID.String(),
+			})
+			authMetrics.TokenRejected(authmetrics.ReasonNotFresh)
+			return tokenConstraintResponse(c, ErrorReauthenticationRequired, "sign in again to continue")
+		}
+
+		// Store the claims in context for use in handlers
+		storeClaims(c, claims)
+		setExpiryHeaders(c, tm, claims)
 
 		logger.Debug("user authenticated", map[string]any{
 			"user_id": claims.UserID.String(),
@@ -60,6 +197,118 @@ func AuthMiddleware(tm *token.TokenManager) fiber.Handler {
 	}
 }
 
+// tokenConstraintResponse is the 401 for a valid token that doesn't meet
+// the route group's AuthOptions, with the error telling clients what to do
+func tokenConstraintResponse(c fiber.Ctx, errorCode, msg string) error {
+	return Respond(c, fiber.StatusUnauthorized, ErrorResponse{
+		Error:   errorCode,
+		Message: msg,
+		Code:    fiber.StatusUnauthorized,
+	})
+}
+
+// foreignTokenMessage explains why a foreign token was rejected
+func foreignTokenMessage(rejected bool) string {
+	if rejected {
+		return "tokens of other services are not accepted here"
+	}
+	return "tokens of other services are read-only"
+}
+
+// checkSession is TokenManager.CheckSession for our own tokens
+func checkSession(tm *token.TokenManager, claims *token.Claims) error {
+	if claims.Foreign() {
+		return nil
+	}
+	return tm.CheckSession(claims.SessionID)
+}
+
+// RequireRole rejects authenticated requests whose token does not carry
+// role. It must be registered after AuthMiddleware. Tokens of other
+// services only pass it on safe methods, whatever roles they carry.
+func RequireRole(role string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if claims, _ := c.Locals(ContextKeyClaims).(*token.Claims); claims != nil && claims.Foreign() && !isSafeMethod(c.Method()) {
+			logger.Warn("foreign access token used for a write", map[string]any{
+				"path":   c.Path(),
+				"role":   role,
+				"issuer": claims.ForeignIssuer,
+			})
+			return ForbiddenResponse(c, foreignTokenMessage(false))
+		}
+
+		roles, _ := c.Locals(ContextKeyRoles).([]string)
+		for _, r := range roles {
+			if r == role {
+				return c.Next()
+			}
+		}
+
+		logger.Warn("missing required role", map[string]any{
+			"path": c.Path(),
+			"role": role,
+		})
+		return ForbiddenResponse(c, "insufficient permissions")
+	}
+}
+
+// OptionalAuth authenticates the request when it carries a valid bearer
+// token and otherwise continues anonymously. Invalid tokens are ignored
+// rather than rejected, so public routes keep working, and so are foreign
+// tokens on unsafe methods and tokens of another tenant than the request's.
+func OptionalAuth(tm *token.TokenManager) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		tokenString, err := extractBearerToken(c.Get("Authorization", ""))
+		if err != nil {
+			return c.Next()
+		}
+
+		claims, err := tm.ValidateAccessToken(tokenString)
+		if err != nil || checkSession(tm, claims) != nil {
+			return c.Next()
+		}
+		if claims.Foreign() && !isSafeMethod(c.Method()) {
+			return c.Next()
+		}
+		if tenantID, err := GetTenantIDFromContext(c); err == nil && claims.TenantID != tenantID {
+			return c.Next()
+		}
+
+		storeClaims(c, claims)
+		return c.Next()
+	}
+}
+
+// storeClaims stores the claims, and the user ID, roles and session from
+// them, in context
+func storeClaims(c fiber.Ctx, claims *token.Claims) {
+	c.Locals(ContextKeyClaims, claims)
+	c.Locals(ContextKeyUserID, claims.UserID)
+	requestmeta.SetUserID(c, claims.UserID)
+	c.Locals(ContextKeyRoles, claims.Roles)
+	if claims.SessionID != uuid.Nil {
+		c.Locals(ContextKeySessionID, claims.SessionID)
+	}
+}
+
+// setExpiryHeaders reports when the access token expires, by tm's clock
+func setExpiryHeaders(c fiber.Ctx, tm *token.TokenManager, claims *token.Claims) {
+	if claims.ExpiresAt == nil {
+		return
+	}
+	c.Set(HeaderTokenExpiresAt, claims.ExpiresAt.UTC().Format(time.RFC3339))
+	c.Set(HeaderTokenExpiresIn, strconv.FormatInt(int64(tm.ExpiresIn(claims).Seconds()), 10))
+}
+
+func hasAnyAudience(claims *token.Claims, audiences []string) bool {
+	for _, aud := range audiences {
+		if claims.HasAudience(aud) {
+			return true
+		}
+	}
+	return false
+}
+
 // extractBearerToken extracts the token from "Bearer <token>" header
 func extractBearerToken(authHeader string) (string, error) {
 	parts := strings.SplitN(authHeader, " ", 2)
@@ -88,3 +337,23 @@ func GetUserIDFromContext(c fiber.Ctx) (uuid.UUID, error) {
 
 	return userID, nil
 }
+
+// GetSessionIDFromContext retrieves the session the access token was issued
+// for. Tokens issued before the sid claim existed have none.
+func GetSessionIDFromContext(c fiber.Ctx) (uuid.UUID, error) {
+	sessionID, ok := c.Locals(ContextKeySessionID).(uuid.UUID)
+	if !ok {
+		return uuid.UUID{}, fmt.Errorf("session_id not found in context")
+	}
+	return sessionID, nil
+}
+
+// GetClaimsFromContext retrieves the access token claims stored in context
+// by AuthMiddleware or OptionalAuth
+func GetClaimsFromContext(c fiber.Ctx) (*token.Claims, error) {
+	claims, ok := c.Locals(ContextKeyClaims).(*token.Claims)
+	if !ok {
+		return nil, fmt.Errorf("claims not found in context")
+	}
+	return claims, nil
+}
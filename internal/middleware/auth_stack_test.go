@@ -0,0 +1,79 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// These run AuthMiddleware where production does: behind the shared stack,
+// on a route of the user module
+
+func TestAuthStack_ValidToken(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/profile", nil, user.AccessToken)
+	assert.Equal(t, http.StatusOK, resp.Status, string(resp.Raw))
+	assert.Equal(t, user.ID, resp.Object("user")["id"])
+}
+
+func TestAuthStack_RejectsMissingAndMalformedHeaders(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+
+	resp := testutil.Request(t, a, http.MethodGet, "/api/v1/user/profile", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Status)
+	assert.Equal(t, "unauthorized", resp.String("error"), "the error handler renders the failure")
+
+	for _, header := range []string{"Basic dXNlcm5hbWU6cGFzc3dvcmQ=", "dXNlcm5hbWU6cGFzc3dvcmQ=", "Bearer ", "Bearer"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/user/profile", nil)
+		req.Header.Set("Authorization", header)
+		res, err := a.Server.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode, header)
+	}
+}
+
+func TestAuthStack_RejectsInvalidToken(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/profile", nil, "invalid.token.string")
+	assert.Equal(t, http.StatusUnauthorized, resp.Status)
+}
+
+func TestAuthStack_RejectsExpiredToken(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+	user := testutil.SignupUser(t, a)
+
+	a.Clock.Advance(a.Deps.TokenManager.AccessTokenTTL() + time.Second)
+
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/profile", nil, user.AccessToken)
+	assert.Equal(t, http.StatusUnauthorized, resp.Status)
+}
+
+func TestAuthStack_RejectsTokenFromWrongKey(t *testing.T) {
+	t.Parallel()
+	a := testutil.NewTestApp(t, testutil.Options{})
+
+	other := token.NewTokenManager(token.TokenConfig{
+		SecretKey:      "a-different-secret-key-of-enough-length",
+		ExpirationTime: time.Hour,
+		Issuer:         a.Deps.Cfg.JWTIssuer,
+	}).WithClock(a.Clock.Now)
+	forged, err := other.GenerateAccessToken(uuid.New())
+	require.NoError(t, err)
+
+	resp := testutil.AuthenticatedRequest(t, a, http.MethodGet, "/api/v1/user/profile", nil, forged)
+	assert.Equal(t, http.StatusUnauthorized, resp.Status)
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"strings"
+
+	"dvith.com/go-service-api/pkg/baggage"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// contextKeyBaggageLog holds the log fields of the request's baggage
+const contextKeyBaggageLog = "baggage_log_fields"
+
+// BaggageConfig configures Baggage
+type BaggageConfig struct {
+	// Keys are the members accepted; the others are dropped, and not
+	// forwarded either
+	Keys []string
+	// LogKeys are the accepted members added, as baggage.<key>, to the
+	// request's slow request and error logs
+	LogKeys []string
+	// MaxBytes caps the header, baggage.MaxBytes when 0
+	MaxBytes int
+}
+
+// Baggage accepts the W3C baggage header of the gateway, keeping the
+// members named in cfg.Keys. They are put on the request context, where
+// outbound httpclient calls forward them, so it must run before
+// RequestContext derives the context handlers use. Malformed or oversized
+// baggage is ignored, never rejected.
+func Baggage(cfg BaggageConfig) fiber.Handler {
+	if cfg.MaxBytes <= 0 || cfg.MaxBytes > baggage.MaxBytes {
+		cfg.MaxBytes = baggage.MaxBytes
+	}
+	return func(c fiber.Ctx) error {
+		header := baggageHeader(c)
+		if header == "" {
+			return c.Next()
+		}
+		if len(header) > cfg.MaxBytes {
+			logger.Debug("ignoring oversized baggage", map[string]any{"path": c.Path(), "bytes": len(header)})
+			return c.Next()
+		}
+		b, err := baggage.Parse(header)
+		if err != nil {
+			logger.Debug("ignoring malformed baggage", map[string]any{"path": c.Path(), "error": err.Error()})
+			return c.Next()
+		}
+
+		b = b.Keep(cfg.Keys)
+		if b.Len() == 0 {
+			return c.Next()
+		}
+		c.SetContext(baggage.NewContext(c.Context(), b))
+
+		fields := map[string]any{}
+		for _, key := range cfg.LogKeys {
+			if v, ok := b.Get(key); ok {
+				fields["baggage."+key] = v
+			}
+		}
+		if len(fields) > 0 {
+			c.Locals(contextKeyBaggageLog, fields)
+		}
+		return c.Next()
+	}
+}
+
+// GetBaggage returns the baggage accepted by Baggage, empty without
+func GetBaggage(c fiber.Ctx) baggage.Baggage {
+	return baggage.FromContext(c.Context())
+}
+
+// baggageHeader joins the request's baggage headers, as a list split over
+// several headers is one list. Values are copied, as the baggage outlives
+// the request buffer Fiber reuses.
+func baggageHeader(c fiber.Ctx) string {
+	values := c.Request().Header.PeekAll(baggage.Header)
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, string(v))
+	}
+	return strings.Join(parts, ",")
+}
+
+// withBaggageFields adds the logged baggage members of the request to
+// fields
+func withBaggageFields(c fiber.Ctx, fields map[string]any) map[string]any {
+	extra, _ := c.Locals(contextKeyBaggageLog).(map[string]any)
+	for k, v := range extra {
+		fields[k] = v
+	}
+	return fields
+}
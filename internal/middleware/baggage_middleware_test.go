@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/baggage"
+	"dvith.com/go-service-api/pkg/httpclient"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaggage_KeepsAllowedMembers(t *testing.T) {
+	var got baggage.Baggage
+	app := fiber.New()
+	app.Use(Baggage(BaggageConfig{Keys: []string{"tier", "exp"}, MaxBytes: 64}), RequestContext(time.Second))
+	app.Get("/", func(c fiber.Ctx) error {
+		got = GetBaggage(c)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := []struct {
+		name    string
+		headers []string
+		want    string
+	}{
+		{"allowed members kept", []string{"tier=gold;src=gw,internal=1,exp=a%2Cb"}, "tier=gold;src=gw,exp=a%2Cb"},
+		{"headers are one list", []string{"tier=gold", "exp=42"}, "tier=gold,exp=42"},
+		{"nothing allowed", []string{"internal=1"}, ""},
+		{"no header", nil, ""},
+		{"malformed is ignored", []string{"tier=gold,bad key=1"}, ""},
+		{"bad encoding is ignored", []string{"tier=%zz"}, ""},
+		{"oversized is ignored", []string{"tier=" + strings.Repeat("g", 64)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = baggage.Baggage{}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, h := range tt.headers {
+				req.Header.Add(baggage.Header, h)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, fiber.StatusNoContent, resp.StatusCode, "baggage never fails a request")
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+
+	// The accessor is typed, and keys case-sensitive
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(baggage.Header, "tier=gold,Tier=silver")
+	_, err := app.Test(req)
+	require.NoError(t, err)
+	tier, ok := got.Get("tier")
+	assert.True(t, ok)
+	assert.Equal(t, "gold", tier)
+}
+
+func TestBaggage_ForwardedOnOutboundCalls(t *testing.T) {
+	forwarded := make(chan string, 1)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.Header.Get(baggage.Header)
+	}))
+	defer downstream.Close()
+	client := httpclient.New(httpclient.Options{Name: "downstream"})
+
+	app := fiber.New()
+	app.Use(Baggage(BaggageConfig{Keys: []string{"tier"}}), RequestContext(time.Second))
+	app.Get("/", func(c fiber.Ctx) error {
+		req, err := http.NewRequestWithContext(GetRequestContext(c), http.MethodGet, downstream.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(baggage.Header, "tier=gold%20plus;ttl=60, internal=secret")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "tier=gold%20plus;ttl=60", <-forwarded, "only allowed members travel on")
+}
+
+func TestBaggage_LogFields(t *testing.T) {
+	buf := captureWarnings(t)
+	app := fiber.New()
+	app.Use(ErrorHandler(), Baggage(BaggageConfig{Keys: []string{"tier", "exp"}, LogKeys: []string{"tier"}}))
+	app.Get("/", func(c fiber.Ctx) error { return errors.New("boom") })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(baggage.Header, "tier=gold,exp=42")
+	_, err := app.Test(req)
+	require.NoError(t, err)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry), buf.String())
+	assert.Equal(t, "request error", entry["msg"])
+	assert.Equal(t, "gold", entry["baggage.tier"])
+	assert.NotContains(t, entry, "baggage.exp", "only LogKeys are logged")
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// MaxJSONDepth is how deeply objects and arrays may nest in a JSON body.
+// No request body needs more than a few levels; the limit keeps a payload
+// of nested brackets from costing more than its size.
+const MaxJSONDepth = 32
+
+// Errors returned by BindBody and DecodeJSON. Each one is a malformed
+// request, answered with 400.
+var (
+	ErrBodyTooDeep     = fmt.Errorf("request body nests deeper than %d levels", MaxJSONDepth)
+	ErrInvalidBody     = errors.New("invalid request body")
+	ErrUnsupportedBody = errors.New("unsupported request body content type")
+)
+
+// BindBody decodes the request body into out like c.Bind().Body, but never
+// lets a hostile body reach a decoder that panics or recurses without
+// bound: JSON goes through DecodeJSON, forms and XML through Fiber's
+// binders with panics turned into ErrInvalidBody, and any other content
+// type, msgpack and CBOR included, is ErrUnsupportedBody.
+func BindBody(c fiber.Ctx, out any) (err error) {
+	switch ctype := c.RequestCtx().Request.Header.ContentType(); {
+	case isMIME(ctype, fiber.MIMEApplicationJSON):
+		return DecodeJSON(c.Body(), out)
+	case isMIME(ctype, fiber.MIMEApplicationForm), isMIME(ctype, fiber.MIMEMultipartForm),
+		isMIME(ctype, fiber.MIMEApplicationXML), isMIME(ctype, fiber.MIMETextXML):
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Warn("request body binder panicked", map[string]any{
+					"path":  c.Path(),
+					"panic": fmt.Sprint(r),
+				})
+				err = ErrInvalidBody
+			}
+		}()
+		return c.Bind().Body(out)
+	default:
+		return ErrUnsupportedBody
+	}
+}
+
+// DecodeJSON unmarshals data into out after checking that it nests no
+// deeper than MaxJSONDepth. A value of the wrong type, such as a number
+// where a string is expected, is an error naming the field rather than a
+// zero value.
+func DecodeJSON(data []byte, out any) error {
+	if err := checkJSONDepth(data); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return fmt.Errorf("%w: %s must be a %s, not a %s", ErrInvalidBody, typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidBody, err)
+	}
+	return nil
+}
+
+// checkJSONDepth scans data for brackets outside strings. It doesn't check
+// that data is valid JSON; json.Unmarshal does that afterwards.
+func checkJSONDepth(data []byte) error {
+	depth := 0
+	inString, escaped := false, false
+	for _, b := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch b {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case b == '"':
+			inString = true
+		case b == '{' || b == '[':
+			depth++
+			if depth > MaxJSONDepth {
+				return ErrBodyTooDeep
+			}
+		case b == '}' || b == ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// isMIME reports whether the Content-Type header ctype is mime, ignoring
+// parameters such as charset and letter case
+func isMIME(ctype []byte, mime string) bool {
+	if i := bytes.IndexByte(ctype, ';'); i >= 0 {
+		ctype = ctype[:i]
+	}
+	return bytes.EqualFold(bytes.TrimSpace(ctype), []byte(mime))
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindBody struct {
+	Email string `json:"email" xml:"email" form:"email"`
+	Tags  []any  `json:"tags" xml:"tags" form:"tags"`
+}
+
+func bindApp() *fiber.App {
+	app := fiber.New()
+	app.Post("/", func(c fiber.Ctx) error {
+		var req bindBody
+		if err := BindBody(c, &req); err != nil {
+			return ValidationErrorResponse(c, err.Error())
+		}
+		return c.SendString(req.Email)
+	})
+	return app
+}
+
+func postBody(t testing.TB, app *fiber.App, ctype string, body []byte) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, ctype)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestBindBody(t *testing.T) {
+	app := bindApp()
+	tests := []struct {
+		name   string
+		ctype  string
+		body   string
+		status int
+	}{
+		{"json", fiber.MIMEApplicationJSON, `{"email":"a@example.com"}`, http.StatusOK},
+		{"json with charset", "Application/JSON; charset=utf-8", `{"email":"a@example.com"}`, http.StatusOK},
+		{"form", fiber.MIMEApplicationForm, `email=a%40example.com`, http.StatusOK},
+		{"xml", fiber.MIMEApplicationXML, `<body><email>a@example.com</email></body>`, http.StatusOK},
+		{"number for a string", fiber.MIMEApplicationJSON, `{"email":42}`, http.StatusBadRequest},
+		{"object for a string", fiber.MIMEApplicationJSON, `{"email":{"$ne":""}}`, http.StatusBadRequest},
+		{"malformed json", fiber.MIMEApplicationJSON, `{"email":`, http.StatusBadRequest},
+		{"msgpack", fiber.MIMEApplicationMsgPack, "\x81\xa5email\xa1a", http.StatusBadRequest},
+		{"cbor", fiber.MIMEApplicationCBOR, "\xa1eemailaa", http.StatusBadRequest},
+		{"no content type", "", `{"email":"a@example.com"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := postBody(t, app, tt.ctype, []byte(tt.body))
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestDecodeJSON_Depth(t *testing.T) {
+	var out bindBody
+	within := `{"tags":` + strings.Repeat("[", MaxJSONDepth-1) + strings.Repeat("]", MaxJSONDepth-1) + `}`
+	assert.NoError(t, DecodeJSON([]byte(within), &out))
+
+	deeper := `{"tags":` + strings.Repeat("[", MaxJSONDepth) + strings.Repeat("]", MaxJSONDepth) + `}`
+	assert.ErrorIs(t, DecodeJSON([]byte(deeper), &out), ErrBodyTooDeep)
+
+	// An unknown field is parsed too, so it's bounded the same way
+	unknown := `{"other":` + strings.Repeat("[", 100000) + `}`
+	assert.ErrorIs(t, DecodeJSON([]byte(unknown), &out), ErrBodyTooDeep)
+
+	// Brackets inside strings don't count
+	quoted := `{"email":"` + strings.Repeat(`[{\"`, 100) + `"}`
+	require.NoError(t, DecodeJSON([]byte(quoted), &out))
+	assert.Equal(t, strings.Repeat(`[{"`, 100), out.Email)
+}
+
+func TestDecodeJSON_TypeMismatch(t *testing.T) {
+	var out bindBody
+	err := DecodeJSON([]byte(`{"email":1e400}`), &out)
+	assert.ErrorIs(t, err, ErrInvalidBody)
+
+	err = DecodeJSON([]byte(`{"email":12}`), &out)
+	require.ErrorIs(t, err, ErrInvalidBody)
+	assert.Contains(t, err.Error(), "email must be a string, not a number")
+}
+
+func FuzzBindBody(f *testing.F) {
+	f.Add(fiber.MIMEApplicationJSON, []byte(`{"email":"a@example.com","tags":[1,"x",{"y":null}]}`))
+	f.Add(fiber.MIMEApplicationJSON, []byte(`{"email":7}`))
+	f.Add(fiber.MIMEApplicationForm, []byte(`email=a&tags=1&tags=2`))
+	f.Add(fiber.MIMEMultipartForm+"; boundary=x", []byte("--x\r\nContent-Disposition: form-data; name=\"email\"\r\n\r\na\r\n--x--\r\n"))
+	f.Add(fiber.MIMEApplicationXML, []byte(`<body><email>a</email><tags>1</tags></body>`))
+	f.Add(fiber.MIMEApplicationMsgPack, []byte("\x81\xa5email\xa1a"))
+
+	app := bindApp()
+	f.Fuzz(func(t *testing.T, ctype string, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, ctype)
+		resp, err := app.Test(req)
+		if err != nil {
+			// A header the HTTP parser rejects never reaches the binder
+			t.Skip(err)
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("content type %q: got status %d", ctype, resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// BodyLimit rejects request bodies larger than maxBytes with 413. The
+// server streams request bodies so that endpoints such as the user import
+// can read uploads incrementally; this restores the limit for everything
+// else. Paths listed in exempt keep their unbuffered stream.
+func BodyLimit(maxBytes int, exempt ...string) fiber.Handler {
+	skip := make(map[string]bool, len(exempt))
+	for _, p := range exempt {
+		skip[p] = true
+	}
+
+	return func(c fiber.Ctx) error {
+		if skip[c.Path()] {
+			return c.Next()
+		}
+
+		if c.Request().Header.ContentLength() > maxBytes {
+			return payloadTooLarge(c)
+		}
+
+		// Chunked bodies carry no length up front, so buffer up to the
+		// limit and hand the handler the buffered copy
+		if stream := c.Request().BodyStream(); stream != nil {
+			body, err := io.ReadAll(io.LimitReader(stream, int64(maxBytes)+1))
+			if err != nil {
+				return ValidationErrorResponse(c, "failed to read request body")
+			}
+			if len(body) > maxBytes {
+				return payloadTooLarge(c)
+			}
+			c.Request().SetBody(body)
+		}
+
+		return c.Next()
+	}
+}
+
+func payloadTooLarge(c fiber.Ctx) error {
+	return Respond(c, fiber.StatusRequestEntityTooLarge, ErrorResponse{
+		Error:   "payload_too_large",
+		Message: "request body too large",
+		Code:    fiber.StatusRequestEntityTooLarge,
+	})
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBodyLimitApp() *fiber.App {
+	app := fiber.New()
+	app.Use(BodyLimit(8, "/import"))
+	echo := func(c fiber.Ctx) error { return c.Send(c.Body()) }
+	app.Post("/echo", echo)
+	app.Post("/import", echo)
+	return app
+}
+
+func TestBodyLimit(t *testing.T) {
+	app := newBodyLimitApp()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("12345678")))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "12345678", string(body))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("123456789")))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestBodyLimit_Exempt(t *testing.T) {
+	app := newBodyLimitApp()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/import", strings.NewReader(strings.Repeat("x", 100))))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,135 @@
+// Package chain registers the cross-cutting middleware of a route group in
+// one canonical order, so a new layer can't accidentally be installed
+// ahead of the error handler or after the request timeout.
+package chain
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Layer names, in the order Apply registers them
+const (
+	LayerRequestID   = "request_id"
+	LayerRequestMeta = "request_meta"
+	LayerBaggage     = "baggage"
+	LayerVersion     = "api_version"
+	LayerTiming      = "timing"
+	LayerErrorRate   = "error_rate"
+	LayerNaming      = "json_naming"
+	LayerRecover     = "recover"
+	LayerLogger      = "logger"
+	LayerCORS        = "cors"
+	LayerNegotiate   = "negotiate"
+	LayerRateLimit   = "rate_limit"
+	LayerCSRF        = "csrf"
+	LayerFaults      = "fault_injection"
+	LayerBodyLimit   = "body_limit"
+	LayerTimeout     = "timeout"
+)
+
+// Stack declares a group's middleware by role. Nil slots are skipped.
+//
+// The order is fixed: the request ID comes first so every later layer can
+// log it, the client's metadata is captured once right after it, the
+// gateway's baggage is accepted before anything can log or call out, the API
+// version and its deprecation headers are set before anything can fail,
+// timing measures everything after it including the rendering of errors,
+// the error rate counts responses by the status they were rendered with,
+// JSON key naming rewrites bodies once they are rendered, recovery wraps
+// everything that can fail so errors are always rendered, CORS, content
+// negotiation, rate limiting and CSRF checks reject requests before bodies
+// are read,
+// injected faults only hit requests that would otherwise have been served,
+// and the timeout starts last so it only bounds the handler and the
+// route-level middleware such as auth.
+type Stack struct {
+	RequestID   fiber.Handler
+	RequestMeta fiber.Handler
+	Baggage     fiber.Handler
+	Version     fiber.Handler
+	Timing      fiber.Handler
+	ErrorRate   fiber.Handler
+	Naming      fiber.Handler
+	Recover     fiber.Handler
+	Logger      fiber.Handler
+	CORS        fiber.Handler
+	Negotiate   fiber.Handler
+	RateLimit   fiber.Handler
+	CSRF        fiber.Handler
+	Faults      fiber.Handler
+	BodyLimit   fiber.Handler
+	Timeout     fiber.Handler
+}
+
+// Layer is a named middleware of a Stack
+type Layer struct {
+	Name    string
+	Handler fiber.Handler
+}
+
+// Layers returns the non-nil layers in canonical order
+func (s Stack) Layers() []Layer {
+	all := []Layer{
+		{LayerRequestID, s.RequestID},
+		{LayerRequestMeta, s.RequestMeta},
+		{LayerBaggage, s.Baggage},
+		{LayerVersion, s.Version},
+		{LayerTiming, s.Timing},
+		{LayerErrorRate, s.ErrorRate},
+		{LayerNaming, s.Naming},
+		{LayerRecover, s.Recover},
+		{LayerLogger, s.Logger},
+		{LayerCORS, s.CORS},
+		{LayerNegotiate, s.Negotiate},
+		{LayerRateLimit, s.RateLimit},
+		{LayerCSRF, s.CSRF},
+		{LayerFaults, s.Faults},
+		{LayerBodyLimit, s.BodyLimit},
+		{LayerTimeout, s.Timeout},
+	}
+
+	out := make([]Layer, 0, len(all))
+	for _, l := range all {
+		if l.Handler != nil {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+var (
+	mu      sync.Mutex
+	applied = map[fiber.Router]bool{}
+)
+
+// Apply registers the stack's layers on router in canonical order and
+// returns their names. Applying a second stack to the same router panics,
+// since the layers would run twice in an order nobody declared.
+func (s Stack) Apply(router fiber.Router) []string {
+	mu.Lock()
+	if applied[router] {
+		mu.Unlock()
+		panic(fmt.Sprintf("chain: a middleware stack is already applied to %q", prefix(router)))
+	}
+	applied[router] = true
+	mu.Unlock()
+
+	layers := s.Layers()
+	names := make([]string, 0, len(layers))
+	for _, l := range layers {
+		router.Use(l.Handler)
+		names = append(names, l.Name)
+	}
+	return names
+}
+
+// prefix names router in panic messages
+func prefix(router fiber.Router) string {
+	if g, ok := router.(*fiber.Group); ok {
+		return g.Prefix
+	}
+	return "/"
+}
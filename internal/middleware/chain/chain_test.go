@@ -0,0 +1,121 @@
+package chain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"dvith.com/go-service-api/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder instruments middleware with the order they run in
+type recorder struct {
+	mu  sync.Mutex
+	seq []string
+}
+
+func (r *recorder) layer(name string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		r.mu.Lock()
+		r.seq = append(r.seq, name)
+		r.mu.Unlock()
+		return c.Next()
+	}
+}
+
+func get(t *testing.T, app *fiber.App, path string) *http.Response {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+	require.NoError(t, err)
+	return resp
+}
+
+func TestStack_CanonicalOrder(t *testing.T) {
+	rec := &recorder{}
+	app := fiber.New()
+	group := app.Group("/api")
+
+	// Fields are deliberately listed out of order
+	names := Stack{
+		Timeout:     rec.layer(LayerTimeout),
+		RateLimit:   rec.layer(LayerRateLimit),
+		CSRF:        rec.layer(LayerCSRF),
+		Recover:     rec.layer(LayerRecover),
+		CORS:        rec.layer(LayerCORS),
+		Negotiate:   rec.layer(LayerNegotiate),
+		BodyLimit:   rec.layer(LayerBodyLimit),
+		Faults:      rec.layer(LayerFaults),
+		Logger:      rec.layer(LayerLogger),
+		RequestID:   rec.layer(LayerRequestID),
+		Timing:      rec.layer(LayerTiming),
+		ErrorRate:   rec.layer(LayerErrorRate),
+		RequestMeta: rec.layer(LayerRequestMeta),
+		Baggage:     rec.layer(LayerBaggage),
+		Naming:      rec.layer(LayerNaming),
+		Version:     rec.layer(LayerVersion),
+	}.Apply(group)
+	group.Get("/ping", func(c fiber.Ctx) error {
+		rec.seq = append(rec.seq, "handler")
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	want := []string{LayerRequestID, LayerRequestMeta, LayerBaggage, LayerVersion, LayerTiming, LayerErrorRate, LayerNaming, LayerRecover, LayerLogger, LayerCORS, LayerNegotiate, LayerRateLimit, LayerCSRF, LayerFaults, LayerBodyLimit, LayerTimeout}
+	assert.Equal(t, want, names)
+
+	assert.Equal(t, fiber.StatusNoContent, get(t, app, "/api/ping").StatusCode)
+	assert.Equal(t, append(want, "handler"), rec.seq)
+}
+
+func TestStack_SkipsNilLayers(t *testing.T) {
+	rec := &recorder{}
+	app := fiber.New()
+
+	names := Stack{
+		Timeout: rec.layer(LayerTimeout),
+		Recover: rec.layer(LayerRecover),
+	}.Apply(app)
+	app.Get("/ping", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	get(t, app, "/ping")
+	assert.Equal(t, []string{LayerRecover, LayerTimeout}, names)
+	assert.Equal(t, names, rec.seq)
+}
+
+func TestStack_DuplicateApplyPanics(t *testing.T) {
+	app := fiber.New()
+	group := app.Group("/api")
+	Stack{Recover: middleware.ErrorHandler()}.Apply(group)
+
+	assert.PanicsWithValue(t, `chain: a middleware stack is already applied to "/api"`, func() {
+		Stack{Timeout: middleware.RequestContext(0)}.Apply(group)
+	})
+
+	// Another group is independent
+	assert.NotPanics(t, func() { Stack{Recover: middleware.ErrorHandler()}.Apply(app.Group("/v2")) })
+}
+
+// The recover layer wraps everything registered after it, so a failure in
+// any later layer still gets a JSON error
+func TestStack_RecoverWrapsLaterLayers(t *testing.T) {
+	app := fiber.New()
+	Stack{
+		Recover: middleware.ErrorHandler(),
+		RateLimit: func(c fiber.Ctx) error {
+			return fiber.NewError(fiber.StatusTooManyRequests, "slow down")
+		},
+	}.Apply(app)
+	app.Get("/ping", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	resp := get(t, app, "/ping")
+	assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+
+	var body middleware.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "too_many_requests", body.Error)
+	assert.Equal(t, "slow down", body.Message)
+}
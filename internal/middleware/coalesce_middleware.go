@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/gofiber/fiber/v3"
+	"golang.org/x/sync/singleflight"
+)
+
+// HeaderClientRequestID names a client-chosen ID that is the same on every
+// copy of one logical request, so double submits can be recognized
+const HeaderClientRequestID = "X-Client-Request-ID"
+
+// HeaderCoalesced is set to "true" on responses that were produced for an
+// earlier copy of the request and replayed
+const HeaderCoalesced = "X-Request-Coalesced"
+
+// maxClientRequestIDLength bounds HeaderClientRequestID
+const maxClientRequestIDLength = 128
+
+// Coalescer runs each request carrying a HeaderClientRequestID once: copies
+// arriving while it runs wait for its response, and copies arriving within
+// the window after it finished are sent that response again. Copies must
+// match in tenant, method, path and body; a different body with the same
+// ID runs as a request of its own.
+//
+// Responses are kept in process memory, so copies that reach another
+// instance run again.
+type Coalescer struct {
+	window   time.Duration
+	now      func() time.Time
+	inflight singleflight.Group
+
+	mu        sync.Mutex
+	responses map[string]coalescedResponse
+}
+
+// coalescedResponse is a response as the handler wrote it
+type coalescedResponse struct {
+	status    int
+	headers   [][2]string
+	body      []byte
+	expiresAt time.Time
+}
+
+// NewCoalescer creates a coalescer replaying responses for window
+func NewCoalescer(window time.Duration) *Coalescer {
+	return &Coalescer{window: window, now: clock.Now, responses: map[string]coalescedResponse{}}
+}
+
+// WithClock replaces the time source for expiring responses, for tests
+func (co *Coalescer) WithClock(now func() time.Time) *Coalescer {
+	co.now = now
+	return co
+}
+
+// Middleware coalesces the requests of the routes it is mounted on.
+// Requests without HeaderClientRequestID pass through.
+func (co *Coalescer) Middleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		id := c.Get(HeaderClientRequestID)
+		if id == "" || co.window <= 0 {
+			return c.Next()
+		}
+		if len(id) > maxClientRequestIDLength {
+			return ValidationErrorResponse(c, HeaderClientRequestID+" must not exceed 128 characters")
+		}
+
+		key := co.key(c, id)
+		if resp, ok := co.cached(key); ok {
+			return resp.write(c)
+		}
+
+		v, err, shared := co.inflight.Do(key, func() (any, error) {
+			resp, err := capture(c)
+			if err != nil {
+				return nil, err
+			}
+			// A retry after a server error should run again
+			if resp.status < fiber.StatusInternalServerError {
+				resp.expiresAt = co.now().Add(co.window)
+				co.store(key, resp)
+			}
+			return resp, nil
+		})
+		if err != nil || !shared {
+			// The handler ran on c itself, which holds its response already
+			return err
+		}
+		return v.(coalescedResponse).write(c)
+	}
+}
+
+// key identifies the copies of a request
+func (co *Coalescer) key(c fiber.Ctx, id string) string {
+	tenantID, _ := tenant.IDFromContext(GetRequestContext(c))
+	h := sha256.New()
+	for _, part := range []string{tenantID.String(), c.Method(), c.Path(), id} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (co *Coalescer) cached(key string) (coalescedResponse, bool) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	resp, ok := co.responses[key]
+	if !ok || !co.now().Before(resp.expiresAt) {
+		return coalescedResponse{}, false
+	}
+	return resp, true
+}
+
+// store saves resp under key and drops expired responses, which are few as
+// the window is short
+func (co *Coalescer) store(key string, resp coalescedResponse) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	now := co.now()
+	for k, r := range co.responses {
+		if !now.Before(r.expiresAt) {
+			delete(co.responses, k)
+		}
+	}
+	co.responses[key] = resp
+}
+
+// capture runs the rest of the chain on c and copies the response it
+// wrote, with only the headers set along the way
+func capture(c fiber.Ctx) (coalescedResponse, error) {
+	before := map[[2]string]bool{}
+	for k, v := range c.Response().Header.All() {
+		before[[2]string{string(k), string(v)}] = true
+	}
+
+	if err := c.Next(); err != nil {
+		return coalescedResponse{}, err
+	}
+
+	resp := coalescedResponse{
+		status: c.Response().StatusCode(),
+		body:   append([]byte(nil), c.Response().Body()...),
+	}
+	for k, v := range c.Response().Header.All() {
+		header := [2]string{string(k), string(v)}
+		if !before[header] && header[0] != fiber.HeaderContentLength {
+			resp.headers = append(resp.headers, header)
+		}
+	}
+	return resp, nil
+}
+
+// write sends the captured response on c
+func (resp coalescedResponse) write(c fiber.Ctx) error {
+	for _, h := range resp.headers {
+		c.Response().Header.Add(h[0], h[1])
+	}
+	c.Set(HeaderCoalesced, "true")
+	return c.Status(resp.status).Send(resp.body)
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type coalesceTest struct {
+	app   *fiber.App
+	clock *clock.Frozen
+	runs  atomic.Int64
+	// release, when set, blocks the handler until it is closed
+	release chan struct{}
+	status  int
+}
+
+func newCoalesceTest(t *testing.T) *coalesceTest {
+	t.Helper()
+	ct := &coalesceTest{clock: clock.NewFrozen(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)), status: fiber.StatusOK}
+	co := NewCoalescer(2 * time.Second).WithClock(ct.clock.Now)
+
+	ct.app = fiber.New()
+	ct.app.Post("/signin", co.Middleware(), func(c fiber.Ctx) error {
+		n := ct.runs.Add(1)
+		if ct.release != nil {
+			<-ct.release
+		}
+		c.Set("X-Run", strconv.FormatInt(n, 10))
+		return c.Status(ct.status).JSON(fiber.Map{"run": n})
+	})
+	return ct
+}
+
+func (ct *coalesceTest) post(t *testing.T, id, body string) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/signin", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if id != "" {
+		req.Header.Set(HeaderClientRequestID, id)
+	}
+	resp, err := ct.app.Test(req)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(raw)
+}
+
+func TestCoalescer_ConcurrentCopiesShareOneRun(t *testing.T) {
+	ct := newCoalesceTest(t)
+	ct.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 2)
+	headers := make([]http.Header, 2)
+	for i := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, body := ct.post(t, "req-1", `{"email":"a@example.com"}`)
+			bodies[i], headers[i] = body, resp.Header
+		}()
+	}
+	// Let both copies reach the coalescer before the first one finishes
+	require.Eventually(t, func() bool { return ct.runs.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(ct.release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), ct.runs.Load())
+	assert.Equal(t, bodies[0], bodies[1])
+	assert.JSONEq(t, `{"run":1}`, bodies[0])
+	for _, h := range headers {
+		assert.Equal(t, "1", h.Get("X-Run"), "headers set by the handler are replayed")
+		assert.True(t, strings.HasPrefix(h.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON))
+	}
+}
+
+func TestCoalescer_ReplaysWithinWindow(t *testing.T) {
+	ct := newCoalesceTest(t)
+
+	_, first := ct.post(t, "req-1", `{}`)
+	resp, again := ct.post(t, "req-1", `{}`)
+	assert.Equal(t, first, again)
+	assert.Equal(t, "true", resp.Header.Get(HeaderCoalesced))
+	assert.Equal(t, int64(1), ct.runs.Load())
+
+	ct.clock.Advance(2 * time.Second)
+	resp, _ = ct.post(t, "req-1", `{}`)
+	assert.Empty(t, resp.Header.Get(HeaderCoalesced))
+	assert.Equal(t, int64(2), ct.runs.Load(), "the response expires with the window")
+}
+
+func TestCoalescer_OnlyIdenticalCopiesAreCoalesced(t *testing.T) {
+	ct := newCoalesceTest(t)
+
+	ct.post(t, "req-1", `{"password":"a"}`)
+	ct.post(t, "req-1", `{"password":"b"}`)
+	ct.post(t, "req-2", `{"password":"a"}`)
+	ct.post(t, "", `{"password":"a"}`)
+	ct.post(t, "", `{"password":"a"}`)
+
+	assert.Equal(t, int64(5), ct.runs.Load())
+}
+
+func TestCoalescer_ServerErrorsAreNotReplayed(t *testing.T) {
+	ct := newCoalesceTest(t)
+	ct.status = fiber.StatusServiceUnavailable
+
+	ct.post(t, "req-1", `{}`)
+	ct.post(t, "req-1", `{}`)
+	assert.Equal(t, int64(2), ct.runs.Load())
+}
+
+func TestCoalescer_RejectsLongIDs(t *testing.T) {
+	ct := newCoalesceTest(t)
+
+	resp, _ := ct.post(t, strings.Repeat("x", 129), `{}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Zero(t, ct.runs.Load())
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"math"
+	"runtime"
+	"strconv"
+	"time"
+
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/metrics"
+	"github.com/gofiber/fiber/v3"
+)
+
+var (
+	concurrencyInFlight = metrics.NewGauge("http_concurrency_in_flight", "Requests running under a concurrency limit.", "path")
+	concurrencyQueued   = metrics.NewGauge("http_concurrency_queued", "Requests waiting for a concurrency limit slot.", "path")
+)
+
+// DefaultConcurrency is the ConcurrencyLimit used when none is given, 4
+// per GOMAXPROCS, enough to keep every CPU busy hashing
+func DefaultConcurrency() int {
+	return 4 * runtime.GOMAXPROCS(0)
+}
+
+// ConcurrencyLimit caps the handlers running at once behind it at n,
+// DefaultConcurrency when n is 0, for endpoints whose cost is memory rather
+// than time, such as Argon2 hashing. The routes given the same handler
+// share its n slots. Requests past the limit wait up to queueTimeout for a
+// slot and are then shed with a 503 and Retry-After. The in-flight and
+// queued requests of each route are exported as gauges.
+func ConcurrencyLimit(n int, queueTimeout time.Duration) fiber.Handler {
+	if n <= 0 {
+		n = DefaultConcurrency()
+	}
+	slots := make(chan struct{}, n)
+	retryAfter := strconv.Itoa(max(1, int(math.Ceil(queueTimeout.Seconds()))))
+
+	return func(c fiber.Ctx) error {
+		path := c.Route().Path
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			concurrencyQueued.Inc(path)
+			timer := time.NewTimer(queueTimeout)
+			select {
+			case slots <- struct{}{}:
+				timer.Stop()
+				concurrencyQueued.Dec(path)
+			case <-timer.C:
+				concurrencyQueued.Dec(path)
+				logger.Warn("concurrency limit reached, request shed", map[string]any{
+					"path":   c.Path(),
+					"method": c.Method(),
+					"limit":  n,
+				})
+				c.Set(fiber.HeaderRetryAfter, retryAfter)
+				return Respond(c, fiber.StatusServiceUnavailable, ErrorResponse{
+					Error:   statusMessage(fiber.StatusServiceUnavailable),
+					Message: "server is busy, try again later",
+					Code:    fiber.StatusServiceUnavailable,
+				})
+			case <-GetRequestContext(c).Done():
+				timer.Stop()
+				concurrencyQueued.Dec(path)
+				return GetRequestContext(c).Err()
+			}
+		}
+
+		concurrencyInFlight.Inc(path)
+		defer func() {
+			<-slots
+			concurrencyInFlight.Dec(path)
+		}()
+		return c.Next()
+	}
+}
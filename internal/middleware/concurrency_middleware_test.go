@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingHasher stands in for Argon2: every Hash call holds its slot until
+// release is closed
+type blockingHasher struct {
+	release chan struct{}
+	running atomic.Int64
+	done    atomic.Int64
+}
+
+func (h *blockingHasher) Hash() {
+	h.running.Add(1)
+	<-h.release
+	h.running.Add(-1)
+	h.done.Add(1)
+}
+
+func newConcurrencyApp(path string, n int, queueTimeout time.Duration) (*fiber.App, *blockingHasher) {
+	hasher := &blockingHasher{release: make(chan struct{})}
+	app := fiber.New()
+	app.Post(path, ConcurrencyLimit(n, queueTimeout), func(c fiber.Ctx) error {
+		hasher.Hash()
+		return c.SendStatus(fiber.StatusCreated)
+	})
+	return app, hasher
+}
+
+// postAsync posts to path in the background, sending the status on the
+// returned channel
+func postAsync(t *testing.T, app *fiber.App, path string) <-chan int {
+	status := make(chan int, 1)
+	go func() {
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, path, nil))
+		if !assert.NoError(t, err) {
+			status <- 0
+			return
+		}
+		status <- resp.StatusCode
+	}()
+	return status
+}
+
+func TestConcurrencyLimit_QueuesUntilSlotFrees(t *testing.T) {
+	const path = "/queue/signup"
+	app, hasher := newConcurrencyApp(path, 2, 500*time.Millisecond)
+
+	var statuses []<-chan int
+	for range 3 {
+		statuses = append(statuses, postAsync(t, app, path))
+	}
+	require.Eventually(t, func() bool {
+		return hasher.running.Load() == 2 && concurrencyQueued.Value(path) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, float64(2), concurrencyInFlight.Value(path))
+
+	close(hasher.release)
+	for _, s := range statuses {
+		assert.Equal(t, fiber.StatusCreated, <-s)
+	}
+	assert.Equal(t, int64(3), hasher.done.Load())
+	assert.Equal(t, float64(0), concurrencyInFlight.Value(path), "slots are released on completion")
+	assert.Equal(t, float64(0), concurrencyQueued.Value(path))
+}
+
+func TestConcurrencyLimit_ShedsAfterQueueTimeout(t *testing.T) {
+	const path = "/shed/signup"
+	app, hasher := newConcurrencyApp(path, 1, 20*time.Millisecond)
+
+	first := postAsync(t, app, path)
+	require.Eventually(t, func() bool { return hasher.running.Load() == 1 }, time.Second, time.Millisecond)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, path, nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get(fiber.HeaderRetryAfter))
+	assert.Equal(t, float64(0), concurrencyQueued.Value(path))
+
+	close(hasher.release)
+	assert.Equal(t, fiber.StatusCreated, <-first)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodPost, path, nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode, "the freed slot is reused")
+}
+
+func TestConcurrencyLimit_SharedAcrossRoutes(t *testing.T) {
+	hasher := &blockingHasher{release: make(chan struct{})}
+	limit := ConcurrencyLimit(1, 20*time.Millisecond)
+	app := fiber.New()
+	for _, path := range []string{"/shared/signup", "/shared/signin"} {
+		app.Post(path, limit, func(c fiber.Ctx) error {
+			hasher.Hash()
+			return c.SendStatus(fiber.StatusOK)
+		})
+	}
+
+	signup := postAsync(t, app, "/shared/signup")
+	require.Eventually(t, func() bool { return hasher.running.Load() == 1 }, time.Second, time.Millisecond)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/shared/signin", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+
+	close(hasher.release)
+	assert.Equal(t, fiber.StatusOK, <-signup)
+}
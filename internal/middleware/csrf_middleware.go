@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/url"
+	"slices"
+	"strings"
+
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// CookieCSRFToken holds the CSRF token. It is readable by scripts, which
+// copy it into HeaderCSRFToken; a cross-site page can't read it.
+const CookieCSRFToken = "csrf_token"
+
+// HeaderCSRFToken carries the copy of CookieCSRFToken on state-changing
+// requests
+const HeaderCSRFToken = "X-CSRF-Token"
+
+// CookieRefreshToken holds the refresh token in the cookie auth mode. CSRF
+// tokens are bound to it, so they can't be swapped between sessions.
+const CookieRefreshToken = "refresh_token"
+
+// csrfNonceLength is the random part of a token
+const csrfNonceLength = 16
+
+// CSRFConfig configures CSRF
+type CSRFConfig struct {
+	// Secret keys the HMAC binding tokens to their session
+	Secret []byte
+	// SessionCookie names the cookie identifying the session; tokens issued
+	// before it exists are bound to no session
+	SessionCookie string
+	// TrustedOrigins are origins besides the request's own that may send
+	// state-changing requests, such as "https://app.example.com"
+	TrustedOrigins []string
+	// Secure marks the token cookie Secure
+	Secure bool
+}
+
+// CSRF protects cookie-authenticated requests with a double-submit cookie.
+// Safe requests are issued a CookieCSRFToken when they lack a valid one.
+// State-changing requests must come from the request's own or a trusted
+// origin, by Origin or else Referer, and repeat the cookie in
+// HeaderCSRFToken; the token must be bound to the request's session.
+//
+// Requests carrying a bearer token are exempt: browsers never attach one
+// on their own, so they can't be forged cross-site.
+func CSRF(cfg CSRFConfig) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if _, err := extractBearerToken(c.Get(fiber.HeaderAuthorization)); err == nil {
+			return c.Next()
+		}
+
+		session := c.Cookies(cfg.SessionCookie)
+		cookie := c.Cookies(CookieCSRFToken)
+		if isSafeMethod(c.Method()) {
+			if !validCSRFToken(cfg.Secret, session, cookie) {
+				setCSRFCookie(c, cfg, newCSRFToken(cfg.Secret, session))
+			}
+			return c.Next()
+		}
+
+		if origin, ok := requestOrigin(c); !ok || !trustedOrigin(c, cfg.TrustedOrigins, origin) {
+			logger.Warn("cross-origin request rejected", map[string]any{
+				"path":   c.Path(),
+				"method": c.Method(),
+				"origin": origin,
+			})
+			return ForbiddenResponse(c, "cross-origin request")
+		}
+
+		header := c.Get(HeaderCSRFToken)
+		if header == "" || cookie == "" {
+			return ForbiddenResponse(c, "missing CSRF token")
+		}
+		if subtle.ConstantTimeCompare([]byte(header), []byte(cookie)) != 1 || !validCSRFToken(cfg.Secret, session, cookie) {
+			logger.Warn("invalid CSRF token", map[string]any{
+				"path":   c.Path(),
+				"method": c.Method(),
+			})
+			return ForbiddenResponse(c, "invalid CSRF token")
+		}
+		return c.Next()
+	}
+}
+
+// isSafeMethod reports whether method doesn't change state
+func isSafeMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// newCSRFToken returns a random nonce and its MAC over session
+func newCSRFToken(secret []byte, session string) string {
+	nonce := make([]byte, csrfNonceLength)
+	_, _ = rand.Read(nonce)
+	return csrfToken(secret, session, nonce)
+}
+
+func csrfToken(secret []byte, session string, nonce []byte) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(nonce) + "." + enc.EncodeToString(csrfMAC(secret, session, nonce))
+}
+
+// csrfMAC binds nonce to a hash of the session cookie, so the cookie value
+// itself never ends up in the token
+func csrfMAC(secret []byte, session string, nonce []byte) []byte {
+	sessionHash := sha256.Sum256([]byte(session))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(nonce)
+	mac.Write(sessionHash[:])
+	return mac.Sum(nil)
+}
+
+// validCSRFToken reports whether token was issued for session
+func validCSRFToken(secret []byte, session, token string) bool {
+	encodedNonce, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	nonce, err := base64.RawURLEncoding.DecodeString(encodedNonce)
+	if err != nil || len(nonce) != csrfNonceLength {
+		return false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(mac, csrfMAC(secret, session, nonce))
+}
+
+func setCSRFCookie(c fiber.Ctx, cfg CSRFConfig, token string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     CookieCSRFToken,
+		Value:    token,
+		Path:     "/",
+		Secure:   cfg.Secure,
+		HTTPOnly: false,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+}
+
+// requestOrigin returns the origin a request says it comes from, by Origin
+// or else Referer. Requests naming neither are rejected, as browsers send
+// at least one on cross-site writes.
+func requestOrigin(c fiber.Ctx) (string, bool) {
+	if origin := c.Get(fiber.HeaderOrigin); origin != "" {
+		return origin, origin != "null"
+	}
+	referer := c.Get(fiber.HeaderReferer)
+	u, err := url.Parse(referer)
+	if referer == "" || err != nil || u.Scheme == "" || u.Host == "" {
+		return referer, false
+	}
+	return u.Scheme + "://" + u.Host, true
+}
+
+// trustedOrigin reports whether origin is the request's own or a trusted one
+func trustedOrigin(c fiber.Ctx, trusted []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	if origin == strings.ToLower(c.Scheme()+"://"+c.Host()) {
+		return true
+	}
+	return slices.ContainsFunc(trusted, func(t string) bool {
+		return strings.ToLower(strings.TrimSuffix(t, "/")) == origin
+	})
+}
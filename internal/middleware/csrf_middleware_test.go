@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var csrfTestConfig = CSRFConfig{
+	Secret:         []byte("test-secret"),
+	SessionCookie:  CookieRefreshToken,
+	TrustedOrigins: []string{"https://app.example.com/"},
+}
+
+func newCSRFApp() *fiber.App {
+	app := fiber.New()
+	app.Use(CSRF(csrfTestConfig))
+	app.Get("/form", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+	app.Post("/refresh", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+	return app
+}
+
+// csrfPost sends a state-changing request from example.com, the test
+// server's own origin, with the given cookies and headers
+func csrfPost(t *testing.T, app *fiber.App, cookies map[string]string, header http.Header) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/refresh", nil)
+	req.Header.Set(fiber.HeaderOrigin, "http://example.com")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	for name, value := range cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// issuedToken returns the CSRF cookie set on a safe request in session
+func issuedToken(t *testing.T, app *fiber.App, session string) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/form", nil)
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: CookieRefreshToken, Value: session})
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == CookieCSRFToken {
+			assert.False(t, cookie.HttpOnly, "scripts copy it into the header")
+			assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+			return cookie.Value
+		}
+	}
+	t.Fatal("no CSRF cookie issued")
+	return ""
+}
+
+func TestCSRF_ValidDoubleSubmit(t *testing.T) {
+	app := newCSRFApp()
+	token := issuedToken(t, app, "session-a")
+
+	resp := csrfPost(t, app, map[string]string{CookieRefreshToken: "session-a", CookieCSRFToken: token}, http.Header{HeaderCSRFToken: {token}})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = csrfPost(t, app, map[string]string{CookieRefreshToken: "session-a", CookieCSRFToken: token}, http.Header{
+		HeaderCSRFToken:    {token},
+		fiber.HeaderOrigin: {"https://app.example.com"},
+	})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode, "trusted origins may send writes")
+}
+
+func TestCSRF_MissingHeader(t *testing.T) {
+	app := newCSRFApp()
+	token := issuedToken(t, app, "session-a")
+
+	resp := csrfPost(t, app, map[string]string{CookieRefreshToken: "session-a", CookieCSRFToken: token}, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = csrfPost(t, app, map[string]string{CookieRefreshToken: "session-a"}, http.Header{HeaderCSRFToken: {token}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "the cookie is needed too")
+}
+
+func TestCSRF_MismatchedToken(t *testing.T) {
+	app := newCSRFApp()
+	token := issuedToken(t, app, "session-a")
+	other := issuedToken(t, app, "session-a")
+
+	resp := csrfPost(t, app, map[string]string{CookieRefreshToken: "session-a", CookieCSRFToken: token}, http.Header{HeaderCSRFToken: {other}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	forged := "AAAAAAAAAAAAAAAAAAAAAA.AAAA"
+	resp = csrfPost(t, app, map[string]string{CookieRefreshToken: "session-a", CookieCSRFToken: forged}, http.Header{HeaderCSRFToken: {forged}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "matching but unsigned tokens are rejected")
+}
+
+func TestCSRF_TokenBoundToSession(t *testing.T) {
+	app := newCSRFApp()
+	attackers := issuedToken(t, app, "session-b")
+
+	resp := csrfPost(t, app, map[string]string{CookieRefreshToken: "session-a", CookieCSRFToken: attackers}, http.Header{HeaderCSRFToken: {attackers}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "another session's token is rejected")
+}
+
+func TestCSRF_CrossOrigin(t *testing.T) {
+	app := newCSRFApp()
+	token := issuedToken(t, app, "session-a")
+	cookies := map[string]string{CookieRefreshToken: "session-a", CookieCSRFToken: token}
+
+	resp := csrfPost(t, app, cookies, http.Header{HeaderCSRFToken: {token}, fiber.HeaderOrigin: {"https://evil.example.net"}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = csrfPost(t, app, cookies, http.Header{HeaderCSRFToken: {token}, fiber.HeaderOrigin: {"null"}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "opaque origins are rejected")
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/refresh", nil)
+	req.Header.Set(fiber.HeaderReferer, "https://evil.example.net/page")
+	req.Header.Set(HeaderCSRFToken, token)
+	req.AddCookie(&http.Cookie{Name: CookieRefreshToken, Value: "session-a"})
+	req.AddCookie(&http.Cookie{Name: CookieCSRFToken, Value: token})
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "Referer is checked without Origin")
+}
+
+func TestCSRF_BearerRequestsAreExempt(t *testing.T) {
+	app := newCSRFApp()
+
+	resp := csrfPost(t, app, nil, http.Header{
+		fiber.HeaderAuthorization: {"Bearer some.access.token"},
+		fiber.HeaderOrigin:        {"https://evil.example.net"},
+	})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestCSRF_SafeRequestsKeepAValidToken(t *testing.T) {
+	app := newCSRFApp()
+	token := issuedToken(t, app, "session-a")
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/form", nil)
+	req.AddCookie(&http.Cookie{Name: CookieRefreshToken, Value: "session-a"})
+	req.AddCookie(&http.Cookie{Name: CookieCSRFToken, Value: token})
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Empty(t, resp.Cookies(), "a valid token isn't replaced")
+}
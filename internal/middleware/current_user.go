@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// Context keys of CurrentUser
+const (
+	ContextKeyCurrentUser       = "current_user"
+	contextKeyCurrentUserLoader = "current_user_loader"
+)
+
+// CurrentUserLoader loads the authenticated user for GetCurrentUser, such
+// as a model.Store. A model.CachedStore is read through its cache shared
+// across requests.
+type CurrentUserLoader interface {
+	FindByID(ctx context.Context, userID uuid.UUID, scope model.Scope) (*model.User, error)
+}
+
+// cachedUserLoader is a CurrentUserLoader with a cross-request cache
+type cachedUserLoader interface {
+	FindCachedByID(ctx context.Context, userID uuid.UUID) (*model.User, error)
+	Purge(ctx context.Context, userID uuid.UUID)
+}
+
+// CurrentUser follows AuthMiddleware so handlers can load the
+// authenticated user with GetCurrentUser. Nothing is loaded until a
+// handler asks.
+func CurrentUser(users CurrentUserLoader) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		c.Locals(contextKeyCurrentUserLoader, users)
+		return c.Next()
+	}
+}
+
+// GetCurrentUser returns the authenticated user, loading it on the first
+// call of the request and from Locals after that. It returns
+// model.ErrUserNotFound for a user deleted since the token was issued.
+func GetCurrentUser(c fiber.Ctx) (*model.User, error) {
+	if user, ok := c.Locals(ContextKeyCurrentUser).(*model.User); ok {
+		return user, nil
+	}
+
+	users, ok := c.Locals(contextKeyCurrentUserLoader).(CurrentUserLoader)
+	if !ok {
+		return nil, fmt.Errorf("CurrentUser middleware not installed")
+	}
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *model.User
+	if cached, ok := users.(cachedUserLoader); ok {
+		user, err = cached.FindCachedByID(GetRequestContext(c), userID)
+	} else {
+		user, err = users.FindByID(GetRequestContext(c), userID, model.ActiveOnly)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Locals(ContextKeyCurrentUser, user)
+	return user, nil
+}
+
+// ForgetCurrentUser drops the authenticated user loaded by GetCurrentUser,
+// and its cross-request copy, after a change made outside the loader
+func ForgetCurrentUser(c fiber.Ctx) {
+	c.Locals(ContextKeyCurrentUser, nil)
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		return
+	}
+	if cached, ok := c.Locals(contextKeyCurrentUserLoader).(cachedUserLoader); ok {
+		cached.Purge(GetRequestContext(c), userID)
+	}
+}
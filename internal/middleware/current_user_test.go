@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/cache"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingUsers counts the users loaded from the store
+type countingUsers struct {
+	model.Store
+	finds atomic.Int32
+}
+
+func (s *countingUsers) FindByID(ctx context.Context, userID uuid.UUID, scope model.Scope) (*model.User, error) {
+	s.finds.Add(1)
+	return s.Store.FindByID(ctx, userID, model.ActiveOnly)
+}
+
+// newCurrentUserApp serves GET /me, which reads the current user twice,
+// and DELETE /me, which forgets it, as the user authenticated as userID
+func newCurrentUserApp(ctx context.Context, userID uuid.UUID, users CurrentUserLoader) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c fiber.Ctx) error {
+		c.Locals(ContextKeyRequestContext, ctx)
+		c.Locals(ContextKeyUserID, userID)
+		return c.Next()
+	}, CurrentUser(users))
+	app.Get("/me", func(c fiber.Ctx) error {
+		if _, err := GetCurrentUser(c); err != nil {
+			return err
+		}
+		user, err := GetCurrentUser(c)
+		if err != nil {
+			return err
+		}
+		return c.SendString(user.Username)
+	})
+	app.Delete("/me", func(c fiber.Ctx) error {
+		ForgetCurrentUser(c)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	return app
+}
+
+func saveCurrentUser(t *testing.T) (context.Context, *countingUsers, *model.User) {
+	t.Helper()
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	users := &countingUsers{Store: model.NewMemoryStore()}
+	user, err := users.SaveUser(ctx, &model.User{Email: "a@example.com", Username: "alice"})
+	require.NoError(t, err)
+	return ctx, users, user
+}
+
+func getMe(t *testing.T, app *fiber.App) string {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/me", nil))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestGetCurrentUser_LoadsOncePerRequest(t *testing.T) {
+	ctx, users, user := saveCurrentUser(t)
+	app := newCurrentUserApp(ctx, user.ID, users)
+
+	assert.Equal(t, "alice", getMe(t, app))
+	assert.Equal(t, int32(1), users.finds.Load())
+
+	getMe(t, app)
+	assert.Equal(t, int32(2), users.finds.Load(), "without a cache each request loads the user")
+}
+
+func TestGetCurrentUser_CrossRequestCache(t *testing.T) {
+	ctx, users, user := saveCurrentUser(t)
+	cached := model.NewCachedStore(users, cache.NewMemory(), 0)
+	app := newCurrentUserApp(ctx, user.ID, cached)
+
+	getMe(t, app)
+	getMe(t, app)
+	assert.Equal(t, int32(1), users.finds.Load())
+
+	name := "Alice"
+	_, err := cached.UpdateProfile(ctx, user.ID, model.ProfileUpdate{FullName: &name})
+	require.NoError(t, err)
+	getMe(t, app)
+	assert.Equal(t, int32(2), users.finds.Load(), "a profile update purges the cache")
+
+	require.NoError(t, cached.SetPassword(ctx, user.ID, "new-hash"))
+	getMe(t, app)
+	assert.Equal(t, int32(3), users.finds.Load(), "a password change purges the cache")
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodDelete, "/me", nil))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	getMe(t, app)
+	assert.Equal(t, int32(4), users.finds.Load(), "ForgetCurrentUser purges the cache")
+}
+
+func TestGetCurrentUser_WithoutMiddleware(t *testing.T) {
+	app := fiber.New()
+	app.Get("/me", func(c fiber.Ctx) error {
+		_, err := GetCurrentUser(c)
+		return err
+	})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/me", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+}
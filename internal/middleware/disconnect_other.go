@@ -0,0 +1,11 @@
+//go:build !unix
+
+package middleware
+
+import "net"
+
+// newDisconnectProbe is unsupported on this platform; requests are only
+// bounded by the timeout.
+func newDisconnectProbe(conn net.Conn) func() connState {
+	return nil
+}
@@ -0,0 +1,45 @@
+//go:build unix
+
+package middleware
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// newDisconnectProbe returns a non-blocking check of conn's state, or nil
+// when conn does not expose a file descriptor (e.g. TLS or in-memory conns).
+// It peeks at the socket so no request bytes are consumed.
+func newDisconnectProbe(conn net.Conn) func() connState {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	buf := make([]byte, 1)
+	return func() connState {
+		state := connOpen
+		err := raw.Control(func(fd uintptr) {
+			n, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+			switch {
+			case errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EWOULDBLOCK) || errors.Is(err, syscall.EINTR):
+				state = connOpen
+			case err != nil:
+				state = connClosed
+			case n == 0:
+				state = connClosed
+			default:
+				state = connHasData
+			}
+		})
+		if err != nil {
+			return connClosed
+		}
+		return state
+	}
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/internal/preferences"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/format"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// DisplayTime is how DisplayTimes renders one timestamp of a response
+type DisplayTime struct {
+	Formatted string `json:"formatted"`
+	Relative  string `json:"relative"`
+}
+
+// DisplayTimes is opt-in middleware for admin routes whose pages are
+// rendered server-side. It finds the RFC 3339 timestamps of successful JSON
+// object responses and adds them, formatted in the locale and time zone of
+// the authenticated user's preferences, under meta.display keyed by their
+// JSON pointer:
+//
+//	{"user": {"created_at": "2024-03-07T12:00:00Z"},
+//	 "meta": {"display": {"/user/created_at": {"formatted": "Mar 7, 2024, 12:00 PM UTC", "relative": "3 days ago"}}}}
+//
+// The timestamps themselves are left as they are. Without preferences, or
+// when they can't be loaded, the defaults of preferences.Defaults are used.
+// Relative times are counted from now, clock.Now when nil.
+func DisplayTimes(prefs preferences.Getter, now func() time.Time) fiber.Handler {
+	if now == nil {
+		now = clock.Now
+	}
+	return func(c fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		resp := c.Response()
+		if resp.StatusCode() >= fiber.StatusMultipleChoices || resp.IsBodyStream() ||
+			!strings.HasPrefix(string(resp.Header.ContentType()), fiber.MIMEApplicationJSON) {
+			return nil
+		}
+
+		var doc map[string]any
+		dec := json.NewDecoder(bytes.NewReader(resp.Body()))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return nil
+		}
+		display := map[string]DisplayTime{}
+		locale, tz := displayPreferences(c, prefs)
+		collectTimes(doc, "", now().In(tz), locale, tz, display)
+		if len(display) == 0 {
+			return nil
+		}
+
+		meta, _ := doc["meta"].(map[string]any)
+		if meta == nil {
+			meta = map[string]any{}
+		}
+		meta["display"] = display
+		doc["meta"] = meta
+		if body, err := json.Marshal(doc); err == nil {
+			resp.SetBodyRaw(body)
+		}
+		return nil
+	}
+}
+
+// displayPreferences returns the locale and time zone of the
+// authenticated user, or the defaults
+func displayPreferences(c fiber.Ctx, prefs preferences.Getter) (string, *time.Location) {
+	locale, timezone := preferences.DefaultLocale, preferences.DefaultTimezone
+	if userID, err := GetUserIDFromContext(c); err == nil && prefs != nil {
+		if p, err := prefs.Get(GetRequestContext(c), userID); err == nil {
+			locale, timezone = p.Locale, p.Timezone
+		} else {
+			logger.Warn("failed to load display preferences", map[string]any{
+				"user_id": userID.String(),
+				"error":   err.Error(),
+			})
+		}
+	}
+	tz, err := time.LoadLocation(timezone)
+	if err != nil {
+		tz = time.UTC
+	}
+	return locale, tz
+}
+
+// collectTimes adds every RFC 3339 string in v, at JSON pointer path, to
+// display
+func collectTimes(v any, path string, now time.Time, locale string, tz *time.Location, display map[string]DisplayTime) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			collectTimes(val, path+"/"+pointerEscaper.Replace(k), now, locale, tz, display)
+		}
+	case []any:
+		for i, val := range t {
+			collectTimes(val, path+"/"+strconv.Itoa(i), now, locale, tz, display)
+		}
+	case string:
+		if at, err := time.Parse(time.RFC3339, t); err == nil {
+			display[path] = DisplayTime{
+				Formatted: format.FormatTime(at, locale, tz),
+				Relative:  format.RelativeTime(at, now, locale),
+			}
+		}
+	}
+}
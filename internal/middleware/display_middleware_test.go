@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/preferences"
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var displayNow = time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+// failingPrefs is a preferences.Getter whose database is down
+type failingPrefs struct{}
+
+func (failingPrefs) Get(ctx context.Context, userID uuid.UUID) (*preferences.Preferences, error) {
+	return nil, errors.New("connection refused")
+}
+
+func displayApp(prefs preferences.Getter, ctx context.Context, userID uuid.UUID) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c fiber.Ctx) error {
+		c.Locals(ContextKeyRequestContext, ctx)
+		if userID != uuid.Nil {
+			c.Locals(ContextKeyUserID, userID)
+		}
+		return c.Next()
+	})
+	app.Use(DisplayTimes(prefs, func() time.Time { return displayNow }))
+	app.Get("/users", func(c fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"users": []fiber.Map{
+				{"username": "jane", "created_at": "2024-03-07T12:00:00Z", "last_login_at": nil},
+			},
+			"generated_at": "2024-03-10T11:58:00Z",
+		})
+	})
+	app.Get("/plain", func(c fiber.Ctx) error {
+		return c.JSON(fiber.Map{"count": 3})
+	})
+	app.Get("/missing", func(c fiber.Ctx) error {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "not found", "at": "2024-03-10T11:58:00Z"})
+	})
+	return app
+}
+
+func getDisplay(t *testing.T, app *fiber.App, path string) (int, map[string]any) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(raw, &body), string(raw))
+	return resp.StatusCode, body
+}
+
+func displayOf(t *testing.T, body map[string]any) map[string]any {
+	t.Helper()
+	meta, ok := body["meta"].(map[string]any)
+	require.True(t, ok, "meta is added")
+	display, ok := meta["display"].(map[string]any)
+	require.True(t, ok, "with display")
+	return display
+}
+
+func TestDisplayTimes_UsesPreferences(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	store := preferences.NewMemoryStore()
+	userID := uuid.New()
+	locale, tz := "th-TH", "Asia/Bangkok"
+	_, err := store.Update(ctx, userID, preferences.Patch{Locale: &locale, Timezone: &tz})
+	require.NoError(t, err)
+
+	status, body := getDisplay(t, displayApp(store, ctx, userID), "/users")
+	require.Equal(t, http.StatusOK, status)
+
+	users := body["users"].([]any)
+	assert.Equal(t, "2024-03-07T12:00:00Z", users[0].(map[string]any)["created_at"], "the raw value stays")
+	assert.Equal(t, map[string]any{
+		"/users/0/created_at": map[string]any{"formatted": "7 มี.ค. 2567 19:00 น. +07", "relative": "3 วันที่แล้ว"},
+		"/generated_at":       map[string]any{"formatted": "10 มี.ค. 2567 18:58 น. +07", "relative": "2 นาทีที่แล้ว"},
+	}, displayOf(t, body))
+
+	// Switching the locale switches the rendering
+	locale, tz = "en-US", "America/Los_Angeles"
+	_, err = store.Update(ctx, userID, preferences.Patch{Locale: &locale, Timezone: &tz})
+	require.NoError(t, err)
+	_, body = getDisplay(t, displayApp(store, ctx, userID), "/users")
+	assert.Equal(t, map[string]any{"formatted": "Mar 7, 2024, 4:00 AM PST", "relative": "3 days ago"}, displayOf(t, body)["/users/0/created_at"])
+	assert.Equal(t, map[string]any{"formatted": "Mar 10, 2024, 4:58 AM PDT", "relative": "2 minutes ago"}, displayOf(t, body)["/generated_at"], "after the DST change")
+}
+
+func TestDisplayTimes_FallsBackToDefaults(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	want := map[string]any{"formatted": "Mar 7, 2024, 12:00 PM UTC", "relative": "3 days ago"}
+
+	for name, app := range map[string]*fiber.App{
+		"no user":            displayApp(preferences.NewMemoryStore(), ctx, uuid.Nil),
+		"no preferences yet": displayApp(preferences.NewMemoryStore(), ctx, uuid.New()),
+		"lookup fails":       displayApp(failingPrefs{}, ctx, uuid.New()),
+		"no store":           displayApp(nil, ctx, uuid.New()),
+	} {
+		status, body := getDisplay(t, app, "/users")
+		require.Equal(t, http.StatusOK, status, name)
+		assert.Equal(t, want, displayOf(t, body)["/users/0/created_at"], name)
+	}
+}
+
+func TestDisplayTimes_LeavesOtherResponsesAlone(t *testing.T) {
+	app := displayApp(nil, context.Background(), uuid.Nil)
+
+	_, body := getDisplay(t, app, "/plain")
+	assert.Equal(t, map[string]any{"count": float64(3)}, body, "no timestamps, no meta")
+
+	status, body := getDisplay(t, app, "/missing")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.NotContains(t, body, "meta", "errors aren't decorated")
+}
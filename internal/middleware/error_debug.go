@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// ContextKeyDebugErrors marks requests whose error responses carry debug
+// details. ErrorHandlerWith sets it when debug mode is on.
+const ContextKeyDebugErrors = "debug_errors"
+
+// ErrorDebug is the debug detail attached to error responses outside
+// production: every error in the chain, outermost first, and the stack
+// trace for panics
+type ErrorDebug struct {
+	Chain []string `json:"chain,omitempty" xml:"chain>error,omitempty"`
+	Stack string   `json:"stack,omitempty" xml:"stack,omitempty"`
+}
+
+// debugEnabled reports whether the request's error responses carry debug
+// details
+func debugEnabled(c fiber.Ctx) bool {
+	on, _ := c.Locals(ContextKeyDebugErrors).(bool)
+	return on
+}
+
+// errorDebug returns the debug detail for causes, or nil when debug mode
+// is off for the request
+func errorDebug(c fiber.Ctx, stack []byte, causes ...error) *ErrorDebug {
+	if !debugEnabled(c) {
+		return nil
+	}
+	d := &ErrorDebug{Stack: string(stack)}
+	for _, err := range causes {
+		d.Chain = append(d.Chain, errorChain(err)...)
+	}
+	if len(d.Chain) == 0 && d.Stack == "" {
+		return nil
+	}
+	return d
+}
+
+// errorChain flattens err and everything it wraps, including every branch
+// of joined errors, depth first
+func errorChain(err error) []string {
+	if err == nil {
+		return nil
+	}
+	out := []string{err.Error()}
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			out = append(out, errorChain(inner)...)
+		}
+	default:
+		out = append(out, errorChain(errors.Unwrap(err))...)
+	}
+	return out
+}
+
+// panicError describes a recovered panic value
+func panicError(r any) error {
+	if err, ok := r.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", r)
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errRelation = errors.New(`relation "users" does not exist`)
+
+func newDebugTestApp(debug bool) *fiber.App {
+	app := fiber.New()
+	app.Use(ErrorHandlerWith(ErrorHandlerConfig{Debug: debug}))
+	app.Get("/error", func(c fiber.Ctx) error {
+		return fmt.Errorf("failed to list users: %w", errRelation)
+	})
+	app.Get("/internal", func(c fiber.Ctx) error {
+		return InternalErrorResponse(c, "failed to list users", fmt.Errorf("query: %w", errRelation))
+	})
+	app.Get("/panic", func(c fiber.Ctx) error {
+		panic("nil map write")
+	})
+	return app
+}
+
+func getErrorResponse(t *testing.T, app *fiber.App, path string) (ErrorResponse, string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var body ErrorResponse
+	require.NoError(t, json.Unmarshal(raw, &body))
+	return body, string(raw)
+}
+
+func TestErrorHandler_DebugMode(t *testing.T) {
+	app := newDebugTestApp(true)
+
+	body, _ := getErrorResponse(t, app, "/error")
+	require.NotNil(t, body.Debug)
+	assert.Equal(t, []string{`failed to list users: relation "users" does not exist`, `relation "users" does not exist`}, body.Debug.Chain)
+	assert.Empty(t, body.Debug.Stack)
+
+	body, _ = getErrorResponse(t, app, "/internal")
+	assert.Equal(t, "failed to list users", body.Message)
+	require.NotNil(t, body.Debug)
+	assert.Contains(t, body.Debug.Chain, `relation "users" does not exist`)
+
+	body, _ = getErrorResponse(t, app, "/panic")
+	assert.Equal(t, "An unexpected error occurred", body.Message)
+	require.NotNil(t, body.Debug)
+	assert.Equal(t, []string{"panic: nil map write"}, body.Debug.Chain)
+	assert.Contains(t, body.Debug.Stack, "goroutine")
+}
+
+func TestErrorHandler_DebugOffStripsField(t *testing.T) {
+	app := newDebugTestApp(false)
+
+	for _, path := range []string{"/error", "/internal", "/panic"} {
+		body, raw := getErrorResponse(t, app, path)
+		assert.Nil(t, body.Debug, path)
+		assert.NotContains(t, raw, `"debug"`, path)
+		assert.NotContains(t, raw, "relation", "%s: causes never reach the client", path)
+	}
+}
+
+func TestErrorChain_JoinedErrors(t *testing.T) {
+	err := fmt.Errorf("save: %w", errors.Join(errors.New("a"), fmt.Errorf("b: %w", errors.New("c"))))
+
+	chain := errorChain(err)
+	assert.Equal(t, "save: a\nb: c", chain[0])
+	assert.Equal(t, []string{"a", "b: c", "c"}, chain[2:])
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// errorHandlerCode is the code of every handler ErrorHandlerWith returns
+var errorHandlerCode = reflect.ValueOf(ErrorHandler()).Pointer()
+
+// DuplicateErrorHandlers returns the routes of app that run behind more
+// than one ErrorHandler, as "METHOD /path", such as routes of a group given
+// its own ErrorHandler under a shared stack that has one. The requests are
+// still handled correctly, see ErrorHandlerWith, but the extra handlers are
+// dead weight.
+func DuplicateErrorHandlers(app *fiber.App) []string {
+	// GetRoutes without the middleware leaves the endpoints; telling the
+	// middleware apart takes matching them against the full list
+	endpoints := app.GetRoutes(true)
+	pending := make(map[string]int, len(endpoints))
+	for _, r := range endpoints {
+		pending[routeSignature(r)]++
+	}
+	var uses []fiber.Route
+	for _, r := range app.GetRoutes() {
+		if sig := routeSignature(r); pending[sig] > 0 {
+			pending[sig]--
+			continue
+		}
+		uses = append(uses, r)
+	}
+
+	var out []string
+	for _, r := range endpoints {
+		if r.Method == fiber.MethodHead {
+			continue
+		}
+		n := countErrorHandlers(r.Handlers)
+		for _, u := range uses {
+			if u.Method == r.Method && coversPath(u.Path, r.Path) {
+				n += countErrorHandlers(u.Handlers)
+			}
+		}
+		if key := r.Method + " " + r.Path; n > 1 && !slices.Contains(out, key) {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+// routeSignature identifies r by method, path and handler code. Routes
+// alike in all three are interchangeable here.
+func routeSignature(r fiber.Route) string {
+	var b strings.Builder
+	b.WriteString(r.Method + " " + r.Path)
+	for _, h := range r.Handlers {
+		b.WriteString(" " + strconv.FormatUint(uint64(reflect.ValueOf(h).Pointer()), 16))
+	}
+	return b.String()
+}
+
+func countErrorHandlers(handlers []fiber.Handler) int {
+	var n int
+	for _, h := range handlers {
+		if reflect.ValueOf(h).Pointer() == errorHandlerCode {
+			n++
+		}
+	}
+	return n
+}
+
+// coversPath reports whether middleware used under prefix runs for path
+func coversPath(prefix, path string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
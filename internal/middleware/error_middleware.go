@@ -1,39 +1,100 @@
 package middleware
 
 import (
+	"encoding/xml"
+	"errors"
+	"math"
+	"runtime/debug"
+	"strconv"
+
+	"dvith.com/go-service-api/internal/errs"
 	"dvith.com/go-service-api/pkg/logger"
 	"github.com/gofiber/fiber/v3"
 )
 
 // ErrorResponse is a uniform error response structure for the API.
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
-	Code    int    `json:"code"`
+	XMLName xml.Name `json:"-" xml:"error"`
+	Error   string   `json:"error" xml:"error"`
+	Message string   `json:"message,omitempty" xml:"message,omitempty"`
+	Code    int      `json:"code" xml:"code"`
+	// Debug is only set in debug mode, see ErrorHandlerConfig
+	Debug *ErrorDebug `json:"debug,omitempty" xml:"debug,omitempty"`
+}
+
+// ErrorHandlerConfig configures ErrorHandlerWith
+type ErrorHandlerConfig struct {
+	// Debug adds the error chain, and the stack trace for panics, to error
+	// responses, including those built by InternalErrorResponse. Never set
+	// it in production; config.Config.ErrorDebugEnabled guards that.
+	Debug bool
 }
 
+// ContextKeyErrorHandler marks a request already behind an ErrorHandler
+const ContextKeyErrorHandler = "error_handler"
+
 // ErrorHandler is middleware that catches panics and errors from route handlers,
 // logs them, and returns a consistent JSON error response.
 func ErrorHandler() fiber.Handler {
+	return ErrorHandlerWith(ErrorHandlerConfig{})
+}
+
+// ErrorHandlerWith is ErrorHandler with options. Installed more than once
+// on a request's path, only the outermost one handles errors; the others
+// pass them up, so each error is logged and rendered once.
+func ErrorHandlerWith(cfg ErrorHandlerConfig) fiber.Handler {
 	return func(c fiber.Ctx) error {
+		if c.Locals(ContextKeyErrorHandler) != nil {
+			return c.Next()
+		}
+		c.Locals(ContextKeyErrorHandler, true)
+
+		if cfg.Debug {
+			c.Locals(ContextKeyDebugErrors, true)
+		}
+
 		// Catch any panic from the handler
 		defer func() {
 			if r := recover(); r != nil {
+				stack := debug.Stack()
 				logger.Error("handler panic", map[string]any{
 					"path":   c.Path(),
 					"method": c.Method(),
 					"panic":  r,
 				})
-				c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+				Respond(c, fiber.StatusInternalServerError, ErrorResponse{
 					Error:   "internal_error",
 					Message: "An unexpected error occurred",
 					Code:    fiber.StatusInternalServerError,
+					Debug:   errorDebug(c, stack, panicError(r)),
 				})
 			}
 		}()
 
 		err := c.Next()
 
+		// Malformed path parameters are the client's fault, not a server error
+		var badParam *BadParamError
+		if errors.As(err, &badParam) {
+			logger.Warn("bad path parameter", map[string]any{
+				"path":   c.Path(),
+				"method": c.Method(),
+				"param":  badParam.Param,
+			})
+			return Respond(c, fiber.StatusBadRequest, ErrorResponse{
+				Error:   statusMessage(fiber.StatusBadRequest),
+				Message: badParam.Error(),
+				Code:    fiber.StatusBadRequest,
+				Debug:   errorDebug(c, nil, err),
+			})
+		}
+
+		// Known error classes (timeouts, missing rows, constraint violations)
+		// get their own status instead of a generic 500
+		if t, ok := errs.Classify(err); ok {
+			return translatedResponse(c, t, err)
+		}
+
 		// Handle Fiber errors
 		if err != nil {
 			var code int
@@ -47,19 +108,27 @@ func ErrorHandler() fiber.Handler {
 				errStr = err.Error()
 			}
 
-			logger.Error("request error", map[string]any{
+			logger.Error("request error", withBaggageFields(c, map[string]any{
 				"path":   c.Path(),
 				"method": c.Method(),
 				"code":   code,
 				"error":  errStr,
-			})
+			}))
+
+			// Unclassified errors may carry driver or internal details; the
+			// client only sees them through the debug field
+			msg := errStr
+			if _, ok := err.(*fiber.Error); !ok {
+				msg = "An unexpected error occurred"
+			}
 
 			// Get a simple status message
 			statusMsg := statusMessage(code)
-			return c.Status(code).JSON(ErrorResponse{
+			return Respond(c, code, ErrorResponse{
 				Error:   statusMsg,
-				Message: errStr,
+				Message: msg,
 				Code:    code,
+				Debug:   errorDebug(c, nil, err),
 			})
 		}
 
@@ -67,6 +136,46 @@ func ErrorHandler() fiber.Handler {
 	}
 }
 
+// translatedResponse renders err according to its errs translation
+func translatedResponse(c fiber.Ctx, t errs.Translation, err error) error {
+	fields := map[string]any{
+		"path":   c.Path(),
+		"method": c.Method(),
+		"code":   t.Status,
+		"class":  t.Name,
+		"error":  err.Error(),
+	}
+	withBaggageFields(c, fields)
+	if t.Status >= fiber.StatusInternalServerError {
+		logger.Error("request error", fields)
+	} else {
+		logger.Warn("request error", fields)
+	}
+
+	msg := t.Message
+	if msg == "" {
+		if t.Status < fiber.StatusInternalServerError {
+			msg = err.Error()
+		} else {
+			msg = "An unexpected error occurred"
+		}
+	}
+	code := t.Code
+	if code == "" {
+		code = statusMessage(t.Status)
+	}
+	if t.RetryAfter > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(t.RetryAfter.Seconds()))))
+	}
+
+	return Respond(c, t.Status, ErrorResponse{
+		Error:   code,
+		Message: msg,
+		Code:    t.Status,
+		Debug:   errorDebug(c, nil, err),
+	})
+}
+
 // statusMessage returns a simple message for a given HTTP status code.
 func statusMessage(code int) string {
 	switch code {
@@ -78,6 +187,12 @@ func statusMessage(code int) string {
 		return "forbidden"
 	case fiber.StatusNotFound:
 		return "not_found"
+	case fiber.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case fiber.StatusConflict:
+		return "conflict"
+	case fiber.StatusTooManyRequests:
+		return "too_many_requests"
 	case fiber.StatusInternalServerError:
 		return "internal_error"
 	case fiber.StatusServiceUnavailable:
@@ -89,7 +204,7 @@ func statusMessage(code int) string {
 
 // ValidationErrorResponse returns a 400 Bad Request with a validation error.
 func ValidationErrorResponse(c fiber.Ctx, msg string) error {
-	return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+	return Respond(c, fiber.StatusBadRequest, ErrorResponse{
 		Error:   "validation_error",
 		Message: msg,
 		Code:    fiber.StatusBadRequest,
@@ -98,27 +213,56 @@ func ValidationErrorResponse(c fiber.Ctx, msg string) error {
 
 // AuthErrorResponse returns a 401 Unauthorized response.
 func AuthErrorResponse(c fiber.Ctx, msg string) error {
-	return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
+	return Respond(c, fiber.StatusUnauthorized, ErrorResponse{
 		Error:   "unauthorized",
 		Message: msg,
 		Code:    fiber.StatusUnauthorized,
 	})
 }
 
+// ForbiddenResponse returns a 403 Forbidden response.
+func ForbiddenResponse(c fiber.Ctx, msg string) error {
+	return Respond(c, fiber.StatusForbidden, ErrorResponse{
+		Error:   "forbidden",
+		Message: msg,
+		Code:    fiber.StatusForbidden,
+	})
+}
+
 // NotFoundResponse returns a 404 Not Found response.
 func NotFoundResponse(c fiber.Ctx, msg string) error {
-	return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+	return Respond(c, fiber.StatusNotFound, ErrorResponse{
 		Error:   "not_found",
 		Message: msg,
 		Code:    fiber.StatusNotFound,
 	})
 }
 
-// InternalErrorResponse returns a 500 Internal Server Error response.
-func InternalErrorResponse(c fiber.Ctx, msg string) error {
-	return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+// ConflictResponse returns a 409 Conflict response.
+func ConflictResponse(c fiber.Ctx, msg string) error {
+	return Respond(c, fiber.StatusConflict, ErrorResponse{
+		Error:   "conflict",
+		Message: msg,
+		Code:    fiber.StatusConflict,
+	})
+}
+
+// TooManyRequestsResponse returns a 429 Too Many Requests response.
+func TooManyRequestsResponse(c fiber.Ctx, msg string) error {
+	return Respond(c, fiber.StatusTooManyRequests, ErrorResponse{
+		Error:   "too_many_requests",
+		Message: msg,
+		Code:    fiber.StatusTooManyRequests,
+	})
+}
+
+// InternalErrorResponse returns a 500 Internal Server Error response. In
+// debug mode the causes are included in the debug field.
+func InternalErrorResponse(c fiber.Ctx, msg string, causes ...error) error {
+	return Respond(c, fiber.StatusInternalServerError, ErrorResponse{
 		Error:   "internal_error",
 		Message: msg,
 		Code:    fiber.StatusInternalServerError,
+		Debug:   errorDebug(c, nil, causes...),
 	})
 }
@@ -1,11 +1,19 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"dvith.com/go-service-api/internal/errs"
 	"github.com/gofiber/fiber/v3"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -21,6 +29,7 @@ func TestStatusMessage(t *testing.T) {
 			{fiber.StatusUnauthorized, "unauthorized"},
 			{fiber.StatusForbidden, "forbidden"},
 			{fiber.StatusNotFound, "not_found"},
+			{fiber.StatusMethodNotAllowed, "method_not_allowed"},
 			{fiber.StatusInternalServerError, "internal_error"},
 			{fiber.StatusServiceUnavailable, "service_unavailable"},
 			{200, "error"},
@@ -178,3 +187,94 @@ func BenchmarkErrorResponseMarshal(b *testing.B) {
 		}
 	})
 }
+
+// TestErrorHandler_TranslatesErrors feeds each error class through a handler
+func TestErrorHandler_TranslatesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		code       string
+		retryAfter string
+	}{
+		{"deadline", fmt.Errorf("list users: %w", context.DeadlineExceeded), fiber.StatusServiceUnavailable, "timeout", ""},
+		{"not found", errs.NotFound("user", pgx.ErrNoRows), fiber.StatusNotFound, "not_found", ""},
+		{"unique", fmt.Errorf("save: %w", &pgconn.PgError{Code: errs.SQLStateUniqueViolation}), fiber.StatusConflict, "conflict", ""},
+		{"foreign key", &pgconn.PgError{Code: errs.SQLStateForeignKeyViolation}, fiber.StatusUnprocessableEntity, "unprocessable_entity", ""},
+		{"serialization", &pgconn.PgError{Code: errs.SQLStateSerializationFailure}, fiber.StatusServiceUnavailable, "serialization_failure", "1"},
+		{"unclassified", errors.New("boom"), fiber.StatusInternalServerError, "internal_error", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(ErrorHandler())
+			app.Get("/test", func(c fiber.Ctx) error { return tt.err })
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/test", nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.Equal(t, tt.retryAfter, resp.Header.Get(fiber.HeaderRetryAfter))
+
+			var body ErrorResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tt.code, body.Error)
+			assert.Equal(t, tt.status, body.Code)
+		})
+	}
+}
+
+// TestErrorHandler_HidesDriverDetails checks constraint messages never reach the client
+func TestErrorHandler_HidesDriverDetails(t *testing.T) {
+	app := fiber.New()
+	app.Use(ErrorHandler())
+	app.Get("/test", func(c fiber.Ctx) error {
+		return &pgconn.PgError{Code: errs.SQLStateUniqueViolation, ConstraintName: "users_email_key"}
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/test", nil))
+	require.NoError(t, err)
+
+	var body ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.NotContains(t, body.Message, "users_email_key")
+	assert.Equal(t, "the resource already exists", body.Message)
+}
+
+// TestErrorHandler_StackedTwice checks a second ErrorHandler on the path
+// leaves the error to the first, which logs and renders it once
+func TestErrorHandler_StackedTwice(t *testing.T) {
+	buf := captureWarnings(t)
+
+	app := fiber.New()
+	api := app.Group("/api", ErrorHandler())
+	api.Get("/fail", ErrorHandler(), func(c fiber.Ctx) error { return errors.New("boom") })
+	api.Get("/panic", ErrorHandler(), func(c fiber.Ctx) error { panic("boom") })
+
+	for _, path := range []string{"/api/fail", "/api/panic"} {
+		buf.Reset()
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+
+		dec := json.NewDecoder(resp.Body)
+		var body ErrorResponse
+		require.NoError(t, dec.Decode(&body), path)
+		assert.Equal(t, "internal_error", body.Error)
+		assert.False(t, dec.More(), "%s has a single body", path)
+
+		assert.Equal(t, 1, strings.Count(strings.TrimSpace(buf.String()), "\n")+1, "%s logs once: %s", path, buf.String())
+	}
+
+	assert.ElementsMatch(t, []string{"GET /api/fail", "GET /api/panic"}, DuplicateErrorHandlers(app))
+}
+
+func TestDuplicateErrorHandlers_None(t *testing.T) {
+	app := fiber.New()
+	api := app.Group("/api", ErrorHandler())
+	api.Get("/ok", func(c fiber.Ctx) error { return nil })
+	app.Get("/other", ErrorHandler(), func(c fiber.Ctx) error { return nil })
+	app.Group("/apis", ErrorHandler()).Get("/x", func(c fiber.Ctx) error { return nil })
+
+	assert.Empty(t, DuplicateErrorHandlers(app))
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// ErrorRateWindow is how far back the ErrorRateTracker looks, at a
+// resolution of one second
+const ErrorRateWindow = 5 * time.Minute
+
+// ErrorRateConfig configures an ErrorRateTracker
+type ErrorRateConfig struct {
+	// Threshold is the share of 5xx responses, from 0 to 1, at or above
+	// which the service is degraded; 0 never degrades
+	Threshold float64
+	// Recovery is the share the rate must fall below before a degraded
+	// service recovers, so a rate hovering at the threshold doesn't flap.
+	// It is capped at Threshold.
+	Recovery float64
+	// MinRequests is how many requests the window needs before its rate
+	// counts; quieter windows are never degraded
+	MinRequests int
+}
+
+// ErrorRate is the error rate over the window, and whether it degrades
+// the service
+type ErrorRate struct {
+	Rate     float64 `json:"error_rate"`
+	Requests int64   `json:"requests"`
+	Errors   int64   `json:"errors"`
+	Degraded bool    `json:"-"`
+}
+
+type errorRateBucket struct {
+	second   int64
+	requests int64
+	errors   int64
+}
+
+// ErrorRateTracker keeps the rate of 5xx responses over the last
+// ErrorRateWindow in a ring of per-second counts. It decides whether the
+// service is degraded whenever a new second begins or the rate is read,
+// and logs each transition.
+type ErrorRateTracker struct {
+	mu       sync.Mutex
+	cfg      ErrorRateConfig
+	buckets  []errorRateBucket
+	current  int64
+	degraded bool
+	now      func() time.Time
+}
+
+// NewErrorRateTracker creates a tracker with an empty window
+func NewErrorRateTracker(cfg ErrorRateConfig) *ErrorRateTracker {
+	cfg.Recovery = min(cfg.Recovery, cfg.Threshold)
+	return &ErrorRateTracker{
+		cfg:     cfg,
+		buckets: make([]errorRateBucket, int(ErrorRateWindow/time.Second)),
+		now:     clock.Now,
+	}
+}
+
+// WithClock replaces the time source, for tests
+func (t *ErrorRateTracker) WithClock(now func() time.Time) *ErrorRateTracker {
+	t.now = now
+	return t
+}
+
+// Config returns the thresholds the tracker was created with
+func (t *ErrorRateTracker) Config() ErrorRateConfig {
+	return t.cfg
+}
+
+// Record counts a response with the given status
+func (t *ErrorRateTracker) Record(status int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	second := t.now().Unix()
+	if second != t.current {
+		// The rate of the seconds before this one is final
+		t.evaluate(t.sum(second - 1))
+		t.current = second
+	}
+	b := &t.buckets[t.index(second)]
+	if b.second != second {
+		*b = errorRateBucket{second: second}
+	}
+	b.requests++
+	if status >= fiber.StatusInternalServerError {
+		b.errors++
+	}
+}
+
+// Rate returns the error rate over the window ending now
+func (t *ErrorRateTracker) Rate() ErrorRate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rate := t.sum(t.now().Unix())
+	t.evaluate(rate)
+	rate.Degraded = t.degraded
+	return rate
+}
+
+func (t *ErrorRateTracker) index(second int64) int {
+	n := int64(len(t.buckets))
+	return int(((second % n) + n) % n)
+}
+
+// sum adds up the buckets of the window ending at second
+func (t *ErrorRateTracker) sum(second int64) ErrorRate {
+	var rate ErrorRate
+	oldest := second - int64(len(t.buckets))
+	for _, b := range t.buckets {
+		if b.second > oldest && b.second <= second {
+			rate.Requests += b.requests
+			rate.Errors += b.errors
+		}
+	}
+	if rate.Requests > 0 {
+		rate.Rate = float64(rate.Errors) / float64(rate.Requests)
+	}
+	return rate
+}
+
+// evaluate moves the tracker in or out of the degraded state. Entering
+// takes a rate at or above the threshold, leaving one below the recovery
+// rate, or too few requests to tell.
+func (t *ErrorRateTracker) evaluate(rate ErrorRate) {
+	if t.cfg.Threshold <= 0 {
+		return
+	}
+	enough := rate.Requests >= int64(t.cfg.MinRequests)
+	switch {
+	case !t.degraded && enough && rate.Rate >= t.cfg.Threshold:
+		t.degraded = true
+		logger.Warn("error rate above threshold, readiness degraded", t.fields(rate))
+	case t.degraded && (!enough || rate.Rate < t.cfg.Recovery):
+		t.degraded = false
+		logger.Info("error rate recovered, readiness restored", t.fields(rate))
+	}
+}
+
+func (t *ErrorRateTracker) fields(rate ErrorRate) map[string]any {
+	return map[string]any{
+		"error_rate": rate.Rate,
+		"requests":   rate.Requests,
+		"errors":     rate.Errors,
+		"threshold":  t.cfg.Threshold,
+		"recovery":   t.cfg.Recovery,
+		"window":     ErrorRateWindow.String(),
+	}
+}
+
+// TrackErrorRate records the status of every response in tracker, after
+// the error handler has rendered it, except for the routes named in skip
+// such as the health probes, whose 503s would otherwise feed back into the
+// rate they report
+func TrackErrorRate(tracker *ErrorRateTracker, skip ...string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		err := c.Next()
+		if slices.Contains(skip, c.Route().Name) {
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+		tracker.Record(status)
+		return err
+	}
+}
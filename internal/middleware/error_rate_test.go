@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newErrorRateTracker(cfg ErrorRateConfig) (*ErrorRateTracker, *clock.Frozen) {
+	clk := clock.NewFrozen(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC))
+	return NewErrorRateTracker(cfg).WithClock(clk.Now), clk
+}
+
+// traffic records perSecond responses a second for seconds, errors of
+// them with a 500
+func traffic(tracker *ErrorRateTracker, clk *clock.Frozen, seconds, perSecond, errors int) {
+	for range seconds {
+		for i := range perSecond {
+			status := fiber.StatusOK
+			if i < errors {
+				status = fiber.StatusInternalServerError
+			}
+			tracker.Record(status)
+		}
+		clk.Advance(time.Second)
+	}
+}
+
+func TestErrorRateTracker_Threshold(t *testing.T) {
+	tracker, clk := newErrorRateTracker(ErrorRateConfig{Threshold: 0.1, Recovery: 0.05, MinRequests: 100})
+
+	traffic(tracker, clk, 60, 20, 1)
+	rate := tracker.Rate()
+	assert.InDelta(t, 0.05, rate.Rate, 1e-9)
+	assert.Equal(t, int64(1200), rate.Requests)
+	assert.Equal(t, int64(60), rate.Errors)
+	assert.False(t, rate.Degraded)
+
+	// Another minute at 15% takes the window to 10%
+	traffic(tracker, clk, 60, 20, 3)
+	rate = tracker.Rate()
+	assert.InDelta(t, 0.1, rate.Rate, 1e-9)
+	assert.True(t, rate.Degraded, "the threshold itself degrades")
+}
+
+func TestErrorRateTracker_MinRequests(t *testing.T) {
+	tracker, clk := newErrorRateTracker(ErrorRateConfig{Threshold: 0.1, Recovery: 0.05, MinRequests: 100})
+
+	traffic(tracker, clk, 9, 10, 10)
+	rate := tracker.Rate()
+	assert.Equal(t, 1.0, rate.Rate)
+	assert.False(t, rate.Degraded, "90 requests are too few to tell")
+
+	traffic(tracker, clk, 1, 10, 10)
+	assert.True(t, tracker.Rate().Degraded)
+}
+
+func TestErrorRateTracker_Hysteresis(t *testing.T) {
+	buf := captureWarnings(t)
+	tracker, clk := newErrorRateTracker(ErrorRateConfig{Threshold: 0.1, Recovery: 0.05, MinRequests: 100})
+
+	traffic(tracker, clk, 300, 10, 2)
+	require.True(t, tracker.Rate().Degraded)
+
+	// Hovering between the recovery rate and the threshold doesn't flap
+	for range 5 {
+		traffic(tracker, clk, 300, 10, 1)
+		rate := tracker.Rate()
+		assert.InDelta(t, 0.1, rate.Rate, 1e-9)
+		assert.True(t, rate.Degraded)
+
+		traffic(tracker, clk, 300, 100, 8)
+		rate = tracker.Rate()
+		assert.InDelta(t, 0.08, rate.Rate, 1e-9)
+		assert.True(t, rate.Degraded, "still above the recovery rate")
+	}
+
+	traffic(tracker, clk, 300, 100, 4)
+	rate := tracker.Rate()
+	assert.InDelta(t, 0.04, rate.Rate, 1e-9)
+	assert.False(t, rate.Degraded)
+
+	// And back under the threshold the service stays healthy
+	traffic(tracker, clk, 300, 100, 9)
+	assert.False(t, tracker.Rate().Degraded)
+
+	logs := buf.String()
+	assert.Equal(t, 1, strings.Count(logs, "error rate above threshold, readiness degraded"), logs)
+	assert.Equal(t, 1, strings.Count(logs, "error rate recovered, readiness restored"), logs)
+	assert.Contains(t, logs, `"threshold":0.1`)
+}
+
+func TestErrorRateTracker_Window(t *testing.T) {
+	tracker, clk := newErrorRateTracker(ErrorRateConfig{Threshold: 0.1, Recovery: 0.05, MinRequests: 10})
+
+	traffic(tracker, clk, 10, 10, 10)
+	require.True(t, tracker.Rate().Degraded)
+
+	clk.Advance(ErrorRateWindow - time.Second)
+	traffic(tracker, clk, 1, 10, 0)
+	rate := tracker.Rate()
+	assert.Equal(t, int64(10), rate.Requests, "seconds older than the window are dropped")
+	assert.Equal(t, int64(0), rate.Errors)
+	assert.False(t, rate.Degraded)
+
+	clk.Advance(ErrorRateWindow)
+	assert.Equal(t, ErrorRate{}, tracker.Rate(), "an idle window is empty and healthy")
+}
+
+func TestErrorRateTracker_ZeroThresholdNeverDegrades(t *testing.T) {
+	tracker, clk := newErrorRateTracker(ErrorRateConfig{})
+	traffic(tracker, clk, 10, 10, 10)
+	rate := tracker.Rate()
+	assert.Equal(t, 1.0, rate.Rate)
+	assert.False(t, rate.Degraded)
+}
+
+func TestTrackErrorRate_RecordsRenderedStatus(t *testing.T) {
+	tracker, _ := newErrorRateTracker(ErrorRateConfig{Threshold: 0.5})
+	app := fiber.New()
+	app.Use(TrackErrorRate(tracker, "health.ready"))
+	app.Get("/ok", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	app.Get("/missing", func(c fiber.Ctx) error { return fiber.ErrNotFound })
+	app.Get("/busy", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusServiceUnavailable) })
+	app.Get("/gateway", func(c fiber.Ctx) error { return fiber.ErrBadGateway })
+	app.Get("/broken", func(c fiber.Ctx) error { return assert.AnError })
+	app.Get("/ready", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusServiceUnavailable) }).Name("health.ready")
+
+	for _, path := range []string{"/ok", "/missing", "/busy", "/gateway", "/broken", "/ready"} {
+		_, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		require.NoError(t, err)
+	}
+
+	rate := tracker.Rate()
+	assert.Equal(t, int64(5), rate.Requests, "the skipped route isn't counted")
+	assert.Equal(t, int64(3), rate.Errors)
+}
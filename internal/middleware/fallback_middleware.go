@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// RouteFallback answers requests no route handled. It is registered last on
+// a group: when the path exists under other methods it responds 405 with an
+// Allow header listing them, otherwise a JSON 404.
+func RouteFallback() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		allowed := allowedMethods(c.App(), c.Path())
+		if len(allowed) == 0 {
+			return NotFoundResponse(c, "no route for "+c.Method()+" "+c.Path())
+		}
+
+		// Fiber appends its automatic HEAD routes after every registered
+		// handler, so HEAD on a GET route reaches this point first
+		if c.Method() == fiber.MethodHead && slices.Contains(allowed, fiber.MethodGet) {
+			return c.Next()
+		}
+
+		c.Set(fiber.HeaderAllow, strings.Join(allowed, ", "))
+		return Respond(c, fiber.StatusMethodNotAllowed, ErrorResponse{
+			Error:   statusMessage(fiber.StatusMethodNotAllowed),
+			Message: "method " + c.Method() + " is not allowed on " + c.Path(),
+			Code:    fiber.StatusMethodNotAllowed,
+		})
+	}
+}
+
+// allowedMethods returns the sorted methods of the routes matching path
+func allowedMethods(app *fiber.App, path string) []string {
+	seen := make(map[string]bool)
+	for _, r := range app.GetRoutes(true) {
+		if !seen[r.Method] && fiber.RoutePatternMatch(path, r.Path, app.Config()) {
+			seen[r.Method] = true
+		}
+	}
+
+	out := make([]string, 0, len(seen))
+	for m := range seen {
+		out = append(out, m)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFallbackTestApp() *fiber.App {
+	app := fiber.New()
+	api := app.Group("/api/v1")
+	api.Use(ErrorHandler())
+	api.Post("/auth/signin", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	api.Get("/users/:id", func(c fiber.Ctx) error { return c.SendString(c.Params("id")) })
+	api.Put("/users/:id", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	api.Use(RouteFallback())
+	return app
+}
+
+func TestRouteFallback_UnknownPath(t *testing.T) {
+	resp, err := newFallbackTestApp().Test(httptest.NewRequest(http.MethodGet, "/api/v1/doesnotexist", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON)
+	assert.Empty(t, resp.Header.Get(fiber.HeaderAllow))
+
+	var body ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "not_found", body.Error)
+	assert.Equal(t, http.StatusNotFound, body.Code)
+}
+
+func TestRouteFallback_WrongMethod(t *testing.T) {
+	app := newFallbackTestApp()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodDelete, "/api/v1/auth/signin", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "POST", resp.Header.Get(fiber.HeaderAllow))
+
+	var body ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "method_not_allowed", body.Error)
+	assert.Equal(t, http.StatusMethodNotAllowed, body.Code)
+
+	// Parameterised paths are matched against the route patterns
+	resp, err = app.Test(httptest.NewRequest(http.MethodDelete, "/api/v1/users/42", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "GET, HEAD, PUT", resp.Header.Get(fiber.HeaderAllow))
+}
+
+func TestRouteFallback_HeadOnGetRoute(t *testing.T) {
+	resp, err := newFallbackTestApp().Test(httptest.NewRequest(http.MethodHead, "/api/v1/users/42", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+)
+
+// HeaderFaultInjected names the faults injected into a response, e.g.
+// "latency=200ms" or "error=503".
+const HeaderFaultInjected = "X-Fault-Injected"
+
+// Fault kinds
+const (
+	FaultLatency = "latency"
+	FaultError   = "error"
+)
+
+// ErrFaultInjectionDisabled is returned when faults are configured on an
+// injector that runs in production.
+var ErrFaultInjectionDisabled = errors.New("fault injection is disabled in production")
+
+// FaultRule injects one kind of fault into a fraction of the requests whose
+// path starts with PathPrefix. An empty prefix matches every request.
+type FaultRule struct {
+	Kind       string
+	Latency    time.Duration
+	Status     int
+	Fraction   float64
+	PathPrefix string
+}
+
+// String returns the rule in the FAULT_INJECTION syntax.
+func (r FaultRule) String() string {
+	value := strconv.Itoa(r.Status)
+	if r.Kind == FaultLatency {
+		value = r.Latency.String()
+	}
+	s := r.Kind + ":" + value + ":" + strconv.FormatFloat(r.Fraction, 'g', -1, 64)
+	if r.PathPrefix != "" {
+		s += ":" + r.PathPrefix
+	}
+	return s
+}
+
+// ParseFaultRules parses a comma-separated list of rules of the form
+// kind:value:fraction[:path_prefix], such as
+// "latency:200ms:0.1,error:503:0.05:/api/v1/users". Latency values are
+// durations, error values are 4xx or 5xx statuses, and fractions lie in
+// (0, 1]. An empty spec means no rules.
+func ParseFaultRules(spec string) ([]FaultRule, error) {
+	var rules []FaultRule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 4)
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("fault rule %q: want kind:value:fraction[:path_prefix]", item)
+		}
+
+		rule := FaultRule{Kind: parts[0]}
+		switch rule.Kind {
+		case FaultLatency:
+			d, err := time.ParseDuration(parts[1])
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("fault rule %q: latency must be a positive duration", item)
+			}
+			rule.Latency = d
+		case FaultError:
+			status, err := strconv.Atoi(parts[1])
+			if err != nil || status < 400 || status > 599 {
+				return nil, fmt.Errorf("fault rule %q: status must be between 400 and 599", item)
+			}
+			rule.Status = status
+		default:
+			return nil, fmt.Errorf("fault rule %q: kind must be latency or error", item)
+		}
+
+		fraction, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || fraction <= 0 || fraction > 1 {
+			return nil, fmt.Errorf("fault rule %q: fraction must be in (0, 1]", item)
+		}
+		rule.Fraction = fraction
+
+		if len(parts) == 4 {
+			if !strings.HasPrefix(parts[3], "/") {
+				return nil, fmt.Errorf("fault rule %q: path prefix must start with /", item)
+			}
+			rule.PathPrefix = parts[3]
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// FaultInjector holds the fault rules FaultInjection applies. Rules can be
+// replaced at runtime, e.g. from the admin API. In production the injector
+// is hard-disabled: it holds no rules and refuses new ones.
+type FaultInjector struct {
+	disabled bool
+
+	mu    sync.Mutex
+	rules []FaultRule
+	rng   *rand.Rand
+}
+
+// NewFaultInjector creates an injector with the given rules. When
+// production is set the rules are dropped and the injector stays empty.
+func NewFaultInjector(rules []FaultRule, production bool) *FaultInjector {
+	f := &FaultInjector{
+		disabled: production,
+		rng:      rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+	}
+	if !production {
+		f.rules = rules
+	}
+	return f
+}
+
+// WithRand replaces the random source, so tests can seed it and get the
+// same injections on every run.
+func (f *FaultInjector) WithRand(rng *rand.Rand) *FaultInjector {
+	f.rng = rng
+	return f
+}
+
+// Enabled reports whether the injector may inject faults at all.
+func (f *FaultInjector) Enabled() bool { return !f.disabled }
+
+// Rules returns the current rules.
+func (f *FaultInjector) Rules() []FaultRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FaultRule(nil), f.rules...)
+}
+
+// SetRules replaces the rules; no rules turns injection off. It returns
+// ErrFaultInjectionDisabled in production.
+func (f *FaultInjector) SetRules(rules []FaultRule) error {
+	if f.disabled {
+		return ErrFaultInjectionDisabled
+	}
+	f.mu.Lock()
+	f.rules = append([]FaultRule(nil), rules...)
+	f.mu.Unlock()
+	return nil
+}
+
+// faults are what pick chose for one request
+type faults struct {
+	latency time.Duration
+	status  int
+	applied []string
+}
+
+// pick rolls each rule matching path once
+func (f *FaultInjector) pick(path string) faults {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out faults
+	for _, r := range f.rules {
+		if !strings.HasPrefix(path, r.PathPrefix) || f.rng.Float64() >= r.Fraction {
+			continue
+		}
+		switch r.Kind {
+		case FaultLatency:
+			out.latency += r.Latency
+			out.applied = append(out.applied, "latency="+r.Latency.String())
+		case FaultError:
+			if out.status == 0 {
+				out.status = r.Status
+				out.applied = append(out.applied, "error="+strconv.Itoa(r.Status))
+			}
+		}
+	}
+	return out
+}
+
+// FaultInjection delays or fails a random fraction of requests according
+// to the injector's rules, for testing how clients and dashboards cope with
+// a slow or failing service. Injected errors are rendered as the usual
+// ErrorResponse, and every affected response carries X-Fault-Injected.
+//
+// It returns nil for a disabled injector, so a production stack never
+// contains the layer.
+func FaultInjection(f *FaultInjector) fiber.Handler {
+	if f == nil || !f.Enabled() {
+		return nil
+	}
+	return func(c fiber.Ctx) error {
+		picked := f.pick(c.Path())
+		if len(picked.applied) == 0 {
+			return c.Next()
+		}
+
+		c.Set(HeaderFaultInjected, strings.Join(picked.applied, ", "))
+		logger.Debug("fault injected", map[string]any{
+			"path":   c.Path(),
+			"method": c.Method(),
+			"faults": picked.applied,
+		})
+
+		if picked.latency > 0 {
+			timer := time.NewTimer(picked.latency)
+			select {
+			case <-timer.C:
+			case <-c.Context().Done():
+				timer.Stop()
+			}
+		}
+		if picked.status != 0 {
+			return Respond(c, picked.status, ErrorResponse{
+				Error:   statusMessage(picked.status),
+				Message: "fault injected",
+				Code:    picked.status,
+			})
+		}
+		return c.Next()
+	}
+}
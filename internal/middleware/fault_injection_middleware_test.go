@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seededRand() *rand.Rand { return rand.New(rand.NewPCG(1, 2)) }
+
+func TestParseFaultRules(t *testing.T) {
+	rules, err := ParseFaultRules(" latency:200ms:0.1, error:503:0.05:/api/v1/users ,")
+	require.NoError(t, err)
+	assert.Equal(t, []FaultRule{
+		{Kind: FaultLatency, Latency: 200 * time.Millisecond, Fraction: 0.1},
+		{Kind: FaultError, Status: 503, Fraction: 0.05, PathPrefix: "/api/v1/users"},
+	}, rules)
+	assert.Equal(t, "latency:200ms:0.1", rules[0].String())
+	assert.Equal(t, "error:503:0.05:/api/v1/users", rules[1].String())
+
+	rules, err = ParseFaultRules("")
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, bad := range []string{"latency:200ms", "latency:fast:0.1", "error:200:0.1", "error:503:1.5", "error:503:0", "drop:1:0.1", "error:503:0.1:api"} {
+		_, err := ParseFaultRules(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestFaultInjection_Proportions(t *testing.T) {
+	rules, err := ParseFaultRules("latency:1ms:0.1,error:503:0.05")
+	require.NoError(t, err)
+	f := NewFaultInjector(rules, false).WithRand(seededRand())
+
+	const n = 20000
+	var delayed, failed int
+	for range n {
+		picked := f.pick("/api/v1/users")
+		if picked.latency > 0 {
+			delayed++
+		}
+		if picked.status != 0 {
+			failed++
+		}
+	}
+	assert.InDelta(t, 0.1, float64(delayed)/n, 0.01)
+	assert.InDelta(t, 0.05, float64(failed)/n, 0.01)
+
+	// The same seed injects the same faults
+	again := NewFaultInjector(rules, false).WithRand(seededRand())
+	first := NewFaultInjector(rules, false).WithRand(seededRand())
+	for range 100 {
+		assert.Equal(t, first.pick("/x"), again.pick("/x"))
+	}
+}
+
+func TestFaultInjection_Responses(t *testing.T) {
+	rules, err := ParseFaultRules("error:503:0.5:/api/v1/users")
+	require.NoError(t, err)
+	f := NewFaultInjector(rules, false).WithRand(seededRand())
+
+	app := fiber.New()
+	app.Use(FaultInjection(f))
+	app.Get("/*", func(c fiber.Ctx) error { return c.SendString("ok") })
+
+	call := func(path string) *http.Response {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		require.NoError(t, err)
+		return resp
+	}
+
+	var failed int
+	for range 200 {
+		resp := call("/api/v1/users/me")
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			failed++
+			assert.Equal(t, "error=503", resp.Header.Get(HeaderFaultInjected))
+			var body ErrorResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, "service_unavailable", body.Error)
+			assert.Equal(t, http.StatusServiceUnavailable, body.Code)
+		} else {
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get(HeaderFaultInjected))
+		}
+		resp.Body.Close()
+	}
+	assert.InDelta(t, 100, failed, 20)
+
+	// Paths outside the prefix are never touched
+	for range 50 {
+		resp := call("/api/v1/auth/signin")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	}
+
+	// Clearing the rules stops injection
+	require.NoError(t, f.SetRules(nil))
+	for range 50 {
+		resp := call("/api/v1/users/me")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	}
+}
+
+func TestFaultInjection_Latency(t *testing.T) {
+	rules, err := ParseFaultRules("latency:20ms:1")
+	require.NoError(t, err)
+	app := fiber.New()
+	app.Use(FaultInjection(NewFaultInjector(rules, false)))
+	app.Get("/", func(c fiber.Ctx) error { return c.SendString("ok") })
+
+	start := time.Now()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "latency=20ms", resp.Header.Get(HeaderFaultInjected))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestFaultInjection_DisabledInProduction(t *testing.T) {
+	rules, err := ParseFaultRules("error:503:1")
+	require.NoError(t, err)
+	f := NewFaultInjector(rules, true)
+
+	assert.False(t, f.Enabled())
+	assert.Empty(t, f.Rules())
+	assert.Nil(t, FaultInjection(f), "no layer in production")
+	assert.ErrorIs(t, f.SetRules(rules), ErrFaultInjectionDisabled)
+	assert.Empty(t, f.pick("/"))
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import "github.com/gofiber/fiber/v3"
+
+// FeatureGate reports whether a feature flag is on
+type FeatureGate interface {
+	Enabled(name string) bool
+}
+
+// RequireFeature hides the routes behind it while the named feature is off.
+// It responds exactly like an unregistered route (404, not 403) so dark
+// endpoints aren't advertised. The flag is checked on every request, so
+// runtime toggles take effect immediately.
+func RequireFeature(gate FeatureGate, name string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if !gate.Enabled(name) {
+			return fiber.ErrNotFound
+		}
+		return c.Next()
+	}
+}
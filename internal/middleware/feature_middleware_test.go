@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dvith.com/go-service-api/internal/features"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireFeature(t *testing.T) {
+	flags := features.NewFlags(nil)
+
+	app := fiber.New()
+	api := app.Group("/api", ErrorHandler())
+	api.Get("/dark", RequireFeature(flags, "signup_v2"), func(c fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	get := func(path string) (int, string) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	// Off: indistinguishable from a route that doesn't exist
+	code, body := get("/api/dark")
+	assert.Equal(t, http.StatusNotFound, code)
+	_, missing := get("/api/missing")
+	assert.Equal(t, missing, body)
+
+	// Flipped on at runtime, without re-registering routes
+	require.NoError(t, flags.Set("signup_v2", true))
+	code, body = get("/api/dark")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body)
+
+	// And back off
+	require.NoError(t, flags.Set("signup_v2", false))
+	code, _ = get("/api/dark")
+	assert.Equal(t, http.StatusNotFound, code)
+}
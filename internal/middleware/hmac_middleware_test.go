@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/httpclient"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var partnerKey = httpclient.HMACKey{ID: "partner-1", Secret: []byte("partner-secret")}
+
+func newHMACApp(now time.Time) *fiber.App {
+	app := fiber.New()
+	app.Use(HMACAuth(HMACConfig{
+		Keys: StaticHMACKeys{
+			partnerKey.ID: {Secret: partnerKey.Secret, Principal: ServicePrincipal{Name: "partner", Roles: []string{"partner"}}},
+		},
+		Nonces: cache.NewMemory().WithClock(func() time.Time { return now }),
+		Now:    func() time.Time { return now },
+	}))
+	app.Post("/orders", func(c fiber.Ctx) error {
+		p, err := GetServicePrincipal(c)
+		if err != nil {
+			return err
+		}
+		return c.SendString(p.Name + " " + p.KeyID)
+	})
+	return app
+}
+
+// signedOrder is a POST /orders signed with key at signedAt
+func signedOrder(t *testing.T, key httpclient.HMACKey, signedAt time.Time, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/orders?limit=5", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	require.NoError(t, httpclient.SignRequest(req, key, signedAt))
+	return req
+}
+
+func TestHMACAuth_ValidSignature(t *testing.T) {
+	now := time.Now()
+	app := newHMACApp(now)
+
+	resp, err := app.Test(signedOrder(t, partnerKey, now.Add(-time.Minute), `{"sku":"a"}`))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "partner partner-1", string(body))
+}
+
+func TestHMACAuth_TamperedRequest(t *testing.T) {
+	now := time.Now()
+	app := newHMACApp(now)
+
+	body := signedOrder(t, partnerKey, now, `{"sku":"a"}`)
+	body.Body = io.NopCloser(strings.NewReader(`{"sku":"b"}`))
+
+	query := signedOrder(t, partnerKey, now, `{"sku":"a"}`)
+	query.URL.RawQuery = "limit=500"
+	query.RequestURI = query.URL.RequestURI()
+
+	for name, req := range map[string]*http.Request{"body": body, "query": query} {
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode, name)
+	}
+}
+
+func TestHMACAuth_ClockSkew(t *testing.T) {
+	now := time.Now()
+	app := newHMACApp(now)
+
+	for _, skew := range []time.Duration{-6 * time.Minute, 6 * time.Minute} {
+		resp, err := app.Test(signedOrder(t, partnerKey, now.Add(skew), `{}`))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode, "skew %s", skew)
+	}
+
+	resp, err := app.Test(signedOrder(t, partnerKey, now.Add(4*time.Minute), `{}`))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+func TestHMACAuth_NonceReplay(t *testing.T) {
+	now := time.Now()
+	app := newHMACApp(now)
+
+	first := signedOrder(t, partnerKey, now, `{}`)
+	replay := httptest.NewRequest(http.MethodPost, "/orders?limit=5", strings.NewReader(`{}`))
+	replay.Header = first.Header.Clone()
+
+	resp, err := app.Test(first)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(replay)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestHMACAuth_UnknownOrMissingKey(t *testing.T) {
+	now := time.Now()
+	app := newHMACApp(now)
+
+	unknown := signedOrder(t, httpclient.HMACKey{ID: "someone", Secret: partnerKey.Secret}, now, `{}`)
+	wrongSecret := signedOrder(t, httpclient.HMACKey{ID: partnerKey.ID, Secret: []byte("guess")}, now, `{}`)
+	bearer := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	bearer.Header.Set(fiber.HeaderAuthorization, "Bearer token")
+
+	for name, req := range map[string]*http.Request{"unknown key": unknown, "wrong secret": wrongSecret, "bearer": bearer} {
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode, name)
+	}
+}
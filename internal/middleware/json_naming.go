@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// JSON key conventions. Handlers always encode snake_case; JSONNaming
+// rewrites it for clients that want camelCase.
+const (
+	NamingSnake = "snake"
+	NamingCamel = "camel"
+)
+
+// HeaderJSONNaming set to snake or camel overrides the configured naming
+// for one request. Other values are ignored.
+const HeaderJSONNaming = "X-JSON-Naming"
+
+// localsNaming holds the naming chosen for the request
+const localsNaming = "json_naming"
+
+// ValidNaming reports whether naming is NamingSnake or NamingCamel
+func ValidNaming(naming string) bool {
+	return naming == NamingSnake || naming == NamingCamel
+}
+
+// JSONNaming is middleware that renames the keys of JSON response bodies,
+// and of MessagePack ones encoded by Respond, to camelCase when naming is
+// NamingCamel or the request asks for it with HeaderJSONNaming. Every
+// object key is renamed, map keys included, so {"by_reason": {"bad_password": 1}}
+// becomes {"byReason": {"badPassword": 1}}. Snake case bodies are left
+// untouched.
+//
+// It must wrap the error handler, so rendered errors are renamed too.
+func JSONNaming(naming string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		chosen := naming
+		if h := strings.ToLower(strings.TrimSpace(c.Get(HeaderJSONNaming))); ValidNaming(h) {
+			chosen = h
+		}
+		c.Locals(localsNaming, chosen)
+		c.Vary(HeaderJSONNaming)
+
+		err := c.Next()
+		if err != nil || chosen != NamingCamel {
+			return err
+		}
+
+		resp := c.Response()
+		if resp.IsBodyStream() || !strings.HasPrefix(string(resp.Header.ContentType()), fiber.MIMEApplicationJSON) {
+			return nil
+		}
+		if body, ok := camelJSON(resp.Body()); ok {
+			resp.SetBodyRaw(body)
+		}
+		return nil
+	}
+}
+
+// namingOf returns the naming JSONNaming chose for the request, snake case
+// when it isn't installed
+func namingOf(c fiber.Ctx) string {
+	if naming, ok := c.Locals(localsNaming).(string); ok {
+		return naming
+	}
+	return NamingSnake
+}
+
+// camelJSON re-encodes a JSON document with camelCase keys. ok is false
+// when raw isn't valid JSON, which is then sent as it is.
+func camelJSON(raw []byte) (out []byte, ok bool) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, false
+	}
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false
+	}
+	out, err := json.Marshal(camelKeys(doc))
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// camelKeys renames the keys of every object in a decoded JSON document
+func camelKeys(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			out[CamelCase(k)] = camelKeys(val)
+		}
+		return out
+	case []any:
+		for i, val := range t {
+			t[i] = camelKeys(val)
+		}
+	}
+	return v
+}
+
+// CamelCase converts a snake_case key to camelCase: access_token becomes
+// accessToken and users_created_24h usersCreated24h. Leading underscores
+// and keys without one are kept.
+func CamelCase(key string) string {
+	if !strings.Contains(strings.TrimLeft(key, "_"), "_") {
+		return key
+	}
+	trimmed := strings.TrimLeft(key, "_")
+	var b strings.Builder
+	b.Grow(len(key))
+	b.WriteString(key[:len(key)-len(trimmed)])
+	upper := false
+	for i, r := range trimmed {
+		if r == '_' {
+			upper = i > 0
+			continue
+		}
+		if upper && r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
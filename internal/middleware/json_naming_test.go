@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/shamaton/msgpack/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCamelCase(t *testing.T) {
+	tests := map[string]string{
+		"access_token":      "accessToken",
+		"users_created_24h": "usersCreated24h",
+		"json_path":         "jsonPath",
+		"id":                "id",
+		"alreadyCamel":      "alreadyCamel",
+		"_links":            "_links",
+		"_meta_data":        "_metaData",
+		"a__b":              "aB",
+		"":                  "",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, CamelCase(in), in)
+	}
+}
+
+func namingApp(naming string) *fiber.App {
+	app := fiber.New()
+	app.Use(JSONNaming(naming), ErrorHandler())
+	app.Get("/ok", func(c fiber.Ctx) error {
+		return c.JSON(fiber.Map{"access_token": "t", "user": fiber.Map{"full_name": "Jo", "created_at": "2025"}, "items": []fiber.Map{{"item_id": 1}}})
+	})
+	app.Get("/fields", func(c fiber.Ctx) error {
+		return FieldErrorsResponse(c, []FieldError{{Field: "Password", JSONPath: "/password", Code: "too_short", Params: Params{"min": "8"}, Message: "too short"}})
+	})
+	app.Get("/fail", func(c fiber.Ctx) error {
+		return errors.New("boom")
+	})
+	app.Get("/text", func(c fiber.Ctx) error {
+		return c.SendString(`{"not_json_content_type": true}`)
+	})
+	app.Get("/msgpack", func(c fiber.Ctx) error {
+		return OK(c, fiber.Map{"token_type": "Bearer"})
+	})
+	return app
+}
+
+func getNamed(t *testing.T, app *fiber.App, path, header, accept string) (*http.Response, []byte) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if header != "" {
+		req.Header.Set(HeaderJSONNaming, header)
+	}
+	if accept != "" {
+		req.Header.Set(fiber.HeaderAccept, accept)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, raw
+}
+
+func TestJSONNaming_Camel(t *testing.T) {
+	app := namingApp(NamingCamel)
+
+	resp, raw := getNamed(t, app, "/ok", "", "")
+	assert.JSONEq(t, `{"accessToken":"t","user":{"fullName":"Jo","createdAt":"2025"},"items":[{"itemId":1}]}`, string(raw))
+	assert.Contains(t, resp.Header.Values(fiber.HeaderVary), HeaderJSONNaming)
+
+	resp, raw = getNamed(t, app, "/fields", "", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(raw, &fields))
+	assert.Equal(t, []any{map[string]any{"field": "Password", "jsonPath": "/password", "code": "too_short", "params": map[string]any{"min": "8"}, "message": "too short"}}, fields["fields"], "codes are values and keep their case")
+
+	resp, raw = getNamed(t, app, "/fail", "", "")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.JSONEq(t, `{"code":500,"error":"internal_error","message":"An unexpected error occurred"}`, string(raw), "error codes are values and keep their case")
+
+	_, raw = getNamed(t, app, "/text", "", "")
+	assert.Equal(t, `{"not_json_content_type": true}`, string(raw), "only JSON bodies are rewritten")
+
+	_, raw = getNamed(t, app, "/msgpack", "", MIMEMsgPack)
+	var packed map[string]any
+	require.NoError(t, msgpack.Unmarshal(raw, &packed))
+	assert.Equal(t, map[string]any{"tokenType": "Bearer"}, packed)
+}
+
+func TestJSONNaming_HeaderOverrides(t *testing.T) {
+	_, raw := getNamed(t, namingApp(NamingSnake), "/ok", "camel", "")
+	assert.Contains(t, string(raw), `"accessToken"`)
+
+	_, raw = getNamed(t, namingApp(NamingCamel), "/ok", "Snake", "")
+	assert.Contains(t, string(raw), `"access_token"`)
+
+	_, raw = getNamed(t, namingApp(NamingSnake), "/ok", "kebab", "")
+	assert.Contains(t, string(raw), `"access_token"`, "unknown values are ignored")
+}
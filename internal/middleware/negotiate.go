@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/shamaton/msgpack/v3"
+)
+
+// Response media types Respond can encode. application/vnd.msgpack and
+// MIMEMsgPackAlt are accepted as aliases of MIMEMsgPack.
+const (
+	MIMEJSON       = fiber.MIMEApplicationJSON
+	MIMEXML        = fiber.MIMEApplicationXML
+	MIMEMsgPack    = "application/msgpack"
+	MIMEMsgPackAlt = "application/x-msgpack"
+)
+
+// offers is what Respond can produce, in the order Accepts breaks ties
+var offers = []string{MIMEJSON, MIMEXML, MIMEMsgPack, fiber.MIMEApplicationMsgPack, MIMEMsgPackAlt}
+
+// Negotiate is middleware that rejects requests whose Accept header names
+// no encoding Respond supports with 406 Not Acceptable. Without it such
+// requests get JSON.
+func Negotiate() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if c.Get(fiber.HeaderAccept) != "" && c.Accepts(offers...) == "" {
+			return c.Status(fiber.StatusNotAcceptable).JSON(ErrorResponse{
+				Error:   "not_acceptable",
+				Message: "supported response types are application/json, application/xml and application/msgpack",
+				Code:    fiber.StatusNotAcceptable,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// Respond writes v with status in the encoding the Accept header prefers:
+// JSON, XML or MessagePack. JSON is used when the header is missing or
+// names nothing supported.
+//
+// XML element names come from the xml struct tags; values XML can't encode,
+// such as maps, are sent as JSON instead. MessagePack carries the same
+// field names and values as the JSON encoding, so DTOs need no msgpack tags.
+func Respond(c fiber.Ctx, status int, v any) error {
+	c.Status(status)
+	switch mime := c.Accepts(offers...); mime {
+	case MIMEXML:
+		body, err := xml.Marshal(v)
+		var unsupported *xml.UnsupportedTypeError
+		if errors.As(err, &unsupported) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+		return c.Send(append([]byte(xml.Header), body...))
+	case MIMEMsgPack, fiber.MIMEApplicationMsgPack, MIMEMsgPackAlt:
+		body, err := encodeMsgPack(v, namingOf(c) == NamingCamel)
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, mime)
+		return c.Send(body)
+	}
+	return c.JSON(v)
+}
+
+// OK writes v with 200 OK, see Respond
+func OK(c fiber.Ctx, v any) error {
+	return Respond(c, fiber.StatusOK, v)
+}
+
+// Created writes v with 201 Created, see Respond
+func Created(c fiber.Ctx, v any) error {
+	return Respond(c, fiber.StatusCreated, v)
+}
+
+// encodeMsgPack encodes v's JSON form, so MessagePack clients see the same
+// names and values (UUIDs and timestamps as strings) as JSON clients. With
+// camel, keys are renamed as JSONNaming renames them in JSON bodies.
+func encodeMsgPack(v any, camel bool) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	if camel {
+		generic = camelKeys(generic)
+	}
+	return msgpack.Marshal(numbers(generic))
+}
+
+// numbers replaces json.Number with int64 when the value is integral and
+// float64 otherwise, so integers stay integers on the wire
+func numbers(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			t[k] = numbers(val)
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = numbers(val)
+		}
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n
+		}
+		f, _ := t.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/shamaton/msgpack/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func negotiate(t *testing.T, app *fiber.App, accept string) (*http.Response, []byte) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if accept != "" {
+		req.Header.Set(fiber.HeaderAccept, accept)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, raw
+}
+
+func TestRespond_ErrorResponseEncodings(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) error {
+		return NotFoundResponse(c, "user not found")
+	})
+
+	resp, raw := negotiate(t, app, "application/json")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	var fromJSON ErrorResponse
+	require.NoError(t, json.Unmarshal(raw, &fromJSON))
+	assert.Equal(t, "not_found", fromJSON.Error)
+	assert.Equal(t, "user not found", fromJSON.Message)
+
+	resp, raw = negotiate(t, app, "application/xml")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get(fiber.HeaderContentType), MIMEXML)
+	assert.Contains(t, string(raw), "<error><error>not_found</error><message>user not found</message><code>404</code></error>")
+	var fromXML ErrorResponse
+	require.NoError(t, xml.Unmarshal(raw, &fromXML))
+	assert.Equal(t, fromJSON.Error, fromXML.Error)
+	assert.Equal(t, fromJSON.Code, fromXML.Code)
+
+	resp, raw = negotiate(t, app, "application/msgpack")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, MIMEMsgPack, resp.Header.Get(fiber.HeaderContentType))
+	var fromMsgPack map[string]any
+	require.NoError(t, msgpack.Unmarshal(raw, &fromMsgPack))
+	assert.Equal(t, "not_found", fromMsgPack["error"])
+	assert.Equal(t, "user not found", fromMsgPack["message"])
+	assert.EqualValues(t, 404, fromMsgPack["code"])
+}
+
+func TestRespond_ValidationErrorsInXML(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) error {
+		return FieldErrorsResponse(c, []FieldError{{Field: "Email", Message: "Email is required"}})
+	})
+
+	_, raw := negotiate(t, app, "application/xml")
+	assert.Contains(t, string(raw), "<fields><field_error><field>Email</field><message>Email is required</message></field_error></fields>")
+	assert.NotContains(t, string(raw), "<errors>", "the legacy list is opt-in")
+}
+
+func TestRespond_PrefersByQuality(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) error {
+		return OK(c, ErrorResponse{Error: "x"})
+	})
+
+	resp, _ := negotiate(t, app, "application/json;q=0.5, application/xml")
+	assert.Contains(t, resp.Header.Get(fiber.HeaderContentType), MIMEXML)
+
+	resp, _ = negotiate(t, app, "")
+	assert.Contains(t, resp.Header.Get(fiber.HeaderContentType), MIMEJSON)
+
+	resp, _ = negotiate(t, app, "*/*")
+	assert.Contains(t, resp.Header.Get(fiber.HeaderContentType), MIMEJSON)
+}
+
+func TestRespond_FallsBackToJSON(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) error {
+		return Created(c, fiber.Map{"status": "ok"})
+	})
+
+	// Unsupported media type
+	resp, raw := negotiate(t, app, "text/csv")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get(fiber.HeaderContentType), MIMEJSON)
+	assert.JSONEq(t, `{"status":"ok"}`, string(raw))
+
+	// Maps have no XML form
+	resp, raw = negotiate(t, app, "application/xml")
+	assert.Contains(t, resp.Header.Get(fiber.HeaderContentType), MIMEJSON)
+	assert.JSONEq(t, `{"status":"ok"}`, string(raw))
+}
+
+func TestNegotiate_RejectsUnsupportedAccept(t *testing.T) {
+	app := fiber.New()
+	app.Use(Negotiate())
+	app.Get("/", func(c fiber.Ctx) error {
+		return OK(c, ErrorResponse{Error: "x"})
+	})
+
+	resp, _ := negotiate(t, app, "text/csv")
+	assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+
+	for _, accept := range []string{"", "*/*", "application/xml", "application/x-msgpack", "text/csv, application/json;q=0.1"} {
+		resp, _ := negotiate(t, app, accept)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, accept)
+	}
+}
+
+func TestRespond_ValidationParamsInXML(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) error {
+		return FieldErrorsResponse(c, []FieldError{{Field: "Password", JSONPath: "/password", Code: "too_short", Params: Params{"min": "8"}, Message: "Password must be at least 8 characters"}})
+	})
+
+	_, raw := negotiate(t, app, "application/xml")
+	assert.Contains(t, string(raw), `<json_path>/password</json_path><code>too_short</code><params><param name="min">8</param></params>`)
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// BadParamError reports a path parameter that is missing or malformed.
+// ErrorHandler renders it as a 400 naming the parameter.
+type BadParamError struct {
+	Param  string
+	Reason string
+}
+
+func (e *BadParamError) Error() string {
+	return fmt.Sprintf("invalid path parameter %q: %s", e.Param, e.Reason)
+}
+
+// Rule checks a single path parameter value and returns the reason it is
+// invalid, or "" when it is acceptable
+type Rule func(value string) string
+
+// UUIDRule accepts canonical UUIDs
+func UUIDRule() Rule {
+	return func(value string) string {
+		if _, err := uuid.Parse(value); err != nil {
+			return "must be a UUID"
+		}
+		return ""
+	}
+}
+
+// IntRule accepts base-10 integers between min and max inclusive
+func IntRule(min, max int) Rule {
+	return func(value string) string {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "must be an integer"
+		}
+		if n < min || n > max {
+			return fmt.Sprintf("must be between %d and %d", min, max)
+		}
+		return ""
+	}
+}
+
+// PatternRule accepts values matching re; hint describes the expected form
+func PatternRule(re *regexp.Regexp, hint string) Rule {
+	return func(value string) string {
+		if !re.MatchString(value) {
+			return "must be " + hint
+		}
+		return ""
+	}
+}
+
+// ParamUUID returns the named path parameter parsed as a UUID
+func ParamUUID(c fiber.Ctx, name string) (uuid.UUID, error) {
+	value, err := checkParam(c, name, UUIDRule())
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return uuid.MustParse(value), nil
+}
+
+// ParamInt returns the named path parameter parsed as an integer between min
+// and max inclusive
+func ParamInt(c fiber.Ctx, name string, min, max int) (int, error) {
+	value, err := checkParam(c, name, IntRule(min, max))
+	if err != nil {
+		return 0, err
+	}
+	n, _ := strconv.Atoi(value)
+	return n, nil
+}
+
+// ValidateParams rejects the request before the handler runs when any of the
+// named path parameters breaks its rule
+func ValidateParams(rules map[string]Rule) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		for name, rule := range rules {
+			if _, err := checkParam(c, name, rule); err != nil {
+				return err
+			}
+		}
+		return c.Next()
+	}
+}
+
+func checkParam(c fiber.Ctx, name string, rule Rule) (string, error) {
+	value := c.Params(name)
+	if value == "" {
+		return "", &BadParamError{Param: name, Reason: "is required"}
+	}
+	if reason := rule(value); reason != "" {
+		return "", &BadParamError{Param: name, Reason: reason}
+	}
+	return value, nil
+}
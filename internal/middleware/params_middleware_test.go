@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newParamsTestApp() *fiber.App {
+	app := fiber.New()
+	api := app.Group("/api", ErrorHandler())
+	api.Get("/users/:id?", func(c fiber.Ctx) error {
+		id, err := ParamUUID(c, "id")
+		if err != nil {
+			return err
+		}
+		return c.SendString(id.String())
+	})
+	api.Get("/pages/:n", func(c fiber.Ctx) error {
+		n, err := ParamInt(c, "n", 1, 100)
+		if err != nil {
+			return err
+		}
+		return c.JSON(fiber.Map{"n": n})
+	})
+	api.Get("/features/:name/:id",
+		ValidateParams(map[string]Rule{
+			"name": PatternRule(regexp.MustCompile(`^[a-z_]+$`), "lower_snake_case"),
+			"id":   UUIDRule(),
+		}),
+		func(c fiber.Ctx) error { return c.SendString("ok") },
+	)
+	return app
+}
+
+func getParamError(t *testing.T, app *fiber.App, path string) (int, ErrorResponse) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+	require.NoError(t, err)
+
+	var body ErrorResponse
+	if resp.StatusCode != http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	}
+	return resp.StatusCode, body
+}
+
+func TestParamUUID(t *testing.T) {
+	app := newParamsTestApp()
+
+	code, _ := getParamError(t, app, "/api/users/"+uuid.NewString())
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body := getParamError(t, app, "/api/users/not-a-uuid")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "bad_request", body.Error)
+	assert.Equal(t, `invalid path parameter "id": must be a UUID`, body.Message)
+
+	code, body = getParamError(t, app, "/api/users/")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, `invalid path parameter "id": is required`, body.Message)
+}
+
+func TestParamInt(t *testing.T) {
+	app := newParamsTestApp()
+
+	tests := []struct {
+		path    string
+		code    int
+		message string
+	}{
+		{"/api/pages/1", http.StatusOK, ""},
+		{"/api/pages/100", http.StatusOK, ""},
+		{"/api/pages/0", http.StatusBadRequest, `invalid path parameter "n": must be between 1 and 100`},
+		{"/api/pages/101", http.StatusBadRequest, `invalid path parameter "n": must be between 1 and 100`},
+		{"/api/pages/abc", http.StatusBadRequest, `invalid path parameter "n": must be an integer`},
+		{"/api/pages/99999999999999999999", http.StatusBadRequest, `invalid path parameter "n": must be an integer`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			code, body := getParamError(t, app, tt.path)
+			assert.Equal(t, tt.code, code)
+			assert.Equal(t, tt.message, body.Message)
+		})
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	app := newParamsTestApp()
+
+	code, _ := getParamError(t, app, "/api/features/dark_mode/"+uuid.NewString())
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body := getParamError(t, app, "/api/features/Dark-Mode/"+uuid.NewString())
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body.Message, `"name": must be lower_snake_case`)
+
+	code, body = getParamError(t, app, "/api/features/dark_mode/123")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body.Message, `"id": must be a UUID`)
+}
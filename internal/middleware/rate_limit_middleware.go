@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"dvith.com/go-service-api/internal/requestmeta"
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/ratelimit"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// Rate limit header names. The X- names are the de facto standard; the
+// others follow the IETF RateLimit header fields draft.
+const (
+	HeaderXRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderXRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderXRateLimitReset     = "X-RateLimit-Reset"
+	HeaderRateLimitLimit      = "RateLimit-Limit"
+	HeaderRateLimitRemaining  = "RateLimit-Remaining"
+	HeaderRateLimitReset      = "RateLimit-Reset"
+)
+
+// RateLimitConfig configures RateLimit.
+type RateLimitConfig struct {
+	Store  ratelimit.Store
+	Window time.Duration
+	// AnonymousLimit is the quota per client IP for requests without a
+	// valid access token.
+	AnonymousLimit int
+	// UserLimit is the quota per user for authenticated requests.
+	UserLimit int
+	// Tokens identifies the user behind a bearer token. When nil every
+	// request is keyed by IP.
+	Tokens *token.TokenManager
+	// IETFHeaders sends RateLimit-* with Reset in seconds from now instead
+	// of X-RateLimit-* with Reset as a Unix time.
+	IETFHeaders bool
+	// Now replaces time.Now, for tests. It should match the store's clock.
+	Now func() time.Time
+}
+
+// RateLimit counts every request against a fixed-window quota and reports
+// the quota on every response, so clients can slow down before they are
+// rejected with 429.
+//
+// Requests are keyed by user when they carry a valid access token, and by
+// client IP otherwise. The layer runs ahead of the per-group AuthMiddleware,
+// so it checks the token itself, the way OptionalAuth does; a request is
+// charged to the same user AuthMiddleware later authenticates.
+func RateLimit(cfg RateLimitConfig) fiber.Handler {
+	now := cfg.Now
+	if now == nil {
+		now = time.Now
+	}
+	return func(c fiber.Ctx) error {
+		key, limit := "ip:"+requestmeta.FromCtx(c).IP, cfg.AnonymousLimit
+		if userID, ok := rateLimitUser(c, cfg.Tokens); ok {
+			key, limit = "user:"+userID.String(), cfg.UserLimit
+		}
+
+		res := cfg.Store.Take(key, limit, cfg.Window)
+		setRateLimitHeaders(c, res, now(), cfg.IETFHeaders)
+
+		if !res.Allowed {
+			logger.Warn("rate limit exceeded", map[string]any{
+				"path":   c.Path(),
+				"method": c.Method(),
+				"key":    key,
+			})
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(resetSeconds(res, now())))
+			return TooManyRequestsResponse(c, "rate limit exceeded, slow down")
+		}
+		return c.Next()
+	}
+}
+
+// rateLimitUser returns the user a request is authenticated as, if any.
+func rateLimitUser(c fiber.Ctx, tm *token.TokenManager) (uuid.UUID, bool) {
+	if userID, err := GetUserIDFromContext(c); err == nil {
+		return userID, true
+	}
+	if tm == nil {
+		return uuid.UUID{}, false
+	}
+	tokenString, err := extractBearerToken(c.Get("Authorization", ""))
+	if err != nil {
+		return uuid.UUID{}, false
+	}
+	claims, err := tm.ValidateAccessToken(tokenString)
+	if err != nil || checkSession(tm, claims) != nil {
+		return uuid.UUID{}, false
+	}
+	return claims.UserID, true
+}
+
+func setRateLimitHeaders(c fiber.Ctx, res ratelimit.Result, now time.Time, ietf bool) {
+	if ietf {
+		c.Set(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+		c.Set(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+		c.Set(HeaderRateLimitReset, strconv.Itoa(resetSeconds(res, now)))
+		return
+	}
+	c.Set(HeaderXRateLimitLimit, strconv.Itoa(res.Limit))
+	c.Set(HeaderXRateLimitRemaining, strconv.Itoa(res.Remaining))
+	c.Set(HeaderXRateLimitReset, strconv.FormatInt(res.Reset.Unix(), 10))
+}
+
+// resetSeconds is the whole seconds until the window resets, rounded up so
+// a client that waits that long always finds the quota refilled.
+func resetSeconds(res ratelimit.Result, now time.Time) int {
+	return int(math.Ceil(res.Reset.Sub(now).Seconds()))
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/ratelimit"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRateLimitApp(t *testing.T, ietf bool) (*fiber.App, *time.Time, string) {
+	t.Helper()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	tm := createTestTokenManager()
+
+	app := fiber.New()
+	app.Use(RateLimit(RateLimitConfig{
+		Store:          ratelimit.NewMemoryStore(cache.NewMemory().WithClock(clock)).WithClock(clock),
+		Window:         time.Minute,
+		AnonymousLimit: 2,
+		UserLimit:      5,
+		Tokens:         tm,
+		IETFHeaders:    ietf,
+		Now:            clock,
+	}))
+	app.Get("/public", func(c fiber.Ctx) error { return c.SendString("ok") })
+	app.Get("/private", AuthMiddleware(tm), func(c fiber.Ctx) error { return c.SendString("ok") })
+
+	access, err := tm.GenerateAccessToken(uuid.New())
+	require.NoError(t, err)
+	return app, &now, access
+}
+
+func rateLimited(t *testing.T, app *fiber.App, path, accessToken string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestRateLimit_HeadersAcrossWindow(t *testing.T) {
+	app, now, _ := newRateLimitApp(t, false)
+	reset := strconv.FormatInt(now.Add(time.Minute).Unix(), 10)
+
+	for _, remaining := range []string{"1", "0"} {
+		resp := rateLimited(t, app, "/public", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get(HeaderXRateLimitLimit))
+		assert.Equal(t, remaining, resp.Header.Get(HeaderXRateLimitRemaining))
+		assert.Equal(t, reset, resp.Header.Get(HeaderXRateLimitReset))
+	}
+
+	*now = now.Add(45 * time.Second)
+	resp := rateLimited(t, app, "/public", "")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "0", resp.Header.Get(HeaderXRateLimitRemaining))
+	assert.Equal(t, reset, resp.Header.Get(HeaderXRateLimitReset))
+	assert.Equal(t, "15", resp.Header.Get(fiber.HeaderRetryAfter))
+
+	// The quota refills when the window ends
+	*now = now.Add(15 * time.Second)
+	resp = rateLimited(t, app, "/public", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get(HeaderXRateLimitRemaining))
+	assert.Equal(t, strconv.FormatInt(now.Add(time.Minute).Unix(), 10), resp.Header.Get(HeaderXRateLimitReset))
+}
+
+func TestRateLimit_IETFHeaders(t *testing.T) {
+	app, now, _ := newRateLimitApp(t, true)
+
+	rateLimited(t, app, "/public", "")
+	*now = now.Add(20500 * time.Millisecond)
+	resp := rateLimited(t, app, "/public", "")
+
+	assert.Equal(t, "2", resp.Header.Get(HeaderRateLimitLimit))
+	assert.Equal(t, "0", resp.Header.Get(HeaderRateLimitRemaining))
+	assert.Equal(t, "40", resp.Header.Get(HeaderRateLimitReset), "seconds left, rounded up")
+	assert.Empty(t, resp.Header.Get(HeaderXRateLimitLimit))
+}
+
+func TestRateLimit_AuthenticatedRequestsUseUserQuota(t *testing.T) {
+	app, _, access := newRateLimitApp(t, false)
+
+	// The anonymous quota of this IP is spent...
+	rateLimited(t, app, "/public", "")
+	rateLimited(t, app, "/public", "")
+	assert.Equal(t, http.StatusTooManyRequests, rateLimited(t, app, "/public", "").StatusCode)
+
+	// ...but once AuthMiddleware accepts the token the user's quota applies
+	resp := rateLimited(t, app, "/private", access)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "5", resp.Header.Get(HeaderXRateLimitLimit))
+	assert.Equal(t, "4", resp.Header.Get(HeaderXRateLimitRemaining))
+
+	// An invalid token doesn't escape the anonymous quota
+	resp = rateLimited(t, app, "/public", "not-a-token")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get(HeaderXRateLimitLimit))
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// ContextKeyRequestContext holds the context installed by RequestContext
+const ContextKeyRequestContext = "request_context"
+
+// ErrClientDisconnected is the cancellation cause when the client closes
+// the connection before the response is written
+var ErrClientDisconnected = errors.New("client disconnected")
+
+// disconnectPollInterval is how often an in-flight request's connection is
+// checked for a client-side close
+const disconnectPollInterval = 100 * time.Millisecond
+
+// RequestContext derives a context for the request that is cancelled when
+// the client disconnects or timeout elapses, whichever comes first, and
+// always once the handler chain returns. Handlers pass GetRequestContext(c)
+// to services and repositories instead of c.Context().
+func RequestContext(timeout time.Duration) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		ctx, cancel := context.WithCancelCause(c.Context())
+		defer cancel(context.Canceled)
+
+		if timeout > 0 {
+			var cancelTimeout context.CancelFunc
+			ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+			defer cancelTimeout()
+		}
+
+		stop := watchDisconnect(c.RequestCtx().Conn(), func() {
+			cancel(ErrClientDisconnected)
+		})
+		defer stop()
+
+		SetRequestContext(c, ctx)
+		return c.Next()
+	}
+}
+
+// SetRequestContext replaces the request context, e.g. to attach values
+// derived from the one installed by RequestContext
+func SetRequestContext(c fiber.Ctx, ctx context.Context) {
+	c.Locals(ContextKeyRequestContext, ctx)
+	c.SetContext(ctx)
+}
+
+// GetRequestContext returns the context installed by RequestContext, or
+// the fiber context when the middleware is not in the chain
+func GetRequestContext(c fiber.Ctx) context.Context {
+	if ctx, ok := c.Locals(ContextKeyRequestContext).(context.Context); ok {
+		return ctx
+	}
+	return c.Context()
+}
+
+// watchDisconnect calls onClose if the peer closes conn while the request
+// is in flight. The returned stop func ends the watch and waits for it.
+func watchDisconnect(conn net.Conn, onClose func()) (stop func()) {
+	probe := newDisconnectProbe(conn)
+	if probe == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(disconnectPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				switch probe() {
+				case connClosed:
+					onClose()
+					return
+				case connHasData:
+					// Pipelined bytes are waiting; the peer is still there
+					// and further peeks would only see the same bytes.
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+type connState int
+
+const (
+	connOpen connState = iota
+	connClosed
+	connHasData
+)
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingService stands in for a service call that only returns once its
+// context is done
+func blockingService(ctx context.Context) error {
+	<-ctx.Done()
+	return context.Cause(ctx)
+}
+
+func TestRequestContext_CancelledOnClientDisconnect(t *testing.T) {
+	causes := make(chan error, 1)
+
+	app := fiber.New()
+	app.Get("/slow", RequestContext(10*time.Second), func(c fiber.Ctx) error {
+		causes <- blockingService(GetRequestContext(c))
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true}) }()
+	defer func() { _ = app.Shutdown() }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("GET /slow HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+
+	// Give the handler time to start, then hang up without reading
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, conn.Close())
+
+	select {
+	case cause := <-causes:
+		assert.ErrorIs(t, cause, ErrClientDisconnected)
+	case <-time.After(2 * time.Second):
+		t.Fatal("service context was not cancelled after the client disconnected")
+	}
+}
+
+func TestRequestContext_Timeout(t *testing.T) {
+	causes := make(chan error, 1)
+
+	app := fiber.New()
+	app.Get("/slow", RequestContext(20*time.Millisecond), func(c fiber.Ctx) error {
+		causes <- blockingService(GetRequestContext(c))
+		return c.SendStatus(fiber.StatusGatewayTimeout)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/slow", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusGatewayTimeout, resp.StatusCode)
+	assert.ErrorIs(t, <-causes, context.DeadlineExceeded)
+}
+
+func TestRequestContext_CancelledAfterHandlerReturns(t *testing.T) {
+	var captured context.Context
+
+	app := fiber.New()
+	app.Get("/", RequestContext(time.Minute), func(c fiber.Ctx) error {
+		captured = GetRequestContext(c)
+		assert.NoError(t, captured.Err())
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	_, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.NotNil(t, captured)
+	assert.Error(t, captured.Err(), "request context must not outlive the request")
+}
+
+func TestGetRequestContext_FallsBackWithoutMiddleware(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) error {
+		assert.NotNil(t, GetRequestContext(c))
+		return nil
+	})
+
+	_, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"dvith.com/go-service-api/pkg/httpclient"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// ContextKeyRequestID holds the ID assigned by RequestID
+const ContextKeyRequestID = "request_id"
+
+// maxRequestIDLength caps an ID accepted from the client
+const maxRequestIDLength = 128
+
+// RequestID assigns every request an ID, reusing the X-Request-ID header
+// from the gateway when it is well-formed. The ID is echoed in the response
+// and put on the request context, where outbound httpclient calls pick it
+// up, so it must run before RequestContext derives the context handlers
+// use.
+func RequestID() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		id := c.Get(httpclient.HeaderRequestID)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Locals(ContextKeyRequestID, id)
+		c.Set(httpclient.HeaderRequestID, id)
+		c.SetContext(httpclient.WithRequestID(c.Context(), id))
+		return c.Next()
+	}
+}
+
+// GetRequestID returns the ID assigned by RequestID, or ""
+func GetRequestID(c fiber.Ctx) string {
+	id, _ := c.Locals(ContextKeyRequestID).(string)
+	return id
+}
+
+// validRequestID accepts short IDs of visible ASCII, so a client can't
+// smuggle anything into logs or downstream headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/httpclient"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	var fromCtx, fromLocals string
+	app := fiber.New()
+	app.Use(RequestID(), RequestContext(time.Second))
+	app.Get("/", func(c fiber.Ctx) error {
+		fromCtx = httpclient.RequestID(GetRequestContext(c))
+		fromLocals = GetRequestID(c)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		reuse  bool
+	}{
+		{"gateway ID is reused", "gw-abc-123", true},
+		{"missing ID is generated", "", false},
+		{"oversized ID is replaced", strings.Repeat("a", 129), false},
+		{"ID with spaces is replaced", "a b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set(httpclient.HeaderRequestID, tt.header)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			id := resp.Header.Get(httpclient.HeaderRequestID)
+			if tt.reuse {
+				assert.Equal(t, tt.header, id)
+			} else {
+				_, err := uuid.Parse(id)
+				assert.NoError(t, err, "a fresh UUID is assigned")
+			}
+			assert.Equal(t, id, fromCtx, "the ID reaches the request context")
+			assert.Equal(t, id, fromLocals)
+		})
+	}
+}
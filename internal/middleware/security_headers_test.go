@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeaders_NoncePerRequest(t *testing.T) {
+	app := fiber.New()
+	app.Use(SecurityHeaders(SecurityHeadersConfig{}))
+	app.Get("/", func(c fiber.Ctx) error { return c.SendString(CSPNonce(c)) })
+
+	var nonces []string
+	for range 2 {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		nonce := string(body)
+		require.NotEmpty(t, nonce)
+		assert.Contains(t, resp.Header.Get(fiber.HeaderContentSecurityPolicy), "script-src 'nonce-"+nonce+"'")
+		assert.Equal(t, "nosniff", resp.Header.Get(fiber.HeaderXContentTypeOptions))
+		assert.Equal(t, "DENY", resp.Header.Get(fiber.HeaderXFrameOptions))
+		assert.Equal(t, "no-referrer", resp.Header.Get(fiber.HeaderReferrerPolicy))
+		nonces = append(nonces, nonce)
+	}
+	assert.NotEqual(t, nonces[0], nonces[1])
+}
+
+func TestSecurityHeaders_CustomPolicyWithoutNonce(t *testing.T) {
+	app := fiber.New()
+	app.Use(SecurityHeaders(SecurityHeadersConfig{ContentSecurityPolicy: "default-src 'none'"}))
+	app.Get("/", func(c fiber.Ctx) error { return c.SendString(CSPNonce(c)) })
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Empty(t, strings.TrimSpace(string(body)))
+	assert.Equal(t, "default-src 'none'", resp.Header.Get(fiber.HeaderContentSecurityPolicy))
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// Tenant context constants
+const (
+	ContextKeyTenantID = "tenant_id"
+	HeaderTenantID     = "X-Tenant-ID"
+)
+
+// TenantResolver resolves the tenant for the request from the X-Tenant-ID
+// header, then the Host header, then the default tenant. The resolved ID is
+// stored in Locals and in the request context so repositories can scope
+// their queries.
+func TenantResolver(store tenant.Store) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		ctx := GetRequestContext(c)
+
+		var t *tenant.Tenant
+		var err error
+
+		if header := c.Get(HeaderTenantID); header != "" {
+			id, parseErr := uuid.Parse(header)
+			if parseErr != nil {
+				return ValidationErrorResponse(c, "invalid "+HeaderTenantID+" header")
+			}
+			t, err = store.FindByID(ctx, id)
+		} else {
+			t, err = store.FindByHost(ctx, c.Hostname())
+			if errors.Is(err, tenant.ErrTenantNotFound) {
+				t, err = store.FindDefault(ctx)
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, tenant.ErrTenantNotFound) {
+				logger.Warn("unknown tenant", map[string]any{
+					"path": c.Path(),
+					"host": c.Hostname(),
+				})
+				return ValidationErrorResponse(c, "unknown tenant")
+			}
+			logger.Error("failed to resolve tenant", map[string]any{
+				"path":  c.Path(),
+				"error": err.Error(),
+			})
+			return InternalErrorResponse(c, "failed to resolve tenant", err)
+		}
+
+		c.Locals(ContextKeyTenantID, t.ID)
+		SetRequestContext(c, tenant.WithID(ctx, t.ID))
+
+		return c.Next()
+	}
+}
+
+// GetTenantIDFromContext retrieves the tenant ID stored by TenantResolver
+func GetTenantIDFromContext(c fiber.Ctx) (uuid.UUID, error) {
+	val := c.Locals(ContextKeyTenantID)
+	if val == nil {
+		return uuid.UUID{}, fmt.Errorf("tenant_id not found in context")
+	}
+
+	tenantID, ok := val.(uuid.UUID)
+	if !ok {
+		return uuid.UUID{}, fmt.Errorf("invalid tenant_id type in context")
+	}
+
+	return tenantID, nil
+}
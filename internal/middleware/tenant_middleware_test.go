@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dvith.com/go-service-api/internal/security/token"
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTenantStore is an in-memory tenant.Store
+type fakeTenantStore struct {
+	byID   map[uuid.UUID]*tenant.Tenant
+	byHost map[string]*tenant.Tenant
+	def    *tenant.Tenant
+}
+
+func newFakeTenantStore(tenants ...*tenant.Tenant) *fakeTenantStore {
+	s := &fakeTenantStore{
+		byID:   make(map[uuid.UUID]*tenant.Tenant),
+		byHost: make(map[string]*tenant.Tenant),
+	}
+	for _, t := range tenants {
+		s.byID[t.ID] = t
+		if t.Host != nil {
+			s.byHost[*t.Host] = t
+		}
+		if t.IsDefault {
+			s.def = t
+		}
+	}
+	return s
+}
+
+func (s *fakeTenantStore) FindByID(ctx context.Context, id uuid.UUID) (*tenant.Tenant, error) {
+	if t, ok := s.byID[id]; ok {
+		return t, nil
+	}
+	return nil, tenant.ErrTenantNotFound
+}
+
+func (s *fakeTenantStore) FindByHost(ctx context.Context, host string) (*tenant.Tenant, error) {
+	if t, ok := s.byHost[host]; ok {
+		return t, nil
+	}
+	return nil, tenant.ErrTenantNotFound
+}
+
+func (s *fakeTenantStore) FindDefault(ctx context.Context) (*tenant.Tenant, error) {
+	if s.def == nil {
+		return nil, tenant.ErrTenantNotFound
+	}
+	return s.def, nil
+}
+
+func newTenantTestApp(store tenant.Store, tm *token.TokenManager) *fiber.App {
+	app := fiber.New()
+	app.Use(TenantResolver(store), AuthMiddleware(tm))
+	app.Get("/protected", func(c fiber.Ctx) error {
+		id, ok := tenant.IDFromContext(c.Context())
+		if !ok {
+			return fmt.Errorf("tenant missing from request context")
+		}
+		return c.JSON(fiber.Map{"tenant_id": id})
+	})
+	return app
+}
+
+// TestTenantResolver_RejectsCrossTenantToken proves a user from tenant A
+// cannot authenticate against tenant B.
+func TestTenantResolver_RejectsCrossTenantToken(t *testing.T) {
+	hostB := "brand-b.example.com"
+	tenantA := &tenant.Tenant{ID: uuid.New(), Slug: "a", IsDefault: true}
+	tenantB := &tenant.Tenant{ID: uuid.New(), Slug: "b", Host: &hostB}
+
+	tm := createTestTokenManager()
+	app := newTenantTestApp(newFakeTenantStore(tenantA, tenantB), tm)
+
+	accessToken, err := tm.GenerateAccessToken(uuid.New(), token.WithTenant(tenantA.ID))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		header string
+		host   string
+		want   int
+	}{
+		{name: "same tenant via header", header: tenantA.ID.String(), want: http.StatusOK},
+		{name: "same tenant via default", want: http.StatusOK},
+		{name: "other tenant via header", header: tenantB.ID.String(), want: http.StatusUnauthorized},
+		{name: "other tenant via host", host: hostB, want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			if tt.header != "" {
+				req.Header.Set(HeaderTenantID, tt.header)
+			}
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+}
+
+// TestOptionalAuth_CrossTenantTokenIsAnonymous proves a token from tenant A
+// doesn't authenticate optional routes of tenant B either
+func TestOptionalAuth_CrossTenantTokenIsAnonymous(t *testing.T) {
+	hostB := "brand-b.example.com"
+	tenantA := &tenant.Tenant{ID: uuid.New(), Slug: "a", IsDefault: true}
+	tenantB := &tenant.Tenant{ID: uuid.New(), Slug: "b", Host: &hostB}
+
+	tm := createTestTokenManager()
+	app := fiber.New()
+	app.Use(TenantResolver(newFakeTenantStore(tenantA, tenantB)), OptionalAuth(tm))
+	app.Get("/public", func(c fiber.Ctx) error {
+		userID, err := GetUserIDFromContext(c)
+		if err != nil {
+			return c.JSON(fiber.Map{"user_id": nil})
+		}
+		return c.JSON(fiber.Map{"user_id": userID})
+	})
+
+	userID := uuid.New()
+	accessToken, err := tm.GenerateAccessToken(userID, token.WithTenant(tenantA.ID))
+	require.NoError(t, err)
+
+	for host, want := range map[string]string{
+		"":    `{"user_id":"` + userID.String() + `"}`,
+		hostB: `{"user_id":null}`,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/public", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		if host != "" {
+			req.Host = host
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "host %q", host)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, want, string(body), "host %q", host)
+	}
+}
+
+// TestTenantResolver_UnknownTenant tests that unknown or malformed tenants are rejected
+func TestTenantResolver_UnknownTenant(t *testing.T) {
+	app := fiber.New()
+	app.Use(TenantResolver(newFakeTenantStore()))
+	app.Get("/", func(c fiber.Ctx) error { return c.SendStatus(http.StatusOK) })
+
+	for _, header := range []string{"not-a-uuid", uuid.NewString(), ""} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(HeaderTenantID, header)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "header %q", header)
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"dvith.com/go-service-api/internal/requestmeta"
+	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// HeaderServerTiming reports the handler latency, as app;dur=12.3 in
+// milliseconds
+const HeaderServerTiming = "Server-Timing"
+
+// Timing measures each request, reports its latency in the Server-Timing
+// header and logs a warning for requests slower than threshold, with the
+// route, the user and how many database queries the request ran. A
+// threshold of 0 turns the log off.
+//
+// It installs the query counter on the request context, so it must run
+// before RequestContext derives the context handlers use.
+func Timing(threshold time.Duration) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		start := time.Now()
+		ctx, queries := database.WithQueryCounter(c.Context())
+		c.SetContext(ctx)
+
+		err := c.Next()
+
+		elapsed := time.Since(start)
+		c.Set(HeaderServerTiming, "app;dur="+strconv.FormatFloat(float64(elapsed.Microseconds())/1000, 'f', 1, 64))
+
+		if threshold > 0 && elapsed >= threshold {
+			fields := map[string]any{
+				"method":      c.Method(),
+				"route":       c.Route().Path,
+				"path":        c.Path(),
+				"duration_ms": elapsed.Milliseconds(),
+				"db_queries":  queries.Count(),
+			}
+			if meta := requestmeta.FromCtx(c); meta.UserID != uuid.Nil {
+				fields["user_id"] = meta.UserID.String()
+			}
+			logger.Warn("slow request", withBaggageFields(c, fields))
+		}
+		return err
+	}
+}
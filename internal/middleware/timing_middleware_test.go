@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/requestmeta"
+	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureWarnings sends the standard logger to a buffer as JSON
+func captureWarnings(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	logger.SetJSON(true)
+	t.Cleanup(func() {
+		logger.SetOutput(os.Stdout)
+		logger.SetJSON(false)
+	})
+	return &buf
+}
+
+func TestTiming_ServerTimingHeader(t *testing.T) {
+	app := fiber.New()
+	app.Use(Timing(0))
+	app.Get("/", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+	app.Get("/fail", func(c fiber.Ctx) error { return fiber.ErrTeapot })
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^app;dur=\d+\.\d$`), resp.Header.Get(HeaderServerTiming))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/fail", nil))
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Header.Get(HeaderServerTiming), "failed requests are timed too")
+}
+
+func TestTiming_LogsSlowRequests(t *testing.T) {
+	buf := captureWarnings(t)
+	userID := uuid.New()
+
+	app := fiber.New()
+	app.Use(Timing(20 * time.Millisecond))
+	app.Use(RequestContext(time.Second))
+	app.Get("/users/:id", func(c fiber.Ctx) error {
+		requestmeta.SetUserID(c, userID)
+		// Two queries, as the pgx tracer would count them
+		ctx := GetRequestContext(c)
+		for range 2 {
+			database.QueryTracer{}.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+		}
+		if c.Query("slow") != "" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	_, err := app.Test(httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	require.NoError(t, err)
+	assert.Empty(t, buf.String(), "fast requests aren't logged")
+
+	_, err = app.Test(httptest.NewRequest(http.MethodGet, "/users/42?slow=1", nil))
+	require.NoError(t, err)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry), buf.String())
+	assert.Equal(t, "slow request", entry["msg"])
+	assert.Equal(t, "warning", entry["level"])
+	assert.Equal(t, "/users/:id", entry["route"])
+	assert.Equal(t, userID.String(), entry["user_id"])
+	assert.EqualValues(t, 2, entry["db_queries"])
+	assert.GreaterOrEqual(t, entry["duration_ms"], float64(30))
+}
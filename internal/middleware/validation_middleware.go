@@ -0,0 +1,321 @@
+package middleware
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dvith.com/go-service-api/pkg/emailaddr"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v3"
+)
+
+// ContextKeyBody holds the request body decoded and validated by ValidateBody
+const ContextKeyBody = "validated_body"
+
+// HeaderValidationErrors set to ValidationErrorsLegacy asks for the
+// legacy "errors" array next to "fields" in validation failures. It is
+// kept for one release while clients move to "fields".
+const (
+	HeaderValidationErrors = "X-Validation-Errors"
+	ValidationErrorsLegacy = "legacy"
+)
+
+var validate = newValidator()
+
+// newValidator reports fields under their JSON names, so error namespaces
+// turn into JSON pointers into the request body. The rfc_email tag checks
+// an address with emailaddr.Validate, which is stricter than email.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	_ = v.RegisterValidation("rfc_email", func(fl validator.FieldLevel) bool {
+		return emailaddr.Validate(fl.Field().String()) == nil
+	})
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name, _, _ := strings.Cut(fld.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return fld.Name
+		}
+		return name
+	})
+	return v
+}
+
+// FieldError describes a single invalid request field. Field and Message
+// are meant for people; clients should match on JSONPath and Code.
+type FieldError struct {
+	// Field is the Go name of the field, as in the legacy "errors" array
+	Field string `json:"field" xml:"field"`
+	// JSONPath is an RFC 6901 pointer to the value in the body, such as
+	// "/password" or "/addresses/0/city"
+	JSONPath string `json:"json_path,omitempty" xml:"json_path,omitempty"`
+	// Code is a stable identifier of the failed rule, such as "too_short"
+	Code string `json:"code,omitempty" xml:"code,omitempty"`
+	// Params holds the rule's constraint, such as {"min": "8"}
+	Params  Params `json:"params,omitempty" xml:"params,omitempty"`
+	Message string `json:"message" xml:"message"`
+}
+
+// Params are the constraints of a failed validation rule
+type Params map[string]string
+
+// MarshalXML writes params as <param name="min">8</param> elements, in
+// name order
+func (p Params) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(p) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, name := range names {
+		param := xml.StartElement{Name: xml.Name{Local: "param"}, Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: name}}}
+		if err := e.EncodeElement(p[name], param); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// ValidationFailedResponse is a 400 response listing every invalid field.
+// Errors is only filled for clients that opt in with
+// HeaderValidationErrors.
+type ValidationFailedResponse struct {
+	ErrorResponse
+	Fields []FieldError   `json:"fields" xml:"fields>field_error"`
+	Errors FieldErrorList `json:"errors,omitempty" xml:"errors,omitempty"`
+}
+
+// FieldErrorList is a list of field errors that XML encodes as
+// <field_error> elements. It exists because encoding/xml can't omit an
+// empty list under a wrapper element.
+type FieldErrorList []FieldError
+
+// MarshalXML wraps the errors in start
+func (l FieldErrorList) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Items []FieldError `xml:"field_error"`
+	}{l}, start)
+}
+
+// ValidateBody decodes the JSON body into T and validates it against its
+// `validate` struct tags. Handlers read the result with GetValidatedBody.
+func ValidateBody[T any]() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		body := new(T)
+		if err := DecodeJSON(c.Body(), body); err != nil {
+			return ValidationErrorResponse(c, "invalid request body")
+		}
+
+		if errs := ValidateStruct(body); len(errs) > 0 {
+			return FieldErrorsResponse(c, errs)
+		}
+
+		c.Locals(ContextKeyBody, body)
+		return c.Next()
+	}
+}
+
+// ValidateForm is ValidateBody for routes HTML forms post to, such as the
+// account pages: an application/x-www-form-urlencoded or multipart body
+// is bound by the `form` tags of T, anything else is decoded as JSON. It
+// is opt-in since only routes whose body carries its own credential, like
+// a reset token, are safe to expose to cross-site form posts.
+func ValidateForm[T any]() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		ctype := c.RequestCtx().Request.Header.ContentType()
+		if !isMIME(ctype, fiber.MIMEApplicationForm) && !isMIME(ctype, fiber.MIMEMultipartForm) {
+			return ValidateBody[T]()(c)
+		}
+
+		body := new(T)
+		if err := BindBody(c, body); err != nil {
+			return ValidationErrorResponse(c, "invalid request body")
+		}
+		if errs := ValidateStruct(body); len(errs) > 0 {
+			return FieldErrorsResponse(c, errs)
+		}
+
+		c.Locals(ContextKeyBody, body)
+		return c.Next()
+	}
+}
+
+// GetValidatedBody returns the body stored by ValidateBody[T]
+func GetValidatedBody[T any](c fiber.Ctx) (*T, error) {
+	body, ok := c.Locals(ContextKeyBody).(*T)
+	if !ok {
+		return nil, fmt.Errorf("validated body not found in context")
+	}
+	return body, nil
+}
+
+// ValidateStruct checks v against its `validate` struct tags with the same
+// validator and messages as ValidateBody, for input that doesn't arrive as
+// a JSON body. It returns nil when v is valid.
+//
+// String fields tagged `maxbytes:"n"` are checked first: if any is longer
+// than n bytes, only those are reported and the validator doesn't run, so
+// an oversized value never reaches a regular expression or a hash.
+func ValidateStruct(v any) []FieldError {
+	if errs := oversizedFields(reflect.ValueOf(v), ""); len(errs) > 0 {
+		return errs
+	}
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return []FieldError{{Message: err.Error()}}
+	}
+	return fieldErrors(verrs)
+}
+
+// FieldErrorsResponse returns a 400 Bad Request listing the invalid fields.
+func FieldErrorsResponse(c fiber.Ctx, errs []FieldError) error {
+	return Respond(c, fiber.StatusBadRequest, ValidationFailedResponse{
+		ErrorResponse: ErrorResponse{
+			Error:   "validation_error",
+			Message: "validation failed",
+			Code:    fiber.StatusBadRequest,
+		},
+		Fields: errs,
+		Errors: LegacyFieldErrors(c, errs),
+	})
+}
+
+// LegacyFieldErrors returns errs in the legacy field and message shape
+// when the client asked for it with HeaderValidationErrors, and nil
+// otherwise
+func LegacyFieldErrors(c fiber.Ctx, errs []FieldError) []FieldError {
+	if !strings.EqualFold(c.Get(HeaderValidationErrors), ValidationErrorsLegacy) {
+		return nil
+	}
+	out := make([]FieldError, len(errs))
+	for i, fe := range errs {
+		out[i] = FieldError{Field: fe.Field, Message: fe.Message}
+	}
+	return out
+}
+
+func fieldErrors(verrs validator.ValidationErrors) []FieldError {
+	out := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		field := fe.StructField()
+		var msg, code string
+		switch fe.Tag() {
+		case "required":
+			code, msg = "required", fmt.Sprintf("%s is required", field)
+		case "email", "rfc_email":
+			code, msg = "invalid_email", fmt.Sprintf("%s must be a valid email address", field)
+		case "min":
+			code, msg = "too_short", fmt.Sprintf("%s must be at least %s characters", field, fe.Param())
+		case "max":
+			code, msg = "too_long", fmt.Sprintf("%s must not exceed %s characters", field, fe.Param())
+		case "bcp47_language_tag":
+			code, msg = "invalid_locale", fmt.Sprintf("%s must be a BCP 47 language tag such as en-US", field)
+		case "timezone":
+			code, msg = "invalid_timezone", fmt.Sprintf("%s must be an IANA time zone such as Asia/Bangkok", field)
+		default:
+			code, msg = "invalid_"+fe.Tag(), fmt.Sprintf("%s is invalid", field)
+		}
+		var params Params
+		if fe.Param() != "" {
+			params = Params{fe.Tag(): fe.Param()}
+		}
+		out = append(out, FieldError{
+			Field:    field,
+			JSONPath: jsonPointer(fe.Namespace()),
+			Code:     code,
+			Params:   params,
+			Message:  msg,
+		})
+	}
+	return out
+}
+
+// oversizedFields returns an error for each string field of the struct v,
+// or of the structs it holds, longer than its `maxbytes` tag. path is the
+// JSON pointer of v.
+func oversizedFields(v reflect.Value, path string) []FieldError {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var out []FieldError
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			fld := t.Field(i)
+			if !fld.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(fld.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = fld.Name
+			}
+			fieldPath := path + "/" + pointerEscaper.Replace(name)
+
+			fv := v.Field(i)
+			limit, err := strconv.Atoi(fld.Tag.Get("maxbytes"))
+			if err != nil || fv.Kind() != reflect.String {
+				out = append(out, oversizedFields(fv, fieldPath)...)
+				continue
+			}
+			if fv.Len() > limit {
+				out = append(out, FieldError{
+					Field:    fld.Name,
+					JSONPath: fieldPath,
+					Code:     "too_large",
+					Params:   Params{"max_bytes": strconv.Itoa(limit)},
+					Message:  fmt.Sprintf("%s must not exceed %d bytes", fld.Name, limit),
+				})
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		// Only structs carry tags; don't walk a []byte element by element
+		if k := v.Type().Elem().Kind(); k != reflect.Struct && k != reflect.Pointer && k != reflect.Interface {
+			break
+		}
+		for i := range v.Len() {
+			out = append(out, oversizedFields(v.Index(i), path+"/"+strconv.Itoa(i))...)
+		}
+	}
+	return out
+}
+
+// pointerEscaper escapes a reference token of a JSON pointer
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// jsonPointer turns a validator namespace such as "Request.items[0].name"
+// into the JSON pointer "/items/0/name", dropping the root type's name
+func jsonPointer(namespace string) string {
+	_, path, ok := strings.Cut(namespace, ".")
+	if !ok {
+		return ""
+	}
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	var b strings.Builder
+	for _, token := range strings.Split(path, ".") {
+		b.WriteString("/")
+		b.WriteString(pointerEscaper.Replace(token))
+	}
+	return b.String()
+}
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"io"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testBody struct {
+	Email string `json:"email" validate:"required,email"`
+	Name  string `json:"name" validate:"required,max=5"`
+}
+
+func TestValidateBody(t *testing.T) {
+	app := fiber.New()
+	app.Post("/", ValidateBody[testBody](), func(c fiber.Ctx) error {
+		body, err := GetValidatedBody[testBody](c)
+		require.NoError(t, err)
+		return c.SendString(body.Name)
+	})
+
+	post := func(body string, headers ...string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("valid body reaches handler", func(t *testing.T) {
+		resp := post(`{"email":"a@example.com","name":"bob"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		resp := post(`{`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("every invalid field is reported", func(t *testing.T) {
+		resp := post(`{"email":"nope","name":"too-long-name"}`)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var body ValidationFailedResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "validation_error", body.Error)
+		assert.Equal(t, []FieldError{
+			{Field: "Email", JSONPath: "/email", Code: "invalid_email", Message: "Email must be a valid email address"},
+			{Field: "Name", JSONPath: "/name", Code: "too_long", Params: Params{"max": "5"}, Message: "Name must not exceed 5 characters"},
+		}, body.Fields)
+		assert.Empty(t, body.Errors, "the legacy list is opt-in")
+	})
+
+	t.Run("legacy errors on request", func(t *testing.T) {
+		resp := post(`{"email":"nope","name":"bob"}`, HeaderValidationErrors, ValidationErrorsLegacy)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var body struct {
+			Fields []map[string]any `json:"fields"`
+			Errors []map[string]any `json:"errors"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Len(t, body.Fields, 1)
+		assert.Equal(t, []map[string]any{{"field": "Email", "message": "Email must be a valid email address"}}, body.Errors)
+	})
+}
+
+type address struct {
+	City     string `json:"city" validate:"required"`
+	Postcode string `json:"post/code" validate:"len=5"`
+}
+
+type nestedBody struct {
+	Password  string    `json:"password,omitempty" validate:"min=8"`
+	Nickname  string    `validate:"required"`
+	Home      address   `json:"home"`
+	Addresses []address `json:"addresses" validate:"dive"`
+}
+
+type testForm struct {
+	Token    string `json:"token" form:"token" validate:"required"`
+	Password string `json:"password" form:"password" validate:"required,min=8"`
+}
+
+func TestValidateForm(t *testing.T) {
+	app := fiber.New()
+	app.Post("/", ValidateForm[testForm](), func(c fiber.Ctx) error {
+		body, err := GetValidatedBody[testForm](c)
+		require.NoError(t, err)
+		return c.SendString(body.Token + " " + body.Password)
+	})
+
+	post := func(ctype, body string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", ctype)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		raw, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(raw)
+	}
+
+	status, body := post("application/x-www-form-urlencoded", "token=abc&password=long-enough")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "abc long-enough", body)
+
+	status, body = post("application/json", `{"token":"abc","password":"long-enough"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "abc long-enough", body)
+
+	status, body = post("application/x-www-form-urlencoded", "token=abc&password=short")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "password", "forms are validated like JSON")
+}
+
+func TestValidateStruct_JSONPointers(t *testing.T) {
+	errs := ValidateStruct(&nestedBody{
+		Password:  "short",
+		Home:      address{Postcode: "10110"},
+		Addresses: []address{{City: "Bangkok", Postcode: "10110"}, {City: "Chiang Mai", Postcode: "502"}},
+	})
+
+	paths := map[string]FieldError{}
+	for _, fe := range errs {
+		paths[fe.JSONPath] = fe
+	}
+	require.Len(t, paths, 4)
+
+	assert.Equal(t, FieldError{Field: "Password", JSONPath: "/password", Code: "too_short", Params: Params{"min": "8"}, Message: "Password must be at least 8 characters"}, paths["/password"])
+	assert.Equal(t, "Nickname", paths["/Nickname"].Field, "fields without a json tag keep their Go name")
+	assert.Equal(t, "required", paths["/home/city"].Code)
+	assert.Equal(t, "City", paths["/home/city"].Field)
+	assert.Equal(t, FieldError{Field: "Postcode", JSONPath: "/addresses/1/post~1code", Code: "invalid_len", Params: Params{"len": "5"}, Message: "Postcode is invalid"}, paths["/addresses/1/post~1code"])
+}
+
+func TestValidateStruct_RFCEmail(t *testing.T) {
+	type body struct {
+		Email string `json:"email" validate:"rfc_email"`
+	}
+
+	assert.Empty(t, ValidateStruct(&body{Email: "user@bücher.example"}))
+	for _, email := range []string{"a@b", "John <john@example.com>", strings.Repeat("a", 65) + "@example.com"} {
+		errs := ValidateStruct(&body{Email: email})
+		require.Len(t, errs, 1, email)
+		assert.Equal(t, FieldError{Field: "Email", JSONPath: "/email", Code: "invalid_email", Message: "Email must be a valid email address"}, errs[0])
+	}
+}
+
+func TestJSONPointer(t *testing.T) {
+	assert.Equal(t, "/email", jsonPointer("Request.email"))
+	assert.Equal(t, "/items/0/name", jsonPointer("Request.items[0].name"))
+	assert.Equal(t, "/labels/a~0b", jsonPointer("Request.labels[a~b]"))
+	assert.Equal(t, "", jsonPointer("Request"))
+}
+
+func TestValidateStruct_MaxBytes(t *testing.T) {
+	type item struct {
+		Label string `json:"label" maxbytes:"4"`
+	}
+	type body struct {
+		Name  string  `json:"name" validate:"required,email" maxbytes:"8"`
+		Items []item  `json:"items"`
+		Next  *item   `json:"next"`
+		Raw   []byte  `json:"raw"`
+		Skip  *item   `json:"-"`
+		Tags  []*item `json:"tags"`
+	}
+
+	assert.Empty(t, ValidateStruct(&body{Name: "a@b.io", Items: []item{{Label: "four"}}}))
+
+	errs := ValidateStruct(&body{
+		Name:  "not-an-email",
+		Items: []item{{Label: "ok"}, {Label: "toolong"}},
+		Next:  &item{Label: "toolong"},
+		Skip:  &item{Label: "toolong"},
+		Tags:  []*item{nil, {Label: "toolong"}},
+	})
+	assert.Equal(t, []FieldError{
+		{Field: "Name", JSONPath: "/name", Code: "too_large", Params: Params{"max_bytes": "8"}, Message: "Name must not exceed 8 bytes"},
+		{Field: "Label", JSONPath: "/items/1/label", Code: "too_large", Params: Params{"max_bytes": "4"}, Message: "Label must not exceed 4 bytes"},
+		{Field: "Label", JSONPath: "/next/label", Code: "too_large", Params: Params{"max_bytes": "4"}, Message: "Label must not exceed 4 bytes"},
+		{Field: "Label", JSONPath: "/tags/1/label", Code: "too_large", Params: Params{"max_bytes": "4"}, Message: "Label must not exceed 4 bytes"},
+	}, errs, "the validator doesn't run while a field is oversized")
+}
@@ -0,0 +1,58 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/internal/queries"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+)
+
+// Event types written to the outbox.
+const (
+	EventUserRegistered = "user.registered"
+	EventUserSignedIn   = "user.signed_in"
+	EventUserDeleted    = "user.deleted"
+)
+
+// Event statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// Event represents a row in the events_outbox table
+type Event struct {
+	ID            uuid.UUID       `db:"id" json:"id"`
+	EventType     string          `db:"event_type" json:"event_type"`
+	AggregateID   uuid.UUID       `db:"aggregate_id" json:"aggregate_id"`
+	Payload       json.RawMessage `db:"payload" json:"payload"`
+	Status        string          `db:"status" json:"status"`
+	Attempts      int             `db:"attempts" json:"attempts"`
+	LastError     *string         `db:"last_error" json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `db:"next_attempt_at" json:"next_attempt_at"`
+	CreatedAt     time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt   *time.Time      `db:"delivered_at" json:"delivered_at,omitempty"`
+}
+
+// Enqueue writes an event to the outbox using q. Callers pass the
+// transaction that performs the related mutation so the event is only
+// persisted when that mutation commits.
+func Enqueue(ctx context.Context, q database.Querier, eventType string, aggregateID uuid.UUID, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+
+	now := clock.Now()
+	if _, err := q.Exec(ctx, queries.OutboxEnqueue.SQL, uuid.New(), eventType, aggregateID, data, StatusPending, now); err != nil {
+		return fmt.Errorf("failed to enqueue outbox event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/testdb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) { testdb.Main(m) }
+
+// TestEnqueue_RolledBackTransaction simulates a crash between the mutation
+// and commit: the event must not survive the rollback.
+func TestEnqueue_RolledBackTransaction(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	aggregateID := uuid.New()
+
+	errCrash := errors.New("simulated crash before commit")
+	err := db.WithTx(ctx, func(tx pgx.Tx) error {
+		if err := Enqueue(ctx, tx, EventUserRegistered, aggregateID, map[string]string{"k": "v"}); err != nil {
+			return err
+		}
+		return errCrash
+	})
+	require.ErrorIs(t, err, errCrash)
+
+	var count int
+	require.NoError(t, db.QueryRow(ctx, `SELECT COUNT(*) FROM events_outbox WHERE aggregate_id = $1`, aggregateID).Scan(&count))
+	assert.Equal(t, 0, count, "rolled back event must not be persisted")
+
+	require.NoError(t, db.WithTx(ctx, func(tx pgx.Tx) error {
+		return Enqueue(ctx, tx, EventUserRegistered, aggregateID, map[string]string{"k": "v"})
+	}))
+	require.NoError(t, db.QueryRow(ctx, `SELECT COUNT(*) FROM events_outbox WHERE aggregate_id = $1`, aggregateID).Scan(&count))
+	assert.Equal(t, 1, count, "committed event must be persisted")
+}
+
+// TestPgStore_ClaimSkipsLeasedRows verifies two claims never return the same row.
+func TestPgStore_ClaimSkipsLeasedRows(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	store := NewPgStore(db)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, Enqueue(ctx, db, EventUserRegistered, uuid.New(), nil))
+	}
+
+	first, err := store.Claim(ctx, 1000, time.Minute)
+	require.NoError(t, err)
+	second, err := store.Claim(ctx, 1000, time.Minute)
+	require.NoError(t, err)
+
+	seen := make(map[uuid.UUID]bool)
+	for _, e := range first {
+		seen[e.ID] = true
+	}
+	for _, e := range second {
+		assert.False(t, seen[e.ID], "event %s claimed twice", e.ID)
+	}
+}
@@ -0,0 +1,211 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/metrics"
+)
+
+var (
+	deliveredTotal = metrics.NewCounter("outbox_events_delivered_total", "Outbox events delivered.", "event_type")
+	failedTotal    = metrics.NewCounter("outbox_events_failed_total", "Outbox delivery attempts that failed.", "event_type")
+	deadTotal      = metrics.NewCounter("outbox_events_dead_total", "Outbox events parked after exhausting retries.", "event_type")
+	pendingGauge   = metrics.NewGauge("outbox_pending_events", "Outbox events awaiting delivery.")
+	lagGauge       = metrics.NewGauge("outbox_lag_seconds", "Age of the oldest undelivered outbox event.")
+)
+
+// PollerConfig holds the poller tuning knobs
+type PollerConfig struct {
+	Interval    time.Duration // Delay between polls when the outbox is idle
+	BatchSize   int           // Maximum events claimed per poll
+	Lease       time.Duration // How long a claimed event is hidden from other pollers
+	MaxAttempts int           // Attempts before an event is marked dead
+	BaseBackoff time.Duration // Backoff after the first failure, doubled per attempt
+	MaxBackoff  time.Duration // Upper bound for the retry backoff
+}
+
+// DefaultPollerConfig returns sensible defaults
+func DefaultPollerConfig() PollerConfig {
+	return PollerConfig{
+		Interval:    time.Second,
+		BatchSize:   50,
+		Lease:       30 * time.Second,
+		MaxAttempts: 10,
+		BaseBackoff: time.Second,
+		MaxBackoff:  10 * time.Minute,
+	}
+}
+
+// Poller claims batches of outbox events and dispatches them to publishers.
+type Poller struct {
+	store      Store
+	publishers []Publisher
+	config     PollerConfig
+	now        func() time.Time
+}
+
+// NewPoller creates a new outbox poller
+func NewPoller(store Store, config PollerConfig, publishers ...Publisher) *Poller {
+	def := DefaultPollerConfig()
+	if config.Interval <= 0 {
+		config.Interval = def.Interval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = def.BatchSize
+	}
+	if config.Lease <= 0 {
+		config.Lease = def.Lease
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = def.MaxAttempts
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = def.BaseBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = def.MaxBackoff
+	}
+
+	return &Poller{
+		store:      store,
+		publishers: publishers,
+		config:     config,
+		now:        clock.Now,
+	}
+}
+
+// Run polls until ctx is cancelled. A full batch is followed immediately by
+// another poll so a backlog drains without waiting for the interval.
+func (p *Poller) Run(ctx context.Context) {
+	logger.Info("outbox poller started", map[string]any{
+		"interval":   p.config.Interval.String(),
+		"batch_size": p.config.BatchSize,
+	})
+
+	for {
+		n, err := p.ProcessBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("outbox poll failed", map[string]any{"error": err.Error()})
+		}
+
+		p.recordLag(ctx)
+
+		wait := p.config.Interval
+		if n == p.config.BatchSize {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("outbox poller stopped", nil)
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ProcessBatch claims and dispatches a single batch, returning the number
+// of events claimed.
+func (p *Poller) ProcessBatch(ctx context.Context) (int, error) {
+	events, err := p.store.Claim(ctx, p.config.BatchSize, p.config.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		p.dispatch(ctx, event)
+	}
+
+	return len(events), nil
+}
+
+func (p *Poller) dispatch(ctx context.Context, event Event) {
+	err := p.publish(ctx, event)
+	if err == nil {
+		if markErr := p.store.MarkDelivered(ctx, event.ID); markErr != nil {
+			logger.Error("failed to mark outbox event delivered", map[string]any{
+				"event_id": event.ID.String(),
+				"error":    markErr.Error(),
+			})
+			return
+		}
+		deliveredTotal.Inc(event.EventType)
+		return
+	}
+
+	attempts := event.Attempts + 1
+	dead := attempts >= p.config.MaxAttempts
+	next := p.now().Add(p.backoff(attempts))
+
+	failedTotal.Inc(event.EventType)
+	if dead {
+		deadTotal.Inc(event.EventType)
+		logger.Error("outbox event moved to dead state", map[string]any{
+			"event_id":   event.ID.String(),
+			"event_type": event.EventType,
+			"attempts":   attempts,
+			"error":      err.Error(),
+		})
+	} else {
+		logger.Warn("outbox event delivery failed", map[string]any{
+			"event_id":   event.ID.String(),
+			"event_type": event.EventType,
+			"attempts":   attempts,
+			"next_at":    next.UTC().Format(time.RFC3339),
+			"error":      err.Error(),
+		})
+	}
+
+	if markErr := p.store.MarkFailed(ctx, event.ID, attempts, next, err.Error(), dead); markErr != nil {
+		logger.Error("failed to record outbox failure", map[string]any{
+			"event_id": event.ID.String(),
+			"error":    markErr.Error(),
+		})
+	}
+}
+
+// publish sends the event to every publisher, recovering from panics so a
+// poison message can't take down the poller.
+func (p *Poller) publish(ctx context.Context, event Event) (err error) {
+	for _, pub := range p.publishers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("publisher %s panicked: %v", pub.Name(), r)
+				}
+			}()
+			if pubErr := pub.Publish(ctx, event); pubErr != nil {
+				err = fmt.Errorf("publisher %s: %w", pub.Name(), pubErr)
+			}
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backoff returns the exponential delay before the given attempt is retried.
+func (p *Poller) backoff(attempts int) time.Duration {
+	d := p.config.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= p.config.MaxBackoff {
+			return p.config.MaxBackoff
+		}
+	}
+	return d
+}
+
+func (p *Poller) recordLag(ctx context.Context) {
+	pending, oldest, err := p.store.Lag(ctx)
+	if err != nil {
+		return
+	}
+	pendingGauge.Set(float64(pending))
+	lagGauge.Set(oldest.Seconds())
+}
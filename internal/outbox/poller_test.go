@@ -0,0 +1,209 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is an in-memory Store that mimics the lease semantics of the
+// FOR UPDATE SKIP LOCKED claim query.
+type memStore struct {
+	mu     sync.Mutex
+	events map[uuid.UUID]*Event
+}
+
+func newMemStore(n int) *memStore {
+	s := &memStore{events: make(map[uuid.UUID]*Event)}
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		id := uuid.New()
+		s.events[id] = &Event{
+			ID:            id,
+			EventType:     EventUserRegistered,
+			AggregateID:   uuid.New(),
+			Status:        StatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}
+	}
+	return s
+}
+
+func (s *memStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var out []Event
+	for _, e := range s.events {
+		if len(out) == limit {
+			break
+		}
+		if e.Status == StatusPending && !e.NextAttemptAt.After(now) {
+			e.NextAttemptAt = now.Add(lease)
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) MarkDelivered(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[id].Status = StatusDelivered
+	return nil
+}
+
+func (s *memStore) MarkFailed(ctx context.Context, id uuid.UUID, attempts int, next time.Time, lastError string, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.events[id]
+	e.Attempts = attempts
+	e.NextAttemptAt = next
+	e.LastError = &lastError
+	if dead {
+		e.Status = StatusDead
+	}
+	return nil
+}
+
+func (s *memStore) Lag(ctx context.Context) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, e := range s.events {
+		if e.Status == StatusPending {
+			n++
+		}
+	}
+	return n, 0, nil
+}
+
+type countingPublisher struct {
+	mu    sync.Mutex
+	seen  map[uuid.UUID]int
+	fail  func(Event) error
+	calls atomic.Int64
+}
+
+func (p *countingPublisher) Name() string { return "counting" }
+
+func (p *countingPublisher) Publish(ctx context.Context, e Event) error {
+	p.calls.Add(1)
+	if p.fail != nil {
+		if err := p.fail(e); err != nil {
+			return err
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seen[e.ID]++
+	return nil
+}
+
+func TestPoller_ConcurrentPollersDoNotDoubleDeliver(t *testing.T) {
+	store := newMemStore(200)
+	pub := &countingPublisher{seen: make(map[uuid.UUID]int)}
+	cfg := PollerConfig{BatchSize: 7, Lease: time.Minute}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := NewPoller(store, cfg, pub)
+			for {
+				n, err := p.ProcessBatch(context.Background())
+				require.NoError(t, err)
+				if n == 0 {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, pub.seen, 200, "every event should be delivered")
+	for id, n := range pub.seen {
+		assert.Equal(t, 1, n, "event %s delivered more than once", id)
+	}
+}
+
+func TestPoller_RetriesWithBackoff(t *testing.T) {
+	store := newMemStore(1)
+	pub := &countingPublisher{
+		seen: make(map[uuid.UUID]int),
+		fail: func(Event) error { return errors.New("downstream unavailable") },
+	}
+	p := NewPoller(store, PollerConfig{MaxAttempts: 5, BaseBackoff: time.Second, MaxBackoff: 3 * time.Second}, pub)
+
+	_, err := p.ProcessBatch(context.Background())
+	require.NoError(t, err)
+
+	for _, e := range store.events {
+		assert.Equal(t, StatusPending, e.Status)
+		assert.Equal(t, 1, e.Attempts)
+		require.NotNil(t, e.LastError)
+		assert.Contains(t, *e.LastError, "downstream unavailable")
+		assert.True(t, e.NextAttemptAt.After(time.Now()), "retry should be scheduled in the future")
+	}
+
+	// Not yet due, so nothing is claimed.
+	n, err := p.ProcessBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 2*time.Second, p.backoff(2))
+	assert.Equal(t, 3*time.Second, p.backoff(3), "backoff should be capped")
+}
+
+func TestPoller_PoisonMessageGoesDead(t *testing.T) {
+	store := newMemStore(2)
+	var poison uuid.UUID
+	for id := range store.events {
+		poison = id
+		break
+	}
+
+	pub := &countingPublisher{
+		seen: make(map[uuid.UUID]int),
+		fail: func(e Event) error {
+			if e.ID == poison {
+				panic("cannot decode payload")
+			}
+			return nil
+		},
+	}
+	p := NewPoller(store, PollerConfig{MaxAttempts: 3, BaseBackoff: time.Nanosecond, MaxBackoff: time.Nanosecond}, pub)
+
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond)
+		_, err := p.ProcessBatch(context.Background())
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, StatusDead, store.events[poison].Status)
+	assert.Equal(t, 3, store.events[poison].Attempts)
+	assert.Contains(t, *store.events[poison].LastError, "panicked")
+
+	for id, e := range store.events {
+		if id != poison {
+			assert.Equal(t, StatusDelivered, e.Status, "healthy events must not be blocked by the poison one")
+		}
+	}
+
+	// Dead events are never claimed again.
+	before := pub.calls.Load()
+	_, err := p.ProcessBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, before, pub.calls.Load())
+}
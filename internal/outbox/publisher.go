@@ -0,0 +1,98 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"dvith.com/go-service-api/pkg/httpclient"
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+// Publisher delivers outbox events to a downstream consumer. Delivery is
+// at-least-once, so publishers and their consumers must tolerate duplicates
+// (the event ID is stable across retries and can be used for deduplication).
+type Publisher interface {
+	Name() string
+	Publish(ctx context.Context, event Event) error
+}
+
+// LogPublisher writes every event to the application log. Useful in
+// development and as a fallback when no other publisher is configured.
+type LogPublisher struct{}
+
+// NewLogPublisher creates a new log publisher
+func NewLogPublisher() *LogPublisher {
+	return &LogPublisher{}
+}
+
+// Name returns the publisher name
+func (p *LogPublisher) Name() string { return "log" }
+
+// Publish logs the event
+func (p *LogPublisher) Publish(ctx context.Context, event Event) error {
+	logger.Info("outbox event published", map[string]any{
+		"event_id":     event.ID.String(),
+		"event_type":   event.EventType,
+		"aggregate_id": event.AggregateID.String(),
+		"attempts":     event.Attempts,
+	})
+	return nil
+}
+
+// WebhookPublisher POSTs each event as JSON to a fixed URL.
+type WebhookPublisher struct {
+	url    string
+	client *httpclient.Client
+}
+
+// NewWebhookPublisher creates a webhook publisher targeting url. Failed
+// deliveries aren't retried by the client; the poller retries the event
+// with its own backoff.
+func NewWebhookPublisher(url string, timeout time.Duration) *WebhookPublisher {
+	return &WebhookPublisher{
+		url:    url,
+		client: httpclient.New(httpclient.Options{Name: "webhook", Timeout: timeout, Retries: httpclient.NoRetries}),
+	}
+}
+
+// Name returns the publisher name
+func (p *WebhookPublisher) Name() string { return "webhook" }
+
+// Publish sends the event to the webhook URL. Any non-2xx response is
+// treated as a failure so the event is retried.
+func (p *WebhookPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(map[string]any{
+		"id":           event.ID,
+		"type":         event.EventType,
+		"aggregate_id": event.AggregateID,
+		"payload":      event.Payload,
+		"created_at":   event.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", event.ID.String())
+	req.Header.Set("X-Event-Type", event.EventType)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+)
+
+// Store is the persistence contract used by the Poller.
+type Store interface {
+	// Claim leases up to limit due events for the given duration. Events
+	// leased by one poller are not returned to others until the lease expires.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]Event, error)
+	// MarkDelivered records a successful delivery.
+	MarkDelivered(ctx context.Context, id uuid.UUID) error
+	// MarkFailed records a failed attempt and schedules the next one. When
+	// dead is true the event is parked and never retried.
+	MarkFailed(ctx context.Context, id uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string, dead bool) error
+	// Lag returns the number of undelivered events and the age of the oldest one.
+	Lag(ctx context.Context) (pending int64, oldest time.Duration, err error)
+}
+
+// PgStore implements Store on top of the events_outbox table
+type PgStore struct {
+	db *database.DBPool
+}
+
+// NewPgStore creates a new Postgres-backed outbox store
+func NewPgStore(db *database.DBPool) *PgStore {
+	return &PgStore{db: db}
+}
+
+// Claim selects due rows with FOR UPDATE SKIP LOCKED and pushes their
+// next_attempt_at forward by the lease, so concurrent pollers never pick up
+// the same event while it is being dispatched.
+func (s *PgStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]Event, error) {
+	query := `
+		UPDATE events_outbox
+		SET next_attempt_at = $3
+		WHERE id IN (
+			SELECT id FROM events_outbox
+			WHERE status = $1 AND next_attempt_at <= $2
+			ORDER BY created_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, aggregate_id, payload, status, attempts, last_error, next_attempt_at, created_at, delivered_at
+	`
+
+	now := clock.Now()
+	events, err := database.QueryAll[Event](ctx, s.db, query, StatusPending, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	return events, nil
+}
+
+// MarkDelivered marks an event as delivered
+func (s *PgStore) MarkDelivered(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE events_outbox
+		SET status = $2, delivered_at = $3, last_error = NULL
+		WHERE id = $1
+	`
+	if _, err := s.db.Exec(ctx, query, id, StatusDelivered, clock.Now()); err != nil {
+		return fmt.Errorf("failed to mark outbox event delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed delivery attempt
+func (s *PgStore) MarkFailed(ctx context.Context, id uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string, dead bool) error {
+	status := StatusPending
+	if dead {
+		status = StatusDead
+	}
+
+	query := `
+		UPDATE events_outbox
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_error = $5
+		WHERE id = $1
+	`
+	if _, err := s.db.Exec(ctx, query, id, status, attempts, nextAttemptAt.UTC(), lastError); err != nil {
+		return fmt.Errorf("failed to mark outbox event failed: %w", err)
+	}
+	return nil
+}
+
+// Lag reports how far behind the poller is
+func (s *PgStore) Lag(ctx context.Context) (int64, time.Duration, error) {
+	query := `
+		SELECT COUNT(*) AS count, MIN(created_at) AS oldest
+		FROM events_outbox
+		WHERE status = $1
+	`
+
+	lag, err := database.QueryOne[struct {
+		Count  int64      `db:"count"`
+		Oldest *time.Time `db:"oldest"`
+	}](ctx, s.db, query, StatusPending)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read outbox lag: %w", err)
+	}
+
+	if lag.Oldest == nil {
+		return lag.Count, 0, nil
+	}
+	return lag.Count, time.Since(*lag.Oldest), nil
+}
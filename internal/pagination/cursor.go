@@ -0,0 +1,35 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Cursor is the opaque position handed to clients as next_cursor. It
+// records the sort it was issued for so it can't be replayed against a
+// different ordering.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Order Order  `json:"o"`
+	Value string `json:"v"`
+	Key   string `json:"k"`
+}
+
+// EncodeCursor serializes c as unpadded base64url JSON
+func EncodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor
+func DecodeCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
@@ -0,0 +1,265 @@
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// Query parameters read by ParsePageRequest
+const (
+	ParamLimit  = "limit"
+	ParamCursor = "cursor"
+	ParamSort   = "sort"
+	ParamOrder  = "order"
+)
+
+// Default limits used when Options leaves them unset
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	// ErrInvalidLimit is returned when limit is not a positive integer
+	ErrInvalidLimit = errors.New("limit must be a positive integer")
+	// ErrInvalidSort is returned when sort names a field outside the whitelist
+	ErrInvalidSort = errors.New("unsupported sort field")
+	// ErrInvalidOrder is returned when order is neither asc nor desc
+	ErrInvalidOrder = errors.New("order must be asc or desc")
+	// ErrInvalidCursor is returned when the cursor is malformed or was
+	// issued for a different sort
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidFilter is returned when a filter value can't be parsed
+	ErrInvalidFilter = errors.New("invalid filter value")
+)
+
+// IsClientError reports whether err was caused by bad query parameters
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrInvalidLimit) ||
+		errors.Is(err, ErrInvalidSort) ||
+		errors.Is(err, ErrInvalidOrder) ||
+		errors.Is(err, ErrInvalidCursor) ||
+		errors.Is(err, ErrInvalidFilter)
+}
+
+// Order is a sort direction
+type Order string
+
+// Sort directions
+const (
+	Asc  Order = "asc"
+	Desc Order = "desc"
+)
+
+// Kind is the type of a sortable or filterable column, used to parse
+// cursor and filter values into typed query arguments
+type Kind int
+
+// Column kinds
+const (
+	KindString Kind = iota
+	KindTime
+	KindInt
+	KindBool
+	KindUUID
+	KindFloat
+)
+
+// Field maps a public parameter name to a column. Column is written into
+// SQL verbatim, so it must come from code, never from the request.
+type Field struct {
+	Column string
+	Kind   Kind
+}
+
+// Options describes what a list endpoint accepts
+type Options struct {
+	// DefaultLimit and MaxLimit default to the package constants when zero
+	DefaultLimit int
+	MaxLimit     int
+	// Sorts whitelists the sort parameter values
+	Sorts map[string]Field
+	// DefaultSort must be a key of Sorts
+	DefaultSort  string
+	DefaultOrder Order
+	// Key is the unique tie-breaker appended to every ORDER BY so keyset
+	// pages are stable; it defaults to a UUID id column
+	Key Field
+	// Filters whitelists query parameters matched by equality
+	Filters map[string]Field
+}
+
+// Filter is a parsed equality filter
+type Filter struct {
+	Field Field
+	Value any
+}
+
+// PageRequest is a validated list request
+type PageRequest struct {
+	Limit   int
+	Sort    string
+	Order   Order
+	Filters []Filter
+	// After is the position the page starts after, nil for the first page
+	After *Position
+
+	sort Field
+	key  Field
+}
+
+// Position is the sort value and key of the last row of a page
+type Position struct {
+	Value any
+	Key   any
+}
+
+// ParsePageRequest reads limit, cursor, sort, order and the whitelisted
+// filters from the query string. Limits above the maximum are capped
+// rather than rejected.
+func ParsePageRequest(c fiber.Ctx, opts Options) (PageRequest, error) {
+	opts = opts.withDefaults()
+
+	req := PageRequest{
+		Limit: opts.DefaultLimit,
+		Sort:  opts.DefaultSort,
+		Order: opts.DefaultOrder,
+		key:   opts.Key,
+	}
+
+	if v := c.Query(ParamLimit); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return PageRequest{}, ErrInvalidLimit
+		}
+		req.Limit = min(n, opts.MaxLimit)
+	}
+
+	if v := c.Query(ParamSort); v != "" {
+		req.Sort = v
+	}
+	field, ok := opts.Sorts[req.Sort]
+	if !ok {
+		return PageRequest{}, fmt.Errorf("%w: %q", ErrInvalidSort, req.Sort)
+	}
+	req.sort = field
+
+	if v := c.Query(ParamOrder); v != "" {
+		switch Order(strings.ToLower(v)) {
+		case Asc:
+			req.Order = Asc
+		case Desc:
+			req.Order = Desc
+		default:
+			return PageRequest{}, ErrInvalidOrder
+		}
+	}
+
+	for _, name := range sortedKeys(opts.Filters) {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		f := opts.Filters[name]
+		value, err := parseValue(f.Kind, v)
+		if err != nil {
+			return PageRequest{}, fmt.Errorf("%w for %s", ErrInvalidFilter, name)
+		}
+		req.Filters = append(req.Filters, Filter{Field: f, Value: value})
+	}
+
+	if v := c.Query(ParamCursor); v != "" {
+		cur, err := DecodeCursor(v)
+		if err != nil {
+			return PageRequest{}, err
+		}
+		after, err := req.position(cur)
+		if err != nil {
+			return PageRequest{}, err
+		}
+		req.After = after
+	}
+
+	return req, nil
+}
+
+// NextCursor encodes the position of the given row for the next page
+func (p PageRequest) NextCursor(value, key any) string {
+	return EncodeCursor(Cursor{
+		Sort:  p.Sort,
+		Order: p.Order,
+		Value: formatValue(value),
+		Key:   formatValue(key),
+	})
+}
+
+// position checks that cur was issued for the same sort as p and parses
+// its values into typed arguments
+func (p PageRequest) position(cur Cursor) (*Position, error) {
+	if cur.Sort != p.Sort || cur.Order != p.Order {
+		return nil, fmt.Errorf("%w: issued for a different sort", ErrInvalidCursor)
+	}
+	value, err := parseValue(p.sort.Kind, cur.Value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	key, err := parseValue(p.key.Kind, cur.Key)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Position{Value: value, Key: key}, nil
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = MaxLimit
+	}
+	if o.DefaultLimit <= 0 {
+		o.DefaultLimit = min(DefaultLimit, o.MaxLimit)
+	}
+	if o.DefaultOrder == "" {
+		o.DefaultOrder = Asc
+	}
+	if o.Key.Column == "" {
+		o.Key = Field{Column: "id", Kind: KindUUID}
+	}
+	return o
+}
+
+func parseValue(kind Kind, v string) (any, error) {
+	switch kind {
+	case KindTime:
+		return time.Parse(time.RFC3339Nano, v)
+	case KindInt:
+		return strconv.ParseInt(v, 10, 64)
+	case KindBool:
+		return strconv.ParseBool(v)
+	case KindUUID:
+		return uuid.Parse(v)
+	case KindFloat:
+		return strconv.ParseFloat(v, 64)
+	default:
+		return v, nil
+	}
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case float64:
+		// The shortest form that parses back to the same value, so a
+		// cursor lands exactly on the row it was issued for
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
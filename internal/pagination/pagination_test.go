@@ -0,0 +1,190 @@
+package pagination
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testOptions = Options{
+	MaxLimit: 50,
+	Sorts: map[string]Field{
+		"created_at": {Column: "created_at", Kind: KindTime},
+		"email":      {Column: "email", Kind: KindString},
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: Desc,
+	Filters: map[string]Field{
+		"is_active": {Column: "is_active", Kind: KindBool},
+		"role":      {Column: "role", Kind: KindString},
+	},
+}
+
+// parse runs ParsePageRequest against the given query string
+func parse(t *testing.T, query string) (PageRequest, error) {
+	t.Helper()
+	var req PageRequest
+	var parseErr error
+
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) error {
+		req, parseErr = ParsePageRequest(c, testOptions)
+		return nil
+	})
+	_, err := app.Test(httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+	require.NoError(t, err)
+	return req, parseErr
+}
+
+func TestParsePageRequest_Defaults(t *testing.T) {
+	req, err := parse(t, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultLimit, req.Limit)
+	assert.Equal(t, "created_at", req.Sort)
+	assert.Equal(t, Desc, req.Order)
+	assert.Nil(t, req.After)
+	assert.Empty(t, req.Filters)
+}
+
+func TestParsePageRequest_LimitCapped(t *testing.T) {
+	req, err := parse(t, "limit=10000")
+	require.NoError(t, err)
+	assert.Equal(t, 50, req.Limit)
+
+	req, err = parse(t, "limit=5")
+	require.NoError(t, err)
+	assert.Equal(t, 5, req.Limit)
+}
+
+func TestParsePageRequest_InvalidLimit(t *testing.T) {
+	for _, v := range []string{"0", "-1", "abc", "1.5"} {
+		_, err := parse(t, "limit="+v)
+		assert.ErrorIs(t, err, ErrInvalidLimit, v)
+		assert.True(t, IsClientError(err))
+	}
+}
+
+func TestParsePageRequest_SortInjection(t *testing.T) {
+	for _, v := range []string{
+		"email;DROP TABLE users",
+		"email DESC, password",
+		"(SELECT password FROM users)",
+		"password",
+		"EMAIL",
+	} {
+		_, err := parse(t, "sort="+url.QueryEscape(v))
+		assert.ErrorIs(t, err, ErrInvalidSort, v)
+	}
+
+	_, err := parse(t, "sort=email&order=desc;DELETE")
+	assert.ErrorIs(t, err, ErrInvalidOrder)
+}
+
+func TestParsePageRequest_Filters(t *testing.T) {
+	req, err := parse(t, "is_active=true&role=admin&unknown=x")
+	require.NoError(t, err)
+	require.Len(t, req.Filters, 2)
+	assert.Equal(t, true, req.Filters[0].Value)
+	assert.Equal(t, "admin", req.Filters[1].Value)
+
+	_, err = parse(t, "is_active=maybe")
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func TestCursor_RoundTrip(t *testing.T) {
+	req, err := parse(t, "")
+	require.NoError(t, err)
+
+	at := time.Date(2026, 3, 1, 12, 30, 0, 123456789, time.FixedZone("x", 3600))
+	id := uuid.New()
+	next := req.NextCursor(at, id)
+
+	cur, err := DecodeCursor(next)
+	require.NoError(t, err)
+	assert.Equal(t, "created_at", cur.Sort)
+	assert.Equal(t, Desc, cur.Order)
+
+	req, err = parse(t, "cursor="+next)
+	require.NoError(t, err)
+	require.NotNil(t, req.After)
+	assert.True(t, at.Equal(req.After.Value.(time.Time)), "nanoseconds survive the round trip")
+	assert.Equal(t, id, req.After.Key)
+}
+
+func TestCursor_Rejected(t *testing.T) {
+	_, err := parse(t, "cursor=not-base64!")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = parse(t, "cursor="+EncodeCursor(Cursor{Sort: "created_at", Order: Desc, Value: "yesterday", Key: uuid.NewString()}))
+	assert.ErrorIs(t, err, ErrInvalidCursor, "value must parse as the sort column's kind")
+
+	_, err = parse(t, "cursor="+EncodeCursor(Cursor{Sort: "created_at", Order: Desc, Value: time.Now().Format(time.RFC3339Nano), Key: "1 OR 1=1"}))
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	emailCursor := EncodeCursor(Cursor{Sort: "email", Order: Asc, Value: "a@example.com", Key: uuid.NewString()})
+	_, err = parse(t, "cursor="+emailCursor)
+	assert.ErrorIs(t, err, ErrInvalidCursor, "cursor issued for another sort")
+
+	_, err = parse(t, "sort=email&order=asc&cursor="+emailCursor)
+	assert.NoError(t, err)
+}
+
+func TestClauses(t *testing.T) {
+	req, err := parse(t, "sort=email&order=asc&limit=10&role=admin")
+	require.NoError(t, err)
+
+	c := req.Clauses(2)
+	assert.Equal(t, " AND role = $2", c.And())
+	assert.Equal(t, "ORDER BY email ASC, id ASC", c.OrderBy)
+	assert.Equal(t, "LIMIT $3", c.Limit)
+	assert.Equal(t, []any{"admin", 11}, c.Args)
+
+	id := uuid.New()
+	req, err = parse(t, "role=admin&cursor="+req.NextCursor("x@example.com", id))
+	assert.ErrorIs(t, err, ErrInvalidCursor, "default sort differs from cursor sort")
+
+	req, err = parse(t, "sort=email&order=desc&role=admin&cursor="+
+		EncodeCursor(Cursor{Sort: "email", Order: Desc, Value: "x@example.com'; --", Key: id.String()}))
+	require.NoError(t, err)
+
+	c = req.Clauses(2)
+	assert.Equal(t, " AND role = $2 AND (email, id) < ($3, $4)", c.And())
+	assert.Equal(t, "ORDER BY email DESC, id DESC", c.OrderBy)
+	assert.Equal(t, "LIMIT $5", c.Limit)
+	assert.Equal(t, []any{"admin", "x@example.com'; --", id, DefaultLimit + 1}, c.Args)
+	assert.NotContains(t, c.And()+c.OrderBy, "--", "cursor values are arguments, never SQL")
+
+	f := req.FilterClauses(2)
+	assert.Equal(t, " AND role = $2", f.And())
+	assert.Equal(t, []any{"admin"}, f.Args)
+}
+
+func TestTrim(t *testing.T) {
+	req := PageRequest{Limit: 2}
+
+	rows, more := Trim(req, []int{1, 2, 3})
+	assert.Equal(t, []int{1, 2}, rows)
+	assert.True(t, more)
+
+	rows, more = Trim(req, []int{1, 2})
+	assert.Equal(t, []int{1, 2}, rows)
+	assert.False(t, more)
+}
+
+func TestBuildPageResponse(t *testing.T) {
+	resp := BuildPageResponse[string](nil, 0, "")
+	assert.NotNil(t, resp.Items)
+	assert.Empty(t, resp.NextCursor)
+
+	resp = BuildPageResponse([]string{"a"}, 7, "abc")
+	assert.Equal(t, int64(7), resp.Total)
+	assert.Equal(t, "abc", resp.NextCursor)
+}
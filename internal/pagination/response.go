@@ -0,0 +1,17 @@
+package pagination
+
+// PageResponse is the standard envelope for list endpoints
+type PageResponse[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// BuildPageResponse wraps a page of items. Items is never null in the
+// JSON, so clients can iterate without a nil check.
+func BuildPageResponse[T any](items []T, total int64, nextCursor string) PageResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return PageResponse[T]{Items: items, Total: total, NextCursor: nextCursor}
+}
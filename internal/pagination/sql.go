@@ -0,0 +1,85 @@
+package pagination
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Clauses are the SQL fragments for a page. Column names come only from
+// the Options whitelist and every value is a placeholder argument, so no
+// request input is ever concatenated into the statement.
+type Clauses struct {
+	// Conditions are predicates to AND into the WHERE clause
+	Conditions []string
+	// OrderBy is the full ORDER BY clause including the key tie-breaker
+	OrderBy string
+	// Limit fetches one row more than requested so Trim can tell whether
+	// another page exists
+	Limit string
+	Args  []any
+}
+
+// And renders the conditions for appending to an existing WHERE clause,
+// e.g. " AND is_active = $2", or "" when there are none
+func (c Clauses) And() string {
+	if len(c.Conditions) == 0 {
+		return ""
+	}
+	return " AND " + strings.Join(c.Conditions, " AND ")
+}
+
+// FilterClauses renders only the filters, for the matching COUNT query.
+// Placeholders are numbered from next.
+func (p PageRequest) FilterClauses(next int) Clauses {
+	var c Clauses
+	for _, f := range p.Filters {
+		c.Conditions = append(c.Conditions, fmt.Sprintf("%s = $%d", f.Field.Column, next))
+		c.Args = append(c.Args, f.Value)
+		next++
+	}
+	return c
+}
+
+// Clauses renders the filters, the keyset condition, ORDER BY and LIMIT.
+// Placeholders are numbered from next.
+func (p PageRequest) Clauses(next int) Clauses {
+	c := p.FilterClauses(next)
+	next += len(c.Args)
+
+	dir, cmp := "ASC", ">"
+	if p.Order == Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	if p.After != nil {
+		c.Conditions = append(c.Conditions, fmt.Sprintf("(%s, %s) %s ($%d, $%d)",
+			p.sort.Column, p.key.Column, cmp, next, next+1))
+		c.Args = append(c.Args, p.After.Value, p.After.Key)
+		next += 2
+	}
+
+	c.OrderBy = fmt.Sprintf("ORDER BY %s %s, %s %s", p.sort.Column, dir, p.key.Column, dir)
+	c.Limit = fmt.Sprintf("LIMIT $%d", next)
+	c.Args = append(c.Args, p.Limit+1)
+
+	return c
+}
+
+// Trim drops the extra row fetched by Clauses and reports whether there
+// is a next page
+func Trim[T any](p PageRequest, rows []T) ([]T, bool) {
+	if len(rows) > p.Limit {
+		return rows[:p.Limit], true
+	}
+	return rows, false
+}
+
+func sortedKeys(m map[string]Field) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,265 @@
+// Package preferences stores each user's email opt-ins, locale and time
+// zone, and decides whether an email may be sent to them.
+package preferences
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	// Time zones are checked with time.LoadLocation, which needs the IANA
+	// database even in images without /usr/share/zoneinfo
+	_ "time/tzdata"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/text/language"
+)
+
+// Defaults of a user who hasn't changed anything, matching the column
+// defaults of user_preferences
+const (
+	DefaultLocale   = "en"
+	DefaultTimezone = "UTC"
+)
+
+var (
+	// ErrInvalidLocale is returned for a locale that isn't a BCP 47 tag
+	ErrInvalidLocale = errors.New("locale must be a BCP 47 language tag")
+	// ErrInvalidTimezone is returned for a time zone the IANA database
+	// doesn't know
+	ErrInvalidTimezone = errors.New("timezone must be an IANA time zone name")
+)
+
+// Preferences represents a row in the user_preferences table
+type Preferences struct {
+	UserID         uuid.UUID `db:"user_id"`
+	TenantID       uuid.UUID `db:"tenant_id"`
+	SecurityEmails bool      `db:"security_emails"`
+	ProductEmails  bool      `db:"product_emails"`
+	Locale         string    `db:"locale"`
+	Timezone       string    `db:"timezone"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
+
+// Defaults returns the preferences of a user who hasn't changed anything
+func Defaults(userID uuid.UUID) Preferences {
+	return Preferences{
+		UserID:         userID,
+		SecurityEmails: true,
+		ProductEmails:  false,
+		Locale:         DefaultLocale,
+		Timezone:       DefaultTimezone,
+	}
+}
+
+// Patch changes the fields it sets and leaves nil ones alone
+type Patch struct {
+	SecurityEmails *bool
+	ProductEmails  *bool
+	Locale         *string
+	Timezone       *string
+}
+
+// normalized checks p's locale and time zone, returning p with the locale
+// in its canonical form, so en_us is stored as en-US
+func (p Patch) normalized() (Patch, error) {
+	if p.Locale != nil {
+		tag, err := language.Parse(strings.ReplaceAll(*p.Locale, "_", "-"))
+		if err != nil {
+			return p, ErrInvalidLocale
+		}
+		locale := tag.String()
+		p.Locale = &locale
+	}
+	if p.Timezone != nil {
+		if err := ValidateTimezone(*p.Timezone); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// apply sets the fields of prefs that p changes
+func (p Patch) apply(prefs *Preferences) {
+	if p.SecurityEmails != nil {
+		prefs.SecurityEmails = *p.SecurityEmails
+	}
+	if p.ProductEmails != nil {
+		prefs.ProductEmails = *p.ProductEmails
+	}
+	if p.Locale != nil {
+		prefs.Locale = *p.Locale
+	}
+	if p.Timezone != nil {
+		prefs.Timezone = *p.Timezone
+	}
+}
+
+// ValidateTimezone checks that name is in the IANA time zone database.
+// time.LoadLocation also accepts "" and "Local", which name no zone.
+func ValidateTimezone(name string) error {
+	if name == "" || strings.EqualFold(name, "local") {
+		return ErrInvalidTimezone
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return ErrInvalidTimezone
+	}
+	return nil
+}
+
+// Store reads and writes preferences in the current tenant
+type Store interface {
+	// Get returns the user's preferences, saving the defaults first when
+	// the user has none yet
+	Get(ctx context.Context, userID uuid.UUID) (*Preferences, error)
+	// Update applies patch to the user's preferences and returns them. It
+	// returns ErrInvalidLocale or ErrInvalidTimezone without saving.
+	Update(ctx context.Context, userID uuid.UUID, patch Patch) (*Preferences, error)
+}
+
+var (
+	_ Store = (*Repository)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// Kind is what an email is about, which decides whether a user can opt
+// out of it
+type Kind int
+
+const (
+	// Critical email, such as a password reset link or the notice that
+	// the account's email is changing, is always sent
+	Critical Kind = iota
+	// Security email, such as a new sign-in notice, is sent unless the
+	// user turned SecurityEmails off
+	Security
+	// Product email, such as announcements, is only sent when the user
+	// turned ProductEmails on
+	Product
+)
+
+// Allows reports whether email of kind may be sent under p
+func (p *Preferences) Allows(kind Kind) bool {
+	switch kind {
+	case Security:
+		return p.SecurityEmails
+	case Product:
+		return p.ProductEmails
+	default:
+		return true
+	}
+}
+
+// Getter loads a user's preferences; Store satisfies it
+type Getter interface {
+	Get(ctx context.Context, userID uuid.UUID) (*Preferences, error)
+}
+
+// Allowed reports whether email of kind may be sent to the user. Critical
+// email is allowed without a lookup. When the preferences can't be loaded,
+// security email is still sent, as the defaults would, and product email
+// isn't.
+func Allowed(ctx context.Context, prefs Getter, userID uuid.UUID, kind Kind) bool {
+	if kind == Critical || prefs == nil {
+		return kind != Product
+	}
+
+	p, err := prefs.Get(ctx, userID)
+	if err != nil {
+		logger.Warn("failed to load email preferences", map[string]any{
+			"user_id": userID.String(),
+			"error":   err.Error(),
+		})
+		return kind == Security
+	}
+	return p.Allows(kind)
+}
+
+const preferenceColumns = `user_id, tenant_id, security_emails, product_emails, locale, timezone, created_at, updated_at`
+
+// Repository is the PostgreSQL Store
+type Repository struct {
+	q database.Querier
+}
+
+// NewRepository creates a preferences repository on q
+func NewRepository(q database.Querier) *Repository {
+	return &Repository{q: q}
+}
+
+// Get implements Store. Reads of an existing row don't write; the first
+// read inserts the defaults, tolerating a concurrent first read.
+func (repo *Repository) Get(ctx context.Context, userID uuid.UUID) (*Preferences, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs, err := repo.find(ctx, tenantID, userID)
+	if err == nil || !errors.Is(err, pgx.ErrNoRows) {
+		return prefs, err
+	}
+
+	query := `
+		INSERT INTO user_preferences (user_id, tenant_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+	if _, err := repo.q.Exec(ctx, query, userID, tenantID, clock.Now()); err != nil {
+		return nil, fmt.Errorf("failed to create preferences: %w", err)
+	}
+	return repo.find(ctx, tenantID, userID)
+}
+
+// Update implements Store
+func (repo *Repository) Update(ctx context.Context, userID uuid.UUID, patch Patch) (*Preferences, error) {
+	patch, err := patch.normalized()
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A user updating before their first read gets the row created with
+	// the defaults and the patch applied
+	query := `
+		INSERT INTO user_preferences (user_id, tenant_id, security_emails, product_emails, locale, timezone, created_at, updated_at)
+		VALUES ($1, $2, COALESCE($3, TRUE), COALESCE($4, FALSE), COALESCE($5, '` + DefaultLocale + `'), COALESCE($6, '` + DefaultTimezone + `'), $7, $7)
+		ON CONFLICT (user_id) DO UPDATE SET
+			security_emails = COALESCE($3, user_preferences.security_emails),
+			product_emails = COALESCE($4, user_preferences.product_emails),
+			locale = COALESCE($5, user_preferences.locale),
+			timezone = COALESCE($6, user_preferences.timezone),
+			updated_at = $7
+		WHERE user_preferences.tenant_id = $2
+		RETURNING ` + preferenceColumns
+
+	prefs, err := database.QueryOne[Preferences](ctx, repo.q, query, userID, tenantID, patch.SecurityEmails, patch.ProductEmails, patch.Locale, patch.Timezone, clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to update preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+func (repo *Repository) find(ctx context.Context, tenantID, userID uuid.UUID) (*Preferences, error) {
+	query := `SELECT ` + preferenceColumns + ` FROM user_preferences WHERE tenant_id = $1 AND user_id = $2`
+
+	prefs, err := database.QueryOne[Preferences](ctx, repo.q, query, tenantID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to find preferences: %w", err)
+	}
+	return prefs, nil
+}
@@ -0,0 +1,84 @@
+package preferences
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps preferences in memory for development mode and tests
+type MemoryStore struct {
+	mu    sync.Mutex
+	prefs map[memoryKey]Preferences
+	now   func() time.Time
+}
+
+type memoryKey struct {
+	tenantID uuid.UUID
+	userID   uuid.UUID
+}
+
+// NewMemoryStore creates an empty in-memory preferences store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{prefs: map[memoryKey]Preferences{}, now: clock.Now}
+}
+
+// WithClock replaces the time source for timestamps, for tests
+func (m *MemoryStore) WithClock(now func() time.Time) *MemoryStore {
+	m.now = now
+	return m
+}
+
+// Get implements Store
+func (m *MemoryStore) Get(ctx context.Context, userID uuid.UUID) (*Preferences, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefs := m.getLocked(memoryKey{tenantID: tenantID, userID: userID})
+	return &prefs, nil
+}
+
+// Update implements Store
+func (m *MemoryStore) Update(ctx context.Context, userID uuid.UUID, patch Patch) (*Preferences, error) {
+	patch, err := patch.normalized()
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryKey{tenantID: tenantID, userID: userID}
+	prefs := m.getLocked(key)
+	patch.apply(&prefs)
+	prefs.UpdatedAt = m.now()
+	m.prefs[key] = prefs
+	return &prefs, nil
+}
+
+// getLocked returns the preferences at key, saving the defaults first when
+// there are none. m.mu must be held.
+func (m *MemoryStore) getLocked(key memoryKey) Preferences {
+	prefs, ok := m.prefs[key]
+	if !ok {
+		prefs = Defaults(key.userID)
+		prefs.TenantID = key.tenantID
+		prefs.CreatedAt = m.now()
+		prefs.UpdatedAt = prefs.CreatedAt
+		m.prefs[key] = prefs
+	}
+	return prefs
+}
@@ -0,0 +1,106 @@
+package preferences
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestMemoryStore_CreatesDefaultsLazily(t *testing.T) {
+	created := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	now := created
+	store := NewMemoryStore().WithClock(func() time.Time { return now })
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	userID := uuid.New()
+
+	prefs, err := store.Get(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, prefs.SecurityEmails)
+	assert.False(t, prefs.ProductEmails)
+	assert.Equal(t, DefaultLocale, prefs.Locale)
+	assert.Equal(t, DefaultTimezone, prefs.Timezone)
+	assert.Equal(t, created, prefs.CreatedAt)
+
+	now = created.Add(time.Hour)
+	again, err := store.Get(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, created, again.CreatedAt, "the defaults are saved once")
+
+	other, err := store.Get(tenant.WithID(context.Background(), uuid.New()), userID)
+	require.NoError(t, err)
+	assert.Equal(t, now, other.CreatedAt, "tenants don't share preferences")
+}
+
+func TestMemoryStore_Update(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	userID := uuid.New()
+
+	prefs, err := store.Update(ctx, userID, Patch{ProductEmails: ptr(true), Locale: ptr("pt_br")})
+	require.NoError(t, err)
+	assert.True(t, prefs.SecurityEmails, "unset fields keep the defaults")
+	assert.True(t, prefs.ProductEmails)
+	assert.Equal(t, "pt-BR", prefs.Locale)
+
+	_, err = store.Update(ctx, userID, Patch{Timezone: ptr("Europe/Atlantis"), ProductEmails: ptr(false)})
+	assert.ErrorIs(t, err, ErrInvalidTimezone)
+	_, err = store.Update(ctx, userID, Patch{Locale: ptr("english please")})
+	assert.ErrorIs(t, err, ErrInvalidLocale)
+
+	prefs, err = store.Get(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, prefs.ProductEmails, "rejected patches change nothing")
+}
+
+func TestValidateTimezone(t *testing.T) {
+	for _, name := range []string{"UTC", "Asia/Bangkok", "America/Argentina/Buenos_Aires"} {
+		assert.NoError(t, ValidateTimezone(name), name)
+	}
+	for _, name := range []string{"", "Local", "local", "Mars/Olympus_Mons", "../etc/passwd", "GMT+7"} {
+		assert.ErrorIs(t, ValidateTimezone(name), ErrInvalidTimezone, name)
+	}
+}
+
+type fakeGetter struct {
+	prefs Preferences
+	err   error
+	calls int
+}
+
+func (g *fakeGetter) Get(ctx context.Context, userID uuid.UUID) (*Preferences, error) {
+	g.calls++
+	if g.err != nil {
+		return nil, g.err
+	}
+	return &g.prefs, nil
+}
+
+func TestAllowed(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	optedOut := &fakeGetter{prefs: Preferences{SecurityEmails: false, ProductEmails: false}}
+	assert.True(t, Allowed(ctx, optedOut, userID, Critical))
+	assert.Zero(t, optedOut.calls, "critical email needs no lookup")
+	assert.False(t, Allowed(ctx, optedOut, userID, Security))
+	assert.False(t, Allowed(ctx, optedOut, userID, Product))
+
+	optedIn := &fakeGetter{prefs: Preferences{SecurityEmails: true, ProductEmails: true}}
+	assert.True(t, Allowed(ctx, optedIn, userID, Security))
+	assert.True(t, Allowed(ctx, optedIn, userID, Product))
+
+	failing := &fakeGetter{err: errors.New("connection refused")}
+	assert.True(t, Allowed(ctx, failing, userID, Security), "security email fails open")
+	assert.False(t, Allowed(ctx, failing, userID, Product), "product email fails closed")
+
+	assert.True(t, Allowed(ctx, nil, userID, Security), "without a store the defaults apply")
+	assert.False(t, Allowed(ctx, nil, userID, Product))
+}
@@ -0,0 +1,335 @@
+// Package preflight checks everything the service needs before it starts
+// serving: valid configuration, a reachable and migrated database, a strong
+// token secret, a writable log file, a well-formed fault injection spec
+// and, when enabled, queries that prepare against the live schema. Every check runs even after one fails, so a
+// single start reports every problem at once.
+package preflight
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/queries"
+	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+// Status is the outcome of one check
+type Status string
+
+const (
+	Pass Status = "pass"
+	// Warn is reported but doesn't stop the service from starting
+	Warn Status = "warn"
+	Fail Status = "fail"
+	// Skip means the check doesn't apply, e.g. no database is configured
+	Skip Status = "skip"
+)
+
+// MinSecretLength is the shortest JWT secret accepted in production. HS256
+// keys shorter than the 32-byte hash output weaken the signature.
+const MinSecretLength = 32
+
+// placeholderSecrets are the example secrets shipped in the defaults and
+// docs; a service signing tokens with one of them can be impersonated
+var placeholderSecrets = []string{
+	"your-secret-key-change-in-production",
+	"your_jwt_secret_key",
+	"secret",
+	"changeme",
+}
+
+// Result is one row of the report
+type Result struct {
+	Check  string `json:"check"`
+	Status Status `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Problems lists each failure separately, e.g. every invalid field
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Report is the outcome of every check
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// OK reports whether no check failed
+func (r Report) OK() bool {
+	return r.Err() == nil
+}
+
+// Err joins every failure into one error, or returns nil
+func (r Report) Err() error {
+	var errs []error
+	for _, res := range r.Results {
+		if res.Status != Fail {
+			continue
+		}
+		if len(res.Problems) == 0 {
+			errs = append(errs, fmt.Errorf("%s: %s", res.Check, res.Detail))
+		}
+		for _, p := range res.Problems {
+			errs = append(errs, fmt.Errorf("%s: %s", res.Check, p))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Log writes the report through the logger, one entry per check and a
+// summary, so it reads as a table in text output and stays structured in
+// JSON output. Failures are logged at error level.
+func (r Report) Log() {
+	failed := 0
+	for _, res := range r.Results {
+		fields := map[string]any{"check": res.Check, "status": string(res.Status)}
+		if res.Detail != "" {
+			fields["detail"] = res.Detail
+		}
+		switch res.Status {
+		case Fail:
+			failed++
+			if len(res.Problems) == 0 {
+				logger.Error("preflight", fields)
+			}
+			for _, p := range res.Problems {
+				logger.Error("preflight", map[string]any{"check": res.Check, "status": string(res.Status), "detail": p})
+			}
+		case Warn:
+			logger.Warn("preflight", fields)
+		default:
+			logger.Info("preflight", fields)
+		}
+	}
+	summary := map[string]any{"checks": len(r.Results), "failed": failed}
+	if failed > 0 {
+		logger.Error("preflight failed, refusing to start", summary)
+		return
+	}
+	logger.Info("preflight passed", summary)
+}
+
+// DB is the database the checks run against
+type DB interface {
+	database.Querier
+	queries.Preparer
+	Health(ctx context.Context) error
+}
+
+// Options tunes the checks
+type Options struct {
+	// MigrationsDir holds the SQL migrations whose tables must exist
+	MigrationsDir string
+	// Timeout bounds each database check
+	Timeout time.Duration
+}
+
+// Run performs every check and returns the report. db is nil when the
+// service runs on in-memory stores or the pool couldn't be created, in
+// which case dbErr says why.
+func Run(ctx context.Context, cfg config.Config, db DB, dbErr error, opts Options) Report {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.MigrationsDir == "" {
+		opts.MigrationsDir = "migrations"
+	}
+
+	return Report{Results: []Result{
+		checkConfig(cfg),
+		checkSecret(cfg),
+		checkLogFile(cfg.LogFile),
+		checkFaults(cfg),
+		checkDatabase(ctx, cfg, db, dbErr, opts.Timeout),
+		checkMigrations(ctx, db, opts),
+		checkQueries(ctx, cfg, db, opts.Timeout),
+	}}
+}
+
+func checkConfig(cfg config.Config) Result {
+	err := cfg.Validate()
+	if err == nil {
+		return Result{Check: "config", Status: Pass}
+	}
+	res := Result{Check: "config", Status: Fail, Detail: "invalid configuration"}
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		for _, e := range joined.Unwrap() {
+			res.Problems = append(res.Problems, e.Error())
+		}
+	} else {
+		res.Problems = []string{err.Error()}
+	}
+	return res
+}
+
+// checkSecret fails weak JWT secrets in production and warns elsewhere,
+// where the shipped default keeps local setups working
+func checkSecret(cfg config.Config) Result {
+	secret := strings.TrimSpace(cfg.JWTSecretKey)
+	var problem string
+	switch {
+	case secret == "":
+		// Already reported by the config check
+		return Result{Check: "jwt_secret", Status: Skip, Detail: "not set"}
+	case slices.Contains(placeholderSecrets, strings.ToLower(secret)):
+		problem = "JWT_SECRET_KEY is a published placeholder"
+	case len(secret) < MinSecretLength:
+		problem = fmt.Sprintf("JWT_SECRET_KEY is %d bytes, want at least %d", len(secret), MinSecretLength)
+	default:
+		return Result{Check: "jwt_secret", Status: Pass}
+	}
+	if cfg.IsProduction() {
+		return Result{Check: "jwt_secret", Status: Fail, Detail: problem}
+	}
+	return Result{Check: "jwt_secret", Status: Warn, Detail: problem}
+}
+
+// checkLogFile opens the log file for appending, creating it if needed,
+// without writing to it
+func checkLogFile(path string) Result {
+	if path == "" {
+		return Result{Check: "log_file", Status: Skip, Detail: "logging to stdout only"}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return Result{Check: "log_file", Status: Fail, Detail: fmt.Sprintf("LOG_FILE is not writable: %v", err)}
+	}
+	_ = f.Close()
+	return Result{Check: "log_file", Status: Pass, Detail: path}
+}
+
+func checkDatabase(ctx context.Context, cfg config.Config, db DB, dbErr error, timeout time.Duration) Result {
+	if cfg.UsesMemoryStore() {
+		return Result{Check: "database", Status: Skip, Detail: "in-memory stores"}
+	}
+	if db == nil {
+		if dbErr == nil {
+			dbErr = errors.New("no connection pool")
+		}
+		return Result{Check: "database", Status: Fail, Detail: dbErr.Error()}
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := db.Health(pingCtx); err != nil {
+		return Result{Check: "database", Status: Fail, Detail: err.Error()}
+	}
+	return Result{Check: "database", Status: Pass}
+}
+
+// checkMigrations confirms every table created by a migration exists. The
+// migrations are applied by hand, so the live schema is the only record of
+// which ones ran.
+func checkMigrations(ctx context.Context, db DB, opts Options) Result {
+	if db == nil {
+		return Result{Check: "migrations", Status: Skip, Detail: "no database"}
+	}
+	tables, err := migrationTables(opts.MigrationsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return Result{Check: "migrations", Status: Skip, Detail: fmt.Sprintf("%s not found", opts.MigrationsDir)}
+	}
+	if err != nil {
+		return Result{Check: "migrations", Status: Fail, Detail: err.Error()}
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	var missing []string
+	for _, table := range tables {
+		var exists bool
+		if err := db.QueryRow(queryCtx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+			return Result{Check: "migrations", Status: Fail, Detail: fmt.Sprintf("failed to inspect schema: %v", err)}
+		}
+		if !exists {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) > 0 {
+		return Result{Check: "migrations", Status: Fail, Detail: "missing tables: " + strings.Join(missing, ", ")}
+	}
+	return Result{Check: "migrations", Status: Pass, Detail: fmt.Sprintf("%d tables present", len(tables))}
+}
+
+// checkFaults parses FAULT_INJECTION, so a typo stops the start instead of
+// silently injecting nothing. Production is covered by checkConfig.
+func checkFaults(cfg config.Config) Result {
+	if strings.TrimSpace(cfg.FaultInjection) == "" {
+		return Result{Check: "fault_injection", Status: Skip, Detail: "FAULT_INJECTION is off"}
+	}
+	if cfg.IsProduction() {
+		return Result{Check: "fault_injection", Status: Skip, Detail: "disabled in production"}
+	}
+	rules, err := middleware.ParseFaultRules(cfg.FaultInjection)
+	if err != nil {
+		return Result{Check: "fault_injection", Status: Fail, Detail: fmt.Sprintf("invalid FAULT_INJECTION: %v", err)}
+	}
+	return Result{Check: "fault_injection", Status: Pass, Detail: fmt.Sprintf("%d rules active", len(rules))}
+}
+
+// checkQueries prepares every named query when VALIDATE_QUERIES_ON_START
+// is set, listing each one the schema rejects
+func checkQueries(ctx context.Context, cfg config.Config, db DB, timeout time.Duration) Result {
+	if !cfg.ValidateQueriesOnStart {
+		return Result{Check: "queries", Status: Skip, Detail: "VALIDATE_QUERIES_ON_START is off"}
+	}
+	if db == nil {
+		return Result{Check: "queries", Status: Skip, Detail: "no database"}
+	}
+
+	prepareCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	all := queries.All()
+	err := queries.Validate(prepareCtx, db, all)
+	if err == nil {
+		return Result{Check: "queries", Status: Pass, Detail: fmt.Sprintf("%d queries prepared", len(all))}
+	}
+	res := Result{Check: "queries", Status: Fail, Detail: "queries rejected by the schema"}
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		res.Problems = append(res.Problems, e.Error())
+	}
+	return res
+}
+
+var createTable = regexp.MustCompile(`(?i)^\s*CREATE TABLE (?:IF NOT EXISTS )?([a-z_][a-z0-9_.]*)`)
+
+// migrationTables lists the tables created by the migrations in dir
+func migrationTables(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var tables []string
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration: %w", err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if m := createTable.FindStringSubmatch(scanner.Text()); m != nil {
+				tables = append(tables, strings.ToLower(m[1]))
+			}
+		}
+		err = scanner.Err()
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", filepath.Base(name), err)
+		}
+	}
+	return tables, nil
+}
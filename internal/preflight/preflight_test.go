@@ -0,0 +1,201 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDB answers health checks, reports which tables exist and rejects
+// statements naming a column in missingColumns
+type fakeDB struct {
+	healthErr      error
+	tables         map[string]bool
+	missingColumns []string
+}
+
+type boolRow bool
+
+func (r boolRow) Scan(dest ...any) error {
+	*dest[0].(*bool) = bool(r)
+	return nil
+}
+
+func (db *fakeDB) Health(ctx context.Context) error { return db.healthErr }
+
+func (db *fakeDB) Prepare(ctx context.Context, sql string) error {
+	for _, col := range db.missingColumns {
+		if strings.Contains(sql, col) {
+			return fmt.Errorf(`column "%s" does not exist`, col)
+		}
+	}
+	return nil
+}
+
+func (db *fakeDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return boolRow(db.tables[args[0].(string)])
+}
+
+func (db *fakeDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errors.New("not implemented")
+}
+
+func validConfig() config.Config {
+	return config.Config{
+		Port: 8080, Env: "production", LogLevel: "info", LogMaxFieldLength: 1, BaggageMaxBytes: 1, UsernameReservation: time.Hour,
+		URL:          "https://api.example.com",
+		DatabaseURL:  "postgres://localhost/app",
+		ReadTimeout:  time.Second,
+		WriteTimeout: time.Second,
+		JWTSecretKey: strings.Repeat("k", MinSecretLength), JWTIssuer: "test",
+		TokenTTLs:          config.DefaultTokenTTLs(),
+		OutboxPollInterval: time.Second, OutboxMaxAttempts: 1,
+		UserPurgeAfter: time.Hour, UserPurgeInterval: time.Hour, UserPurgeMaxPerRun: 1,
+		RequestTimeout:  time.Second,
+		SignupRateLimit: 1, SignupRateWindow: time.Minute,
+		MagicLinkRateLimit: 1, MagicLinkRateWindow: time.Minute,
+		ShutdownHookTimeout: time.Second, PasswordHashQueueTimeout: time.Second, TokenHistoryRetention: time.Hour,
+		HealthCheckTimeout: time.Second, HealthReadyBudget: time.Second,
+		UserImportBatchSize: 1, BodyLimit: 1,
+		RateLimitWindow: time.Minute, RateLimitAnonymous: 1, RateLimitAuthenticated: 1,
+	}
+}
+
+func writeMigrations(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1_users.sql"), []byte("CREATE TABLE users (\n  id uuid\n);\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2_outbox.sql"), []byte("create table if not exists events_outbox (id int);\n"), 0o644))
+	return dir
+}
+
+func result(r Report, check string) Result {
+	for _, res := range r.Results {
+		if res.Check == check {
+			return res
+		}
+	}
+	return Result{}
+}
+
+func TestRun_AllPass(t *testing.T) {
+	cfg := validConfig()
+	cfg.Env = "staging"
+	cfg.LogFile = filepath.Join(t.TempDir(), "app.log")
+	cfg.ValidateQueriesOnStart = true
+	cfg.FaultInjection = "latency:200ms:0.1"
+	db := &fakeDB{tables: map[string]bool{"users": true, "events_outbox": true}}
+
+	report := Run(context.Background(), cfg, db, nil, Options{MigrationsDir: writeMigrations(t)})
+
+	require.NoError(t, report.Err())
+	assert.True(t, report.OK())
+	for _, res := range report.Results {
+		assert.Equal(t, Pass, res.Status, res.Check)
+	}
+}
+
+func TestRun_ReportsEveryProblem(t *testing.T) {
+	cfg := validConfig()
+	cfg.Port = 0
+	cfg.RateLimitWindow = 0
+	cfg.JWTSecretKey = "short"
+	cfg.LogFile = filepath.Join(t.TempDir(), "missing", "app.log")
+	db := &fakeDB{tables: map[string]bool{"users": true}}
+
+	report := Run(context.Background(), cfg, db, nil, Options{MigrationsDir: writeMigrations(t)})
+
+	err := report.Err()
+	require.Error(t, err)
+	assert.False(t, report.OK())
+	for _, want := range []string{"PORT", "RATE_LIMIT_WINDOW", "JWT_SECRET_KEY", "LOG_FILE", "events_outbox"} {
+		assert.Contains(t, err.Error(), want)
+	}
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 5)
+	assert.Equal(t, Pass, result(report, "database").Status)
+}
+
+func TestRun_DatabaseUnreachable(t *testing.T) {
+	cfg := validConfig()
+
+	report := Run(context.Background(), cfg, nil, errors.New("connection refused"), Options{})
+	assert.Equal(t, Fail, result(report, "database").Status)
+	assert.Contains(t, report.Err().Error(), "connection refused")
+	assert.Equal(t, Skip, result(report, "migrations").Status)
+
+	report = Run(context.Background(), cfg, &fakeDB{healthErr: errors.New("timeout")}, nil, Options{MigrationsDir: t.TempDir() + "/none"})
+	assert.Equal(t, Fail, result(report, "database").Status)
+	assert.Equal(t, Skip, result(report, "migrations").Status)
+}
+
+func TestCheckSecret_WarnsOutsideProduction(t *testing.T) {
+	cfg := validConfig()
+	cfg.JWTSecretKey = "your-secret-key-change-in-production"
+	assert.Equal(t, Fail, checkSecret(cfg).Status)
+
+	cfg.Env = "development"
+	assert.Equal(t, Warn, checkSecret(cfg).Status)
+}
+
+func TestRun_MemoryStoreSkipsDatabase(t *testing.T) {
+	cfg := validConfig()
+	cfg.Env = "development"
+	cfg.DatabaseURL = ""
+
+	report := Run(context.Background(), cfg, nil, nil, Options{})
+	assert.Equal(t, Skip, result(report, "database").Status)
+	assert.True(t, report.OK(), "a weak development secret only warns")
+}
+
+func TestCheckFaults(t *testing.T) {
+	cfg := validConfig()
+	assert.Equal(t, Skip, checkFaults(cfg).Status)
+
+	cfg.FaultInjection = "error:503:0.1"
+	assert.Equal(t, Skip, checkFaults(cfg).Status, "checkConfig rejects it in production")
+
+	cfg.Env = "staging"
+	assert.Equal(t, Pass, checkFaults(cfg).Status)
+
+	cfg.FaultInjection = "error:503:lots"
+	res := checkFaults(cfg)
+	assert.Equal(t, Fail, res.Status)
+	assert.Contains(t, res.Detail, "FAULT_INJECTION")
+}
+
+func TestRun_ValidatesQueries(t *testing.T) {
+	cfg := validConfig()
+	db := &fakeDB{missingColumns: []string{"email_verified"}}
+	opts := Options{MigrationsDir: t.TempDir()}
+
+	report := Run(context.Background(), cfg, db, nil, opts)
+	assert.Equal(t, Skip, result(report, "queries").Status, "off by default")
+
+	cfg.ValidateQueriesOnStart = true
+	report = Run(context.Background(), cfg, db, nil, opts)
+	res := result(report, "queries")
+	require.Equal(t, Fail, res.Status)
+	assert.Contains(t, report.Err().Error(), "users.find_by_email")
+	for _, p := range res.Problems {
+		assert.Contains(t, p, `column "email_verified" does not exist`)
+	}
+
+	db.missingColumns = nil
+	report = Run(context.Background(), cfg, db, nil, opts)
+	assert.Equal(t, Pass, result(report, "queries").Status)
+}
@@ -0,0 +1,231 @@
+// Package queries holds the SQL the repositories run as named queries,
+// loaded from the .sql files in sql/. Keeping statements out of Go code
+// lets them be reviewed, EXPLAINed and checked against the live schema on
+// their own.
+//
+// Each file holds queries introduced by a "-- name: <name>" line; the query
+// runs until the next name line. Other comment lines document the queries
+// and are dropped, so they can't appear inside a statement. A query is
+// registered as "<file>.<name>", e.g. "users.find_by_email".
+package queries
+
+import (
+	"bufio"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"dvith.com/go-service-api/pkg/database"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// Query is a named SQL statement
+type Query struct {
+	Name string
+	SQL  string
+}
+
+// String returns the statement
+func (q Query) String() string { return q.SQL }
+
+var registry = mustLoad(files)
+
+// Users
+var (
+	UserInsert            = get("users.insert")
+	UserFindByEmail       = get("users.find_by_email")
+	UserTaken             = get("users.taken")
+	UserFindByID          = get("users.find_by_id")
+	UserUpdateProfile     = get("users.update_profile")
+	UserSetPassword       = get("users.set_password")
+	UserRehashPassword    = get("users.rehash_password")
+	UserSetRole           = get("users.set_role")
+	UserMarkEmailVerified = get("users.mark_email_verified")
+	UserCount             = get("users.count")
+	UserList              = get("users.list")
+	UserCountSearch       = get("users.count_search")
+	UserSearch            = get("users.search")
+	UserSearchRanked      = get("users.search_ranked")
+	UserSoftDelete        = get("users.soft_delete")
+	UserRestore           = get("users.restore")
+)
+
+// Username history
+var (
+	UsernameLockName     = get("username_history.lock_name")
+	UsernameLockUser     = get("username_history.lock_user")
+	UsernameCountSince   = get("username_history.count_since")
+	UsernameReserved     = get("username_history.reserved")
+	UsernameRelease      = get("username_history.release")
+	UsernameInsert       = get("username_history.insert")
+	UsernameDeleteByUser = get("username_history.delete_by_user")
+)
+
+// Sessions
+var (
+	SessionInsert       = get("sessions.insert")
+	SessionListRecent   = get("sessions.list_recent")
+	SessionRevoke       = get("sessions.revoke")
+	SessionRevokeByUser = get("sessions.revoke_by_user")
+	SessionStreamByUser = get("sessions.stream_by_user")
+	SessionDeleteByUser = get("sessions.delete_by_user")
+	SessionLockUser     = get("sessions.lock_user")
+	SessionListActive   = get("sessions.list_active")
+	SessionEvict        = get("sessions.evict")
+)
+
+// Audit events and the outbox
+var (
+	AuditInsert       = get("audit.insert")
+	AuditStreamByUser = get("audit.stream_by_user")
+	OutboxEnqueue     = get("outbox.enqueue")
+)
+
+// All returns every registered query, sorted by name
+func All() []Query {
+	all := make([]Query, 0, len(registry))
+	for _, q := range registry {
+		all = append(all, q)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Preparer parses and plans a statement without running it.
+// database.DBPool implements it.
+type Preparer interface {
+	Prepare(ctx context.Context, sql string) error
+}
+
+// Validate prepares each query against the live schema and reports every
+// one that fails, e.g. because it names a column that doesn't exist
+func Validate(ctx context.Context, p Preparer, qs []Query) error {
+	var problems []error
+	for _, q := range qs {
+		if err := p.Prepare(ctx, q.SQL); err != nil {
+			problems = append(problems, fmt.Errorf("query %s: %w", q.Name, err))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// Explain returns the plan Postgres picks for q without running it, one
+// line per plan node. Parameters stay unbound, which EXPLAIN (GENERIC_PLAN)
+// allows since PostgreSQL 16.
+func Explain(ctx context.Context, db database.Querier, q Query) (string, error) {
+	rows, err := db.Query(ctx, "EXPLAIN (GENERIC_PLAN) "+q.SQL)
+	if err != nil {
+		return "", fmt.Errorf("failed to explain %s: %w", q.Name, err)
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", fmt.Errorf("failed to explain %s: %w", q.Name, err)
+		}
+		plan = append(plan, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to explain %s: %w", q.Name, err)
+	}
+	return strings.Join(plan, "\n"), nil
+}
+
+func get(name string) Query {
+	q, ok := registry[name]
+	if !ok {
+		panic("queries: no query named " + name)
+	}
+	return q
+}
+
+func mustLoad(fsys fs.FS) map[string]Query {
+	qs, err := load(fsys)
+	if err != nil {
+		panic("queries: " + err.Error())
+	}
+	return qs
+}
+
+// load parses every .sql file under sql/ in fsys
+func load(fsys fs.FS) (map[string]Query, error) {
+	names, err := fs.Glob(fsys, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	qs := make(map[string]Query)
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parse(strings.TrimSuffix(path.Base(name), ".sql"), string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, q := range parsed {
+			if _, dup := qs[q.Name]; dup {
+				return nil, fmt.Errorf("%s: query %s is defined twice", name, q.Name)
+			}
+			qs[q.Name] = q
+		}
+	}
+	return qs, nil
+}
+
+// parse splits one file into its named queries
+func parse(prefix, src string) ([]Query, error) {
+	var (
+		qs   []Query
+		name string
+		body []string
+	)
+	flush := func() error {
+		if name == "" {
+			return nil
+		}
+		sql := strings.TrimSuffix(strings.TrimSpace(strings.Join(body, "\n")), ";")
+		if sql == "" {
+			return fmt.Errorf("query %s is empty", name)
+		}
+		qs = append(qs, Query{Name: name, SQL: sql})
+		return nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if n, ok := strings.CutPrefix(trimmed, "-- name:"); ok {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			name, body = prefix+"."+strings.TrimSpace(n), nil
+			continue
+		}
+		if strings.HasPrefix(trimmed, "--") || trimmed == "" {
+			continue
+		}
+		if name == "" {
+			return nil, errors.New("statement before the first -- name: line")
+		}
+		body = append(body, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return qs, nil
+}
@@ -0,0 +1,43 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"dvith.com/go-service-api/internal/testdb"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) { testdb.Main(m) }
+
+// explainAll EXPLAINs every registered query and logs its plan
+func explainAll(t *testing.T, db database.Querier) {
+	t.Helper()
+	for _, q := range All() {
+		plan, err := Explain(context.Background(), db, q)
+		if assert.NoError(t, err, q.Name) {
+			t.Logf("%s:\n%s", q.Name, plan)
+		}
+	}
+}
+
+func TestQueries_PrepareAgainstMigrations(t *testing.T) {
+	db := testdb.Open(t)
+
+	require.NoError(t, Validate(context.Background(), db, All()))
+	explainAll(t, db)
+}
+
+func TestValidate_CatchesTypoedColumn(t *testing.T) {
+	db := testdb.Open(t)
+
+	typo := Query{Name: "users.find_by_email", SQL: strings.Replace(UserFindByEmail.SQL, "email_verified", "email_verifed", 1)}
+	err := Validate(context.Background(), db, append(All(), typo))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("query %s", typo.Name))
+	assert.Contains(t, err.Error(), "email_verifed")
+}
@@ -0,0 +1,94 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_LoadsEveryFile(t *testing.T) {
+	all := All()
+	require.NotEmpty(t, all)
+
+	names := make(map[string]bool)
+	for _, q := range all {
+		names[q.Name] = true
+		assert.NotContains(t, q.SQL, "--", "%s keeps no comments", q.Name)
+		assert.False(t, strings.HasSuffix(q.SQL, ";"), "%s drops the trailing semicolon", q.Name)
+	}
+	for _, q := range []Query{UserInsert, UserList, SessionRevoke, AuditInsert, OutboxEnqueue} {
+		assert.True(t, names[q.Name], q.Name)
+	}
+	assert.True(t, strings.HasPrefix(UserFindByEmail.SQL, "SELECT id, tenant_id, email,"))
+}
+
+func TestParse(t *testing.T) {
+	src := `-- Shared notes, dropped
+-- name: first
+SELECT 1;
+
+-- Belongs to second, dropped too
+-- name: second
+UPDATE t
+SET a = $1
+WHERE id = $2;
+`
+	qs, err := parse("things", src)
+	require.NoError(t, err)
+	assert.Equal(t, []Query{
+		{Name: "things.first", SQL: "SELECT 1"},
+		{Name: "things.second", SQL: "UPDATE t\nSET a = $1\nWHERE id = $2"},
+	}, qs)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"statement without a name", fstest.MapFS{"sql/a.sql": {Data: []byte("SELECT 1;\n")}}, "before the first"},
+		{"empty query", fstest.MapFS{"sql/a.sql": {Data: []byte("-- name: x\n-- name: y\nSELECT 1;\n")}}, "a.x is empty"},
+		{"duplicate", fstest.MapFS{"sql/a.sql": {Data: []byte("-- name: x\nSELECT 1;\n-- name: x\nSELECT 2;\n")}}, "defined twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(tt.files)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+// rejectingPreparer fails statements containing any of its words
+type rejectingPreparer []string
+
+func (p rejectingPreparer) Prepare(ctx context.Context, sql string) error {
+	for _, word := range p {
+		if strings.Contains(sql, word) {
+			return errors.New("rejected " + word)
+		}
+	}
+	return nil
+}
+
+func TestValidate_ReportsEveryFailure(t *testing.T) {
+	qs := []Query{
+		{Name: "a.ok", SQL: "SELECT 1"},
+		{Name: "a.bad", SQL: "SELECT emial FROM users"},
+		{Name: "a.worse", SQL: "SELECT * FROM userz"},
+	}
+
+	err := Validate(context.Background(), rejectingPreparer{"emial", "userz"}, qs)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "query a.bad: rejected emial")
+	assert.Contains(t, err.Error(), "query a.worse: rejected userz")
+	assert.NotContains(t, err.Error(), "a.ok")
+
+	assert.NoError(t, Validate(context.Background(), rejectingPreparer{}, qs))
+}
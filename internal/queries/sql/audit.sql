@@ -0,0 +1,9 @@
+-- name: insert
+INSERT INTO audit_events (id, tenant_id, user_id, action, ip, user_agent, metadata, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: stream_by_user
+SELECT id, tenant_id, user_id, action, ip, user_agent, metadata, created_at
+FROM audit_events
+WHERE user_id = $1
+ORDER BY created_at, id;
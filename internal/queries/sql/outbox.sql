@@ -0,0 +1,3 @@
+-- name: enqueue
+INSERT INTO events_outbox (id, event_type, aggregate_id, payload, status, attempts, next_attempt_at, created_at)
+VALUES ($1, $2, $3, $4, $5, 0, $6, $6);
@@ -0,0 +1,69 @@
+-- name: insert
+INSERT INTO sessions (id, tenant_id, user_id, ip, user_agent, browser, os, device_type, fingerprint, created_at, last_used_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);
+
+-- name: list_recent
+SELECT id, tenant_id, user_id, COALESCE(ip, '') AS ip, COALESCE(user_agent, '') AS user_agent, browser, os, device_type, fingerprint, created_at, last_used_at, revoked_at
+FROM sessions
+WHERE tenant_id = $1 AND user_id = $2
+ORDER BY last_used_at DESC, id
+LIMIT $3;
+
+-- Marks the session and its refresh tokens revoked and notifies $5 with
+-- its ID; returns no row when the session doesn't exist
+-- name: revoke
+WITH revoked AS (
+	UPDATE sessions SET revoked_at = COALESCE(revoked_at, $4)
+	WHERE id = $1 AND user_id = $2 AND tenant_id = $3
+	RETURNING id
+), tokens AS (
+	UPDATE refresh_tokens SET revoked_at = $4
+	WHERE session_id IN (SELECT id FROM revoked) AND revoked_at IS NULL
+)
+SELECT pg_notify($5, id::text) FROM revoked;
+
+-- Revokes every active session of the user and all of the user's refresh
+-- tokens, notifying $4 with each session ID
+-- name: revoke_by_user
+WITH revoked AS (
+	UPDATE sessions SET revoked_at = $3
+	WHERE tenant_id = $1 AND user_id = $2 AND revoked_at IS NULL
+	RETURNING id
+), tokens AS (
+	UPDATE refresh_tokens SET revoked_at = $3
+	WHERE user_id = $2 AND revoked_at IS NULL
+)
+SELECT id, pg_notify($4, id::text) FROM revoked;
+
+-- name: stream_by_user
+SELECT id, tenant_id, user_id, COALESCE(ip, '') AS ip, COALESCE(user_agent, '') AS user_agent, browser, os, device_type, fingerprint, created_at, last_used_at, revoked_at
+FROM sessions
+WHERE tenant_id = $1 AND user_id = $2
+ORDER BY created_at, id;
+
+-- name: delete_by_user
+DELETE FROM sessions WHERE user_id = $1;
+
+-- Serializes session limit checks of one user until the transaction ends
+-- name: lock_user
+SELECT pg_advisory_xact_lock(hashtextextended($1::text, 0));
+
+-- Active sessions least recently used first, the order they are evicted in
+-- name: list_active
+SELECT id
+FROM sessions
+WHERE tenant_id = $1 AND user_id = $2 AND revoked_at IS NULL
+ORDER BY last_used_at, created_at, id;
+
+-- Revokes the sessions in $1 and their refresh tokens, notifying $3 with
+-- each session ID
+-- name: evict
+WITH evicted AS (
+	UPDATE sessions SET revoked_at = $2
+	WHERE id = ANY($1) AND revoked_at IS NULL
+	RETURNING id
+), tokens AS (
+	UPDATE refresh_tokens SET revoked_at = $2
+	WHERE session_id = ANY($1) AND revoked_at IS NULL
+)
+SELECT pg_notify($3, id::text) FROM evicted;
@@ -0,0 +1,35 @@
+-- Serializes the renames into and out of username $2 in tenant $1 until
+-- the transaction ends
+-- name: lock_name
+SELECT pg_advisory_xact_lock(hashtextextended('username:' || $1::text || ':' || $2::text, 0));
+
+-- Locks the user being renamed and returns their current username
+-- name: lock_user
+SELECT username
+FROM users
+WHERE deleted_at IS NULL AND tenant_id = $1 AND id = $2
+FOR UPDATE;
+
+-- name: count_since
+SELECT COUNT(*)
+FROM username_history
+WHERE user_id = $1 AND changed_at > $2;
+
+-- Whether username $2 is reserved at $4 for a user other than $3
+-- name: reserved
+SELECT EXISTS (
+	SELECT 1 FROM username_history
+	WHERE tenant_id = $1 AND username = $2 AND user_id <> $3 AND reserved_until > $4
+);
+
+-- Ends the reservations of username $2 held by user $1, once they reclaim it
+-- name: release
+UPDATE username_history SET reserved_until = $3
+WHERE user_id = $1 AND username = $2 AND reserved_until > $3;
+
+-- name: insert
+INSERT INTO username_history (id, tenant_id, user_id, username, changed_at, reserved_until)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: delete_by_user
+DELETE FROM username_history WHERE user_id = $1;
@@ -0,0 +1,118 @@
+-- Every users query returning rows selects all the columns of model.User,
+-- which are scanned by name:
+-- id, tenant_id, email, password, full_name, username, role, is_active,
+-- email_verified, verified_at, created_at, updated_at, deleted_at,
+-- password_changed_at, must_reset_password, token_version
+
+-- name: insert
+INSERT INTO users (id, tenant_id, email, password, full_name, username, is_active, email_verified, verified_at, created_at, updated_at, password_changed_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING id, tenant_id, email, password, full_name, username, role, is_active, email_verified, verified_at, created_at, updated_at, deleted_at, password_changed_at, must_reset_password, token_version;
+
+-- The lookups and listings end in their WHERE clause so the caller's
+-- model.Scope, and for listings the pagination filters, ordering and
+-- limit, can be appended
+-- name: find_by_email
+SELECT id, tenant_id, email, password, full_name, username, role, is_active, email_verified, verified_at, created_at, updated_at, deleted_at, password_changed_at, must_reset_password, token_version
+FROM users
+WHERE tenant_id = $1 AND email = $2;
+
+-- Soft-deleted users keep holding their email and username, as in the
+-- unique constraints, and usernames renamed away from stay reserved until
+-- $4. Email and username are answered separately, in one round trip.
+-- name: taken
+SELECT
+	EXISTS (SELECT 1 FROM users WHERE tenant_id = $1 AND email = $2) AS email_taken,
+	EXISTS (SELECT 1 FROM users WHERE tenant_id = $1 AND username = $3)
+		OR EXISTS (SELECT 1 FROM username_history WHERE tenant_id = $1 AND username = $3 AND reserved_until > $4) AS username_taken;
+
+-- name: find_by_id
+SELECT id, tenant_id, email, password, full_name, username, role, is_active, email_verified, verified_at, created_at, updated_at, deleted_at, password_changed_at, must_reset_password, token_version
+FROM users
+WHERE tenant_id = $1 AND id = $2;
+
+-- name: update_profile
+UPDATE users
+SET full_name = COALESCE($3, full_name),
+	username = COALESCE($4, username),
+	updated_at = $5
+WHERE deleted_at IS NULL AND tenant_id = $1 AND id = $2
+RETURNING id, tenant_id, email, password, full_name, username, role, is_active, email_verified, verified_at, created_at, updated_at, deleted_at, password_changed_at, must_reset_password, token_version;
+
+-- Invalidates every outstanding password reset token of the user in the
+-- same statement, and satisfies a required reset
+-- name: set_password
+WITH invalidated_resets AS (
+	DELETE FROM password_reset_tokens WHERE tenant_id = $1 AND user_id = $2
+)
+UPDATE users
+SET password = $3, password_changed_at = $4, must_reset_password = false, updated_at = $4
+WHERE deleted_at IS NULL AND tenant_id = $1 AND id = $2;
+
+-- Swaps a hash for a stronger one of the same password, so
+-- password_changed_at stays. Matching the old hash keeps a concurrent
+-- password change from being overwritten.
+-- name: rehash_password
+UPDATE users
+SET password = $4, updated_at = $5
+WHERE deleted_at IS NULL AND tenant_id = $1 AND id = $2 AND password = $3;
+
+-- name: set_role
+UPDATE users
+SET role = $3, updated_at = $4
+WHERE deleted_at IS NULL AND tenant_id = $1 AND id = $2;
+
+-- name: mark_email_verified
+UPDATE users
+SET email_verified = true, verified_at = COALESCE(verified_at, $3), updated_at = $3
+WHERE deleted_at IS NULL AND tenant_id = $1 AND id = $2;
+
+-- name: count
+SELECT COUNT(*) FROM users WHERE tenant_id = $1;
+
+-- name: list
+SELECT id, tenant_id, email, password, full_name, username, role, is_active, email_verified, verified_at, created_at, updated_at, deleted_at, password_changed_at, must_reset_password, token_version
+FROM users
+WHERE tenant_id = $1;
+
+-- The searches match $2, an escaped ILIKE pattern, anywhere in the email
+-- or username, and select the users' columns plus search_rank so results
+-- can be paged by rank. Prefix matches of $3 rank first.
+-- name: count_search
+SELECT COUNT(*) FROM users
+WHERE tenant_id = $1 AND (email ILIKE $2 ESCAPE '\' OR username ILIKE $2 ESCAPE '\');
+
+-- name: search
+SELECT id, tenant_id, email, password, full_name, username, role, is_active, email_verified, verified_at, created_at, updated_at, deleted_at, password_changed_at, must_reset_password, token_version, search_rank
+FROM (
+  SELECT id, tenant_id, email, password, full_name, username, role, is_active, email_verified, verified_at, created_at, updated_at, deleted_at, password_changed_at, must_reset_password, token_version,
+    CASE WHEN email ILIKE $3 ESCAPE '\' OR username ILIKE $3 ESCAPE '\' THEN 1 ELSE 0 END::float8 AS search_rank
+  FROM users
+  WHERE tenant_id = $1 AND (email ILIKE $2 ESCAPE '\' OR username ILIKE $2 ESCAPE '\')
+) AS matches
+WHERE true;
+
+-- Within prefix and substring matches users rank by their trigram
+-- similarity to the term $4. Needs pg_trgm.
+-- name: search_ranked
+SELECT id, tenant_id, email, password, full_name, username, role, is_active, email_verified, verified_at, created_at, updated_at, deleted_at, password_changed_at, must_reset_password, token_version, search_rank
+FROM (
+  SELECT id, tenant_id, email, password, full_name, username, role, is_active, email_verified, verified_at, created_at, updated_at, deleted_at, password_changed_at, must_reset_password, token_version,
+    (CASE WHEN email ILIKE $3 ESCAPE '\' OR username ILIKE $3 ESCAPE '\' THEN 1 ELSE 0 END
+      + GREATEST(similarity(email, $4), similarity(username, $4)))::float8 AS search_rank
+  FROM users
+  WHERE tenant_id = $1 AND (email ILIKE $2 ESCAPE '\' OR username ILIKE $2 ESCAPE '\')
+) AS matches
+WHERE true;
+
+-- Soft deletion keeps is_active in step with deleted_at
+-- name: soft_delete
+UPDATE users
+SET is_active = false, deleted_at = $3, updated_at = $3
+WHERE deleted_at IS NULL AND tenant_id = $1 AND id = $2;
+
+-- Purged users have lost their personal data and stay deleted
+-- name: restore
+UPDATE users
+SET is_active = true, deleted_at = NULL, updated_at = $3
+WHERE deleted_at IS NOT NULL AND purged_at IS NULL AND tenant_id = $1 AND id = $2;
@@ -0,0 +1,85 @@
+// Package requestmeta captures who made a request: the client IP, the user
+// agent, the request ID and the authenticated user. They are read once per
+// request, so sessions, audit events, logs and rate limits all record the
+// same values.
+package requestmeta
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"dvith.com/go-service-api/pkg/httpclient"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// MaxUserAgentBytes caps the user agent kept for a request. Real browsers
+// send a few hundred bytes; anything longer is only stored and logged.
+const MaxUserAgentBytes = 512
+
+// contextKey holds the request's *RequestMeta in Locals
+const contextKey = "request_meta"
+
+// RequestMeta describes the client of a request
+type RequestMeta struct {
+	// IP is the client address. Behind a load balancer speaking the PROXY
+	// protocol, or with Fiber's TrustProxy set, it is the client's rather
+	// than the balancer's.
+	IP string
+	// UserAgent is the User-Agent header, cut to MaxUserAgentBytes
+	UserAgent string
+	// RequestID is the ID assigned by middleware.RequestID, or ""
+	RequestID string
+	// UserID is the authenticated user, or uuid.Nil before authentication
+	// and on anonymous requests
+	UserID uuid.UUID
+}
+
+// Middleware captures the request's metadata. It must run after
+// middleware.RequestID so the ID is known.
+func Middleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		capture(c)
+		return c.Next()
+	}
+}
+
+// FromCtx returns the metadata of the request. Without Middleware it is
+// captured on first use.
+func FromCtx(c fiber.Ctx) RequestMeta {
+	return *capture(c)
+}
+
+// SetUserID records the authenticated user of the request
+func SetUserID(c fiber.Ctx, userID uuid.UUID) {
+	capture(c).UserID = userID
+}
+
+func capture(c fiber.Ctx) *RequestMeta {
+	if m, ok := c.Locals(contextKey).(*RequestMeta); ok {
+		return m
+	}
+	// Header values point into the request buffer Fiber reuses, and the
+	// metadata can outlive the handler in background work such as login
+	// notifications, so they are copied
+	m := &RequestMeta{
+		IP:        strings.Clone(c.IP()),
+		UserAgent: TruncateUserAgent(c.Get(fiber.HeaderUserAgent)),
+		RequestID: httpclient.RequestID(c.Context()),
+	}
+	c.Locals(contextKey, m)
+	return m
+}
+
+// TruncateUserAgent returns a copy of ua cut to at most MaxUserAgentBytes,
+// without splitting a UTF-8 sequence
+func TruncateUserAgent(ua string) string {
+	if len(ua) <= MaxUserAgentBytes {
+		return strings.Clone(ua)
+	}
+	cut := MaxUserAgentBytes
+	for cut > 0 && !utf8.RuneStart(ua[cut]) {
+		cut--
+	}
+	return strings.Clone(ua[:cut])
+}
@@ -0,0 +1,134 @@
+package requestmeta
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"dvith.com/go-service-api/pkg/httpclient"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type metaBody struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	RequestID string `json:"request_id"`
+	UserID    string `json:"user_id"`
+}
+
+func metaApp(cfg ...fiber.Config) *fiber.App {
+	app := fiber.New(cfg...)
+	app.Use(func(c fiber.Ctx) error {
+		c.SetContext(httpclient.WithRequestID(c.Context(), "req-1"))
+		return c.Next()
+	})
+	app.Use(Middleware())
+	app.Get("/", func(c fiber.Ctx) error {
+		if id := c.Query("user"); id != "" {
+			SetUserID(c, uuid.MustParse(id))
+		}
+		m := FromCtx(c)
+		body := metaBody{IP: m.IP, UserAgent: m.UserAgent, RequestID: m.RequestID}
+		if m.UserID != uuid.Nil {
+			body.UserID = m.UserID.String()
+		}
+		return c.JSON(body)
+	})
+	return app
+}
+
+func getMeta(t *testing.T, app *fiber.App, req *http.Request) metaBody {
+	t.Helper()
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var body metaBody
+	require.NoError(t, json.Unmarshal(raw, &body), string(raw))
+	return body
+}
+
+func TestFromCtx(t *testing.T) {
+	userID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/?user="+userID.String(), nil)
+	req.Header.Set(fiber.HeaderUserAgent, "Mozilla/5.0 (X11; Linux x86_64)")
+
+	body := getMeta(t, metaApp(), req)
+	assert.Equal(t, "0.0.0.0", body.IP)
+	assert.Equal(t, "Mozilla/5.0 (X11; Linux x86_64)", body.UserAgent)
+	assert.Equal(t, "req-1", body.RequestID)
+	assert.Equal(t, userID.String(), body.UserID)
+}
+
+func TestFromCtx_MissingHeaders(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Del(fiber.HeaderUserAgent)
+
+	body := getMeta(t, metaApp(), req)
+	assert.Empty(t, body.UserAgent)
+	assert.Empty(t, body.UserID, "anonymous requests have no user")
+	assert.NotEmpty(t, body.IP)
+}
+
+func TestFromCtx_OversizedUserAgent(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderUserAgent, strings.Repeat("a", 4*MaxUserAgentBytes))
+
+	body := getMeta(t, metaApp(), req)
+	assert.Len(t, body.UserAgent, MaxUserAgentBytes)
+}
+
+func TestTruncateUserAgent(t *testing.T) {
+	assert.Equal(t, "curl/8.0", TruncateUserAgent("curl/8.0"))
+
+	exact := strings.Repeat("a", MaxUserAgentBytes)
+	assert.Equal(t, exact, TruncateUserAgent(exact))
+
+	// A multi-byte character straddling the limit is dropped whole
+	ua := strings.Repeat("a", MaxUserAgentBytes-1) + "é" + "tail"
+	got := TruncateUserAgent(ua)
+	assert.Len(t, got, MaxUserAgentBytes-1)
+	assert.True(t, utf8.ValidString(got))
+}
+
+func TestFromCtx_TrustedProxy(t *testing.T) {
+	proxied := fiber.Config{
+		ProxyHeader:      fiber.HeaderXForwardedFor,
+		TrustProxy:       true,
+		TrustProxyConfig: fiber.TrustProxyConfig{Proxies: []string{"0.0.0.0"}},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderXForwardedFor, "203.0.113.7")
+	assert.Equal(t, "203.0.113.7", getMeta(t, metaApp(proxied), req).IP)
+
+	// The header is ignored from clients that aren't a trusted proxy
+	untrusted := proxied
+	untrusted.TrustProxyConfig = fiber.TrustProxyConfig{Proxies: []string{"10.0.0.1"}}
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderXForwardedFor, "203.0.113.7")
+	assert.Equal(t, "0.0.0.0", getMeta(t, metaApp(untrusted), req).IP)
+}
+
+func TestFromCtx_CapturedOnce(t *testing.T) {
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/", func(c fiber.Ctx) error {
+		// A header rewritten later doesn't change what was captured
+		c.Request().Header.SetUserAgent("rewritten")
+		return c.SendString(FromCtx(c).UserAgent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderUserAgent, "original")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	raw, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "original", string(raw))
+}
@@ -0,0 +1,101 @@
+package routemeta
+
+import (
+	"sync"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Visibility controls who a route is advertised to in discovery documents
+type Visibility int
+
+const (
+	// Public routes are advertised to every caller
+	Public Visibility = iota
+	// Authenticated routes are only advertised to callers with a valid token
+	Authenticated
+)
+
+// Route describes a named fiber route. Method and path are not stored here;
+// they are resolved from the app by name so metadata cannot drift from the
+// actual registration.
+type Route struct {
+	Name        string
+	Rel         string
+	Summary     string
+	RequireAuth bool
+	Visibility  Visibility
+}
+
+// ResolvedRoute is a Route joined with its registered method and path
+type ResolvedRoute struct {
+	Route
+	Method string
+	Path   string
+}
+
+// Stack is the middleware order applied to the routes under Prefix
+type Stack struct {
+	Prefix     string   `json:"prefix"`
+	Middleware []string `json:"middleware"`
+}
+
+// Registry holds metadata for named routes in registration order
+type Registry struct {
+	mu     sync.RWMutex
+	routes []Route
+	stacks []Stack
+}
+
+// NewRegistry creates an empty route metadata registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Describe records metadata for the route registered under route.Name
+func (r *Registry) Describe(route Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, route)
+}
+
+// DescribeStack records the middleware order applied under prefix
+func (r *Registry) DescribeStack(prefix string, middleware []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stacks = append(r.stacks, Stack{Prefix: prefix, Middleware: append([]string(nil), middleware...)})
+}
+
+// Stacks returns the recorded middleware stacks in registration order
+func (r *Registry) Stacks() []Stack {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Stack(nil), r.stacks...)
+}
+
+// Resolve joins the described routes with the app's registered routes.
+// Described names with no matching route are skipped.
+func (r *Registry) Resolve(app *fiber.App) []ResolvedRoute {
+	return r.ResolveAs(app, func(name string) string { return name })
+}
+
+// ResolveAs is Resolve looking each route up under routeName(name), such
+// as the name of its copy in another API version
+func (r *Registry) ResolveAs(app *fiber.App, routeName func(name string) string) []ResolvedRoute {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]ResolvedRoute, 0, len(r.routes))
+	for _, route := range r.routes {
+		registered := app.GetRoute(routeName(route.Name))
+		if registered.Path == "" {
+			continue
+		}
+		out = append(out, ResolvedRoute{
+			Route:  route,
+			Method: registered.Method,
+			Path:   registered.Path,
+		})
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package authmetrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_LabelSets(t *testing.T) {
+	r := metrics.NewRegistry()
+	m := New(r)
+
+	m.Signin(ReasonNone)
+	m.Signin(ReasonBadPassword)
+	m.Signup(ReasonLocked)
+	m.Refresh(ReasonExpiredToken)
+	m.TokenRejected(ReasonRevokedToken)
+	m.PasswordVerified(80 * time.Millisecond)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+	out := buf.String()
+
+	assert.Contains(t, out, `auth_signins_total{result="success",reason="none"} 1`)
+	assert.Contains(t, out, `auth_signins_total{result="failure",reason="bad_password"} 1`)
+	assert.Contains(t, out, `auth_signups_total{result="failure",reason="locked"} 1`)
+	assert.Contains(t, out, `auth_token_refreshes_total{result="failure",reason="expired_token"} 1`)
+	assert.Contains(t, out, `auth_access_token_rejections_total{reason="revoked_token"} 1`)
+	assert.Contains(t, out, `auth_password_verify_seconds_bucket{le="0.1"} 1`)
+	assert.Equal(t, uint64(1), m.PasswordVerifications())
+}
+
+func TestNew_SharesFamiliesPerRegistry(t *testing.T) {
+	r := metrics.NewRegistry()
+	New(r).Signin(ReasonNone)
+	assert.Equal(t, float64(1), New(r).SigninCount(ReasonNone))
+	assert.Equal(t, float64(0), New(metrics.NewRegistry()).SigninCount(ReasonNone))
+}
@@ -0,0 +1,61 @@
+package hashpassword
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// CalibrationTarget is how long a hash with calibrated parameters
+	// should take
+	CalibrationTarget = 250 * time.Millisecond
+	// MinArgon2Memory is the least memory, in KiB, calibration settles for
+	MinArgon2Memory = 8 * 1024
+	// MaxArgon2Time caps the iterations calibration picks
+	MaxArgon2Time = 10
+)
+
+// CalibrateArgon2 measures this host and returns the Argon2id parameters
+// a hash takes about target with, never using more than maxMemory KiB. It
+// keeps the memory at the ceiling, the costlier resource for an attacker,
+// and raises the iterations to reach target; on a host too slow for one
+// iteration there, it halves the memory down to MinArgon2Memory instead.
+// It also returns how long a hash with the result took.
+func CalibrateArgon2(target time.Duration, maxMemory uint32) (Argon2Params, time.Duration, error) {
+	if target <= 0 {
+		return Argon2Params{}, 0, fmt.Errorf("calibration target must be > 0")
+	}
+	if maxMemory < MinArgon2Memory {
+		return Argon2Params{}, 0, fmt.Errorf("memory ceiling of %d KiB is below the minimum of %d KiB", maxMemory, MinArgon2Memory)
+	}
+
+	params := DefaultArgon2Params
+	params.Time = 1
+	params.Memory = maxMemory
+
+	took := measure(params)
+	for took > target && params.Memory/2 >= MinArgon2Memory {
+		params.Memory /= 2
+		took = measure(params)
+	}
+
+	// Each iteration costs about the same, so the rest is extrapolated
+	if took < target {
+		n := uint32(target / max(took, time.Microsecond))
+		params.Time = min(max(n, 1), MaxArgon2Time)
+		if params.Time > 1 {
+			took = measure(params)
+		}
+	}
+	return params, took, nil
+}
+
+// measure times one Argon2id hash with params
+func measure(params Argon2Params) time.Duration {
+	salt := make([]byte, params.SaltLength)
+	start := time.Now()
+	argon2.IDKey([]byte("calibration password"), salt, params.Time, params.Memory, params.Parallelism, params.KeyLength)
+	return time.Since(start)
+}
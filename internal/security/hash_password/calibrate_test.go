@@ -0,0 +1,55 @@
+package hashpassword
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalibrateArgon2_RespectsMemoryCeiling(t *testing.T) {
+	ceiling := uint32(16 * 1024)
+
+	params, took, err := CalibrateArgon2(20*time.Millisecond, ceiling)
+	if err != nil {
+		t.Fatalf("CalibrateArgon2() failed: %v", err)
+	}
+	if params.Memory > ceiling || params.Memory < MinArgon2Memory {
+		t.Errorf("Memory = %d KiB, want between %d and %d", params.Memory, MinArgon2Memory, ceiling)
+	}
+	if params.Time < 1 || params.Time > MaxArgon2Time {
+		t.Errorf("Time = %d, want between 1 and %d", params.Time, MaxArgon2Time)
+	}
+	if took <= 0 {
+		t.Errorf("took = %v, want the duration of a calibrated hash", took)
+	}
+
+	// The parameters make working hashes
+	h := NewArgon2(params)
+	hash, err := h.Hash("calibratedPassword1")
+	if err != nil {
+		t.Fatalf("Hash() failed: %v", err)
+	}
+	if !h.Verify("calibratedPassword1", hash) || h.NeedsRehash(hash) {
+		t.Errorf("calibrated hasher doesn't verify its own hash")
+	}
+}
+
+func TestCalibrateArgon2_TooSlowLowersMemory(t *testing.T) {
+	// No host hashes in a nanosecond: memory drops to the floor, never
+	// below it, and one iteration is left
+	params, _, err := CalibrateArgon2(time.Nanosecond, 32*1024)
+	if err != nil {
+		t.Fatalf("CalibrateArgon2() failed: %v", err)
+	}
+	if params.Memory != MinArgon2Memory || params.Time != 1 {
+		t.Errorf("params = m=%d,t=%d, want m=%d,t=1", params.Memory, params.Time, MinArgon2Memory)
+	}
+}
+
+func TestCalibrateArgon2_InvalidArguments(t *testing.T) {
+	if _, _, err := CalibrateArgon2(time.Millisecond, MinArgon2Memory-1); err == nil {
+		t.Errorf("CalibrateArgon2() accepted a ceiling below MinArgon2Memory")
+	}
+	if _, _, err := CalibrateArgon2(0, 64*1024); err == nil {
+		t.Errorf("CalibrateArgon2() accepted a zero target")
+	}
+}
@@ -1,20 +1,156 @@
 package hashpassword
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"fmt"
+	"strings"
 
 	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword hashes a password using Argon2
-func HashPassword(password string) (string, error) {
+// Hasher hashes passwords and checks them against stored hashes
+type Hasher interface {
+	// Hash returns the encoded hash of password
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash
+	Verify(password, hash string) bool
+	// NeedsRehash reports whether hash was made by an older scheme or with
+	// other parameters than Hash uses, and should be replaced the next time
+	// the password is known
+	NeedsRehash(hash string) bool
+}
+
+// Argon2Params are the cost parameters of an Argon2id hash. Memory is in
+// KiB, as argon2.IDKey takes it.
+type Argon2Params struct {
+	Time        uint32
+	Memory      uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params are the parameters used unless calibrated:
+// time=3, memory=64MB, parallelism=4, tag length=32
+var DefaultArgon2Params = Argon2Params{
+	Time:        3,
+	Memory:      64 * 1024,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Default is the Hasher of the package functions, Argon2id with
+// DefaultArgon2Params
+var Default Hasher = NewArgon2(DefaultArgon2Params)
+
+var _ Hasher = (*Argon2)(nil)
+
+// Argon2 hashes with Argon2id into PHC strings, which carry a random salt
+// and the parameters, so hashes made with other parameters still verify.
+// It also verifies the hashes of older schemes: bcrypt hashes imported
+// from the legacy system, and the unsalted hex Argon2 hashes made before
+// PHC strings.
+type Argon2 struct {
+	params Argon2Params
+}
+
+// NewArgon2 creates an Argon2id hasher with params
+func NewArgon2(params Argon2Params) *Argon2 {
+	return &Argon2{params: params}
+}
+
+// Params returns the parameters new hashes are made with
+func (h *Argon2) Params() Argon2Params {
+	return h.params
+}
+
+// Hash implements Hasher
+func (h *Argon2) Hash(password string) (string, error) {
 	if password == "" {
 		return "", fmt.Errorf("password cannot be empty")
 	}
 
-	// Argon2 parameters
-	// time=3, memory=64MB, parallelism=4, tag length=32
-	hash := argon2.IDKey(
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Time, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.Memory, h.params.Time, h.params.Parallelism,
+		b64.EncodeToString(salt), b64.EncodeToString(key),
+	), nil
+}
+
+// Verify implements Hasher
+func (h *Argon2) Verify(password, hash string) bool {
+	if IsBcrypt(hash) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+	if !strings.HasPrefix(hash, "$") {
+		return verifyLegacy(password, hash)
+	}
+
+	params, salt, key, err := decodePHC(hash)
+	if err != nil {
+		return false
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// NeedsRehash implements Hasher
+func (h *Argon2) NeedsRehash(hash string) bool {
+	params, salt, _, err := decodePHC(hash)
+	if err != nil {
+		return true
+	}
+	params.SaltLength = uint32(len(salt))
+	return params != h.params
+}
+
+// b64 is the encoding of salts and keys in PHC strings
+var b64 = base64.RawStdEncoding
+
+// decodePHC parses an Argon2id PHC string
+func decodePHC(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2 parameters: %w", err)
+	}
+	if params.Time == 0 || params.Memory == 0 || params.Parallelism == 0 {
+		return params, nil, nil, fmt.Errorf("malformed argon2 parameters %q", parts[3])
+	}
+
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2 salt: %w", err)
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("malformed argon2 key")
+	}
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// verifyLegacy checks password against a hex Argon2 hash made before PHC
+// strings, with a fixed salt and DefaultArgon2Params
+func verifyLegacy(password, hash string) bool {
+	key := argon2.IDKey(
 		[]byte(password),
 		[]byte("salt"),
 		3,       // time cost: number of iterations
@@ -22,22 +158,34 @@ func HashPassword(password string) (string, error) {
 		4,       // parallelism
 		32,      // tag length
 	)
+	return subtle.ConstantTimeCompare([]byte(fmt.Sprintf("%x", key)), []byte(hash)) == 1
+}
 
-	// Convert hash to string format (base64-like representation)
-	hashStr := fmt.Sprintf("%x", hash)
-	return hashStr, nil
+// HashPassword hashes a password with Default
+func HashPassword(password string) (string, error) {
+	return Default.Hash(password)
+}
+
+// IsBcrypt reports whether hash is a well-formed bcrypt hash, as carried
+// over from the legacy system by user imports
+func IsBcrypt(hash string) bool {
+	if !strings.HasPrefix(hash, "$2") {
+		return false
+	}
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
 }
 
-// CheckPassword checks if a given password matches a hashed password
+// NeedsRehash reports whether hash was made by an older scheme than
+// HashPassword uses, such as the legacy bcrypt hashes, or with other
+// parameters, and should be replaced the next time the password is known
+func NeedsRehash(hash string) bool {
+	return Default.NeedsRehash(hash)
+}
+
+// CheckPassword checks if a given password matches a hashed password.
+// Bcrypt hashes imported from the legacy system are verified as such;
+// everything else is treated as Argon2.
 func CheckPassword(password, hashedPassword string) bool {
-	hash := argon2.IDKey(
-		[]byte(password),
-		[]byte("salt"),
-		3,       // time cost: number of iterations
-		64*1024, // memory cost: 64MB
-		4,       // parallelism
-		32,      // tag length
-	)
-	hashStr := fmt.Sprintf("%x", hash)
-	return hashStr == hashedPassword
+	return Default.Verify(password, hashedPassword)
 }
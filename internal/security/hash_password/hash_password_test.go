@@ -1,7 +1,12 @@
 package hashpassword
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestHashPassword(t *testing.T) {
@@ -58,14 +63,20 @@ func TestHashPassword(t *testing.T) {
 	}
 }
 
-func TestHashPasswordConsistency(t *testing.T) {
+func TestHashPasswordSalted(t *testing.T) {
 	password := "consistencyTestPassword123"
 
 	hash1, _ := HashPassword(password)
 	hash2, _ := HashPassword(password)
 
-	if hash1 != hash2 {
-		t.Errorf("HashPassword() is not consistent: got %s, then %s", hash1, hash2)
+	if hash1 == hash2 {
+		t.Errorf("HashPassword() reused a salt: got %s twice", hash1)
+	}
+	if !CheckPassword(password, hash1) || !CheckPassword(password, hash2) {
+		t.Errorf("CheckPassword() rejected a hash of the correct password")
+	}
+	if !strings.HasPrefix(hash1, "$argon2id$v=19$m=65536,t=3,p=4$") {
+		t.Errorf("HashPassword() = %s, want a PHC string with the default parameters", hash1)
 	}
 }
 
@@ -124,6 +135,29 @@ func TestCheckPassword(t *testing.T) {
 	}
 }
 
+func TestCheckPasswordBcrypt(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("legacyPassword1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() failed: %v", err)
+	}
+
+	if !IsBcrypt(string(hash)) {
+		t.Errorf("IsBcrypt() = false for a bcrypt hash")
+	}
+	if !CheckPassword("legacyPassword1", string(hash)) {
+		t.Errorf("CheckPassword() rejected the correct password for a bcrypt hash")
+	}
+	if CheckPassword("wrongPassword", string(hash)) {
+		t.Errorf("CheckPassword() accepted a wrong password for a bcrypt hash")
+	}
+
+	for _, h := range []string{"", "$2a$10$short", "plaintext"} {
+		if IsBcrypt(h) {
+			t.Errorf("IsBcrypt(%q) = true", h)
+		}
+	}
+}
+
 func BenchmarkHashPassword(b *testing.B) {
 	password := "benchmarkPassword123"
 	b.ResetTimer()
@@ -142,3 +176,81 @@ func BenchmarkCheckPassword(b *testing.B) {
 		CheckPassword(password, hashedPassword)
 	}
 }
+
+func TestNeedsRehash(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("legacyPassword1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() failed: %v", err)
+	}
+	if !NeedsRehash(string(legacy)) {
+		t.Errorf("NeedsRehash() = false for a bcrypt hash")
+	}
+
+	current, err := HashPassword("legacyPassword1")
+	if err != nil {
+		t.Fatalf("HashPassword() failed: %v", err)
+	}
+	if NeedsRehash(current) {
+		t.Errorf("NeedsRehash() = true for a current hash")
+	}
+}
+
+func TestArgon2_Hasher(t *testing.T) {
+	var h Hasher = NewArgon2(Argon2Params{Time: 1, Memory: 8 * 1024, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+
+	hash, err := h.Hash("hasherPassword1")
+	if err != nil {
+		t.Fatalf("Hash() failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Errorf("Hash() = %s, want the parameters in the PHC string", hash)
+	}
+	if !h.Verify("hasherPassword1", hash) || h.Verify("wrongPassword", hash) {
+		t.Errorf("Verify() doesn't check the password")
+	}
+	if h.NeedsRehash(hash) {
+		t.Errorf("NeedsRehash() = true for a hash with the hasher's parameters")
+	}
+
+	// Hashes made with other parameters verify, but are due for a rehash
+	if !Default.Verify("hasherPassword1", hash) {
+		t.Errorf("Verify() rejected a hash made with other parameters")
+	}
+	if !Default.NeedsRehash(hash) {
+		t.Errorf("NeedsRehash() = false for a hash made with other parameters")
+	}
+}
+
+func TestArgon2_LegacyHexHash(t *testing.T) {
+	// Made before PHC strings, with a fixed salt and the default parameters
+	key := argon2.IDKey([]byte("legacyPassword1"), []byte("salt"), 3, 64*1024, 4, 32)
+	legacy := fmt.Sprintf("%x", key)
+
+	if !CheckPassword("legacyPassword1", legacy) {
+		t.Errorf("CheckPassword() rejected the correct password for a hex hash")
+	}
+	if CheckPassword("wrongPassword", legacy) {
+		t.Errorf("CheckPassword() accepted a wrong password for a hex hash")
+	}
+	if !NeedsRehash(legacy) {
+		t.Errorf("NeedsRehash() = false for a hex hash")
+	}
+}
+
+func TestArgon2_MalformedHashes(t *testing.T) {
+	for _, h := range []string{
+		"",
+		"$argon2id$v=19$m=65536,t=3,p=4$c2FsdA",
+		"$argon2i$v=19$m=65536,t=3,p=4$c2FsdA$a2V5",
+		"$argon2id$v=16$m=65536,t=3,p=4$c2FsdA$a2V5",
+		"$argon2id$v=19$m=0,t=3,p=4$c2FsdA$a2V5",
+		"$argon2id$v=19$m=65536,t=3,p=4$!!$a2V5",
+	} {
+		if CheckPassword("anything", h) {
+			t.Errorf("CheckPassword() accepted %q", h)
+		}
+		if !NeedsRehash(h) {
+			t.Errorf("NeedsRehash(%q) = false", h)
+		}
+	}
+}
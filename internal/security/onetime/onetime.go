@@ -0,0 +1,101 @@
+// Package onetime holds what the emailed one-time token flows share. A raw
+// token is "<row id>.<secret>": the row is looked up by ID and only the
+// secret's SHA-256 is stored, so a database leak exposes no usable link.
+package onetime
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// NewSecret returns 32 random bytes, base64url encoded
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// Token joins a row ID and its secret into the raw token
+func Token(id uuid.UUID, secret string) string {
+	return id.String() + "." + secret
+}
+
+// ParseToken splits a raw token into its row ID and secret
+func ParseToken(raw string) (uuid.UUID, string, bool) {
+	idPart, secret, ok := strings.Cut(strings.TrimSpace(raw), ".")
+	if !ok || secret == "" {
+		return uuid.Nil, "", false
+	}
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return uuid.Nil, "", false
+	}
+	return id, secret, true
+}
+
+// HashSecret returns the hex SHA-256 of secret, the form that is stored
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashMatches compares the stored hash with the presented secret's hash in
+// constant time
+func HashMatches(hash, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashSecret(secret))) == 1
+}
+
+// Expirer is a token store that can drop its expired rows
+type Expirer interface {
+	// DeleteExpired removes rows that expired before cutoff
+	DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// CleanupJob deletes the expired rows of a token store
+type CleanupJob struct {
+	name  string
+	what  string
+	store Expirer
+	now   func() time.Time
+}
+
+// NewCleanupJob creates the jobs.Job called name that deletes the expired
+// rows of store; what names them in the log, "password reset tokens"
+func NewCleanupJob(name, what string, store Expirer) *CleanupJob {
+	return &CleanupJob{name: name, what: what, store: store, now: clock.Now}
+}
+
+// WithClock replaces the time source, for tests
+func (j *CleanupJob) WithClock(now func() time.Time) *CleanupJob {
+	j.now = now
+	return j
+}
+
+// Name implements jobs.Job
+func (j *CleanupJob) Name() string { return j.name }
+
+// Run implements jobs.Job
+func (j *CleanupJob) Run(ctx context.Context) error {
+	deleted, err := j.store.DeleteExpired(ctx, j.now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logger.Info("deleted expired "+j.what, map[string]any{
+			"deleted": deleted,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package onetime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseToken(t *testing.T) {
+	id := uuid.New()
+	secret, err := NewSecret()
+	require.NoError(t, err)
+
+	gotID, gotSecret, ok := ParseToken(" " + Token(id, secret) + "\n")
+	require.True(t, ok, "surrounding whitespace is trimmed")
+	assert.Equal(t, id, gotID)
+	assert.Equal(t, secret, gotSecret)
+
+	for _, raw := range []string{"", secret, id.String(), id.String() + ".", "not-a-uuid." + secret} {
+		_, _, ok := ParseToken(raw)
+		assert.False(t, ok, raw)
+	}
+}
+
+func TestHashMatches(t *testing.T) {
+	hash := HashSecret("secret")
+	assert.Len(t, hash, 64)
+	assert.True(t, HashMatches(hash, "secret"))
+	assert.False(t, HashMatches(hash, "Secret"))
+	assert.False(t, HashMatches("", "secret"))
+}
+
+// stubExpirer records the cutoffs it's asked about
+type stubExpirer struct {
+	cutoffs []time.Time
+}
+
+func (s *stubExpirer) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	s.cutoffs = append(s.cutoffs, cutoff)
+	return 2, nil
+}
+
+func TestCleanupJob(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	store := &stubExpirer{}
+	job := NewCleanupJob("reset_cleanup", "reset tokens", store).WithClock(func() time.Time { return now })
+
+	assert.Equal(t, "reset_cleanup", job.Name())
+	require.NoError(t, job.Run(context.Background()))
+	assert.Equal(t, []time.Time{now}, store.cutoffs)
+}
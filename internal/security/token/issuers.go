@@ -0,0 +1,110 @@
+package token
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// IssuerConfig accepts the access tokens of another service, such as a
+// sibling minting tokens for the same users. Their signatures are checked
+// with Key, a PEM public key, or the keys served at JWKSURL.
+type IssuerConfig struct {
+	Issuer      string        // iss claim of the tokens
+	Audience    string        // aud the tokens must carry
+	Key         string        // PEM-encoded public key, RSA, ECDSA or Ed25519
+	JWKSURL     string        // URL of the issuer's JSON Web Key Set, when there is no Key
+	JWKSRefresh time.Duration // How long fetched keys are used, DefaultJWKSRefresh when 0
+}
+
+// foreignMethods are the signing methods accepted from other issuers.
+// Only public keys are shared with them, so never HMAC.
+var foreignMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// issuer verifies the tokens of one IssuerConfig
+type issuer struct {
+	config IssuerConfig
+	key    any
+	keyErr error
+	jwks   *JWKS
+}
+
+func newIssuer(config IssuerConfig) *issuer {
+	iss := &issuer{config: config}
+	if config.Key != "" {
+		iss.key, iss.keyErr = ParsePublicKey(config.Key)
+	} else {
+		iss.jwks = NewJWKS(config.JWKSURL, config.JWKSRefresh)
+	}
+	return iss
+}
+
+// keyFunc finds the key a token of the issuer was signed with
+func (iss *issuer) keyFunc(token *jwt.Token) (any, error) {
+	if iss.jwks == nil {
+		return iss.key, iss.keyErr
+	}
+	kid, _ := token.Header["kid"].(string)
+	ctx, cancel := context.WithTimeout(context.Background(), jwksTimeout)
+	defer cancel()
+	return iss.jwks.Key(ctx, kid)
+}
+
+// ParsePublicKey parses a PEM-encoded PKIX public key
+func ParsePublicKey(data string) (any, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	return key, nil
+}
+
+// issuerOf returns the additional issuer that tokenString claims to come
+// from, nil for our own tokens and unknown issuers. The claim is read
+// without verifying the token, only to pick the key to verify it with.
+func (tm *TokenManager) issuerOf(tokenString string) *issuer {
+	if len(tm.issuers) == 0 {
+		return nil
+	}
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, &claims); err != nil {
+		return nil
+	}
+	if claims.Issuer == tm.config.Issuer {
+		return nil
+	}
+	return tm.issuers[claims.Issuer]
+}
+
+// validateForeignToken verifies a token of iss and tags its claims with
+// the issuer
+func (tm *TokenManager) validateForeignToken(tokenString string, iss *issuer) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, iss.keyFunc,
+		jwt.WithValidMethods(foreignMethods),
+		jwt.WithIssuer(iss.config.Issuer),
+		jwt.WithAudience(iss.config.Audience),
+		jwt.WithTimeFunc(tm.now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse access token of %s: %w", iss.config.Issuer, err)
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	claims.ForeignIssuer = iss.config.Issuer
+	// Their sessions and authentication strength are theirs to track
+	claims.SessionID = uuid.Nil
+	claims.Fresh = false
+	return claims, nil
+}
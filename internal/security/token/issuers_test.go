@@ -0,0 +1,239 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	return key
+}
+
+func publicKeyPEM(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// foreignToken mints an access token the way another service would
+func foreignToken(t *testing.T, method jwt.SigningMethod, key any, kid, issuer, audience string, now time.Time) string {
+	t.Helper()
+	claims := Claims{
+		UserID:    uuid.New(),
+		Roles:     []string{"user"},
+		SessionID: uuid.New(),
+		Fresh:     true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return signed
+}
+
+func newForeignTokenManager(issuers ...IssuerConfig) *TokenManager {
+	return NewTokenManager(TokenConfig{
+		SecretKey:         "test-secret-key",
+		ExpirationTime:    time.Hour,
+		Issuer:            "go-service-api",
+		AdditionalIssuers: issuers,
+	})
+}
+
+func TestValidateAccessToken_AdditionalIssuer(t *testing.T) {
+	key := newRSAKey(t)
+	tm := newForeignTokenManager(IssuerConfig{Issuer: "billing", Audience: "go-service-api", Key: publicKeyPEM(t, key)})
+	now := time.Now()
+
+	claims, err := tm.ValidateAccessToken(foreignToken(t, jwt.SigningMethodRS256, key, "", "billing", "go-service-api", now))
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if !claims.Foreign() || claims.ForeignIssuer != "billing" {
+		t.Errorf("ForeignIssuer = %q, want billing", claims.ForeignIssuer)
+	}
+	if claims.SessionID != uuid.Nil || claims.Fresh {
+		t.Errorf("foreign tokens must not carry our session or freshness, got sid %s fresh %v", claims.SessionID, claims.Fresh)
+	}
+
+	own, err := tm.GenerateAccessToken(uuid.New())
+	if err != nil {
+		t.Fatalf("GenerateAccessToken() error = %v", err)
+	}
+	claims, err = tm.ValidateAccessToken(own)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if claims.Foreign() {
+		t.Errorf("own tokens must not be foreign")
+	}
+}
+
+func TestValidateAccessToken_AdditionalIssuerRejects(t *testing.T) {
+	key := newRSAKey(t)
+	other := newRSAKey(t)
+	tm := newForeignTokenManager(IssuerConfig{Issuer: "billing", Audience: "go-service-api", Key: publicKeyPEM(t, key)})
+	now := time.Now()
+
+	tests := map[string]string{
+		"wrong audience": foreignToken(t, jwt.SigningMethodRS256, key, "", "billing", "billing-web", now),
+		"unknown issuer": foreignToken(t, jwt.SigningMethodRS256, key, "", "shipping", "go-service-api", now),
+		"other key":      foreignToken(t, jwt.SigningMethodRS256, other, "", "billing", "go-service-api", now),
+		"expired":        foreignToken(t, jwt.SigningMethodRS256, key, "", "billing", "go-service-api", now.Add(-2*time.Hour)),
+		// Signed with our own secret but claiming the other issuer, so an
+		// HMAC key can't be used to pass as them
+		"hmac": foreignToken(t, jwt.SigningMethodHS256, []byte("test-secret-key"), "", "billing", "go-service-api", now),
+	}
+	for name, tokenString := range tests {
+		if _, err := tm.ValidateAccessToken(tokenString); err == nil {
+			t.Errorf("%s: ValidateAccessToken() accepted the token", name)
+		}
+	}
+}
+
+// jwksServer serves the given keys as a JWKS, counting the fetches
+type jwksServer struct {
+	*httptest.Server
+	keys    atomic.Pointer[map[string]*rsa.PrivateKey]
+	fetches atomic.Int32
+	fail    atomic.Bool
+}
+
+func newJWKSServer(t *testing.T, keys map[string]*rsa.PrivateKey) *jwksServer {
+	t.Helper()
+	s := &jwksServer{}
+	s.keys.Store(&keys)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		if s.fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		for kid, key := range *s.keys.Load() {
+			set.Keys = append(set.Keys, jwk{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestValidateAccessToken_JWKSCache(t *testing.T) {
+	key := newRSAKey(t)
+	server := newJWKSServer(t, map[string]*rsa.PrivateKey{"k1": key})
+	frozen := clock.NewFrozen(time.Now())
+	tm := newForeignTokenManager(IssuerConfig{
+		Issuer:      "billing",
+		Audience:    "go-service-api",
+		JWKSURL:     server.URL,
+		JWKSRefresh: time.Minute,
+	}).WithClock(frozen.Now)
+
+	validate := func(kid string, signer *rsa.PrivateKey) error {
+		_, err := tm.ValidateAccessToken(foreignToken(t, jwt.SigningMethodRS256, signer, kid, "billing", "go-service-api", frozen.Now()))
+		return err
+	}
+
+	if err := validate("k1", key); err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	frozen.Advance(59 * time.Second)
+	if err := validate("k1", key); err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if got := server.fetches.Load(); got != 1 {
+		t.Errorf("fetches within the refresh interval = %d, want 1", got)
+	}
+
+	frozen.Advance(time.Second)
+	if err := validate("k1", key); err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if got := server.fetches.Load(); got != 2 {
+		t.Errorf("fetches after the refresh interval = %d, want 2", got)
+	}
+
+	// A rotated-in key is fetched as soon as a token names it
+	rotated := newRSAKey(t)
+	server.keys.Store(&map[string]*rsa.PrivateKey{"k1": key, "k2": rotated})
+	frozen.Advance(jwksUnknownKeyRefetch)
+	if err := validate("k2", rotated); err != nil {
+		t.Fatalf("ValidateAccessToken() with a rotated key error = %v", err)
+	}
+	if got := server.fetches.Load(); got != 3 {
+		t.Errorf("fetches for a new key = %d, want 3", got)
+	}
+
+	// Unknown kids don't fetch the set on every token, and it was just
+	// fetched
+	for range 5 {
+		if err := validate("k3", rotated); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("ValidateAccessToken() error = %v, want ErrUnknownKey", err)
+		}
+	}
+	if got := server.fetches.Load(); got != 3 {
+		t.Errorf("fetches for unknown keys = %d, want 3", got)
+	}
+
+	// An issuer that's down leaves the cached keys working
+	server.fail.Store(true)
+	frozen.Advance(time.Minute)
+	if err := validate("k1", key); err != nil {
+		t.Errorf("ValidateAccessToken() with the JWKS down error = %v", err)
+	}
+	if got := server.fetches.Load(); got <= 3 {
+		t.Errorf("fetches with the JWKS down = %d, want a refetch", got)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	if _, err := ParsePublicKey(publicKeyPEM(t, newRSAKey(t))); err != nil {
+		t.Errorf("ParsePublicKey() error = %v", err)
+	}
+	if _, err := ParsePublicKey("not a key"); err == nil {
+		t.Errorf("ParsePublicKey() accepted a non-PEM value")
+	}
+}
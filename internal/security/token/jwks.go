@@ -0,0 +1,222 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/httpclient"
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+const (
+	// DefaultJWKSRefresh is how long a fetched key set is used before it
+	// is fetched again
+	DefaultJWKSRefresh = 10 * time.Minute
+	// jwksUnknownKeyRefetch is the least time between fetches made for a
+	// key ID the set doesn't hold, so tokens with made-up kids can't make
+	// every request fetch the set
+	jwksUnknownKeyRefetch = 30 * time.Second
+	// jwksTimeout bounds a fetch of the key set
+	jwksTimeout = 5 * time.Second
+)
+
+// ErrUnknownKey is returned for tokens signed with a key the issuer's key
+// set doesn't hold
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// JWKS is the cached JSON Web Key Set of an issuer. The set is fetched on
+// first use and again once it is older than its refresh interval, or when
+// a token names a key it lacks, as issuers add keys before signing with
+// them. When a refetch fails the keys already fetched keep working.
+type JWKS struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	now     func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]any
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+// NewJWKS creates the key set served at url, refetched every refresh,
+// DefaultJWKSRefresh when 0
+func NewJWKS(url string, refresh time.Duration) *JWKS {
+	if refresh <= 0 {
+		refresh = DefaultJWKSRefresh
+	}
+	return &JWKS{
+		url:     url,
+		refresh: refresh,
+		client:  httpclient.New(httpclient.Options{Name: "jwks", Timeout: jwksTimeout}).Client,
+		now:     clock.Now,
+	}
+}
+
+// WithClock replaces the time source for the refresh interval, for tests
+func (j *JWKS) WithClock(now func() time.Time) *JWKS {
+	j.now = now
+	return j
+}
+
+// Key returns the public key with the given ID. A set holding a single
+// key also answers for tokens naming none.
+func (j *JWKS) Key(ctx context.Context, kid string) (any, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := j.now()
+	key, ok := j.lookup(kid)
+	stale := j.keys == nil || now.Sub(j.fetchedAt) >= j.refresh
+	if stale || (!ok && now.Sub(j.triedAt) >= jwksUnknownKeyRefetch) {
+		j.triedAt = now
+		if err := j.fetch(ctx); err != nil {
+			if j.keys == nil {
+				return nil, err
+			}
+			logger.Warn("failed to refresh JWKS, using the cached keys", map[string]any{
+				"url":   j.url,
+				"error": err.Error(),
+			})
+		} else {
+			j.fetchedAt = now
+		}
+		key, ok = j.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+func (j *JWKS) lookup(kid string) (any, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// fetch replaces the keys with the set served at the URL
+func (j *JWKS) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// One key we can't use shouldn't take the others down
+			logger.Warn("skipping JWKS key", map[string]any{"url": j.url, "kid": k.Kid, "error": err.Error()})
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	j.keys = keys
+	return nil
+}
+
+// jwk is a public JSON Web Key, RFC 7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,146 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Kind is the purpose a token is issued for. Every kind has its own
+// lifetime and audience, so a token of one kind is never accepted as
+// another.
+type Kind string
+
+// Token kinds
+const (
+	KindAccess        Kind = "access"
+	KindRefresh       Kind = "refresh"
+	KindMFA           Kind = "mfa"
+	KindReset         Kind = "reset"
+	KindVerification  Kind = "verification"
+	KindImpersonation Kind = "impersonation"
+)
+
+// DefaultTTLs are the lifetimes of the kinds missing from TokenConfig.TTLs.
+// Access and refresh tokens fall back to ExpirationTime and RefreshDuration
+// first.
+var DefaultTTLs = map[Kind]time.Duration{
+	KindAccess:        time.Hour,
+	KindRefresh:       7 * 24 * time.Hour,
+	KindMFA:           5 * time.Minute,
+	KindReset:         30 * time.Minute,
+	KindVerification:  24 * time.Hour,
+	KindImpersonation: 15 * time.Minute,
+}
+
+// ErrWrongKind is returned when a purpose token is requested for the access
+// or refresh kinds, which have their own generators
+var ErrWrongKind = errors.New("token kind has its own generator")
+
+// PurposeClaims are the claims of the single-purpose tokens: MFA
+// challenges, email verification, password reset and impersonation
+type PurposeClaims struct {
+	UserID   uuid.UUID `json:"user_id"`
+	TenantID uuid.UUID `json:"tenant_id,omitempty"`
+	Kind     Kind      `json:"kind"`
+	jwt.RegisteredClaims
+}
+
+// TTL returns how long tokens of kind stay valid
+func (tm *TokenManager) TTL(kind Kind) time.Duration {
+	if ttl := tm.config.TTLs[kind]; ttl > 0 {
+		return ttl
+	}
+	switch kind {
+	case KindAccess:
+		if tm.config.ExpirationTime > 0 {
+			return tm.config.ExpirationTime
+		}
+	case KindRefresh:
+		if tm.config.RefreshDuration > 0 {
+			return tm.config.RefreshDuration
+		}
+	}
+	return DefaultTTLs[kind]
+}
+
+// purposeAudience is the audience of purpose tokens of kind
+func (tm *TokenManager) purposeAudience(kind Kind) string {
+	return tm.config.Issuer + "-" + string(kind)
+}
+
+// GeneratePurposeToken signs a single-purpose token of kind for userID,
+// valid for TTL(kind)
+func (tm *TokenManager) GeneratePurposeToken(kind Kind, userID uuid.UUID, opts ...ClaimOption) (string, error) {
+	if kind == KindAccess || kind == KindRefresh {
+		return "", ErrWrongKind
+	}
+	if _, ok := DefaultTTLs[kind]; !ok {
+		return "", fmt.Errorf("unknown token kind %q", kind)
+	}
+	o := newClaimOptions(opts)
+
+	now := tm.now()
+	claims := &PurposeClaims{
+		UserID:   userID,
+		TenantID: o.tenantID,
+		Kind:     kind,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tm.TTL(kind))),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    tm.config.Issuer,
+			Audience:  jwt.ClaimStrings{tm.purposeAudience(kind)},
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(tm.config.SecretKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign %s token: %w", kind, err)
+	}
+	return tokenString, nil
+}
+
+// ValidatePurposeToken validates and parses a purpose token, which must
+// have been issued for kind
+func (tm *TokenManager) ValidatePurposeToken(kind Kind, tokenString string) (*PurposeClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &PurposeClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(tm.config.SecretKey), nil
+	}, jwt.WithTimeFunc(tm.now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s token: %w", kind, err)
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid %s token", kind)
+	}
+
+	claims, ok := token.Claims.(*PurposeClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	if claims.Issuer != tm.config.Issuer {
+		return nil, fmt.Errorf("invalid token issuer")
+	}
+	if claims.Kind != kind {
+		return nil, fmt.Errorf("invalid token kind")
+	}
+	found := false
+	for _, aud := range claims.Audience {
+		if aud == tm.purposeAudience(kind) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("invalid token audience")
+	}
+	return claims, nil
+}
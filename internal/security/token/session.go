@@ -0,0 +1,55 @@
+package token
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrSessionRevoked is returned for tokens whose session was revoked
+	ErrSessionRevoked = errors.New("session has been revoked")
+	// ErrMissingSession is returned for tokens without a sid claim when
+	// TokenConfig.RequireSession is set
+	ErrMissingSession = errors.New("token carries no session")
+)
+
+// SessionRevocations reports revoked sessions. It is consulted on every
+// authenticated request, so implementations answer from memory.
+type SessionRevocations interface {
+	IsRevoked(sessionID uuid.UUID) bool
+}
+
+// WithSession binds the token to the session it was issued for, so
+// revoking the session also rejects the token
+func WithSession(sessionID uuid.UUID) ClaimOption {
+	return func(o *claimOptions) {
+		o.sessionID = sessionID
+	}
+}
+
+// GenerateSessionAccessToken generates an access token bound to sessionID
+func (tm *TokenManager) GenerateSessionAccessToken(userID, sessionID uuid.UUID, opts ...ClaimOption) (string, error) {
+	return tm.GenerateAccessToken(userID, append(opts, WithSession(sessionID))...)
+}
+
+// WithSessionRevocations makes CheckSession consult r
+func (tm *TokenManager) WithSessionRevocations(r SessionRevocations) *TokenManager {
+	tm.revocations = r
+	return tm
+}
+
+// CheckSession rejects a token's session once it is revoked. Tokens issued
+// before the sid claim existed pass unless RequireSession is set.
+func (tm *TokenManager) CheckSession(sessionID uuid.UUID) error {
+	if sessionID == uuid.Nil {
+		if tm.config.RequireSession {
+			return ErrMissingSession
+		}
+		return nil
+	}
+	if tm.revocations != nil && tm.revocations.IsRevoked(sessionID) {
+		return ErrSessionRevoked
+	}
+	return nil
+}
@@ -1,108 +1,347 @@
 package token
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
+	"dvith.com/go-service-api/pkg/clock"
+
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+// ErrUnknownClient is returned when a token is requested for a client ID
+// that has no configured profile
+var ErrUnknownClient = errors.New("unknown client_id")
+
 // TokenConfig holds JWT configuration
 type TokenConfig struct {
-	SecretKey       string        // Secret key for signing tokens
-	ExpirationTime  time.Duration // Token expiration duration
-	RefreshDuration time.Duration // Refresh token expiration duration
-	Issuer          string        // JWT issuer claim
+	SecretKey       string                   // Secret key for signing tokens
+	ExpirationTime  time.Duration            // Token expiration duration
+	RefreshDuration time.Duration            // Refresh token expiration duration
+	TTLs            map[Kind]time.Duration   // Per-kind lifetimes; these win over ExpirationTime and RefreshDuration
+	Issuer          string                   // JWT issuer claim
+	ClientProfiles  map[string]ClientProfile // Per-client audience and lifetimes, keyed by client ID
+	RequireSession  bool                     // Reject access tokens without a sid claim
+	// AdditionalIssuers are other services whose access tokens are
+	// accepted too, selected by the token's iss claim
+	AdditionalIssuers []IssuerConfig
+}
+
+// ClientProfile customizes tokens issued to one type of client (e.g. web,
+// mobile). Zero TTLs fall back to the TokenConfig defaults.
+type ClientProfile struct {
+	Audience   string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
 }
 
 // Claims represents custom JWT claims
 type Claims struct {
-	UserID uuid.UUID `json:"user_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	TenantID uuid.UUID `json:"tenant_id,omitempty"`
+	Roles    []string  `json:"roles,omitempty"`
+	ClientID string    `json:"client_id,omitempty"`
+	// SessionID is the session opened at signin; tokens issued before the
+	// claim existed carry none
+	SessionID uuid.UUID `json:"sid,omitzero"`
+	// Fresh marks tokens issued on direct password authentication, see
+	// WithFresh
+	Fresh bool `json:"fresh,omitempty"`
+	// ForeignIssuer is set on the claims of tokens minted by one of the
+	// TokenConfig.AdditionalIssuers, to the issuer. It is never read from
+	// the token itself.
+	ForeignIssuer string `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// TokenID returns the jti claim, "" for tokens issued before access tokens
+// carried one
+func (c *Claims) TokenID() string {
+	return c.ID
+}
+
+// Foreign reports whether the token was minted by another service
+func (c *Claims) Foreign() bool {
+	return c.ForeignIssuer != ""
+}
+
 // RefreshTokenClaims represents refresh token claims
 type RefreshTokenClaims struct {
-	UserID uuid.UUID `json:"user_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	TenantID uuid.UUID `json:"tenant_id,omitempty"`
+	ClientID string    `json:"client_id,omitempty"`
+	// SessionID is carried over to the access tokens the refresh issues
+	SessionID uuid.UUID `json:"sid,omitzero"`
 	jwt.RegisteredClaims
 }
 
+// TokenID returns the jti claim
+func (c *RefreshTokenClaims) TokenID() string {
+	return c.ID
+}
+
+// ClaimOption customizes the custom claims embedded in generated tokens
+type ClaimOption func(*claimOptions)
+
+type claimOptions struct {
+	tenantID  uuid.UUID
+	roles     []string
+	clientID  string
+	sessionID uuid.UUID
+	fresh     bool
+}
+
+func newClaimOptions(opts []ClaimOption) claimOptions {
+	var o claimOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTenant scopes the token to the given tenant
+func WithTenant(tenantID uuid.UUID) ClaimOption {
+	return func(o *claimOptions) {
+		o.tenantID = tenantID
+	}
+}
+
+// WithClient issues the token under the named client profile, which sets
+// its audience and lifetime
+func WithClient(clientID string) ClaimOption {
+	return func(o *claimOptions) {
+		o.clientID = clientID
+	}
+}
+
+// WithFresh marks the access token as issued on direct password
+// authentication. Refresh tokens never carry the marker, so the access
+// tokens they are exchanged for aren't fresh.
+func WithFresh() ClaimOption {
+	return func(o *claimOptions) {
+		o.fresh = true
+	}
+}
+
 // TokenPair represents access and refresh tokens
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	TokenType    string `json:"token_type"`
 	ExpiresIn    int64  `json:"expires_in"`
+	// AccessTokenID and RefreshTokenID are the jti claims of the tokens,
+	// for audit records and revocation lists; clients read them from the
+	// tokens if at all
+	AccessTokenID  string `json:"-"`
+	RefreshTokenID string `json:"-"`
 }
 
 // TokenManager handles JWT token operations
 type TokenManager struct {
-	config TokenConfig
+	config      TokenConfig
+	issuers     map[string]*issuer
+	revocations SessionRevocations
+	validations *ValidationCache
+	now         func() time.Time
 }
 
 // NewTokenManager creates a new token manager
 func NewTokenManager(config TokenConfig) *TokenManager {
-	return &TokenManager{
+	tm := &TokenManager{
 		config: config,
+		now:    clock.Now,
+	}
+	if len(config.AdditionalIssuers) > 0 {
+		tm.issuers = make(map[string]*issuer, len(config.AdditionalIssuers))
+		for _, ic := range config.AdditionalIssuers {
+			tm.issuers[ic.Issuer] = newIssuer(ic)
+		}
+	}
+	return tm
+}
+
+// WithClock replaces the time source tokens are issued and validated
+// against, and the additional issuers' key sets are refreshed by, for tests
+func (tm *TokenManager) WithClock(now func() time.Time) *TokenManager {
+	tm.now = now
+	for _, iss := range tm.issuers {
+		if iss.jwks != nil {
+			iss.jwks.WithClock(now)
+		}
+	}
+	return tm
+}
+
+// AccessTokenTTL returns how long generated access tokens stay valid
+func (tm *TokenManager) AccessTokenTTL() time.Duration {
+	return tm.TTL(KindAccess)
+}
+
+// HasClient reports whether clientID is empty or names a configured profile
+func (tm *TokenManager) HasClient(clientID string) bool {
+	if clientID == "" {
+		return true
 	}
+	_, ok := tm.config.ClientProfiles[clientID]
+	return ok
+}
+
+// ClientProfile returns the profile configured for clientID
+func (tm *TokenManager) ClientProfile(clientID string) (ClientProfile, bool) {
+	p, ok := tm.config.ClientProfiles[clientID]
+	return p, ok
+}
+
+// ClientAccessTTL returns the access token lifetime for clientID
+func (tm *TokenManager) ClientAccessTTL(clientID string) time.Duration {
+	if p, ok := tm.config.ClientProfiles[clientID]; ok && p.AccessTTL > 0 {
+		return p.AccessTTL
+	}
+	return tm.TTL(KindAccess)
+}
+
+// ClientRefreshTTL returns the refresh token lifetime for clientID
+func (tm *TokenManager) ClientRefreshTTL(clientID string) time.Duration {
+	if p, ok := tm.config.ClientProfiles[clientID]; ok && p.RefreshTTL > 0 {
+		return p.RefreshTTL
+	}
+	return tm.TTL(KindRefresh)
+}
+
+// defaultAudience is the audience of access tokens issued without a client
+func (tm *TokenManager) defaultAudience() string {
+	return tm.config.Issuer + "-users"
+}
+
+// clientAudience returns the access token audience for clientID
+func (tm *TokenManager) clientAudience(clientID string) string {
+	if p, ok := tm.config.ClientProfiles[clientID]; ok && p.Audience != "" {
+		return p.Audience
+	}
+	return tm.defaultAudience()
+}
+
+// HasAudience reports whether the claims include aud
+func (c *Claims) HasAudience(aud string) bool {
+	for _, a := range c.Audience {
+		if a == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// WithRoles embeds the user's roles in the access token
+func WithRoles(roles ...string) ClaimOption {
+	return func(o *claimOptions) {
+		o.roles = roles
+	}
+}
+
+// HasRole reports whether the claims include role
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
 }
 
 // GenerateTokenPair generates both access and refresh tokens
-func (tm *TokenManager) GenerateTokenPair(userID uuid.UUID) (*TokenPair, error) {
+func (tm *TokenManager) GenerateTokenPair(userID uuid.UUID, opts ...ClaimOption) (*TokenPair, error) {
+	o := newClaimOptions(opts)
+
 	// Generate access token
-	accessToken, err := tm.GenerateAccessToken(userID)
+	accessToken, accessID, err := tm.generateAccessToken(userID, o)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	// Generate refresh token
-	refreshToken, err := tm.GenerateRefreshToken(userID)
+	refreshToken, refreshID, err := tm.generateRefreshToken(userID, o)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
 	return &TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    int64(tm.config.ExpirationTime.Seconds()),
+		AccessToken:    accessToken,
+		RefreshToken:   refreshToken,
+		TokenType:      "Bearer",
+		ExpiresIn:      int64(tm.ClientAccessTTL(o.clientID).Seconds()),
+		AccessTokenID:  accessID,
+		RefreshTokenID: refreshID,
 	}, nil
 }
 
 // GenerateAccessToken generates a JWT access token
-func (tm *TokenManager) GenerateAccessToken(userID uuid.UUID) (string, error) {
-	now := time.Now()
-	expirationTime := now.Add(tm.config.ExpirationTime)
+func (tm *TokenManager) GenerateAccessToken(userID uuid.UUID, opts ...ClaimOption) (string, error) {
+	tokenString, _, err := tm.generateAccessToken(userID, newClaimOptions(opts))
+	return tokenString, err
+}
+
+// generateAccessToken signs an access token and returns it with its jti
+func (tm *TokenManager) generateAccessToken(userID uuid.UUID, o claimOptions) (string, string, error) {
+	if !tm.HasClient(o.clientID) {
+		return "", "", ErrUnknownClient
+	}
+
+	now := tm.now()
+	expirationTime := now.Add(tm.ClientAccessTTL(o.clientID))
 
 	claims := &Claims{
-		UserID: userID,
+		UserID:    userID,
+		TenantID:  o.tenantID,
+		Roles:     o.roles,
+		ClientID:  o.clientID,
+		SessionID: o.sessionID,
+		Fresh:     o.fresh,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    tm.config.Issuer,
-			Audience:  jwt.ClaimStrings{tm.config.Issuer + "-users"},
+			Audience:  jwt.ClaimStrings{tm.clientAudience(o.clientID)},
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(tm.config.SecretKey))
 	if err != nil {
-		return "", fmt.Errorf("failed to sign access token: %w", err)
+		return "", "", fmt.Errorf("failed to sign access token: %w", err)
 	}
 
-	return tokenString, nil
+	return tokenString, claims.ID, nil
 }
 
 // GenerateRefreshToken generates a JWT refresh token
-func (tm *TokenManager) GenerateRefreshToken(userID uuid.UUID) (string, error) {
-	now := time.Now()
-	expirationTime := now.Add(tm.config.RefreshDuration)
+func (tm *TokenManager) GenerateRefreshToken(userID uuid.UUID, opts ...ClaimOption) (string, error) {
+	tokenString, _, err := tm.generateRefreshToken(userID, newClaimOptions(opts))
+	return tokenString, err
+}
+
+// generateRefreshToken signs a refresh token and returns it with its jti
+func (tm *TokenManager) generateRefreshToken(userID uuid.UUID, o claimOptions) (string, string, error) {
+	if !tm.HasClient(o.clientID) {
+		return "", "", ErrUnknownClient
+	}
+
+	now := tm.now()
+	expirationTime := now.Add(tm.ClientRefreshTTL(o.clientID))
 
 	claims := &RefreshTokenClaims{
-		UserID: userID,
+		UserID:    userID,
+		TenantID:  o.tenantID,
+		ClientID:  o.clientID,
+		SessionID: o.sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			// A random ID keeps rotated tokens distinct even when they are
+			// issued within the same second
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
@@ -114,21 +353,46 @@ func (tm *TokenManager) GenerateRefreshToken(userID uuid.UUID) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(tm.config.SecretKey))
 	if err != nil {
-		return "", fmt.Errorf("failed to sign refresh token: %w", err)
+		return "", "", fmt.Errorf("failed to sign refresh token: %w", err)
 	}
 
-	return tokenString, nil
+	return tokenString, claims.ID, nil
 }
 
-// ValidateAccessToken validates and parses an access token
+// ValidateAccessToken validates and parses an access token, answering
+// from the validation cache when there is one
 func (tm *TokenManager) ValidateAccessToken(tokenString string) (*Claims, error) {
+	if tm.validations == nil {
+		return tm.validateAccessToken(tokenString)
+	}
+
+	key := cacheKey(tokenString)
+	now := tm.now()
+	if claims, ok := tm.validations.get(key, now); ok {
+		return claims, nil
+	}
+	claims, err := tm.validateAccessToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	tm.validations.put(key, claims, now)
+	return claims, nil
+}
+
+// validateAccessToken verifies the signature and claims of tokenString,
+// with the key of the additional issuer its iss claim names if any
+func (tm *TokenManager) validateAccessToken(tokenString string) (*Claims, error) {
+	if iss := tm.issuerOf(tokenString); iss != nil {
+		return tm.validateForeignToken(tokenString, iss)
+	}
+
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		// Verify signing method
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(tm.config.SecretKey), nil
-	})
+	}, jwt.WithTimeFunc(tm.now))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse access token: %w", err)
@@ -148,21 +412,32 @@ func (tm *TokenManager) ValidateAccessToken(tokenString string) (*Claims, error)
 		return nil, fmt.Errorf("invalid token issuer")
 	}
 
-	// Check if audience contains our expected value
-	found := false
-	for _, aud := range claims.Audience {
-		if aud == "go-service-api-users" {
-			found = true
-			break
-		}
-	}
-	if !found {
+	// The audience must be the one issued for the token's client
+	if !tm.HasClient(claims.ClientID) || !claims.HasAudience(tm.clientAudience(claims.ClientID)) {
 		return nil, fmt.Errorf("invalid token audience")
 	}
 
 	return claims, nil
 }
 
+// TokenAge returns how long ago the token was issued. Tokens without an
+// iat claim are treated as infinitely old.
+func (tm *TokenManager) TokenAge(claims *Claims) time.Duration {
+	if claims.IssuedAt == nil {
+		return time.Duration(math.MaxInt64)
+	}
+	return tm.now().Sub(claims.IssuedAt.Time)
+}
+
+// ExpiresIn returns how long until the token expires, never negative.
+// Tokens without an exp claim never do.
+func (tm *TokenManager) ExpiresIn(claims *Claims) time.Duration {
+	if claims.ExpiresAt == nil {
+		return time.Duration(math.MaxInt64)
+	}
+	return max(claims.ExpiresAt.Time.Sub(tm.now()), 0)
+}
+
 // ValidateRefreshToken validates and parses a refresh token
 func (tm *TokenManager) ValidateRefreshToken(tokenString string) (*RefreshTokenClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &RefreshTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
@@ -171,7 +446,7 @@ func (tm *TokenManager) ValidateRefreshToken(tokenString string) (*RefreshTokenC
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(tm.config.SecretKey), nil
-	})
+	}, jwt.WithTimeFunc(tm.now))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse refresh token: %w", err)
@@ -194,7 +469,7 @@ func (tm *TokenManager) ValidateRefreshToken(tokenString string) (*RefreshTokenC
 	// Check if audience contains our expected value
 	found := false
 	for _, aud := range claims.Audience {
-		if aud == "go-service-api-refresh" {
+		if aud == tm.config.Issuer+"-refresh" {
 			found = true
 			break
 		}
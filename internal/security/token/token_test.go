@@ -1,9 +1,13 @@
 package token
 
 import (
+	"errors"
 	"testing"
 	"time"
 
+	"dvith.com/go-service-api/pkg/clock"
+
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -154,3 +158,478 @@ func BenchmarkValidateAccessToken(b *testing.B) {
 		tm.ValidateAccessToken(token)
 	}
 }
+
+func TestGenerateTokenPair_WithTenant(t *testing.T) {
+	tm := NewTokenManager(TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  1 * time.Hour,
+		RefreshDuration: 7 * 24 * time.Hour,
+		Issuer:          "go-service-api",
+	})
+
+	userID := uuid.New()
+	tenantID := uuid.New()
+	pair, err := tm.GenerateTokenPair(userID, WithTenant(tenantID))
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+
+	access, err := tm.ValidateAccessToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if access.TenantID != tenantID {
+		t.Errorf("access TenantID = %v, want %v", access.TenantID, tenantID)
+	}
+
+	refresh, err := tm.ValidateRefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("ValidateRefreshToken() error = %v", err)
+	}
+	if refresh.TenantID != tenantID {
+		t.Errorf("refresh TenantID = %v, want %v", refresh.TenantID, tenantID)
+	}
+}
+
+func newClientTokenManager() *TokenManager {
+	return NewTokenManager(TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  1 * time.Hour,
+		RefreshDuration: 7 * 24 * time.Hour,
+		Issuer:          "go-service-api",
+		ClientProfiles: map[string]ClientProfile{
+			"web":    {Audience: "web", AccessTTL: 15 * time.Minute, RefreshTTL: 24 * time.Hour},
+			"mobile": {Audience: "mobile", AccessTTL: 2 * time.Hour},
+		},
+	})
+}
+
+func TestGenerateTokenPair_ClientProfiles(t *testing.T) {
+	tm := newClientTokenManager()
+
+	tests := []struct {
+		client     string
+		audience   string
+		accessTTL  time.Duration
+		refreshTTL time.Duration
+	}{
+		{client: "", audience: "go-service-api-users", accessTTL: time.Hour, refreshTTL: 7 * 24 * time.Hour},
+		{client: "web", audience: "web", accessTTL: 15 * time.Minute, refreshTTL: 24 * time.Hour},
+		{client: "mobile", audience: "mobile", accessTTL: 2 * time.Hour, refreshTTL: 7 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run("client_"+tt.client, func(t *testing.T) {
+			pair, err := tm.GenerateTokenPair(uuid.New(), WithClient(tt.client))
+			if err != nil {
+				t.Fatalf("GenerateTokenPair() error = %v", err)
+			}
+			if pair.ExpiresIn != int64(tt.accessTTL.Seconds()) {
+				t.Errorf("ExpiresIn = %d, want %d", pair.ExpiresIn, int64(tt.accessTTL.Seconds()))
+			}
+
+			access, err := tm.ValidateAccessToken(pair.AccessToken)
+			if err != nil {
+				t.Fatalf("ValidateAccessToken() error = %v", err)
+			}
+			if !access.HasAudience(tt.audience) {
+				t.Errorf("audience = %v, want %s", access.Audience, tt.audience)
+			}
+			if access.ClientID != tt.client {
+				t.Errorf("ClientID = %q, want %q", access.ClientID, tt.client)
+			}
+			if got := access.ExpiresAt.Sub(access.IssuedAt.Time); got != tt.accessTTL {
+				t.Errorf("access lifetime = %v, want %v", got, tt.accessTTL)
+			}
+
+			refresh, err := tm.ValidateRefreshToken(pair.RefreshToken)
+			if err != nil {
+				t.Fatalf("ValidateRefreshToken() error = %v", err)
+			}
+			if refresh.ClientID != tt.client {
+				t.Errorf("refresh ClientID = %q, want %q", refresh.ClientID, tt.client)
+			}
+			if got := refresh.ExpiresAt.Sub(refresh.IssuedAt.Time); got != tt.refreshTTL {
+				t.Errorf("refresh lifetime = %v, want %v", got, tt.refreshTTL)
+			}
+		})
+	}
+}
+
+func TestGenerateAccessToken_UnknownClient(t *testing.T) {
+	tm := newClientTokenManager()
+
+	if _, err := tm.GenerateAccessToken(uuid.New(), WithClient("desktop")); err != ErrUnknownClient {
+		t.Errorf("GenerateAccessToken() error = %v, want ErrUnknownClient", err)
+	}
+}
+
+func TestValidateAccessToken_ClientRemoved(t *testing.T) {
+	token, err := newClientTokenManager().GenerateAccessToken(uuid.New(), WithClient("web"))
+	if err != nil {
+		t.Fatalf("GenerateAccessToken() error = %v", err)
+	}
+
+	// A manager without the web profile must not accept web tokens
+	tm := NewTokenManager(TokenConfig{
+		SecretKey:      "test-secret-key",
+		ExpirationTime: 1 * time.Hour,
+		Issuer:         "go-service-api",
+	})
+	if _, err := tm.ValidateAccessToken(token); err == nil {
+		t.Error("ValidateAccessToken() accepted a token for an unconfigured client")
+	}
+}
+
+type revokedSet map[uuid.UUID]bool
+
+func (r revokedSet) IsRevoked(id uuid.UUID) bool { return r[id] }
+
+func TestSessionClaim(t *testing.T) {
+	tm := NewTokenManager(TokenConfig{SecretKey: "test-secret-key", ExpirationTime: time.Hour, RefreshDuration: time.Hour, Issuer: "go-service-api"})
+	userID, sessionID := uuid.New(), uuid.New()
+
+	access, err := tm.GenerateSessionAccessToken(userID, sessionID)
+	if err != nil {
+		t.Fatalf("GenerateSessionAccessToken() error = %v", err)
+	}
+	claims, err := tm.ValidateAccessToken(access)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if claims.SessionID != sessionID {
+		t.Errorf("SessionID = %v, want %v", claims.SessionID, sessionID)
+	}
+
+	// Refreshes carry the session over to the next access token
+	pair, err := tm.GenerateTokenPair(userID, WithSession(sessionID))
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+	refreshClaims, err := tm.ValidateRefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("ValidateRefreshToken() error = %v", err)
+	}
+	if refreshClaims.SessionID != sessionID {
+		t.Errorf("refresh SessionID = %v, want %v", refreshClaims.SessionID, sessionID)
+	}
+
+	// Tokens without a session omit the claim entirely
+	legacy, err := tm.GenerateAccessToken(userID)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken() error = %v", err)
+	}
+	raw := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(legacy, raw); err != nil {
+		t.Fatalf("ParseUnverified() error = %v", err)
+	}
+	if _, ok := raw["sid"]; ok {
+		t.Errorf("legacy token carries a sid claim: %v", raw["sid"])
+	}
+}
+
+func TestTokenIDs(t *testing.T) {
+	tm := NewTokenManager(TokenConfig{SecretKey: "test-secret-key", ExpirationTime: time.Hour, RefreshDuration: time.Hour, Issuer: "go-service-api"})
+	userID := uuid.New()
+
+	seen := map[string]bool{}
+	for range 50 {
+		pair, err := tm.GenerateTokenPair(userID)
+		if err != nil {
+			t.Fatalf("GenerateTokenPair() error = %v", err)
+		}
+		access, err := tm.ValidateAccessToken(pair.AccessToken)
+		if err != nil {
+			t.Fatalf("ValidateAccessToken() error = %v", err)
+		}
+		refresh, err := tm.ValidateRefreshToken(pair.RefreshToken)
+		if err != nil {
+			t.Fatalf("ValidateRefreshToken() error = %v", err)
+		}
+
+		if access.TokenID() == "" || access.TokenID() != pair.AccessTokenID {
+			t.Errorf("access jti = %q, want %q", access.TokenID(), pair.AccessTokenID)
+		}
+		if refresh.TokenID() == "" || refresh.TokenID() != pair.RefreshTokenID {
+			t.Errorf("refresh jti = %q, want %q", refresh.TokenID(), pair.RefreshTokenID)
+		}
+		for _, id := range []string{pair.AccessTokenID, pair.RefreshTokenID} {
+			if seen[id] {
+				t.Fatalf("jti %s issued twice", id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+func TestValidateAccessToken_WithoutTokenID(t *testing.T) {
+	tm := NewTokenManager(TokenConfig{SecretKey: "test-secret-key", ExpirationTime: time.Hour, Issuer: "go-service-api"})
+	now := time.Now()
+
+	// Access tokens issued before the jti claim existed
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID: uuid.New(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "go-service-api",
+			Audience:  jwt.ClaimStrings{"go-service-api-users"},
+		},
+	}).SignedString([]byte("test-secret-key"))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	claims, err := tm.ValidateAccessToken(legacy)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if claims.TokenID() != "" {
+		t.Errorf("TokenID() = %q, want none", claims.TokenID())
+	}
+}
+
+func TestCheckSession(t *testing.T) {
+	revoked := uuid.New()
+	config := TokenConfig{SecretKey: "test-secret-key", ExpirationTime: time.Hour, Issuer: "go-service-api"}
+	tm := NewTokenManager(config).WithSessionRevocations(revokedSet{revoked: true})
+
+	if err := tm.CheckSession(uuid.New()); err != nil {
+		t.Errorf("CheckSession(active) error = %v", err)
+	}
+	if err := tm.CheckSession(revoked); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession(revoked) error = %v, want ErrSessionRevoked", err)
+	}
+	if err := tm.CheckSession(uuid.Nil); err != nil {
+		t.Errorf("CheckSession(legacy) error = %v, want nil by default", err)
+	}
+
+	config.RequireSession = true
+	if err := NewTokenManager(config).CheckSession(uuid.Nil); !errors.Is(err, ErrMissingSession) {
+		t.Errorf("CheckSession(legacy) error = %v, want ErrMissingSession", err)
+	}
+}
+
+func TestTTL_PerKind(t *testing.T) {
+	ttls := map[Kind]time.Duration{
+		KindAccess:        10 * time.Minute,
+		KindRefresh:       48 * time.Hour,
+		KindMFA:           2 * time.Minute,
+		KindReset:         20 * time.Minute,
+		KindVerification:  6 * time.Hour,
+		KindImpersonation: 5 * time.Minute,
+	}
+	tm := NewTokenManager(TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  time.Hour,
+		RefreshDuration: 7 * 24 * time.Hour,
+		Issuer:          "go-service-api",
+		TTLs:            ttls,
+	})
+
+	for kind, want := range ttls {
+		if got := tm.TTL(kind); got != want {
+			t.Errorf("TTL(%s) = %v, want %v", kind, got, want)
+		}
+	}
+	if got := tm.AccessTokenTTL(); got != ttls[KindAccess] {
+		t.Errorf("AccessTokenTTL() = %v, want the per-kind TTL", got)
+	}
+
+	userID := uuid.New()
+	pair, err := tm.GenerateTokenPair(userID)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+	access, _ := tm.ValidateAccessToken(pair.AccessToken)
+	if got := access.ExpiresAt.Sub(access.IssuedAt.Time); got != ttls[KindAccess] {
+		t.Errorf("access token lifetime = %v, want %v", got, ttls[KindAccess])
+	}
+	refresh, _ := tm.ValidateRefreshToken(pair.RefreshToken)
+	if got := refresh.ExpiresAt.Sub(refresh.IssuedAt.Time); got != ttls[KindRefresh] {
+		t.Errorf("refresh token lifetime = %v, want %v", got, ttls[KindRefresh])
+	}
+
+	for _, kind := range []Kind{KindMFA, KindReset, KindVerification, KindImpersonation} {
+		raw, err := tm.GeneratePurposeToken(kind, userID)
+		if err != nil {
+			t.Fatalf("GeneratePurposeToken(%s) error = %v", kind, err)
+		}
+		claims, err := tm.ValidatePurposeToken(kind, raw)
+		if err != nil {
+			t.Fatalf("ValidatePurposeToken(%s) error = %v", kind, err)
+		}
+		if got := claims.ExpiresAt.Sub(claims.IssuedAt.Time); got != ttls[kind] {
+			t.Errorf("%s token lifetime = %v, want %v", kind, got, ttls[kind])
+		}
+	}
+}
+
+func TestTTL_Fallbacks(t *testing.T) {
+	tm := NewTokenManager(TokenConfig{SecretKey: "test-secret-key", ExpirationTime: 20 * time.Minute, Issuer: "go-service-api"})
+
+	if got := tm.TTL(KindAccess); got != 20*time.Minute {
+		t.Errorf("TTL(access) = %v, want ExpirationTime", got)
+	}
+	if got := tm.TTL(KindRefresh); got != DefaultTTLs[KindRefresh] {
+		t.Errorf("TTL(refresh) = %v, want the default", got)
+	}
+	if got := tm.TTL(KindMFA); got != DefaultTTLs[KindMFA] {
+		t.Errorf("TTL(mfa) = %v, want the default", got)
+	}
+}
+
+func TestValidatePurposeToken_WrongKind(t *testing.T) {
+	tm := NewTokenManager(TokenConfig{SecretKey: "test-secret-key", Issuer: "go-service-api"})
+
+	raw, err := tm.GeneratePurposeToken(KindMFA, uuid.New())
+	if err != nil {
+		t.Fatalf("GeneratePurposeToken() error = %v", err)
+	}
+	if _, err := tm.ValidatePurposeToken(KindImpersonation, raw); err == nil {
+		t.Error("an MFA token was accepted as an impersonation token")
+	}
+	if _, err := tm.ValidateAccessToken(raw); err == nil {
+		t.Error("an MFA token was accepted as an access token")
+	}
+	if _, err := tm.GeneratePurposeToken(KindAccess, uuid.New()); !errors.Is(err, ErrWrongKind) {
+		t.Errorf("GeneratePurposeToken(access) error = %v, want ErrWrongKind", err)
+	}
+}
+
+func TestTokenManager_FrozenClock(t *testing.T) {
+	issued := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	frozen := clock.NewFrozen(issued)
+	tm := NewTokenManager(TokenConfig{
+		SecretKey:       "test-secret-key",
+		ExpirationTime:  15 * time.Minute,
+		RefreshDuration: 24 * time.Hour,
+		Issuer:          "go-service-api",
+	}).WithClock(frozen.Now)
+
+	pair, err := tm.GenerateTokenPair(uuid.New())
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+	reset, err := tm.GeneratePurposeToken(KindReset, uuid.New())
+	if err != nil {
+		t.Fatalf("GeneratePurposeToken() error = %v", err)
+	}
+
+	claims, err := tm.ValidateAccessToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if !claims.IssuedAt.Equal(issued) || !claims.ExpiresAt.Equal(issued.Add(15*time.Minute)) {
+		t.Errorf("access token issued %v expiring %v, want %v and 15m later", claims.IssuedAt, claims.ExpiresAt, issued)
+	}
+	refresh, err := tm.ValidateRefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("ValidateRefreshToken() error = %v", err)
+	}
+	if !refresh.ExpiresAt.Equal(issued.Add(24 * time.Hour)) {
+		t.Errorf("refresh token expires %v, want 24h after issue", refresh.ExpiresAt)
+	}
+
+	// Validation runs on the same clock, so expiry is exact
+	frozen.Advance(15*time.Minute - time.Second)
+	if _, err := tm.ValidateAccessToken(pair.AccessToken); err != nil {
+		t.Errorf("access token rejected a second before expiry: %v", err)
+	}
+	frozen.Advance(time.Second)
+	if _, err := tm.ValidateAccessToken(pair.AccessToken); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("access token at expiry: error = %v, want ErrTokenExpired", err)
+	}
+
+	frozen.Set(issued.Add(DefaultTTLs[KindReset]))
+	if _, err := tm.ValidatePurposeToken(KindReset, reset); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("reset token at expiry: error = %v, want ErrTokenExpired", err)
+	}
+	if _, err := tm.ValidateRefreshToken(pair.RefreshToken); err != nil {
+		t.Errorf("refresh token rejected before expiry: %v", err)
+	}
+}
+
+func TestFreshClaim(t *testing.T) {
+	tm := NewTokenManager(TokenConfig{SecretKey: "test-secret-key", ExpirationTime: time.Hour, RefreshDuration: time.Hour, Issuer: "go-service-api"})
+
+	pair, err := tm.GenerateTokenPair(uuid.New(), WithFresh())
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+	claims, err := tm.ValidateAccessToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if !claims.Fresh {
+		t.Error("access token issued WithFresh isn't fresh")
+	}
+
+	// The refresh token doesn't carry the marker on to what it is
+	// exchanged for
+	raw := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(pair.RefreshToken, raw); err != nil {
+		t.Fatalf("ParseUnverified() error = %v", err)
+	}
+	if _, ok := raw["fresh"]; ok {
+		t.Errorf("refresh token carries a fresh claim: %v", raw["fresh"])
+	}
+
+	stale, err := tm.GenerateAccessToken(uuid.New())
+	if err != nil {
+		t.Fatalf("GenerateAccessToken() error = %v", err)
+	}
+	raw = jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(stale, raw); err != nil {
+		t.Fatalf("ParseUnverified() error = %v", err)
+	}
+	if _, ok := raw["fresh"]; ok {
+		t.Errorf("token issued without WithFresh carries a fresh claim: %v", raw["fresh"])
+	}
+}
+
+func TestTokenAge(t *testing.T) {
+	frozen := clock.NewFrozen(time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC))
+	tm := NewTokenManager(TokenConfig{SecretKey: "test-secret-key", ExpirationTime: time.Hour, Issuer: "go-service-api"}).WithClock(frozen.Now)
+
+	access, err := tm.GenerateAccessToken(uuid.New())
+	if err != nil {
+		t.Fatalf("GenerateAccessToken() error = %v", err)
+	}
+	frozen.Advance(11 * time.Minute)
+	claims, err := tm.ValidateAccessToken(access)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if age := tm.TokenAge(claims); age != 11*time.Minute {
+		t.Errorf("TokenAge() = %v, want 11m", age)
+	}
+
+	claims.IssuedAt = nil
+	if age := tm.TokenAge(claims); age < 100*365*24*time.Hour {
+		t.Errorf("TokenAge() without iat = %v, want it treated as ancient", age)
+	}
+}
+
+func TestExpiresIn(t *testing.T) {
+	frozen := clock.NewFrozen(time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC))
+	tm := NewTokenManager(TokenConfig{SecretKey: "test-secret-key", ExpirationTime: time.Hour, Issuer: "go-service-api"}).WithClock(frozen.Now)
+
+	access, err := tm.GenerateAccessToken(uuid.New())
+	if err != nil {
+		t.Fatalf("GenerateAccessToken() error = %v", err)
+	}
+	frozen.Advance(15 * time.Minute)
+	claims, err := tm.ValidateAccessToken(access)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if left := tm.ExpiresIn(claims); left != 45*time.Minute {
+		t.Errorf("ExpiresIn() = %v, want 45m", left)
+	}
+
+	frozen.Advance(2 * time.Hour)
+	if left := tm.ExpiresIn(claims); left != 0 {
+		t.Errorf("ExpiresIn() after expiry = %v, want 0", left)
+	}
+}
@@ -0,0 +1,146 @@
+package token
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"slices"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/security/authmetrics"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MaxValidationCacheTTL bounds how long a validated access token is served
+// from a ValidationCache before its signature is checked again
+const MaxValidationCacheTTL = time.Minute
+
+// ValidationCache remembers the claims of access tokens that passed
+// ValidateAccessToken, so repeated requests with the same token skip the
+// HMAC check and claims decoding. An entry lives until the token expires,
+// at most MaxValidationCacheTTL, and the least recently used entry is
+// evicted beyond the size limit. Only validation is cached: revocation
+// checks such as CheckSession still run on every request. It is safe for
+// concurrent use.
+type ValidationCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List
+	metrics *authmetrics.Metrics
+}
+
+type validationEntry struct {
+	key       [sha256.Size]byte
+	claims    Claims
+	expiresAt time.Time
+}
+
+// NewValidationCache creates a cache of at most size tokens
+func NewValidationCache(size int) *ValidationCache {
+	return &ValidationCache{
+		size:    max(size, 1),
+		entries: make(map[[sha256.Size]byte]*list.Element, size),
+		lru:     list.New(),
+		metrics: authmetrics.Default,
+	}
+}
+
+// WithMetrics replaces the metrics hits and misses are recorded on
+func (vc *ValidationCache) WithMetrics(m *authmetrics.Metrics) *ValidationCache {
+	vc.metrics = m
+	return vc
+}
+
+// Len returns the number of cached tokens
+func (vc *ValidationCache) Len() int {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	return vc.lru.Len()
+}
+
+// cacheKey keys tokens by digest, so the cache holds no usable tokens
+func cacheKey(tokenString string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(tokenString))
+}
+
+// get returns a copy of the claims cached under key if they are still
+// fresh at now
+func (vc *ValidationCache) get(key [sha256.Size]byte, now time.Time) (*Claims, bool) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	el, ok := vc.entries[key]
+	if !ok {
+		vc.metrics.TokenCacheLookup(false)
+		return nil, false
+	}
+	entry := el.Value.(*validationEntry)
+	if !now.Before(entry.expiresAt) {
+		vc.remove(el)
+		vc.metrics.TokenCacheLookup(false)
+		return nil, false
+	}
+	vc.lru.MoveToFront(el)
+	vc.metrics.TokenCacheLookup(true)
+	// Callers get their own copy, as they may be kept in request context
+	claims := cloneClaims(&entry.claims)
+	return &claims, true
+}
+
+// put caches a copy of claims under key from now until the token expires
+func (vc *ValidationCache) put(key [sha256.Size]byte, claims *Claims, now time.Time) {
+	if claims.ExpiresAt == nil {
+		return
+	}
+	expiresAt := claims.ExpiresAt.Time
+	if limit := now.Add(MaxValidationCacheTTL); limit.Before(expiresAt) {
+		expiresAt = limit
+	}
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if el, ok := vc.entries[key]; ok {
+		vc.remove(el)
+	}
+	entry := &validationEntry{key: key, claims: cloneClaims(claims), expiresAt: expiresAt}
+	vc.entries[key] = vc.lru.PushFront(entry)
+	for vc.lru.Len() > vc.size {
+		vc.remove(vc.lru.Back())
+	}
+}
+
+// cloneClaims returns a copy of c sharing no slices or dates with it
+func cloneClaims(c *Claims) Claims {
+	claims := *c
+	claims.Roles = slices.Clone(c.Roles)
+	claims.Audience = slices.Clone(c.Audience)
+	claims.ExpiresAt = cloneDate(c.ExpiresAt)
+	claims.NotBefore = cloneDate(c.NotBefore)
+	claims.IssuedAt = cloneDate(c.IssuedAt)
+	return claims
+}
+
+func cloneDate(d *jwt.NumericDate) *jwt.NumericDate {
+	if d == nil {
+		return nil
+	}
+	return jwt.NewNumericDate(d.Time)
+}
+
+// remove drops el; the caller holds mu
+func (vc *ValidationCache) remove(el *list.Element) {
+	vc.lru.Remove(el)
+	delete(vc.entries, el.Value.(*validationEntry).key)
+}
+
+// WithValidationCache makes ValidateAccessToken serve repeated tokens from
+// vc. Without one, every call verifies the token.
+func (tm *TokenManager) WithValidationCache(vc *ValidationCache) *TokenManager {
+	tm.validations = vc
+	return tm
+}
@@ -0,0 +1,230 @@
+package token
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/security/authmetrics"
+	"dvith.com/go-service-api/pkg/metrics"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// newCachingTokenManager issues tokens valid for ttl on a frozen clock and
+// validates them through a cache of size
+func newCachingTokenManager(ttl time.Duration, size int) (*TokenManager, *authmetrics.Metrics, *time.Time) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	m := authmetrics.New(metrics.NewRegistry())
+	tm := NewTokenManager(TokenConfig{
+		SecretKey:      "test-secret-key",
+		ExpirationTime: ttl,
+		Issuer:         "go-service-api",
+	}).WithClock(func() time.Time { return now }).
+		WithValidationCache(NewValidationCache(size).WithMetrics(m))
+	return tm, m, &now
+}
+
+func TestValidationCache_Hit(t *testing.T) {
+	tm, m, _ := newCachingTokenManager(time.Hour, 10)
+	userID := uuid.New()
+	token, err := tm.GenerateAccessToken(userID, WithRoles("admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := tm.ValidateAccessToken(token)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	first.UserID = uuid.New()
+	first.Roles[0] = "user"
+
+	second, err := tm.ValidateAccessToken(token)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if second.UserID != userID || !second.HasRole("admin") {
+		t.Errorf("cached claims = %+v, want the token's, unchanged by callers", second)
+	}
+	if hits, misses := m.TokenCacheLookups(true), m.TokenCacheLookups(false); hits != 1 || misses != 1 {
+		t.Errorf("hits, misses = %v, %v, want 1, 1", hits, misses)
+	}
+
+	if _, err := tm.ValidateAccessToken(token + "x"); err == nil {
+		t.Error("a tampered token must be verified, not served from the cache")
+	}
+}
+
+func TestValidationCache_CopiesClaims(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	vc := NewValidationCache(10)
+	key := cacheKey("token")
+	claims := &Claims{
+		UserID: uuid.New(),
+		Roles:  []string{"admin"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{"go-service-api"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	vc.put(key, claims, now)
+	// The caller that validated the token keeps its claims
+	claims.Audience[0] = "changed"
+	claims.ExpiresAt.Time = now.Add(48 * time.Hour)
+
+	got, ok := vc.get(key, now)
+	if !ok {
+		t.Fatal("get() missed a fresh entry")
+	}
+	got.Roles[0] = "user"
+	got.Audience[0] = "other-service"
+	got.ExpiresAt.Time = now.Add(24 * time.Hour)
+	got.NotBefore.Time = now.Add(time.Hour)
+	got.IssuedAt.Time = now.Add(time.Hour)
+
+	again, ok := vc.get(key, now)
+	if !ok {
+		t.Fatal("get() missed a fresh entry")
+	}
+	if again.Roles[0] != "admin" || again.Audience[0] != "go-service-api" {
+		t.Errorf("roles, audience = %v, %v, want the cached ones, unchanged by callers", again.Roles, again.Audience)
+	}
+	for name, d := range map[string]*jwt.NumericDate{"exp": again.ExpiresAt, "nbf": again.NotBefore, "iat": again.IssuedAt} {
+		want := now
+		if name == "exp" {
+			want = now.Add(time.Hour)
+		}
+		if !d.Equal(want) {
+			t.Errorf("%s = %v, want %v", name, d.Time, want)
+		}
+	}
+}
+
+func TestValidationCache_TokenExpiryBoundary(t *testing.T) {
+	tm, m, now := newCachingTokenManager(30*time.Second, 10)
+	token, err := tm.GenerateAccessToken(uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tm.ValidateAccessToken(token); err != nil {
+		t.Fatal(err)
+	}
+
+	*now = now.Add(30*time.Second - time.Nanosecond)
+	if _, err := tm.ValidateAccessToken(token); err != nil {
+		t.Fatalf("just before expiry: error = %v", err)
+	}
+	if m.TokenCacheLookups(true) != 1 {
+		t.Error("a token is served from the cache until it expires")
+	}
+
+	*now = now.Add(time.Nanosecond)
+	if _, err := tm.ValidateAccessToken(token); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("at expiry: error = %v, want jwt.ErrTokenExpired", err)
+	}
+}
+
+func TestValidationCache_TTLCapped(t *testing.T) {
+	tm, m, now := newCachingTokenManager(time.Hour, 10)
+	token, err := tm.GenerateAccessToken(uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tm.ValidateAccessToken(token); err != nil {
+		t.Fatal(err)
+	}
+
+	*now = now.Add(MaxValidationCacheTTL - time.Nanosecond)
+	if _, err := tm.ValidateAccessToken(token); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(time.Nanosecond)
+	if _, err := tm.ValidateAccessToken(token); err != nil {
+		t.Fatal(err)
+	}
+	if hits, misses := m.TokenCacheLookups(true), m.TokenCacheLookups(false); hits != 1 || misses != 2 {
+		t.Errorf("hits, misses = %v, %v, want the token verified again after %s", hits, misses, MaxValidationCacheTTL)
+	}
+}
+
+func TestValidationCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	tm, m, _ := newCachingTokenManager(time.Hour, 2)
+	tokens := make([]string, 3)
+	for i := range tokens {
+		var err error
+		if tokens[i], err = tm.GenerateAccessToken(uuid.New()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tm.ValidateAccessToken(tokens[0])
+	tm.ValidateAccessToken(tokens[1])
+	tm.ValidateAccessToken(tokens[0])
+	tm.ValidateAccessToken(tokens[2])
+	if n := tm.validations.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want 2", n)
+	}
+
+	before := m.TokenCacheLookups(true)
+	tm.ValidateAccessToken(tokens[0])
+	tm.ValidateAccessToken(tokens[1])
+	if hits := m.TokenCacheLookups(true) - before; hits != 1 {
+		t.Errorf("hits = %v, want only the recently used token still cached", hits)
+	}
+}
+
+func TestValidationCache_RevokedSessionStillChecked(t *testing.T) {
+	tm, _, _ := newCachingTokenManager(time.Hour, 10)
+	sessionID := uuid.New()
+	tm.WithSessionRevocations(revokedSet{})
+	token, err := tm.GenerateSessionAccessToken(uuid.New(), sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := tm.ValidateAccessToken(token)
+	if err != nil || tm.CheckSession(claims.SessionID) != nil {
+		t.Fatalf("fresh token rejected: %v", err)
+	}
+
+	tm.WithSessionRevocations(revokedSet{sessionID: true})
+	claims, err = tm.ValidateAccessToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.CheckSession(claims.SessionID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession() = %v, want ErrSessionRevoked for a cached token", err)
+	}
+}
+
+func TestValidationCache_Concurrent(t *testing.T) {
+	tm, _, _ := newCachingTokenManager(time.Hour, 8)
+	tokens := make([]string, 16)
+	for i := range tokens {
+		var err error
+		if tokens[i], err = tm.GenerateAccessToken(uuid.New()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				if _, err := tm.ValidateAccessToken(tokens[(g+i)%len(tokens)]); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := tm.validations.Len(); n > 8 {
+		t.Errorf("Len() = %d, want at most 8", n)
+	}
+}
@@ -0,0 +1,235 @@
+// Package seed fills a development database with realistic fake data
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/crypto"
+	"dvith.com/go-service-api/pkg/database"
+)
+
+// ErrProduction is returned when the seeder is pointed at production
+var ErrProduction = errors.New("refusing to seed a production environment")
+
+// Known test accounts. Their passwords are reset on every run so the
+// documented credentials always work.
+const (
+	UserEmail     = "user@example.com"
+	UserPassword  = "UserPass123!"
+	AdminEmail    = "admin@example.com"
+	AdminPassword = "AdminPass123!"
+	// FakePassword is shared by every generated user
+	FakePassword = "SeedPass123!"
+)
+
+// DefaultUsers is how many fake users are generated when Options.Users is
+// not set
+const DefaultUsers = 25
+
+// DB is where the seeder writes
+type DB struct {
+	Users    model.Store
+	Sessions session.Store
+	// Audit records audit events; nil skips them, as the in-memory stores
+	// don't keep any
+	Audit func(ctx context.Context, e audit.Entry) error
+}
+
+// PgDB seeds the Postgres database
+func PgDB(db *database.DBPool) DB {
+	return DB{
+		Users:    model.NewUserRepository(db),
+		Sessions: session.NewRepository(db),
+		Audit: func(ctx context.Context, e audit.Entry) error {
+			return audit.Record(ctx, db, e)
+		},
+	}
+}
+
+// Options control what SeedDev generates
+type Options struct {
+	// Env is the deployment environment; production is refused
+	Env string
+	// Users is how many fake users to generate besides the test accounts
+	Users int
+	// Seed makes the fake data reproducible. Generated emails depend only
+	// on the user's number, so reruns find the users they made before.
+	Seed uint64
+}
+
+// Summary counts what a run wrote
+type Summary struct {
+	Created     int
+	Existing    int
+	Sessions    int
+	AuditEvents int
+}
+
+// SeedDev creates the test accounts and opts.Users fake users in the
+// tenant of ctx. Users are upserted by email: one that already exists is
+// left as it is, apart from the test accounts' passwords and roles, and
+// gets no new sessions or audit events, so repeated runs don't duplicate
+// anything.
+func SeedDev(ctx context.Context, db DB, opts Options) (*Summary, error) {
+	if strings.EqualFold(opts.Env, "production") {
+		return nil, ErrProduction
+	}
+	if opts.Users <= 0 {
+		opts.Users = DefaultUsers
+	}
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+
+	// One hash for every fake user keeps large runs fast
+	fakeHash, err := hashpassword.HashPassword(FakePassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash seed password: %w", err)
+	}
+
+	accounts := []account{
+		{user: model.User{Email: UserEmail, FullName: "Test User", Username: "testuser", Role: "user", EmailVerified: true}, password: UserPassword},
+		{user: model.User{Email: AdminEmail, FullName: "Test Admin", Username: "testadmin", Role: "admin", EmailVerified: true}, password: AdminPassword},
+	}
+	for i := 1; i <= opts.Users; i++ {
+		first, last := firstNames[rng.IntN(len(firstNames))], lastNames[rng.IntN(len(lastNames))]
+		accounts = append(accounts, account{
+			user: model.User{
+				Email:    fakeEmail(i),
+				FullName: crypto.EncryptedString(first + " " + last),
+				Username: fmt.Sprintf("%s%s%03d", strings.ToLower(first), strings.ToLower(last[:1]), i),
+				Role:     "user",
+				// Every third fake user hasn't verified their email
+				EmailVerified: i%3 != 0,
+			},
+			hash:     fakeHash,
+			sessions: 1 + rng.IntN(3),
+		})
+	}
+	// The test accounts get one session each
+	accounts[0].sessions, accounts[1].sessions = 1, 1
+
+	var sum Summary
+	for _, a := range accounts {
+		if err := a.seed(ctx, db, rng, &sum); err != nil {
+			return &sum, fmt.Errorf("failed to seed %s: %w", a.user.Email, err)
+		}
+	}
+	return &sum, nil
+}
+
+// account is one user to seed
+type account struct {
+	user     model.User
+	password string // known password, hashed on every run
+	hash     string // shared hash, when there is no known password
+	sessions int
+}
+
+func (a account) seed(ctx context.Context, db DB, rng *rand.Rand, sum *Summary) error {
+	hash := a.hash
+	if a.password != "" {
+		h, err := hashpassword.HashPassword(a.password)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+		hash = h
+	}
+
+	existing, err := db.Users.FindByEmail(ctx, a.user.Email, model.ActiveOnly)
+	if err == nil {
+		sum.Existing++
+		if a.password == "" {
+			return nil
+		}
+		if err := db.Users.SetPassword(ctx, existing.ID, hash); err != nil {
+			return err
+		}
+		return db.Users.SetRole(ctx, existing.ID, a.user.Role)
+	}
+	if !errors.Is(err, model.ErrUserNotFound) {
+		return err
+	}
+
+	user := a.user
+	user.Password = hash
+	user.IsActive = true
+	if user.EmailVerified {
+		verifiedAt := clock.Now()
+		user.VerifiedAt = &verifiedAt
+	}
+	saved, err := db.Users.SaveUser(ctx, &user)
+	if err != nil {
+		return err
+	}
+	if a.user.Role != "user" {
+		if err := db.Users.SetRole(ctx, saved.ID, a.user.Role); err != nil {
+			return err
+		}
+	}
+	sum.Created++
+	if err := sum.record(ctx, db, audit.Entry{UserID: saved.ID, Action: audit.ActionUserRegistered}); err != nil {
+		return err
+	}
+
+	for range a.sessions {
+		ip, agent := fakeIP(rng), userAgents[rng.IntN(len(userAgents))]
+		if err := db.Sessions.Create(ctx, &session.Session{UserID: saved.ID, IP: ip, UserAgent: agent}); err != nil {
+			return err
+		}
+		sum.Sessions++
+		if err := sum.record(ctx, db, audit.Entry{UserID: saved.ID, Action: audit.ActionUserSignedIn, IP: ip, UserAgent: agent}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sum *Summary) record(ctx context.Context, db DB, e audit.Entry) error {
+	if db.Audit == nil {
+		return nil
+	}
+	if err := db.Audit(ctx, e); err != nil {
+		return err
+	}
+	sum.AuditEvents++
+	return nil
+}
+
+// fakeEmail is the email of the i-th fake user
+func fakeEmail(i int) string {
+	return fmt.Sprintf("seed.user%03d@example.com", i)
+}
+
+// fakeIP returns an address from the documentation ranges of RFC 5737
+func fakeIP(rng *rand.Rand) string {
+	prefixes := []string{"192.0.2", "198.51.100", "203.0.113"}
+	return fmt.Sprintf("%s.%d", prefixes[rng.IntN(len(prefixes))], 1+rng.IntN(254))
+}
+
+var firstNames = []string{
+	"Somchai", "Anong", "Niran", "Pim", "Kittisak", "Malee", "James", "Olivia",
+	"Noah", "Emma", "Liam", "Sophia", "Haruto", "Yui", "Mateo", "Valentina",
+	"Arjun", "Priya", "Lucas", "Chloe", "Minh", "Linh", "Omar", "Layla",
+}
+
+var lastNames = []string{
+	"Srisuk", "Chaiyaporn", "Wongsa", "Thongchai", "Smith", "Johnson", "Brown",
+	"Garcia", "Tanaka", "Sato", "Nguyen", "Tran", "Patel", "Sharma", "Silva",
+	"Rossi", "Müller", "Dubois", "Kim", "Hassan",
+}
+
+var userAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+	"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36",
+	"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0",
+}
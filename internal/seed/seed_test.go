@@ -0,0 +1,94 @@
+package seed
+
+import (
+	"context"
+	"testing"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
+	"dvith.com/go-service-api/internal/session"
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func memoryDB() (DB, *[]audit.Entry) {
+	var events []audit.Entry
+	return DB{
+		Users:    model.NewMemoryStore(),
+		Sessions: session.NewMemoryStore(),
+		Audit: func(ctx context.Context, e audit.Entry) error {
+			events = append(events, e)
+			return nil
+		},
+	}, &events
+}
+
+func TestSeedDev(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	db, events := memoryDB()
+
+	sum, err := SeedDev(ctx, db, Options{Env: "development", Users: 6})
+	require.NoError(t, err)
+	assert.Equal(t, 8, sum.Created, "six fake users and the two test accounts")
+	assert.Zero(t, sum.Existing)
+	assert.GreaterOrEqual(t, sum.Sessions, 8)
+	assert.Equal(t, sum.Created+sum.Sessions, sum.AuditEvents, "a registration per user and a signin per session")
+	assert.Len(t, *events, sum.AuditEvents)
+
+	admin, err := db.Users.FindByEmail(ctx, AdminEmail, model.ActiveOnly)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", admin.Role)
+	assert.True(t, hashpassword.CheckPassword(AdminPassword, admin.Password))
+	user, err := db.Users.FindByEmail(ctx, UserEmail, model.ActiveOnly)
+	require.NoError(t, err)
+	assert.Equal(t, "user", user.Role)
+	assert.True(t, hashpassword.CheckPassword(UserPassword, user.Password))
+
+	verified := 0
+	for i := 1; i <= 6; i++ {
+		u, err := db.Users.FindByEmail(ctx, fakeEmail(i), model.ActiveOnly)
+		require.NoError(t, err)
+		if u.EmailVerified {
+			verified++
+		}
+	}
+	assert.Equal(t, 4, verified, "a mix of verified and unverified users")
+
+	sessions, err := db.Sessions.ListRecent(ctx, admin.ID, 10)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+func TestSeedDev_Idempotent(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	db, events := memoryDB()
+
+	first, err := SeedDev(ctx, db, Options{Users: 5})
+	require.NoError(t, err)
+	recorded := len(*events)
+
+	second, err := SeedDev(ctx, db, Options{Users: 5})
+	require.NoError(t, err)
+	assert.Zero(t, second.Created)
+	assert.Equal(t, first.Created, second.Existing)
+	assert.Zero(t, second.Sessions)
+	assert.Len(t, *events, recorded, "no new audit events")
+
+	// Growing the count only adds the new users
+	third, err := SeedDev(ctx, db, Options{Users: 7})
+	require.NoError(t, err)
+	assert.Equal(t, 2, third.Created)
+}
+
+func TestSeedDev_RefusesProduction(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	db, _ := memoryDB()
+
+	_, err := SeedDev(ctx, db, Options{Env: "Production"})
+	assert.ErrorIs(t, err, ErrProduction)
+	_, err = db.Users.FindByEmail(ctx, UserEmail, model.ActiveOnly)
+	assert.ErrorIs(t, err, model.ErrUserNotFound)
+}
@@ -0,0 +1,147 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"dvith.com/go-service-api/internal/audit"
+	"dvith.com/go-service-api/internal/errs"
+	"dvith.com/go-service-api/internal/queries"
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// EvictionPolicy decides what happens when a user at the session limit
+// signs in again
+type EvictionPolicy string
+
+// Eviction policies
+const (
+	// EvictOldest revokes the least recently used sessions to make room
+	EvictOldest EvictionPolicy = "oldest"
+	// DenyNew rejects the new signin with ErrSessionLimit
+	DenyNew EvictionPolicy = "deny"
+)
+
+// ErrSessionLimit is returned under DenyNew when the user already has the
+// maximum number of active sessions
+var ErrSessionLimit = errors.New("maximum number of active sessions reached, sign out elsewhere first")
+
+func init() {
+	errs.Register(errs.Translation{
+		Name:   "session_limit",
+		Match:  func(err error) bool { return errors.Is(err, ErrSessionLimit) },
+		Status: http.StatusConflict,
+		Code:   "conflict",
+	})
+}
+
+// Limit caps a user's active, unrevoked sessions. A Max of 0 means no cap;
+// an empty Policy means EvictOldest.
+type Limit struct {
+	Max    int
+	Policy EvictionPolicy
+}
+
+// ActionSessionEvicted is the audit action recorded for every session
+// revoked to make room under a Limit
+const ActionSessionEvicted = "session.evicted"
+
+// beginner starts a transaction, or a savepoint when already inside one
+type beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// CreateLimited creates s like Create, first enforcing limit on the user's
+// active sessions. It returns the IDs of the sessions it evicted.
+//
+// Everything runs in one transaction holding a per-user advisory lock, so
+// parallel signins of the same user can't both see room for one more
+// session. Evicted sessions are revoked with their refresh token families
+// and an audit event each, and announced on RevokedChannel like Revoke.
+func (repo *Repository) CreateLimited(ctx context.Context, s *Session, limit Limit) ([]uuid.UUID, error) {
+	if limit.Max <= 0 {
+		return nil, repo.Create(ctx, s)
+	}
+	if s == nil {
+		return nil, fmt.Errorf("session cannot be nil")
+	}
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := repo.q.(beginner)
+	if !ok {
+		return nil, fmt.Errorf("session limit needs a database that can begin transactions")
+	}
+
+	var evicted []uuid.UUID
+	err = pgx.BeginFunc(ctx, b, func(tx pgx.Tx) error {
+		evicted = nil
+		if _, err := tx.Exec(ctx, queries.SessionLockUser.SQL, s.UserID.String()); err != nil {
+			return fmt.Errorf("failed to lock user sessions: %w", err)
+		}
+
+		active, err := activeSessions(ctx, tx, tenantID, s.UserID)
+		if err != nil {
+			return err
+		}
+		if excess := len(active) - limit.Max + 1; excess > 0 {
+			if limit.Policy == DenyNew {
+				return ErrSessionLimit
+			}
+			evicted = active[:excess]
+			if err := evict(ctx, tx, s.UserID, evicted, repo.now()); err != nil {
+				return err
+			}
+		}
+
+		return NewRepository(tx).WithClock(repo.now).Create(ctx, s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return evicted, nil
+}
+
+// activeSessions lists the IDs of the user's unrevoked sessions, least
+// recently used first
+func activeSessions(ctx context.Context, tx pgx.Tx, tenantID, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := tx.Query(ctx, queries.SessionListActive.SQL, tenantID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active sessions: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active sessions: %w", err)
+	}
+	return ids, nil
+}
+
+// evict revokes the sessions and their refresh token families and audits
+// each one
+func evict(ctx context.Context, tx pgx.Tx, userID uuid.UUID, ids []uuid.UUID, now time.Time) error {
+	rows, err := tx.Query(ctx, queries.SessionEvict.SQL, ids, now, RevokedChannel)
+	if err != nil {
+		return fmt.Errorf("failed to evict sessions: %w", err)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to evict sessions: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := audit.Record(ctx, tx, audit.Entry{
+			UserID:   userID,
+			Action:   ActionSessionEvicted,
+			Metadata: map[string]any{"session_id": id.String(), "reason": "max_sessions"},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func activeIDs(t *testing.T, ctx context.Context, m *MemoryStore, userID uuid.UUID) []uuid.UUID {
+	t.Helper()
+	sessions, err := m.ListRecent(ctx, userID, 100)
+	require.NoError(t, err)
+	var ids []uuid.UUID
+	for _, s := range sessions {
+		if s.RevokedAt == nil {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids
+}
+
+func TestMemoryStore_CreateLimited_EvictOldest(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	m := NewMemoryStore()
+	userID := uuid.New()
+	limit := Limit{Max: 2, Policy: EvictOldest}
+
+	var created []uuid.UUID
+	for range 2 {
+		s := &Session{UserID: userID}
+		evicted, err := m.CreateLimited(ctx, s, limit)
+		require.NoError(t, err)
+		assert.Empty(t, evicted)
+		created = append(created, s.ID)
+		time.Sleep(time.Millisecond)
+	}
+
+	// Another user's sessions don't count
+	_, err := m.CreateLimited(ctx, &Session{UserID: uuid.New()}, limit)
+	require.NoError(t, err)
+
+	third := &Session{UserID: userID}
+	evicted, err := m.CreateLimited(ctx, third, limit)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{created[0]}, evicted, "the least recently used session goes")
+	assert.ElementsMatch(t, []uuid.UUID{created[1], third.ID}, activeIDs(t, ctx, m, userID))
+	assert.True(t, m.IsRevoked(created[0]))
+}
+
+func TestMemoryStore_CreateLimited_DenyNew(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	m := NewMemoryStore()
+	userID := uuid.New()
+	limit := Limit{Max: 1, Policy: DenyNew}
+
+	first := &Session{UserID: userID}
+	_, err := m.CreateLimited(ctx, first, limit)
+	require.NoError(t, err)
+
+	_, err = m.CreateLimited(ctx, &Session{UserID: userID}, limit)
+	assert.ErrorIs(t, err, ErrSessionLimit)
+	assert.Equal(t, []uuid.UUID{first.ID}, activeIDs(t, ctx, m, userID))
+
+	// Signing out elsewhere makes room again
+	require.NoError(t, m.Revoke(ctx, userID, first.ID))
+	_, err = m.CreateLimited(ctx, &Session{UserID: userID}, limit)
+	assert.NoError(t, err)
+}
+
+func TestMemoryStore_CreateLimited_Unlimited(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), uuid.New())
+	m := NewMemoryStore()
+	userID := uuid.New()
+
+	for range 5 {
+		_, err := m.CreateLimited(ctx, &Session{UserID: userID}, Limit{})
+		require.NoError(t, err)
+	}
+	assert.Len(t, activeIDs(t, ctx, m, userID), 5)
+}
+
+func TestMemoryStore_CreateLimited_ConcurrentSignins(t *testing.T) {
+	for _, policy := range []EvictionPolicy{EvictOldest, DenyNew} {
+		t.Run(string(policy), func(t *testing.T) {
+			ctx := tenant.WithID(context.Background(), uuid.New())
+			m := NewMemoryStore()
+			userID := uuid.New()
+			limit := Limit{Max: 1, Policy: policy}
+
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for i := range errs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, errs[i] = m.CreateLimited(ctx, &Session{UserID: userID}, limit)
+				}()
+			}
+			wg.Wait()
+
+			assert.Len(t, activeIDs(t, ctx, m, userID), 1, "parallel signins never overshoot the limit")
+			if policy == DenyNew {
+				assert.ElementsMatch(t, []bool{true, false}, []bool{errs[0] == nil, errs[1] == nil}, "exactly one signin wins")
+			} else {
+				assert.NoError(t, errs[0])
+				assert.NoError(t, errs[1])
+			}
+		})
+	}
+}
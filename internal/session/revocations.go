@@ -0,0 +1,115 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// RevokedChannel is the Postgres NOTIFY channel Revoke publishes session
+// IDs on
+const RevokedChannel = "session_revoked"
+
+// revokedPrefix namespaces revocation entries in the shared cache
+const revokedPrefix = "session_revoked:"
+
+// Revocations remembers recently revoked sessions so AuthMiddleware can
+// reject their access tokens without a database query. Entries only need
+// to outlive the longest access token, after which the tokens are expired
+// anyway.
+type Revocations struct {
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// NewRevocations keeps revocations in c for ttl, the longest access token
+// lifetime
+func NewRevocations(c cache.Cache, ttl time.Duration) *Revocations {
+	return &Revocations{cache: c, ttl: ttl}
+}
+
+// IsRevoked reports whether sessionID was revoked within the last ttl
+func (r *Revocations) IsRevoked(sessionID uuid.UUID) bool {
+	_, ok := r.cache.Get(revokedPrefix + sessionID.String())
+	return ok
+}
+
+// MarkRevoked records sessionID as revoked on this instance
+func (r *Revocations) MarkRevoked(sessionID uuid.UUID) {
+	r.cache.Set(revokedPrefix+sessionID.String(), true, r.ttl)
+}
+
+// Load fills the cache with sessions revoked within the last ttl, across
+// all tenants, and returns how many it found
+func (r *Revocations) Load(ctx context.Context, q database.Querier) (int, error) {
+	since := clock.Now().Add(-r.ttl)
+	rows, err := q.Query(ctx, `SELECT id FROM sessions WHERE revoked_at > $1`, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load revoked sessions: %w", err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return n, fmt.Errorf("failed to scan revoked session: %w", err)
+		}
+		r.MarkRevoked(id)
+		n++
+	}
+	return n, rows.Err()
+}
+
+// Listen loads the recent revocations and then follows RevokedChannel
+// until ctx ends. A dropped connection is retried after a pause and the
+// revocations are reloaded, so notifications missed meanwhile still land.
+func (r *Revocations) Listen(ctx context.Context, db *database.DBPool) {
+	for ctx.Err() == nil {
+		if n, err := r.Load(ctx, db); err != nil {
+			logger.Warn("could not load revoked sessions", map[string]any{"error": err.Error()})
+		} else {
+			logger.Debug("revoked sessions loaded", map[string]any{"count": n})
+		}
+
+		if err := r.listen(ctx, db); err != nil && ctx.Err() == nil {
+			logger.Warn("session revocation listener stopped, retrying", map[string]any{"error": err.Error()})
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (r *Revocations) listen(ctx context.Context, db *database.DBPool) error {
+	conn, err := db.GetPool().Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+RevokedChannel); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		id, err := uuid.Parse(n.Payload)
+		if err != nil {
+			logger.Warn("ignoring malformed session revocation", map[string]any{"payload": n.Payload})
+			continue
+		}
+		r.MarkRevoked(id)
+	}
+}
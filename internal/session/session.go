@@ -0,0 +1,207 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/internal/queries"
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/internal/useragent"
+	"dvith.com/go-service-api/pkg/clock"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+)
+
+// ErrSessionNotFound is returned when the session doesn't exist or belongs
+// to another user
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session represents a row in the sessions table
+type Session struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	TenantID    uuid.UUID  `db:"tenant_id" json:"-"`
+	UserID      uuid.UUID  `db:"user_id" json:"-"`
+	IP          string     `db:"ip" json:"ip,omitempty"`
+	UserAgent   string     `db:"user_agent" json:"user_agent,omitempty"`
+	Browser     string     `db:"browser" json:"browser"`
+	OS          string     `db:"os" json:"os"`
+	DeviceType  string     `db:"device_type" json:"device_type"`
+	Fingerprint string     `db:"fingerprint" json:"-"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	LastUsedAt  time.Time  `db:"last_used_at" json:"last_used_at"`
+	RevokedAt   *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+// Fingerprint identifies the device and network a signin came from. Two
+// signins with the same user agent from the same IP share a fingerprint.
+func Fingerprint(userAgent, ip string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(userAgent) + "\x00" + strings.TrimSpace(ip)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Repository reads and writes sessions in the current tenant
+type Repository struct {
+	q   database.Querier
+	now func() time.Time
+}
+
+// NewRepository creates a session repository on q
+func NewRepository(q database.Querier) *Repository {
+	return &Repository{q: q, now: clock.Now}
+}
+
+// WithClock replaces the time source for timestamps, for tests
+func (repo *Repository) WithClock(now func() time.Time) *Repository {
+	repo.now = now
+	return repo
+}
+
+// Create inserts s, filling in its ID, tenant, fingerprint, parsed user
+// agent and timestamps
+func (repo *Repository) Create(ctx context.Context, s *Session) error {
+	if err := fill(ctx, s, repo.now()); err != nil {
+		return err
+	}
+
+	if _, err := repo.q.Exec(ctx, queries.SessionInsert.SQL, s.ID, s.TenantID, s.UserID, nullable(s.IP), nullable(s.UserAgent), s.Browser, s.OS, s.DeviceType, s.Fingerprint, s.CreatedAt, s.LastUsedAt); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+// ListRecent returns up to limit of the user's sessions, most recently used
+// first
+func (repo *Repository) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]Session, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := database.QueryAll[Session](ctx, repo.q, queries.SessionListRecent.SQL, tenantID, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// StreamByUser calls fn for each of the user's sessions, revoked ones
+// included, oldest first. Rows are iterated rather than collected.
+func (repo *Repository) StreamByUser(ctx context.Context, userID uuid.UUID, fn func(Session) error) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := repo.q.Query(ctx, queries.SessionStreamByUser.SQL, tenantID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s, err := database.ScanRow[Session](rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan session: %w", err)
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Revoke marks the user's session and its refresh tokens revoked and
+// notifies RevokedChannel so every instance drops the session's access
+// tokens. The refresh tokens stay revoked after the instances forget the
+// session. Revoking an already revoked session is not an error.
+func (repo *Repository) Revoke(ctx context.Context, userID, sessionID uuid.UUID) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := repo.q.Query(ctx, queries.SessionRevoke.SQL, sessionID, userID, tenantID, repo.now(), RevokedChannel)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	defer rows.Close()
+
+	found := rows.Next()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeAll revokes every active session of the user along with all of
+// the user's refresh tokens, notifying RevokedChannel like Revoke, and
+// returns the revoked session IDs
+func (repo *Repository) RevokeAll(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.q.Query(ctx, queries.SessionRevokeByUser.SQL, tenantID, userID, repo.now(), RevokedChannel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id, nil); err != nil {
+			return nil, fmt.Errorf("failed to scan revoked session: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return ids, nil
+}
+
+// DeleteByUser removes every session belonging to the user
+func (repo *Repository) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	if _, err := repo.q.Exec(ctx, queries.SessionDeleteByUser.SQL, userID); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return nil
+}
+
+// fill sets the fields Create fills in on a new session created at now
+func fill(ctx context.Context, s *Session, now time.Time) error {
+	if s == nil {
+		return fmt.Errorf("session cannot be nil")
+	}
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.ID = uuid.New()
+	s.TenantID = tenantID
+	s.Fingerprint = Fingerprint(s.UserAgent, s.IP)
+	agent := useragent.Parse(s.UserAgent)
+	s.Browser, s.OS, s.DeviceType = agent.Browser, agent.OS, agent.Device
+	s.CreatedAt = now
+	s.LastUsedAt = now
+	return nil
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,192 @@
+package session
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/internal/tenant"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/google/uuid"
+)
+
+// Store is the session persistence contract shared by Repository and
+// MemoryStore
+type Store interface {
+	Create(ctx context.Context, s *Session) error
+	ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]Session, error)
+	Revoke(ctx context.Context, userID, sessionID uuid.UUID) error
+	RevokeAll(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	// CreateLimited is Create enforcing limit atomically, returning the
+	// IDs of the sessions evicted to make room
+	CreateLimited(ctx context.Context, s *Session, limit Limit) ([]uuid.UUID, error)
+	DeleteByUser(ctx context.Context, userID uuid.UUID) error
+}
+
+var (
+	_ Store = (*Repository)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// MemoryStore keeps sessions in memory for development mode and tests
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions []Session
+	now      func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory session store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{now: clock.Now}
+}
+
+// WithClock replaces the time source for timestamps, for tests
+func (m *MemoryStore) WithClock(now func() time.Time) *MemoryStore {
+	m.now = now
+	return m
+}
+
+// Create implements Store, filling in the same fields as Repository.Create
+func (m *MemoryStore) Create(ctx context.Context, s *Session) error {
+	if err := fill(ctx, s, m.now()); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.sessions = append(m.sessions, *s)
+	m.mu.Unlock()
+	return nil
+}
+
+// CreateLimited implements Store. The store's mutex covers both the check
+// and the insert, like the advisory lock of Repository.CreateLimited.
+func (m *MemoryStore) CreateLimited(ctx context.Context, s *Session, limit Limit) ([]uuid.UUID, error) {
+	if limit.Max <= 0 {
+		return nil, m.Create(ctx, s)
+	}
+	if err := fill(ctx, s, m.now()); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var active []int
+	for i, existing := range m.sessions {
+		if existing.TenantID == s.TenantID && existing.UserID == s.UserID && existing.RevokedAt == nil {
+			active = append(active, i)
+		}
+	}
+
+	var evicted []uuid.UUID
+	if excess := len(active) - limit.Max + 1; excess > 0 {
+		if limit.Policy == DenyNew {
+			return nil, ErrSessionLimit
+		}
+		sort.SliceStable(active, func(i, j int) bool {
+			return m.sessions[active[i]].LastUsedAt.Before(m.sessions[active[j]].LastUsedAt)
+		})
+		now := m.now()
+		for _, i := range active[:excess] {
+			m.sessions[i].RevokedAt = &now
+			evicted = append(evicted, m.sessions[i].ID)
+		}
+	}
+
+	m.sessions = append(m.sessions, *s)
+	return evicted, nil
+}
+
+// ListRecent implements Store
+func (m *MemoryStore) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]Session, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	var out []Session
+	for _, s := range m.sessions {
+		if s.TenantID == tenantID && s.UserID == userID {
+			out = append(out, s)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].LastUsedAt.After(out[j].LastUsedAt)
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// Revoke implements Store. There is no other instance to notify.
+func (m *MemoryStore) Revoke(ctx context.Context, userID, sessionID uuid.UUID) error {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, s := range m.sessions {
+		if s.ID == sessionID && s.UserID == userID && s.TenantID == tenantID {
+			if s.RevokedAt == nil {
+				now := m.now()
+				m.sessions[i].RevokedAt = &now
+			}
+			return nil
+		}
+	}
+	return ErrSessionNotFound
+}
+
+// RevokeAll implements Store
+func (m *MemoryStore) RevokeAll(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var ids []uuid.UUID
+	for i, s := range m.sessions {
+		if s.UserID == userID && s.TenantID == tenantID && s.RevokedAt == nil {
+			m.sessions[i].RevokedAt = &now
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids, nil
+}
+
+// IsRevoked reports whether the session exists and has been revoked
+func (m *MemoryStore) IsRevoked(sessionID uuid.UUID) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, s := range m.sessions {
+		if s.ID == sessionID {
+			return s.RevokedAt != nil
+		}
+	}
+	return false
+}
+
+// DeleteByUser implements Store
+func (m *MemoryStore) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.sessions[:0]
+	for _, s := range m.sessions {
+		if s.UserID != userID {
+			kept = append(kept, s)
+		}
+	}
+	m.sessions = kept
+	return nil
+}
@@ -0,0 +1,59 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTenantNotFound is returned when no active tenant matches the lookup
+	ErrTenantNotFound = errors.New("tenant not found")
+	// ErrNoTenant is returned by tenant-scoped repositories when the
+	// context does not carry a resolved tenant
+	ErrNoTenant = errors.New("no tenant in context")
+)
+
+// Tenant represents a brand hosted on this deployment
+type Tenant struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	Slug      string    `db:"slug" json:"slug"`
+	Name      string    `db:"name" json:"name"`
+	Host      *string   `db:"host" json:"host,omitempty"`
+	IsDefault bool      `db:"is_default" json:"is_default"`
+	IsActive  bool      `db:"is_active" json:"is_active"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Store looks tenants up for the resolver middleware
+type Store interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*Tenant, error)
+	FindByHost(ctx context.Context, host string) (*Tenant, error)
+	FindDefault(ctx context.Context) (*Tenant, error)
+}
+
+type contextKey struct{}
+
+// WithID returns a copy of ctx carrying the tenant ID
+func WithID(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// IDFromContext returns the tenant ID stored in ctx
+func IDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(contextKey{}).(uuid.UUID)
+	return id, ok && id != uuid.Nil
+}
+
+// RequireID returns the tenant ID stored in ctx or ErrNoTenant. Repositories
+// call it before every query so an unscoped query can never run.
+func RequireID(ctx context.Context) (uuid.UUID, error) {
+	id, ok := IDFromContext(ctx)
+	if !ok {
+		return uuid.Nil, ErrNoTenant
+	}
+	return id, nil
+}
@@ -0,0 +1,69 @@
+package tenant
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/google/uuid"
+)
+
+// MemoryStore is a Store holding a fixed set of tenants, for development
+// mode and tests
+type MemoryStore struct {
+	mu      sync.RWMutex
+	tenants []Tenant
+}
+
+// NewMemoryStore creates a store with a single default tenant
+func NewMemoryStore() *MemoryStore {
+	now := clock.Now()
+	return &MemoryStore{tenants: []Tenant{{
+		ID:        uuid.New(),
+		Slug:      "default",
+		Name:      "Default",
+		IsDefault: true,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}}
+}
+
+// Add registers another tenant
+func (m *MemoryStore) Add(t Tenant) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tenants = append(m.tenants, t)
+}
+
+// FindByID implements Store
+func (m *MemoryStore) FindByID(ctx context.Context, id uuid.UUID) (*Tenant, error) {
+	return m.find(func(t Tenant) bool { return t.ID == id })
+}
+
+// FindByHost implements Store
+func (m *MemoryStore) FindByHost(ctx context.Context, host string) (*Tenant, error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if host == "" {
+		return nil, ErrTenantNotFound
+	}
+	return m.find(func(t Tenant) bool { return t.Host != nil && *t.Host == host })
+}
+
+// FindDefault implements Store
+func (m *MemoryStore) FindDefault(ctx context.Context) (*Tenant, error) {
+	return m.find(func(t Tenant) bool { return t.IsDefault })
+}
+
+func (m *MemoryStore) find(match func(Tenant) bool) (*Tenant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, t := range m.tenants {
+		if t.IsActive && match(t) {
+			out := t
+			return &out, nil
+		}
+	}
+	return nil, ErrTenantNotFound
+}
@@ -0,0 +1,78 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/pkg/cache"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// cacheTTL bounds how long tenant lookups are served from memory
+const cacheTTL = time.Minute
+
+// Repository loads tenants from the database with a short-lived cache,
+// since the resolver runs on every tenant-scoped request
+type Repository struct {
+	db    *database.DBPool
+	cache cache.Cache
+}
+
+// NewRepository creates a new tenant repository
+func NewRepository(db *database.DBPool) *Repository {
+	return &Repository{
+		db:    db,
+		cache: cache.NewMemory(),
+	}
+}
+
+const selectTenant = `
+	SELECT id, slug, name, host, is_default, is_active, created_at, updated_at
+	FROM tenants
+`
+
+// FindByID returns the active tenant with the given ID
+func (repo *Repository) FindByID(ctx context.Context, id uuid.UUID) (*Tenant, error) {
+	return repo.find(ctx, "id:"+id.String(), selectTenant+" WHERE is_active = true AND id = $1", id)
+}
+
+// FindByHost returns the active tenant mapped to the given host name
+func (repo *Repository) FindByHost(ctx context.Context, host string) (*Tenant, error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if host == "" {
+		return nil, ErrTenantNotFound
+	}
+	return repo.find(ctx, "host:"+host, selectTenant+" WHERE is_active = true AND host = $1", host)
+}
+
+// FindDefault returns the tenant used when a request names no tenant
+func (repo *Repository) FindDefault(ctx context.Context) (*Tenant, error) {
+	return repo.find(ctx, "default", selectTenant+" WHERE is_active = true AND is_default = true")
+}
+
+func (repo *Repository) find(ctx context.Context, key, query string, args ...any) (*Tenant, error) {
+	if v, ok := repo.cache.Get(key); ok {
+		if v == nil {
+			return nil, ErrTenantNotFound
+		}
+		return v.(*Tenant), nil
+	}
+
+	t, err := database.QueryOne[Tenant](ctx, repo.db, query, args...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Cache misses too, so unknown hosts can't hammer the database.
+			repo.cache.Set(key, nil, cacheTTL)
+			return nil, ErrTenantNotFound
+		}
+		return nil, fmt.Errorf("failed to find tenant: %w", err)
+	}
+
+	repo.cache.Set(key, t, cacheTTL)
+	return t, nil
+}
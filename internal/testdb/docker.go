@@ -0,0 +1,124 @@
+package testdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"dvith.com/go-service-api/pkg/database"
+)
+
+// postgresImage is the image of the disposable server, the oldest major
+// version the migrations support
+const postgresImage = "postgres:16-alpine"
+
+// startupTimeout bounds pulling the image and the server becoming ready
+const startupTimeout = 2 * time.Minute
+
+// dockerContainer is a running disposable server, managed through the
+// docker CLI (see the package doc for why not testcontainers-go)
+type dockerContainer struct {
+	id  string
+	url string
+}
+
+// dockerUnavailable returns why no container can be started, or "" when
+// Docker is usable
+func dockerUnavailable() string {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return "docker is not installed"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := docker(ctx, "info", "--format", "{{.ServerVersion}}"); err != nil {
+		return "the Docker daemon is not reachable"
+	}
+	return ""
+}
+
+// startPostgres runs a server on a random local port and waits until it
+// accepts connections. The container is removed when it stops.
+func startPostgres() (*dockerContainer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+
+	id, err := docker(ctx, "run", "--detach", "--rm",
+		"--label", "dvith.com/go-service-api/testdb=true",
+		"--env", "POSTGRES_USER=test",
+		"--env", "POSTGRES_PASSWORD=test",
+		"--env", "POSTGRES_DB=test",
+		"--publish", "127.0.0.1::5432",
+		postgresImage,
+		// Durability is of no use to a throwaway server
+		"-c", "fsync=off", "-c", "synchronous_commit=off", "-c", "full_page_writes=off",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("testdb: failed to start %s: %w", postgresImage, err)
+	}
+	c := &dockerContainer{id: id}
+
+	addr, err := docker(ctx, "port", id, "5432/tcp")
+	if err != nil {
+		_ = c.remove()
+		return nil, fmt.Errorf("testdb: failed to read the container port: %w", err)
+	}
+	// Hosts with IPv6 list one mapping per address family
+	addr, _, _ = strings.Cut(addr, "\n")
+	c.url = "postgres://test:test@" + addr + "/test?sslmode=disable"
+
+	if err := waitReady(ctx, c.url); err != nil {
+		_ = c.remove()
+		return nil, fmt.Errorf("testdb: %s did not become ready: %w", postgresImage, err)
+	}
+	return c, nil
+}
+
+// waitReady polls url until a query succeeds. The image only listens on
+// TCP once initialisation is over, so the first success is the real server.
+func waitReady(ctx context.Context, url string) error {
+	for {
+		attempt, cancel := context.WithTimeout(ctx, 2*time.Second)
+		db, err := database.NewDB(attempt, url)
+		if err == nil {
+			err = db.Health(attempt)
+			db.Close()
+		}
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// remove stops and removes the container
+func (c *dockerContainer) remove() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := docker(ctx, "rm", "--force", "--volumes", c.id); err != nil {
+		return fmt.Errorf("failed to remove container %s: %w", c.id, err)
+	}
+	return nil
+}
+
+// docker runs the docker CLI and returns its trimmed output
+func docker(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,157 @@
+// Package testdb gives integration tests a migrated PostgreSQL database of
+// their own. The server is TEST_DATABASE_URL when it is set; otherwise a
+// disposable container is started with Docker for the test binary and
+// removed when it finishes. Without either, the tests are skipped.
+//
+// The container is run with the docker CLI rather than testcontainers-go on
+// purpose: the module would pull the Docker client and its dependency tree
+// into go.mod for the sake of one image, while the CLI is already wherever
+// Docker is, CI included. See docker.go.
+//
+// A package whose tests call Open runs them through Main:
+//
+//	func TestMain(m *testing.M) { testdb.Main(m) }
+package testdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/migrations"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/google/uuid"
+)
+
+// EnvURL names the server to use instead of a container
+const EnvURL = "TEST_DATABASE_URL"
+
+// connectTimeout bounds connecting and migrating a schema
+const connectTimeout = 30 * time.Second
+
+var (
+	// mainRunning is set by Main, which stops the container on exit
+	mainRunning bool
+
+	serverOnce sync.Once
+	serverURL  string
+	// skipReason is why there is no server, when there is none
+	skipReason string
+	serverErr  error
+	container  *dockerContainer
+)
+
+// Main runs the package's tests and removes the container started for
+// them, if any. Call it from TestMain.
+func Main(m *testing.M) {
+	mainRunning = true
+	code := m.Run()
+	if container != nil {
+		if err := container.remove(); err != nil {
+			fmt.Fprintln(os.Stderr, "testdb:", err)
+		}
+	}
+	os.Exit(code)
+}
+
+// Open returns a pool on a new schema holding every migration, dropped when
+// t ends. Tests sharing the server can't see each other's rows. It skips t
+// under -short or when there is no server to use.
+func Open(t testing.TB) *database.DBPool {
+	t.Helper()
+	url := URL(t)
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	admin, err := database.NewDB(ctx, url)
+	if err != nil {
+		if os.Getenv(EnvURL) != "" {
+			t.Skip("PostgreSQL not available at "+EnvURL+", skipping integration test:", err)
+		}
+		t.Fatalf("testdb: failed to connect to the container: %v", err)
+	}
+	t.Cleanup(admin.Close)
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("testdb: failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	db, err := database.NewDB(ctx, withSearchPath(url, schema))
+	if err != nil {
+		t.Fatalf("testdb: failed to connect to schema %s: %v", schema, err)
+	}
+	t.Cleanup(db.Close)
+
+	if err := migrate(ctx, db); err != nil {
+		t.Fatalf("testdb: %v", err)
+	}
+	return db
+}
+
+// URL returns the URL of the server, starting the container on first use.
+// It skips t under -short or when there is no server to use. Tests get the
+// server's default schema, so most want Open instead.
+func URL(t testing.TB) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	serverOnce.Do(func() {
+		if url := os.Getenv(EnvURL); url != "" {
+			serverURL = url
+			return
+		}
+		if reason := dockerUnavailable(); reason != "" {
+			skipReason = EnvURL + " not set and " + reason + ", skipping integration test"
+			return
+		}
+		if !mainRunning {
+			serverErr = fmt.Errorf("testdb: the container is only removed through testdb.Main; call it from TestMain")
+			return
+		}
+		container, serverErr = startPostgres()
+		if serverErr == nil {
+			serverURL = container.url
+		}
+	})
+
+	if skipReason != "" {
+		t.Skip(skipReason)
+	}
+	if serverErr != nil {
+		t.Fatal(serverErr)
+	}
+	return serverURL
+}
+
+// migrate applies every migration in order
+func migrate(ctx context.Context, db *database.DBPool) error {
+	for _, name := range migrations.Names() {
+		ddl, err := migrations.FS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(ctx, string(ddl)); err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// withSearchPath adds a search_path parameter to url
+func withSearchPath(url, schema string) string {
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	return url + sep + "search_path=" + schema
+}
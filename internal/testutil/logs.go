@@ -0,0 +1,74 @@
+package testutil
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+// LogBuffer holds the log output written while its App is in use. The
+// service logs through the process-wide logger, so an App running in
+// parallel with others also sees their lines: assert on lines that carry
+// something unique to the test, such as its user's ID.
+type LogBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write implements io.Writer
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// String returns everything logged so far
+func (b *LogBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Contains reports whether any line logged so far contains s
+func (b *LogBuffer) Contains(s string) bool {
+	return strings.Contains(b.String(), s)
+}
+
+// sinks fans each log write out to the buffers of the live Apps
+var sinks = &fanout{buffers: map[*LogBuffer]struct{}{}}
+
+type fanout struct {
+	mu      sync.Mutex
+	buffers map[*LogBuffer]struct{}
+}
+
+func (f *fanout) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for b := range f.buffers {
+		_, _ = b.Write(p)
+	}
+	return len(p), nil
+}
+
+// captureLogs points the process-wide logger at a new buffer until t ends.
+// Nothing reaches stdout meanwhile, so test output stays readable.
+func captureLogs(t testing.TB) *LogBuffer {
+	b := &LogBuffer{}
+	sinks.mu.Lock()
+	sinks.buffers[b] = struct{}{}
+	sinks.mu.Unlock()
+
+	// Set every time: a test elsewhere in the package may have restored
+	// stdout since the last App was built
+	logger.SetOutput(sinks)
+	t.Cleanup(func() {
+		sinks.mu.Lock()
+		delete(sinks.buffers, b)
+		sinks.mu.Unlock()
+	})
+	return b
+}
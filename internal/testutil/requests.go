@@ -0,0 +1,138 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"dvith.com/go-service-api/internal/middleware"
+
+	"github.com/stretchr/testify/require"
+)
+
+// UserAgent is sent with every request, so sessions record a device
+const UserAgent = "Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0"
+
+// Password is the password of users created by SignupUser
+const Password = "SecurePass123!"
+
+// Response is a response with its JSON body decoded
+type Response struct {
+	Status int
+	Header http.Header
+	// Body is the decoded JSON object, empty when the body wasn't one
+	Body map[string]any
+	// Raw is the body as sent
+	Raw []byte
+}
+
+// String returns the field of the body at key, or "" when it isn't a string
+func (r *Response) String(key string) string {
+	s, _ := r.Body[key].(string)
+	return s
+}
+
+// Object returns the object in the body at key, or nil
+func (r *Response) Object(key string) map[string]any {
+	m, _ := r.Body[key].(map[string]any)
+	return m
+}
+
+// Request sends an unauthenticated request with body encoded as JSON, or
+// without a body when it's nil
+func Request(t testing.TB, a *App, method, path string, body any) *Response {
+	t.Helper()
+	return AuthenticatedRequest(t, a, method, path, body, "")
+}
+
+// AuthenticatedRequest is Request with token as the bearer token; an empty
+// token sends no Authorization header
+func AuthenticatedRequest(t testing.TB, a *App, method, path string, body any, token string) *Response {
+	t.Helper()
+	req := NewRequest(t, method, path, body)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return Send(t, a, req)
+}
+
+// NewRequest builds the request Request sends, for tests that need to set
+// more headers before passing it to Send
+func NewRequest(t testing.TB, method, path string, body any) *http.Request {
+	t.Helper()
+
+	var payload io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		require.NoError(t, err)
+		payload = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, payload)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	return req
+}
+
+// Send runs req through the app and decodes the response
+func Send(t testing.TB, a *App, req *http.Request) *Response {
+	t.Helper()
+
+	resp, err := a.Server.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	out := &Response{Status: resp.StatusCode, Header: resp.Header, Body: map[string]any{}, Raw: raw}
+	_ = json.Unmarshal(raw, &out.Body)
+	return out
+}
+
+// User is an account created by SignupUser, with the tokens from signup
+type User struct {
+	ID           string
+	Email        string
+	Username     string
+	Password     string
+	AccessToken  string
+	RefreshToken string
+}
+
+// signups numbers the users SignupUser creates, so they never collide
+var signups atomic.Int64
+
+// SignupUser signs up a new user with a unique email and username
+func SignupUser(t testing.TB, a *App) User {
+	t.Helper()
+
+	n := signups.Add(1)
+	user := User{
+		Email:    fmt.Sprintf("member%d@example.com", n),
+		Username: fmt.Sprintf("member_%d", n),
+		Password: Password,
+	}
+	req := NewRequest(t, http.MethodPost, "/api/v1/auth/signup", map[string]any{
+		"email":     user.Email,
+		"password":  user.Password,
+		"full_name": "Test Member",
+		"username":  user.Username,
+	})
+	// The fields below are read by their snake_case names whatever the
+	// app's JSON_NAMING
+	req.Header.Set(middleware.HeaderJSONNaming, middleware.NamingSnake)
+	resp := Send(t, a, req)
+	require.Equal(t, http.StatusCreated, resp.Status, string(resp.Raw))
+
+	user.ID, _ = resp.Object("user")["id"].(string)
+	user.AccessToken = resp.String("access_token")
+	user.RefreshToken = resp.String("refresh_token")
+	require.NotEmpty(t, user.AccessToken)
+	return user
+}
@@ -0,0 +1,81 @@
+// Package testutil boots the whole service for handler tests: the real
+// middleware stack and routes from the bootstrap, on in-memory stores, with
+// a frozen clock and the logs captured. Each App has its own dependencies,
+// so tests using it can run in parallel.
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/config"
+	"dvith.com/go-service-api/internal/domain"
+	"dvith.com/go-service-api/pkg/clock"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// Epoch is where the clock of an App starts unless Options.Now says
+// otherwise
+var Epoch = time.Date(2025, time.January, 15, 9, 0, 0, 0, time.UTC)
+
+// Options adjust the App NewTestApp builds. The zero value is the
+// development configuration on in-memory stores.
+type Options struct {
+	// Configure edits the configuration before the dependencies are built
+	Configure func(cfg *config.Config)
+	// Modules replaces domain.DefaultModules
+	Modules *domain.Registry
+	// Now is where the frozen clock starts; Epoch when zero
+	Now time.Time
+}
+
+// App is the service as wired by the bootstrap
+type App struct {
+	Server *fiber.App
+	Deps   *app.Deps
+	// Clock drives token lifetimes, stored timestamps and cache expiry;
+	// advance it to expire them
+	Clock *clock.Frozen
+	// Logs receives what the service logs while the App is in use
+	Logs *LogBuffer
+}
+
+// NewTestApp boots the service for t. It runs in development without a
+// DATABASE_URL, so every route backed by the in-memory stores works.
+func NewTestApp(t testing.TB, opts Options) *App {
+	t.Helper()
+
+	cfg, err := config.LoadFromEnv()
+	require.NoError(t, err)
+	cfg.Env = "development"
+	cfg.DatabaseURL = ""
+	cfg = cfg.Effective()
+	// Every request comes from the same address; tests of the signup
+	// quota lower it again
+	cfg.SignupRateLimit = 1000
+	if opts.Configure != nil {
+		opts.Configure(&cfg)
+	}
+	require.True(t, cfg.UsesMemoryStore(), "the test app runs on in-memory stores")
+
+	now := opts.Now
+	if now.IsZero() {
+		now = Epoch
+	}
+	frozen := clock.NewFrozen(now)
+
+	// Logs are captured before NewDeps, which warns about the memory stores
+	logs := captureLogs(t)
+	deps := app.NewDeps(nil, cfg).WithClock(frozen.Now)
+
+	modules := opts.Modules
+	if modules == nil {
+		modules = domain.DefaultModules()
+	}
+	server := fiber.New(fiber.Config{BodyLimit: cfg.BodyLimit, StreamRequestBody: true})
+	domain.InitModules(server, deps, modules)
+
+	return &App{Server: server, Deps: deps, Clock: frozen, Logs: logs}
+}
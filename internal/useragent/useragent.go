@@ -0,0 +1,118 @@
+package useragent
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Unknown is reported for any field the parser cannot recognise
+const Unknown = "Unknown"
+
+// Device types
+const (
+	DeviceDesktop = "Desktop"
+	DeviceMobile  = "Mobile"
+	DeviceTablet  = "Tablet"
+	DeviceBot     = "Bot"
+)
+
+// maxLength caps how much of a header is inspected, so oversized headers
+// can't make the regexes expensive
+const maxLength = 512
+
+// Agent is the human-readable summary of a User-Agent header
+type Agent struct {
+	Browser string `json:"browser"`
+	OS      string `json:"os"`
+	Device  string `json:"device"`
+}
+
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Rules are checked in order and the first match wins, so more specific
+// agents must come before the ones they imitate (Edge and Opera before
+// Chrome, Chrome before Safari).
+var browserRules = []rule{
+	{"Bot", regexp.MustCompile(`(?i)bot\b|crawler|spider|slurp|facebookexternalhit`)},
+	{"Edge", regexp.MustCompile(`Edg(e|A|iOS)?/`)},
+	{"Opera", regexp.MustCompile(`OPR/|OPiOS/|Opera`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/`)},
+	{"Yandex", regexp.MustCompile(`YaBrowser/`)},
+	{"Vivaldi", regexp.MustCompile(`Vivaldi/`)},
+	{"Firefox", regexp.MustCompile(`Firefox/|FxiOS/`)},
+	{"Chrome", regexp.MustCompile(`Chrome/|CriOS/|CrMo/`)},
+	{"Safari", regexp.MustCompile(`Version/[\d.]+.*Safari/|Mobile/\w+ Safari/`)},
+	{"Internet Explorer", regexp.MustCompile(`MSIE |Trident/`)},
+	{"curl", regexp.MustCompile(`^curl/`)},
+	{"Postman", regexp.MustCompile(`^PostmanRuntime/`)},
+	{"OkHttp", regexp.MustCompile(`^okhttp/`)},
+	{"Go HTTP Client", regexp.MustCompile(`^Go-http-client/`)},
+	{"Python Requests", regexp.MustCompile(`^python-requests/`)},
+}
+
+var osRules = []rule{
+	{"Windows Phone", regexp.MustCompile(`Windows Phone`)},
+	{"Windows", regexp.MustCompile(`Windows NT|Win64|Windows`)},
+	{"iOS", regexp.MustCompile(`iPhone|iPad|iPod|iOS`)},
+	{"macOS", regexp.MustCompile(`Mac OS X|Macintosh`)},
+	{"Android", regexp.MustCompile(`Android`)},
+	{"ChromeOS", regexp.MustCompile(`CrOS`)},
+	{"Linux", regexp.MustCompile(`Linux|X11`)},
+}
+
+var (
+	botPattern     = browserRules[0].pattern
+	tabletPattern  = regexp.MustCompile(`iPad|Tablet|Kindle|Silk/|SM-T\d`)
+	mobilePattern  = regexp.MustCompile(`Mobile|iPhone|iPod|Windows Phone|Android`)
+	desktopPattern = regexp.MustCompile(`Windows NT|Macintosh|X11|CrOS`)
+)
+
+// Parse derives the browser family, OS and device type from a User-Agent
+// header. Fields that can't be determined are Unknown.
+func Parse(ua string) Agent {
+	ua = strings.TrimSpace(ua)
+	if len(ua) > maxLength {
+		ua = ua[:maxLength]
+	}
+
+	return Agent{
+		Browser: match(browserRules, ua),
+		OS:      match(osRules, ua),
+		Device:  device(ua),
+	}
+}
+
+func match(rules []rule, ua string) string {
+	if ua == "" {
+		return Unknown
+	}
+	for _, r := range rules {
+		if r.pattern.MatchString(ua) {
+			return r.name
+		}
+	}
+	return Unknown
+}
+
+func device(ua string) string {
+	switch {
+	case ua == "":
+		return Unknown
+	case botPattern.MatchString(ua):
+		return DeviceBot
+	case tabletPattern.MatchString(ua):
+		return DeviceTablet
+	// Android tablets omit "Mobile" from their user agent
+	case strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile"):
+		return DeviceTablet
+	case mobilePattern.MatchString(ua):
+		return DeviceMobile
+	case desktopPattern.MatchString(ua):
+		return DeviceDesktop
+	default:
+		return Unknown
+	}
+}
@@ -0,0 +1,87 @@
+package useragent
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want Agent
+	}{
+		// Desktop browsers
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36", Agent{"Chrome", "Windows", DeviceDesktop}},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 Edg/129.0.2792.79", Agent{"Edge", "Windows", DeviceDesktop}},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:131.0) Gecko/20100101 Firefox/131.0", Agent{"Firefox", "Windows", DeviceDesktop}},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 OPR/114.0.0.0", Agent{"Opera", "Windows", DeviceDesktop}},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/128.0.0.0 YaBrowser/24.10.0.0 Safari/537.36", Agent{"Yandex", "Windows", DeviceDesktop}},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/128.0.0.0 Safari/537.36 Vivaldi/6.9.3447.54", Agent{"Vivaldi", "Windows", DeviceDesktop}},
+		{"Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko", Agent{"Internet Explorer", "Windows", DeviceDesktop}},
+		{"Mozilla/4.0 (compatible; MSIE 8.0; Windows NT 6.1; Trident/4.0)", Agent{"Internet Explorer", "Windows", DeviceDesktop}},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Safari/605.1.15", Agent{"Safari", "macOS", DeviceDesktop}},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36", Agent{"Chrome", "macOS", DeviceDesktop}},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14.7; rv:131.0) Gecko/20100101 Firefox/131.0", Agent{"Firefox", "macOS", DeviceDesktop}},
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36", Agent{"Chrome", "Linux", DeviceDesktop}},
+		{"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0", Agent{"Firefox", "Linux", DeviceDesktop}},
+		{"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36", Agent{"Chrome", "ChromeOS", DeviceDesktop}},
+
+		// Phones
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Mobile/15E148 Safari/604.1", Agent{"Safari", "iOS", DeviceMobile}},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/129.0.6668.69 Mobile/15E148 Safari/604.1", Agent{"Chrome", "iOS", DeviceMobile}},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/131.0 Mobile/15E148 Safari/605.1.15", Agent{"Firefox", "iOS", DeviceMobile}},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) EdgiOS/129.0.2792.84 Version/18.0 Mobile/15E148 Safari/604.1", Agent{"Edge", "iOS", DeviceMobile}},
+		{"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.6668.81 Mobile Safari/537.36", Agent{"Chrome", "Android", DeviceMobile}},
+		{"Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/26.0 Chrome/122.0.0.0 Mobile Safari/537.36", Agent{"Samsung Internet", "Android", DeviceMobile}},
+		{"Mozilla/5.0 (Android 14; Mobile; rv:131.0) Gecko/131.0 Firefox/131.0", Agent{"Firefox", "Android", DeviceMobile}},
+		{"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Mobile Safari/537.36 EdgA/129.0.2792.84", Agent{"Edge", "Android", DeviceMobile}},
+		{"Mozilla/5.0 (Windows Phone 10.0; Android 6.0.1; Microsoft; Lumia 950) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/52.0.2743.116 Mobile Safari/537.36 Edge/15.14977", Agent{"Edge", "Windows Phone", DeviceMobile}},
+
+		// Tablets
+		{"Mozilla/5.0 (iPad; CPU OS 18_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Mobile/15E148 Safari/604.1", Agent{"Safari", "iOS", DeviceTablet}},
+		{"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36", Agent{"Chrome", "Android", DeviceTablet}},
+		{"Mozilla/5.0 (Linux; Android 11; KFTRWI) AppleWebKit/537.36 (KHTML, like Gecko) Silk/129.2.1 like Chrome/129.0.6668.100 Safari/537.36", Agent{"Chrome", "Android", DeviceTablet}},
+
+		// Bots and tools
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", Agent{"Bot", Unknown, DeviceBot}},
+		{"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", Agent{"Bot", Unknown, DeviceBot}},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", Agent{"Bot", Unknown, DeviceBot}},
+		{"curl/8.5.0", Agent{"curl", Unknown, Unknown}},
+		{"PostmanRuntime/7.42.0", Agent{"Postman", Unknown, Unknown}},
+		{"okhttp/4.12.0", Agent{"OkHttp", Unknown, Unknown}},
+		{"Go-http-client/1.1", Agent{"Go HTTP Client", Unknown, Unknown}},
+		{"python-requests/2.32.3", Agent{"Python Requests", Unknown, Unknown}},
+
+		// Garbage
+		{"", Agent{Unknown, Unknown, Unknown}},
+		{"   ", Agent{Unknown, Unknown, Unknown}},
+		{"totally-not-a-browser", Agent{Unknown, Unknown, Unknown}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ua, func(t *testing.T) {
+			assert.Equal(t, tt.want, Parse(tt.ua))
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36")
+	f.Add("Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) Version/18.0 Mobile/15E148 Safari/604.1")
+	f.Add("")
+	f.Add("\x00\xff")
+
+	f.Fuzz(func(t *testing.T, ua string) {
+		a := Parse(ua)
+		for _, field := range []string{a.Browser, a.OS, a.Device} {
+			if field == "" {
+				t.Fatalf("Parse(%q) returned an empty field: %+v", ua, a)
+			}
+			if !utf8.ValidString(field) {
+				t.Fatalf("Parse(%q) returned invalid UTF-8: %+v", ua, a)
+			}
+		}
+	})
+}
@@ -0,0 +1,75 @@
+// Submits the account forms to the API and shows its answer in the page.
+// Without JavaScript the forms still post, and the API answers in JSON.
+(function () {
+  "use strict";
+
+  var message = document.querySelector(".message");
+
+  function show(text, ok) {
+    message.textContent = text;
+    message.className = ok ? "message success" : "message error";
+    message.hidden = false;
+  }
+
+  function errorText(body) {
+    if (body && body.errors && body.errors.length) {
+      return body.errors.map(function (e) { return e.message || e.field; }).join(". ");
+    }
+    return (body && (body.message || body.error)) || "Something went wrong, please try again.";
+  }
+
+  function send(url, init) {
+    return fetch(url, init).then(function (resp) {
+      return resp.json().catch(function () { return null; }).then(function (body) {
+        return { ok: resp.ok, body: body };
+      });
+    });
+  }
+
+  document.querySelectorAll("form[data-api-form]").forEach(function (form) {
+    form.addEventListener("submit", function (event) {
+      event.preventDefault();
+      var button = form.querySelector("button");
+      button.disabled = true;
+
+      send(form.action, {
+        method: "POST",
+        headers: { "Accept": "application/json" },
+        body: new URLSearchParams(new FormData(form)),
+        credentials: "same-origin"
+      }).then(function (result) {
+        if (result.ok) {
+          form.hidden = true;
+          show((result.body && result.body.message) || "Done.", true);
+        } else {
+          button.disabled = false;
+          show(errorText(result.body), false);
+        }
+      }, function () {
+        button.disabled = false;
+        show("Could not reach the server, please try again.", false);
+      });
+    });
+  });
+
+  var callback = document.querySelector("[data-oauth-callback]");
+  if (callback) {
+    send(callback.getAttribute("data-oauth-callback"), {
+      headers: { "Accept": "application/json" },
+      credentials: "same-origin"
+    }).then(function (result) {
+      if (!result.ok) {
+        show(errorText(result.body), false);
+        return;
+      }
+      if (window.opener) {
+        window.opener.postMessage({ type: "oauth_login", tokens: result.body }, window.location.origin);
+        window.close();
+        return;
+      }
+      show("You are signed in, you can close this window.", true);
+    }, function () {
+      show("Could not reach the server, please try again.", false);
+    });
+  }
+})();
@@ -0,0 +1,201 @@
+// Package web serves the built-in account pages: the forms emailed
+// password reset and email change links open, and the interstitial an
+// OAuth provider redirects back to. Pages are server-rendered from
+// embedded templates and post to the JSON API, so a deployment needs no
+// separate frontend; see Config.ServeAccountPages.
+package web
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/url"
+
+	"dvith.com/go-service-api/internal/apiversion"
+	"dvith.com/go-service-api/internal/app"
+	"dvith.com/go-service-api/internal/config"
+	emailchange "dvith.com/go-service-api/internal/domain/authentication/email_change"
+	"dvith.com/go-service-api/internal/domain/authentication/oauth"
+	passwordreset "dvith.com/go-service-api/internal/domain/authentication/password_reset"
+	"dvith.com/go-service-api/internal/middleware"
+	"dvith.com/go-service-api/internal/routemeta"
+	"dvith.com/go-service-api/pkg/logger"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/static"
+)
+
+// Page paths. Emailed links point at them when the pages are served.
+const (
+	Prefix             = "/account"
+	ResetPasswordPath  = Prefix + "/reset-password"
+	ForgotPasswordPath = Prefix + "/forgot-password"
+	ConfirmEmailPath   = Prefix + "/confirm-email"
+	StaticPath         = Prefix + "/static"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+//go:embed static
+var staticFS embed.FS
+
+// pages are the templates by file name, each parsed with the layout
+var pages = mustParsePages("reset_password.html", "forgot_password.html", "confirm_email.html", "oauth_callback.html")
+
+func mustParsePages(names ...string) map[string]*template.Template {
+	parsed := make(map[string]*template.Template, len(names))
+	for _, name := range names {
+		parsed[name] = template.Must(template.ParseFS(templateFS, "templates/layout.html", "templates/"+name))
+	}
+	return parsed
+}
+
+// ResetLinkPath is where password reset emails point: the reset page when
+// it is served, the API otherwise
+func ResetLinkPath(cfg config.Config) string {
+	if cfg.ServeAccountPages {
+		return ResetPasswordPath
+	}
+	return passwordreset.LinkPath
+}
+
+// EmailChangeLinks builds the links of email change emails, sending the
+// confirmation to the confirm page when it is served. Opening the page
+// changes nothing until the user submits it, so mail scanners following
+// links can't confirm a change.
+func EmailChangeLinks(cfg config.Config) emailchange.LinkFunc {
+	if !cfg.ServeAccountPages {
+		return cfg.AbsoluteURL
+	}
+	return func(path string, query url.Values) string {
+		if path == emailchange.ConfirmPath {
+			path = ConfirmEmailPath
+		}
+		return cfg.AbsoluteURL(path, query)
+	}
+}
+
+// LinkVerifier checks signed links; signedurl.Signer implements it
+type LinkVerifier interface {
+	Verify(rawURL string) (map[string]string, error)
+}
+
+// page is what every template renders from
+type page struct {
+	Title string
+	// Nonce lets the page's script run under the Content-Security-Policy
+	Nonce  string
+	Static string
+	// Action is the API route the page's form posts to
+	Action string
+	Token  string
+	Error  string
+	// Forgot links a failed reset to a new request
+	Forgot string
+	// Callback is the API route completing an OAuth login
+	Callback string
+}
+
+// Register serves the pages under Prefix, outside the versioned API
+func Register(server fiber.Router, deps *app.Deps) {
+	api := apiversion.V1.Prefix()
+	account := server.Group(Prefix, middleware.RequestID(), middleware.SecurityHeaders(middleware.SecurityHeadersConfig{}))
+
+	account.Use(StaticPath[len(Prefix):], static.New("", static.Config{FS: mustSub(staticFS, "static"), MaxAge: 3600}))
+	account.Get(ResetPasswordPath[len(Prefix):], ResetPasswordPage(deps.Links, api+"/auth/password/reset")).Name("account.reset_password")
+	account.Get(ForgotPasswordPath[len(Prefix):], ForgotPasswordPage(api+"/auth/password/forgot")).Name("account.forgot_password")
+	account.Get(ConfirmEmailPath[len(Prefix):], ConfirmEmailPage(api+"/auth/confirm-email-change")).Name("account.confirm_email")
+
+	providers := map[string]bool{}
+	for _, p := range oauth.ProvidersFromConfig(deps.Cfg) {
+		providers[p.Name()] = true
+	}
+	account.Get("/oauth/:provider/callback", OAuthCallbackPage(providers, api+"/auth/oauth/")).Name("account.oauth_callback")
+
+	deps.Routes.Describe(routemeta.Route{Name: "account.reset_password", Summary: "Page setting a new password, opened from the reset email"})
+	deps.Routes.Describe(routemeta.Route{Name: "account.forgot_password", Summary: "Page asking for a password reset email"})
+	deps.Routes.Describe(routemeta.Route{Name: "account.confirm_email", Summary: "Page confirming an email change, opened from the confirmation email"})
+	deps.Routes.Describe(routemeta.Route{Name: "account.oauth_callback", Summary: "Page an OAuth provider redirects back to, completing the login"})
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// render writes the named page with the request's nonce
+func render(c fiber.Ctx, status int, name string, data page) error {
+	data.Nonce = middleware.CSPNonce(c)
+	data.Static = StaticPath
+
+	var buf bytes.Buffer
+	if err := pages[name].ExecuteTemplate(&buf, "layout", data); err != nil {
+		logger.Error("failed to render account page", map[string]any{
+			"page":  name,
+			"error": err.Error(),
+		})
+		return middleware.InternalErrorResponse(c, "failed to render page", err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Type("html", "utf-8")
+	return c.Status(status).Send(buf.Bytes())
+}
+
+// ResetPasswordPage checks the signed link from the reset email, as
+// passwordreset.VerifyLinkHandler does, and renders a form posting its
+// token and the new password to action
+func ResetPasswordPage(links LinkVerifier, action string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		data := page{Title: "Choose a new password", Action: action, Forgot: ForgotPasswordPath}
+		params, err := links.Verify(c.OriginalURL())
+		if err != nil || params["token"] == "" {
+			data.Error = passwordreset.ErrInvalidLink.Error()
+			return render(c, fiber.StatusBadRequest, "reset_password.html", data)
+		}
+		data.Token = params["token"]
+		return render(c, fiber.StatusOK, "reset_password.html", data)
+	}
+}
+
+// ForgotPasswordPage renders a form posting an email address to action
+func ForgotPasswordPage(action string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		return render(c, fiber.StatusOK, "forgot_password.html", page{Title: "Reset your password", Action: action})
+	}
+}
+
+// ConfirmEmailPage renders a form posting the token of the confirmation
+// link to action
+func ConfirmEmailPage(action string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		data := page{Title: "Confirm your new email address", Action: action, Token: c.Query("token")}
+		if data.Token == "" {
+			data.Error = emailchange.ErrInvalidToken.Error()
+			return render(c, fiber.StatusBadRequest, "confirm_email.html", data)
+		}
+		return render(c, fiber.StatusOK, "confirm_email.html", data)
+	}
+}
+
+// OAuthCallbackPage is the interstitial a provider redirects back to. Its
+// script completes the login through the API callback under apiPrefix,
+// forwarding the query, and hands the tokens to the window that opened it.
+func OAuthCallbackPage(providers map[string]bool, apiPrefix string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		provider := c.Params("provider")
+		if !providers[provider] {
+			return middleware.NotFoundResponse(c, "unknown OAuth provider")
+		}
+
+		data := page{
+			Title:    "Signing you in",
+			Callback: apiPrefix + url.PathEscape(provider) + "/callback?" + string(c.RequestCtx().QueryArgs().QueryString()),
+		}
+		return render(c, fiber.StatusOK, "oauth_callback.html", data)
+	}
+}
@@ -0,0 +1,247 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/config"
+	emailchange "dvith.com/go-service-api/internal/domain/authentication/email_change"
+	passwordreset "dvith.com/go-service-api/internal/domain/authentication/password_reset"
+	"dvith.com/go-service-api/internal/domain/user/model"
+	"dvith.com/go-service-api/internal/middleware"
+	hashpassword "dvith.com/go-service-api/internal/security/hash_password"
+	"dvith.com/go-service-api/pkg/mailer"
+	"dvith.com/go-service-api/pkg/signedurl"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const newPassword = "N3w-Passw0rd!"
+
+// resetStore keeps reset tokens in memory, each usable once
+type resetStore struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]*passwordreset.Record
+}
+
+func (s *resetStore) Create(ctx context.Context, rec *passwordreset.Record, maxOutstanding int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *rec
+	s.tokens[rec.ID] = &cp
+	return nil
+}
+
+func (s *resetStore) Consume(ctx context.Context, id uuid.UUID, now time.Time, verify func(string) bool) (*passwordreset.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.tokens[id]
+	if !ok || r.UsedAt != nil || !r.ExpiresAt.After(now) || !verify(r.TokenHash) {
+		return nil, passwordreset.ErrInvalidToken
+	}
+	r.UsedAt = &now
+	cp := *r
+	return &cp, nil
+}
+
+func (s *resetStore) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// userStore is a single user whose password can be reset
+type userStore struct {
+	user *model.User
+	hash string
+}
+
+func (s *userStore) FindByEmail(ctx context.Context, email string, scope model.Scope) (*model.User, error) {
+	if email != s.user.Email {
+		return nil, model.ErrUserNotFound
+	}
+	return s.user, nil
+}
+
+func (s *userStore) SetPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	s.hash = passwordHash
+	return nil
+}
+
+type outbox struct {
+	sent []mailer.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg mailer.Message) error {
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+type fixture struct {
+	app    *fiber.App
+	users  *userStore
+	outbox *outbox
+	// confirmed are the tokens the confirm form posted
+	confirmed []string
+}
+
+// newFixture serves the pages and the API routes they post to, as
+// Register and the authentication module wire them
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	f := &fixture{
+		users:  &userStore{user: &model.User{ID: uuid.New(), Email: "user@example.com", FullName: "Jane Doe"}},
+		outbox: &outbox{},
+	}
+	links := signedurl.MustNew("https://example.com", "test-secret")
+	resets := passwordreset.NewService(&resetStore{tokens: map[uuid.UUID]*passwordreset.Record{}}, f.users, f.outbox, 30*time.Minute, links, ResetPasswordPath)
+
+	f.app = fiber.New()
+	account := f.app.Group(Prefix, middleware.SecurityHeaders(middleware.SecurityHeadersConfig{}))
+	account.Get("/reset-password", ResetPasswordPage(links, "/api/v1/auth/password/reset"))
+	account.Get("/forgot-password", ForgotPasswordPage("/api/v1/auth/password/forgot"))
+	account.Get("/confirm-email", ConfirmEmailPage("/api/v1/auth/confirm-email-change"))
+	account.Get("/oauth/:provider/callback", OAuthCallbackPage(map[string]bool{"google": true}, "/api/v1/auth/oauth/"))
+
+	f.app.Post("/api/v1/auth/password/forgot", middleware.ValidateForm[passwordreset.ForgotPasswordRequest](), passwordreset.ForgotPasswordHandler(resets))
+	f.app.Post("/api/v1/auth/password/reset", middleware.ValidateForm[passwordreset.ResetPasswordRequest](), passwordreset.ResetPasswordHandler(resets))
+	f.app.Post("/api/v1/auth/confirm-email-change", middleware.ValidateForm[emailchange.ConfirmEmailChangeRequest](), func(c fiber.Ctx) error {
+		req, err := middleware.GetValidatedBody[emailchange.ConfirmEmailChangeRequest](c)
+		if err != nil {
+			return err
+		}
+		f.confirmed = append(f.confirmed, req.Token)
+		return c.SendStatus(fiber.StatusOK)
+	})
+	return f
+}
+
+func (f *fixture) get(t *testing.T, target string) (*http.Response, string) {
+	t.Helper()
+	resp, err := f.app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+// submit posts the form of page as a browser would without JavaScript
+func (f *fixture) submit(t *testing.T, page string, fields url.Values) *http.Response {
+	t.Helper()
+	action := regexp.MustCompile(`<form method="post" action="([^"]+)"`).FindStringSubmatch(page)
+	require.NotNil(t, action, "page must have a form: %s", page)
+	for _, hidden := range regexp.MustCompile(`<input type="hidden" name="([^"]+)" value="([^"]*)"`).FindAllStringSubmatch(page, -1) {
+		fields.Set(hidden[1], unescapeHTML(hidden[2]))
+	}
+
+	req := httptest.NewRequest(http.MethodPost, action[1], strings.NewReader(fields.Encode()))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	resp, err := f.app.Test(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func unescapeHTML(s string) string {
+	return strings.NewReplacer("&#34;", `"`, "&#39;", "'", "&lt;", "<", "&gt;", ">", "&amp;", "&").Replace(s)
+}
+
+func TestResetPasswordFlow(t *testing.T) {
+	f := newFixture(t)
+
+	_, forgot := f.get(t, ForgotPasswordPath)
+	resp := f.submit(t, forgot, url.Values{"email": {"user@example.com"}})
+	require.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+	require.Len(t, f.outbox.sent, 1)
+
+	body := f.outbox.sent[0].Body
+	i := strings.Index(body, "https://example.com"+ResetPasswordPath+"?")
+	require.NotEqual(t, -1, i, "the email links to the reset page: %s", body)
+	link, err := url.Parse(strings.Fields(body[i:])[0])
+	require.NoError(t, err)
+
+	resp, page := f.get(t, link.RequestURI())
+	require.Equal(t, fiber.StatusOK, resp.StatusCode, page)
+	resp = f.submit(t, page, url.Values{"password": {newPassword}})
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.True(t, hashpassword.CheckPassword(newPassword, f.users.hash))
+
+	resp = f.submit(t, page, url.Values{"password": {"An0ther-Passw0rd!"}})
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, "the token is spent")
+}
+
+func TestResetPasswordPage_RejectsTamperedLink(t *testing.T) {
+	f := newFixture(t)
+	links := signedurl.MustNew("https://example.com", "test-secret")
+	link, err := links.Sign(ResetPasswordPath, map[string]string{"token": "abc.def"}, time.Hour)
+	require.NoError(t, err)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+
+	resp, page := f.get(t, strings.Replace(u.RequestURI(), "abc.def", "abc.xyz", 1))
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, page, passwordreset.ErrInvalidLink.Error())
+	assert.NotContains(t, page, "<form", "no form for a link that can't be used")
+	assert.Contains(t, page, ForgotPasswordPath)
+}
+
+func TestConfirmEmailPage(t *testing.T) {
+	f := newFixture(t)
+	token := `id.se"><script>alert(1)</script>`
+
+	resp, page := f.get(t, ConfirmEmailPath+"?token="+url.QueryEscape(token))
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.NotContains(t, page, "<script>alert", "the token is escaped")
+	assert.Equal(t, "no-store", resp.Header.Get(fiber.HeaderCacheControl))
+	assert.Empty(t, f.confirmed, "opening the page confirms nothing")
+
+	resp = f.submit(t, page, url.Values{})
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{token}, f.confirmed)
+
+	resp, _ = f.get(t, ConfirmEmailPath)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+func TestPages_ScriptCarriesCSPNonce(t *testing.T) {
+	f := newFixture(t)
+
+	for _, path := range []string{ForgotPasswordPath, ConfirmEmailPath + "?token=a.b", Prefix + "/oauth/google/callback?state=s&code=c"} {
+		resp, page := f.get(t, path)
+		require.Equal(t, fiber.StatusOK, resp.StatusCode, path)
+
+		nonce := regexp.MustCompile(`<script nonce="([^"]+)"`).FindStringSubmatch(page)
+		require.NotNil(t, nonce, path)
+		assert.Contains(t, resp.Header.Get("Content-Security-Policy"), "'nonce-"+nonce[1]+"'", path)
+		assert.Equal(t, 1, strings.Count(page, "<script"), "no inline scripts: %s", path)
+	}
+}
+
+func TestOAuthCallbackPage(t *testing.T) {
+	f := newFixture(t)
+
+	_, page := f.get(t, Prefix+"/oauth/google/callback?state=s1&code=c%26d")
+	assert.Contains(t, page, `data-oauth-callback="/api/v1/auth/oauth/google/callback?state=s1&amp;code=c%26d"`)
+
+	resp, _ := f.get(t, Prefix+"/oauth/github/callback?state=s1&code=c")
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestEmailChangeLinks(t *testing.T) {
+	cfg := config.Config{URL: "https://example.com"}
+	assert.Equal(t, "https://example.com"+emailchange.ConfirmPath+"?token=t", EmailChangeLinks(cfg)(emailchange.ConfirmPath, url.Values{"token": {"t"}}))
+	assert.Equal(t, passwordreset.LinkPath, ResetLinkPath(cfg))
+
+	cfg.ServeAccountPages = true
+	links := EmailChangeLinks(cfg)
+	assert.Equal(t, "https://example.com"+ConfirmEmailPath+"?token=t", links(emailchange.ConfirmPath, url.Values{"token": {"t"}}))
+	assert.Equal(t, "https://example.com/api/v1/auth/cancel-email-change?token=t", links("/api/v1/auth/cancel-email-change", url.Values{"token": {"t"}}))
+	assert.Equal(t, ResetPasswordPath, ResetLinkPath(cfg))
+}
@@ -0,0 +1,18 @@
+-- Create transactional outbox table
+CREATE TABLE IF NOT EXISTS events_outbox (
+  id UUID PRIMARY KEY,
+  event_type VARCHAR(100) NOT NULL,
+  aggregate_id UUID NOT NULL,
+  payload JSONB NOT NULL DEFAULT '{}'::jsonb,
+  status VARCHAR(20) NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  delivered_at TIMESTAMP,
+  CONSTRAINT events_outbox_status_check CHECK (status IN ('pending', 'delivered', 'dead'))
+);
+
+-- Index used by the poller claim query
+CREATE INDEX IF NOT EXISTS idx_events_outbox_pending ON events_outbox(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_events_outbox_aggregate_id ON events_outbox(aggregate_id);
@@ -0,0 +1,32 @@
+-- Create tenants table
+CREATE TABLE tenants (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  slug VARCHAR(100) UNIQUE NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  host VARCHAR(255) UNIQUE,
+  is_default BOOLEAN NOT NULL DEFAULT false,
+  is_active BOOLEAN NOT NULL DEFAULT true,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT slug_not_empty CHECK (slug != '')
+);
+
+-- Only one tenant can be the default
+CREATE UNIQUE INDEX idx_tenants_single_default ON tenants(is_default) WHERE is_default;
+
+-- Seed the default tenant that owns all pre-existing users
+INSERT INTO tenants (id, slug, name, is_default)
+VALUES ('00000000-0000-0000-0000-000000000001', 'default', 'Default', true);
+
+-- Scope users to a tenant
+ALTER TABLE users ADD COLUMN tenant_id UUID REFERENCES tenants(id);
+UPDATE users SET tenant_id = '00000000-0000-0000-0000-000000000001' WHERE tenant_id IS NULL;
+ALTER TABLE users ALTER COLUMN tenant_id SET NOT NULL;
+
+-- Email and username are unique per tenant rather than globally
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+ALTER TABLE users DROP CONSTRAINT users_username_key;
+ALTER TABLE users ADD CONSTRAINT users_tenant_email_key UNIQUE (tenant_id, email);
+ALTER TABLE users ADD CONSTRAINT users_tenant_username_key UNIQUE (tenant_id, username);
+
+CREATE INDEX idx_users_tenant_id ON users(tenant_id);
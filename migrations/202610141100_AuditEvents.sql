@@ -0,0 +1,15 @@
+-- Create audit events table
+CREATE TABLE audit_events (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  tenant_id UUID REFERENCES tenants(id),
+  user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  action VARCHAR(100) NOT NULL,
+  ip VARCHAR(64),
+  user_agent TEXT,
+  metadata JSONB NOT NULL DEFAULT '{}'::jsonb,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for per-user history
+CREATE INDEX idx_audit_events_user_id_created_at ON audit_events(user_id, created_at);
+CREATE INDEX idx_audit_events_action ON audit_events(action);
@@ -0,0 +1,8 @@
+-- Add role used for admin authorization
+ALTER TABLE users ADD COLUMN role VARCHAR(50) NOT NULL DEFAULT 'user';
+
+-- Record when a soft-deleted user was anonymized by the purge job
+ALTER TABLE users ADD COLUMN purged_at TIMESTAMP;
+
+-- Index used to find purge candidates
+CREATE INDEX idx_users_purge_candidates ON users(deleted_at) WHERE deleted_at IS NOT NULL AND purged_at IS NULL;
@@ -0,0 +1,15 @@
+-- Create sessions table, one row per signin
+CREATE TABLE sessions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  tenant_id UUID NOT NULL REFERENCES tenants(id),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  ip VARCHAR(64),
+  user_agent TEXT,
+  fingerprint VARCHAR(64) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  revoked_at TIMESTAMP
+);
+
+-- Index for listing a user's most recent sessions
+CREATE INDEX idx_sessions_user_id_last_used_at ON sessions(user_id, last_used_at DESC);
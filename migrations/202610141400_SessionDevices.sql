@@ -0,0 +1,4 @@
+-- Store the parsed user agent alongside the raw header
+ALTER TABLE sessions ADD COLUMN browser VARCHAR(100) NOT NULL DEFAULT 'Unknown';
+ALTER TABLE sessions ADD COLUMN os VARCHAR(100) NOT NULL DEFAULT 'Unknown';
+ALTER TABLE sessions ADD COLUMN device_type VARCHAR(50) NOT NULL DEFAULT 'Unknown';
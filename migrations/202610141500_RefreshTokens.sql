@@ -0,0 +1,17 @@
+-- Track refresh tokens by family so rotation and reuse can be detected
+CREATE TABLE refresh_tokens (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  family_id UUID NOT NULL,
+  tenant_id UUID REFERENCES tenants(id),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash VARCHAR(64) UNIQUE NOT NULL,
+  parent_id UUID REFERENCES refresh_tokens(id) ON DELETE SET NULL,
+  replaced_by UUID REFERENCES refresh_tokens(id) ON DELETE SET NULL,
+  issued_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  rotated_at TIMESTAMP,
+  revoked_at TIMESTAMP
+);
+
+-- Index used to revoke a whole family on reuse
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
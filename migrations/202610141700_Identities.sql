@@ -0,0 +1,17 @@
+-- Create identities table, one row per OAuth account linked to a user
+CREATE TABLE identities (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  tenant_id UUID NOT NULL REFERENCES tenants(id),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  provider VARCHAR(32) NOT NULL,
+  provider_subject VARCHAR(255) NOT NULL,
+  email VARCHAR(255),
+  linked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  -- A provider account signs in as exactly one user per tenant
+  CONSTRAINT identities_provider_subject_unique UNIQUE (tenant_id, provider, provider_subject),
+  -- A user links at most one account per provider
+  CONSTRAINT identities_user_provider_unique UNIQUE (user_id, provider)
+);
+
+-- Index for listing a user's identities
+CREATE INDEX idx_identities_user_id ON identities(user_id);
@@ -0,0 +1,2 @@
+-- Index for loading recently revoked sessions into the revocation cache
+CREATE INDEX idx_sessions_revoked_at ON sessions(revoked_at) WHERE revoked_at IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Record when each user last set their password. Existing rows stay NULL:
+-- when those passwords were chosen is unknown.
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP;
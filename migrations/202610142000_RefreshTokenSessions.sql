@@ -0,0 +1,10 @@
+-- Link refresh tokens to the session they were issued for, so evicting or
+-- revoking a session can revoke its refresh tokens too. Tokens tracked
+-- before this column existed keep a NULL session.
+ALTER TABLE refresh_tokens ADD COLUMN session_id UUID;
+
+-- Index used to revoke a session's refresh tokens
+CREATE INDEX idx_refresh_tokens_session_id ON refresh_tokens(session_id) WHERE session_id IS NOT NULL;
+
+-- Index used to find a user's active sessions, oldest first
+CREATE INDEX idx_sessions_user_active ON sessions(user_id, last_used_at) WHERE revoked_at IS NULL;
@@ -0,0 +1,3 @@
+-- Let admins force a password reset, e.g. after a credential leak. Signin
+-- is refused until the user sets a new password.
+ALTER TABLE users ADD COLUMN must_reset_password BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,3 @@
+-- full_name holds AES-GCM ciphertext once column encryption is configured,
+-- which outgrows VARCHAR(255) for long names
+ALTER TABLE users ALTER COLUMN full_name TYPE TEXT;
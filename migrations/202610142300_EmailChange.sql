@@ -0,0 +1,25 @@
+-- Count changes to how a user signs in. Changing the email bumps it, in
+-- the same transaction that revokes the user's sessions.
+ALTER TABLE users ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
+
+-- Pending email changes. The old email stays active until the new address
+-- confirms; only SHA-256s of the confirm and cancel secrets are stored.
+CREATE TABLE email_change_requests (
+  id UUID PRIMARY KEY,
+  tenant_id UUID NOT NULL REFERENCES tenants(id),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  old_email VARCHAR(255) NOT NULL,
+  new_email VARCHAR(255) NOT NULL,
+  confirm_hash VARCHAR(64) NOT NULL,
+  cancel_hash VARCHAR(64) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  confirmed_at TIMESTAMP,
+  cancelled_at TIMESTAMP
+);
+
+-- A user has at most one pending change; a new request replaces it
+CREATE UNIQUE INDEX idx_email_change_requests_pending ON email_change_requests(user_id) WHERE confirmed_at IS NULL AND cancelled_at IS NULL;
+
+-- Index for the expired request cleanup job
+CREATE INDEX idx_email_change_requests_expires_at ON email_change_requests(expires_at);
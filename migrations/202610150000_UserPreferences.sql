@@ -0,0 +1,12 @@
+-- Per-user email opt-ins and display settings. Rows are created on first
+-- read, so users without one have the defaults below.
+CREATE TABLE user_preferences (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  tenant_id UUID NOT NULL REFERENCES tenants(id),
+  security_emails BOOLEAN NOT NULL DEFAULT TRUE,
+  product_emails BOOLEAN NOT NULL DEFAULT FALSE,
+  locale VARCHAR(35) NOT NULL DEFAULT 'en',
+  timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
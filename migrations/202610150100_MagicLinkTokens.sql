@@ -0,0 +1,17 @@
+-- Create magic link tokens table for password-less signin. Only a SHA-256
+-- of the token's secret part is stored.
+CREATE TABLE magic_link_tokens (
+  id UUID PRIMARY KEY,
+  tenant_id UUID NOT NULL REFERENCES tenants(id),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash VARCHAR(64) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP
+);
+
+-- Index for the per-user outstanding token cap
+CREATE INDEX idx_magic_link_tokens_user_id ON magic_link_tokens(user_id, created_at) WHERE used_at IS NULL;
+
+-- Index for the expired token cleanup job
+CREATE INDEX idx_magic_link_tokens_expires_at ON magic_link_tokens(expires_at);
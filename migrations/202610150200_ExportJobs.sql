@@ -0,0 +1,25 @@
+-- Asynchronous data exports. A worker builds the file into the storage
+-- backend; rows and files are deleted once expires_at passes.
+CREATE TABLE export_jobs (
+  id UUID PRIMARY KEY,
+  tenant_id UUID NOT NULL REFERENCES tenants(id),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  format VARCHAR(8) NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  storage_key VARCHAR(255) NOT NULL DEFAULT '',
+  size_bytes BIGINT NOT NULL DEFAULT 0,
+  error TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  started_at TIMESTAMP,
+  finished_at TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL
+);
+
+-- A user has at most one job pending or running
+CREATE UNIQUE INDEX idx_export_jobs_active_user ON export_jobs(user_id) WHERE status IN ('pending', 'running');
+
+-- Index for the worker picking the oldest pending job
+CREATE INDEX idx_export_jobs_pending ON export_jobs(created_at) WHERE status IN ('pending', 'running');
+
+-- Index for the expired job cleanup
+CREATE INDEX idx_export_jobs_expires_at ON export_jobs(expires_at);
@@ -0,0 +1,19 @@
+-- Signup invitation codes, required when SIGNUP_REQUIRES_INVITE is on. Only
+-- the SHA-256 of a code is stored; signup increments used_count in the
+-- transaction that creates the user.
+CREATE TABLE invitations (
+  id UUID PRIMARY KEY,
+  tenant_id UUID NOT NULL REFERENCES tenants(id),
+  code_hash CHAR(64) NOT NULL,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  max_uses INTEGER NOT NULL CHECK (max_uses > 0),
+  used_count INTEGER NOT NULL DEFAULT 0 CHECK (used_count <= max_uses),
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_invitations_tenant_code ON invitations(tenant_id, code_hash);
+
+-- Index for listing a tenant's invitations, newest first
+CREATE INDEX idx_invitations_tenant_created ON invitations(tenant_id, created_at DESC);
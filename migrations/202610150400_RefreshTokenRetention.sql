@@ -0,0 +1,3 @@
+-- Index used by the retention job to delete expired refresh tokens in
+-- batches, oldest first
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
@@ -0,0 +1,20 @@
+-- Trigram indexes for the admin user search, which matches ILIKE
+-- substrings of emails and usernames. Creating pg_trgm takes privileges
+-- managed databases don't always grant; without it the search still works
+-- by scanning, and ranks prefix matches first instead of by similarity.
+DO $$
+BEGIN
+  CREATE EXTENSION IF NOT EXISTS pg_trgm;
+EXCEPTION WHEN insufficient_privilege OR undefined_file THEN
+  RAISE NOTICE 'pg_trgm is unavailable, user search runs without trigram indexes';
+END $$;
+
+-- The extension may live in a schema outside the search path, so check for
+-- its functions rather than its catalog entry
+DO $$
+BEGIN
+  IF to_regproc('similarity') IS NOT NULL THEN
+    CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING gin (email gin_trgm_ops);
+    CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (username gin_trgm_ops);
+  END IF;
+END $$;
@@ -0,0 +1,22 @@
+-- Roles assigned at signup by email domain. signup_domain_roles holds the
+-- rules admins manage at runtime, overriding SIGNUP_DOMAIN_ROLES for the
+-- same domain; user_roles the roles granted to users on top of
+-- users.role.
+CREATE TABLE signup_domain_roles (
+  tenant_id UUID NOT NULL REFERENCES tenants(id),
+  domain VARCHAR(255) NOT NULL,
+  role VARCHAR(50) NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (tenant_id, domain)
+);
+
+-- A grant requiring a verified email only appears in tokens once
+-- users.email_verified is true
+CREATE TABLE user_roles (
+  tenant_id UUID NOT NULL REFERENCES tenants(id),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role VARCHAR(50) NOT NULL,
+  requires_verified_email BOOLEAN NOT NULL DEFAULT FALSE,
+  granted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, role)
+);
@@ -0,0 +1,17 @@
+-- Usernames users renamed away from. Each row stays reserved for its user
+-- until reserved_until, so nobody else can take the name and impersonate
+-- them; the rows also count a user's renames for the monthly cap.
+CREATE TABLE username_history (
+  id UUID PRIMARY KEY,
+  tenant_id UUID NOT NULL REFERENCES tenants(id),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  username VARCHAR(100) NOT NULL,
+  changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  reserved_until TIMESTAMP NOT NULL
+);
+
+-- Index for the reservation check of the availability lookups
+CREATE INDEX idx_username_history_tenant_username ON username_history(tenant_id, username, reserved_until);
+
+-- Index for counting a user's recent renames
+CREATE INDEX idx_username_history_user_changed ON username_history(user_id, changed_at DESC);
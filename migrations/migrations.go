@@ -0,0 +1,21 @@
+// Package migrations embeds the SQL migrations so tests and tools can apply
+// them without reading the source tree
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+	"sort"
+)
+
+// FS holds every migration, named so that file name order is apply order
+//
+//go:embed *.sql
+var FS embed.FS
+
+// Names returns the migration file names in apply order
+func Names() []string {
+	names, _ := fs.Glob(FS, "*.sql")
+	sort.Strings(names)
+	return names
+}
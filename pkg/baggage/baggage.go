@@ -0,0 +1,250 @@
+// Package baggage parses and encodes W3C Baggage, the header gateways and
+// other services use to pass request metadata such as the account tier or
+// experiment flags along a call chain. See https://www.w3.org/TR/baggage/.
+package baggage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Header is the HTTP header carrying baggage
+const Header = "baggage"
+
+// Limits of the specification: a header holds at most MaxMembers entries
+// in at most MaxBytes
+const (
+	MaxMembers = 64
+	MaxBytes   = 8192
+)
+
+// ErrMalformed is returned for a header that isn't valid baggage
+var ErrMalformed = errors.New("malformed baggage")
+
+// Property is metadata attached to a member, with or without a value
+type Property struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+// Member is one key and value of the baggage
+type Member struct {
+	Key        string
+	Value      string
+	Properties []Property
+}
+
+// Baggage is an ordered set of members, keyed by their case-sensitive
+// keys. The zero value is empty.
+type Baggage struct {
+	members []Member
+}
+
+// Parse parses a baggage header. Values are percent-decoded. When a key is
+// repeated, the last member wins.
+func Parse(header string) (Baggage, error) {
+	if len(header) > MaxBytes {
+		return Baggage{}, fmt.Errorf("%w: longer than %d bytes", ErrMalformed, MaxBytes)
+	}
+	if trimOWS(header) == "" {
+		return Baggage{}, nil
+	}
+
+	raws := strings.Split(header, ",")
+	if len(raws) > MaxMembers {
+		return Baggage{}, fmt.Errorf("%w: more than %d members", ErrMalformed, MaxMembers)
+	}
+	var b Baggage
+	for _, raw := range raws {
+		m, err := parseMember(raw)
+		if err != nil {
+			return Baggage{}, err
+		}
+		b = b.With(m)
+	}
+	return b, nil
+}
+
+func parseMember(raw string) (Member, error) {
+	parts := strings.Split(raw, ";")
+	key, value, ok := strings.Cut(parts[0], "=")
+	if !ok {
+		return Member{}, fmt.Errorf("%w: member %q has no value", ErrMalformed, trimOWS(raw))
+	}
+	m := Member{Key: trimOWS(key)}
+	if !validKey(m.Key) {
+		return Member{}, fmt.Errorf("%w: invalid key %q", ErrMalformed, m.Key)
+	}
+	var err error
+	if m.Value, err = decodeValue(trimOWS(value)); err != nil {
+		return Member{}, err
+	}
+
+	for _, rawProp := range parts[1:] {
+		key, value, hasValue := strings.Cut(rawProp, "=")
+		p := Property{Key: trimOWS(key), HasValue: hasValue}
+		if !validKey(p.Key) {
+			return Member{}, fmt.Errorf("%w: invalid property %q", ErrMalformed, p.Key)
+		}
+		if hasValue {
+			if p.Value, err = decodeValue(trimOWS(value)); err != nil {
+				return Member{}, err
+			}
+		}
+		m.Properties = append(m.Properties, p)
+	}
+	return m, nil
+}
+
+// Len returns the number of members
+func (b Baggage) Len() int {
+	return len(b.members)
+}
+
+// Get returns the value of the member with key
+func (b Baggage) Get(key string) (string, bool) {
+	m, ok := b.Member(key)
+	return m.Value, ok
+}
+
+// Member returns the member with key
+func (b Baggage) Member(key string) (Member, bool) {
+	for _, m := range b.members {
+		if m.Key == key {
+			return m, true
+		}
+	}
+	return Member{}, false
+}
+
+// Members returns a copy of the members, in order
+func (b Baggage) Members() []Member {
+	return append([]Member(nil), b.members...)
+}
+
+// With returns a copy of b with m added, replacing the member with the
+// same key
+func (b Baggage) With(m Member) Baggage {
+	members := make([]Member, 0, len(b.members)+1)
+	for _, existing := range b.members {
+		if existing.Key != m.Key {
+			members = append(members, existing)
+		}
+	}
+	return Baggage{members: append(members, m)}
+}
+
+// Keep returns the members of b whose key is in keys
+func (b Baggage) Keep(keys []string) Baggage {
+	var kept []Member
+	for _, m := range b.members {
+		for _, k := range keys {
+			if m.Key == k {
+				kept = append(kept, m)
+				break
+			}
+		}
+	}
+	return Baggage{members: kept}
+}
+
+// String encodes b as a baggage header
+func (b Baggage) String() string {
+	var sb strings.Builder
+	for i, m := range b.members {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(m.Key)
+		sb.WriteByte('=')
+		sb.WriteString(encodeValue(m.Value))
+		for _, p := range m.Properties {
+			sb.WriteByte(';')
+			sb.WriteString(p.Key)
+			if p.HasValue {
+				sb.WriteByte('=')
+				sb.WriteString(encodeValue(p.Value))
+			}
+		}
+	}
+	return sb.String()
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying b, which httpclient forwards
+// on outbound calls
+func NewContext(ctx context.Context, b Baggage) context.Context {
+	return context.WithValue(ctx, contextKey{}, b)
+}
+
+// FromContext returns the baggage set with NewContext, empty without
+func FromContext(ctx context.Context) Baggage {
+	b, _ := ctx.Value(contextKey{}).(Baggage)
+	return b
+}
+
+// trimOWS trims the optional whitespace of RFC 7230, spaces and tabs
+func trimOWS(s string) string {
+	return strings.Trim(s, " \t")
+}
+
+// validKey reports whether s is an RFC 7230 token
+func validKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// valueOctet reports whether c may appear unencoded in a value: printable
+// ASCII but for space, '"', ',', ';' and '\'
+func valueOctet(c byte) bool {
+	return c == 0x21 || (0x23 <= c && c <= 0x2B) || (0x2D <= c && c <= 0x3A) ||
+		(0x3C <= c && c <= 0x5B) || (0x5D <= c && c <= 0x7E)
+}
+
+func decodeValue(s string) (string, error) {
+	for i := 0; i < len(s); i++ {
+		if !valueOctet(s[i]) {
+			return "", fmt.Errorf("%w: invalid character in value %q", ErrMalformed, s)
+		}
+	}
+	v, err := url.PathUnescape(s)
+	if err != nil || !utf8.ValidString(v) {
+		return "", fmt.Errorf("%w: invalid percent-encoding in value %q", ErrMalformed, s)
+	}
+	return v, nil
+}
+
+// encodeValue percent-encodes the bytes of s that can't appear in a value,
+// and '%' itself
+func encodeValue(s string) string {
+	const hex = "0123456789ABCDEF"
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if valueOctet(c) && c != '%' {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteByte('%')
+		sb.WriteByte(hex[c>>4])
+		sb.WriteByte(hex[c&0x0F])
+	}
+	return sb.String()
+}
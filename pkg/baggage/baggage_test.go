@@ -0,0 +1,113 @@
+package baggage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	b, err := Parse(" tier = gold ;source=gw;sampled, cohort=a%20b%2Cc,exp=%E2%9C%93 ,\tflag=on")
+	require.NoError(t, err)
+	assert.Equal(t, 4, b.Len())
+
+	tier, ok := b.Member("tier")
+	require.True(t, ok)
+	assert.Equal(t, Member{
+		Key:        "tier",
+		Value:      "gold",
+		Properties: []Property{{Key: "source", Value: "gw", HasValue: true}, {Key: "sampled"}},
+	}, tier)
+
+	for key, want := range map[string]string{"cohort": "a b,c", "exp": "✓", "flag": "on"} {
+		got, ok := b.Get(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, want, got, key)
+	}
+	_, ok = b.Get("Tier")
+	assert.False(t, ok, "keys are case-sensitive")
+}
+
+func TestParse_Edges(t *testing.T) {
+	b, err := Parse("")
+	require.NoError(t, err)
+	assert.Equal(t, 0, b.Len())
+
+	b, err = Parse("k=1,k=2")
+	require.NoError(t, err)
+	v, _ := b.Get("k")
+	assert.Equal(t, "2", v, "the last member wins")
+	assert.Equal(t, 1, b.Len())
+
+	b, err = Parse("empty=")
+	require.NoError(t, err)
+	v, ok := b.Get("empty")
+	assert.True(t, ok)
+	assert.Empty(t, v)
+
+	b, err = Parse("plus=a+b")
+	require.NoError(t, err)
+	v, _ = b.Get("plus")
+	assert.Equal(t, "a+b", v, "'+' isn't a space in baggage")
+}
+
+func TestParse_Malformed(t *testing.T) {
+	for _, header := range []string{
+		"novalue",
+		"=value",
+		"k=1,,k2=2",
+		"k=1,",
+		"bad key=1",
+		"k=a b",
+		`k="quoted"`,
+		`k=back\slash`,
+		"k=%",
+		"k=%zz",
+		"k=%ff",
+		"k=1;=p",
+		"k=1;bad prop",
+		"k=1;p=%zz",
+		"ké=1",
+		strings.Repeat("k=1,", MaxMembers) + "last=1",
+		"k=" + strings.Repeat("a", MaxBytes),
+	} {
+		_, err := Parse(header)
+		assert.ErrorIs(t, err, ErrMalformed, header)
+	}
+}
+
+func TestString_RoundTrips(t *testing.T) {
+	b := Baggage{}.
+		With(Member{Key: "tier", Value: "gold", Properties: []Property{{Key: "ttl", Value: "60", HasValue: true}, {Key: "sticky"}}}).
+		With(Member{Key: "note", Value: `50% off; "sale", ✓`})
+
+	header := b.String()
+	assert.Equal(t, "tier=gold;ttl=60;sticky,note=50%25%20off%3B%20%22sale%22%2C%20%E2%9C%93", header)
+
+	parsed, err := Parse(header)
+	require.NoError(t, err)
+	assert.Equal(t, b, parsed)
+}
+
+func TestKeep(t *testing.T) {
+	b, err := Parse("tier=gold,internal=x,exp=42")
+	require.NoError(t, err)
+
+	kept := b.Keep([]string{"exp", "tier", "missing"})
+	assert.Equal(t, "tier=gold,exp=42", kept.String(), "the header's order is kept")
+	assert.Equal(t, 0, b.Keep(nil).Len())
+	assert.Equal(t, 3, b.Len(), "b itself is unchanged")
+}
+
+func TestContext(t *testing.T) {
+	assert.Equal(t, 0, FromContext(context.Background()).Len())
+
+	b, err := Parse("tier=gold")
+	require.NoError(t, err)
+	v, ok := FromContext(NewContext(context.Background(), b)).Get("tier")
+	assert.True(t, ok)
+	assert.Equal(t, "gold", v)
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is a key/value store with per-entry expiry. The in-memory
+// implementation is used today; the interface leaves room for a shared
+// backend (e.g. Redis) when the service runs with several replicas.
+type Cache interface {
+	// Get returns the value stored under key if present and not expired.
+	Get(key string) (any, bool)
+	// Set stores value under key. A ttl <= 0 means the entry never expires.
+	Set(key string, value any, ttl time.Duration)
+	// Add stores value only if key is absent (or expired) and reports
+	// whether it did so. It is atomic with respect to other Add calls.
+	Add(key string, value any, ttl time.Duration) bool
+	// Delete removes key.
+	Delete(key string)
+	// DeletePrefix removes every key starting with prefix.
+	DeletePrefix(prefix string)
+}
+
+type entry struct {
+	value     any
+	expiresAt time.Time
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// Memory is a concurrency-safe in-process Cache.
+type Memory struct {
+	mu    sync.Mutex
+	items map[string]entry
+	now   func() time.Time
+
+	sets int
+}
+
+// sweepEvery controls how often (in Set/Add calls) expired entries are purged.
+const sweepEvery = 1024
+
+// NewMemory creates a new in-memory cache
+func NewMemory() *Memory {
+	return &Memory{
+		items: make(map[string]entry),
+		now:   time.Now,
+	}
+}
+
+// WithClock overrides the time source, for tests.
+func (m *Memory) WithClock(now func() time.Time) *Memory {
+	m.now = now
+	return m
+}
+
+// Get returns the value for key
+func (m *Memory) Get(key string) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(m.now()) {
+		delete(m.items, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Set stores value under key
+func (m *Memory) Set(key string, value any, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(key, value, ttl)
+}
+
+// Add stores value under key only if it is not already present
+func (m *Memory) Add(key string, value any, ttl time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.items[key]; ok && !e.expired(m.now()) {
+		return false
+	}
+	m.set(key, value, ttl)
+	return true
+}
+
+// Delete removes key
+func (m *Memory) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, key)
+}
+
+// DeletePrefix removes all keys with the given prefix
+func (m *Memory) DeletePrefix(prefix string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k := range m.items {
+		if strings.HasPrefix(k, prefix) {
+			delete(m.items, k)
+		}
+	}
+}
+
+// Len returns the number of stored entries, including expired ones not yet purged
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.items)
+}
+
+func (m *Memory) set(key string, value any, ttl time.Duration) {
+	var exp time.Time
+	if ttl > 0 {
+		exp = m.now().Add(ttl)
+	}
+	m.items[key] = entry{value: value, expiresAt: exp}
+
+	m.sets++
+	if m.sets%sweepEvery == 0 {
+		now := m.now()
+		for k, e := range m.items {
+			if e.expired(now) {
+				delete(m.items, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_SetGetExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewMemory().WithClock(func() time.Time { return now })
+
+	c.Set("a", 1, time.Minute)
+	c.Set("forever", 2, 0)
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	now = now.Add(time.Minute)
+	_, ok = c.Get("a")
+	assert.False(t, ok, "entry should expire exactly at its ttl")
+
+	_, ok = c.Get("forever")
+	assert.True(t, ok, "ttl <= 0 should never expire")
+}
+
+func TestMemory_AddIsAtomic(t *testing.T) {
+	c := NewMemory()
+
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c.Add("nonce", true, time.Minute) {
+				wins.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), wins.Load())
+}
+
+func TestMemory_DeletePrefix(t *testing.T) {
+	c := NewMemory()
+	c.Set("user:1:a", 1, 0)
+	c.Set("user:1:b", 1, 0)
+	c.Set("user:2:a", 1, 0)
+
+	c.DeletePrefix("user:1:")
+
+	assert.Equal(t, 1, c.Len())
+	_, ok := c.Get("user:2:a")
+	assert.True(t, ok)
+}
@@ -0,0 +1,48 @@
+// Package clock is the time source for every timestamp the service stores
+// or hands out. Components take a func() time.Time, defaulting to Now, so
+// tests can swap in a Frozen clock and assert exact times.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Now returns the current time in UTC, so stored timestamps never mix
+// offsets across servers in different zones
+func Now() time.Time {
+	return time.Now().UTC()
+}
+
+// Frozen is a clock that only moves when told to. Its Now method can be
+// passed wherever a func() time.Time is expected.
+type Frozen struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewFrozen creates a clock stopped at t, in UTC
+func NewFrozen(t time.Time) *Frozen {
+	return &Frozen{t: t.UTC()}
+}
+
+// Now returns the frozen time
+func (f *Frozen) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.t
+}
+
+// Advance moves the clock forward by d
+func (f *Frozen) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.t = f.t.Add(d)
+	f.mu.Unlock()
+}
+
+// Set moves the clock to t, in UTC
+func (f *Frozen) Set(t time.Time) {
+	f.mu.Lock()
+	f.t = t.UTC()
+	f.mu.Unlock()
+}
@@ -0,0 +1,31 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNow_IsUTC(t *testing.T) {
+	assert.Equal(t, time.UTC, Now().Location())
+}
+
+func TestFrozen(t *testing.T) {
+	bangkok := time.FixedZone("ICT", 7*60*60)
+	start := time.Date(2026, 1, 2, 10, 0, 0, 0, bangkok)
+
+	c := NewFrozen(start)
+	assert.Equal(t, time.UTC, c.Now().Location(), "the frozen time is kept in UTC")
+	assert.True(t, c.Now().Equal(start))
+	assert.Equal(t, c.Now(), c.Now(), "the clock doesn't move on its own")
+
+	c.Advance(90 * time.Second)
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 1, 30, 0, time.UTC), c.Now())
+
+	c.Set(start)
+	assert.True(t, c.Now().Equal(start))
+
+	var now func() time.Time = c.Now
+	assert.True(t, now().Equal(start), "Now fits the func() time.Time clocks take")
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrNoKeyRing is returned when an encrypted value is read without a key
+// ring configured
+var ErrNoKeyRing = errors.New("encrypted value read without a key ring")
+
+var defaultRing atomic.Pointer[KeyRing]
+
+// SetDefault sets the key ring EncryptedString values are encrypted with.
+// nil turns encryption off: values are then written in the clear, which
+// only suits development.
+func SetDefault(kr *KeyRing) {
+	defaultRing.Store(kr)
+}
+
+// Default returns the key ring set with SetDefault, or nil
+func Default() *KeyRing {
+	return defaultRing.Load()
+}
+
+// EncryptedString is a string column encrypted at rest with the default
+// key ring. It is written through driver.Valuer and read through
+// sql.Scanner, both of which pgx honors, so struct fields of this type
+// are encrypted by every query that writes or scans them.
+type EncryptedString string
+
+// Value implements driver.Valuer, encrypting s with the primary key
+func (s EncryptedString) Value() (driver.Value, error) {
+	kr := Default()
+	if kr == nil {
+		return string(s), nil
+	}
+	return kr.Encrypt(string(s))
+}
+
+// Scan implements sql.Scanner, decrypting the stored value. NULL reads as
+// the empty string and plaintext left from before encryption as is.
+func (s *EncryptedString) Scan(src any) error {
+	var stored string
+	switch v := src.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into EncryptedString", src)
+	}
+
+	if !IsEncrypted(stored) {
+		*s = EncryptedString(stored)
+		return nil
+	}
+	kr := Default()
+	if kr == nil {
+		return ErrNoKeyRing
+	}
+	plaintext, err := kr.Decrypt(stored)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// String returns the plaintext
+func (s EncryptedString) String() string {
+	return string(s)
+}
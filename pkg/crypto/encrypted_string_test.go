@@ -0,0 +1,64 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withDefault installs kr as the default key ring for the test
+func withDefault(t *testing.T, kr *KeyRing) {
+	t.Helper()
+	prev := Default()
+	SetDefault(kr)
+	t.Cleanup(func() { SetDefault(prev) })
+}
+
+func TestEncryptedString_PgxRoundTrip(t *testing.T) {
+	kr, err := NewKeyRing("v1", map[string][]byte{"v1": testKey(1)})
+	require.NoError(t, err)
+	withDefault(t, kr)
+
+	m := pgtype.NewMap()
+	for _, format := range []int16{pgtype.TextFormatCode, pgtype.BinaryFormatCode} {
+		buf, err := m.Encode(pgtype.TextOID, format, EncryptedString("Jane Doe"), nil)
+		require.NoError(t, err)
+		assert.True(t, IsEncrypted(string(buf)), "pgx writes the ciphertext")
+
+		var got EncryptedString
+		require.NoError(t, m.Scan(pgtype.TextOID, format, buf, &got))
+		assert.Equal(t, EncryptedString("Jane Doe"), got, "pgx reads the plaintext")
+	}
+
+	var got EncryptedString
+	require.NoError(t, m.Scan(pgtype.TextOID, pgtype.TextFormatCode, nil, &got))
+	assert.Empty(t, got, "NULL reads as empty")
+	require.NoError(t, m.Scan(pgtype.TextOID, pgtype.TextFormatCode, []byte("legacy row"), &got))
+	assert.Equal(t, EncryptedString("legacy row"), got, "plaintext rows still read")
+}
+
+func TestEncryptedString_WithoutKeyRing(t *testing.T) {
+	withDefault(t, nil)
+
+	v, err := EncryptedString("Jane Doe").Value()
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe", v, "values are written in the clear")
+
+	var got EncryptedString
+	assert.ErrorIs(t, got.Scan("enc:v1:abc"), ErrNoKeyRing)
+}
+
+func TestEncryptedString_RejectsTampering(t *testing.T) {
+	kr, err := NewKeyRing("v1", map[string][]byte{"v1": testKey(1)})
+	require.NoError(t, err)
+	withDefault(t, kr)
+
+	v, err := EncryptedString("Jane Doe").Value()
+	require.NoError(t, err)
+	tampered := v.(string)[:len(v.(string))-2] + "AA"
+
+	var got EncryptedString
+	assert.ErrorIs(t, got.Scan(tampered), ErrTampered)
+}
@@ -0,0 +1,176 @@
+// Package crypto encrypts sensitive column values at rest with AES-256-GCM
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the length of an AES-256 key in bytes
+const KeySize = 32
+
+// prefix marks encrypted values, so plaintext written before encryption was
+// turned on can still be told apart and read
+const prefix = "enc:"
+
+var (
+	// ErrUnknownKey is returned when a ciphertext names a key the ring
+	// doesn't hold
+	ErrUnknownKey = errors.New("ciphertext uses an unknown key")
+	// ErrTampered is returned when a ciphertext fails authentication
+	ErrTampered = errors.New("ciphertext is malformed or was tampered with")
+)
+
+// KeyRing holds the column encryption keys by ID. New values are encrypted
+// with the primary key and prefixed with its ID; the other keys only
+// decrypt values written before a rotation.
+type KeyRing struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyRing creates a key ring encrypting with keys[primary]
+func NewKeyRing(primary string, keys map[string][]byte) (*KeyRing, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the key ring", primary)
+	}
+	kr := &KeyRing{primary: primary, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.ContainsAny(id, ":,") {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key %q must be %d bytes, got %d", id, KeySize, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		kr.aeads[id] = aead
+	}
+	return kr, nil
+}
+
+// ParseKeyRing parses "id:base64key,id:base64key", the first key being the
+// primary
+func ParseKeyRing(spec string) (*KeyRing, error) {
+	keys := make(map[string][]byte)
+	var primary string
+	for entry := range strings.SplitSeq(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("key %q must be id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid base64: %w", id, err)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("key %q is listed twice", id)
+		}
+		keys[id] = key
+		if primary == "" {
+			primary = id
+		}
+	}
+	if primary == "" {
+		return nil, errors.New("no keys given")
+	}
+	return NewKeyRing(primary, keys)
+}
+
+// DeriveKeyRing derives one key per ID from master with HKDF-SHA256, the
+// first ID being the primary. Rotating means prepending a new ID.
+func DeriveKeyRing(master []byte, ids ...string) (*KeyRing, error) {
+	if len(master) < KeySize {
+		return nil, fmt.Errorf("master key must be at least %d bytes", KeySize)
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no key IDs given")
+	}
+	keys := make(map[string][]byte, len(ids))
+	for _, id := range ids {
+		key, err := DeriveKey(master, id)
+		if err != nil {
+			return nil, err
+		}
+		keys[id] = key
+	}
+	return NewKeyRing(ids[0], keys)
+}
+
+// DeriveKey derives the column encryption key named id from master
+func DeriveKey(master []byte, id string) ([]byte, error) {
+	return hkdf.Key(sha256.New, master, nil, "go-service-api column encryption "+id, KeySize)
+}
+
+// Primary returns the ID of the key new values are encrypted with
+func (kr *KeyRing) Primary() string {
+	return kr.primary
+}
+
+// Encrypt encrypts plaintext with the primary key. The result reads
+// "enc:<key id>:<base64 nonce and sealed data>".
+func (kr *KeyRing) Encrypt(plaintext string) (string, error) {
+	aead := kr.aeads[kr.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// The key ID is authenticated too, so it can't be swapped
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(kr.primary))
+	return prefix + kr.primary + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt with any key in the ring.
+// Values without the encryption prefix are returned as they are, since
+// rows written before encryption was enabled hold plaintext.
+func (kr *KeyRing) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", ErrTampered
+	}
+	aead, ok := kr.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrTampered
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(id))
+	if err != nil {
+		return "", ErrTampered
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value is plaintext or encrypted with a key
+// other than the primary
+func (kr *KeyRing) NeedsRotation(value string) bool {
+	return !strings.HasPrefix(value, prefix+kr.primary+":")
+}
+
+// IsEncrypted reports whether value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
@@ -0,0 +1,123 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestKeyRing_RoundTrip(t *testing.T) {
+	kr, err := NewKeyRing("v1", map[string][]byte{"v1": testKey(1)})
+	require.NoError(t, err)
+
+	ciphertext, err := kr.Encrypt("Jane Doe")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(ciphertext, "enc:v1:"), "the key ID prefixes the ciphertext")
+	assert.NotContains(t, ciphertext, "Jane")
+
+	again, err := kr.Encrypt("Jane Doe")
+	require.NoError(t, err)
+	assert.NotEqual(t, ciphertext, again, "every encryption uses a fresh nonce")
+
+	plaintext, err := kr.Decrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe", plaintext)
+
+	plaintext, err = kr.Decrypt("written before encryption")
+	require.NoError(t, err)
+	assert.Equal(t, "written before encryption", plaintext)
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	old, err := NewKeyRing("v1", map[string][]byte{"v1": testKey(1)})
+	require.NoError(t, err)
+	ciphertext, err := old.Encrypt("Jane Doe")
+	require.NoError(t, err)
+
+	rotated, err := NewKeyRing("v2", map[string][]byte{"v1": testKey(1), "v2": testKey(2)})
+	require.NoError(t, err)
+
+	plaintext, err := rotated.Decrypt(ciphertext)
+	require.NoError(t, err, "values under the old key still decrypt")
+	assert.Equal(t, "Jane Doe", plaintext)
+	assert.True(t, rotated.NeedsRotation(ciphertext))
+	assert.True(t, rotated.NeedsRotation("plaintext"))
+
+	fresh, err := rotated.Encrypt(plaintext)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(fresh, "enc:v2:"), "new values use the new primary key")
+	assert.False(t, rotated.NeedsRotation(fresh))
+
+	// Once the old key is dropped its values no longer decrypt
+	_, err = old.Decrypt(fresh)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestKeyRing_RejectsTamperedCiphertext(t *testing.T) {
+	kr, err := NewKeyRing("v1", map[string][]byte{"v1": testKey(1), "v2": testKey(2)})
+	require.NoError(t, err)
+	ciphertext, err := kr.Encrypt("Jane Doe")
+	require.NoError(t, err)
+
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(ciphertext, "enc:v1:"))
+	require.NoError(t, err)
+	sealed[len(sealed)-1] ^= 1
+	flipped := "enc:v1:" + base64.RawStdEncoding.EncodeToString(sealed)
+
+	for name, value := range map[string]string{
+		"flipped bit":  flipped,
+		"swapped key":  strings.Replace(ciphertext, "enc:v1:", "enc:v2:", 1),
+		"truncated":    ciphertext[:len("enc:v1:")+8],
+		"not base64":   "enc:v1:!!!",
+		"missing data": "enc:v1",
+	} {
+		_, err := kr.Decrypt(value)
+		assert.ErrorIs(t, err, ErrTampered, name)
+	}
+}
+
+func TestParseKeyRing(t *testing.T) {
+	k1, k2 := base64.StdEncoding.EncodeToString(testKey(1)), base64.StdEncoding.EncodeToString(testKey(2))
+
+	kr, err := ParseKeyRing("v2:" + k2 + ", v1:" + k1)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", kr.Primary(), "the first key is the primary")
+
+	for _, spec := range []string{"", "v1", "v1:not-base64", "v1:" + base64.StdEncoding.EncodeToString([]byte("short")), "v1:" + k1 + ",v1:" + k2} {
+		_, err := ParseKeyRing(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestDeriveKeyRing(t *testing.T) {
+	master := testKey(9)
+
+	kr, err := DeriveKeyRing(master, "v2", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", kr.Primary())
+
+	v1, err := DeriveKeyRing(master, "v1")
+	require.NoError(t, err)
+	ciphertext, err := v1.Encrypt("Jane Doe")
+	require.NoError(t, err)
+	plaintext, err := kr.Decrypt(ciphertext)
+	require.NoError(t, err, "derivation is deterministic per key ID")
+	assert.Equal(t, "Jane Doe", plaintext)
+
+	k1, err := DeriveKey(master, "v1")
+	require.NoError(t, err)
+	k2, err := DeriveKey(master, "v2")
+	require.NoError(t, err)
+	assert.NotEqual(t, k1, k2)
+
+	_, err = DeriveKeyRing([]byte("too short"), "v1")
+	assert.Error(t, err)
+}
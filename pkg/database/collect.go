@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CollectOneRow scans the only row of rows into a T, matching columns to
+// the struct's db tags by name, and closes rows. It returns pgx.ErrNoRows
+// when there is no row. Every selected column needs a field and every
+// field a column, so a query and struct that drift apart fail loudly
+// instead of filling the wrong fields.
+func CollectOneRow[T any](rows pgx.Rows) (*T, error) {
+	return pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[T])
+}
+
+// CollectRows scans every row of rows into a T like CollectOneRow and
+// closes rows
+func CollectRows[T any](rows pgx.Rows) ([]T, error) {
+	return pgx.CollectRows(rows, pgx.RowToStructByName[T])
+}
+
+// ScanRow scans the current row into a T like CollectOneRow, for loops that
+// handle rows one at a time instead of collecting them
+func ScanRow[T any](row pgx.CollectableRow) (T, error) {
+	return pgx.RowToStructByName[T](row)
+}
+
+// QueryOne runs query on q and collects its only row with CollectOneRow.
+// It suits INSERT and UPDATE statements with a RETURNING clause as well.
+func QueryOne[T any](ctx context.Context, q Querier, query string, args ...any) (*T, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return CollectOneRow[T](rows)
+}
+
+// QueryAll runs query on q and collects every row with CollectRows
+func QueryAll[T any](ctx context.Context, q Querier, query string, args ...any) ([]T, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return CollectRows[T](rows)
+}
@@ -2,9 +2,11 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"dvith.com/go-service-api/pkg/logger"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -15,14 +17,32 @@ type DBPool struct {
 	pool *pgxpool.Pool
 }
 
+// Querier is the subset of query methods shared by DBPool and pgx.Tx, so
+// repositories can run the same statements inside or outside a transaction.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
 // NewDB creates a new database connection pool with the given DSN
 func NewDB(ctx context.Context, databaseURL string) (*DBPool, error) {
 	if databaseURL == "" {
 		return nil, fmt.Errorf("database URL is required")
 	}
 
+	dsn, stripped, err := SanitizeDSN(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(stripped) > 0 {
+		logger.Warn("ignoring database URL parameters pgx doesn't support", map[string]any{
+			"params": stripped,
+		})
+	}
+
 	// Parse the connection string
-	config, err := pgxpool.ParseConfig(databaseURL)
+	config, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse database URL: %w", err)
 	}
@@ -34,6 +54,9 @@ func NewDB(ctx context.Context, databaseURL string) (*DBPool, error) {
 	config.MaxConnIdleTime = 2 * time.Minute
 	config.HealthCheckPeriod = 1 * time.Minute
 
+	// Count each request's queries for the slow request log
+	config.ConnConfig.Tracer = QueryTracer{}
+
 	// Create the connection pool
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
@@ -74,6 +97,57 @@ func (db *DBPool) Begin(ctx context.Context) (pgx.Tx, error) {
 	return db.pool.Begin(ctx)
 }
 
+// WithTx runs fn inside a transaction. The transaction is committed when fn
+// returns nil and rolled back when fn returns an error or panics.
+func (db *DBPool) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return runTx(ctx, db.pool, fn)
+}
+
+// Prepare parses and plans sql on a pooled connection without running it,
+// so errors such as unknown tables or columns surface before the statement
+// is ever used. The statement is left unnamed and isn't kept.
+func (db *DBPool) Prepare(ctx context.Context, sql string) error {
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	_, err = conn.Conn().PgConn().Prepare(ctx, "", sql, nil)
+	return err
+}
+
+// beginner starts transactions; pgxpool.Pool satisfies it and tests fake it
+type beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+func runTx(ctx context.Context, b beginner, fn func(tx pgx.Tx) error) (err error) {
+	tx, err := b.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 // Close closes all connections in the pool
 func (db *DBPool) Close() {
 	if db.pool != nil {
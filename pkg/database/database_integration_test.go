@@ -0,0 +1,81 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/internal/testdb"
+	"dvith.com/go-service-api/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) { testdb.Main(m) }
+
+// TestDBPool_ValidConnection connects to the test server
+func TestDBPool_ValidConnection(t *testing.T) {
+	url := testdb.URL(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	db, err := database.NewDB(ctx, url)
+	require.NoError(t, err)
+	defer db.Close()
+
+	assert.NotNil(t, db)
+	assert.NotNil(t, db.GetPool())
+}
+
+// TestDBPool_HealthCheck tests the health check functionality
+func TestDBPool_HealthCheck(t *testing.T) {
+	db := testdb.Open(t)
+
+	healthCtx, healthCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer healthCancel()
+
+	err := db.Health(healthCtx)
+	assert.NoError(t, err)
+}
+
+// TestDBPool_GetPool tests the GetPool method
+func TestDBPool_GetPool(t *testing.T) {
+	db := testdb.Open(t)
+
+	pool := db.GetPool()
+	require.NotNil(t, pool)
+	assert.Same(t, pool, db.GetPool())
+	assert.NoError(t, pool.Ping(context.Background()))
+}
+
+func TestQueryCounter_CountsPoolQueries(t *testing.T) {
+	db := testdb.Open(t)
+
+	ctx, qc := database.WithQueryCounter(context.Background())
+	var n int
+	require.NoError(t, db.QueryRow(ctx, "SELECT 1").Scan(&n))
+	_, err := db.Exec(ctx, "SELECT 2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, qc.Count())
+}
+
+func TestWithTx_RollsBackOnError(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	_, err := db.Exec(ctx, "CREATE TABLE tx_probe (n int)")
+	require.NoError(t, err)
+
+	err = db.WithTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "INSERT INTO tx_probe VALUES (1)"); err != nil {
+			return err
+		}
+		return context.Canceled
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	var count int
+	require.NoError(t, db.QueryRow(ctx, "SELECT count(*) FROM tx_probe").Scan(&count))
+	assert.Zero(t, count)
+}
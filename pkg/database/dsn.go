@@ -0,0 +1,121 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ErrUnsupportedScheme is returned for a database URL that isn't
+// postgres:// or postgresql://
+var ErrUnsupportedScheme = errors.New("unsupported database URL scheme")
+
+// dsnParams are the query parameters pgx understands, plus the server
+// settings commonly passed in a URL. pgx sends any other parameter to the
+// server as a setting, which fails the connection.
+var dsnParams = map[string]bool{
+	// libpq connection parameters pgx reads
+	"host": true, "port": true, "dbname": true, "database": true, "user": true,
+	"password": true, "passfile": true, "connect_timeout": true, "sslmode": true,
+	"sslkey": true, "sslcert": true, "sslrootcert": true, "sslpassword": true,
+	"sslsni": true, "sslnegotiation": true, "krbspn": true, "krbsrvname": true,
+	"target_session_attrs": true, "service": true, "servicefile": true,
+	// pgx and pgxpool settings
+	"statement_cache_capacity": true, "description_cache_capacity": true,
+	"default_query_exec_mode": true, "pool_max_conns": true, "pool_min_conns": true,
+	"pool_min_idle_conns": true, "pool_max_conn_lifetime": true,
+	"pool_max_conn_lifetime_jitter": true, "pool_max_conn_idle_time": true,
+	"pool_health_check_period": true,
+	// Server settings
+	"application_name": true, "search_path": true, "timezone": true,
+	"client_encoding": true, "options": true, "statement_timeout": true,
+	"lock_timeout": true, "idle_in_transaction_session_timeout": true,
+	"datestyle": true, "intervalstyle": true, "default_transaction_isolation": true,
+	"default_transaction_read_only": true,
+}
+
+// CheckScheme returns ErrUnsupportedScheme, naming the scheme, unless dsn
+// is a postgres:// or postgresql:// URL. The rest of dsn, credentials
+// included, is never part of the error.
+func CheckScheme(dsn string) error {
+	scheme, _, ok := strings.Cut(strings.TrimSpace(dsn), "://")
+	if !ok || scheme == "" || strings.ContainsFunc(scheme, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '+' || r == '-' || r == '.')
+	}) {
+		return fmt.Errorf("%w: no scheme, expected postgres:// or postgresql://", ErrUnsupportedScheme)
+	}
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return nil
+	default:
+		return fmt.Errorf("%w %q: only postgres:// and postgresql:// are supported", ErrUnsupportedScheme, scheme)
+	}
+}
+
+// SanitizeDSN prepares a database URL for pgx. It drops query parameters
+// pgx would send to the server as unknown settings, such as the
+// "schema=main" other drivers accept, and returns their names. A unix
+// socket directory given libpq style as a percent-encoded host, as in
+// postgres://app@%2Fvar%2Frun%2Fpostgresql/app, is moved to the host
+// parameter, which is the form pgx parses.
+func SanitizeDSN(dsn string) (string, []string, error) {
+	if err := CheckScheme(dsn); err != nil {
+		return "", nil, err
+	}
+	dsn = strings.TrimSpace(dsn)
+
+	socket, dsn, err := extractSocketHost(dsn)
+	if err != nil {
+		return "", nil, err
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		// Only the parser's reason: the URL holds the password
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", nil, fmt.Errorf("invalid database URL: %w", err)
+	}
+
+	query := u.Query()
+	var stripped []string
+	for name := range query {
+		if !dsnParams[strings.ToLower(name)] {
+			stripped = append(stripped, name)
+			query.Del(name)
+		}
+	}
+	sort.Strings(stripped)
+	if socket != "" && query.Get("host") == "" {
+		query.Set("host", socket)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), stripped, nil
+}
+
+// extractSocketHost removes a percent-encoded socket directory from the
+// host of dsn and returns it unescaped, along with the rest of dsn
+func extractSocketHost(dsn string) (string, string, error) {
+	scheme, rest, _ := strings.Cut(dsn, "://")
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	authority := rest[:end]
+	userinfo, host := "", authority
+	if at := strings.LastIndexByte(authority, '@'); at >= 0 {
+		userinfo, host = authority[:at+1], authority[at+1:]
+	}
+	if !strings.HasPrefix(strings.ToLower(host), "%2f") {
+		return "", dsn, nil
+	}
+
+	socket, err := url.PathUnescape(host)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid database URL: socket host: %w", err)
+	}
+	return socket, scheme + "://" + userinfo + rest[end:], nil
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QueryCounter counts the statements run with a context, see
+// WithQueryCounter
+type QueryCounter struct {
+	n atomic.Int64
+}
+
+// Count returns the statements counted so far
+func (qc *QueryCounter) Count() int {
+	return int(qc.n.Load())
+}
+
+type queryCounterKey struct{}
+
+// WithQueryCounter returns a context whose statements, and those of every
+// context derived from it, are counted by the returned counter when they
+// run on a DBPool
+func WithQueryCounter(ctx context.Context) (context.Context, *QueryCounter) {
+	qc := &QueryCounter{}
+	return context.WithValue(ctx, queryCounterKey{}, qc), qc
+}
+
+// QueryCounterFromContext returns the counter installed by
+// WithQueryCounter, or nil
+func QueryCounterFromContext(ctx context.Context) *QueryCounter {
+	qc, _ := ctx.Value(queryCounterKey{}).(*QueryCounter)
+	return qc
+}
+
+// QueryTracer is the pgx tracer NewDB installs. It counts every query,
+// inside transactions too, on the context's QueryCounter.
+type QueryTracer struct{}
+
+// TraceQueryStart counts the query
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	if qc := QueryCounterFromContext(ctx); qc != nil {
+		qc.n.Add(1)
+	}
+	return ctx
+}
+
+// TraceQueryEnd does nothing; queries are counted when they start
+func (QueryTracer) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLSTATE codes for conflicts between concurrent transactions, which go
+// away when the transaction is run again
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// Defaults for TxRetryOptions
+const (
+	DefaultTxAttempts  = 3
+	DefaultTxBaseDelay = 10 * time.Millisecond
+	DefaultTxMaxDelay  = 250 * time.Millisecond
+)
+
+// ErrTxConflict is returned by WithTxRetry when every attempt hit a
+// serialization failure or deadlock. The last database error stays in the
+// chain.
+var ErrTxConflict = errors.New("transaction kept conflicting with concurrent transactions")
+
+// TxRetryOptions configures WithTxRetry. The zero value uses the defaults.
+type TxRetryOptions struct {
+	// MaxAttempts bounds how many times fn runs, the first one included
+	MaxAttempts int
+	// BaseDelay is the pause before the first retry; it doubles for each
+	// later retry up to MaxDelay, and every pause is jittered
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Reset runs before every retry. Use it to clear the variables fn
+	// writes its results to, so values from an attempt that rolled back
+	// never survive into the next one.
+	Reset func()
+}
+
+// WithTxRetry is WithTx, running the whole transaction again when Postgres
+// aborts it with a serialization failure (40001) or deadlock (40P01),
+// whether fn or the commit hit it.
+//
+// fn may run several times, so it must be side-effect-safe: it may only
+// change state through tx, which is rolled back between attempts, and must
+// not send mail, publish events or touch caches outside it. Results it
+// hands back through captured variables are cleared with opts.Reset.
+func (db *DBPool) WithTxRetry(ctx context.Context, opts TxRetryOptions, fn func(tx pgx.Tx) error) error {
+	return withTxRetry(ctx, db.pool, opts, fn)
+}
+
+func withTxRetry(ctx context.Context, b beginner, opts TxRetryOptions, fn func(tx pgx.Tx) error) error {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultTxAttempts
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = DefaultTxBaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultTxMaxDelay
+	}
+
+	delay := opts.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, b, fn)
+		if !IsTxConflict(err) {
+			return err
+		}
+		if attempt >= opts.MaxAttempts {
+			return fmt.Errorf("%w after %d attempts: %w", ErrTxConflict, attempt, err)
+		}
+
+		// Half fixed, half random, so colliding transactions drift apart
+		pause := delay/2 + rand.N(delay/2+1)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-time.After(pause):
+		}
+		delay = min(2*delay, opts.MaxDelay)
+
+		if opts.Reset != nil {
+			opts.Reset()
+		}
+	}
+}
+
+// IsTxConflict reports whether err is a serialization failure or deadlock
+// that running the transaction again can resolve
+func IsTxConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == sqlStateSerializationFailure || pgErr.Code == sqlStateDeadlockDetected
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// conflictingDB hands out transactions whose Exec fails with code for the
+// first conflicts calls
+type conflictingDB struct {
+	code      string
+	conflicts int
+	execs     int
+	commits   int
+	rollbacks int
+}
+
+type fakeTx struct {
+	pgx.Tx
+	db *conflictingDB
+}
+
+func (db *conflictingDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return &fakeTx{db: db}, nil
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tx.db.execs++
+	if tx.db.execs <= tx.db.conflicts {
+		return pgconn.CommandTag{}, &pgconn.PgError{Code: tx.db.code}
+	}
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	tx.db.commits++
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	tx.db.rollbacks++
+	return nil
+}
+
+var fastRetry = TxRetryOptions{BaseDelay: time.Microsecond, MaxDelay: time.Microsecond}
+
+func TestWithTxRetry_RetriesSerializationFailure(t *testing.T) {
+	db := &conflictingDB{code: sqlStateSerializationFailure, conflicts: 2}
+	attempts, resets := 0, 0
+	var updated int64
+
+	opts := fastRetry
+	opts.Reset = func() {
+		resets++
+		updated = 0
+	}
+	err := withTxRetry(context.Background(), db, opts, func(tx pgx.Tx) error {
+		attempts++
+		tag, err := tx.Exec(context.Background(), "UPDATE users SET name = $1", "x")
+		if err != nil {
+			return err
+		}
+		updated += tag.RowsAffected()
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 2, resets)
+	assert.Equal(t, int64(1), updated)
+	assert.Equal(t, 2, db.rollbacks)
+	assert.Equal(t, 1, db.commits)
+}
+
+func TestWithTxRetry_GivesUp(t *testing.T) {
+	db := &conflictingDB{code: sqlStateDeadlockDetected, conflicts: 10}
+	attempts := 0
+
+	err := withTxRetry(context.Background(), db, fastRetry, func(tx pgx.Tx) error {
+		attempts++
+		_, err := tx.Exec(context.Background(), "UPDATE users SET name = $1", "x")
+		return err
+	})
+
+	require.ErrorIs(t, err, ErrTxConflict)
+	assert.True(t, IsTxConflict(err), "the last database error stays in the chain")
+	assert.Equal(t, DefaultTxAttempts, attempts)
+	assert.Equal(t, 0, db.commits)
+}
+
+func TestWithTxRetry_OtherErrorsAreNotRetried(t *testing.T) {
+	db := &conflictingDB{code: "23505", conflicts: 10}
+	attempts := 0
+
+	err := withTxRetry(context.Background(), db, fastRetry, func(tx pgx.Tx) error {
+		attempts++
+		_, err := tx.Exec(context.Background(), "INSERT INTO users DEFAULT VALUES")
+		return err
+	})
+
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrTxConflict))
+	assert.Equal(t, 1, attempts)
+}
+
+func TestWithTxRetry_StopsWhenContextDone(t *testing.T) {
+	db := &conflictingDB{code: sqlStateSerializationFailure, conflicts: 10}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := withTxRetry(ctx, db, TxRetryOptions{BaseDelay: time.Hour, MaxDelay: time.Hour}, func(tx pgx.Tx) error {
+		attempts++
+		_, err := tx.Exec(ctx, "UPDATE users SET name = $1", "x")
+		return err
+	})
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,109 @@
+// Package emailaddr validates email addresses more strictly than a syntax
+// check alone: RFC 5322 syntax, the SMTP length limits, a domain with a
+// dot, and optionally a DNS lookup of the domain's mail servers.
+// Internationalized domains are accepted and normalized to punycode.
+package emailaddr
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// Length limits from RFC 5321, applied to the normalized address
+const (
+	MaxLength      = 320
+	MaxLocalLength = 64
+)
+
+// Validation errors
+var (
+	ErrInvalid          = errors.New("email address is not valid")
+	ErrTooLong          = errors.New("email address is too long")
+	ErrLocalPartTooLong = errors.New("email address part before the @ is too long")
+	ErrInvalidDomain    = errors.New("email address domain is not valid")
+)
+
+// Validate checks that addr is a bare address, such as user@example.com,
+// without a display name or angle brackets
+func Validate(addr string) error {
+	// ParseAddress also accepts "Name <addr>" and surrounding comments
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil || parsed.Name != "" || strings.ContainsAny(addr, "<>") || strings.TrimSpace(addr) != addr {
+		return ErrInvalid
+	}
+
+	local, domain, _ := cut(addr)
+	ascii, err := domainToASCII(domain)
+	if err != nil {
+		return ErrInvalidDomain
+	}
+	// A single label, as in a@b, is only deliverable inside a network
+	if !strings.Contains(ascii, ".") {
+		return ErrInvalidDomain
+	}
+	if len(local) > MaxLocalLength {
+		return ErrLocalPartTooLong
+	}
+	if len(local)+1+len(ascii) > MaxLength {
+		return ErrTooLong
+	}
+	return nil
+}
+
+// Normalize converts an internationalized domain of addr to punycode, so
+// bücher.example is stored and looked up as xn--bcher-kva.example. ASCII
+// domains, and addresses that aren't valid, are returned unchanged.
+func Normalize(addr string) string {
+	local, domain, ok := cut(addr)
+	if !ok || isASCII(domain) {
+		return addr
+	}
+	ascii, err := domainToASCII(domain)
+	if err != nil {
+		return addr
+	}
+	return local + "@" + ascii
+}
+
+// Domain returns the domain of addr in punycode, or "" when addr has none
+func Domain(addr string) string {
+	_, domain, ok := cut(addr)
+	if !ok {
+		return ""
+	}
+	ascii, err := domainToASCII(domain)
+	if err != nil {
+		return ""
+	}
+	return ascii
+}
+
+// cut splits addr at its last @; quoted local parts may contain another
+func cut(addr string) (local, domain string, ok bool) {
+	at := strings.LastIndex(addr, "@")
+	if at < 0 {
+		return addr, "", false
+	}
+	return addr[:at], addr[at+1:], true
+}
+
+func domainToASCII(domain string) (string, error) {
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(domain, "."))
+	if err != nil || ascii == "" || strings.HasPrefix(ascii, ".") || strings.HasSuffix(ascii, ".") {
+		return "", ErrInvalidDomain
+	}
+	return ascii, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
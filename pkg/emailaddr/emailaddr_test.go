@@ -0,0 +1,133 @@
+package emailaddr
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		addr string
+		want error
+	}{
+		{"john@example.com", nil},
+		{"john.doe+tag@mail.example.co.uk", nil},
+		{`"john doe"@example.com`, nil},
+		{"josé@example.com", nil},
+		{"user@bücher.example", nil},
+		{"user@xn--bcher-kva.example", nil},
+		{"a@b", ErrInvalidDomain},
+		{"user@localhost", ErrInvalidDomain},
+		{"user@.example.com", ErrInvalid},
+		{"user@[127.0.0.1]", ErrInvalidDomain},
+		{"John <john@example.com>", ErrInvalid},
+		{"<john@example.com>", ErrInvalid},
+		{"john@example.com (John)", ErrInvalid},
+		{" john@example.com", ErrInvalid},
+		{"john", ErrInvalid},
+		{"john@", ErrInvalid},
+		{"@example.com", ErrInvalid},
+		{"jo hn@example.com", ErrInvalid},
+		{"", ErrInvalid},
+	}
+	for _, tt := range tests {
+		assert.ErrorIs(t, Validate(tt.addr), tt.want, tt.addr)
+	}
+}
+
+func TestValidate_LengthLimits(t *testing.T) {
+	local := strings.Repeat("a", MaxLocalLength)
+	assert.NoError(t, Validate(local+"@example.com"))
+	assert.ErrorIs(t, Validate(local+"a@example.com"), ErrLocalPartTooLong)
+
+	// Labels are at most 63 bytes, so build a long domain out of several
+	label := strings.Repeat("d", 63)
+	domain := strings.Join([]string{label, label, label, label}, ".") + ".com"
+	addr := local + "@" + domain
+	require.Greater(t, len(addr), MaxLength)
+	assert.ErrorIs(t, Validate(addr), ErrTooLong)
+
+	fits := local + "@" + strings.Join([]string{label, label, label}, ".") + ".com"
+	require.LessOrEqual(t, len(fits), MaxLength)
+	assert.NoError(t, Validate(fits))
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "user@xn--bcher-kva.example", Normalize("user@bücher.example"))
+	assert.Equal(t, "user@xn--bcher-kva.example", Normalize("user@BÜCHER.example"), "IDN domains are case-folded")
+	assert.Equal(t, "josé@xn--mnchen-3ya.de", Normalize("josé@münchen.de"), "the local part is kept")
+	assert.Equal(t, "John@Example.com", Normalize("John@Example.com"), "ASCII addresses are left alone")
+	assert.Equal(t, "not-an-address", Normalize("not-an-address"))
+}
+
+type fakeResolver struct {
+	mx      []*net.MX
+	mxErr   error
+	hosts   []string
+	hostErr error
+	block   bool
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if r.block {
+		<-ctx.Done()
+		return nil, &net.DNSError{Err: "i/o timeout", Name: name, IsTimeout: true}
+	}
+	return r.mx, r.mxErr
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return r.hosts, r.hostErr
+}
+
+func notFoundErr(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func TestMXChecker(t *testing.T) {
+	ctx := context.Background()
+	check := func(r *fakeResolver) error {
+		return NewMXChecker(r, DefaultMXTimeout).Check(ctx, "user@example.com")
+	}
+
+	assert.NoError(t, check(&fakeResolver{mx: []*net.MX{{Host: "mx.example.com.", Pref: 10}}}))
+	assert.NoError(t, check(&fakeResolver{mxErr: notFoundErr("example.com"), hosts: []string{"192.0.2.1"}}), "falls back to address records")
+	assert.ErrorIs(t, check(&fakeResolver{mxErr: notFoundErr("example.com"), hostErr: notFoundErr("example.com")}), ErrNoMailServer)
+	assert.ErrorIs(t, check(&fakeResolver{mx: []*net.MX{{Host: "."}}}), ErrNoMailServer, "null MX")
+	assert.NoError(t, check(&fakeResolver{mxErr: &net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}}), "resolver failures fail open")
+}
+
+func TestMXChecker_TimeoutFailsOpen(t *testing.T) {
+	checker := NewMXChecker(&fakeResolver{block: true}, 20*time.Millisecond)
+
+	start := time.Now()
+	err := checker.Check(context.Background(), "user@slow.example")
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second, "the lookup is abandoned at the timeout")
+}
+
+func TestMXChecker_LooksUpPunycode(t *testing.T) {
+	var asked string
+	r := &recordingResolver{asked: &asked}
+	require.NoError(t, NewMXChecker(r, DefaultMXTimeout).Check(context.Background(), "user@bücher.example"))
+	assert.Equal(t, "xn--bcher-kva.example", asked)
+}
+
+type recordingResolver struct {
+	asked *string
+}
+
+func (r *recordingResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	*r.asked = name
+	return []*net.MX{{Host: "mx." + name + "."}}, nil
+}
+
+func (r *recordingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, nil
+}
@@ -0,0 +1,85 @@
+package emailaddr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+// DefaultMXTimeout bounds the DNS lookups of a check, so a slow resolver
+// delays a signup by at most this long
+const DefaultMXTimeout = 500 * time.Millisecond
+
+// ErrNoMailServer means DNS says the domain can't receive mail
+var ErrNoMailServer = errors.New("email address domain does not accept mail")
+
+// Resolver looks up mail servers; *net.Resolver satisfies it
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// MXChecker checks that an address's domain has somewhere to deliver mail
+type MXChecker struct {
+	resolver Resolver
+	timeout  time.Duration
+}
+
+// NewMXChecker creates a checker asking resolver, each check giving up
+// after timeout
+func NewMXChecker(resolver Resolver, timeout time.Duration) *MXChecker {
+	return &MXChecker{resolver: resolver, timeout: timeout}
+}
+
+// Check returns ErrNoMailServer only when DNS answers that the domain of
+// addr doesn't exist or publishes a null MX (RFC 7505). A domain without MX
+// records is delivered to its address records instead, as RFC 5321 allows.
+// Timeouts and resolver failures fail open and return nil, so an outage of
+// DNS doesn't block signups.
+func (c *MXChecker) Check(ctx context.Context, addr string) error {
+	domain := Domain(addr)
+	if domain == "" {
+		return ErrInvalidDomain
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	records, err := c.resolver.LookupMX(ctx, domain)
+	if err == nil {
+		if len(records) == 1 && records[0].Host == "." {
+			return ErrNoMailServer
+		}
+		if len(records) > 0 {
+			return nil
+		}
+	} else if !notFound(err) {
+		return failOpen(domain, err)
+	}
+
+	if _, err := c.resolver.LookupHost(ctx, domain); err != nil {
+		if notFound(err) {
+			return ErrNoMailServer
+		}
+		return failOpen(domain, err)
+	}
+	return nil
+}
+
+// notFound reports whether err is DNS answering that the name has no
+// records, rather than failing to answer
+func notFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound && !dnsErr.IsTimeout
+}
+
+func failOpen(domain string, err error) error {
+	logger.Warn("email domain lookup failed, accepting the address", map[string]any{
+		"domain": domain,
+		"error":  err.Error(),
+	})
+	return nil
+}
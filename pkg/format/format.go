@@ -0,0 +1,130 @@
+// Package format renders times and numbers for people to read, in English
+// or Thai. Other locales fall back to English.
+package format
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Supported languages
+const (
+	English = "en"
+	Thai    = "th"
+)
+
+// Language returns the supported language of a BCP 47 locale, so th-TH is
+// Thai, and English for anything else, malformed locales included
+func Language(locale string) string {
+	tag, err := language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	if err != nil {
+		return English
+	}
+	if base, _ := tag.Base(); base.String() == Thai {
+		return Thai
+	}
+	return English
+}
+
+// unit is a step of RelativeTime with its names
+type unit struct {
+	d         time.Duration
+	one, many string
+	th        string
+}
+
+// units are tried from the largest; months and years are 30 and 365 days
+var units = []unit{
+	{365 * 24 * time.Hour, "year", "years", "ปี"},
+	{30 * 24 * time.Hour, "month", "months", "เดือน"},
+	{7 * 24 * time.Hour, "week", "weeks", "สัปดาห์"},
+	{24 * time.Hour, "day", "days", "วัน"},
+	{time.Hour, "hour", "hours", "ชั่วโมง"},
+	{time.Minute, "minute", "minutes", "นาที"},
+}
+
+// RelativeTime describes t relative to now in the language of locale, such
+// as "3 days ago", "in 2 hours" or "3 วันที่แล้ว". Less than a minute either
+// way is "just now". Days and longer are counted on the wall clock of now's
+// location, so across a DST change noon the day before is still 1 day ago
+// although only 23 or 25 hours passed.
+func RelativeTime(t, now time.Time, locale string) string {
+	elapsed := now.Sub(t)
+	if wall := wallClock(now).Sub(wallClock(t.In(now.Location()))); abs(wall) >= 24*time.Hour {
+		elapsed = wall
+	}
+	future := elapsed < 0
+	elapsed = abs(elapsed)
+
+	thai := Language(locale) == Thai
+	for _, u := range units {
+		n := int64(elapsed / u.d)
+		if n < 1 {
+			continue
+		}
+		switch {
+		case thai && future:
+			return fmt.Sprintf("อีก %d %s", n, u.th)
+		case thai:
+			return fmt.Sprintf("%d %sที่แล้ว", n, u.th)
+		}
+		name := u.many
+		if n == 1 {
+			name = u.one
+		}
+		if future {
+			return fmt.Sprintf("in %d %s", n, name)
+		}
+		return fmt.Sprintf("%d %s ago", n, name)
+	}
+	if thai {
+		return "เมื่อสักครู่"
+	}
+	return "just now"
+}
+
+// wallClock returns t's date and clock reading as if it were UTC, so
+// subtracting two of them ignores DST changes between
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// thaiMonths are the abbreviated Thai month names
+var thaiMonths = [...]string{
+	"ม.ค.", "ก.พ.", "มี.ค.", "เม.ย.", "พ.ค.", "มิ.ย.",
+	"ก.ค.", "ส.ค.", "ก.ย.", "ต.ค.", "พ.ย.", "ธ.ค.",
+}
+
+// FormatTime renders t in tz, UTC when nil, with the zone's abbreviation.
+// English reads "Mar 10, 2024, 3:00 AM PDT"; Thai uses the 24-hour clock
+// and the Buddhist era, "10 มี.ค. 2567 03:00 น. PDT".
+func FormatTime(t time.Time, locale string, tz *time.Location) string {
+	if tz == nil {
+		tz = time.UTC
+	}
+	t = t.In(tz)
+	if Language(locale) == Thai {
+		return fmt.Sprintf("%d %s %d %s น. %s", t.Day(), thaiMonths[t.Month()-1], t.Year()+543, t.Format("15:04"), t.Format("MST"))
+	}
+	return t.Format("Jan 2, 2006, 3:04 PM MST")
+}
+
+// FormatNumber renders n with the digit grouping of locale, 1,234,567
+func FormatNumber(n int64, locale string) string {
+	tag := language.English
+	if Language(locale) == Thai {
+		tag = language.Thai
+	}
+	return message.NewPrinter(tag).Sprintf("%d", n)
+}
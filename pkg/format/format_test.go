@@ -0,0 +1,95 @@
+package format
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguage(t *testing.T) {
+	for locale, want := range map[string]string{
+		"en":    English,
+		"en-US": English,
+		"th":    Thai,
+		"th-TH": Thai,
+		"th_TH": Thai,
+		"fr":    English,
+		"":      English,
+		"!!":    English,
+	} {
+		assert.Equal(t, want, Language(locale), locale)
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		ago    time.Duration
+		en, th string
+	}{
+		{10 * time.Second, "just now", "เมื่อสักครู่"},
+		{-10 * time.Second, "just now", "เมื่อสักครู่"},
+		{time.Minute, "1 minute ago", "1 นาทีที่แล้ว"},
+		{5 * time.Hour, "5 hours ago", "5 ชั่วโมงที่แล้ว"},
+		{3 * 24 * time.Hour, "3 days ago", "3 วันที่แล้ว"},
+		{-2 * time.Hour, "in 2 hours", "อีก 2 ชั่วโมง"},
+		{14 * 24 * time.Hour, "2 weeks ago", "2 สัปดาห์ที่แล้ว"},
+		{400 * 24 * time.Hour, "1 year ago", "1 ปีที่แล้ว"},
+	}
+	for _, tt := range tests {
+		at := now.Add(-tt.ago)
+		assert.Equal(t, tt.en, RelativeTime(at, now, "en"), tt.ago.String())
+		assert.Equal(t, tt.th, RelativeTime(at, now, "th-TH"), tt.ago.String())
+		assert.Equal(t, tt.en, RelativeTime(at, now, "de"), "unsupported locales read English")
+	}
+}
+
+func TestRelativeTime_AcrossDST(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+
+	// Clocks sprang forward on March 10, 2024: noon to noon is 23 hours
+	before := time.Date(2024, time.March, 9, 12, 0, 0, 0, la)
+	after := time.Date(2024, time.March, 10, 12, 0, 0, 0, la)
+	require.Equal(t, 23*time.Hour, after.Sub(before))
+	assert.Equal(t, "1 day ago", RelativeTime(before, after, "en"))
+	assert.Equal(t, "in 1 day", RelativeTime(after, before, "en"))
+
+	// and fell back on November 3: 25 hours
+	before = time.Date(2024, time.November, 2, 12, 0, 0, 0, la)
+	after = time.Date(2024, time.November, 3, 12, 0, 0, 0, la)
+	require.Equal(t, 25*time.Hour, after.Sub(before))
+	assert.Equal(t, "1 day ago", RelativeTime(before, after, "en"))
+
+	// Within a day, the hours that really passed count
+	before = time.Date(2024, time.March, 10, 1, 30, 0, 0, la)
+	after = time.Date(2024, time.March, 10, 3, 30, 0, 0, la)
+	assert.Equal(t, "1 hour ago", RelativeTime(before, after, "en"))
+}
+
+func TestFormatTime(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	require.NoError(t, err)
+
+	// One second either side of the spring-forward gap
+	gap := time.Date(2024, time.March, 10, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, "Mar 10, 2024, 1:59 AM PST", FormatTime(gap.Add(-time.Minute), "en", la))
+	assert.Equal(t, "Mar 10, 2024, 3:00 AM PDT", FormatTime(gap, "en", la))
+	assert.Equal(t, "10 มี.ค. 2567 03:00 น. PDT", FormatTime(gap, "th", la))
+
+	assert.Equal(t, "Mar 10, 2024, 5:00 PM +07", FormatTime(gap, "en", bangkok))
+	assert.Equal(t, "10 มี.ค. 2567 17:00 น. +07", FormatTime(gap, "th-TH", bangkok))
+	assert.Equal(t, "Mar 10, 2024, 10:00 AM UTC", FormatTime(gap, "en", nil), "no zone is UTC")
+}
+
+func TestFormatNumber(t *testing.T) {
+	assert.Equal(t, "1,234,567", FormatNumber(1234567, "en"))
+	assert.Equal(t, "1,234,567", FormatNumber(1234567, "th"))
+	assert.Equal(t, "-42", FormatNumber(-42, "fr"))
+}
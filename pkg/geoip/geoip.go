@@ -0,0 +1,22 @@
+package geoip
+
+import "context"
+
+// Resolver maps an IP address to a human-readable approximate location
+type Resolver interface {
+	Locate(ctx context.Context, ip string) (string, error)
+}
+
+// NoopResolver resolves nothing. It is the default until a GeoIP database
+// or service is configured.
+type NoopResolver struct{}
+
+// NewNoopResolver creates a resolver that never knows the location
+func NewNoopResolver() *NoopResolver {
+	return &NoopResolver{}
+}
+
+// Locate always returns an empty location
+func (r *NoopResolver) Locate(ctx context.Context, ip string) (string, error) {
+	return "", nil
+}
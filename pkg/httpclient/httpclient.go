@@ -0,0 +1,266 @@
+// Package httpclient is the shared client for outbound HTTP calls. It
+// bounds every attempt with a timeout, retries idempotent requests that hit
+// a connection error or a 5xx, forwards the request ID and baggage, signs
+// requests with HMAC when asked to and logs and measures every attempt, so
+// callers don't hand-roll any of it.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dvith.com/go-service-api/pkg/baggage"
+	"dvith.com/go-service-api/pkg/logger"
+	"dvith.com/go-service-api/pkg/metrics"
+)
+
+// Defaults for the zero fields of Options
+const (
+	DefaultTimeout    = 10 * time.Second
+	DefaultRetries    = 2
+	DefaultBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff = 2 * time.Second
+)
+
+// NoRetries turns retries off when set as Options.Retries
+const NoRetries = -1
+
+// HeaderRequestID carries the request ID to downstream services
+const HeaderRequestID = "X-Request-ID"
+
+var requestDuration = metrics.NewHistogram("http_client_request_duration_seconds", "Outbound HTTP request attempt latency in seconds.", metrics.DefaultBuckets, "client", "method", "host", "status")
+
+// Attempt describes one try of an outbound request
+type Attempt struct {
+	Client   string
+	Method   string
+	Host     string
+	Status   int // 0 when the request failed without a response
+	Attempt  int // 1 for the first try
+	Duration time.Duration
+	Err      error
+}
+
+// Options configures a Client. Zero fields take the defaults above.
+type Options struct {
+	// Name identifies the client in logs and metrics, e.g. "webhook"
+	Name string
+
+	// Timeout bounds each attempt, including reading the response body
+	Timeout time.Duration
+
+	// Retries is how many times an idempotent request is retried after a
+	// connection error or a 5xx; NoRetries turns retries off
+	Retries int
+
+	// Backoff is the delay before the first retry, doubled for every
+	// further one up to MaxBackoff and jittered
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Transport sends the requests, http.DefaultTransport when nil
+	Transport http.RoundTripper
+
+	// HMACKey, when set, signs every attempt with SignRequest, for servers
+	// authenticating callers by HMAC instead of a bearer token
+	HMACKey *HMACKey
+
+	// Observe is called after every attempt, e.g. to feed metrics beyond
+	// the http_client_request_duration_seconds histogram
+	Observe func(Attempt)
+}
+
+// Client is an *http.Client whose transport applies Options. Use it like
+// any *http.Client.
+type Client struct {
+	*http.Client
+}
+
+// New creates a client applying opts
+func New(opts Options) *Client {
+	if opts.Name == "" {
+		opts.Name = "default"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	switch {
+	case opts.Retries == NoRetries:
+		opts.Retries = 0
+	case opts.Retries <= 0:
+		opts.Retries = DefaultRetries
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	return &Client{Client: &http.Client{Transport: &transport{opts: opts}}}
+}
+
+// transport implements the retries, timeouts and logging of a Client
+type transport struct {
+	opts Options
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if id := RequestID(ctx); id != "" && req.Header.Get(HeaderRequestID) == "" {
+		req = req.Clone(ctx)
+		req.Header.Set(HeaderRequestID, id)
+	}
+	if b := baggage.FromContext(ctx); b.Len() > 0 && req.Header.Get(baggage.Header) == "" {
+		req = req.Clone(ctx)
+		req.Header.Set(baggage.Header, b.String())
+	}
+
+	attempts := 1
+	if retryable(req) {
+		attempts += t.opts.Retries
+	}
+
+	for n := 1; ; n++ {
+		if n > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+
+		// Each attempt is signed afresh, as its nonce can only be used once
+		if t.opts.HMACKey != nil {
+			req = req.Clone(ctx)
+			if err := SignRequest(req, *t.opts.HMACKey, time.Now()); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := t.attempt(req, n)
+		if n == attempts || !shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			// Drained so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(t.backoff(n)):
+		}
+	}
+}
+
+// attempt sends req once under the per-attempt timeout, which keeps
+// running until the response body is closed
+func (t *transport) attempt(req *http.Request, n int) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.opts.Timeout)
+	start := time.Now()
+	resp, err := t.opts.Transport.RoundTrip(req.WithContext(ctx))
+	elapsed := time.Since(start)
+
+	a := Attempt{
+		Client:   t.opts.Name,
+		Method:   req.Method,
+		Host:     req.URL.Host,
+		Attempt:  n,
+		Duration: elapsed,
+		Err:      err,
+	}
+	if err != nil {
+		cancel()
+	} else {
+		a.Status = resp.StatusCode
+		resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	}
+	t.observe(a)
+	return resp, err
+}
+
+func (t *transport) observe(a Attempt) {
+	status := strconv.Itoa(a.Status)
+	if a.Err != nil {
+		status = "error"
+	}
+	requestDuration.Observe(a.Duration.Seconds(), a.Client, a.Method, a.Host, status)
+
+	fields := map[string]any{
+		"client":      a.Client,
+		"method":      a.Method,
+		"host":        a.Host,
+		"status":      a.Status,
+		"attempt":     a.Attempt,
+		"duration_ms": a.Duration.Milliseconds(),
+	}
+	switch {
+	case a.Err != nil:
+		fields["error"] = a.Err.Error()
+		logger.Warn("outbound request failed", fields)
+	case a.Status >= http.StatusInternalServerError:
+		logger.Warn("outbound request failed", fields)
+	default:
+		logger.Debug("outbound request", fields)
+	}
+
+	if t.opts.Observe != nil {
+		t.opts.Observe(a)
+	}
+}
+
+// backoff returns the jittered delay before retry n
+func (t *transport) backoff(n int) time.Duration {
+	d := t.opts.Backoff << (n - 1)
+	if d <= 0 || d > t.opts.MaxBackoff {
+		d = t.opts.MaxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryable reports whether req may be sent more than once: its method is
+// idempotent and its body, if any, can be replayed
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// shouldRetry reports whether an attempt failed in a way another attempt
+// might not: a 5xx or a connection error, but not the caller giving up
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// cancelOnClose releases an attempt's timeout once its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
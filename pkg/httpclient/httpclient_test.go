@@ -0,0 +1,299 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/baggage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastOptions keeps the backoff out of the test's run time
+func fastOptions(opts Options) Options {
+	opts.Backoff = time.Millisecond
+	opts.MaxBackoff = 2 * time.Millisecond
+	return opts
+}
+
+func TestClient_RetriesIdempotentRequestsOn5xx(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	var attempts []Attempt
+	client := New(fastOptions(Options{Name: "test", Observe: func(a Attempt) { attempts = append(attempts, a) }}))
+
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, int32(3), calls.Load())
+	require.Len(t, attempts, 3)
+	assert.Equal(t, http.StatusServiceUnavailable, attempts[0].Status)
+	assert.Equal(t, 3, attempts[2].Attempt)
+	assert.Equal(t, "test", attempts[2].Client)
+	assert.Equal(t, http.MethodGet, attempts[2].Method)
+	assert.Equal(t, strings.TrimPrefix(srv.URL, "http://"), attempts[2].Host)
+}
+
+func TestClient_GivesUpAfterRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	resp, err := New(fastOptions(Options{Retries: 1})).Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode, "the last response is returned")
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestClient_RetryCounts(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   io.Reader
+		opts   Options
+		status int
+		calls  int32
+	}{
+		{"post is not retried", http.MethodPost, strings.NewReader("x"), Options{}, http.StatusInternalServerError, 1},
+		{"4xx is not retried", http.MethodGet, nil, Options{}, http.StatusNotFound, 1},
+		{"retries turned off", http.MethodGet, nil, Options{Retries: NoRetries}, http.StatusInternalServerError, 1},
+		{"put replays its body", http.MethodPut, strings.NewReader("x"), Options{}, http.StatusInternalServerError, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				if r.Body != nil {
+					body, _ := io.ReadAll(r.Body)
+					if tt.body != nil {
+						assert.Equal(t, "x", string(body))
+					}
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			req, err := http.NewRequest(tt.method, srv.URL, tt.body)
+			require.NoError(t, err)
+			resp, err := New(fastOptions(tt.opts)).Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.calls, calls.Load())
+		})
+	}
+}
+
+func TestClient_RetriesConnectionErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			// Drop the connection without answering
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var errs []error
+	client := New(fastOptions(Options{Observe: func(a Attempt) {
+		mu.Lock()
+		errs = append(errs, a.Err)
+		mu.Unlock()
+	}}))
+
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Len(t, errs, 2)
+	assert.Error(t, errs[0])
+	assert.NoError(t, errs[1])
+}
+
+func TestClient_PropagatesRequestID(t *testing.T) {
+	got := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get(HeaderRequestID)
+	}))
+	defer srv.Close()
+	client := New(Options{})
+
+	req, err := http.NewRequestWithContext(WithRequestID(context.Background(), "req-123"), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "req-123", <-got)
+	assert.Empty(t, req.Header.Get(HeaderRequestID), "the caller's request is left alone")
+
+	req, err = http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, <-got, "no header without a request ID")
+}
+
+func TestClient_PropagatesBaggage(t *testing.T) {
+	got := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get(baggage.Header)
+	}))
+	defer srv.Close()
+	client := New(Options{})
+
+	b, err := baggage.Parse("tier=gold;source=gw, cohort=a%20b")
+	require.NoError(t, err)
+	req, err := http.NewRequestWithContext(baggage.NewContext(context.Background(), b), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "tier=gold;source=gw,cohort=a%20b", <-got)
+
+	// A header the caller set wins
+	req, err = http.NewRequestWithContext(baggage.NewContext(context.Background(), b), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set(baggage.Header, "tier=silver")
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "tier=silver", <-got)
+}
+
+func TestClient_TimesOutEachAttempt(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := New(fastOptions(Options{Timeout: 50 * time.Millisecond, Retries: 1})).Get(srv.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(2), calls.Load(), "a timed out attempt is retried")
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestClient_TimeoutCoversBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := New(Options{Timeout: 50 * time.Millisecond}).Get(srv.URL)
+	require.NoError(t, err, "headers arrive in time")
+	defer resp.Body.Close()
+
+	_, err = io.ReadAll(resp.Body)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_StopsWhenCallerCancels(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := New(Options{Backoff: time.Second, Observe: func(Attempt) { cancel() }})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "no retry once the caller gave up")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestClient_SignsEveryAttempt(t *testing.T) {
+	key := HMACKey{ID: "svc", Secret: []byte("shared-secret")}
+	var mu sync.Mutex
+	var nonces []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		p, err := ParseHMACAuthorization(r.Header.Get("Authorization"))
+		if err != nil || p.KeyID != key.ID || !VerifyHMACSignature(key.Secret, r.Method, r.URL.RequestURI(), p, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		nonces = append(nonces, p.Nonce)
+		n := len(nonces)
+		mu.Unlock()
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	client := New(fastOptions(Options{HMACKey: &key}))
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/orders/1?dry_run=true", strings.NewReader(`{"qty":2}`))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, nonces, 2)
+	assert.NotEqual(t, nonces[0], nonces[1], "a retry is signed with a new nonce")
+	assert.Empty(t, req.Header.Get("Authorization"), "the caller's request is left alone")
+}
+
+func TestParseHMACAuthorization(t *testing.T) {
+	p, err := ParseHMACAuthorization(`HMAC keyId="svc", signature="c2ln", ts=1760443200, nonce="bm9uY2U"`)
+	require.NoError(t, err)
+	assert.Equal(t, HMACParams{KeyID: "svc", Signature: "c2ln", Timestamp: 1760443200, Nonce: "bm9uY2U"}, p)
+
+	round, err := ParseHMACAuthorization(p.String())
+	require.NoError(t, err)
+	assert.Equal(t, p, round)
+
+	for _, header := range []string{"", "Bearer abc", "HMAC keyId=svc,signature=c2ln,nonce=n", "HMAC keyId=svc,signature=c2ln,ts=soon,nonce=n"} {
+		_, err := ParseHMACAuthorization(header)
+		assert.ErrorIs(t, err, ErrMalformedHMAC, header)
+	}
+}
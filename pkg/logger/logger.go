@@ -5,6 +5,7 @@ import (
 	"maps"
 	"os"
 	"strings"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 )
@@ -66,6 +67,8 @@ func newTextFormatter(colors bool) logrus.Formatter {
 type Logger struct {
 	logrus *logrus.Logger
 	fields map[string]any
+	// maxFieldLength is shared with child loggers, like the level
+	maxFieldLength *atomic.Int64
 }
 
 // NewLogger constructs a new Logger using logrus backend.
@@ -86,9 +89,13 @@ func NewLogger(out io.Writer, level Level, jsonFmt bool) *Logger {
 		l.SetFormatter(newTextFormatter(false))
 	}
 
+	maxFieldLength := new(atomic.Int64)
+	maxFieldLength.Store(DefaultMaxFieldLength)
+
 	return &Logger{
-		logrus: l,
-		fields: make(map[string]any),
+		logrus:         l,
+		fields:         make(map[string]any),
+		maxFieldLength: maxFieldLength,
 	}
 }
 
@@ -99,7 +106,8 @@ func NewDefault() *Logger {
 
 func (l *Logger) clone() *Logger {
 	nl := &Logger{
-		logrus: l.logrus,
+		logrus:         l.logrus,
+		maxFieldLength: l.maxFieldLength,
 	}
 	nl.fields = make(map[string]any, len(l.fields))
 	for k, v := range l.fields {
@@ -123,6 +131,34 @@ func (l *Logger) SetLevel(level Level) {
 	l.logrus.SetLevel(toLogrusLevel(level))
 }
 
+// Level returns the logger's current level.
+func (l *Logger) Level() Level {
+	switch l.logrus.GetLevel() {
+	case logrus.DebugLevel, logrus.TraceLevel:
+		return DebugLevel
+	case logrus.WarnLevel:
+		return WarnLevel
+	case logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel:
+		return ErrorLevel
+	default:
+		return InfoLevel
+	}
+}
+
+// SetMaxFieldLength sets the longest string value written in a field, in
+// bytes; longer ones are truncated. n <= 0 restores DefaultMaxFieldLength.
+func (l *Logger) SetMaxFieldLength(n int) {
+	if n <= 0 {
+		n = DefaultMaxFieldLength
+	}
+	l.maxFieldLength.Store(int64(n))
+}
+
+// SetOutput replaces where log entries are written.
+func (l *Logger) SetOutput(out io.Writer) {
+	l.logrus.SetOutput(out)
+}
+
 // SetJSON toggles JSON output.
 func (l *Logger) SetJSON(jsonFmt bool) {
 	if jsonFmt {
@@ -140,6 +176,14 @@ func (l *Logger) log(level Level, msg string, fields map[string]any) {
 	for k, v := range fields {
 		data[k] = v
 	}
+	san := sanitizer{maxLength: int(l.maxFieldLength.Load())}
+	for k, v := range data {
+		if IsSensitiveKey(k) {
+			data[k] = RedactedValue
+		} else {
+			data[k] = san.value(v, 0)
+		}
+	}
 
 	entry := l.logrus.WithFields(data)
 
@@ -185,6 +229,12 @@ func SetLevel(level Level) { std.SetLevel(level) }
 // SetJSON toggles JSON output on the default logger.
 func SetJSON(jsonFmt bool) { std.SetJSON(jsonFmt) }
 
+// SetMaxFieldLength sets the longest field value the default logger writes.
+func SetMaxFieldLength(n int) { std.SetMaxFieldLength(n) }
+
+// SetOutput replaces where the default logger writes.
+func SetOutput(out io.Writer) { std.SetOutput(out) }
+
 // LevelFromEnv returns the log level appropriate for the given environment name.
 //
 //	development, local → DebugLevel  (all logs)
@@ -204,6 +254,23 @@ func LevelFromEnv(env string) Level {
 	}
 }
 
+// ParseLevel returns the level named by s: debug, info, warn or error, in
+// any case. Unknown names return InfoLevel and false.
+func ParseLevel(s string) (Level, bool) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return DebugLevel, true
+	case "info":
+		return InfoLevel, true
+	case "warn", "warning":
+		return WarnLevel, true
+	case "error":
+		return ErrorLevel, true
+	default:
+		return InfoLevel, false
+	}
+}
+
 // InitFromEnv configures the default logger for the given application environment.
 // It sets the log level automatically and enables coloured output for
 // development/local environments.
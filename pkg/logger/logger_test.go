@@ -99,3 +99,27 @@ func TestPackageHelpers(t *testing.T) {
 		assert.Contains(t, out, "i", "info should be printed")
 	})
 }
+
+func TestSensitiveFieldsRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf, InfoLevel, true).WithFields(map[string]any{"client_secret": "s3cr3t"})
+
+	l.Info("signin", map[string]any{"password": "hunter2", "refresh_token": "rt", "token_type": "Bearer", "user_id": "42"})
+
+	var obj map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	assert.Equal(t, RedactedValue, obj["password"])
+	assert.Equal(t, RedactedValue, obj["refresh_token"])
+	assert.Equal(t, RedactedValue, obj["client_secret"])
+	assert.Equal(t, "Bearer", obj["token_type"])
+	assert.Equal(t, "42", obj["user_id"])
+}
+
+func TestIsSensitiveKey(t *testing.T) {
+	for _, key := range []string{"password", "New-Password", "Authorization", "Set-Cookie", "X-Api-Key", "access_token", "X-CSRF-Token", "code_verifier"} {
+		assert.True(t, IsSensitiveKey(key), key)
+	}
+	for _, key := range []string{"email", "token_type", "token_tenant", "Content-Type", "expires_in"} {
+		assert.False(t, IsSensitiveKey(key), key)
+	}
+}
@@ -0,0 +1,46 @@
+package logger
+
+import "strings"
+
+// RedactedValue replaces the value of sensitive fields
+const RedactedValue = "[REDACTED]"
+
+// sensitiveKeys are field, header and JSON key names whose values are never
+// written out, compared after normalising with normalizeKey
+var sensitiveKeys = map[string]bool{
+	"password":            true,
+	"password_hash":       true,
+	"token":               true,
+	"secret":              true,
+	"authorization":       true,
+	"proxy_authorization": true,
+	"cookie":              true,
+	"set_cookie":          true,
+	"api_key":             true,
+	"x_api_key":           true,
+	"code_verifier":       true,
+}
+
+// sensitiveSuffixes catch the variants of the keys above, e.g.
+// new_password, refresh_token or client_secret
+var sensitiveSuffixes = []string{"_password", "_token", "_secret"}
+
+// IsSensitiveKey reports whether values under key must be redacted. Keys
+// are matched case-insensitively with dashes treated as underscores, so
+// the rules apply alike to log fields, HTTP headers and JSON bodies.
+func IsSensitiveKey(key string) bool {
+	k := normalizeKey(key)
+	if sensitiveKeys[k] {
+		return true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(k, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "-", "_")
+}
@@ -0,0 +1,173 @@
+package logger
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"unicode/utf8"
+)
+
+// DefaultMaxFieldLength is the longest string value written in a field, in
+// bytes, before it is truncated
+const DefaultMaxFieldLength = 4096
+
+// maxFieldDepth is how deeply nested maps and slices are written out
+const maxFieldDepth = 8
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// sanitizer turns field values into ones both formatters can write, so a
+// log call always produces exactly one well-formed entry, whatever it is
+// given. Values that don't serialize, such as channels and funcs, are
+// replaced with their type, long strings are truncated and nesting is
+// limited.
+type sanitizer struct {
+	maxLength int
+}
+
+func (s sanitizer) value(v any, depth int) any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return s.truncate(v)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		return v
+	case float64:
+		return finite(v)
+	case float32:
+		return finite(float64(v))
+	case error:
+		return s.truncate(errorString(v))
+	}
+
+	rv := reflect.ValueOf(v)
+	t := rv.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return s.marshaled(v, depth)
+	}
+
+	switch rv.Kind() {
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return typeName(v)
+	case reflect.String:
+		return s.truncate(rv.String())
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return finite(f)
+		}
+		return v
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return s.marshaled(v, depth)
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+		if depth >= maxFieldDepth {
+			return tooDeep(v)
+		}
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = s.value(iter.Value().Interface(), depth+1)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return s.marshaled(v, depth)
+		}
+		if depth >= maxFieldDepth {
+			return tooDeep(v)
+		}
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = s.value(rv.Index(i).Interface(), depth+1)
+		}
+		return items
+	default:
+		return s.marshaled(v, depth)
+	}
+}
+
+// marshaled checks v encodes as JSON. Values encoding to a short document
+// are kept as they are, so the text format still prints them its way;
+// longer ones are replaced by their decoded JSON, sanitized in turn.
+func (s sanitizer) marshaled(v any, depth int) any {
+	b, ok := marshal(v)
+	if !ok {
+		return typeName(v)
+	}
+	if len(b) <= s.maxLength {
+		return v
+	}
+	var decoded any
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return typeName(v)
+	}
+	return s.value(decoded, depth)
+}
+
+// truncate cuts str to the maximum length, on a rune boundary
+func (s sanitizer) truncate(str string) string {
+	if len(str) <= s.maxLength {
+		return str
+	}
+	cut := s.maxLength
+	for cut > 0 && !utf8.RuneStart(str[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s...(%d bytes truncated)", str[:cut], len(str)-cut)
+}
+
+// marshal encodes v as JSON, failing rather than panicking on broken
+// MarshalJSON methods
+func marshal(v any) (b []byte, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	b, err := json.Marshal(v)
+	return b, err == nil
+}
+
+// errorString is err.Error(), or the error's type when that panics, as it
+// can on a nil pointer
+func errorString(err error) (s string) {
+	defer func() {
+		if recover() != nil {
+			s = typeName(err)
+		}
+	}()
+	return err.Error()
+}
+
+// finite replaces the floats JSON can't represent with their name
+func finite(f float64) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprint(f)
+	}
+	return f
+}
+
+func typeName(v any) string {
+	return fmt.Sprintf("%T", v)
+}
+
+func tooDeep(v any) string {
+	return fmt.Sprintf("%T (nested too deep)", v)
+}
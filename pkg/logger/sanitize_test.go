@@ -0,0 +1,218 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type panickingMarshaler struct{}
+
+func (panickingMarshaler) MarshalJSON() ([]byte, error) { panic("boom") }
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalJSON() ([]byte, error) { return nil, errors.New("nope") }
+
+type nilError struct{ msg string }
+
+func (e *nilError) Error() string { return e.msg }
+
+type withHandler struct {
+	Name    string
+	Handler func()
+}
+
+// logJSON logs fields once and returns the one entry written
+func logJSON(t *testing.T, l *Logger, fields map[string]any) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	l.SetOutput(&buf)
+	l.Info("entry", fields)
+
+	out := buf.String()
+	require.Equal(t, 1, strings.Count(out, "\n"), "exactly one line: %q", out)
+	var obj map[string]any
+	require.NoError(t, json.Unmarshal([]byte(out), &obj), out)
+	require.Equal(t, "entry", obj["msg"], out)
+	return obj
+}
+
+func TestLogger_UnserializableFields(t *testing.T) {
+	l := NewLogger(nil, InfoLevel, true)
+	var broken *nilError
+
+	obj := logJSON(t, l, map[string]any{
+		"chan":      make(chan int),
+		"func":      func() {},
+		"complex":   complex(1, 2),
+		"nan":       math.NaN(),
+		"inf":       math.Inf(-1),
+		"panics":    panickingMarshaler{},
+		"fails":     failingMarshaler{},
+		"nil_error": error(broken),
+		"struct":    withHandler{Name: "x", Handler: func() {}},
+		"nested":    map[string]any{"ch": make(chan struct{}), "ok": 1},
+		"kept":      "value",
+	})
+
+	assert.Equal(t, "chan int", obj["chan"])
+	assert.Equal(t, "func()", obj["func"])
+	assert.Equal(t, "complex128", obj["complex"])
+	assert.Equal(t, "NaN", obj["nan"])
+	assert.Equal(t, "-Inf", obj["inf"])
+	assert.Equal(t, "logger.panickingMarshaler", obj["panics"])
+	assert.Equal(t, "logger.failingMarshaler", obj["fails"])
+	assert.Equal(t, "*logger.nilError", obj["nil_error"])
+	assert.Equal(t, "logger.withHandler", obj["struct"])
+	assert.Equal(t, map[string]any{"ch": "chan struct {}", "ok": float64(1)}, obj["nested"])
+	assert.Equal(t, "value", obj["kept"])
+}
+
+func TestLogger_KeepsSerializableFields(t *testing.T) {
+	l := NewLogger(nil, InfoLevel, true)
+	at := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+
+	obj := logJSON(t, l, map[string]any{
+		"at":       at,
+		"duration": time.Second,
+		"error":    errors.New("failed"),
+		"ids":      []int{1, 2},
+		"bytes":    []byte("hi"),
+		"ptr":      &withHandler{Name: "x"},
+		"nil":      nil,
+	})
+
+	assert.Equal(t, "2026-10-14T09:00:00Z", obj["at"])
+	assert.Equal(t, float64(time.Second), obj["duration"])
+	assert.Equal(t, "failed", obj["error"])
+	assert.Equal(t, []any{float64(1), float64(2)}, obj["ids"])
+	assert.Equal(t, "aGk=", obj["bytes"])
+	assert.Equal(t, "*logger.withHandler", obj["ptr"], "pointers to unserializable structs are replaced")
+	assert.Nil(t, obj["nil"])
+}
+
+func TestLogger_TruncatesLongStrings(t *testing.T) {
+	l := NewLogger(nil, InfoLevel, true)
+	l.SetMaxFieldLength(10)
+
+	obj := logJSON(t, l, map[string]any{
+		"short":  "0123456789",
+		"long":   strings.Repeat("a", 25),
+		"runes":  strings.Repeat("é", 8),
+		"nested": []any{map[string]string{"body": strings.Repeat("b", 20)}},
+		"error":  errors.New(strings.Repeat("e", 11)),
+	})
+
+	assert.Equal(t, "0123456789", obj["short"])
+	assert.Equal(t, "aaaaaaaaaa...(15 bytes truncated)", obj["long"])
+	assert.Equal(t, "ééééé...(6 bytes truncated)", obj["runes"], "cut on a rune boundary")
+	assert.Equal(t, []any{map[string]any{"body": "bbbbbbbbbb...(10 bytes truncated)"}}, obj["nested"])
+	assert.Equal(t, "eeeeeeeeee...(1 bytes truncated)", obj["error"])
+
+	// Child loggers follow the limit, like the level
+	child := l.WithFields(map[string]any{"service": strings.Repeat("s", 11)})
+	l.SetMaxFieldLength(0)
+	obj = logJSON(t, child, map[string]any{"long": strings.Repeat("a", 25)})
+	assert.Equal(t, strings.Repeat("s", 11), obj["service"])
+	assert.Equal(t, strings.Repeat("a", 25), obj["long"])
+}
+
+func TestLogger_LimitsNesting(t *testing.T) {
+	l := NewLogger(nil, InfoLevel, true)
+
+	loop := map[string]any{}
+	loop["self"] = loop
+	list := []any{nil}
+	list[0] = list
+
+	obj := logJSON(t, l, map[string]any{"loop": loop, "list": list})
+
+	depth := 0
+	for v := obj["loop"]; ; depth++ {
+		m, ok := v.(map[string]any)
+		if !ok {
+			assert.Equal(t, "map[string]interface {} (nested too deep)", v)
+			break
+		}
+		v = m["self"]
+	}
+	assert.Equal(t, maxFieldDepth, depth)
+
+	depth = 0
+	for v := obj["list"]; ; depth++ {
+		items, ok := v.([]any)
+		if !ok {
+			assert.Equal(t, "[]interface {} (nested too deep)", v)
+			break
+		}
+		v = items[0]
+	}
+	assert.Equal(t, maxFieldDepth, depth)
+}
+
+func TestLogger_TextFormatOneLine(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf, InfoLevel, false)
+
+	l.Info("entry", map[string]any{"func": func() {}, "multiline": "a\nb", "nested": map[string]any{"s": "c\nd"}})
+
+	out := buf.String()
+	assert.Equal(t, 1, strings.Count(out, "\n"), out)
+	assert.Contains(t, out, `func="func()"`)
+	assert.NotContains(t, out, "logrus_error")
+}
+
+func FuzzLogger_Fields(f *testing.F) {
+	f.Add("value", 0, 1.5, uint8(0))
+	f.Add("", 3, math.NaN(), uint8(7))
+	f.Add("\x00\xff\n\"", 40, math.Inf(1), uint8(255))
+	f.Add(strings.Repeat("é", 3000), 9, -0.0, uint8(42))
+
+	f.Fuzz(func(t *testing.T, s string, depth int, fl float64, pick uint8) {
+		var nested any = s
+		for range depth % 32 {
+			if depth%2 == 0 {
+				nested = map[string]any{s: nested}
+			} else {
+				nested = []any{nested, fl}
+			}
+		}
+		fields := map[string]any{
+			"string":  s,
+			"float":   fl,
+			"nested":  nested,
+			"bytes":   []byte(s),
+			"error":   errors.New(s),
+			"map_key": map[float64]string{fl: s},
+			"long":    strings.Repeat(s, int(pick)),
+		}
+		adversarial := []any{make(chan string), func() {}, panickingMarshaler{}, failingMarshaler{}, complex(fl, fl), &nilError{}, (*nilError)(nil), withHandler{Name: s}}
+		fields["pick"] = adversarial[int(pick)%len(adversarial)]
+
+		for _, jsonFmt := range []bool{true, false} {
+			var buf bytes.Buffer
+			l := NewLogger(&buf, InfoLevel, jsonFmt)
+			l.SetMaxFieldLength(int(pick))
+			l.Warn("fuzz", fields)
+
+			out := buf.String()
+			if strings.Count(out, "\n") != 1 || !strings.HasSuffix(out, "\n") {
+				t.Fatalf("want exactly one line, got %q", out)
+			}
+			if strings.Contains(out, "logrus_error") {
+				t.Fatalf("logrus dropped a field: %q", out)
+			}
+			if jsonFmt && !json.Valid([]byte(out)) {
+				t.Fatalf("invalid JSON: %q", out)
+			}
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+
+	"dvith.com/go-service-api/pkg/logger"
+)
+
+// Message is a single outgoing email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends transactional email
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer writes messages to the log instead of delivering them. It is the
+// default until a real transport is configured.
+type LogMailer struct{}
+
+// NewLogMailer creates a mailer that only logs messages
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// Send logs the message recipient and subject
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if msg.To == "" {
+		return fmt.Errorf("mail recipient cannot be empty")
+	}
+
+	logger.Info("mail sent", map[string]any{
+		"to":      msg.To,
+		"subject": msg.Subject,
+	})
+	return nil
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.Counter("test_events_total", "Events seen.", "kind")
+	c.Inc("a")
+	c.Add(2, "a")
+	c.Add(-5, "a") // ignored
+	c.Inc("b")
+
+	assert.Equal(t, float64(3), c.Value("a"))
+	assert.Equal(t, float64(1), c.Value("b"))
+
+	g := r.Gauge("test_queue_depth", "Queue depth.")
+	g.Set(10)
+	g.Dec()
+	assert.Equal(t, float64(9), g.Value())
+
+	// Re-registering returns the same family.
+	assert.Equal(t, float64(3), r.Counter("test_events_total", "Events seen.", "kind").Value("a"))
+}
+
+func TestRegister_ConflictPanics(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("conflict", "help")
+
+	assert.Panics(t, func() { r.Gauge("conflict", "help") })
+	assert.Panics(t, func() { r.Counter("conflict", "help", "label") })
+}
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("requests_total", "Requests.", "code").Inc("200")
+	h := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE requests_total counter")
+	assert.Contains(t, out, `requests_total{code="200"} 1`)
+	assert.Contains(t, out, `latency_seconds_bucket{le="0.1"} 1`)
+	assert.Contains(t, out, `latency_seconds_bucket{le="1"} 2`)
+	assert.Contains(t, out, `latency_seconds_bucket{le="+Inf"} 3`)
+	assert.Contains(t, out, "latency_seconds_count 3")
+	assert.Equal(t, uint64(3), h.Count())
+}
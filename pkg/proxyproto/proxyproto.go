@@ -0,0 +1,257 @@
+// Package proxyproto wraps a net.Listener to read the PROXY protocol
+// header (v1 text or v2 binary) that L4 load balancers prepend to each
+// connection. The connection's RemoteAddr then reports the original client
+// instead of the balancer, so c.IP() in handlers needs no further changes.
+//
+// Only enable it behind a balancer that always sends the header and is the
+// only way in: anyone who can connect directly can send a header of their
+// own and pick their address.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHeaderTimeout bounds how long a new connection may take to send
+// its header
+const DefaultHeaderTimeout = 5 * time.Second
+
+// ErrInvalidHeader is returned from Read for connections whose PROXY
+// header can't be parsed; the server then drops the connection
+var ErrInvalidHeader = errors.New("proxyproto: invalid PROXY header")
+
+// v2Signature starts every v2 header
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// v1MaxLength is the longest v1 header allowed by the spec, CRLF included
+const v1MaxLength = 107
+
+// Listener accepts connections and strips their PROXY header
+type Listener struct {
+	net.Listener
+	headerTimeout time.Duration
+}
+
+// NewListener wraps inner. A headerTimeout <= 0 means DefaultHeaderTimeout.
+func NewListener(inner net.Listener, headerTimeout time.Duration) *Listener {
+	if headerTimeout <= 0 {
+		headerTimeout = DefaultHeaderTimeout
+	}
+	return &Listener{Listener: inner, headerTimeout: headerTimeout}
+}
+
+// Accept returns the next connection. The header is read lazily on the
+// connection's first Read or RemoteAddr, so a slow client can't stall the
+// accept loop.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: conn, r: bufio.NewReaderSize(conn, 256), headerTimeout: l.headerTimeout}, nil
+}
+
+// Conn is a connection whose PROXY header has been (or will be) consumed.
+// Connections that arrive without a header, such as health checks that
+// skip the balancer, keep the socket's addresses.
+type Conn struct {
+	net.Conn
+	r             *bufio.Reader
+	headerTimeout time.Duration
+
+	once   sync.Once
+	remote net.Addr
+	local  net.Addr
+	err    error
+
+	mu           sync.Mutex
+	readDeadline time.Time
+}
+
+// Read reads from the connection after the header
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr returns the client address from the header, or the socket's
+// peer when there was none
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address from the header, or the
+// socket's own address when there was none
+func (c *Conn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// SetReadDeadline records the deadline so the header read can restore it
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+// SetDeadline records the read deadline so the header read can restore it
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *Conn) readHeader() {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.headerTimeout))
+	defer func() {
+		c.mu.Lock()
+		deadline := c.readDeadline
+		c.mu.Unlock()
+		_ = c.Conn.SetReadDeadline(deadline)
+	}()
+
+	first, err := c.r.Peek(1)
+	if err != nil {
+		// Let the caller's Read see the connection error itself
+		return
+	}
+	switch first[0] {
+	case 'P':
+		if prefix, err := c.r.Peek(6); err == nil && string(prefix) == "PROXY " {
+			c.remote, c.local, c.err = parseV1(c.r)
+		}
+	case '\r':
+		if sig, err := c.r.Peek(len(v2Signature)); err == nil && bytes.Equal(sig, v2Signature) {
+			c.remote, c.local, c.err = parseV2(c.r)
+		}
+	}
+}
+
+// parseV1 reads a header such as "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"
+func parseV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, fmt.Errorf("%w: v1 header is not CRLF terminated within %d bytes", ErrInvalidHeader, v1MaxLength)
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, fmt.Errorf("%w: malformed v1 header", ErrInvalidHeader)
+	}
+
+	src, err := v1Addr(fields[2], fields[4], fields[1] == "TCP4")
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := v1Addr(fields[3], fields[5], fields[1] == "TCP4")
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+func v1Addr(host, port string, v4 bool) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil || (ip.To4() != nil) != v4 {
+		return nil, fmt.Errorf("%w: bad address %q", ErrInvalidHeader, host)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad port %q", ErrInvalidHeader, port)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// parseV2 reads a binary header: the signature, version/command, family,
+// a big-endian length and then the addresses followed by optional TLVs,
+// which are skipped
+func parseV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var head [16]byte
+	if err := readFull(r, head[:]); err != nil {
+		return nil, nil, err
+	}
+	if head[12]>>4 != 2 {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidHeader, head[12]>>4)
+	}
+	command := head[12] & 0x0f
+	family := head[13]
+
+	payload := make([]byte, binary.BigEndian.Uint16(head[14:16]))
+	if err := readFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+
+	switch command {
+	case 0x0:
+		// LOCAL: the balancer's own connection, e.g. a health check
+		return nil, nil, nil
+	case 0x1:
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported command %d", ErrInvalidHeader, command)
+	}
+
+	var ipLen int
+	switch family >> 4 {
+	case 0x1:
+		ipLen = net.IPv4len
+	case 0x2:
+		ipLen = net.IPv6len
+	default:
+		// AF_UNSPEC and AF_UNIX carry no client IP worth reporting
+		return nil, nil, nil
+	}
+	if len(payload) < 2*ipLen+4 {
+		return nil, nil, fmt.Errorf("%w: address block too short", ErrInvalidHeader)
+	}
+
+	srcIP := net.IP(payload[:ipLen])
+	dstIP := net.IP(payload[ipLen : 2*ipLen])
+	srcPort := int(binary.BigEndian.Uint16(payload[2*ipLen:]))
+	dstPort := int(binary.BigEndian.Uint16(payload[2*ipLen+2:]))
+	if family&0x0f == 0x2 {
+		return &net.UDPAddr{IP: srcIP, Port: srcPort}, &net.UDPAddr{IP: dstIP, Port: dstPort}, nil
+	}
+	return &net.TCPAddr{IP: srcIP, Port: srcPort}, &net.TCPAddr{IP: dstIP, Port: dstPort}, nil
+}
+
+func readFull(r io.Reader, b []byte) error {
+	if _, err := io.ReadFull(r, b); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package proxyproto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accept starts a wrapped listener and returns it with a channel that
+// yields each accepted connection
+func accept(t *testing.T) (*Listener, <-chan net.Conn) {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln := NewListener(inner, time.Second)
+	t.Cleanup(func() { ln.Close() })
+
+	conns := make(chan net.Conn, 1)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- c
+		}
+	}()
+	return ln, conns
+}
+
+// dial sends raw bytes on a fresh connection and returns the server side
+func dial(t *testing.T, ln *Listener, conns <-chan net.Conn, raw []byte) net.Conn {
+	t.Helper()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	_, err = client.Write(raw)
+	require.NoError(t, err)
+
+	select {
+	case c := <-conns:
+		t.Cleanup(func() { c.Close() })
+		return c
+	case <-time.After(time.Second):
+		t.Fatal("connection not accepted")
+		return nil
+	}
+}
+
+func readLine(t *testing.T, c net.Conn) string {
+	t.Helper()
+	line, err := bufio.NewReader(c).ReadString('\n')
+	require.NoError(t, err)
+	return line
+}
+
+func v2Header(command, family byte, addrs []byte) []byte {
+	h := append([]byte{}, v2Signature...)
+	h = append(h, 0x20|command, family)
+	h = binary.BigEndian.AppendUint16(h, uint16(len(addrs)))
+	return append(h, addrs...)
+}
+
+func TestV1_TCP4(t *testing.T) {
+	ln, conns := accept(t)
+	c := dial(t, ln, conns, []byte("PROXY TCP4 203.0.113.7 192.0.2.10 56324 443\r\nGET / HTTP/1.1\r\n"))
+
+	assert.Equal(t, "203.0.113.7:56324", c.RemoteAddr().String())
+	assert.Equal(t, "192.0.2.10:443", c.LocalAddr().String())
+	assert.Equal(t, "GET / HTTP/1.1\r\n", readLine(t, c))
+}
+
+func TestV1_TCP6(t *testing.T) {
+	ln, conns := accept(t)
+	c := dial(t, ln, conns, []byte("PROXY TCP6 2001:db8::1 2001:db8::2 4000 8080\r\nhello\n"))
+
+	assert.Equal(t, "[2001:db8::1]:4000", c.RemoteAddr().String())
+	assert.Equal(t, "hello\n", readLine(t, c))
+}
+
+func TestV1_Unknown(t *testing.T) {
+	ln, conns := accept(t)
+	c := dial(t, ln, conns, []byte("PROXY UNKNOWN\r\nhello\n"))
+
+	assert.Equal(t, "127.0.0.1", c.RemoteAddr().(*net.TCPAddr).IP.String())
+	assert.Equal(t, "hello\n", readLine(t, c))
+}
+
+func TestV2_TCP4AndTLVs(t *testing.T) {
+	addrs := []byte{198, 51, 100, 4, 10, 0, 0, 1}
+	addrs = binary.BigEndian.AppendUint16(addrs, 1234)
+	addrs = binary.BigEndian.AppendUint16(addrs, 80)
+	addrs = append(addrs, 0x04, 0x00, 0x01, 0xff) // a TLV, skipped
+
+	ln, conns := accept(t)
+	c := dial(t, ln, conns, append(v2Header(0x1, 0x11, addrs), "hello\n"...))
+
+	assert.Equal(t, "198.51.100.4:1234", c.RemoteAddr().String())
+	assert.Equal(t, "10.0.0.1:80", c.LocalAddr().String())
+	assert.Equal(t, "hello\n", readLine(t, c))
+}
+
+func TestV2_TCP6(t *testing.T) {
+	src, dst := net.ParseIP("2001:db8::7"), net.ParseIP("2001:db8::8")
+	addrs := append(append([]byte{}, src...), dst...)
+	addrs = binary.BigEndian.AppendUint16(addrs, 5555)
+	addrs = binary.BigEndian.AppendUint16(addrs, 443)
+
+	ln, conns := accept(t)
+	c := dial(t, ln, conns, append(v2Header(0x1, 0x21, addrs), "hello\n"...))
+
+	assert.Equal(t, "[2001:db8::7]:5555", c.RemoteAddr().String())
+	assert.Equal(t, "hello\n", readLine(t, c))
+}
+
+func TestV2_LocalKeepsSocketAddress(t *testing.T) {
+	ln, conns := accept(t)
+	c := dial(t, ln, conns, append(v2Header(0x0, 0x00, nil), "hello\n"...))
+
+	assert.Equal(t, "127.0.0.1", c.RemoteAddr().(*net.TCPAddr).IP.String())
+	assert.Equal(t, "hello\n", readLine(t, c))
+}
+
+func TestNoHeaderPassesThrough(t *testing.T) {
+	ln, conns := accept(t)
+	c := dial(t, ln, conns, []byte("POST / HTTP/1.1\r\n"))
+
+	assert.Equal(t, "127.0.0.1", c.RemoteAddr().(*net.TCPAddr).IP.String())
+	assert.Equal(t, "POST / HTTP/1.1\r\n", readLine(t, c))
+}
+
+func TestInvalidHeaders(t *testing.T) {
+	tests := map[string][]byte{
+		"v1 bad protocol":    []byte("PROXY SCTP 1.2.3.4 5.6.7.8 1 2\r\n"),
+		"v1 family mismatch": []byte("PROXY TCP4 2001:db8::1 5.6.7.8 1 2\r\n"),
+		"v1 bad port":        []byte("PROXY TCP4 1.2.3.4 5.6.7.8 99999 2\r\n"),
+		"v1 no CRLF":         append([]byte("PROXY TCP4 "), make([]byte, 120)...),
+		"v2 bad version":     append(append([]byte{}, v2Signature...), 0x11, 0x11, 0, 0),
+		"v2 short address":   v2Header(0x1, 0x11, []byte{1, 2, 3}),
+	}
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			ln, conns := accept(t)
+			c := dial(t, ln, conns, raw)
+			_, err := c.Read(make([]byte, 1))
+			assert.ErrorIs(t, err, ErrInvalidHeader)
+		})
+	}
+}
+
+func TestHeaderTimeout(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln := NewListener(inner, 50*time.Millisecond)
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Write([]byte("PROXY TCP4 1.2.3.4"))
+	require.NoError(t, err)
+
+	c, err := ln.Accept()
+	require.NoError(t, err)
+	defer c.Close()
+
+	start := time.Now()
+	_, err = c.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestFiberSeesClientIP(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln := NewListener(inner, time.Second)
+
+	app := fiber.New()
+	app.Get("/ip", func(c fiber.Ctx) error { return c.SendString(c.IP()) })
+	go func() { _ = app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true}) }()
+	defer app.Shutdown()
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		conn, err = net.Dial("tcp", inner.Addr().String())
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("PROXY TCP4 203.0.113.9 192.0.2.1 40000 80\r\nGET /ip HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.9", string(body))
+}
@@ -0,0 +1,85 @@
+// Package ratelimit counts requests per key over fixed windows. Take
+// consumes one request and reports the quota left in the same step, so the
+// headers a client sees always match the decision that was made.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"dvith.com/go-service-api/pkg/cache"
+)
+
+// Result is the outcome of one Take
+type Result struct {
+	// Allowed is false once the key has used up its limit for the window
+	Allowed bool
+	// Limit is the number of requests allowed per window
+	Limit int
+	// Remaining is how many requests are left in the window, never negative
+	Remaining int
+	// Reset is when the current window ends and the quota refills
+	Reset time.Time
+}
+
+// Store consumes quota for a key
+type Store interface {
+	// Take counts one request against key, allowing limit per window
+	Take(key string, limit int, window time.Duration) Result
+}
+
+// counter is one key's current window
+type counter struct {
+	mu      sync.Mutex
+	count   int
+	resetAt time.Time
+}
+
+// MemoryStore keeps the counters in a cache.Cache
+type MemoryStore struct {
+	cache cache.Cache
+	now   func() time.Time
+}
+
+// NewMemoryStore creates a store backed by c
+func NewMemoryStore(c cache.Cache) *MemoryStore {
+	return &MemoryStore{cache: c, now: time.Now}
+}
+
+// WithClock replaces the time source, for tests. The cache should share it
+// so counters expire when their window ends.
+func (s *MemoryStore) WithClock(now func() time.Time) *MemoryStore {
+	s.now = now
+	return s
+}
+
+// Take counts one request against key
+func (s *MemoryStore) Take(key string, limit int, window time.Duration) Result {
+	key = "ratelimit:" + key
+	for {
+		now := s.now()
+		s.cache.Add(key, &counter{resetAt: now.Add(window)}, window)
+		v, ok := s.cache.Get(key)
+		if !ok {
+			// The window expired between Add and Get; open a new one
+			continue
+		}
+
+		c := v.(*counter)
+		c.mu.Lock()
+		if !now.Before(c.resetAt) {
+			// Expired but not yet evicted by the cache
+			c.count = 0
+			c.resetAt = now.Add(window)
+		}
+		c.count++
+		res := Result{
+			Allowed:   c.count <= limit,
+			Limit:     limit,
+			Remaining: max(limit-c.count, 0),
+			Reset:     c.resetAt,
+		}
+		c.mu.Unlock()
+		return res
+	}
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dvith.com/go-service-api/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore_WindowBoundary(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	store := NewMemoryStore(cache.NewMemory().WithClock(clock)).WithClock(clock)
+	reset := now.Add(time.Minute)
+
+	for i := 1; i <= 3; i++ {
+		res := store.Take("k", 3, time.Minute)
+		assert.Equal(t, Result{Allowed: true, Limit: 3, Remaining: 3 - i, Reset: reset}, res)
+	}
+	assert.Equal(t, Result{Allowed: false, Limit: 3, Remaining: 0, Reset: reset}, store.Take("k", 3, time.Minute))
+	assert.True(t, store.Take("other", 3, time.Minute).Allowed, "keys are counted separately")
+
+	now = reset
+	assert.Equal(t, Result{Allowed: true, Limit: 3, Remaining: 2, Reset: reset.Add(time.Minute)}, store.Take("k", 3, time.Minute))
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	store := NewMemoryStore(cache.NewMemory())
+
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+	remaining := make([]bool, 50)
+	var mu sync.Mutex
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := store.Take("k", 50, time.Minute)
+			if res.Allowed {
+				allowed.Add(1)
+				mu.Lock()
+				remaining[res.Remaining] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(50), allowed.Load())
+	for n, seen := range remaining {
+		assert.True(t, seen, "remaining %d reported exactly by one caller", n)
+	}
+}
@@ -0,0 +1,179 @@
+// Package signedurl builds and checks self-contained links for emails. A
+// signed link carries its parameters, an expiry and an HMAC over both, so
+// it can be validated without a database lookup.
+package signedurl
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reserved query parameters added by Sign
+const (
+	ParamExpires   = "expires"
+	ParamSignature = "signature"
+)
+
+// DefaultSkew is how far past its expiry a link is still accepted, to
+// tolerate clock differences between the instances signing and verifying
+const DefaultSkew = 30 * time.Second
+
+// keyInfo separates the link key from other keys derived from the same secret
+const keyInfo = "go-service-api signed url v1"
+
+var (
+	// ErrMalformed is returned for links that can't be parsed or lack the
+	// expiry or signature
+	ErrMalformed = errors.New("malformed signed link")
+	// ErrInvalidSignature is returned when the path or any parameter was
+	// changed, added, removed or reordered
+	ErrInvalidSignature = errors.New("invalid link signature")
+	// ErrExpired is returned for links past their expiry
+	ErrExpired = errors.New("link has expired")
+)
+
+// IsClientError reports whether err from Verify is the link's fault
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrMalformed) || errors.Is(err, ErrInvalidSignature) || errors.Is(err, ErrExpired)
+}
+
+// Signer signs links under a base URL
+type Signer struct {
+	base *url.URL
+	key  []byte
+	skew time.Duration
+	now  func() time.Time
+}
+
+// DeriveKey derives the link signing key from secret with HKDF-SHA256, so
+// the secret itself is never used as an HMAC key for links
+func DeriveKey(secret string) ([]byte, error) {
+	if secret == "" {
+		return nil, errors.New("signedurl: secret cannot be empty")
+	}
+	return hkdf.Key(sha256.New, []byte(secret), nil, keyInfo, sha256.Size)
+}
+
+// New creates a signer for links under baseURL, keyed by a key derived
+// from secret
+func New(baseURL, secret string) (*Signer, error) {
+	base, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("signedurl: invalid base URL: %w", err)
+	}
+	key, err := DeriveKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{base: base, key: key, skew: DefaultSkew, now: time.Now}, nil
+}
+
+// MustNew is like New but panics on error, for configuration that was
+// already validated at startup
+func MustNew(baseURL, secret string) *Signer {
+	s, err := New(baseURL, secret)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// WithSkew replaces the expiry tolerance
+func (s *Signer) WithSkew(skew time.Duration) *Signer {
+	s.skew = skew
+	return s
+}
+
+// WithClock replaces the time source, for tests
+func (s *Signer) WithClock(now func() time.Time) *Signer {
+	s.now = now
+	return s
+}
+
+// Sign returns an absolute link to path under the base URL carrying params,
+// valid for ttl
+func (s *Signer) Sign(path string, params map[string]string, ttl time.Duration) (string, error) {
+	for k := range params {
+		if k == ParamExpires || k == ParamSignature {
+			return "", fmt.Errorf("signedurl: parameter %q is reserved", k)
+		}
+	}
+
+	values := make(url.Values, len(params)+1)
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	values.Set(ParamExpires, strconv.FormatInt(s.now().Add(ttl).Unix(), 10))
+
+	fullPath := s.base.Path + "/" + strings.TrimLeft(path, "/")
+	query := values.Encode()
+	link := *s.base
+	link.Path = fullPath
+	link.RawQuery = query + "&" + ParamSignature + "=" + s.sign(link.EscapedPath(), query)
+	return link.String(), nil
+}
+
+// Verify checks a link produced by Sign and returns its parameters without
+// the expiry and signature. Only the path and query are checked, so a link
+// verifies whichever host the request arrived on.
+func (s *Signer) Verify(rawURL string) (map[string]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	values, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	for _, vs := range values {
+		if len(vs) != 1 {
+			return nil, ErrInvalidSignature
+		}
+	}
+
+	signature := values.Get(ParamSignature)
+	expires := values.Get(ParamExpires)
+	if signature == "" || expires == "" {
+		return nil, ErrMalformed
+	}
+	values.Del(ParamSignature)
+
+	// The query must be exactly what Sign produced (Encode sorts by key):
+	// any reordering or extra parameter changes the raw string
+	query := values.Encode()
+	if u.RawQuery != query+"&"+ParamSignature+"="+signature {
+		return nil, ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(u.EscapedPath(), query))) {
+		return nil, ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if s.now().After(time.Unix(unix, 0).Add(s.skew)) {
+		return nil, ErrExpired
+	}
+
+	values.Del(ParamExpires)
+	params := make(map[string]string, len(values))
+	for k := range values {
+		params[k] = values.Get(k)
+	}
+	return params, nil
+}
+
+func (s *Signer) sign(path, query string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(path + "?" + query))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
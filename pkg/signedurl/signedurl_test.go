@@ -0,0 +1,161 @@
+package signedurl
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+func newTestSigner(now *time.Time) *Signer {
+	return MustNew("https://example.com/app", "test-secret").WithClock(func() time.Time { return *now })
+}
+
+func TestSignVerify_RoundTrip(t *testing.T) {
+	now := testNow
+	s := newTestSigner(&now)
+
+	link, err := s.Sign("/verify", map[string]string{"user": "42", "token": "a b/c"}, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(link, "https://example.com/app/verify?"))
+
+	params, err := s.Verify(link)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"user": "42", "token": "a b/c"}, params)
+}
+
+func TestVerify_IgnoresHost(t *testing.T) {
+	now := testNow
+	s := newTestSigner(&now)
+	link, err := s.Sign("/verify", map[string]string{"user": "42"}, time.Hour)
+	require.NoError(t, err)
+
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	_, err = s.Verify(u.RequestURI())
+	assert.NoError(t, err, "handlers verify the request URI, not the public host")
+}
+
+func TestVerify_Tampering(t *testing.T) {
+	now := testNow
+	s := newTestSigner(&now)
+	link, err := s.Sign("/verify", map[string]string{"user": "42", "token": "abc"}, time.Hour)
+	require.NoError(t, err)
+
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	q := u.Query()
+
+	tamper := func(fn func(u *url.URL)) string {
+		c := *u
+		fn(&c)
+		return c.String()
+	}
+
+	tests := map[string]string{
+		"user changed": tamper(func(c *url.URL) {
+			c.RawQuery = strings.Replace(c.RawQuery, "user=42", "user=43", 1)
+		}),
+		"token changed": tamper(func(c *url.URL) {
+			c.RawQuery = strings.Replace(c.RawQuery, "token=abc", "token=abd", 1)
+		}),
+		"expiry extended": tamper(func(c *url.URL) {
+			c.RawQuery = strings.Replace(c.RawQuery, "expires="+q.Get(ParamExpires), "expires=9999999999", 1)
+		}),
+		"signature changed": tamper(func(c *url.URL) {
+			sig := q.Get(ParamSignature)
+			c.RawQuery = strings.Replace(c.RawQuery, sig, "A"+sig[1:], 1)
+		}),
+		"path changed":     tamper(func(c *url.URL) { c.Path = "/app/admin" }),
+		"param added":      tamper(func(c *url.URL) { c.RawQuery = "admin=1&" + c.RawQuery }),
+		"param appended":   tamper(func(c *url.URL) { c.RawQuery += "&admin=1" }),
+		"param duplicated": tamper(func(c *url.URL) { c.RawQuery = "user=42&" + c.RawQuery }),
+		"params reordered": tamper(func(c *url.URL) {
+			parts := strings.Split(c.RawQuery, "&")
+			parts[0], parts[1] = parts[1], parts[0]
+			c.RawQuery = strings.Join(parts, "&")
+		}),
+		"param removed": tamper(func(c *url.URL) {
+			c.RawQuery = strings.Replace(c.RawQuery, "user=42&", "", 1)
+		}),
+	}
+
+	for name, tampered := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.NotEqual(t, link, tampered)
+			_, err := s.Verify(tampered)
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+			assert.True(t, IsClientError(err))
+		})
+	}
+}
+
+func TestVerify_Malformed(t *testing.T) {
+	now := testNow
+	s := newTestSigner(&now)
+
+	for _, link := range []string{
+		"https://example.com/app/verify?user=42",
+		"https://example.com/app/verify?user=42&expires=1",
+		"https://example.com/app/verify?%zz",
+	} {
+		_, err := s.Verify(link)
+		assert.ErrorIs(t, err, ErrMalformed, link)
+	}
+}
+
+func TestVerify_DifferentKeyRejected(t *testing.T) {
+	now := testNow
+	link, err := newTestSigner(&now).Sign("/verify", map[string]string{"user": "42"}, time.Hour)
+	require.NoError(t, err)
+
+	other := MustNew("https://example.com/app", "other-secret").WithClock(func() time.Time { return now })
+	_, err = other.Verify(link)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestVerify_ExpiryAndSkew(t *testing.T) {
+	now := testNow
+	s := newTestSigner(&now).WithSkew(30 * time.Second)
+	link, err := s.Sign("/verify", map[string]string{"user": "42"}, time.Minute)
+	require.NoError(t, err)
+
+	now = testNow.Add(time.Minute + 20*time.Second)
+	_, err = s.Verify(link)
+	assert.NoError(t, err, "a verifier whose clock runs slightly ahead still accepts the link")
+
+	now = testNow.Add(time.Minute + 31*time.Second)
+	_, err = s.Verify(link)
+	assert.ErrorIs(t, err, ErrExpired)
+
+	_, err = s.WithSkew(0).Verify(link)
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestSign_ReservedParams(t *testing.T) {
+	now := testNow
+	s := newTestSigner(&now)
+
+	_, err := s.Sign("/verify", map[string]string{ParamExpires: "1"}, time.Hour)
+	assert.Error(t, err)
+	_, err = s.Sign("/verify", map[string]string{ParamSignature: "x"}, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestDeriveKey(t *testing.T) {
+	a, err := DeriveKey("secret")
+	require.NoError(t, err)
+	b, err := DeriveKey("secret")
+	require.NoError(t, err)
+	assert.Equal(t, a, b)
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, []byte("secret"), a)
+
+	_, err = DeriveKey("")
+	assert.Error(t, err)
+}
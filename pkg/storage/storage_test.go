@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackends(t *testing.T) {
+	dir, err := NewDir(t.TempDir())
+	require.NoError(t, err)
+
+	for name, b := range map[string]Backend{"memory": NewMemory(), "dir": dir} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			require.NoError(t, b.Put(ctx, "exports/a/1.json", strings.NewReader("first")))
+			require.NoError(t, b.Put(ctx, "exports/a/1.json", strings.NewReader("second")))
+
+			r, err := b.Open(ctx, "exports/a/1.json")
+			require.NoError(t, err)
+			data, _ := io.ReadAll(r)
+			r.Close()
+			assert.Equal(t, "second", string(data), "Put replaces the object")
+
+			require.NoError(t, b.Delete(ctx, "exports/a/1.json"))
+			require.NoError(t, b.Delete(ctx, "exports/a/1.json"), "deleting a missing key is not an error")
+			_, err = b.Open(ctx, "exports/a/1.json")
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestDir_RejectsEscapingKeys(t *testing.T) {
+	dir, err := NewDir(t.TempDir())
+	require.NoError(t, err)
+
+	for _, key := range []string{"../outside", "exports/../../outside", ""} {
+		assert.Error(t, dir.Put(context.Background(), key, strings.NewReader("x")), key)
+	}
+}
@@ -0,0 +1,65 @@
+// Package textnorm normalizes user-typed identifiers and names before they
+// are stored or looked up, so values pasted with stray whitespace or
+// invisible characters match what the user sees.
+package textnorm
+
+import (
+	"strings"
+	"unicode"
+
+	"dvith.com/go-service-api/pkg/emailaddr"
+	"golang.org/x/text/unicode/norm"
+)
+
+// invisible are characters that render as nothing and carry no meaning in
+// a name: zero-width space, word joiner and the byte order mark. Zero-width
+// (non-)joiners are kept, since Persian and Indic names and emoji sequences
+// need them.
+var invisible = map[rune]bool{
+	'\u200b': true,
+	'\u2060': true,
+	'\ufeff': true,
+}
+
+// Email trims surrounding whitespace and removes every invisible format
+// character (Unicode category Cf), none of which can appear in an address.
+// An internationalized domain is converted to punycode, see
+// emailaddr.Normalize.
+func Email(s string) string {
+	return emailaddr.Normalize(strings.TrimSpace(stripFormat(s)))
+}
+
+// Username trims surrounding whitespace and removes invisible format
+// characters. Whitespace inside the result is left for validation to
+// reject, see HasSpace.
+func Username(s string) string {
+	return strings.TrimSpace(stripFormat(s))
+}
+
+// FullName removes zero-width spaces, collapses every run of whitespace,
+// tabs and newlines included, into a single space, trims the ends and
+// applies NFC, so a name typed with a combining accent and one typed with
+// the precomposed letter are stored identically.
+func FullName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if invisible[r] {
+			return -1
+		}
+		return r
+	}, s)
+	return norm.NFC.String(strings.Join(strings.Fields(s), " "))
+}
+
+// HasSpace reports whether s contains any Unicode whitespace
+func HasSpace(s string) bool {
+	return strings.IndexFunc(s, unicode.IsSpace) >= 0
+}
+
+func stripFormat(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
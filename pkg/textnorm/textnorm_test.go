@@ -0,0 +1,74 @@
+package textnorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmail(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"clean", "john@example.com", "john@example.com"},
+		{"padded", "  john@example.com \n", "john@example.com"},
+		{"tabs", "\tjohn@example.com\t", "john@example.com"},
+		{"zero-width space", "john\u200b@example.com", "john@example.com"},
+		{"byte order mark", "\ufeffjohn@example.com", "john@example.com"},
+		{"zero-width joiner", "jo\u200dhn@example.com", "john@example.com"},
+		{"internationalized domain", " user@bücher.example ", "user@xn--bcher-kva.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Email(tt.in))
+		})
+	}
+}
+
+func TestUsername(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		want      string
+		wantSpace bool
+	}{
+		{"padded", " john_doe ", "john_doe", false},
+		{"zero-width inside", "john\u200b_doe", "john_doe", false},
+		{"inner space", "john doe", "john doe", true},
+		{"inner tab", "john\tdoe", "john\tdoe", true},
+		{"no-break space", "john\u00a0doe", "john\u00a0doe", true},
+		{"ideographic space", "john\u3000doe", "john\u3000doe", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Username(tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantSpace, HasSpace(got))
+		})
+	}
+}
+
+func TestFullName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"trailing spaces", "John Doe   ", "John Doe"},
+		{"inner runs", "John    Doe", "John Doe"},
+		{"tabs and newlines", "\tJohn\t\tDoe\n", "John Doe"},
+		{"combining accent to NFC", "Jose\u0301 Garci\u0301a", "José García"},
+		{"precomposed stays", "Jos\u00e9", "Jos\u00e9"},
+		{"thai", " สมชาย  ใจดี ", "สมชาย ใจดี"},
+		{"zero-width space stripped", "John\u200b Doe", "John Doe"},
+		{"zero-width non-joiner kept", "می\u200cرود", "می\u200cرود"},
+		{"only whitespace", " \t\u200b ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FullName(tt.in))
+		})
+	}
+}